@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/devops-excellence/automation/go-tools/pkg/migrate"
+	"github.com/devops-excellence/automation/go-tools/pkg/secrets"
+	_ "github.com/lib/pq"
+	"github.com/spf13/cobra"
+)
+
+var (
+	databaseURL   string
+	migrationsDir string
+	dryRun        bool
+	output        string
+)
+
+// newMigrator opens the database and returns a migrator for the migrations directory
+func newMigrator() *migrate.Migrator {
+	if databaseURL == "" {
+		databaseURL = os.Getenv("DATABASE_URL")
+	}
+	if databaseURL == "" {
+		log.Fatalf("A database URL is required (--database-url or DATABASE_URL)")
+	}
+	// The URL embeds the password and may be a reference such as aws-sm:prod/db#url
+	url, err := secrets.NewDefaultResolver().Resolve(context.Background(), databaseURL)
+	if err != nil {
+		log.Fatalf("Failed to resolve database URL: %v", err)
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+
+	return &migrate.Migrator{DB: db, Dir: migrationsDir, DryRun: dryRun, Out: os.Stdout}
+}
+
+func createUpCmd() *cobra.Command {
+	var target int64
+	var allowDrift bool
+
+	upCmd := &cobra.Command{
+		Use:   "up",
+		Short: "Apply pending migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			count, err := newMigrator().Up(context.Background(), target, allowDrift)
+			if err != nil {
+				log.Fatalf("Failed to apply migrations: %v", err)
+			}
+			if !dryRun {
+				fmt.Printf("Applied %d migrations\n", count)
+			}
+		},
+	}
+	upCmd.Flags().Int64Var(&target, "to", 0, "Apply migrations up to and including this version")
+	upCmd.Flags().BoolVar(&allowDrift, "allow-drift", false, "Apply even if applied migrations were edited")
+
+	return upCmd
+}
+
+func createDownCmd() *cobra.Command {
+	var steps int
+
+	downCmd := &cobra.Command{
+		Use:   "down",
+		Short: "Roll back the most recent migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			count, err := newMigrator().Down(context.Background(), steps)
+			if err != nil {
+				log.Fatalf("Failed to roll back migrations: %v", err)
+			}
+			if !dryRun {
+				fmt.Printf("Rolled back %d migrations\n", count)
+			}
+		},
+	}
+	downCmd.Flags().IntVar(&steps, "steps", 1, "Number of migrations to roll back")
+
+	return downCmd
+}
+
+func createStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show applied and pending migrations and detect drift",
+		Run: func(cmd *cobra.Command, args []string) {
+			statuses, err := newMigrator().Status(context.Background())
+			if err != nil {
+				log.Fatalf("Failed to get migration status: %v", err)
+			}
+
+			drift := false
+			for _, s := range statuses {
+				if s.Drift != "" {
+					drift = true
+				}
+			}
+
+			if output == "json" {
+				jsonData, err := json.MarshalIndent(statuses, "", "  ")
+				if err != nil {
+					log.Fatalf("Error marshaling JSON: %v", err)
+				}
+				fmt.Println(string(jsonData))
+			} else {
+				fmt.Printf("%-16s %-40s %-22s %s\n", "VERSION", "NAME", "APPLIED", "DRIFT")
+				for _, s := range statuses {
+					applied := "pending"
+					if s.AppliedAt != nil {
+						applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+					}
+					fmt.Printf("%-16d %-40s %-22s %s\n", s.Version, s.Name, applied, s.Drift)
+				}
+			}
+
+			// Exit with non-zero status so CI can catch edited historical migrations
+			if drift {
+				os.Exit(1)
+			}
+		},
+	}
+}
+
+func createBaselineCmd() *cobra.Command {
+	var version int64
+
+	baselineCmd := &cobra.Command{
+		Use:   "baseline",
+		Short: "Mark migrations as applied on an existing database without running them",
+		Run: func(cmd *cobra.Command, args []string) {
+			count, err := newMigrator().Baseline(context.Background(), version)
+			if err != nil {
+				log.Fatalf("Failed to baseline database: %v", err)
+			}
+			if !dryRun {
+				fmt.Printf("Baselined %d migrations\n", count)
+			}
+		},
+	}
+	baselineCmd.Flags().Int64Var(&version, "version", 0, "Highest version already present in the database")
+	baselineCmd.MarkFlagRequired("version")
+
+	return baselineCmd
+}
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:   "dbmigrate",
+		Short: "Versioned SQL migrations for Postgres-backed services",
+		Long:  `Applies versioned up/down SQL migrations (NNNN_name.up.sql / NNNN_name.down.sql), records checksums and detects edited historical migrations.`,
+	}
+
+	rootCmd.PersistentFlags().StringVar(&databaseURL, "database-url", "", "Postgres connection URL (defaults to DATABASE_URL)")
+	rootCmd.PersistentFlags().StringVarP(&migrationsDir, "dir", "d", "migrations", "Directory containing migration files")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the SQL instead of executing it")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "text", "Output format (text|json)")
+
+	rootCmd.AddCommand(createUpCmd())
+	rootCmd.AddCommand(createDownCmd())
+	rootCmd.AddCommand(createStatusCmd())
+	rootCmd.AddCommand(createBaselineCmd())
+
+	if err := rootCmd.Execute(); err != nil {
+		log.Fatal(err)
+	}
+}
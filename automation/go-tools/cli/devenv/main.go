@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Spec describes a local development environment
+type Spec struct {
+	Name    string      `yaml:"name"`
+	Kind    KindSpec    `yaml:"kind"`
+	Compose ComposeSpec `yaml:"compose"`
+	Seed    []SeedStep  `yaml:"seed"`
+}
+
+// KindSpec configures the local kind cluster and the manifests applied to it
+type KindSpec struct {
+	Cluster   string   `yaml:"cluster"`
+	Config    string   `yaml:"config"`
+	Manifests []string `yaml:"manifests"`
+}
+
+// ComposeSpec configures the Docker Compose services (databases, servers)
+type ComposeSpec struct {
+	File    string `yaml:"file"`
+	Project string `yaml:"project"`
+}
+
+// SeedStep is a command run after everything is up to load seed data
+type SeedStep struct {
+	Name    string            `yaml:"name"`
+	Command []string          `yaml:"command"`
+	Env     map[string]string `yaml:"env"`
+}
+
+var specFile string
+
+// loadSpec reads the environment spec
+func loadSpec() *Spec {
+	data, err := os.ReadFile(specFile)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", specFile, err)
+	}
+
+	var spec Spec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		log.Fatalf("Failed to parse %s: %v", specFile, err)
+	}
+	if spec.Kind.Cluster == "" {
+		spec.Kind.Cluster = spec.Name
+	}
+	if spec.Compose.Project == "" {
+		spec.Compose.Project = spec.Name
+	}
+	return &spec
+}
+
+// run executes a command with output attached to the terminal
+func run(env map[string]string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	return cmd.Run()
+}
+
+// kindClusterExists reports whether the named kind cluster is running
+func kindClusterExists(name string) (bool, error) {
+	out, err := exec.Command("kind", "get", "clusters").Output()
+	if err != nil {
+		return false, err
+	}
+	for _, cluster := range strings.Fields(string(out)) {
+		if cluster == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *Spec) composeArgs(args ...string) []string {
+	return append([]string{"compose", "-f", s.Compose.File, "-p", s.Compose.Project}, args...)
+}
+
+// Up creates the kind cluster, starts compose services, applies manifests and seeds data
+func (s *Spec) Up() error {
+	if s.Kind.Cluster != "" {
+		exists, err := kindClusterExists(s.Kind.Cluster)
+		if err != nil {
+			return fmt.Errorf("failed to query kind clusters: %w", err)
+		}
+		if !exists {
+			args := []string{"create", "cluster", "--name", s.Kind.Cluster}
+			if s.Kind.Config != "" {
+				args = append(args, "--config", s.Kind.Config)
+			}
+			if err := run(nil, "kind", args...); err != nil {
+				return fmt.Errorf("failed to create kind cluster: %w", err)
+			}
+		}
+
+		for _, manifest := range s.Kind.Manifests {
+			if err := run(nil, "kubectl", "--context", "kind-"+s.Kind.Cluster, "apply", "-f", manifest); err != nil {
+				return fmt.Errorf("failed to apply %s: %w", manifest, err)
+			}
+		}
+	}
+
+	if s.Compose.File != "" {
+		if err := run(nil, "docker", s.composeArgs("up", "-d", "--wait")...); err != nil {
+			return fmt.Errorf("failed to start compose services: %w", err)
+		}
+	}
+
+	for _, step := range s.Seed {
+		if len(step.Command) == 0 {
+			continue
+		}
+		log.Printf("Seeding: %s", step.Name)
+		if err := run(step.Env, step.Command[0], step.Command[1:]...); err != nil {
+			return fmt.Errorf("seed step %s failed: %w", step.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Status prints the state of the kind cluster and compose services
+func (s *Spec) Status() error {
+	if s.Kind.Cluster != "" {
+		exists, err := kindClusterExists(s.Kind.Cluster)
+		if err != nil {
+			return fmt.Errorf("failed to query kind clusters: %w", err)
+		}
+		state := "not running"
+		if exists {
+			state = "running (context kind-" + s.Kind.Cluster + ")"
+		}
+		fmt.Printf("kind cluster %s: %s\n\n", s.Kind.Cluster, state)
+	}
+
+	if s.Compose.File != "" {
+		return run(nil, "docker", s.composeArgs("ps")...)
+	}
+	return nil
+}
+
+// Down stops compose services and deletes the kind cluster
+func (s *Spec) Down(keepVolumes bool) error {
+	if s.Compose.File != "" {
+		args := s.composeArgs("down")
+		if !keepVolumes {
+			args = append(args, "--volumes")
+		}
+		if err := run(nil, "docker", args...); err != nil {
+			return fmt.Errorf("failed to stop compose services: %w", err)
+		}
+	}
+
+	if s.Kind.Cluster != "" {
+		if err := run(nil, "kind", "delete", "cluster", "--name", s.Kind.Cluster); err != nil {
+			return fmt.Errorf("failed to delete kind cluster: %w", err)
+		}
+	}
+	return nil
+}
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:   "devenv",
+		Short: "Local development environment orchestrator",
+		Long:  `Brings up a local development environment described by a spec file: a kind cluster with manifests applied, Docker Compose services, and seed data.`,
+	}
+	rootCmd.PersistentFlags().StringVarP(&specFile, "file", "f", "devenv.yaml", "Environment spec file")
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "up",
+		Short: "Create and seed the environment",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := loadSpec().Up(); err != nil {
+				log.Fatalf("Failed to bring environment up: %v", err)
+			}
+			fmt.Println("Environment is up")
+		},
+	})
+
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "status",
+		Short: "Show environment status",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := loadSpec().Status(); err != nil {
+				log.Fatalf("Failed to get status: %v", err)
+			}
+		},
+	})
+
+	var keepVolumes bool
+	downCmd := &cobra.Command{
+		Use:   "down",
+		Short: "Tear the environment down",
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := loadSpec().Down(keepVolumes); err != nil {
+				log.Fatalf("Failed to tear environment down: %v", err)
+			}
+		},
+	}
+	downCmd.Flags().BoolVar(&keepVolumes, "keep-volumes", false, "Keep compose volumes (database data)")
+	rootCmd.AddCommand(downCmd)
+
+	if err := rootCmd.Execute(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// accessReviewWorkers bounds concurrent access review requests
+const accessReviewWorkers = 10
+
+// defaultAccessVerbs and defaultAccessResources are the matrix axes used when
+// none are given. Resources use kubectl's resource.group[/subresource] form.
+var (
+	defaultAccessVerbs     = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+	defaultAccessResources = []string{
+		"pods", "pods/exec", "pods/log", "services", "configmaps", "secrets",
+		"persistentvolumeclaims", "deployments.apps", "statefulsets.apps",
+		"roles.rbac.authorization.k8s.io", "rolebindings.rbac.authorization.k8s.io",
+	}
+)
+
+// AccessSubject is who an access matrix is built for. An empty User means the
+// current credentials, checked with SelfSubjectAccessReview.
+type AccessSubject struct {
+	User   string   `json:"user,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+// AccessRow is the allowed verbs for one resource in one namespace
+type AccessRow struct {
+	Namespace string          `json:"namespace"`
+	Resource  string          `json:"resource"`
+	Allowed   map[string]bool `json:"allowed"`
+}
+
+// AccessMatrix is the verb by resource access of a subject per namespace
+type AccessMatrix struct {
+	Subject AccessSubject `json:"subject"`
+	Verbs   []string      `json:"verbs"`
+	Rows    []AccessRow   `json:"rows"`
+}
+
+// parseAccessSubject turns --as and --as-group into a subject. Service
+// accounts given as system:serviceaccount:<ns>:<name> get the groups the API
+// server would assign them.
+func parseAccessSubject(user string, groups []string) AccessSubject {
+	subject := AccessSubject{User: user, Groups: groups}
+	if parts := strings.Split(user, ":"); len(parts) == 4 && parts[0] == "system" && parts[1] == "serviceaccount" {
+		subject.Groups = dedupeStrings(append(subject.Groups,
+			"system:serviceaccounts", "system:serviceaccounts:"+parts[2], "system:authenticated"))
+	}
+	return subject
+}
+
+// parseAccessResource splits resource.group/subresource
+func parseAccessResource(resource string) (name, group, subresource string) {
+	name, subresource, _ = strings.Cut(resource, "/")
+	name, group, _ = strings.Cut(name, ".")
+	return name, group, subresource
+}
+
+// AccessMatrix asks the API server whether subject may perform each verb on
+// each resource in each namespace. Namespace "" checks access across all
+// namespaces.
+func (k *K8sToolkit) AccessMatrix(ctx context.Context, subject AccessSubject, namespaces, verbs, resources []string) (*AccessMatrix, error) {
+	matrix := &AccessMatrix{Subject: subject, Verbs: verbs}
+	for _, namespace := range namespaces {
+		for _, resource := range resources {
+			matrix.Rows = append(matrix.Rows, AccessRow{Namespace: namespace, Resource: resource, Allowed: make(map[string]bool, len(verbs))})
+		}
+	}
+
+	type review struct {
+		row  int
+		verb string
+	}
+	jobs := make(chan review)
+	var mu sync.Mutex
+	var firstErr error
+	var wg sync.WaitGroup
+
+	for w := 0; w < accessReviewWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				row := matrix.Rows[job.row]
+				allowed, err := k.accessAllowed(ctx, subject, row.Namespace, row.Resource, job.verb)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = fmt.Errorf("access review for %s %s failed: %w", job.verb, row.Resource, err)
+				}
+				row.Allowed[job.verb] = allowed
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for i := range matrix.Rows {
+		for _, verb := range verbs {
+			jobs <- review{row: i, verb: verb}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, classifyError(firstErr)
+	}
+	return matrix, nil
+}
+
+// accessAllowed runs a single SelfSubjectAccessReview or SubjectAccessReview
+func (k *K8sToolkit) accessAllowed(ctx context.Context, subject AccessSubject, namespace, resource, verb string) (bool, error) {
+	name, group, subresource := parseAccessResource(resource)
+	attributes := &authorizationv1.ResourceAttributes{
+		Namespace:   namespace,
+		Verb:        verb,
+		Group:       group,
+		Resource:    name,
+		Subresource: subresource,
+	}
+
+	if subject.User == "" && len(subject.Groups) == 0 {
+		review, err := k.clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attributes},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return false, err
+		}
+		return review.Status.Allowed, nil
+	}
+
+	review, err := k.clientset.AuthorizationV1().SubjectAccessReviews().Create(ctx, &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: attributes,
+			User:               subject.User,
+			Groups:             subject.Groups,
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, err
+	}
+	return review.Status.Allowed, nil
+}
+
+// PrintAccessMatrix prints the matrix as a table, CSV or JSON
+func (k *K8sToolkit) PrintAccessMatrix(matrix *AccessMatrix) {
+	if k.filtered(matrix) {
+		return
+	}
+	namespaceLabel := func(namespace string) string {
+		if namespace == "" {
+			return "*"
+		}
+		return namespace
+	}
+
+	switch k.output {
+	case "json":
+		printJSON(matrix)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write(append([]string{"namespace", "resource"}, matrix.Verbs...))
+		for _, row := range matrix.Rows {
+			record := []string{namespaceLabel(row.Namespace), row.Resource}
+			for _, verb := range matrix.Verbs {
+				record = append(record, fmt.Sprintf("%t", row.Allowed[verb]))
+			}
+			w.Write(record)
+		}
+		w.Flush()
+	default:
+		who := matrix.Subject.User
+		if who == "" {
+			who = "current user"
+		}
+		fmt.Printf("Access Matrix for %s\n", who)
+		if len(matrix.Subject.Groups) > 0 {
+			fmt.Printf("Groups: %s\n", strings.Join(matrix.Subject.Groups, ", "))
+		}
+		fmt.Println()
+
+		fmt.Printf("%-20s %-45s", "NAMESPACE", "RESOURCE")
+		for _, verb := range matrix.Verbs {
+			fmt.Printf(" %-7s", strings.ToUpper(verb))
+		}
+		fmt.Println()
+		for _, row := range matrix.Rows {
+			fmt.Printf("%-20s %-45s", namespaceLabel(row.Namespace), row.Resource)
+			for _, verb := range matrix.Verbs {
+				mark := "-"
+				if row.Allowed[verb] {
+					mark = "yes"
+				}
+				fmt.Printf(" %-7s", mark)
+			}
+			fmt.Println()
+		}
+	}
+}
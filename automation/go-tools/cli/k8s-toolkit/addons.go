@@ -0,0 +1,380 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// addonSnapshotVersion is the format version of snapshot files
+const addonSnapshotVersion = 1
+
+// cniConfigMaps are the kube-system ConfigMaps holding the configuration of
+// common CNI plugins
+var cniConfigMaps = []string{"calico-config", "canal-config", "cilium-config", "kube-flannel-cfg", "weave-net", "antrea-config"}
+
+// AddonSnapshot is a versioned capture of cluster add-on configuration
+type AddonSnapshot struct {
+	Version       int           `yaml:"version" json:"version"`
+	TakenAt       time.Time     `yaml:"taken_at" json:"taken_at"`
+	ServerVersion string        `yaml:"server_version" json:"server_version"`
+	Objects       []AddonObject `yaml:"objects" json:"objects"`
+}
+
+// AddonObject is the configuration of one add-on object, keyed by file or field
+type AddonObject struct {
+	Addon     string            `yaml:"addon" json:"addon"`
+	Kind      string            `yaml:"kind" json:"kind"`
+	Namespace string            `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Name      string            `yaml:"name" json:"name"`
+	Data      map[string]string `yaml:"data" json:"data"`
+}
+
+func (o AddonObject) ref() string {
+	return objectRef{Kind: o.Kind, Namespace: o.Namespace, Name: o.Name}.String()
+}
+
+// SnapshotAddons captures the CoreDNS Corefile, kube-proxy and CNI
+// configuration, ingress controller configuration and IngressClasses, and
+// admission webhook configurations. CA bundles are left out because they
+// rotate without any configuration change.
+func (k *K8sToolkit) SnapshotAddons(ctx context.Context) (*AddonSnapshot, error) {
+	snapshot := &AddonSnapshot{Version: addonSnapshotVersion, TakenAt: time.Now().UTC()}
+	if version, err := k.serverVersion(ctx); err == nil {
+		snapshot.ServerVersion = version.GitVersion
+	}
+
+	addConfigMap := func(addon, namespace, name string) error {
+		cm, err := k.clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get configmap %s/%s: %w", namespace, name, err)
+		}
+		snapshot.Objects = append(snapshot.Objects, AddonObject{Addon: addon, Kind: "ConfigMap", Namespace: namespace, Name: name, Data: cm.Data})
+		return nil
+	}
+
+	if err := addConfigMap("coredns", "kube-system", "coredns"); err != nil {
+		return nil, err
+	}
+	if err := addConfigMap("kube-proxy", "kube-system", "kube-proxy"); err != nil {
+		return nil, err
+	}
+	for _, name := range cniConfigMaps {
+		if err := addConfigMap("cni", "kube-system", name); err != nil {
+			return nil, err
+		}
+	}
+
+	ingressConfigMaps, err := k.clientset.CoreV1().ConfigMaps("").List(ctx, metav1.ListOptions{LabelSelector: "app.kubernetes.io/name=ingress-nginx"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingress controller configmaps: %w", err)
+	}
+	for _, cm := range ingressConfigMaps.Items {
+		snapshot.Objects = append(snapshot.Objects, AddonObject{Addon: "ingress", Kind: "ConfigMap", Namespace: cm.Namespace, Name: cm.Name, Data: cm.Data})
+	}
+
+	classes, err := k.clientset.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingress classes: %w", err)
+	}
+	for _, class := range classes.Items {
+		data, err := addonYAML(class.Spec)
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Objects = append(snapshot.Objects, AddonObject{Addon: "ingress", Kind: "IngressClass", Name: class.Name, Data: map[string]string{"spec": data}})
+	}
+
+	validating, err := k.clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list validating webhook configurations: %w", err)
+	}
+	for _, config := range validating.Items {
+		data := make(map[string]string)
+		for _, webhook := range config.Webhooks {
+			webhook.ClientConfig.CABundle = nil
+			if data[webhook.Name], err = addonYAML(webhook); err != nil {
+				return nil, err
+			}
+		}
+		snapshot.Objects = append(snapshot.Objects, AddonObject{Addon: "admission", Kind: "ValidatingWebhookConfiguration", Name: config.Name, Data: data})
+	}
+
+	mutating, err := k.clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mutating webhook configurations: %w", err)
+	}
+	for _, config := range mutating.Items {
+		data := make(map[string]string)
+		for _, webhook := range config.Webhooks {
+			webhook.ClientConfig.CABundle = nil
+			if data[webhook.Name], err = addonYAML(webhook); err != nil {
+				return nil, err
+			}
+		}
+		snapshot.Objects = append(snapshot.Objects, AddonObject{Addon: "admission", Kind: "MutatingWebhookConfiguration", Name: config.Name, Data: data})
+	}
+
+	sort.Slice(snapshot.Objects, func(i, j int) bool {
+		if snapshot.Objects[i].Addon != snapshot.Objects[j].Addon {
+			return snapshot.Objects[i].Addon < snapshot.Objects[j].Addon
+		}
+		return snapshot.Objects[i].ref() < snapshot.Objects[j].ref()
+	})
+	return snapshot, nil
+}
+
+// addonYAML renders an API struct as YAML through its JSON field names
+func addonYAML(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return "", err
+	}
+	out, err := yaml.Marshal(generic)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// WriteAddonSnapshot writes the snapshot into dir, named by the time it was taken
+func WriteAddonSnapshot(snapshot *AddonSnapshot, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	data, err := yaml.Marshal(snapshot)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	path := filepath.Join(dir, "addons-"+snapshot.TakenAt.Format("20060102-150405")+".yaml")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// loadAddonSnapshot reads a snapshot file
+func loadAddonSnapshot(path string) (*AddonSnapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var snapshot AddonSnapshot
+	if err := yaml.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if snapshot.Version != addonSnapshotVersion {
+		return nil, fmt.Errorf("%s has snapshot version %d, expected %d", path, snapshot.Version, addonSnapshotVersion)
+	}
+	return &snapshot, nil
+}
+
+// AddonChange is one difference between two snapshots
+type AddonChange struct {
+	Addon  string   `json:"addon"`
+	Object string   `json:"object"`
+	Key    string   `json:"key,omitempty"`
+	Change string   `json:"change"`
+	Lines  []string `json:"lines,omitempty"`
+}
+
+// DiffAddonSnapshots lists objects and keys added, removed or changed
+// between two snapshots, with the changed lines of each modified key
+func DiffAddonSnapshots(before, after *AddonSnapshot) []AddonChange {
+	index := func(s *AddonSnapshot) map[string]AddonObject {
+		objects := make(map[string]AddonObject)
+		for _, o := range s.Objects {
+			objects[o.ref()] = o
+		}
+		return objects
+	}
+	old, current := index(before), index(after)
+
+	var changes []AddonChange
+	for ref, o := range old {
+		if _, ok := current[ref]; !ok {
+			changes = append(changes, AddonChange{Addon: o.Addon, Object: ref, Change: "removed"})
+		}
+	}
+	for ref, o := range current {
+		prev, ok := old[ref]
+		if !ok {
+			changes = append(changes, AddonChange{Addon: o.Addon, Object: ref, Change: "added"})
+			continue
+		}
+		for key, value := range o.Data {
+			prevValue, ok := prev.Data[key]
+			switch {
+			case !ok:
+				changes = append(changes, AddonChange{Addon: o.Addon, Object: ref, Key: key, Change: "added", Lines: diffLines("", value)})
+			case prevValue != value:
+				changes = append(changes, AddonChange{Addon: o.Addon, Object: ref, Key: key, Change: "changed", Lines: diffLines(prevValue, value)})
+			}
+		}
+		for key, value := range prev.Data {
+			if _, ok := o.Data[key]; !ok {
+				changes = append(changes, AddonChange{Addon: o.Addon, Object: ref, Key: key, Change: "removed", Lines: diffLines(value, "")})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Addon != changes[j].Addon {
+			return changes[i].Addon < changes[j].Addon
+		}
+		if changes[i].Object != changes[j].Object {
+			return changes[i].Object < changes[j].Object
+		}
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
+
+// diffLines returns the removed ("- ") and added ("+ ") lines between two
+// texts, in order, using a longest common subsequence
+func diffLines(a, b string) []string {
+	split := func(s string) []string {
+		if s == "" {
+			return nil
+		}
+		return strings.Split(strings.TrimRight(s, "\n"), "\n")
+	}
+	x, y := split(a), split(b)
+
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			i++
+			j++
+		case j < len(y) && (i == len(x) || lcs[i][j+1] >= lcs[i+1][j]):
+			lines = append(lines, "+ "+y[j])
+			j++
+		default:
+			lines = append(lines, "- "+x[i])
+			i++
+		}
+	}
+	return lines
+}
+
+// PrintAddonChanges prints the differences between two snapshots
+func PrintAddonChanges(before, after *AddonSnapshot, changes []AddonChange) {
+	if viper.GetString("output") == "json" {
+		data, _ := json.MarshalIndent(changes, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("Add-on changes %s (%s) -> %s (%s)\n",
+		before.TakenAt.Format(time.RFC3339), before.ServerVersion, after.TakenAt.Format(time.RFC3339), after.ServerVersion)
+	fmt.Println("=====================================")
+	if len(changes) == 0 {
+		fmt.Println("No changes")
+		return
+	}
+	for _, c := range changes {
+		target := c.Object
+		if c.Key != "" {
+			target += " [" + c.Key + "]"
+		}
+		fmt.Printf("%-8s %-10s %s\n", c.Change, c.Addon, target)
+		for _, line := range c.Lines {
+			fmt.Printf("    %s\n", line)
+		}
+	}
+}
+
+// createAddonsCmd creates the addons command group
+func createAddonsCmd() *cobra.Command {
+	addonsCmd := &cobra.Command{
+		Use:   "addons",
+		Short: "Back up and compare cluster add-on configuration",
+	}
+
+	var dir string
+
+	snapshotCmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Capture add-on configuration into a versioned snapshot file",
+		Long: `Captures the CoreDNS Corefile, kube-proxy and CNI configuration, ingress-nginx
+configuration and IngressClasses, and admission webhook configurations into a
+timestamped YAML file. Take one before and after an upgrade and compare them
+with "addons diff".`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			snapshot, err := toolkit.SnapshotAddons(context.Background())
+			if err != nil {
+				logger.Fatalf("Failed to snapshot add-ons: %v", err)
+			}
+
+			path, err := WriteAddonSnapshot(snapshot, dir)
+			if err != nil {
+				logger.Fatalf("Failed to write snapshot: %v", err)
+			}
+			fmt.Printf("Snapshot of %d add-on objects written to %s\n", len(snapshot.Objects), path)
+		},
+	}
+	snapshotCmd.Flags().StringVar(&dir, "dir", "addon-snapshots", "Directory to write the snapshot into")
+
+	diffCmd := &cobra.Command{
+		Use:   "diff <before> <after>",
+		Short: "Compare two add-on snapshots",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			before, err := loadAddonSnapshot(args[0])
+			if err != nil {
+				logger.Fatalf("Failed to load snapshot: %v", err)
+			}
+			after, err := loadAddonSnapshot(args[1])
+			if err != nil {
+				logger.Fatalf("Failed to load snapshot: %v", err)
+			}
+
+			PrintAddonChanges(before, after, DiffAddonSnapshots(before, after))
+		},
+	}
+
+	addonsCmd.AddCommand(snapshotCmd)
+	addonsCmd.AddCommand(diffCmd)
+	return addonsCmd
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxAPIRuns bounds the on-demand check runs served at the same time
+const maxAPIRuns = 4
+
+// apiServer lets other systems, such as a deploy gate, run checks on demand
+// instead of waiting for the next scheduled run. Results are returned
+// synchronously and are not written to ClusterHealthCheck status.
+type apiServer struct {
+	toolkit *K8sToolkit
+	token   string
+	timeout time.Duration
+	slots   chan struct{}
+}
+
+// apiToken returns the bearer token from operator.api_token, which may be a
+// secret reference, or K8S_TOOLKIT_API_TOKEN
+func apiToken(ctx context.Context) (string, error) {
+	token, err := secretSetting(ctx, "operator.api_token")
+	if err != nil || token != "" {
+		return token, err
+	}
+	return os.Getenv("K8S_TOOLKIT_API_TOKEN"), nil
+}
+
+// newAPIServer creates the on-demand check API; it refuses to start without a token
+func (k *K8sToolkit) newAPIServer(ctx context.Context, timeout time.Duration) (*apiServer, error) {
+	token, err := apiToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if token == "" {
+		return nil, fmt.Errorf("the API needs a token: set operator.api_token or K8S_TOOLKIT_API_TOKEN")
+	}
+	return &apiServer{toolkit: k, token: token, timeout: timeout, slots: make(chan struct{}, maxAPIRuns)}, nil
+}
+
+// handler routes POST /run-check/{name}, POST /run-all and POST /remediate[/{rule}]
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/run-check/", s.authorized(s.runCheck))
+	mux.HandleFunc("/run-all", s.authorized(s.runAll))
+	mux.HandleFunc("/remediate", s.authorized(s.remediate))
+	mux.HandleFunc("/remediate/", s.authorized(s.remediate))
+	return mux
+}
+
+// authorized rejects requests that are not POSTs with the bearer token, and
+// requests beyond maxAPIRuns concurrent runs
+func (s *apiServer) authorized(next func(http.ResponseWriter, *http.Request, *K8sToolkit)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeAPIError(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeAPIError(w, http.StatusUnauthorized, "invalid or missing bearer token")
+			return
+		}
+
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		default:
+			writeAPIError(w, http.StatusTooManyRequests, "too many check runs in progress")
+			return
+		}
+
+		// ?namespace= scopes namespaced checks like spec.namespace does
+		scoped := *s.toolkit
+		scoped.namespace = r.URL.Query().Get("namespace")
+		configMu.RLock()
+		defer configMu.RUnlock()
+		next(w, r, &scoped)
+	}
+}
+
+// requestContext applies ?timeout=, capped at the server timeout
+func (s *apiServer) requestContext(r *http.Request) (context.Context, context.CancelFunc, error) {
+	timeout := s.timeout
+	if value := r.URL.Query().Get("timeout"); value != "" {
+		requested, err := time.ParseDuration(value)
+		if err != nil || requested <= 0 {
+			return nil, nil, fmt.Errorf("invalid timeout %q", value)
+		}
+		if requested < timeout {
+			timeout = requested
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	return ctx, cancel, nil
+}
+
+// runCheck runs one check by name and returns its HealthCheckResult
+func (s *apiServer) runCheck(w http.ResponseWriter, r *http.Request, k *K8sToolkit) {
+	name := strings.TrimPrefix(r.URL.Path, "/run-check/")
+	var check HealthChecker
+	for _, candidate := range k.healthChecks() {
+		if candidate.Name() == name {
+			check = candidate
+			break
+		}
+	}
+	if check == nil {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("unknown check %q", name))
+		return
+	}
+
+	ctx, cancel, err := s.requestContext(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	k.checkLog(name).Infof("Running check on request from %s", r.RemoteAddr)
+	writeAPIResponse(w, k.runCheck(ctx, check))
+}
+
+// runAll runs every enabled check and returns the ClusterHealth
+func (s *apiServer) runAll(w http.ResponseWriter, r *http.Request, k *K8sToolkit) {
+	ctx, cancel, err := s.requestContext(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	k.log().Infof("Running all checks on request from %s", r.RemoteAddr)
+	health, err := k.RunHealthCheck(ctx)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIResponse(w, health)
+}
+
+func writeAPIResponse(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warnf("failed to write API response: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// replicatedWorkload is a Deployment or StatefulSet considered by CheckAvailability
+type replicatedWorkload struct {
+	key      workloadKey
+	replicas int32
+	labels   labels.Set
+}
+
+// CheckAvailability reports replicated workloads without a PodDisruptionBudget,
+// PDBs that allow no disruptions and so block node drains, single-replica
+// workloads, and workloads whose replicas all run on one node
+func (k *K8sToolkit) CheckAvailability(ctx context.Context) ([]Finding, error) {
+	var workloads []replicatedWorkload
+
+	deployments, err := k.clientset.AppsV1().Deployments(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, d := range inScopeItems(k, deployments.Items) {
+		replicas := int32(1)
+		if d.Spec.Replicas != nil {
+			replicas = *d.Spec.Replicas
+		}
+		workloads = append(workloads, replicatedWorkload{
+			key:      workloadKey{namespace: d.Namespace, kind: "Deployment", name: d.Name},
+			replicas: replicas,
+			labels:   d.Spec.Template.Labels,
+		})
+	}
+
+	statefulSets, err := k.clientset.AppsV1().StatefulSets(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for _, s := range inScopeItems(k, statefulSets.Items) {
+		replicas := int32(1)
+		if s.Spec.Replicas != nil {
+			replicas = *s.Spec.Replicas
+		}
+		workloads = append(workloads, replicatedWorkload{
+			key:      workloadKey{namespace: s.Namespace, kind: "StatefulSet", name: s.Name},
+			replicas: replicas,
+			labels:   s.Spec.Template.Labels,
+		})
+	}
+
+	pdbs, err := k.clientset.PolicyV1().PodDisruptionBudgets(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod disruption budgets: %w", err)
+	}
+
+	// Nodes running the pods of each workload
+	rsOwners, err := k.replicaSetOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[workloadKey]map[string]bool)
+	err = k.eachPod(ctx, k.namespace, metav1.ListOptions{FieldSelector: "status.phase=Running"}, func(pod *corev1.Pod) error {
+		key := podWorkload(pod, rsOwners)
+		if nodes[key] == nil {
+			nodes[key] = make(map[string]bool)
+		}
+		nodes[key][pod.Spec.NodeName] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var findings []Finding
+	add := func(rule, severity, namespace, resource, message, remediation string) {
+		findings = append(findings, Finding{
+			Source:      "availability",
+			RuleID:      "availability/" + rule,
+			Severity:    severity,
+			Namespace:   namespace,
+			Resource:    resource,
+			Message:     message,
+			Remediation: remediation,
+		})
+	}
+
+	for _, pdb := range pdbs.Items {
+		if pdb.Status.ExpectedPods > 0 && pdb.Status.DisruptionsAllowed == 0 {
+			add("pdb-blocks-drain", "High", pdb.Namespace, "pdb/"+pdb.Name,
+				fmt.Sprintf("allows 0 disruptions (%d of %d pods healthy, %s)", pdb.Status.CurrentHealthy, pdb.Status.ExpectedPods, describePDB(&pdb)),
+				"lower minAvailable or raise maxUnavailable so at least one pod can be evicted, or add replicas")
+		}
+	}
+
+	for _, w := range workloads {
+		if w.replicas == 0 {
+			continue
+		}
+		resource := w.key.String()
+
+		if w.replicas == 1 {
+			add("single-replica", "Medium", w.key.namespace, resource,
+				"runs a single replica and goes down on every node drain",
+				"run at least 2 replicas with a PodDisruptionBudget")
+			continue
+		}
+
+		if !pdbCovers(pdbs.Items, w.key.namespace, w.labels) {
+			add("no-pdb", "Medium", w.key.namespace, resource,
+				fmt.Sprintf("has %d replicas but no PodDisruptionBudget; a drain may evict all of them at once", w.replicas),
+				"add a PodDisruptionBudget with maxUnavailable: 1")
+		}
+
+		if running := nodes[w.key]; len(running) == 1 {
+			var node string
+			for name := range running {
+				node = name
+			}
+			add("single-node", "Medium", w.key.namespace, resource,
+				fmt.Sprintf("all %d replicas run on node %s", w.replicas, node),
+				"add a topologySpreadConstraint or pod anti-affinity on kubernetes.io/hostname")
+		}
+	}
+
+	sortFindings(findings)
+	return findings, nil
+}
+
+// pdbCovers reports whether any PDB in the namespace selects pods with these labels
+func pdbCovers(pdbs []policyv1.PodDisruptionBudget, namespace string, podLabels labels.Set) bool {
+	for i := range pdbs {
+		if pdbs[i].Namespace != namespace || pdbs[i].Spec.Selector == nil {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(pdbs[i].Spec.Selector)
+		if err != nil || selector.Empty() {
+			continue
+		}
+		if selector.Matches(podLabels) {
+			return true
+		}
+	}
+	return false
+}
+
+// describePDB formats the budget of a PDB
+func describePDB(pdb *policyv1.PodDisruptionBudget) string {
+	var parts []string
+	if pdb.Spec.MinAvailable != nil {
+		parts = append(parts, "minAvailable "+pdb.Spec.MinAvailable.String())
+	}
+	if pdb.Spec.MaxUnavailable != nil {
+		parts = append(parts, "maxUnavailable "+pdb.Spec.MaxUnavailable.String())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
+
+// createAvailabilityCmd creates the availability command
+func createAvailabilityCmd() *cobra.Command {
+	var failOn string
+
+	availabilityCmd := &cobra.Command{
+		Use:   "availability",
+		Short: "Find disruption risks that block or break node drains",
+		Long: `Finds Deployments and StatefulSets with more than one replica but no PodDisruptionBudget,
+PDBs that allow zero disruptions and would block drains, single-replica workloads, and
+workloads whose replicas all run on the same node. Run it before cluster upgrades.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			findings, err := toolkit.CheckAvailability(context.Background())
+			if err != nil {
+				logger.Fatalf("Failed to check availability: %v", err)
+			}
+
+			toolkit.PrintFindings("Availability Risk Report", findings)
+			exitOnFindings(findings, failOn)
+		},
+	}
+
+	availabilityCmd.Flags().StringVar(&failOn, "fail-on", "none", "Exit non-zero on findings at or above this severity (Low|Medium|High|none)")
+
+	return withSchema(availabilityCmd, "findings")
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/spf13/viper"
+)
+
+// awsErrorCode returns the AWS error code of err, or "" for other errors
+func awsErrorCode(err error) string {
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code()
+	}
+	return ""
+}
+
+// awsSession creates a session from the standard AWS environment and shared
+// config, as the governance command does
+func awsSession() (*session.Session, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+	return sess, nil
+}
+
+// AuditIAM reports a root account without MFA or with access keys, console
+// users without MFA, active access keys older than
+// compliance.iam.max_key_age and users with AdministratorAccess attached
+// directly
+func AuditIAM(ctx context.Context) ([]Finding, error) {
+	sess, err := awsSession()
+	if err != nil {
+		return nil, err
+	}
+	client := iam.New(sess)
+
+	var findings []Finding
+	add := func(rule, severity, resource, message, remediation string) {
+		findings = append(findings, Finding{
+			Source:      "security-iam",
+			RuleID:      "iam/" + rule,
+			Severity:    severity,
+			Resource:    resource,
+			Message:     message,
+			Remediation: remediation,
+		})
+	}
+
+	summary, err := client.GetAccountSummaryWithContext(ctx, &iam.GetAccountSummaryInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get IAM account summary: %w", err)
+	}
+	if aws.Int64Value(summary.SummaryMap[iam.SummaryKeyTypeAccountMfaenabled]) == 0 {
+		add("root-mfa", "Critical", "account/root", "root user has no MFA device",
+			"enable a hardware or virtual MFA device for the root user")
+	}
+	if aws.Int64Value(summary.SummaryMap[iam.SummaryKeyTypeAccountAccessKeysPresent]) > 0 {
+		add("root-access-keys", "Critical", "account/root", "root user has access keys",
+			"delete the root access keys and use IAM roles instead")
+	}
+
+	var users []*iam.User
+	err = client.ListUsersPagesWithContext(ctx, &iam.ListUsersInput{}, func(page *iam.ListUsersOutput, _ bool) bool {
+		users = append(users, page.Users...)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list IAM users: %w", err)
+	}
+
+	maxKeyAge := viper.GetDuration("compliance.iam.max_key_age")
+	for _, user := range users {
+		name := aws.StringValue(user.UserName)
+		resource := "iam-user/" + name
+
+		keys, err := client.ListAccessKeysWithContext(ctx, &iam.ListAccessKeysInput{UserName: user.UserName})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list access keys of %s: %w", name, err)
+		}
+		for _, key := range keys.AccessKeyMetadata {
+			if aws.StringValue(key.Status) != iam.StatusTypeActive {
+				continue
+			}
+			if age := time.Since(aws.TimeValue(key.CreateDate)); age > maxKeyAge {
+				add("stale-access-key", "High", resource,
+					fmt.Sprintf("active access key %s is %d days old", aws.StringValue(key.AccessKeyId), int(age.Hours()/24)),
+					"rotate the key, or replace it with a role the workload assumes")
+			}
+		}
+
+		_, err = client.GetLoginProfileWithContext(ctx, &iam.GetLoginProfileInput{UserName: user.UserName})
+		switch {
+		case awsErrorCode(err) == iam.ErrCodeNoSuchEntityException:
+		case err != nil:
+			return nil, fmt.Errorf("failed to get login profile of %s: %w", name, err)
+		default:
+			devices, err := client.ListMFADevicesWithContext(ctx, &iam.ListMFADevicesInput{UserName: user.UserName})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list MFA devices of %s: %w", name, err)
+			}
+			if len(devices.MFADevices) == 0 {
+				add("console-without-mfa", "High", resource, "can sign in to the console without MFA",
+					"require an MFA device for console users")
+			}
+		}
+
+		policies, err := client.ListAttachedUserPoliciesWithContext(ctx, &iam.ListAttachedUserPoliciesInput{UserName: user.UserName})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list policies of %s: %w", name, err)
+		}
+		for _, policy := range policies.AttachedPolicies {
+			if aws.StringValue(policy.PolicyName) == "AdministratorAccess" {
+				add("admin-user", "Medium", resource, "has AdministratorAccess attached directly",
+					"grant admin access through a group or an assumed role")
+			}
+		}
+	}
+
+	sortFindings(findings)
+	return findings, nil
+}
+
+// AuditS3 reports buckets that are public, lack a public access block, have
+// no default encryption or do not keep object versions. Each bucket is
+// queried in its own region.
+func AuditS3(ctx context.Context) ([]Finding, error) {
+	sess, err := awsSession()
+	if err != nil {
+		return nil, err
+	}
+	client := s3.New(sess)
+
+	var findings []Finding
+	add := func(rule, severity, bucket, message, remediation string) {
+		findings = append(findings, Finding{
+			Source:      "security-s3",
+			RuleID:      "s3/" + rule,
+			Severity:    severity,
+			Resource:    "bucket/" + bucket,
+			Message:     message,
+			Remediation: remediation,
+		})
+	}
+
+	buckets, err := client.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list S3 buckets: %w", err)
+	}
+
+	regional := make(map[string]*s3.S3)
+	for _, bucket := range buckets.Buckets {
+		name := aws.StringValue(bucket.Name)
+
+		location, err := client.GetBucketLocationWithContext(ctx, &s3.GetBucketLocationInput{Bucket: bucket.Name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get location of bucket %s: %w", name, err)
+		}
+		region := s3.NormalizeBucketLocation(aws.StringValue(location.LocationConstraint))
+		if regional[region] == nil {
+			regional[region] = s3.New(sess, aws.NewConfig().WithRegion(region))
+		}
+		bucketClient := regional[region]
+
+		block, err := bucketClient.GetPublicAccessBlockWithContext(ctx, &s3.GetPublicAccessBlockInput{Bucket: bucket.Name})
+		switch {
+		case awsErrorCode(err) == "NoSuchPublicAccessBlockConfiguration":
+			add("public-access-block", "High", name, "has no public access block",
+				"enable all four public access block settings on the bucket")
+		case err != nil:
+			return nil, fmt.Errorf("failed to get public access block of bucket %s: %w", name, err)
+		default:
+			config := block.PublicAccessBlockConfiguration
+			if !aws.BoolValue(config.BlockPublicAcls) || !aws.BoolValue(config.IgnorePublicAcls) ||
+				!aws.BoolValue(config.BlockPublicPolicy) || !aws.BoolValue(config.RestrictPublicBuckets) {
+				add("public-access-block", "High", name, "does not enable every public access block setting",
+					"enable all four public access block settings on the bucket")
+			}
+		}
+
+		status, err := bucketClient.GetBucketPolicyStatusWithContext(ctx, &s3.GetBucketPolicyStatusInput{Bucket: bucket.Name})
+		switch {
+		case awsErrorCode(err) == "NoSuchBucketPolicy":
+		case err != nil:
+			return nil, fmt.Errorf("failed to get policy status of bucket %s: %w", name, err)
+		case aws.BoolValue(status.PolicyStatus.IsPublic):
+			add("public-bucket", "Critical", name, "bucket policy makes the bucket public",
+				"remove the public statements from the bucket policy")
+		}
+
+		_, err = bucketClient.GetBucketEncryptionWithContext(ctx, &s3.GetBucketEncryptionInput{Bucket: bucket.Name})
+		switch {
+		case awsErrorCode(err) == "ServerSideEncryptionConfigurationNotFoundError":
+			add("unencrypted", "Medium", name, "has no default encryption",
+				"configure SSE-S3 or SSE-KMS default encryption")
+		case err != nil:
+			return nil, fmt.Errorf("failed to get encryption of bucket %s: %w", name, err)
+		}
+
+		versioning, err := bucketClient.GetBucketVersioningWithContext(ctx, &s3.GetBucketVersioningInput{Bucket: bucket.Name})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get versioning of bucket %s: %w", name, err)
+		}
+		if aws.StringValue(versioning.Status) != s3.BucketVersioningStatusEnabled {
+			add("versioning-disabled", "Low", name, "does not keep object versions",
+				"enable versioning so deleted or overwritten objects can be recovered")
+		}
+	}
+
+	sortFindings(findings)
+	return findings, nil
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// benchSizes are the synthetic cluster sizes in pods
+var benchSizes = []int{100, 1000, 10000}
+
+// syntheticCluster generates a cluster of the given pod count with nodes,
+// Deployments, ReplicaSets and PVs in realistic proportions and a small share
+// of broken objects so the failure paths are exercised too
+func syntheticCluster(podCount int) []runtime.Object {
+	var objects []runtime.Object
+	isController := true
+
+	nodeCount := podCount/30 + 1
+	for i := 0; i < nodeCount; i++ {
+		objects = append(objects, &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("node-%d", i)},
+			Status: corev1.NodeStatus{
+				Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+				NodeInfo:   corev1.NodeSystemInfo{KubeletVersion: "v1.27.4"},
+			},
+		})
+	}
+
+	for i := 0; i < podCount/10+1; i++ {
+		phase := corev1.VolumeBound
+		if i%100 == 99 {
+			phase = corev1.VolumeFailed
+		}
+		objects = append(objects, &corev1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pv-%d", i)},
+			Status:     corev1.PersistentVolumeStatus{Phase: phase},
+		})
+	}
+
+	for i := 0; i < podCount; i++ {
+		namespace := fmt.Sprintf("team-%d", i/50)
+		if i%100 == 0 {
+			namespace = "kube-system"
+		}
+		app := fmt.Sprintf("app-%d", i/10)
+		rsName := app + "-7d9f8c"
+
+		if i%10 == 0 || namespace == "kube-system" {
+			objects = append(objects, &appsv1.ReplicaSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      rsName,
+					Namespace: namespace,
+					OwnerReferences: []metav1.OwnerReference{{
+						APIVersion: "apps/v1", Kind: "Deployment", Name: app,
+						UID: types.UID(namespace + "-" + app), Controller: &isController,
+					}},
+				},
+			})
+		}
+
+		status := corev1.ContainerStatus{Name: "app", Ready: true, State: corev1.ContainerState{Running: &corev1.ContainerStateRunning{}}}
+		if i%100 == 50 {
+			status = corev1.ContainerStatus{Name: "app", RestartCount: 12, State: corev1.ContainerState{
+				Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+			}}
+		}
+
+		objects = append(objects, &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("%s-%d", rsName, i),
+				Namespace: namespace,
+				Labels:    map[string]string{"app": app},
+				OwnerReferences: []metav1.OwnerReference{{
+					APIVersion: "apps/v1", Kind: "ReplicaSet", Name: rsName,
+					UID: types.UID(namespace + "-" + rsName), Controller: &isController,
+				}},
+			},
+			Spec: corev1.PodSpec{
+				NodeName: fmt.Sprintf("node-%d", i%nodeCount),
+				Containers: []corev1.Container{{
+					Name:  "app",
+					Image: fmt.Sprintf("registry.example.com/%s:1.%d", app, i%5),
+				}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, ContainerStatuses: []corev1.ContainerStatus{status}},
+		})
+	}
+
+	return objects
+}
+
+// withStdout runs fn with stdout sent to /dev/null so rendering can be measured
+func withStdout(fn func()) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		fn()
+		return
+	}
+	defer devNull.Close()
+
+	stdout := os.Stdout
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+	fn()
+}
+
+// benchToolkits caches a toolkit per size, since populating the fake
+// clientset with 10000 pods takes longer than the benchmarks themselves
+var benchToolkits = make(map[int]*K8sToolkit)
+
+func benchToolkit(size int) *K8sToolkit {
+	if toolkit, ok := benchToolkits[size]; ok {
+		return toolkit
+	}
+	toolkit := &K8sToolkit{clientset: fake.NewSimpleClientset(syntheticCluster(size)...)}
+	benchToolkits[size] = toolkit
+	return toolkit
+}
+
+// benchEachSize runs fn as a sub-benchmark per cluster size, named after the
+// size so benchstat compares like with like
+func benchEachSize(b *testing.B, fn func(b *testing.B, toolkit *K8sToolkit)) {
+	for _, size := range benchSizes {
+		toolkit := benchToolkit(size)
+		b.Run(fmt.Sprintf("pods=%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			fn(b, toolkit)
+		})
+	}
+}
+
+func BenchmarkHealth(b *testing.B) {
+	ctx := context.Background()
+	benchEachSize(b, func(b *testing.B, toolkit *K8sToolkit) {
+		for i := 0; i < b.N; i++ {
+			toolkit.RunHealthCheck(ctx)
+		}
+	})
+}
+
+func BenchmarkSecurityPods(b *testing.B) {
+	ctx := context.Background()
+	benchEachSize(b, func(b *testing.B, toolkit *K8sToolkit) {
+		for i := 0; i < b.N; i++ {
+			toolkit.AuditPodSecurity(ctx, "restricted")
+		}
+	})
+}
+
+// benchRender measures printing a health report in the given output format
+func benchRender(b *testing.B, output string) {
+	ctx := context.Background()
+	benchEachSize(b, func(b *testing.B, toolkit *K8sToolkit) {
+		health, err := toolkit.RunHealthCheck(ctx)
+		if err != nil {
+			b.Fatalf("health check failed on synthetic cluster: %v", err)
+		}
+		toolkit.output = output
+		b.ResetTimer()
+		withStdout(func() {
+			for i := 0; i < b.N; i++ {
+				toolkit.PrintHealthCheck(health)
+			}
+		})
+	})
+}
+
+func BenchmarkRenderText(b *testing.B) { benchRender(b, "text") }
+
+func BenchmarkRenderJSON(b *testing.B) { benchRender(b, "json") }
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+// Upstream API call latency SLOs: single object calls within 1s, lists
+// within 5s in a namespace and 30s across the cluster, all at p99
+const (
+	apiSLOGet           = time.Second
+	apiSLONamespaceList = 5 * time.Second
+	apiSLOClusterList   = 30 * time.Second
+)
+
+// APIBenchOptions configures BenchAPI
+type APIBenchOptions struct {
+	Resources   []string
+	Iterations  int
+	Concurrency int
+	PageSize    int64
+	Watch       bool
+}
+
+// APIBenchOp is the client-side latency of one verb on one resource
+type APIBenchOp struct {
+	Resource  string  `json:"resource"`
+	Verb      string  `json:"verb"`
+	Samples   int     `json:"samples"`
+	Errors    int     `json:"errors"`
+	Objects   int     `json:"objects,omitempty"`
+	P50       float64 `json:"p50_ms"`
+	P90       float64 `json:"p90_ms"`
+	P99       float64 `json:"p99_ms"`
+	Max       float64 `json:"max_ms"`
+	SLO       float64 `json:"slo_ms"`
+	WithinSLO bool    `json:"within_slo"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// ServerLatency is the server-side latency distribution of a request class
+// during the benchmark, estimated from histogram buckets like
+// histogram_quantile does
+type ServerLatency struct {
+	Source   string  `json:"source"`
+	Verb     string  `json:"verb"`
+	Resource string  `json:"resource"`
+	Requests uint64  `json:"requests"`
+	P50      float64 `json:"p50_ms"`
+	P99      float64 `json:"p99_ms"`
+}
+
+// APIBenchReport is the result of bench api
+type APIBenchReport struct {
+	Context     string          `json:"context"`
+	Iterations  int             `json:"iterations"`
+	Concurrency int             `json:"concurrency"`
+	Ops         []APIBenchOp    `json:"ops"`
+	Server      []ServerLatency `json:"server,omitempty"`
+	ServerError string          `json:"server_error,omitempty"`
+	Passed      bool            `json:"passed"`
+}
+
+// latencyPercentile returns the p-th percentile of sorted durations in ms
+func latencyPercentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	index := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if index < 0 {
+		index = 0
+	}
+	return float64(sorted[index].Microseconds()) / 1000
+}
+
+// benchSLO returns the configured p99 threshold for a verb, falling back to
+// the upstream SLO
+func benchSLO(verb string, clusterWide bool) time.Duration {
+	if slo := viper.GetDuration("bench.slo." + strings.ToLower(verb)); slo > 0 {
+		return slo
+	}
+	switch {
+	case verb == "LIST" && clusterWide:
+		return apiSLOClusterList
+	case verb == "LIST":
+		return apiSLONamespaceList
+	}
+	return apiSLOGet
+}
+
+// requestHistogram is a cumulative latency histogram of one request class
+type requestHistogram struct {
+	bounds []float64
+	counts []uint64
+	count  uint64
+}
+
+// quantile estimates the q-quantile in seconds by linear interpolation
+// within the bucket that contains it
+func (h requestHistogram) quantile(q float64) float64 {
+	if h.count == 0 || len(h.bounds) == 0 {
+		return 0
+	}
+	rank := q * float64(h.count)
+	lower, below := 0.0, uint64(0)
+	for i, bound := range h.bounds {
+		if float64(h.counts[i]) >= rank {
+			if math.IsInf(bound, 1) {
+				return lower
+			}
+			inBucket := h.counts[i] - below
+			if inBucket == 0 {
+				return bound
+			}
+			return lower + (bound-lower)*(rank-float64(below))/float64(inBucket)
+		}
+		lower, below = bound, h.counts[i]
+	}
+	return h.bounds[len(h.bounds)-1]
+}
+
+// sub returns the observations between an earlier scrape and h
+func (h requestHistogram) sub(before requestHistogram) requestHistogram {
+	if len(before.counts) != len(h.counts) {
+		return h
+	}
+	delta := requestHistogram{bounds: h.bounds, counts: make([]uint64, len(h.counts)), count: h.count - before.count}
+	for i := range h.counts {
+		delta.counts[i] = h.counts[i] - before.counts[i]
+	}
+	return delta
+}
+
+// scrapeRequestHistograms reads the API server's request and etcd latency
+// histograms from /metrics, keyed by "source verb resource"
+func (k *K8sToolkit) scrapeRequestHistograms(ctx context.Context) (map[string]requestHistogram, error) {
+	data, err := k.clientset.Discovery().RESTClient().Get().AbsPath("/metrics").DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read API server metrics: %w", err)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse API server metrics: %w", err)
+	}
+
+	histograms := make(map[string]requestHistogram)
+	collect := func(family, source, verbLabel, resourceLabel string) {
+		f, ok := families[family]
+		if !ok {
+			return
+		}
+		for _, m := range f.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			key := source + " " + labels[verbLabel] + " " + labels[resourceLabel]
+			h := histograms[key]
+			addHistogram(&h, m.GetHistogram())
+			histograms[key] = h
+		}
+	}
+	collect("apiserver_request_duration_seconds", "apiserver", "verb", "resource")
+	collect("etcd_request_duration_seconds", "etcd", "operation", "type")
+	return histograms, nil
+}
+
+// addHistogram adds the buckets of m to h. Series differing only in labels
+// that are not part of the key, such as scope or component, are summed.
+func addHistogram(h *requestHistogram, m *dto.Histogram) {
+	buckets := m.GetBucket()
+	if h.bounds == nil {
+		for _, b := range buckets {
+			h.bounds = append(h.bounds, b.GetUpperBound())
+		}
+		h.bounds = append(h.bounds, math.Inf(1))
+		h.counts = make([]uint64, len(h.bounds))
+	}
+	if len(buckets)+1 != len(h.bounds) {
+		return
+	}
+	for i, b := range buckets {
+		h.counts[i] += b.GetCumulativeCount()
+	}
+	h.counts[len(h.counts)-1] += m.GetSampleCount()
+	h.count += m.GetSampleCount()
+}
+
+// benchTarget is a resolved resource to benchmark
+type benchTarget struct {
+	name        string
+	gvr         schema.GroupVersionResource
+	namespaced  bool
+	clusterWide bool
+}
+
+// resolveBenchTargets maps resource names such as pods or deployments.apps
+// to served resources
+func (k *K8sToolkit) resolveBenchTargets(resources []string) ([]benchTarget, error) {
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(k.clientset.Discovery()))
+	var targets []benchTarget
+	for _, name := range resources {
+		resource, group, _ := strings.Cut(name, ".")
+		gvr, err := mapper.ResourceFor(schema.GroupVersionResource{Group: group, Resource: resource})
+		if err != nil {
+			return nil, fmt.Errorf("unknown resource %s: %w", name, err)
+		}
+		gvk, err := mapper.KindFor(gvr)
+		if err != nil {
+			return nil, fmt.Errorf("unknown resource %s: %w", name, err)
+		}
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			return nil, fmt.Errorf("unknown resource %s: %w", name, err)
+		}
+		namespaced := mapping.Scope.Name() == meta.RESTScopeNameNamespace
+		targets = append(targets, benchTarget{
+			name:        name,
+			gvr:         gvr,
+			namespaced:  namespaced,
+			clusterWide: !namespaced || k.namespace == "",
+		})
+	}
+	return targets, nil
+}
+
+// benchSamples collects the latencies of one verb on one resource
+type benchSamples struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int
+	lastError string
+	objects   int
+}
+
+func (s *benchSamples) add(d time.Duration, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errors++
+		s.lastError = classifyError(err).Error()
+		return
+	}
+	s.latencies = append(s.latencies, d)
+}
+
+// op summarizes the samples against the SLO
+func (s *benchSamples) op(resource, verb string, slo time.Duration) APIBenchOp {
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	op := APIBenchOp{
+		Resource:  resource,
+		Verb:      verb,
+		Samples:   len(s.latencies),
+		Errors:    s.errors,
+		Objects:   s.objects,
+		P50:       latencyPercentile(s.latencies, 50),
+		P90:       latencyPercentile(s.latencies, 90),
+		P99:       latencyPercentile(s.latencies, 99),
+		Max:       latencyPercentile(s.latencies, 100),
+		SLO:       float64(slo.Milliseconds()),
+		LastError: s.lastError,
+	}
+	op.WithinSLO = op.Errors == 0 && op.P99 <= op.SLO
+	return op
+}
+
+// BenchAPI measures LIST, GET and WATCH latency of the given resources as
+// seen by the client and compares the p99 with the SLOs. When the caller may
+// read /metrics, the API server and etcd latency distribution of the same
+// period is reported as well.
+func (k *K8sToolkit) BenchAPI(ctx context.Context, opts APIBenchOptions) (*APIBenchReport, error) {
+	targets, err := k.resolveBenchTargets(opts.Resources)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	report := &APIBenchReport{Context: k.contextName, Iterations: opts.Iterations, Concurrency: opts.Concurrency, Passed: true}
+
+	before, scrapeErr := k.scrapeRequestHistograms(ctx)
+
+	for _, target := range targets {
+		namespace := ""
+		if target.namespaced {
+			namespace = k.namespace
+		}
+		client := k.dynamicClient.Resource(target.gvr).Namespace(namespace)
+		list, get, watch := &benchSamples{}, &benchSamples{}, &benchSamples{}
+
+		// One unmeasured list finds the objects to get and the resource
+		// version to watch from
+		first, err := client.List(ctx, metav1.ListOptions{Limit: opts.PageSize})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", target.name, classifyError(err))
+		}
+
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < opts.Concurrency; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					start := time.Now()
+					objects := 0
+					listOpts := metav1.ListOptions{Limit: opts.PageSize}
+					var err error
+					for {
+						page, pageErr := client.List(ctx, listOpts)
+						if pageErr != nil {
+							err = pageErr
+							break
+						}
+						objects += len(page.Items)
+						if page.GetContinue() == "" {
+							break
+						}
+						listOpts.Continue = page.GetContinue()
+					}
+					list.add(time.Since(start), err)
+					list.mu.Lock()
+					list.objects = objects
+					list.mu.Unlock()
+
+					if len(first.Items) > 0 {
+						obj := first.Items[i%len(first.Items)]
+						start = time.Now()
+						_, err = k.dynamicClient.Resource(target.gvr).Namespace(obj.GetNamespace()).Get(ctx, obj.GetName(), metav1.GetOptions{})
+						get.add(time.Since(start), err)
+					}
+
+					if opts.Watch {
+						start = time.Now()
+						w, err := client.Watch(ctx, metav1.ListOptions{ResourceVersion: first.GetResourceVersion(), AllowWatchBookmarks: true})
+						watch.add(time.Since(start), err)
+						if err == nil {
+							w.Stop()
+						}
+					}
+				}
+			}()
+		}
+		for i := 0; i < opts.Iterations; i++ {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+
+		ops := []APIBenchOp{list.op(target.name, "LIST", benchSLO("LIST", target.clusterWide))}
+		if len(first.Items) > 0 {
+			ops = append(ops, get.op(target.name, "GET", benchSLO("GET", false)))
+		}
+		if opts.Watch {
+			ops = append(ops, watch.op(target.name, "WATCH", benchSLO("WATCH", false)))
+		}
+		for _, op := range ops {
+			if !op.WithinSLO {
+				report.Passed = false
+			}
+		}
+		report.Ops = append(report.Ops, ops...)
+	}
+
+	if scrapeErr == nil {
+		var after map[string]requestHistogram
+		after, scrapeErr = k.scrapeRequestHistograms(ctx)
+		if scrapeErr == nil {
+			report.Server = serverLatencies(before, after, targets)
+		}
+	}
+	if scrapeErr != nil {
+		report.ServerError = scrapeErr.Error()
+	}
+	return report, nil
+}
+
+// serverLatencies returns the distribution of the request classes the
+// benchmark exercised, from the difference of two scrapes
+func serverLatencies(before, after map[string]requestHistogram, targets []benchTarget) []ServerLatency {
+	var latencies []ServerLatency
+	for key, h := range after {
+		source, rest, _ := strings.Cut(key, " ")
+		verb, resource, _ := strings.Cut(rest, " ")
+		relevant := false
+		for _, target := range targets {
+			if source == "apiserver" && resource == target.gvr.Resource && (verb == "LIST" || verb == "GET" || verb == "WATCH") {
+				relevant = true
+			}
+			// etcd types are the resource prefix or the Go type, e.g.
+			// /registry/pods or *core.Pod, depending on the release
+			if source == "etcd" && strings.Contains(strings.ToLower(resource), strings.TrimSuffix(target.gvr.Resource, "s")) {
+				relevant = true
+			}
+		}
+		if !relevant {
+			continue
+		}
+		delta := h.sub(before[key])
+		if delta.count == 0 {
+			continue
+		}
+		latencies = append(latencies, ServerLatency{
+			Source:   source,
+			Verb:     verb,
+			Resource: resource,
+			Requests: delta.count,
+			P50:      math.Round(delta.quantile(0.5)*1e6) / 1000,
+			P99:      math.Round(delta.quantile(0.99)*1e6) / 1000,
+		})
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		a, b := latencies[i], latencies[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Resource != b.Resource {
+			return a.Resource < b.Resource
+		}
+		return a.Verb < b.Verb
+	})
+	return latencies
+}
+
+// PrintAPIBenchReport prints client-side percentiles and the server-side distribution
+func (k *K8sToolkit) PrintAPIBenchReport(report *APIBenchReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Printf("API latency of %s: %d iterations, concurrency %d\n\n", report.Context, report.Iterations, report.Concurrency)
+	fmt.Printf("%-28s %-6s %8s %10s %10s %10s %10s %10s  %s\n", "RESOURCE", "VERB", "SAMPLES", "P50 ms", "P90 ms", "P99 ms", "MAX ms", "SLO ms", "RESULT")
+	for _, op := range report.Ops {
+		result := "ok"
+		switch {
+		case op.Errors > 0:
+			result = fmt.Sprintf("%d errors: %s", op.Errors, op.LastError)
+		case !op.WithinSLO:
+			result = "p99 above SLO"
+		}
+		fmt.Printf("%-28s %-6s %8d %10.1f %10.1f %10.1f %10.1f %10.0f  %s\n", op.Resource, op.Verb, op.Samples, op.P50, op.P90, op.P99, op.Max, op.SLO, result)
+	}
+
+	if report.ServerError != "" {
+		fmt.Printf("\nServer-side distribution unavailable: %s\n", report.ServerError)
+	} else if len(report.Server) > 0 {
+		fmt.Printf("\nServer-side latency during the run (from /metrics histograms)\n")
+		fmt.Printf("%-10s %-14s %-40s %9s %10s %10s\n", "SOURCE", "VERB", "RESOURCE", "REQUESTS", "P50 ms", "P99 ms")
+		for _, s := range report.Server {
+			fmt.Printf("%-10s %-14s %-40s %9d %10.1f %10.1f\n", s.Source, s.Verb, s.Resource, s.Requests, s.P50, s.P99)
+		}
+	}
+
+	if report.Passed {
+		fmt.Println("\n✅ All p99 latencies within SLO")
+	} else {
+		fmt.Println("\n❌ Latency SLO missed")
+	}
+}
+
+// createBenchCmd creates the bench command for benchmarks against a live
+// cluster; the synthetic benchmarks are Benchmark functions run with
+// go test -bench
+func createBenchCmd() *cobra.Command {
+	benchCmd := &cobra.Command{
+		Use:   "bench",
+		Short: "Benchmark the cluster's API server",
+	}
+	benchCmd.AddCommand(createBenchAPICmd())
+	return benchCmd
+}
+
+// createBenchAPICmd creates the bench api command
+func createBenchAPICmd() *cobra.Command {
+	var opts APIBenchOptions
+
+	apiCmd := &cobra.Command{
+		Use:   "api",
+		Short: "Measure API server list, get and watch latency against SLOs",
+		Long: `Runs --iterations rounds of LIST (following every page), GET of an existing object and, with
+--watch, WATCH establishment against each of --resources and reports p50/p90/p99/max latency as
+seen by the client. The p99 is compared with bench.slo.list, bench.slo.get and bench.slo.watch
+from the config, defaulting to the upstream SLOs: 1s for single objects, 5s for lists in a
+namespace (--namespace) and 30s for cluster-wide lists. When the credentials may read /metrics,
+the API server request and etcd latency histograms are scraped before and after the run to show
+the server-side distribution of the same requests. Use it to validate control plane sizing after
+upgrades; exits 1 when an SLO is missed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			if opts.Iterations < 1 {
+				logger.Fatalf("--iterations must be at least 1")
+			}
+
+			report, err := toolkit.BenchAPI(context.Background(), opts)
+			if err != nil {
+				logger.Fatalf("API benchmark failed: %v", err)
+			}
+			toolkit.PrintAPIBenchReport(report)
+			if !report.Passed {
+				os.Exit(1)
+			}
+		},
+	}
+	apiCmd.Flags().StringSliceVar(&opts.Resources, "resources", []string{"pods", "nodes", "services", "configmaps"}, "Resources to measure (plural name or name.group)")
+	apiCmd.Flags().IntVar(&opts.Iterations, "iterations", 20, "Rounds of requests per resource")
+	apiCmd.Flags().IntVar(&opts.Concurrency, "concurrency", 1, "Rounds run in parallel")
+	apiCmd.Flags().Int64Var(&opts.PageSize, "page-size", 500, "Objects per LIST page (0 lists everything in one request)")
+	apiCmd.Flags().BoolVar(&opts.Watch, "watch", true, "Also measure how long a WATCH takes to be established")
+
+	return apiCmd
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	listerscorev1 "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// CachedResource is the state of one informer behind --use-cache
+type CachedResource struct {
+	Resource       string    `json:"resource"`
+	Synced         bool      `json:"synced"`
+	Objects        int       `json:"objects"`
+	LastEvent      time.Time `json:"last_event,omitempty"`
+	WatchError     string    `json:"watch_error,omitempty"`
+	WatchErrorTime time.Time `json:"watch_error_time,omitempty"`
+
+	// StalenessSeconds is how long the watch has been broken; 0 while it is
+	// healthy, in which case the cache trails the cluster by watch latency only
+	StalenessSeconds float64 `json:"staleness_seconds"`
+}
+
+// CacheStatus reports how fresh the informer cache is
+type CacheStatus struct {
+	StalenessSeconds float64          `json:"staleness_seconds"`
+	Resources        []CachedResource `json:"resources"`
+}
+
+// informerState tracks watch health of one informer
+type informerState struct {
+	informer   cache.SharedIndexInformer
+	lastEvent  time.Time
+	watchErr   error
+	watchErrAt time.Time
+}
+
+// clusterCache keeps nodes, pods and PVs in shared informers so repeated
+// runs read from memory instead of listing them from the API server
+type clusterCache struct {
+	nodes listerscorev1.NodeLister
+	pods  listerscorev1.PodLister
+	pvs   listerscorev1.PersistentVolumeLister
+
+	mu     sync.Mutex
+	states map[string]*informerState
+}
+
+// newClusterCache starts the informers and waits until they have synced
+func newClusterCache(ctx context.Context, clientset kubernetes.Interface) (*clusterCache, error) {
+	factory := informers.NewSharedInformerFactory(clientset, 0)
+	c := &clusterCache{
+		nodes:  factory.Core().V1().Nodes().Lister(),
+		pods:   factory.Core().V1().Pods().Lister(),
+		pvs:    factory.Core().V1().PersistentVolumes().Lister(),
+		states: make(map[string]*informerState),
+	}
+
+	informersByName := map[string]cache.SharedIndexInformer{
+		"nodes":             factory.Core().V1().Nodes().Informer(),
+		"pods":              factory.Core().V1().Pods().Informer(),
+		"persistentvolumes": factory.Core().V1().PersistentVolumes().Informer(),
+	}
+	for name, informer := range informersByName {
+		name := name
+		state := &informerState{informer: informer}
+		c.states[name] = state
+
+		// Managed fields are never read by checks and dominate pod size
+		if err := informer.SetTransform(func(obj interface{}) (interface{}, error) {
+			if accessor, ok := obj.(metav1.Object); ok {
+				accessor.SetManagedFields(nil)
+			}
+			return obj, nil
+		}); err != nil {
+			return nil, err
+		}
+		if err := informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+			c.mu.Lock()
+			if state.watchErr == nil {
+				state.watchErrAt = time.Now()
+			}
+			state.watchErr = err
+			c.mu.Unlock()
+			logger.WithField("resource", name).Warnf("cache watch failed: %v", err)
+		}); err != nil {
+			return nil, err
+		}
+		// Any event after a watch error means the reflector has recovered
+		observe := func() {
+			c.mu.Lock()
+			state.lastEvent = time.Now()
+			state.watchErr = nil
+			c.mu.Unlock()
+		}
+		informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(interface{}) { observe() },
+			UpdateFunc: func(interface{}, interface{}) { observe() },
+			DeleteFunc: func(interface{}) { observe() },
+		})
+	}
+
+	factory.Start(ctx.Done())
+	for name, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return nil, fmt.Errorf("cache of %s did not sync: %w", name, ctx.Err())
+		}
+	}
+	return c, nil
+}
+
+// Status reports sync state and staleness of every cached resource
+func (c *clusterCache) Status() *CacheStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	status := &CacheStatus{}
+	for name, state := range c.states {
+		resource := CachedResource{
+			Resource:  name,
+			Synced:    state.informer.HasSynced(),
+			Objects:   len(state.informer.GetStore().ListKeys()),
+			LastEvent: state.lastEvent,
+		}
+		if state.watchErr != nil {
+			resource.WatchError = state.watchErr.Error()
+			resource.WatchErrorTime = state.watchErrAt
+			resource.StalenessSeconds = now.Sub(state.watchErrAt).Seconds()
+		}
+		if resource.StalenessSeconds > status.StalenessSeconds {
+			status.StalenessSeconds = resource.StalenessSeconds
+		}
+		status.Resources = append(status.Resources, resource)
+	}
+	sort.Slice(status.Resources, func(i, j int) bool { return status.Resources[i].Resource < status.Resources[j].Resource })
+	return status
+}
+
+// enableCache switches List calls for nodes, pods and PVs to informers. It
+// is meant for long-running modes; the informers stop when ctx is done.
+func (k *K8sToolkit) enableCache(ctx context.Context) error {
+	start := time.Now()
+	c, err := newClusterCache(ctx, k.clientset)
+	if err != nil {
+		return err
+	}
+	k.cache = c
+	k.clientset = &cachedClientset{Interface: k.clientset, cache: c}
+	k.log().Infof("Cache synced in %s", time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// cacheSelector returns the label selector of a List call that the cache
+// can answer. Field selectors and explicit resource versions go to the API
+// server.
+func cacheSelector(opts metav1.ListOptions) (labels.Selector, bool) {
+	if opts.FieldSelector != "" || opts.ResourceVersion != "" {
+		return nil, false
+	}
+	selector, err := labels.Parse(opts.LabelSelector)
+	if err != nil {
+		return nil, false
+	}
+	return selector, true
+}
+
+// cachedClientset serves List calls for cached resources from informers and
+// passes everything else through. Pagination options are ignored because
+// the whole list is already in memory.
+type cachedClientset struct {
+	kubernetes.Interface
+	cache *clusterCache
+}
+
+func (c *cachedClientset) CoreV1() typedcorev1.CoreV1Interface {
+	return &cachedCoreV1{CoreV1Interface: c.Interface.CoreV1(), cache: c.cache}
+}
+
+type cachedCoreV1 struct {
+	typedcorev1.CoreV1Interface
+	cache *clusterCache
+}
+
+func (c *cachedCoreV1) Nodes() typedcorev1.NodeInterface {
+	return &cachedNodes{NodeInterface: c.CoreV1Interface.Nodes(), lister: c.cache.nodes}
+}
+
+func (c *cachedCoreV1) Pods(namespace string) typedcorev1.PodInterface {
+	return &cachedPods{PodInterface: c.CoreV1Interface.Pods(namespace), lister: c.cache.pods, namespace: namespace}
+}
+
+func (c *cachedCoreV1) PersistentVolumes() typedcorev1.PersistentVolumeInterface {
+	return &cachedPVs{PersistentVolumeInterface: c.CoreV1Interface.PersistentVolumes(), lister: c.cache.pvs}
+}
+
+type cachedNodes struct {
+	typedcorev1.NodeInterface
+	lister listerscorev1.NodeLister
+}
+
+func (n *cachedNodes) List(ctx context.Context, opts metav1.ListOptions) (*corev1.NodeList, error) {
+	selector, ok := cacheSelector(opts)
+	if !ok {
+		return n.NodeInterface.List(ctx, opts)
+	}
+	nodes, err := n.lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.NodeList{Items: make([]corev1.Node, 0, len(nodes))}
+	for _, node := range nodes {
+		list.Items = append(list.Items, *node)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list, nil
+}
+
+type cachedPods struct {
+	typedcorev1.PodInterface
+	lister    listerscorev1.PodLister
+	namespace string
+}
+
+func (p *cachedPods) List(ctx context.Context, opts metav1.ListOptions) (*corev1.PodList, error) {
+	selector, ok := cacheSelector(opts)
+	if !ok {
+		return p.PodInterface.List(ctx, opts)
+	}
+	var pods []*corev1.Pod
+	var err error
+	if p.namespace == "" {
+		pods, err = p.lister.List(selector)
+	} else {
+		pods, err = p.lister.Pods(p.namespace).List(selector)
+	}
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.PodList{Items: make([]corev1.Pod, 0, len(pods))}
+	for _, pod := range pods {
+		list.Items = append(list.Items, *pod)
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		if list.Items[i].Namespace != list.Items[j].Namespace {
+			return list.Items[i].Namespace < list.Items[j].Namespace
+		}
+		return list.Items[i].Name < list.Items[j].Name
+	})
+	return list, nil
+}
+
+type cachedPVs struct {
+	typedcorev1.PersistentVolumeInterface
+	lister listerscorev1.PersistentVolumeLister
+}
+
+func (v *cachedPVs) List(ctx context.Context, opts metav1.ListOptions) (*corev1.PersistentVolumeList, error) {
+	selector, ok := cacheSelector(opts)
+	if !ok {
+		return v.PersistentVolumeInterface.List(ctx, opts)
+	}
+	pvs, err := v.lister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+	list := &corev1.PersistentVolumeList{Items: make([]corev1.PersistentVolume, 0, len(pvs))}
+	for _, pv := range pvs {
+		list.Items = append(list.Items, *pv)
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	return list, nil
+}
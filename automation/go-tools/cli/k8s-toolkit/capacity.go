@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeCapacity is the allocatable, requested and used resources of one node
+type NodeCapacity struct {
+	Name              string  `json:"name"`
+	Schedulable       bool    `json:"schedulable"`
+	CPUAllocatable    int64   `json:"cpu_allocatable_millicores"`
+	CPURequested      int64   `json:"cpu_requested_millicores"`
+	CPUUsage          int64   `json:"cpu_usage_millicores"`
+	CPUFree           int64   `json:"cpu_free_millicores"`
+	MemoryAllocatable int64   `json:"memory_allocatable_bytes"`
+	MemoryRequested   int64   `json:"memory_requested_bytes"`
+	MemoryUsage       int64   `json:"memory_usage_bytes"`
+	MemoryFree        int64   `json:"memory_free_bytes"`
+	PodsAllocatable   int64   `json:"pods_allocatable"`
+	Pods              int64   `json:"pods"`
+	CPURequestPercent float64 `json:"cpu_percent_requested"`
+	MemRequestPercent float64 `json:"memory_percent_requested"`
+
+	node *corev1.Node
+}
+
+// LargestPod is the biggest pod request that still fits on a single node
+type LargestPod struct {
+	Node   string `json:"node"`
+	CPU    int64  `json:"cpu_millicores"`
+	Memory int64  `json:"memory_bytes"`
+}
+
+// ReplicaHeadroom is how many more replicas of a workload fit on the cluster
+type ReplicaHeadroom struct {
+	Workload      string         `json:"workload"`
+	CPURequest    int64          `json:"cpu_request_millicores"`
+	MemoryRequest int64          `json:"memory_request_bytes"`
+	Replicas      int64          `json:"additional_replicas"`
+	PerNode       map[string]int `json:"per_node,omitempty"`
+	Note          string         `json:"note,omitempty"`
+}
+
+// CapacityReport is the cluster capacity and bin-packing report
+type CapacityReport struct {
+	MetricsAvailable  bool              `json:"metrics_available"`
+	Nodes             []NodeCapacity    `json:"nodes"`
+	CPUAllocatable    int64             `json:"cpu_allocatable_millicores"`
+	CPURequested      int64             `json:"cpu_requested_millicores"`
+	CPUUsage          int64             `json:"cpu_usage_millicores"`
+	MemoryAllocatable int64             `json:"memory_allocatable_bytes"`
+	MemoryRequested   int64             `json:"memory_requested_bytes"`
+	MemoryUsage       int64             `json:"memory_usage_bytes"`
+	LargestByCPU      *LargestPod       `json:"largest_pod_by_cpu,omitempty"`
+	LargestByMemory   *LargestPod       `json:"largest_pod_by_memory,omitempty"`
+	Headroom          []ReplicaHeadroom `json:"headroom,omitempty"`
+}
+
+// BuildCapacityReport compares allocatable capacity with the requests of
+// scheduled pods and, when the metrics server is available, actual usage.
+// Free capacity is allocatable minus requests, since that is what the
+// scheduler works with; cordoned nodes are listed but never counted as free.
+func (k *K8sToolkit) BuildCapacityReport(ctx context.Context, selector string) (*CapacityReport, error) {
+	nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	byName := make(map[string]*NodeCapacity, len(nodes.Items))
+	report := &CapacityReport{}
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		byName[node.Name] = &NodeCapacity{
+			Name:              node.Name,
+			Schedulable:       !node.Spec.Unschedulable,
+			CPUAllocatable:    node.Status.Allocatable.Cpu().MilliValue(),
+			MemoryAllocatable: node.Status.Allocatable.Memory().Value(),
+			PodsAllocatable:   node.Status.Allocatable.Pods().Value(),
+			node:              node,
+		}
+	}
+
+	err = k.eachPod(ctx, "", metav1.ListOptions{}, func(pod *corev1.Pod) error {
+		nc := byName[pod.Spec.NodeName]
+		if nc == nil || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			return nil
+		}
+		cpu, _, memory, _ := podResources(pod)
+		nc.CPURequested += cpu
+		nc.MemoryRequested += memory
+		nc.Pods++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	if k.metricsClientset != nil {
+		metrics, err := k.metricsClientset.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			k.log().Warnf("failed to get node metrics, usage will be omitted: %v", err)
+		} else {
+			report.MetricsAvailable = true
+			for _, metric := range metrics.Items {
+				if nc := byName[metric.Name]; nc != nil {
+					nc.CPUUsage = metric.Usage.Cpu().MilliValue()
+					nc.MemoryUsage = metric.Usage.Memory().Value()
+				}
+			}
+		}
+	}
+
+	for _, nc := range byName {
+		nc.CPUFree = nc.CPUAllocatable - nc.CPURequested
+		nc.MemoryFree = nc.MemoryAllocatable - nc.MemoryRequested
+		if nc.CPUFree < 0 {
+			nc.CPUFree = 0
+		}
+		if nc.MemoryFree < 0 {
+			nc.MemoryFree = 0
+		}
+		nc.CPURequestPercent = percentOf(nc.CPURequested, nc.CPUAllocatable)
+		nc.MemRequestPercent = percentOf(nc.MemoryRequested, nc.MemoryAllocatable)
+
+		report.CPUAllocatable += nc.CPUAllocatable
+		report.CPURequested += nc.CPURequested
+		report.CPUUsage += nc.CPUUsage
+		report.MemoryAllocatable += nc.MemoryAllocatable
+		report.MemoryRequested += nc.MemoryRequested
+		report.MemoryUsage += nc.MemoryUsage
+
+		if !nc.Schedulable || nc.Pods >= nc.PodsAllocatable {
+			report.Nodes = append(report.Nodes, *nc)
+			continue
+		}
+		if report.LargestByCPU == nil || nc.CPUFree > report.LargestByCPU.CPU {
+			report.LargestByCPU = &LargestPod{Node: nc.Name, CPU: nc.CPUFree, Memory: nc.MemoryFree}
+		}
+		if report.LargestByMemory == nil || nc.MemoryFree > report.LargestByMemory.Memory {
+			report.LargestByMemory = &LargestPod{Node: nc.Name, CPU: nc.CPUFree, Memory: nc.MemoryFree}
+		}
+		report.Nodes = append(report.Nodes, *nc)
+	}
+
+	sort.Slice(report.Nodes, func(i, j int) bool { return report.Nodes[i].Name < report.Nodes[j].Name })
+	return report, nil
+}
+
+// nodeFits reports whether a pod template may be scheduled on the node by
+// its nodeSelector and NoSchedule/NoExecute taints. Affinity and topology
+// spread constraints are not evaluated.
+func nodeFits(node *corev1.Node, spec *corev1.PodSpec) bool {
+	if node.Spec.Unschedulable {
+		return false
+	}
+	for key, value := range spec.NodeSelector {
+		if node.Labels[key] != value {
+			return false
+		}
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		if !anyTolerates(spec.Tolerations, taint) {
+			return false
+		}
+	}
+	return true
+}
+
+// ReplicaHeadroom estimates how many more replicas of a Deployment or
+// StatefulSet, given as [kind/]namespace/name, fit in the free capacity of
+// the report's nodes
+func (k *K8sToolkit) ReplicaHeadroom(ctx context.Context, report *CapacityReport, workload string) (*ReplicaHeadroom, error) {
+	kind := "Deployment"
+	parts := strings.Split(workload, "/")
+	if len(parts) == 3 {
+		switch strings.ToLower(parts[0]) {
+		case "deployment", "deploy":
+		case "statefulset", "sts":
+			kind = "StatefulSet"
+		default:
+			return nil, fmt.Errorf("unsupported workload kind %q", parts[0])
+		}
+		parts = parts[1:]
+	}
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid workload %q, expected [kind/]namespace/name", workload)
+	}
+
+	template, err := k.workloadTemplate(ctx, parts[0], kind, parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s %s/%s: %w", kind, parts[0], parts[1], err)
+	}
+	cpu, _, memory, _ := podResources(&corev1.Pod{Spec: template.Spec})
+
+	headroom := &ReplicaHeadroom{
+		Workload:      fmt.Sprintf("%s/%s/%s", kind, parts[0], parts[1]),
+		CPURequest:    cpu,
+		MemoryRequest: memory,
+		PerNode:       make(map[string]int),
+	}
+	if cpu == 0 && memory == 0 {
+		headroom.Note = "pods have no requests; only the node pod limits bound the estimate"
+	}
+
+	for _, nc := range report.Nodes {
+		if !nodeFits(nc.node, &template.Spec) {
+			continue
+		}
+		fit := nc.PodsAllocatable - nc.Pods
+		if cpu > 0 && nc.CPUFree/cpu < fit {
+			fit = nc.CPUFree / cpu
+		}
+		if memory > 0 && nc.MemoryFree/memory < fit {
+			fit = nc.MemoryFree / memory
+		}
+		if fit > 0 {
+			headroom.PerNode[nc.Name] = int(fit)
+			headroom.Replicas += fit
+		}
+	}
+	return headroom, nil
+}
+
+// PrintCapacityReport prints per-node capacity, the largest schedulable pod
+// and replica headroom
+func (k *K8sToolkit) PrintCapacityReport(report *CapacityReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	usage := func(value int64, format func(int64) string) string {
+		if !report.MetricsAvailable {
+			return "-"
+		}
+		return format(value)
+	}
+	millicores := func(v int64) string { return fmt.Sprintf("%dm", v) }
+
+	fmt.Println("Cluster Capacity")
+	fmt.Println("=====================================")
+	fmt.Printf("%-30s %10s %10s %10s %10s %10s %10s %10s %10s %8s\n",
+		"NODE", "CPU ALLOC", "CPU REQ", "CPU USED", "CPU FREE", "MEM ALLOC", "MEM REQ", "MEM USED", "MEM FREE", "PODS")
+	for _, nc := range report.Nodes {
+		name := nc.Name
+		if !nc.Schedulable {
+			name += " (cordoned)"
+		}
+		fmt.Printf("%-30s %10s %10s %10s %10s %10s %10s %10s %10s %8s\n",
+			name,
+			millicores(nc.CPUAllocatable), millicores(nc.CPURequested), usage(nc.CPUUsage, millicores), millicores(nc.CPUFree),
+			formatMemory(nc.MemoryAllocatable), formatMemory(nc.MemoryRequested), usage(nc.MemoryUsage, formatMemory), formatMemory(nc.MemoryFree),
+			fmt.Sprintf("%d/%d", nc.Pods, nc.PodsAllocatable))
+	}
+
+	fmt.Printf("\nRequested: %dm of %dm CPU (%.1f%%), %s of %s memory (%.1f%%)\n",
+		report.CPURequested, report.CPUAllocatable, percentOf(report.CPURequested, report.CPUAllocatable),
+		formatMemory(report.MemoryRequested), formatMemory(report.MemoryAllocatable), percentOf(report.MemoryRequested, report.MemoryAllocatable))
+	if report.MetricsAvailable {
+		fmt.Printf("Used:      %dm CPU (%.1f%%), %s memory (%.1f%%)\n",
+			report.CPUUsage, percentOf(report.CPUUsage, report.CPUAllocatable),
+			formatMemory(report.MemoryUsage), percentOf(report.MemoryUsage, report.MemoryAllocatable))
+	}
+
+	if report.LargestByCPU == nil {
+		fmt.Println("\nNo schedulable node has room for another pod")
+	} else {
+		fmt.Printf("\nLargest schedulable pod: %dm CPU with %s memory (on %s), or %s memory with %dm CPU (on %s)\n",
+			report.LargestByCPU.CPU, formatMemory(report.LargestByCPU.Memory), report.LargestByCPU.Node,
+			formatMemory(report.LargestByMemory.Memory), report.LargestByMemory.CPU, report.LargestByMemory.Node)
+	}
+
+	for _, h := range report.Headroom {
+		fmt.Printf("\n%s (%dm CPU, %s memory per replica): %d more replicas fit\n",
+			h.Workload, h.CPURequest, formatMemory(h.MemoryRequest), h.Replicas)
+		if h.Note != "" {
+			fmt.Printf("  Note: %s\n", h.Note)
+		}
+	}
+}
+
+// createCapacityCmd creates the capacity command
+func createCapacityCmd() *cobra.Command {
+	var selector string
+	var workloads []string
+
+	capacityCmd := &cobra.Command{
+		Use:   "capacity",
+		Short: "Show node capacity, the largest schedulable pod and replica headroom",
+		Long: `Shows allocatable, requested and (with the metrics server) used CPU and memory per node,
+the largest pod that could still be scheduled, and for each --workload how many more replicas
+fit in the remaining requestable capacity. The estimate honours nodeSelector, taints and the
+node pod limit but not affinity or topology spread constraints.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx := context.Background()
+			report, err := toolkit.BuildCapacityReport(ctx, selector)
+			if err != nil {
+				logger.Fatalf("Failed to build capacity report: %v", err)
+			}
+
+			for _, workload := range workloads {
+				headroom, err := toolkit.ReplicaHeadroom(ctx, report, workload)
+				if err != nil {
+					logger.Fatalf("Failed to estimate headroom: %v", err)
+				}
+				report.Headroom = append(report.Headroom, *headroom)
+			}
+
+			toolkit.PrintCapacityReport(report)
+		},
+	}
+
+	capacityCmd.Flags().StringVarP(&selector, "selector", "l", "", "Only include nodes matching this label selector")
+	capacityCmd.Flags().StringSliceVar(&workloads, "workload", nil, "Estimate replica headroom for [deployment|statefulset/]namespace/name (repeatable)")
+
+	return capacityCmd
+}
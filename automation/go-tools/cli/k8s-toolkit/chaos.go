@@ -0,0 +1,560 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// chaosName names the resources created by chaos experiments
+const chaosName = "k8s-toolkit-chaos"
+
+// chaosCordonAnnotation marks nodes cordoned by cordon-random-node with the
+// time they are due to be uncordoned, in case the command never gets to it
+const chaosCordonAnnotation = "k8s-toolkit/chaos-cordoned-until"
+
+// KillPodsOptions controls kill-pods
+type KillPodsOptions struct {
+	Selector   string
+	Interval   time.Duration
+	Duration   time.Duration
+	MaxKills   int
+	MaxPercent int
+	Recovery   time.Duration
+	DryRun     bool
+}
+
+// CordonOptions controls cordon-random-node
+type CordonOptions struct {
+	Selector       string
+	Duration       time.Duration
+	MinSchedulable int
+	DryRun         bool
+}
+
+// LatencyOptions controls latency
+type LatencyOptions struct {
+	Selector     string
+	NodeSelector string
+	Namespace    string
+	Interface    string
+	Delay        time.Duration
+	Jitter       time.Duration
+	Duration     time.Duration
+	MaxNodes     int
+	Image        string
+	ReadyTimeout time.Duration
+	DryRun       bool
+}
+
+// checkChaosNamespace refuses experiments in namespaces listed under
+// chaos.protected_namespaces
+func checkChaosNamespace(namespace string) error {
+	if namespace == "" {
+		return fmt.Errorf("chaos experiments need a namespace; set --namespace")
+	}
+	for _, protected := range viper.GetStringSlice("chaos.protected_namespaces") {
+		if namespace == protected {
+			return fmt.Errorf("namespace %s is protected by chaos.protected_namespaces", namespace)
+		}
+	}
+	return nil
+}
+
+// sleepCtx waits for d and reports whether it elapsed before ctx ended
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+// KillPods deletes a random ready pod matching opts.Selector every
+// opts.Interval until opts.Duration elapses or opts.MaxKills pods are gone.
+// A kill is skipped while it would leave more than opts.MaxPercent of the
+// matching pods unavailable, and pods without a controller are never
+// killed since nothing would replace them. Afterwards it waits up to
+// opts.Recovery for all matching pods to be ready again.
+func (k *K8sToolkit) KillPods(ctx context.Context, m *mutation, namespace string, opts KillPodsOptions) error {
+	pods := k.clientset.CoreV1().Pods(namespace)
+	listOptions := metav1.ListOptions{LabelSelector: opts.Selector}
+	deadline := time.Now().Add(opts.Duration)
+	killed := 0
+
+	for killed < opts.MaxKills && time.Now().Before(deadline) {
+		list, err := pods.List(ctx, listOptions)
+		if err != nil {
+			return fmt.Errorf("failed to list pods: %w", err)
+		}
+
+		var candidates []corev1.Pod
+		unavailable := 0
+		for _, pod := range list.Items {
+			if pod.DeletionTimestamp != nil || !isPodReady(&pod) {
+				unavailable++
+				continue
+			}
+			if metav1.GetControllerOf(&pod) != nil {
+				candidates = append(candidates, pod)
+			}
+		}
+
+		total := len(list.Items)
+		switch {
+		case len(candidates) == 0:
+			fmt.Printf("  %s  no ready pods with a controller match %s\n", time.Now().Format("15:04:05"), opts.Selector)
+		case (unavailable+1)*100 > opts.MaxPercent*total:
+			fmt.Printf("  %s  skip: %d of %d pods unavailable, killing another exceeds %d%%\n",
+				time.Now().Format("15:04:05"), unavailable, total, opts.MaxPercent)
+		default:
+			pod := candidates[rand.Intn(len(candidates))]
+			target := objectRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}.String()
+			if opts.DryRun {
+				fmt.Printf("  %s  would kill %s (%d of %d pods unavailable)\n", time.Now().Format("15:04:05"), target, unavailable, total)
+			} else {
+				err := pods.Delete(ctx, pod.Name, metav1.DeleteOptions{Preconditions: &metav1.Preconditions{UID: &pod.UID}})
+				m.record("delete", target, err)
+				if err != nil && !apierrors.IsNotFound(err) {
+					return fmt.Errorf("failed to kill %s: %w", target, err)
+				}
+				fmt.Printf("  %s  killed %s (%d of %d pods unavailable)\n", time.Now().Format("15:04:05"), target, unavailable, total)
+			}
+			killed++
+		}
+
+		if killed >= opts.MaxKills || !sleepCtx(ctx, opts.Interval) {
+			break
+		}
+	}
+	if opts.DryRun {
+		fmt.Printf("Dry run: %d pods would have been killed\n", killed)
+		return nil
+	}
+	fmt.Printf("Killed %d pods, waiting for %s to recover\n", killed, opts.Selector)
+
+	// Wait for recovery with a fresh context so an interrupt still reports it
+	recoverCtx, cancel := context.WithTimeout(context.Background(), opts.Recovery)
+	defer cancel()
+	for {
+		list, err := pods.List(recoverCtx, listOptions)
+		if err == nil {
+			ready := 0
+			for _, pod := range list.Items {
+				if pod.DeletionTimestamp == nil && isPodReady(&pod) {
+					ready++
+				}
+			}
+			if ready == len(list.Items) {
+				fmt.Printf("Recovered: %d of %d pods ready\n", ready, len(list.Items))
+				return nil
+			}
+		}
+		if !sleepCtx(recoverCtx, 5*time.Second) {
+			return fmt.Errorf("pods matching %s did not recover within %s", opts.Selector, opts.Recovery)
+		}
+	}
+}
+
+// CordonRandomNode cordons one random ready, schedulable node matching
+// opts.Selector for opts.Duration and uncordons it again, also when ctx is
+// cancelled. It refuses when fewer than opts.MinSchedulable nodes would
+// remain schedulable.
+func (k *K8sToolkit) CordonRandomNode(ctx context.Context, m *mutation, opts CordonOptions) error {
+	nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: opts.Selector})
+	if err != nil {
+		return fmt.Errorf("failed to list nodes: %w", err)
+	}
+	var candidates []string
+	for _, node := range nodes.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+				candidates = append(candidates, node.Name)
+			}
+		}
+	}
+	if len(candidates)-1 < opts.MinSchedulable {
+		return fmt.Errorf("only %d ready schedulable nodes; cordoning one would leave fewer than %d", len(candidates), opts.MinSchedulable)
+	}
+
+	node := candidates[rand.Intn(len(candidates))]
+	if opts.DryRun {
+		fmt.Printf("Dry run: %s would be cordoned for %s (%d of %d nodes left schedulable)\n", node, opts.Duration, len(candidates)-1, len(candidates))
+		return nil
+	}
+
+	until := time.Now().Add(opts.Duration).UTC().Format(time.RFC3339)
+	patch := fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}},"spec":{"unschedulable":true}}`, chaosCordonAnnotation, until)
+	_, err = k.clientset.CoreV1().Nodes().Patch(ctx, node, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	m.record("cordon", "Node/"+node, err)
+	if err != nil {
+		return fmt.Errorf("failed to cordon %s: %w", node, err)
+	}
+	fmt.Printf("Cordoned %s until %s\n", node, until)
+
+	sleepCtx(ctx, opts.Duration)
+
+	// Roll back with a fresh context so an interrupt still uncordons
+	rollbackCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	patch = fmt.Sprintf(`{"metadata":{"annotations":{%q:null}},"spec":{"unschedulable":false}}`, chaosCordonAnnotation)
+	_, err = k.clientset.CoreV1().Nodes().Patch(rollbackCtx, node, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	m.record("uncordon", "Node/"+node, err)
+	if err != nil {
+		return fmt.Errorf("failed to uncordon %s, uncordon it by hand: %w", node, err)
+	}
+	fmt.Printf("Uncordoned %s\n", node)
+	return nil
+}
+
+// latencyNodes returns the nodes to inject latency on: those matching
+// opts.NodeSelector, or those running pods matching opts.Selector in
+// namespace, limited to a random opts.MaxNodes of them
+func (k *K8sToolkit) latencyNodes(ctx context.Context, namespace string, opts LatencyOptions) ([]string, error) {
+	seen := make(map[string]bool)
+	if opts.NodeSelector != "" {
+		nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: opts.NodeSelector})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list nodes: %w", err)
+		}
+		for _, node := range nodes.Items {
+			seen[node.Name] = true
+		}
+	} else {
+		pods, err := k.clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: opts.Selector})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %w", err)
+		}
+		for _, pod := range pods.Items {
+			if pod.Spec.NodeName != "" && pod.Status.Phase == corev1.PodRunning {
+				seen[pod.Spec.NodeName] = true
+			}
+		}
+	}
+
+	var nodes []string
+	for node := range seen {
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no nodes match the target")
+	}
+	rand.Shuffle(len(nodes), func(i, j int) { nodes[i], nodes[j] = nodes[j], nodes[i] })
+	if len(nodes) > opts.MaxNodes {
+		nodes = nodes[:opts.MaxNodes]
+	}
+	sort.Strings(nodes)
+	return nodes, nil
+}
+
+// latencyDaemonSet builds the DaemonSet that adds a netem delay to the
+// interface of each target node. The script removes the delay when the pod
+// is terminated and by itself once the deadline passes, and never adds it
+// again after the deadline, so a lost rollback only leaves an idle pod.
+func latencyDaemonSet(nodes []string, opts LatencyOptions) *appsv1.DaemonSet {
+	deadline := time.Now().Add(opts.Duration).Unix()
+	script := fmt.Sprintf(`idle() { while true; do sleep 3600; done; }
+remove() { tc qdisc del dev %[1]s root 2>/dev/null; }
+trap 'remove; exit 0' TERM INT
+[ "$(date +%%s)" -ge %[2]d ] && idle
+tc qdisc replace dev %[1]s root netem delay %[3]dms %[4]dms || exit 1
+while [ "$(date +%%s)" -lt %[2]d ]; do sleep 5 & wait $!; done
+remove
+idle
+`, opts.Interface, deadline, opts.Delay.Milliseconds(), opts.Jitter.Milliseconds())
+
+	matchLabels := map[string]string{"app.kubernetes.io/name": chaosName, "app.kubernetes.io/component": "latency"}
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: chaosName + "-latency-",
+			Namespace:    opts.Namespace,
+			Labels:       matchLabels,
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: matchLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: matchLabels},
+				Spec: corev1.PodSpec{
+					HostNetwork:                  true,
+					AutomountServiceAccountToken: new(bool),
+					Tolerations:                  []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+							NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+								MatchFields: []corev1.NodeSelectorRequirement{{
+									Key:      "metadata.name",
+									Operator: corev1.NodeSelectorOpIn,
+									Values:   nodes,
+								}},
+							}},
+						},
+					}},
+					Containers: []corev1.Container{{
+						Name:    "latency",
+						Image:   opts.Image,
+						Command: []string{"sh", "-c", script},
+						SecurityContext: &corev1.SecurityContext{
+							Capabilities: &corev1.Capabilities{
+								Drop: []corev1.Capability{"ALL"},
+								Add:  []corev1.Capability{"NET_ADMIN"},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// InjectLatency runs a DaemonSet on the target nodes that delays all
+// traffic leaving opts.Interface for opts.Duration, then deletes it, also
+// when ctx is cancelled
+func (k *K8sToolkit) InjectLatency(ctx context.Context, m *mutation, namespace string, opts LatencyOptions) error {
+	nodes, err := k.latencyNodes(ctx, namespace, opts)
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		fmt.Printf("Dry run: %s±%s latency would be added on %s of %d nodes for %s: %s\n",
+			opts.Delay, opts.Jitter, opts.Interface, len(nodes), opts.Duration, strings.Join(nodes, ", "))
+		return nil
+	}
+
+	daemonSets := k.clientset.AppsV1().DaemonSets(opts.Namespace)
+	ds, err := daemonSets.Create(ctx, latencyDaemonSet(nodes, opts), metav1.CreateOptions{})
+	if err != nil {
+		m.record("create", "DaemonSet/"+opts.Namespace+"/"+chaosName+"-latency", err)
+		return fmt.Errorf("failed to create latency DaemonSet: %w", err)
+	}
+	target := objectRef{Kind: "DaemonSet", Namespace: ds.Namespace, Name: ds.Name}.String()
+	m.record("create", target, nil)
+	defer func() {
+		// Roll back with a fresh context so an interrupt still cleans up;
+		// terminating the pods removes the delay
+		deleteCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		policy := metav1.DeletePropagationForeground
+		err := daemonSets.Delete(deleteCtx, ds.Name, metav1.DeleteOptions{PropagationPolicy: &policy})
+		m.record("delete", target, err)
+		if err != nil {
+			k.log().Warnf("failed to delete %s, delete it by hand: %v", target, err)
+			return
+		}
+		fmt.Printf("Deleted %s, latency removed\n", target)
+	}()
+
+	readyCtx, cancel := context.WithTimeout(ctx, opts.ReadyTimeout)
+	defer cancel()
+	for {
+		current, err := daemonSets.Get(readyCtx, ds.Name, metav1.GetOptions{})
+		if err == nil && current.Status.DesiredNumberScheduled > 0 && current.Status.NumberReady == current.Status.DesiredNumberScheduled {
+			break
+		}
+		if !sleepCtx(readyCtx, 2*time.Second) {
+			return fmt.Errorf("%s did not become ready within %s", target, opts.ReadyTimeout)
+		}
+	}
+	fmt.Printf("Injecting %s±%s latency on %s of %s until %s\n", opts.Delay, opts.Jitter, opts.Interface,
+		strings.Join(nodes, ", "), time.Now().Add(opts.Duration).Format("15:04:05"))
+
+	sleepCtx(ctx, opts.Duration)
+	return nil
+}
+
+// chaosContext returns a context cancelled on interrupt
+func chaosContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// createChaosCmd creates the chaos command
+func createChaosCmd() *cobra.Command {
+	chaosCmd := &cobra.Command{
+		Use:   "chaos",
+		Short: "Inject failures for game days and resilience testing",
+		Long: `Minimal chaos experiments. Each one is limited in blast radius and duration, rolls back on its
+own when the duration ends or the command is interrupted, and only shows its plan with --dry-run.
+Namespaces under chaos.protected_namespaces (default kube-system) cannot be targeted.`,
+	}
+	chaosCmd.AddCommand(createKillPodsCmd())
+	chaosCmd.AddCommand(createCordonRandomNodeCmd())
+	chaosCmd.AddCommand(createLatencyCmd())
+	return chaosCmd
+}
+
+// createKillPodsCmd creates the chaos kill-pods command
+func createKillPodsCmd() *cobra.Command {
+	var opts KillPodsOptions
+
+	killPodsCmd := &cobra.Command{
+		Use:   "kill-pods",
+		Short: "Delete random pods matching a selector at a fixed interval",
+		Long: `Deletes one random ready pod matching --selector in --namespace every --interval until --duration
+elapses or --max-kills pods are deleted. A kill is skipped while more than --max-unavailable percent
+of the matching pods would be unavailable, and pods without a controller are never deleted. Deleted
+pods are replaced by their controllers; afterwards the command waits up to --recovery for all
+matching pods to be ready again and fails if they are not.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			if err := checkChaosNamespace(toolkit.namespace); err != nil {
+				logger.Fatalf("kill-pods refused: %v", err)
+			}
+			if _, err := labels.Parse(opts.Selector); err != nil || opts.Selector == "" {
+				logger.Fatalf("kill-pods needs a valid --selector")
+			}
+			if opts.MaxPercent <= 0 || opts.MaxPercent > 100 {
+				logger.Fatalf("--max-unavailable must be between 1 and 100")
+			}
+
+			ctx, stop := chaosContext()
+			defer stop()
+
+			var m *mutation
+			if !opts.DryRun {
+				m, err = beginMutation("chaos kill-pods", fmt.Sprintf("About to delete up to %d pods matching %s in %s over %s.",
+					opts.MaxKills, opts.Selector, toolkit.namespace, opts.Duration))
+				if err != nil {
+					logger.Fatalf("kill-pods aborted: %v", err)
+				}
+			}
+			if err := toolkit.KillPods(ctx, m, toolkit.namespace, opts); err != nil {
+				logger.Fatalf("kill-pods failed: %v", err)
+			}
+		},
+	}
+
+	killPodsCmd.Flags().StringVarP(&opts.Selector, "selector", "l", "", "Label selector of the pods to kill (required)")
+	killPodsCmd.Flags().DurationVar(&opts.Interval, "interval", time.Minute, "Time between kills")
+	killPodsCmd.Flags().DurationVar(&opts.Duration, "duration", 10*time.Minute, "How long to keep killing pods")
+	killPodsCmd.Flags().IntVar(&opts.MaxKills, "max-kills", 5, "Maximum number of pods to kill in total")
+	killPodsCmd.Flags().IntVar(&opts.MaxPercent, "max-unavailable", 25, "Maximum percentage of matching pods unavailable at once")
+	killPodsCmd.Flags().DurationVar(&opts.Recovery, "recovery", 5*time.Minute, "Time to wait for the pods to recover afterwards")
+	killPodsCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Show which pods would be killed without deleting them")
+
+	return killPodsCmd
+}
+
+// createCordonRandomNodeCmd creates the chaos cordon-random-node command
+func createCordonRandomNodeCmd() *cobra.Command {
+	var opts CordonOptions
+
+	cordonCmd := &cobra.Command{
+		Use:   "cordon-random-node",
+		Short: "Cordon a random node for a while",
+		Long: fmt.Sprintf(`Cordons one random ready and schedulable node, optionally among those matching --selector, for
+--duration and uncordons it again, also when interrupted. Refuses when fewer than --min-schedulable
+nodes would remain schedulable. The node carries the %s annotation while it is
+cordoned, so a node left behind by a lost rollback is easy to spot.`, chaosCordonAnnotation),
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx, stop := chaosContext()
+			defer stop()
+
+			var m *mutation
+			if !opts.DryRun {
+				m, err = beginMutation("chaos cordon-random-node", fmt.Sprintf("About to cordon a random node for %s.", opts.Duration))
+				if err != nil {
+					logger.Fatalf("cordon-random-node aborted: %v", err)
+				}
+			}
+			if err := toolkit.CordonRandomNode(ctx, m, opts); err != nil {
+				logger.Fatalf("cordon-random-node failed: %v", err)
+			}
+		},
+	}
+
+	cordonCmd.Flags().StringVarP(&opts.Selector, "selector", "l", "", "Label selector of the candidate nodes")
+	cordonCmd.Flags().DurationVar(&opts.Duration, "duration", 10*time.Minute, "How long to keep the node cordoned")
+	cordonCmd.Flags().IntVar(&opts.MinSchedulable, "min-schedulable", 2, "Minimum number of candidate nodes that must stay schedulable")
+	cordonCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Show which node would be cordoned without changing it")
+
+	return cordonCmd
+}
+
+// createLatencyCmd creates the chaos latency command
+func createLatencyCmd() *cobra.Command {
+	var opts LatencyOptions
+
+	latencyCmd := &cobra.Command{
+		Use:   "latency",
+		Short: "Add network latency on nodes through a temporary DaemonSet",
+		Long: `Adds --delay (±--jitter) to all traffic leaving --interface on the nodes running pods that match
+--selector in --namespace, or on the nodes matching --node-selector, at most --max-nodes of them
+picked at random. A temporary host-network DaemonSet with NET_ADMIN in --daemonset-namespace runs
+tc netem on each node and is deleted after --duration or when interrupted, which removes the
+delay. Every pod on those nodes is affected, not only the selected ones. The delay also expires on
+its own at the deadline should the DaemonSet be left behind. Replacing the root qdisc discards any
+existing one on the interface until the experiment ends.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			if (opts.Selector == "") == (opts.NodeSelector == "") {
+				logger.Fatalf("latency needs exactly one of --selector and --node-selector")
+			}
+			if opts.Selector != "" {
+				if err := checkChaosNamespace(toolkit.namespace); err != nil {
+					logger.Fatalf("latency refused: %v", err)
+				}
+			}
+			if opts.MaxNodes <= 0 {
+				logger.Fatalf("--max-nodes must be at least 1")
+			}
+
+			ctx, stop := chaosContext()
+			defer stop()
+
+			var m *mutation
+			if !opts.DryRun {
+				m, err = beginMutation("chaos latency", fmt.Sprintf("About to add %s latency on up to %d nodes for %s.",
+					opts.Delay, opts.MaxNodes, opts.Duration))
+				if err != nil {
+					logger.Fatalf("latency aborted: %v", err)
+				}
+			}
+			if err := toolkit.InjectLatency(ctx, m, toolkit.namespace, opts); err != nil {
+				logger.Fatalf("latency failed: %v", err)
+			}
+		},
+	}
+
+	latencyCmd.Flags().StringVarP(&opts.Selector, "selector", "l", "", "Label selector of the pods whose nodes get the latency")
+	latencyCmd.Flags().StringVar(&opts.NodeSelector, "node-selector", "", "Label selector of the nodes that get the latency")
+	latencyCmd.Flags().StringVar(&opts.Namespace, "daemonset-namespace", "default", "Namespace to run the latency DaemonSet in")
+	latencyCmd.Flags().StringVar(&opts.Interface, "interface", "eth0", "Node network interface to delay")
+	latencyCmd.Flags().DurationVar(&opts.Delay, "delay", 100*time.Millisecond, "Latency to add")
+	latencyCmd.Flags().DurationVar(&opts.Jitter, "jitter", 10*time.Millisecond, "Random variation of the latency")
+	latencyCmd.Flags().DurationVar(&opts.Duration, "duration", 5*time.Minute, "How long to keep the latency")
+	latencyCmd.Flags().IntVar(&opts.MaxNodes, "max-nodes", 1, "Maximum number of nodes to add latency on")
+	latencyCmd.Flags().StringVar(&opts.Image, "image", "nicolaka/netshoot:v0.11", "Image with tc for the DaemonSet")
+	latencyCmd.Flags().DurationVar(&opts.ReadyTimeout, "ready-timeout", 2*time.Minute, "Maximum time for the DaemonSet to start")
+	latencyCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Show which nodes would get the latency without changing anything")
+
+	return latencyCmd
+}
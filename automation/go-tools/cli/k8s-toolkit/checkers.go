@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// HealthChecker is a check that RunHealthCheck can schedule. Name selects the
+// check in checks.enabled; Component labels its result in reports.
+type HealthChecker interface {
+	Name() string
+	Component() string
+	Timeout() time.Duration
+	Check(ctx context.Context) HealthCheckResult
+}
+
+// HealthCheckerFactory binds a checker to the toolkit it runs against
+type HealthCheckerFactory func(k *K8sToolkit) HealthChecker
+
+// healthCheckerRegistry holds the registered checkers in registration order,
+// which is also report order
+var healthCheckerRegistry = struct {
+	mu        sync.Mutex
+	names     []string
+	factories map[string]HealthCheckerFactory
+}{factories: make(map[string]HealthCheckerFactory)}
+
+// RegisterHealthChecker adds a checker to every health check run. It panics
+// when the name is already registered, like database/sql.Register.
+func RegisterHealthChecker(name string, factory HealthCheckerFactory) {
+	healthCheckerRegistry.mu.Lock()
+	defer healthCheckerRegistry.mu.Unlock()
+	if _, ok := healthCheckerRegistry.factories[name]; ok {
+		panic(fmt.Sprintf("health checker %q registered twice", name))
+	}
+	healthCheckerRegistry.names = append(healthCheckerRegistry.names, name)
+	healthCheckerRegistry.factories[name] = factory
+}
+
+// registerCheck registers a toolkit method as a checker
+func registerCheck(name, component string, timeout time.Duration, check func(*K8sToolkit, context.Context) HealthCheckResult) {
+	RegisterHealthChecker(name, func(k *K8sToolkit) HealthChecker {
+		return healthCheck{name, component, timeout, func(ctx context.Context) HealthCheckResult { return check(k, ctx) }}
+	})
+}
+
+// healthCheck adapts a function to HealthChecker
+type healthCheck struct {
+	name      string
+	component string
+	timeout   time.Duration
+	run       func(ctx context.Context) HealthCheckResult
+}
+
+func (c healthCheck) Name() string                                { return c.name }
+func (c healthCheck) Component() string                           { return c.component }
+func (c healthCheck) Timeout() time.Duration                      { return c.timeout }
+func (c healthCheck) Check(ctx context.Context) HealthCheckResult { return c.run(ctx) }
+
+// healthChecks returns the registered checkers followed by the mesh checks
+// when Istio is installed, the exec checks from checks.exec and the installed
+// check plugins, in report order
+func (k *K8sToolkit) healthChecks() []HealthChecker {
+	healthCheckerRegistry.mu.Lock()
+	var checkers []HealthChecker
+	for _, name := range healthCheckerRegistry.names {
+		checkers = append(checkers, healthCheckerRegistry.factories[name](k))
+	}
+	healthCheckerRegistry.mu.Unlock()
+
+	checkers = append(checkers, k.meshChecks()...)
+	checkers = append(checkers, k.execChecks()...)
+	return append(checkers, k.pluginChecks()...)
+}
+
+// execCheckConfig is an entry under checks.exec in the config file
+type execCheckConfig struct {
+	Name      string            `mapstructure:"name"`
+	Component string            `mapstructure:"component"`
+	Command   []string          `mapstructure:"command"`
+	Timeout   time.Duration     `mapstructure:"timeout"`
+	Env       map[string]string `mapstructure:"env"`
+
+	// Isolated runs the command with only the environment and the
+	// restricted kubeconfig from pluginEnv, in an empty working directory
+	Isolated bool `mapstructure:"isolated"`
+}
+
+// execCheck runs an external command as a health check. The exit code sets
+// the status (0 Healthy, 1 Warning, anything else Critical) unless stdout is
+// a JSON object with a status, which then sets status, message and details.
+type execCheck struct {
+	config    execCheckConfig
+	toolkit   *K8sToolkit
+	configErr error
+}
+
+// execCheckOutput is the JSON an exec check may print on stdout
+type execCheckOutput struct {
+	Status  string            `json:"status"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details"`
+}
+
+// execChecks builds the checks configured under checks.exec. An invalid
+// entry becomes a check that reports the configuration error.
+func (k *K8sToolkit) execChecks() []HealthChecker {
+	if !viper.IsSet("checks.exec") {
+		return nil
+	}
+	var configs []execCheckConfig
+	if err := viper.UnmarshalKey("checks.exec", &configs); err != nil {
+		return []HealthChecker{&execCheck{
+			config:    execCheckConfig{Name: "exec", Component: "Exec Checks"},
+			configErr: fmt.Errorf("invalid checks.exec: %w", err),
+		}}
+	}
+
+	checkers := make([]HealthChecker, 0, len(configs))
+	for i, config := range configs {
+		check := &execCheck{config: config, toolkit: k}
+		switch {
+		case config.Name == "":
+			check.config.Name = fmt.Sprintf("exec-%d", i)
+			check.configErr = fmt.Errorf("checks.exec[%d] has no name", i)
+		case len(config.Command) == 0:
+			check.configErr = fmt.Errorf("checks.exec[%d] (%s) has no command", i, config.Name)
+		}
+		if check.config.Component == "" {
+			check.config.Component = check.config.Name
+		}
+		if check.config.Timeout <= 0 {
+			check.config.Timeout = 30 * time.Second
+		}
+		checkers = append(checkers, check)
+	}
+	return checkers
+}
+
+func (c *execCheck) Name() string           { return c.config.Name }
+func (c *execCheck) Component() string      { return c.config.Component }
+func (c *execCheck) Timeout() time.Duration { return c.config.Timeout }
+
+// Check runs the command with the kubeconfig, context and namespace of the
+// toolkit in its environment
+func (c *execCheck) Check(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: c.config.Component,
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+	if c.configErr != nil {
+		result.Status = "Warning"
+		result.Message = c.configErr.Error()
+		result.ErrorCategory = CategoryConfig
+		return result
+	}
+
+	cmd := exec.CommandContext(ctx, c.config.Command[0], c.config.Command[1:]...)
+	if c.config.Isolated {
+		dir, err := os.MkdirTemp("", "k8s-toolkit-check-")
+		if err != nil {
+			result.Status = "Warning"
+			result.Message = fmt.Sprintf("Failed to create working directory: %v", err)
+			return result
+		}
+		defer os.RemoveAll(dir)
+		cmd.Dir = dir
+		if cmd.Env, err = c.toolkit.pluginEnv(ctx, dir, c.toolkit.namespace, c.config.Timeout); err != nil {
+			result.Status = "Warning"
+			result.Message = fmt.Sprintf("Failed to prepare the isolated environment: %v", err)
+			result.ErrorCategory = CategoryConfig
+			return result
+		}
+	} else {
+		cmd.Env = append(os.Environ(), toolkitEnv(c.toolkit.namespace)...)
+	}
+	for key, value := range c.config.Env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Check command did not finish: %v", ctx.Err())
+		result.Err = ctx.Err()
+		return result
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to run check command: %v", err)
+		result.ErrorCategory = CategoryConfig
+		return result
+	}
+
+	var output execCheckOutput
+	if json.Unmarshal(stdout.Bytes(), &output) == nil && output.Status != "" {
+		switch output.Status {
+		case "Healthy", "Warning", "Critical":
+			result.Status = output.Status
+		default:
+			result.Status = "Warning"
+			output.Message = fmt.Sprintf("unknown status %q: %s", output.Status, output.Message)
+		}
+		result.Message = output.Message
+		for key, value := range output.Details {
+			result.Details[key] = value
+		}
+		return result
+	}
+
+	exitCode := 0
+	if exitErr != nil {
+		exitCode = exitErr.ExitCode()
+	}
+	switch exitCode {
+	case 0:
+		result.Status = "Healthy"
+	case 1:
+		result.Status = "Warning"
+	default:
+		result.Status = "Critical"
+	}
+	result.Details["exit_code"] = fmt.Sprint(exitCode)
+
+	result.Message = firstLine(stdout.String())
+	if result.Message == "" {
+		result.Message = firstLine(stderr.String())
+	}
+	if result.Message == "" {
+		result.Message = fmt.Sprintf("Check command exited with %d", exitCode)
+	}
+	return result
+}
+
+// firstLine returns the first non-empty line of s
+func firstLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
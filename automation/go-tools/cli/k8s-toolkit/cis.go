@@ -0,0 +1,503 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// CIS control statuses
+const (
+	cisPass   = "PASS"
+	cisFail   = "FAIL"
+	cisManual = "MANUAL"
+)
+
+// cisControlPlaneComponents are the static pods the control plane controls
+// read their flags from, by their kubeadm component label
+var cisControlPlaneComponents = []string{"kube-apiserver", "kube-controller-manager", "kube-scheduler", "etcd"}
+
+// cisControl is an entry of the cis.yaml data bundle
+type cisControl struct {
+	ID          string `yaml:"id"`
+	Title       string `yaml:"title"`
+	Benchmark   string `yaml:"benchmark"`
+	Component   string `yaml:"component"`
+	Flag        string `yaml:"flag"`
+	Field       string `yaml:"field"`
+	Test        string `yaml:"test"`
+	Value       string `yaml:"value"`
+	Default     string `yaml:"default"`
+	Severity    string `yaml:"severity"`
+	Remediation string `yaml:"remediation"`
+}
+
+// cisBenchmark is the cis.yaml data bundle
+type cisBenchmark struct {
+	Benchmark string       `yaml:"benchmark"`
+	Controls  []cisControl `yaml:"controls"`
+}
+
+// CISResult is the outcome of one control across every instance of its component
+type CISResult struct {
+	Control     string   `json:"control"`
+	RuleID      string   `json:"rule_id"`
+	Benchmark   string   `json:"benchmark,omitempty"`
+	Title       string   `json:"title"`
+	Component   string   `json:"component"`
+	Status      string   `json:"status"`
+	Severity    string   `json:"severity"`
+	Evidence    []string `json:"evidence,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+}
+
+// CISReport is the result of security cis
+type CISReport struct {
+	Benchmark string         `json:"benchmark"`
+	Results   []CISResult    `json:"results"`
+	Summary   map[string]int `json:"summary"`
+}
+
+// loadCISBenchmark reads the CIS controls from the data directory, falling
+// back to the copy bundled with the binary
+func loadCISBenchmark() (*cisBenchmark, error) {
+	data, _, err := loadDataBundle("cis.yaml")
+	if err != nil {
+		return nil, err
+	}
+	var benchmark cisBenchmark
+	if err := yaml.Unmarshal(data, &benchmark); err != nil {
+		return nil, fmt.Errorf("failed to parse cis.yaml: %w", err)
+	}
+	for _, c := range benchmark.Controls {
+		switch c.Test {
+		case "equals", "not_equals", "contains", "not_contains", "at_least", "set", "unset", "manual":
+		default:
+			return nil, fmt.Errorf("cis.yaml control %s: unknown test %q", c.ID, c.Test)
+		}
+	}
+	return &benchmark, nil
+}
+
+// ruleID is the finding rule ID of the control; controls from another
+// benchmark version carry the version so their numbers do not collide
+func (c cisControl) ruleID() string {
+	if c.Benchmark == "" {
+		return "cis/" + c.ID
+	}
+	fields := strings.Fields(c.Benchmark)
+	return "cis/" + fields[len(fields)-1] + "/" + c.ID
+}
+
+// keys returns the flags or configuration fields the control reads
+func (c cisControl) keys() []string {
+	key := c.Flag
+	if c.Component == "kubelet" {
+		key = c.Field
+	}
+	return strings.Split(key, ",")
+}
+
+// describe renders a flag or field for evidence
+func (c cisControl) describe(key string) string {
+	if c.Component == "kubelet" {
+		return key
+	}
+	return "--" + key
+}
+
+// evaluate applies the control's test to the settings of one component
+// instance and returns whether it passed and what was found
+func (c cisControl) evaluate(settings map[string]string) (bool, string) {
+	keys := c.keys()
+
+	switch c.Test {
+	case "set", "unset":
+		var present, missing []string
+		for _, key := range keys {
+			if settings[key] != "" {
+				present = append(present, c.describe(key))
+			} else {
+				missing = append(missing, c.describe(key))
+			}
+		}
+		if c.Test == "set" {
+			if len(missing) > 0 {
+				return false, strings.Join(missing, ", ") + " not set"
+			}
+			return true, strings.Join(present, ", ") + " set"
+		}
+		if len(present) > 0 {
+			return false, strings.Join(present, ", ") + " set"
+		}
+		return true, strings.Join(missing, ", ") + " not set"
+	}
+
+	key := keys[0]
+	value, ok := settings[key]
+	found := fmt.Sprintf("%s=%s", c.describe(key), value)
+	if !ok {
+		value = c.Default
+		found = fmt.Sprintf("%s not set", c.describe(key))
+		if c.Default != "" {
+			found += fmt.Sprintf(" (default %s)", c.Default)
+		}
+	}
+	list := make(map[string]bool)
+	for _, item := range strings.Split(value, ",") {
+		list[strings.TrimSpace(item)] = true
+	}
+
+	switch c.Test {
+	case "equals":
+		return value == c.Value, found
+	case "not_equals":
+		return value != c.Value, found
+	case "contains":
+		return list[c.Value], found
+	case "not_contains":
+		return !list[c.Value], found
+	case "at_least":
+		n, err := strconv.Atoi(value)
+		want, _ := strconv.Atoi(c.Value)
+		return err == nil && n >= want, found
+	}
+	return false, found
+}
+
+// parseComponentFlags reads --flag=value and --flag value arguments from a
+// container command line; a flag without a value is "true"
+func parseComponentFlags(args []string) map[string]string {
+	flags := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !hasValue {
+			value = "true"
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+				value = args[i+1]
+				i++
+			}
+		}
+		flags[name] = value
+	}
+	return flags
+}
+
+// flattenConfig turns a nested configuration document into dotted keys
+func flattenConfig(prefix string, value interface{}, out map[string]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			name := key
+			if prefix != "" {
+				name = prefix + "." + key
+			}
+			flattenConfig(name, child, out)
+		}
+	case nil:
+	default:
+		out[prefix] = fmt.Sprint(v)
+	}
+}
+
+// controlPlaneSettings returns the flags of every visible control plane
+// static pod by component and node. Managed control planes return none.
+func (k *K8sToolkit) controlPlaneSettings(ctx context.Context) (map[string]map[string]map[string]string, error) {
+	pods, err := k.clientset.CoreV1().Pods("kube-system").List(ctx, metav1.ListOptions{
+		LabelSelector: "component in (" + strings.Join(cisControlPlaneComponents, ",") + ")",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list control plane pods: %w", err)
+	}
+
+	settings := make(map[string]map[string]map[string]string)
+	for _, pod := range pods.Items {
+		component := pod.Labels["component"]
+		var container *corev1.Container
+		for i := range pod.Spec.Containers {
+			if pod.Spec.Containers[i].Name == component || container == nil {
+				container = &pod.Spec.Containers[i]
+			}
+		}
+		if container == nil {
+			continue
+		}
+		if settings[component] == nil {
+			settings[component] = make(map[string]map[string]string)
+		}
+		instance := pod.Spec.NodeName
+		if instance == "" {
+			instance = pod.Name
+		}
+		settings[component][instance] = parseComponentFlags(append(append([]string{}, container.Command...), container.Args...))
+	}
+	return settings, nil
+}
+
+// kubeletSettings reads the running configuration of every kubelet through
+// the API server's node proxy. Nodes whose configz cannot be read are
+// returned as errors per node.
+func (k *K8sToolkit) kubeletSettings(ctx context.Context) (map[string]map[string]string, map[string]string, error) {
+	nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	settings := make(map[string]map[string]string)
+	failed := make(map[string]string)
+	for _, node := range nodes.Items {
+		data, err := k.clientset.CoreV1().RESTClient().Get().
+			Resource("nodes").Name(node.Name).SubResource("proxy").Suffix("configz").
+			DoRaw(ctx)
+		if err != nil {
+			failed[node.Name] = err.Error()
+			continue
+		}
+		var configz struct {
+			KubeletConfig map[string]interface{} `json:"kubeletconfig"`
+		}
+		if err := json.Unmarshal(data, &configz); err != nil {
+			failed[node.Name] = fmt.Sprintf("invalid configz: %v", err)
+			continue
+		}
+		flat := make(map[string]string)
+		flattenConfig("", configz.KubeletConfig, flat)
+		settings[node.Name] = flat
+	}
+	return settings, failed, nil
+}
+
+// probeAnonymousAuth sends an unauthenticated request to the API server. A
+// 401 means anonymous requests are rejected; any other answer means they
+// are authenticated as system:anonymous.
+func (k *K8sToolkit) probeAnonymousAuth(ctx context.Context) (bool, string, error) {
+	clientset, err := kubernetes.NewForConfig(rest.AnonymousClientConfig(k.restConfig))
+	if err != nil {
+		return false, "", err
+	}
+	err = clientset.Discovery().RESTClient().Get().AbsPath("/api").Do(ctx).Error()
+	var status *apierrors.StatusError
+	switch {
+	case err == nil:
+		return true, "unauthenticated GET /api succeeded", nil
+	case apierrors.IsUnauthorized(err):
+		return false, "unauthenticated GET /api was rejected with 401", nil
+	case errors.As(err, &status):
+		return true, fmt.Sprintf("unauthenticated GET /api was answered with %d, so anonymous requests are authenticated", status.ErrStatus.Code), nil
+	}
+	return false, "", err
+}
+
+// RunCISBenchmark evaluates the CIS controls that are visible through the
+// API. Controls of a component that cannot be inspected, such as a managed
+// control plane, are reported as MANUAL.
+func (k *K8sToolkit) RunCISBenchmark(ctx context.Context) (*CISReport, error) {
+	benchmark, err := loadCISBenchmark()
+	if err != nil {
+		return nil, err
+	}
+	controlPlane, err := k.controlPlaneSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	kubelets, kubeletErrors, err := k.kubeletSettings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CISReport{Benchmark: benchmark.Benchmark, Summary: map[string]int{cisPass: 0, cisFail: 0, cisManual: 0}}
+	for _, c := range benchmark.Controls {
+		result := CISResult{
+			Control:     c.ID,
+			RuleID:      c.ruleID(),
+			Benchmark:   c.Benchmark,
+			Title:       c.Title,
+			Component:   c.Component,
+			Severity:    c.Severity,
+			Remediation: c.Remediation,
+		}
+		if result.Severity == "" {
+			result.Severity = "Medium"
+		}
+
+		instances := controlPlane[c.Component]
+		if c.Component == "kubelet" {
+			instances = kubelets
+		}
+		names := make([]string, 0, len(instances))
+		for name := range instances {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		switch {
+		case c.Test == "manual":
+			result.Status = cisManual
+			for _, name := range names {
+				if value, ok := instances[name][c.keys()[0]]; ok {
+					result.Evidence = append(result.Evidence, fmt.Sprintf("%s: %s=%s", name, c.describe(c.keys()[0]), value))
+				}
+			}
+		case len(instances) == 0 && c.ID == "1.2.1" && c.Component == "kube-apiserver":
+			// Managed control planes hide the API server flags, but
+			// anonymous auth can be observed from outside
+			anonymous, evidence, err := k.probeAnonymousAuth(ctx)
+			switch {
+			case err != nil:
+				result.Status = cisManual
+				result.Evidence = []string{fmt.Sprintf("kube-apiserver pods not visible and the anonymous probe failed: %v", err)}
+			case anonymous:
+				result.Status = cisFail
+				result.Evidence = []string{evidence}
+			default:
+				result.Status = cisPass
+				result.Evidence = []string{evidence}
+			}
+		case len(instances) == 0:
+			result.Status = cisManual
+			result.Evidence = []string{fmt.Sprintf("no %s instance visible through the API (managed control plane?)", c.Component)}
+		default:
+			result.Status = cisPass
+			for _, name := range names {
+				passed, found := c.evaluate(instances[name])
+				if !passed {
+					result.Status = cisFail
+				}
+				result.Evidence = append(result.Evidence, fmt.Sprintf("%s: %s", name, found))
+			}
+		}
+		if c.Component == "kubelet" && c.Test != "manual" {
+			for _, node := range sortedKeys(kubeletErrors) {
+				result.Evidence = append(result.Evidence, fmt.Sprintf("%s: configz not readable: %s", node, kubeletErrors[node]))
+			}
+		}
+
+		report.Summary[result.Status]++
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// sortedKeys returns the keys of m in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// cisFindings converts failed controls into findings for compliance reports,
+// SARIF and --fail-on
+func cisFindings(report *CISReport) []Finding {
+	var findings []Finding
+	for _, r := range report.Results {
+		if r.Status != cisFail {
+			continue
+		}
+		findings = append(findings, Finding{
+			Source:      "security-cis",
+			RuleID:      r.RuleID,
+			Severity:    r.Severity,
+			Resource:    r.Component,
+			Message:     fmt.Sprintf("CIS %s %s: %s", r.Control, r.Title, strings.Join(r.Evidence, "; ")),
+			Remediation: r.Remediation,
+			Controls:    []string{"CIS-" + r.Control},
+		})
+	}
+	return findings
+}
+
+// cisAuditFindings adapts RunCISBenchmark to a compliance finding source
+func (k *K8sToolkit) cisAuditFindings(ctx context.Context) ([]Finding, error) {
+	report, err := k.RunCISBenchmark(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return cisFindings(report), nil
+}
+
+// PrintCISReport prints each control with its status and evidence
+func (k *K8sToolkit) PrintCISReport(report *CISReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+	if k.output == "sarif" {
+		printSARIF("CIS Kubernetes Benchmark", cisFindings(report))
+		return
+	}
+
+	fmt.Printf("%s\n", report.Benchmark)
+	fmt.Printf("%-8s %-7s %-24s %-9s %s\n", "CONTROL", "STATUS", "COMPONENT", "SEVERITY", "TITLE")
+	for _, r := range report.Results {
+		title := r.Title
+		if r.Benchmark != "" {
+			title += " [" + r.Benchmark + "]"
+		}
+		fmt.Printf("%-8s %-7s %-24s %-9s %s\n", r.Control, r.Status, r.Component, r.Severity, title)
+		if r.Status == cisPass {
+			continue
+		}
+		for _, evidence := range r.Evidence {
+			fmt.Printf("%43s %s\n", "", evidence)
+		}
+		if r.Status == cisFail && r.Remediation != "" {
+			fmt.Printf("%43s Remediation: %s\n", "", r.Remediation)
+		}
+	}
+	fmt.Printf("\n%d passed, %d failed, %d manual\n", report.Summary[cisPass], report.Summary[cisFail], report.Summary[cisManual])
+}
+
+// createCISCmd creates the security cis command
+func createCISCmd() *cobra.Command {
+	var failOn string
+
+	cisCmd := &cobra.Command{
+		Use:   "cis",
+		Short: "Check the CIS Kubernetes Benchmark controls visible through the API",
+		Long: `Runs the subset of CIS Kubernetes Benchmark controls that can be checked through the API, in
+the manner of kube-bench: API server, controller manager, scheduler and etcd flags from the
+static pods in kube-system, and kubelet settings (anonymous auth, authorization mode,
+read-only port) from each node's configz. Every control is reported as PASS, FAIL or MANUAL
+with its control number. On managed control planes the static pods are not visible; their
+controls are MANUAL, except anonymous auth, which is probed with an unauthenticated request.
+The controls are read from the cis.yaml data bundle and can be refreshed with data update.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			report, err := toolkit.RunCISBenchmark(context.Background())
+			if err != nil {
+				logger.Fatalf("Failed to run CIS benchmark: %v", err)
+			}
+
+			toolkit.PrintCISReport(report)
+			exitOnFindings(cisFindings(report), failOn)
+		},
+	}
+	cisCmd.Flags().StringVar(&failOn, "fail-on", "none", "Exit non-zero on failed controls at or above this severity (Low|Medium|High|Critical|none)")
+	return cisCmd
+}
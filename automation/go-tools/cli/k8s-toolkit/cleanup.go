@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CleanupCandidate is a resource that can be safely removed
+type CleanupCandidate struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+}
+
+// FindCleanupCandidates finds finished Jobs older than maxAge, Evicted and
+// Succeeded pods, ConfigMaps and Secrets not referenced by any pod, and Released PVs
+func (k *K8sToolkit) FindCleanupCandidates(ctx context.Context, maxAge time.Duration) ([]CleanupCandidate, error) {
+	var candidates []CleanupCandidate
+	cutoff := time.Now().Add(-maxAge)
+
+	jobs, err := k.clientset.BatchV1().Jobs(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	for _, job := range inScopeItems(k, jobs.Items) {
+		if state, finishedAt, ok := jobFinished(&job); ok && finishedAt.Before(cutoff) {
+			candidates = append(candidates, CleanupCandidate{
+				Kind:      "Job",
+				Namespace: job.Namespace,
+				Name:      job.Name,
+				Reason:    fmt.Sprintf("%s %s ago", state, time.Since(finishedAt).Round(time.Hour)),
+			})
+		}
+	}
+
+	referencedConfigMaps := make(map[string]bool)
+	referencedSecrets := make(map[string]bool)
+	err = k.eachPod(ctx, k.namespace, metav1.ListOptions{}, func(pod *corev1.Pod) error {
+		collectPodReferences(pod, referencedConfigMaps, referencedSecrets)
+
+		switch {
+		case pod.Status.Phase == corev1.PodFailed && pod.Status.Reason == "Evicted":
+			candidates = append(candidates, CleanupCandidate{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Reason: "Evicted"})
+		case pod.Status.Phase == corev1.PodSucceeded && metav1.GetControllerOf(pod) == nil:
+			candidates = append(candidates, CleanupCandidate{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Reason: "Succeeded"})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	configMaps, err := k.clientset.CoreV1().ConfigMaps(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configmaps: %w", err)
+	}
+	for _, cm := range inScopeItems(k, configMaps.Items) {
+		if cm.Name == "kube-root-ca.crt" || referencedConfigMaps[cm.Namespace+"/"+cm.Name] {
+			continue
+		}
+		candidates = append(candidates, CleanupCandidate{Kind: "ConfigMap", Namespace: cm.Namespace, Name: cm.Name, Reason: "not referenced by any pod"})
+	}
+
+	secrets, err := k.clientset.CoreV1().Secrets(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	for _, secret := range inScopeItems(k, secrets.Items) {
+		// Service account tokens, Helm releases and TLS certificates are consumed outside pod specs
+		switch secret.Type {
+		case corev1.SecretTypeServiceAccountToken, corev1.SecretTypeTLS, "helm.sh/release.v1":
+			continue
+		}
+		if referencedSecrets[secret.Namespace+"/"+secret.Name] {
+			continue
+		}
+		candidates = append(candidates, CleanupCandidate{Kind: "Secret", Namespace: secret.Namespace, Name: secret.Name, Reason: "not referenced by any pod"})
+	}
+
+	pvs, err := k.clientset.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVs: %w", err)
+	}
+	for _, pv := range pvs.Items {
+		if pv.Status.Phase == corev1.VolumeReleased {
+			candidates = append(candidates, CleanupCandidate{Kind: "PersistentVolume", Name: pv.Name, Reason: "Released"})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Namespace < candidates[j].Namespace
+	})
+
+	return candidates, nil
+}
+
+// jobFinished reports whether a job has completed or failed and when
+func jobFinished(job *batchv1.Job) (string, time.Time, bool) {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case batchv1.JobComplete:
+			return "completed", condition.LastTransitionTime.Time, true
+		case batchv1.JobFailed:
+			return "failed", condition.LastTransitionTime.Time, true
+		}
+	}
+	return "", time.Time{}, false
+}
+
+// collectPodReferences records the ConfigMaps and Secrets a pod consumes
+func collectPodReferences(pod *corev1.Pod, configMaps, secrets map[string]bool) {
+	ref := func(name string) string { return pod.Namespace + "/" + name }
+
+	for _, secret := range pod.Spec.ImagePullSecrets {
+		secrets[ref(secret.Name)] = true
+	}
+
+	for _, volume := range pod.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			configMaps[ref(volume.ConfigMap.Name)] = true
+		}
+		if volume.Secret != nil {
+			secrets[ref(volume.Secret.SecretName)] = true
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					configMaps[ref(source.ConfigMap.Name)] = true
+				}
+				if source.Secret != nil {
+					secrets[ref(source.Secret.Name)] = true
+				}
+			}
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+	for _, container := range containers {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				configMaps[ref(envFrom.ConfigMapRef.Name)] = true
+			}
+			if envFrom.SecretRef != nil {
+				secrets[ref(envFrom.SecretRef.Name)] = true
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				configMaps[ref(env.ValueFrom.ConfigMapKeyRef.Name)] = true
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secrets[ref(env.ValueFrom.SecretKeyRef.Name)] = true
+			}
+		}
+	}
+}
+
+// DeleteCleanupCandidate deletes a single candidate
+func (k *K8sToolkit) DeleteCleanupCandidate(ctx context.Context, c CleanupCandidate) error {
+	background := metav1.DeletePropagationBackground
+	opts := metav1.DeleteOptions{PropagationPolicy: &background}
+
+	switch c.Kind {
+	case "Job":
+		return k.clientset.BatchV1().Jobs(c.Namespace).Delete(ctx, c.Name, opts)
+	case "Pod":
+		return k.clientset.CoreV1().Pods(c.Namespace).Delete(ctx, c.Name, opts)
+	case "ConfigMap":
+		return k.clientset.CoreV1().ConfigMaps(c.Namespace).Delete(ctx, c.Name, opts)
+	case "Secret":
+		return k.clientset.CoreV1().Secrets(c.Namespace).Delete(ctx, c.Name, opts)
+	case "PersistentVolume":
+		return k.clientset.CoreV1().PersistentVolumes().Delete(ctx, c.Name, opts)
+	default:
+		return fmt.Errorf("unsupported kind %s", c.Kind)
+	}
+}
+
+// PrintCleanupCandidates prints candidates grouped by namespace
+func (k *K8sToolkit) PrintCleanupCandidates(candidates []CleanupCandidate) {
+	if k.filtered(candidates) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(candidates)
+		return
+	}
+
+	if len(candidates) == 0 {
+		fmt.Println("Nothing to clean up")
+		return
+	}
+
+	currentNamespace := "\x00"
+	for _, c := range candidates {
+		if c.Namespace != currentNamespace {
+			currentNamespace = c.Namespace
+			if currentNamespace == "" {
+				fmt.Printf("\nCluster-scoped:\n")
+			} else {
+				fmt.Printf("\nNamespace %s:\n", currentNamespace)
+			}
+		}
+		fmt.Printf("  %-18s %-50s %s\n", c.Kind, c.Name, c.Reason)
+	}
+	fmt.Println()
+}
+
+// createCleanupCmd creates the cleanup command
+func createCleanupCmd() *cobra.Command {
+	var days int
+	var apply bool
+
+	cleanupCmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Find and remove stale resources",
+		Long: `Finds completed or failed Jobs older than --days, Evicted and Succeeded pods, ConfigMaps and Secrets
+not referenced by any pod, and Released PVs. Runs as a dry run unless --apply is given.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx := context.Background()
+			candidates, err := toolkit.FindCleanupCandidates(ctx, time.Duration(days)*24*time.Hour)
+			if err != nil {
+				logger.Fatalf("Failed to find cleanup candidates: %v", err)
+			}
+
+			toolkit.PrintCleanupCandidates(candidates)
+
+			if !apply {
+				if len(candidates) > 0 && toolkit.output != "json" {
+					fmt.Printf("Dry run: %d resources would be deleted. Re-run with --apply to delete them.\n", len(candidates))
+				}
+				return
+			}
+
+			if len(candidates) == 0 {
+				return
+			}
+			m, err := beginMutation("cleanup", fmt.Sprintf("About to delete %d resources.", len(candidates)))
+			if err != nil {
+				logger.Fatalf("Cleanup aborted: %v", err)
+			}
+
+			deleted := 0
+			for _, c := range candidates {
+				err := toolkit.DeleteCleanupCandidate(ctx, c)
+				m.record("delete", fmt.Sprintf("%s %s/%s", c.Kind, c.Namespace, c.Name), err)
+				if err != nil {
+					logger.Errorf("Failed to delete %s %s/%s: %v", c.Kind, c.Namespace, c.Name, err)
+					continue
+				}
+				deleted++
+			}
+			fmt.Printf("Deleted %d of %d resources\n", deleted, len(candidates))
+		},
+	}
+
+	cleanupCmd.Flags().IntVar(&days, "days", 7, "Minimum age in days of finished Jobs to remove")
+	cleanupCmd.Flags().BoolVar(&apply, "apply", false, "Delete the resources instead of only listing them")
+
+	return cleanupCmd
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// compareKinds are the resource kinds compare understands
+var compareKinds = []string{"deployments", "statefulsets", "daemonsets", "configmaps", "secrets"}
+
+// ResourceDivergence is one field of a resource that differs between two
+// clusters. An empty A or B means the field is absent in that cluster.
+type ResourceDivergence struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Field     string `json:"field"`
+	A         string `json:"a"`
+	B         string `json:"b"`
+}
+
+// ClusterComparison lists how the selected resources of two clusters differ
+type ClusterComparison struct {
+	ContextA    string               `json:"context_a"`
+	ContextB    string               `json:"context_b"`
+	Kinds       []string             `json:"kinds"`
+	Divergences []ResourceDivergence `json:"divergences"`
+	OnlyA       []string             `json:"only_a"`
+	OnlyB       []string             `json:"only_b"`
+	Identical   int                  `json:"identical"`
+}
+
+// comparedResource is the comparable state of one resource: field names
+// such as replicas, image/<container> or config mapped to their values
+type comparedResource struct {
+	kind, namespace, name string
+	fields                map[string]string
+}
+
+func (r comparedResource) key() string {
+	return objectRef{Kind: r.kind, Namespace: r.namespace, Name: r.name}.String()
+}
+
+// dataHash returns a short hash of the keys and values of a ConfigMap or
+// Secret, so contents can be compared without printing them
+func dataHash(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(h, "%s=%d:", key, len(data[key]))
+		h.Write(data[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// containerResources formats the requests and limits of a container
+func containerResources(requirements corev1.ResourceRequirements) string {
+	format := func(list corev1.ResourceList) string {
+		var parts []string
+		for name, quantity := range list {
+			parts = append(parts, fmt.Sprintf("%s=%s", name, quantity.String()))
+		}
+		sort.Strings(parts)
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprintf("requests %s; limits %s", format(requirements.Requests), format(requirements.Limits))
+}
+
+// podTemplateFields returns the images and resources of every container and
+// a hash of the ConfigMaps and Secrets the pod template references, so a
+// workload shows which configuration differs even when its spec does not
+func podTemplateFields(spec corev1.PodSpec, namespace string, configHashes map[string]string) map[string]string {
+	fields := make(map[string]string)
+	containers := append(append([]corev1.Container(nil), spec.InitContainers...), spec.Containers...)
+	refs := make(map[string]bool)
+	for _, container := range containers {
+		fields["image/"+container.Name] = container.Image
+		fields["resources/"+container.Name] = containerResources(container.Resources)
+		for _, source := range container.EnvFrom {
+			if source.ConfigMapRef != nil {
+				refs["ConfigMap/"+namespace+"/"+source.ConfigMapRef.Name] = true
+			}
+			if source.SecretRef != nil {
+				refs["Secret/"+namespace+"/"+source.SecretRef.Name] = true
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+				refs["ConfigMap/"+namespace+"/"+ref.Name] = true
+			}
+			if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+				refs["Secret/"+namespace+"/"+ref.Name] = true
+			}
+		}
+	}
+	for _, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			refs["ConfigMap/"+namespace+"/"+volume.ConfigMap.Name] = true
+		}
+		if volume.Secret != nil {
+			refs["Secret/"+namespace+"/"+volume.Secret.SecretName] = true
+		}
+	}
+
+	if len(refs) > 0 {
+		names := make([]string, 0, len(refs))
+		for ref := range refs {
+			names = append(names, ref)
+		}
+		sort.Strings(names)
+		h := sha256.New()
+		for _, ref := range names {
+			hash := configHashes[ref]
+			if hash == "" {
+				hash = "missing"
+			}
+			fmt.Fprintf(h, "%s=%s\n", ref, hash)
+		}
+		fields["config"] = hex.EncodeToString(h.Sum(nil))[:12]
+	}
+	return fields
+}
+
+// collectComparedResources reads the selected kinds from the toolkit's
+// cluster, in its namespace or all namespaces
+func (k *K8sToolkit) collectComparedResources(ctx context.Context, kinds []string) ([]comparedResource, error) {
+	wanted := make(map[string]bool)
+	for _, kind := range kinds {
+		wanted[kind] = true
+	}
+	opts := k.listOptions(metav1.ListOptions{})
+	var resources []comparedResource
+
+	// ConfigMaps and Secrets are always read since workloads hash the ones
+	// they reference
+	configHashes := make(map[string]string)
+	configMaps, err := k.clientset.CoreV1().ConfigMaps(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list configmaps: %w", err)
+	}
+	for _, cm := range inScopeItems(k, configMaps.Items) {
+		// The cluster CA differs in every cluster by design
+		if cm.Name == "kube-root-ca.crt" {
+			continue
+		}
+		data := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+		for key, value := range cm.Data {
+			data[key] = []byte(value)
+		}
+		for key, value := range cm.BinaryData {
+			data[key] = value
+		}
+		r := comparedResource{kind: "ConfigMap", namespace: cm.Namespace, name: cm.Name, fields: map[string]string{"data": dataHash(data)}}
+		configHashes[r.key()] = r.fields["data"]
+		if wanted["configmaps"] {
+			resources = append(resources, r)
+		}
+	}
+	secrets, err := k.clientset.CoreV1().Secrets(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+	for _, secret := range inScopeItems(k, secrets.Items) {
+		// Tokens and Helm release records are unique to each cluster
+		if secret.Type == corev1.SecretTypeServiceAccountToken || secret.Type == "helm.sh/release.v1" {
+			continue
+		}
+		r := comparedResource{kind: "Secret", namespace: secret.Namespace, name: secret.Name, fields: map[string]string{"data": dataHash(secret.Data)}}
+		configHashes[r.key()] = r.fields["data"]
+		if wanted["secrets"] {
+			resources = append(resources, r)
+		}
+	}
+
+	if wanted["deployments"] {
+		deployments, err := k.clientset.AppsV1().Deployments(k.namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deployments: %w", err)
+		}
+		for _, d := range inScopeItems(k, deployments.Items) {
+			fields := podTemplateFields(d.Spec.Template.Spec, d.Namespace, configHashes)
+			if d.Spec.Replicas != nil {
+				fields["replicas"] = fmt.Sprint(*d.Spec.Replicas)
+			}
+			resources = append(resources, comparedResource{kind: "Deployment", namespace: d.Namespace, name: d.Name, fields: fields})
+		}
+	}
+	if wanted["statefulsets"] {
+		statefulSets, err := k.clientset.AppsV1().StatefulSets(k.namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+		}
+		for _, s := range inScopeItems(k, statefulSets.Items) {
+			fields := podTemplateFields(s.Spec.Template.Spec, s.Namespace, configHashes)
+			if s.Spec.Replicas != nil {
+				fields["replicas"] = fmt.Sprint(*s.Spec.Replicas)
+			}
+			resources = append(resources, comparedResource{kind: "StatefulSet", namespace: s.Namespace, name: s.Name, fields: fields})
+		}
+	}
+	if wanted["daemonsets"] {
+		daemonSets, err := k.clientset.AppsV1().DaemonSets(k.namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list daemonsets: %w", err)
+		}
+		// The number of pods follows the number of nodes, so it is not compared
+		for _, d := range inScopeItems(k, daemonSets.Items) {
+			fields := podTemplateFields(d.Spec.Template.Spec, d.Namespace, configHashes)
+			resources = append(resources, comparedResource{kind: "DaemonSet", namespace: d.Namespace, name: d.Name, fields: fields})
+		}
+	}
+	return resources, nil
+}
+
+// CompareClusters reads the selected kinds from both toolkits concurrently
+// and reports the resources present in only one cluster and every field
+// that differs for resources present in both
+func CompareClusters(ctx context.Context, a, b *K8sToolkit, kinds []string) (*ClusterComparison, error) {
+	var wg sync.WaitGroup
+	var resources [2][]comparedResource
+	var errs [2]error
+	for i, toolkit := range []*K8sToolkit{a, b} {
+		wg.Add(1)
+		go func(i int, toolkit *K8sToolkit) {
+			defer wg.Done()
+			resources[i], errs[i] = toolkit.collectComparedResources(ctx, kinds)
+		}(i, toolkit)
+	}
+	wg.Wait()
+	for i, toolkit := range []*K8sToolkit{a, b} {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", toolkit.contextName, errs[i])
+		}
+	}
+
+	comparison := &ClusterComparison{ContextA: a.contextName, ContextB: b.contextName, Kinds: kinds}
+	inB := make(map[string]comparedResource, len(resources[1]))
+	for _, r := range resources[1] {
+		inB[r.key()] = r
+	}
+	seen := make(map[string]bool, len(resources[0]))
+	for _, ra := range resources[0] {
+		key := ra.key()
+		seen[key] = true
+		rb, ok := inB[key]
+		if !ok {
+			comparison.OnlyA = append(comparison.OnlyA, key)
+			continue
+		}
+
+		fields := make(map[string]bool)
+		for field := range ra.fields {
+			fields[field] = true
+		}
+		for field := range rb.fields {
+			fields[field] = true
+		}
+		identical := true
+		for field := range fields {
+			if ra.fields[field] != rb.fields[field] {
+				identical = false
+				comparison.Divergences = append(comparison.Divergences, ResourceDivergence{
+					Kind: ra.kind, Namespace: ra.namespace, Name: ra.name,
+					Field: field, A: ra.fields[field], B: rb.fields[field],
+				})
+			}
+		}
+		if identical {
+			comparison.Identical++
+		}
+	}
+	for _, rb := range resources[1] {
+		if !seen[rb.key()] {
+			comparison.OnlyB = append(comparison.OnlyB, rb.key())
+		}
+	}
+
+	sort.Slice(comparison.Divergences, func(i, j int) bool {
+		di, dj := comparison.Divergences[i], comparison.Divergences[j]
+		ki := objectRef{Kind: di.Kind, Namespace: di.Namespace, Name: di.Name}.String()
+		kj := objectRef{Kind: dj.Kind, Namespace: dj.Namespace, Name: dj.Name}.String()
+		if ki != kj {
+			return ki < kj
+		}
+		return di.Field < dj.Field
+	})
+	sort.Strings(comparison.OnlyA)
+	sort.Strings(comparison.OnlyB)
+	return comparison, nil
+}
+
+// HasDivergence reports whether the clusters differ at all
+func (c *ClusterComparison) HasDivergence() bool {
+	return len(c.Divergences) > 0 || len(c.OnlyA) > 0 || len(c.OnlyB) > 0
+}
+
+// PrintClusterComparison prints the divergences grouped by resource
+func (k *K8sToolkit) PrintClusterComparison(comparison *ClusterComparison) {
+	if k.filtered(comparison) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(comparison)
+		return
+	}
+
+	fmt.Printf("\n🔀 %s vs %s (%s)\n", comparison.ContextA, comparison.ContextB, strings.Join(comparison.Kinds, ", "))
+	fmt.Println("==================================================")
+	last := ""
+	for _, d := range comparison.Divergences {
+		key := objectRef{Kind: d.Kind, Namespace: d.Namespace, Name: d.Name}.String()
+		if key != last {
+			fmt.Printf("\n%s\n", key)
+			last = key
+		}
+		valueA, valueB := d.A, d.B
+		if valueA == "" {
+			valueA = "-"
+		}
+		if valueB == "" {
+			valueB = "-"
+		}
+		fmt.Printf("  %-30s %s: %s\n  %-30s %s: %s\n", d.Field, comparison.ContextA, valueA, "", comparison.ContextB, valueB)
+	}
+	for _, side := range []struct {
+		context string
+		keys    []string
+	}{{comparison.ContextA, comparison.OnlyA}, {comparison.ContextB, comparison.OnlyB}} {
+		if len(side.keys) == 0 {
+			continue
+		}
+		fmt.Printf("\nOnly in %s:\n", side.context)
+		for _, key := range side.keys {
+			fmt.Printf("  %s\n", key)
+		}
+	}
+
+	fmt.Printf("\n%d fields differ, %d resources only in %s, %d only in %s, %d identical\n",
+		len(comparison.Divergences), len(comparison.OnlyA), comparison.ContextA,
+		len(comparison.OnlyB), comparison.ContextB, comparison.Identical)
+}
+
+// createCompareCmd creates the compare command
+func createCompareCmd() *cobra.Command {
+	var contextA, contextB string
+	var kinds []string
+	var failOnDiff bool
+
+	compareCmd := &cobra.Command{
+		Use:   "compare",
+		Short: "Compare resources between two clusters",
+		Long: `Reads the selected resource kinds from two kubeconfig contexts and reports resources present in
+only one cluster and, for those in both, every field that differs: container images, replica
+counts, requests and limits, and a hash of the ConfigMaps and Secrets each workload references.
+ConfigMaps and Secrets themselves are compared by a hash of their data, so no values are printed.
+Uses --namespace, --selector and --exclude-namespaces like the other commands. The cluster CA
+ConfigMap, service account tokens and Helm release Secrets differ by design and are skipped.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if contextA == "" || contextB == "" {
+				logger.Fatalf("Both --context-a and --context-b are required")
+			}
+			for _, kind := range kinds {
+				known := false
+				for _, k := range compareKinds {
+					known = known || kind == k
+				}
+				if !known {
+					logger.Fatalf("Unknown kind %q (use %s)", kind, strings.Join(compareKinds, ", "))
+				}
+			}
+
+			a, err := NewK8sToolkitForContext(contextA)
+			if err != nil {
+				logger.Fatalf("Failed to connect to %s: %v", contextA, err)
+			}
+			b, err := NewK8sToolkitForContext(contextB)
+			if err != nil {
+				logger.Fatalf("Failed to connect to %s: %v", contextB, err)
+			}
+
+			comparison, err := CompareClusters(context.Background(), a, b, kinds)
+			if err != nil {
+				logger.Fatalf("Failed to compare clusters: %v", err)
+			}
+
+			a.PrintClusterComparison(comparison)
+			if failOnDiff && comparison.HasDivergence() {
+				os.Exit(1)
+			}
+		},
+	}
+
+	compareCmd.Flags().StringVar(&contextA, "context-a", "", "First kubeconfig context")
+	compareCmd.Flags().StringVar(&contextB, "context-b", "", "Second kubeconfig context")
+	compareCmd.Flags().StringSliceVar(&kinds, "kinds", []string{"deployments", "statefulsets", "daemonsets"}, "Resource kinds to compare: "+strings.Join(compareKinds, ", "))
+	compareCmd.Flags().BoolVar(&failOnDiff, "fail-on-diff", false, "Exit non-zero when the clusters differ")
+
+	return compareCmd
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Finding is a single issue reported by an audit
+type Finding struct {
+	Source      string   `json:"source"`
+	RuleID      string   `json:"rule_id"`
+	Severity    string   `json:"severity"`
+	Namespace   string   `json:"namespace,omitempty"`
+	Resource    string   `json:"resource"`
+	Message     string   `json:"message"`
+	Remediation string   `json:"remediation,omitempty"`
+	Controls    []string `json:"controls,omitempty"`
+}
+
+// findingSource is an audit that contributes findings to a compliance report
+type findingSource struct {
+	name string
+	run  func(ctx context.Context) ([]Finding, error)
+}
+
+// ControlMapping maps rule IDs to compliance control IDs. A key ending in
+// "/*" matches every rule ID with that prefix.
+type ControlMapping struct {
+	Controls map[string][]string `yaml:"controls"`
+}
+
+// EvidenceBundle is the document written for auditors
+type EvidenceBundle struct {
+	GeneratedAt time.Time           `json:"generated_at"`
+	Sources     map[string]string   `json:"sources"`
+	Partial     bool                `json:"partial"`
+	Errors      []CheckError        `json:"errors,omitempty"`
+	Findings    []Finding           `json:"findings"`
+	ByControl   map[string][]string `json:"by_control"`
+}
+
+// FindingSourceOptions enables the finding sources that reach beyond the
+// cluster API. Each command opts in with its own flags, since pulling every
+// image for a Trivy scan or contacting every registry is too slow and noisy
+// to happen by default.
+type FindingSourceOptions struct {
+	// ScanImages adds the security-vulns source, a Trivy scan of every image
+	ScanImages bool
+	// ProbeRegistries lets security-secrets contact the registries of
+	// docker-registry secrets
+	ProbeRegistries bool
+	// AWS adds the security-iam and security-s3 audits of the AWS account of
+	// the standard AWS environment. It has no effect in offline mode.
+	AWS bool
+}
+
+// addFindingSourceFlags registers the image scan and registry probe opt-ins
+// of opts on cmd. --aws is registered by the commands the account-wide AWS
+// findings make sense for.
+func addFindingSourceFlags(cmd *cobra.Command, opts *FindingSourceOptions) {
+	cmd.Flags().BoolVar(&opts.ScanImages, "scan-images", false, "Scan every image with Trivy (security-vulns source)")
+	cmd.Flags().BoolVar(&opts.ProbeRegistries, "probe-registries", false, "Check docker-registry secrets against their registries")
+}
+
+// findingSources returns the audits run by the compliance report and the
+// other finding consumers. Sources not enabled in opts are left out.
+func (k *K8sToolkit) findingSources(opts FindingSourceOptions) []findingSource {
+	sources := []findingSource{
+		{"health", k.healthFindings},
+		{"security-pods", func(ctx context.Context) ([]Finding, error) { return k.AuditPodSecurity(ctx, "restricted") }},
+		{"security-images", k.imageFindings},
+		{"security-netpol", k.netpolFindings},
+		{"security-secrets", func(ctx context.Context) ([]Finding, error) {
+			return k.AuditSecrets(ctx, SecretsAuditOptions{ProbeRegistries: opts.ProbeRegistries})
+		}},
+		{"security-serviceaccounts", k.AuditServiceAccounts},
+		{"security-rbac", k.AuditRBAC},
+		{"security-cis", k.cisAuditFindings},
+		{"reachability", k.reachabilityFindings},
+		{"availability", k.CheckAvailability},
+		{"slo", k.sloFindings},
+	}
+	if opts.ScanImages {
+		sources = append(sources, findingSource{"security-vulns", k.vulnFindings})
+	}
+	if opts.AWS && !offline() {
+		sources = append(sources, findingSource{"security-iam", AuditIAM}, findingSource{"security-s3", AuditS3})
+	}
+	return sources
+}
+
+// healthFindings converts non-healthy health checks into findings
+func (k *K8sToolkit) healthFindings(ctx context.Context) ([]Finding, error) {
+	health, err := k.RunHealthCheck(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, check := range health.Checks {
+		if check.Status == "Healthy" {
+			continue
+		}
+		findings = append(findings, Finding{
+			Source:   "health",
+			RuleID:   "health/" + strings.ReplaceAll(strings.ToLower(check.Component), " ", "-"),
+			Severity: check.Status,
+			Resource: check.Component,
+			Message:  check.Message,
+		})
+	}
+	return findings, nil
+}
+
+// loadControlMapping reads a rule ID to control ID mapping file
+func loadControlMapping(path string) (*ControlMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var mapping ControlMapping
+	if err := yaml.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &mapping, nil
+}
+
+// controlsFor returns the controls mapped to a rule ID
+func (m *ControlMapping) controlsFor(ruleID string) []string {
+	var controls []string
+	for pattern, ids := range m.Controls {
+		if pattern == ruleID || (strings.HasSuffix(pattern, "/*") && strings.HasPrefix(ruleID, strings.TrimSuffix(pattern, "*"))) {
+			controls = append(controls, ids...)
+		}
+	}
+	sort.Strings(controls)
+	return controls
+}
+
+// BuildEvidenceBundle runs every enabled finding source and maps the
+// results to controls. A failing source is recorded in the bundle instead of
+// aborting the run, and sources left out are listed as skipped so auditors
+// can tell them from clean results.
+func (k *K8sToolkit) BuildEvidenceBundle(ctx context.Context, mapping *ControlMapping, opts FindingSourceOptions) *EvidenceBundle {
+	bundle := &EvidenceBundle{
+		GeneratedAt: time.Now(),
+		Sources:     make(map[string]string),
+		ByControl:   make(map[string][]string),
+	}
+
+	if !opts.ScanImages {
+		bundle.Sources["security-vulns"] = "skipped (enable with --scan-images)"
+	}
+	if !opts.AWS || offline() {
+		bundle.Sources["security-iam"] = "skipped (enable with --aws, not available offline)"
+		bundle.Sources["security-s3"] = "skipped (enable with --aws, not available offline)"
+	}
+
+	for _, source := range k.findingSources(opts) {
+		findings, err := source.run(ctx)
+		if err != nil {
+			category := errorCategory(err)
+			bundle.Sources[source.name] = fmt.Sprintf("error (%s): %v", category, err)
+			bundle.Errors = append(bundle.Errors, CheckError{Component: source.name, Category: category, Message: err.Error()})
+			bundle.Partial = true
+			continue
+		}
+		bundle.Sources[source.name] = fmt.Sprintf("ok (%d findings)", len(findings))
+
+		for _, finding := range findings {
+			finding.Controls = mapping.controlsFor(finding.RuleID)
+			for _, control := range finding.Controls {
+				bundle.ByControl[control] = append(bundle.ByControl[control], finding.Resource+": "+finding.Message)
+			}
+			bundle.Findings = append(bundle.Findings, finding)
+		}
+	}
+
+	return bundle
+}
+
+// WriteEvidenceBundle writes the bundle as JSON and HTML into a dated directory
+func WriteEvidenceBundle(bundle *EvidenceBundle, outputDir string) (string, error) {
+	dir := filepath.Join(outputDir, "evidence-"+bundle.GeneratedAt.Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	jsonData, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "findings.json"), jsonData, 0644); err != nil {
+		return "", fmt.Errorf("failed to write findings.json: %w", err)
+	}
+
+	htmlFile, err := os.Create(filepath.Join(dir, "report.html"))
+	if err != nil {
+		return "", fmt.Errorf("failed to create report.html: %w", err)
+	}
+	defer htmlFile.Close()
+	if err := renderHTML(htmlFile, "evidence.html.tmpl", bundle); err != nil {
+		return "", fmt.Errorf("failed to render report.html: %w", err)
+	}
+
+	return dir, nil
+}
+
+// createComplianceCmd creates the compliance command
+func createComplianceCmd() *cobra.Command {
+	complianceCmd := &cobra.Command{
+		Use:   "compliance",
+		Short: "Compliance evidence tooling",
+	}
+
+	var mappingFile string
+	var outputDir string
+	var sourceOpts FindingSourceOptions
+
+	reportCmd := &cobra.Command{
+		Use:   "report",
+		Short: "Generate a dated compliance evidence bundle",
+		Long: `Runs the toolkit's audits, maps findings to compliance control IDs from a mapping file and writes a dated evidence bundle (JSON and HTML).
+
+The cluster audits, including RBAC, always run. IAM and S3 in the current AWS
+account are audited unless --aws=false is given. The Trivy image scan and the
+registry probes of docker-registry secrets are opt-in with --scan-images and
+--probe-registries.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			mapping, err := loadControlMapping(mappingFile)
+			if err != nil {
+				logger.Fatalf("Failed to load control mapping: %v", err)
+			}
+
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			bundle := toolkit.BuildEvidenceBundle(context.Background(), mapping, sourceOpts)
+			dir, err := WriteEvidenceBundle(bundle, outputDir)
+			if err != nil {
+				logger.Fatalf("Failed to write evidence bundle: %v", err)
+			}
+
+			fmt.Printf("Evidence bundle written to %s (%d findings)\n", dir, len(bundle.Findings))
+		},
+	}
+	reportCmd.Flags().StringVar(&mappingFile, "mapping", "controls.yaml", "Rule ID to control ID mapping file")
+	reportCmd.Flags().StringVar(&outputDir, "output-dir", ".", "Directory to write the evidence bundle into")
+	addFindingSourceFlags(reportCmd, &sourceOpts)
+	reportCmd.Flags().BoolVar(&sourceOpts.AWS, "aws", true, "Audit IAM and S3 in the current AWS account (security-iam and security-s3 sources)")
+
+	complianceCmd.AddCommand(reportCmd)
+	return complianceCmd
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devops-excellence/automation/go-tools/pkg/secrets"
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// secretResolver resolves credential settings written as secret references
+// such as "env:JIRA_TOKEN" or "vault:secret/data/ci#token". Plain values are
+// used as they are.
+var secretResolver = secrets.NewDefaultResolver()
+
+// secretSetting returns the setting at key with a secret reference resolved
+func secretSetting(ctx context.Context, key string) (string, error) {
+	value, err := secretResolver.Resolve(ctx, viper.GetString(key))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// setConfigDefaults registers default values for settings that can be
+// overridden from a config file or a config repository
+func setConfigDefaults() {
+	viper.SetDefault("thresholds.cpu_percent", 80.0)
+	viper.SetDefault("thresholds.memory_percent", 80.0)
+	viper.SetDefault("health.timeout", 60*time.Second)
+	viper.SetDefault("health.workers", 4)
+	viper.SetDefault("health.events_limit", 3)
+	viper.SetDefault("config_repo.ref", "main")
+	viper.SetDefault("config_repo.path", "k8s-toolkit.yaml")
+	viper.SetDefault("config_repo.sync_interval", 5*time.Minute)
+	viper.SetDefault("config_repo.allow_unsigned", false)
+	viper.SetDefault("security.vulns.trivy_path", "trivy")
+	viper.SetDefault("security.vulns.parallel", 2)
+	viper.SetDefault("security.vulns.timeout", 5*time.Minute)
+	viper.SetDefault("security.vulns.fail_on", "Critical")
+	viper.SetDefault("credentials.warn_within", 14*24*time.Hour)
+	viper.SetDefault("rotation.warn_within", 7*24*time.Hour)
+	viper.SetDefault("compliance.iam.max_key_age", 90*24*time.Hour)
+	viper.SetDefault("progressive.stuck_after", 30*time.Minute)
+	viper.SetDefault("cronjobs.missed_grace", 10*time.Minute)
+	viper.SetDefault("cronjobs.failure_threshold", 3)
+	viper.SetDefault("mesh.istio", "auto")
+	viper.SetDefault("mesh.istio_namespace", "istio-system")
+	viper.SetDefault("mesh.max_proxy_skew", 2)
+	viper.SetDefault("chaos.protected_namespaces", []string{"kube-system"})
+	viper.SetDefault("remediation.dry_run", true)
+	viper.SetDefault("remediation.max_actions", 20)
+	viper.SetDefault("remediation.window", time.Hour)
+	viper.SetDefault("issues.github.url", "https://api.github.com")
+	viper.SetDefault("issues.jira.issue_type", "Bug")
+	viper.SetDefault("issues.jira.done_transition", "Done")
+}
+
+// initConfig loads the local config file and, when configured, the config
+// file stored in a git repository. Repository settings take precedence.
+func initConfig() {
+	setConfigDefaults()
+
+	if cfgFile := viper.GetString("config"); cfgFile != "" {
+		viper.SetConfigFile(cfgFile)
+		if err := viper.ReadInConfig(); err != nil {
+			logger.Fatalf("Failed to read config file %s: %v", cfgFile, err)
+		}
+	}
+
+	if viper.GetString("config_repo.url") == "" {
+		return
+	}
+
+	source, err := NewGitConfigSource()
+	if err != nil {
+		logger.Fatalf("Failed to initialize config repository: %v", err)
+	}
+	if _, err := source.Sync(); err != nil {
+		logger.Fatalf("Failed to sync config repository: %v", err)
+	}
+	configSource = source
+}
+
+// configSource is the config repository loaded at startup, if any
+var configSource *GitConfigSource
+
+// configMu guards viper while a new config repository revision is swapped
+// in. viper is not safe for concurrent use, so code that reads settings
+// while watchConfigRepo runs, such as a health run, an operator reconcile
+// or an API request, holds the read lock for its duration.
+var configMu sync.RWMutex
+
+// watchConfigRepo re-syncs the config repository every
+// config_repo.sync_interval until ctx is cancelled, so long-running modes
+// pick up reviewed config changes without a restart
+func watchConfigRepo(ctx context.Context) {
+	interval := viper.GetDuration("config_repo.sync_interval")
+	if configSource == nil || configSource.Offline || interval <= 0 {
+		return
+	}
+	go configSource.Watch(ctx, interval, func() {
+		logger.Infof("Applied config repository revision %s", configSource.lastHash)
+	})
+}
+
+// GitConfigSource keeps toolkit configuration in sync with a file in a git
+// repository so changes go through code review instead of host edits
+type GitConfigSource struct {
+	URL      string
+	Ref      string
+	Path     string
+	Keyring  string
+	Dir      string
+	lastHash plumbing.Hash
+
+	// Offline reads the existing clone without contacting the remote
+	Offline bool
+}
+
+// NewGitConfigSource creates a GitConfigSource from the config_repo settings.
+// Every URL and ref gets its own clone, so changing either never reads the
+// previous origin.
+func NewGitConfigSource() (*GitConfigSource, error) {
+	source := &GitConfigSource{
+		URL:  viper.GetString("config_repo.url"),
+		Ref:  viper.GetString("config_repo.ref"),
+		Path: viper.GetString("config_repo.path"),
+
+		Offline: offline(),
+	}
+
+	if keyringFile := viper.GetString("config_repo.keyring"); keyringFile != "" {
+		keyring, err := os.ReadFile(keyringFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keyring: %w", err)
+		}
+		source.Keyring = string(keyring)
+	} else if !viper.GetBool("config_repo.allow_unsigned") {
+		return nil, fmt.Errorf("config repository commits must be signed: set --config-keyring, or config_repo.allow_unsigned to accept unsigned commits")
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(source.URL + "#" + source.Ref))
+	source.Dir = filepath.Join(cacheDir, "k8s-toolkit", "config-repo-"+hex.EncodeToString(sum[:8]))
+
+	return source, nil
+}
+
+// Sync fetches the latest revision, verifies its signature unless unsigned
+// commits are explicitly allowed and replaces the repository settings in
+// viper with the config file. It reports whether the revision changed since
+// the previous sync.
+func (s *GitConfigSource) Sync() (bool, error) {
+	repo, err := s.fetch()
+	if err != nil {
+		return false, err
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return false, fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	if head.Hash() == s.lastHash {
+		return false, nil
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return false, fmt.Errorf("failed to load commit %s: %w", head.Hash(), err)
+	}
+
+	if s.Keyring != "" {
+		if _, err := commit.Verify(s.Keyring); err != nil {
+			return false, fmt.Errorf("signature verification failed for commit %s: %w", head.Hash(), err)
+		}
+	}
+
+	file, err := commit.File(s.Path)
+	if err != nil {
+		return false, fmt.Errorf("failed to find %s in commit %s: %w", s.Path, head.Hash(), err)
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", s.Path, err)
+	}
+
+	settings, err := s.parse(contents)
+	if err != nil {
+		return false, err
+	}
+	if err := replaceConfig(settings); err != nil {
+		return false, err
+	}
+
+	s.lastHash = head.Hash()
+	return true, nil
+}
+
+// parse builds the settings of a revision in a fresh viper instance: the
+// local config file with the repository's file on top. Keys deleted from
+// the repository are absent instead of lingering from earlier revisions.
+func (s *GitConfigSource) parse(contents string) (map[string]interface{}, error) {
+	next := viper.New()
+	if cfgFile := viper.GetString("config"); cfgFile != "" {
+		next.SetConfigFile(cfgFile)
+		if err := next.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", cfgFile, err)
+		}
+	}
+	next.SetConfigType(strings.TrimPrefix(filepath.Ext(s.Path), "."))
+	if err := next.MergeConfig(strings.NewReader(contents)); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", s.Path, err)
+	}
+	return next.AllSettings(), nil
+}
+
+// replaceConfig swaps viper's config file layer for settings under configMu.
+// Defaults, flags and environment variables keep taking part as before.
+func replaceConfig(settings map[string]interface{}) error {
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	configMu.Lock()
+	defer configMu.Unlock()
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to apply config: %w", err)
+	}
+	return nil
+}
+
+// Watch re-syncs the repository on every interval until ctx is cancelled,
+// calling onChange after a new revision has been applied
+func (s *GitConfigSource) Watch(ctx context.Context, interval time.Duration, onChange func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := s.Sync()
+			if err != nil {
+				logger.Warnf("config sync failed, keeping previous config: %v", err)
+				continue
+			}
+			if changed && onChange != nil {
+				onChange()
+			}
+		}
+	}
+}
+
+// fetch clones the repository on first use and afterwards fetches the
+// configured ref and hard-resets the clone to it, so upstream force-pushes
+// are followed too. In offline mode only an existing clone is used.
+func (s *GitConfigSource) fetch() (*git.Repository, error) {
+	ref := plumbing.NewBranchReferenceName(s.Ref)
+
+	repo, err := git.PlainOpen(s.Dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) && s.Offline {
+		return nil, fmt.Errorf("no local clone of %s in %s and offline mode is set", s.URL, s.Dir)
+	}
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainClone(s.Dir, false, &git.CloneOptions{
+			URL:           s.URL,
+			ReferenceName: ref,
+			SingleBranch:  true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to clone %s: %w", s.URL, err)
+		}
+		return repo, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", s.Dir, err)
+	}
+	if s.Offline {
+		return repo, nil
+	}
+
+	remoteRef := plumbing.NewRemoteReferenceName("origin", s.Ref)
+	err = repo.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []gitconfig.RefSpec{gitconfig.RefSpec(fmt.Sprintf("+%s:%s", ref, remoteRef))},
+		Force:      true,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, fmt.Errorf("failed to fetch %s: %w", s.URL, err)
+	}
+	remote, err := repo.Reference(remoteRef, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", remoteRef, err)
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worktree: %w", err)
+	}
+	if err := worktree.Reset(&git.ResetOptions{Commit: remote.Hash(), Mode: git.HardReset}); err != nil {
+		return nil, fmt.Errorf("failed to reset to %s: %w", remoteRef, err)
+	}
+
+	return repo, nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// instanceTypeLabel is the well-known node label holding the cloud instance type
+const instanceTypeLabel = "node.kubernetes.io/instance-type"
+
+// PricingConfig prices cluster resources, either per unit or per node
+// instance type. Instance prices take precedence for nodes whose type is listed.
+type PricingConfig struct {
+	CPUHour   float64            `yaml:"cpu_hour" mapstructure:"cpu_hour" json:"cpu_hour"`
+	GBHour    float64            `yaml:"gb_hour" mapstructure:"gb_hour" json:"gb_hour"`
+	Instances map[string]float64 `yaml:"instances" mapstructure:"instances" json:"instances,omitempty"`
+	Currency  string             `yaml:"currency" mapstructure:"currency" json:"currency"`
+}
+
+// loadPricing reads the pricing file, or the cost section of the config when
+// path is empty
+func loadPricing(path string) (*PricingConfig, error) {
+	pricing := &PricingConfig{}
+	if path == "" {
+		if err := viper.UnmarshalKey("cost", pricing); err != nil {
+			return nil, fmt.Errorf("invalid cost config: %w", err)
+		}
+	} else {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if err := yaml.Unmarshal(data, pricing); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	if pricing.CPUHour == 0 && pricing.GBHour == 0 && len(pricing.Instances) == 0 {
+		return nil, fmt.Errorf("no prices configured: set cpu_hour/gb_hour or instances")
+	}
+	if pricing.Currency == "" {
+		pricing.Currency = "USD"
+	}
+	return pricing, nil
+}
+
+// nodeRates returns the hourly price of one CPU core and one GB of memory on
+// a node. An instance price is split evenly between the node's CPU and memory.
+func (p *PricingConfig) nodeRates(node *corev1.Node) (cpuHour, gbHour float64) {
+	price, ok := p.Instances[node.Labels[instanceTypeLabel]]
+	if !ok {
+		return p.CPUHour, p.GBHour
+	}
+	cores := float64(node.Status.Allocatable.Cpu().MilliValue()) / 1000
+	gb := float64(node.Status.Allocatable.Memory().Value()) / (1 << 30)
+	if cores > 0 {
+		cpuHour = price / 2 / cores
+	}
+	if gb > 0 {
+		gbHour = price / 2 / gb
+	}
+	return cpuHour, gbHour
+}
+
+// WorkloadCost is the estimated monthly cost of one workload
+type WorkloadCost struct {
+	Namespace string  `json:"namespace"`
+	Workload  string  `json:"workload"`
+	Pods      int     `json:"pods"`
+	CPU       int64   `json:"cpu_millicores"`
+	Memory    int64   `json:"memory_bytes"`
+	CPUCost   float64 `json:"cpu_cost"`
+	MemCost   float64 `json:"memory_cost"`
+	Monthly   float64 `json:"monthly"`
+}
+
+// NamespaceCost is the estimated monthly cost of a namespace and its workloads
+type NamespaceCost struct {
+	Namespace string         `json:"namespace"`
+	Monthly   float64        `json:"monthly"`
+	Share     float64        `json:"percent_of_total"`
+	Workloads []WorkloadCost `json:"workloads"`
+}
+
+// CostReport is the monthly cost breakdown by namespace and workload
+type CostReport struct {
+	Basis      string          `json:"basis"`
+	Currency   string          `json:"currency"`
+	Total      float64         `json:"monthly_total"`
+	Namespaces []NamespaceCost `json:"namespaces"`
+}
+
+// BuildCostReport attributes cost to the workloads of running pods, based on
+// their requests or, with basis "usage", on current usage from the metrics
+// server. The hourly cost of each pod is extrapolated to a month.
+func (k *K8sToolkit) BuildCostReport(ctx context.Context, pricing *PricingConfig, basis string) (*CostReport, error) {
+	if basis != "requests" && basis != "usage" {
+		return nil, fmt.Errorf("unknown basis %q, expected requests or usage", basis)
+	}
+
+	nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	type rates struct{ cpu, gb float64 }
+	nodeRates := make(map[string]rates, len(nodes.Items))
+	for i := range nodes.Items {
+		cpu, gb := pricing.nodeRates(&nodes.Items[i])
+		nodeRates[nodes.Items[i].Name] = rates{cpu, gb}
+	}
+
+	usage := make(map[string]corev1.ResourceList)
+	if basis == "usage" {
+		if k.metricsClientset == nil {
+			return nil, fmt.Errorf("metrics server not available")
+		}
+		metrics, err := k.metricsClientset.MetricsV1beta1().PodMetricses(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod metrics: %w", err)
+		}
+		for _, metric := range inScopeItems(k, metrics.Items) {
+			total := corev1.ResourceList{}
+			for _, container := range metric.Containers {
+				for name, quantity := range container.Usage {
+					sum := total[name]
+					sum.Add(quantity)
+					total[name] = sum
+				}
+			}
+			usage[metric.Namespace+"/"+metric.Name] = total
+		}
+	}
+
+	rsOwners, err := k.replicaSetOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	workloads := make(map[workloadKey]*WorkloadCost)
+	err = k.eachPod(ctx, k.namespace, k.listOptions(metav1.ListOptions{}), func(pod *corev1.Pod) error {
+		if !k.inScope(pod.Namespace) || pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			return nil
+		}
+
+		var cpu, memory int64
+		if basis == "usage" {
+			used, ok := usage[pod.Namespace+"/"+pod.Name]
+			if !ok {
+				return nil
+			}
+			cpu, memory = used.Cpu().MilliValue(), used.Memory().Value()
+		} else {
+			cpu, _, memory, _ = podResources(pod)
+		}
+
+		key := podWorkload(pod, rsOwners)
+		wc := workloads[key]
+		if wc == nil {
+			wc = &WorkloadCost{Namespace: key.namespace, Workload: key.String()}
+			workloads[key] = wc
+		}
+		r := nodeRates[pod.Spec.NodeName]
+		wc.Pods++
+		wc.CPU += cpu
+		wc.Memory += memory
+		wc.CPUCost += float64(cpu) / 1000 * r.cpu * hoursPerMonth
+		wc.MemCost += float64(memory) / (1 << 30) * r.gb * hoursPerMonth
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	report := &CostReport{Basis: basis, Currency: pricing.Currency}
+	byNamespace := make(map[string]*NamespaceCost)
+	for _, wc := range workloads {
+		wc.Monthly = wc.CPUCost + wc.MemCost
+		nc := byNamespace[wc.Namespace]
+		if nc == nil {
+			nc = &NamespaceCost{Namespace: wc.Namespace}
+			byNamespace[wc.Namespace] = nc
+		}
+		nc.Workloads = append(nc.Workloads, *wc)
+		nc.Monthly += wc.Monthly
+		report.Total += wc.Monthly
+	}
+
+	for _, nc := range byNamespace {
+		if report.Total > 0 {
+			nc.Share = nc.Monthly / report.Total * 100
+		}
+		sort.Slice(nc.Workloads, func(i, j int) bool { return nc.Workloads[i].Monthly > nc.Workloads[j].Monthly })
+		report.Namespaces = append(report.Namespaces, *nc)
+	}
+	sort.Slice(report.Namespaces, func(i, j int) bool {
+		return report.Namespaces[i].Monthly > report.Namespaces[j].Monthly
+	})
+	return report, nil
+}
+
+// PrintCostReport prints the monthly cost per namespace and, unless
+// namespacesOnly is set, per workload
+func (k *K8sToolkit) PrintCostReport(report *CostReport, namespacesOnly bool) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Printf("Monthly Cost Estimate (by %s)\n", report.Basis)
+	fmt.Println("=====================================")
+	for _, nc := range report.Namespaces {
+		fmt.Printf("%-40s %12.2f %s  %5.1f%%\n", nc.Namespace, nc.Monthly, report.Currency, nc.Share)
+		if namespacesOnly {
+			continue
+		}
+		for _, wc := range nc.Workloads {
+			fmt.Printf("  %-38s %12.2f %s  (%d pods, %dm CPU, %s)\n",
+				wc.Workload, wc.Monthly, report.Currency, wc.Pods, wc.CPU, formatMemory(wc.Memory))
+		}
+	}
+	fmt.Printf("\n%-40s %12.2f %s\n", "Total", report.Total, report.Currency)
+}
+
+// createCostCmd creates the cost command
+func createCostCmd() *cobra.Command {
+	var pricingFile string
+	var basis string
+	var namespacesOnly bool
+
+	costCmd := &cobra.Command{
+		Use:   "cost",
+		Short: "Estimate monthly cost per namespace and workload",
+		Long: `Attributes cluster cost to namespaces and workloads for chargeback. Prices come from
+--pricing or the cost section of the config file:
+
+  cost:
+    currency: USD
+    cpu_hour: 0.031      # per core
+    gb_hour: 0.004       # per GB of memory
+    instances:           # hourly node price by node.kubernetes.io/instance-type
+      m5.xlarge: 0.192
+
+Instance prices are split evenly between a node's CPU and memory. Pods are charged for their
+requests, or with --basis usage for their current usage, extrapolated to a 730-hour month.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			pricing, err := loadPricing(pricingFile)
+			if err != nil {
+				logger.Fatalf("Failed to load pricing: %v", err)
+			}
+
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			report, err := toolkit.BuildCostReport(context.Background(), pricing, basis)
+			if err != nil {
+				logger.Fatalf("Failed to build cost report: %v", err)
+			}
+
+			toolkit.PrintCostReport(report, namespacesOnly)
+		},
+	}
+
+	costCmd.PersistentFlags().StringVar(&pricingFile, "pricing", "", "YAML pricing file (defaults to the cost section of the config file)")
+	costCmd.Flags().StringVar(&basis, "basis", "requests", "Attribute cost by requests or usage")
+	costCmd.Flags().BoolVar(&namespacesOnly, "namespaces-only", false, "Only show the per-namespace totals")
+
+	costCmd.AddCommand(createShowbackCmds(&pricingFile)...)
+	return costCmd
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// crashLoopReason returns the waiting reason of a container that keeps
+// restarting. Such pods still report phase Running, so phase-based checks
+// count them as healthy.
+func crashLoopReason(pod *corev1.Pod) string {
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+			return fmt.Sprintf("%s (container %s, %d restarts)", status.State.Waiting.Reason, status.Name, status.RestartCount)
+		}
+	}
+	return ""
+}
+
+// CheckCrashLoops reports pods in scope with a container in CrashLoopBackOff
+func (k *K8sToolkit) CheckCrashLoops(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Crashlooping Pods",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	var crashing []string
+	total := 0
+	err := k.eachPod(ctx, k.namespace, k.listOptions(metav1.ListOptions{}), func(pod *corev1.Pod) error {
+		if !k.inScope(pod.Namespace) {
+			return nil
+		}
+		total++
+		if reason := crashLoopReason(pod); reason != "" {
+			crashing = append(crashing, fmt.Sprintf("%s/%s: %s", pod.Namespace, pod.Name, reason))
+			result.Affected = append(result.Affected, objectRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name})
+		}
+		return nil
+	})
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list pods: %v", err)
+		result.Err = err
+		return result
+	}
+
+	result.Details["pods"] = strconv.Itoa(total)
+	if len(crashing) > 0 {
+		sort.Strings(crashing)
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d pods are crashlooping", len(crashing))
+		result.Details["crashlooping"] = strings.Join(crashing, "; ")
+	} else {
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("No crashlooping pods among %d", total)
+	}
+	return result
+}
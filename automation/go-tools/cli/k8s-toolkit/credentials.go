@@ -0,0 +1,363 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// expiresAtAnnotation records when a credential stored in a Secret expires,
+// for credentials such as cloud provider keys whose expiry cannot be read
+// from the value itself
+const expiresAtAnnotation = "k8s-toolkit.devops/expires-at"
+
+// CredentialExpiry is a credential that expires within the reporting window
+type CredentialExpiry struct {
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	Detail    string    `json:"detail,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	DaysLeft  int       `json:"days_left"`
+}
+
+// displayName returns the namespaced name of the credential's object
+func (c CredentialExpiry) displayName() string {
+	if c.Namespace == "" {
+		return c.Name
+	}
+	return c.Namespace + "/" + c.Name
+}
+
+func (c CredentialExpiry) key() string {
+	return c.Kind + "/" + c.Namespace + "/" + c.Name + "/" + c.Detail + "/" + c.ExpiresAt.Format(time.RFC3339)
+}
+
+// FindExpiringCredentials reports credentials expiring within the window:
+// JWT tokens stored in Secrets (bound service account tokens handed to
+// external systems), Secrets annotated with an expiry, client certificates
+// and tokens in the kubeconfig, and admission webhook CA bundles. Expired
+// credentials are included with negative days left.
+func (k *K8sToolkit) FindExpiringCredentials(ctx context.Context, within time.Duration) ([]CredentialExpiry, error) {
+	now := time.Now()
+	deadline := now.Add(within)
+
+	var expiring []CredentialExpiry
+	add := func(kind, namespace, name, detail string, expiresAt time.Time) {
+		if expiresAt.After(deadline) {
+			return
+		}
+		expiring = append(expiring, CredentialExpiry{
+			Kind:      kind,
+			Namespace: namespace,
+			Name:      name,
+			Detail:    detail,
+			ExpiresAt: expiresAt,
+			DaysLeft:  int(expiresAt.Sub(now).Hours() / 24),
+		})
+	}
+
+	err := k.eachSecret(ctx, k.namespace, metav1.ListOptions{}, func(secret *corev1.Secret) error {
+		if !k.inScope(secret.Namespace) {
+			return nil
+		}
+		if value, ok := secret.Annotations[expiresAtAnnotation]; ok {
+			expiresAt, err := parseExpiry(value)
+			if err != nil {
+				k.log().Warnf("secret %s/%s has invalid %s annotation: %v", secret.Namespace, secret.Name, expiresAtAnnotation, err)
+			} else {
+				add("Secret", secret.Namespace, secret.Name, "annotated expiry", expiresAt)
+			}
+		}
+		for key, value := range secret.Data {
+			if expiresAt, ok := jwtExpiry(string(value)); ok {
+				add("Secret", secret.Namespace, secret.Name, "token in key "+key, expiresAt)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	validating, err := k.clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list validating webhook configurations: %w", err)
+	}
+	for _, config := range validating.Items {
+		for _, webhook := range config.Webhooks {
+			for _, cert := range parseCertificates(webhook.ClientConfig.CABundle) {
+				add("ValidatingWebhookConfiguration", "", config.Name, "caBundle of "+webhook.Name+" ("+cert.Subject.CommonName+")", cert.NotAfter)
+			}
+		}
+	}
+
+	mutating, err := k.clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mutating webhook configurations: %w", err)
+	}
+	for _, config := range mutating.Items {
+		for _, webhook := range config.Webhooks {
+			for _, cert := range parseCertificates(webhook.ClientConfig.CABundle) {
+				add("MutatingWebhookConfiguration", "", config.Name, "caBundle of "+webhook.Name+" ("+cert.Subject.CommonName+")", cert.NotAfter)
+			}
+		}
+	}
+
+	kubeconfig := viper.GetString("kubeconfig")
+	if kubeconfig == "" {
+		kubeconfig = clientcmd.RecommendedHomeFile
+	}
+	if config, err := clientcmd.LoadFromFile(kubeconfig); err != nil {
+		k.log().Warnf("failed to read kubeconfig %s: %v", kubeconfig, err)
+	} else {
+		for name, auth := range config.AuthInfos {
+			data := auth.ClientCertificateData
+			if len(data) == 0 && auth.ClientCertificate != "" {
+				if data, err = os.ReadFile(auth.ClientCertificate); err != nil {
+					k.log().Warnf("failed to read client certificate of kubeconfig user %s: %v", name, err)
+				}
+			}
+			for _, cert := range parseCertificates(data) {
+				add("Kubeconfig", "", name, "client certificate ("+cert.Subject.CommonName+")", cert.NotAfter)
+			}
+			if expiresAt, ok := jwtExpiry(auth.Token); ok {
+				add("Kubeconfig", "", name, "token", expiresAt)
+			}
+		}
+	}
+
+	sort.Slice(expiring, func(i, j int) bool {
+		if !expiring[i].ExpiresAt.Equal(expiring[j].ExpiresAt) {
+			return expiring[i].ExpiresAt.Before(expiring[j].ExpiresAt)
+		}
+		return expiring[i].key() < expiring[j].key()
+	})
+	return expiring, nil
+}
+
+// parseExpiry accepts an RFC 3339 timestamp or a plain date
+func parseExpiry(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// jwtExpiry returns the exp claim of a JWT without verifying its signature
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 || !strings.HasPrefix(parts[0], "eyJ") {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
+
+// parseCertificates decodes every certificate in a PEM bundle, skipping
+// blocks that are not certificates
+func parseCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
+// CheckCredentialExpiry reports credentials expiring within
+// credentials.warn_within as a health check
+func (k *K8sToolkit) CheckCredentialExpiry(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Credential Expiry",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	expiring, err := k.FindExpiringCredentials(ctx, viper.GetDuration("credentials.warn_within"))
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to check credential expiry: %v", err)
+		result.Err = err
+		return result
+	}
+
+	expired := 0
+	var names []string
+	for _, c := range expiring {
+		if c.ExpiresAt.Before(result.Timestamp) {
+			expired++
+		}
+		names = append(names, fmt.Sprintf("%s %s %s (%s)", c.Kind, c.displayName(), c.Detail, c.ExpiresAt.Format("2006-01-02")))
+		if c.Kind != "Kubeconfig" {
+			result.Affected = append(result.Affected, objectRef{Kind: c.Kind, Namespace: c.Namespace, Name: c.Name})
+		}
+	}
+	result.Details["expiring"] = strconv.Itoa(len(expiring) - expired)
+	result.Details["expired"] = strconv.Itoa(expired)
+
+	switch {
+	case expired > 0:
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("%d credentials expired, %d expiring soon", expired, len(expiring)-expired)
+		result.Details["credentials"] = strings.Join(names, "; ")
+	case len(expiring) > 0:
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d credentials expiring within %s", len(expiring), viper.GetDuration("credentials.warn_within"))
+		result.Details["credentials"] = strings.Join(names, "; ")
+	default:
+		result.Status = "Healthy"
+		result.Message = "No credentials expiring soon"
+	}
+
+	return result
+}
+
+// PrintCredentialExpiry prints the expiring credentials
+func (k *K8sToolkit) PrintCredentialExpiry(expiring []CredentialExpiry, within time.Duration) {
+	if k.filtered(expiring) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(expiring)
+		return
+	}
+
+	fmt.Println(tr("Credentials Expiring Within %d Days", int(within.Hours()/24)))
+	fmt.Println("=====================================")
+	if len(expiring) == 0 {
+		fmt.Println("No credentials expiring")
+		return
+	}
+	fmt.Printf("%-12s %-32s %-40s %-45s %s\n", "EXPIRES", "KIND", "NAME", "DETAIL", "DAYS LEFT")
+	for _, c := range expiring {
+		fmt.Printf("%-12s %-32s %-40s %-45s %d\n", c.ExpiresAt.Format("2006-01-02"), c.Kind, c.displayName(), c.Detail, c.DaysLeft)
+	}
+}
+
+// notifyCredentialExpiry posts a Slack-compatible message listing the
+// expiring credentials to url
+func notifyCredentialExpiry(ctx context.Context, url string, expiring []CredentialExpiry) error {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%d credentials expire soon:\n", len(expiring))
+	for _, c := range expiring {
+		fmt.Fprintf(&text, "• %s %s %s expires %s (%d days)\n",
+			c.Kind, c.displayName(), c.Detail, c.ExpiresAt.Format("2006-01-02"), c.DaysLeft)
+	}
+
+	return postWebhook(ctx, url, map[string]interface{}{"text": text.String(), "credentials": expiring})
+}
+
+// createExpiryCmd creates the expiry command
+func createExpiryCmd() *cobra.Command {
+	var interval time.Duration
+	var failOnExpiring bool
+
+	expiryCmd := &cobra.Command{
+		Use:   "expiry",
+		Short: "Report credentials that expire soon",
+		Long: `Reports tokens stored in Secrets, Secrets annotated with ` + expiresAtAnnotation + `,
+kubeconfig client certificates and tokens, and admission webhook CA bundles that expire
+within --within. With --interval the check repeats until interrupted and posts to
+--notify-url whenever a credential enters the window.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			within := viper.GetDuration("credentials.warn_within")
+			notifyURL, err := secretSetting(context.Background(), "credentials.notify_url")
+			if err != nil {
+				logger.Fatalf("Failed to configure notifications: %v", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			notified := make(map[string]bool)
+			for {
+				expiring, err := toolkit.FindExpiringCredentials(ctx, within)
+				if err != nil {
+					logger.Fatalf("Failed to check credential expiry: %v", err)
+				}
+
+				if notifyURL != "" && !offline() {
+					var fresh []CredentialExpiry
+					for _, c := range expiring {
+						if !notified[c.key()] {
+							fresh = append(fresh, c)
+						}
+					}
+					if len(fresh) > 0 {
+						if err := notifyCredentialExpiry(ctx, notifyURL, fresh); err != nil {
+							logger.Warnf("failed to send notification: %v", err)
+						} else {
+							for _, c := range fresh {
+								notified[c.key()] = true
+							}
+						}
+					}
+				}
+
+				if interval <= 0 {
+					toolkit.PrintCredentialExpiry(expiring, within)
+					if failOnExpiring && len(expiring) > 0 {
+						os.Exit(1)
+					}
+					return
+				}
+				logger.Infof("%d credentials expiring within %s", len(expiring), within)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(interval):
+				}
+			}
+		},
+	}
+
+	expiryCmd.Flags().Duration("within", 14*24*time.Hour, "Report credentials expiring within this duration")
+	expiryCmd.Flags().String("notify-url", "", "Webhook URL (Slack-compatible) notified about newly expiring credentials")
+	expiryCmd.Flags().DurationVar(&interval, "interval", 0, "Repeat the check on this interval instead of exiting")
+	expiryCmd.Flags().BoolVar(&failOnExpiring, "fail-on-expiring", false, "Exit non-zero when any credential is expiring")
+	viper.BindPFlag("credentials.warn_within", expiryCmd.Flags().Lookup("within"))
+	viper.BindPFlag("credentials.notify_url", expiryCmd.Flags().Lookup("notify-url"))
+
+	return expiryCmd
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/viper"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxScheduleSteps bounds the walk through a schedule's activation times;
+// a CronJob that missed more runs than this is reported as missed anyway
+const maxScheduleSteps = 100000
+
+// lastScheduledBefore returns the latest activation of schedule after since
+// and at or before until, or the zero time when there is none
+func lastScheduledBefore(schedule cron.Schedule, since, until time.Time) time.Time {
+	var last time.Time
+	for next, i := schedule.Next(since), 0; !next.After(until); next, i = schedule.Next(next), i+1 {
+		if i == maxScheduleSteps {
+			return until
+		}
+		last = next
+	}
+	return last
+}
+
+// parseCronJobSchedule parses a CronJob's schedule in its time zone, the way
+// the CronJob controller does
+func parseCronJobSchedule(cronJob *batchv1.CronJob) (cron.Schedule, error) {
+	schedule := cronJob.Spec.Schedule
+	if cronJob.Spec.TimeZone != nil && !strings.Contains(schedule, "TZ=") {
+		schedule = "CRON_TZ=" + *cronJob.Spec.TimeZone + " " + schedule
+	}
+	return cron.ParseStandard(schedule)
+}
+
+// jobDeadlineExceeded reports whether a job ran past activeDeadlineSeconds:
+// either it failed with DeadlineExceeded or it is still active beyond it
+func jobDeadlineExceeded(job *batchv1.Job, now time.Time) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue && condition.Reason == "DeadlineExceeded" {
+			return true
+		}
+	}
+	if job.Spec.ActiveDeadlineSeconds == nil || job.Status.StartTime == nil || job.Status.Active == 0 {
+		return false
+	}
+	return now.After(job.Status.StartTime.Add(time.Duration(*job.Spec.ActiveDeadlineSeconds) * time.Second))
+}
+
+// consecutiveFailures counts how many of the most recent finished jobs
+// failed in a row
+func consecutiveFailures(jobs []*batchv1.Job) int {
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreationTimestamp.After(jobs[j].CreationTimestamp.Time) })
+	failures := 0
+	for _, job := range jobs {
+		state, _, finished := jobFinished(job)
+		if !finished {
+			continue
+		}
+		if state != "failed" {
+			break
+		}
+		failures++
+	}
+	return failures
+}
+
+// CheckJobs reports CronJobs that missed their schedule window, CronJobs
+// and Jobs failing repeatedly, suspended CronJobs and Jobs that ran past
+// activeDeadlineSeconds. A CronJob missed a run when its last schedule time
+// is older than the latest activation of its schedule, allowing
+// startingDeadlineSeconds or cronjobs.missed_grace for the controller to
+// start it. The last successful run of every CronJob is listed in the
+// details so that missed backups show up next to their age.
+func (k *K8sToolkit) CheckJobs(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Jobs",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+	now := result.Timestamp
+
+	cronJobs, err := k.clientset.BatchV1().CronJobs(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list CronJobs: %v", err)
+		result.Err = err
+		return result
+	}
+	jobs, err := k.clientset.BatchV1().Jobs(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list Jobs: %v", err)
+		result.Err = err
+		return result
+	}
+
+	jobsByCronJob := make(map[string][]*batchv1.Job)
+	var standalone []*batchv1.Job
+	inScopeJobs := 0
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if !k.inScope(job.Namespace) {
+			continue
+		}
+		inScopeJobs++
+		if owner := metav1.GetControllerOf(job); owner != nil && owner.Kind == "CronJob" {
+			jobsByCronJob[job.Namespace+"/"+owner.Name] = append(jobsByCronJob[job.Namespace+"/"+owner.Name], job)
+			continue
+		}
+		standalone = append(standalone, job)
+	}
+
+	grace := viper.GetDuration("cronjobs.missed_grace")
+	threshold := viper.GetInt("cronjobs.failure_threshold")
+	var missed, failing, suspended, deadline, lastSuccess []string
+	critical := 0
+
+	scopedCronJobs := inScopeItems(k, cronJobs.Items)
+	for _, cronJob := range scopedCronJobs {
+		key := cronJob.Namespace + "/" + cronJob.Name
+		ref := objectRef{Kind: "CronJob", Namespace: cronJob.Namespace, Name: cronJob.Name}
+
+		success := "never"
+		if cronJob.Status.LastSuccessfulTime != nil {
+			success = cronJob.Status.LastSuccessfulTime.UTC().Format(time.RFC3339)
+		}
+		lastSuccess = append(lastSuccess, fmt.Sprintf("%s=%s", key, success))
+
+		if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+			suspended = append(suspended, key)
+			continue
+		}
+
+		schedule, err := parseCronJobSchedule(&cronJob)
+		if err != nil {
+			missed = append(missed, fmt.Sprintf("%s (invalid schedule %q)", key, cronJob.Spec.Schedule))
+			result.Affected = append(result.Affected, ref)
+			critical++
+			continue
+		}
+		window := grace
+		if cronJob.Spec.StartingDeadlineSeconds != nil {
+			window = time.Duration(*cronJob.Spec.StartingDeadlineSeconds) * time.Second
+		}
+		since := cronJob.CreationTimestamp.Time
+		if cronJob.Status.LastScheduleTime != nil {
+			since = cronJob.Status.LastScheduleTime.Time
+		}
+		// Forbid skips runs while the previous one is active; that is a long
+		// run, not a missed one
+		blocked := cronJob.Spec.ConcurrencyPolicy == batchv1.ForbidConcurrent && len(cronJob.Status.Active) > 0
+		if due := lastScheduledBefore(schedule, since, now.Add(-window)); !due.IsZero() && !blocked {
+			missed = append(missed, fmt.Sprintf("%s (due %s, last success %s)", key, due.UTC().Format(time.RFC3339), success))
+			result.Affected = append(result.Affected, ref)
+			critical++
+		}
+
+		if failures := consecutiveFailures(jobsByCronJob[key]); failures >= threshold {
+			failing = append(failing, fmt.Sprintf("%s (%d runs in a row)", key, failures))
+			result.Affected = append(result.Affected, ref)
+			critical++
+		}
+		for _, job := range jobsByCronJob[key] {
+			if jobDeadlineExceeded(job, now) {
+				deadline = append(deadline, job.Namespace+"/"+job.Name)
+			}
+		}
+	}
+
+	for _, job := range standalone {
+		if jobDeadlineExceeded(job, now) {
+			deadline = append(deadline, job.Namespace+"/"+job.Name)
+			continue
+		}
+		if state, _, finished := jobFinished(job); finished && state == "failed" {
+			failing = append(failing, fmt.Sprintf("%s/%s (%d failed pods)", job.Namespace, job.Name, job.Status.Failed))
+			result.Affected = append(result.Affected, objectRef{Kind: "Job", Namespace: job.Namespace, Name: job.Name})
+		}
+	}
+
+	result.Details["cronjobs"] = strconv.Itoa(len(scopedCronJobs))
+	result.Details["jobs"] = strconv.Itoa(inScopeJobs)
+	if len(lastSuccess) > 0 {
+		result.Details["last_success"] = strings.Join(lastSuccess, ", ")
+	}
+	for name, items := range map[string][]string{"missed": missed, "failing": failing, "suspended": suspended, "deadline_exceeded": deadline} {
+		if len(items) > 0 {
+			result.Details[name] = strings.Join(items, "; ")
+		}
+	}
+
+	var parts []string
+	if len(missed) > 0 {
+		parts = append(parts, fmt.Sprintf("%d CronJobs missed their schedule", len(missed)))
+	}
+	if len(failing) > 0 {
+		parts = append(parts, fmt.Sprintf("%d failing", len(failing)))
+	}
+	if len(deadline) > 0 {
+		parts = append(parts, fmt.Sprintf("%d Jobs past activeDeadlineSeconds", len(deadline)))
+	}
+	if len(suspended) > 0 {
+		parts = append(parts, fmt.Sprintf("%d CronJobs suspended", len(suspended)))
+	}
+
+	switch {
+	case critical > 0:
+		result.Status = "Critical"
+		result.Message = strings.Join(parts, ", ")
+	case len(parts) > 0:
+		result.Status = "Warning"
+		result.Message = strings.Join(parts, ", ")
+	default:
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("All %d CronJobs ran on schedule", len(scopedCronJobs))
+	}
+	return result
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+)
+
+// defaultNodeAgents are DaemonSet name patterns of the agents every node
+// needs: CNI plugins, kube-proxy, log shippers and node metrics. Override
+// them with daemonsets.critical.
+var defaultNodeAgents = []string{
+	"kube-proxy", "calico-node", "cilium", "aws-node", "azure-cni*", "kube-flannel*", "weave-net", "antrea-agent",
+	"fluent-bit*", "fluentd*", "promtail", "vector*", "filebeat*",
+	"*node-exporter*", "datadog*",
+}
+
+// isNodeAgent reports whether a DaemonSet name matches one of patterns
+func isNodeAgent(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// uncoveredReason explains why a DaemonSet has no pod on a node: an
+// untolerated NoSchedule/NoExecute taint, a nodeSelector mismatch or
+// required node affinity. An empty reason means the node is eligible and
+// the pod is missing for another reason, such as being unschedulable.
+func uncoveredReason(ds *appsv1.DaemonSet, node *corev1.Node) string {
+	spec := ds.Spec.Template.Spec
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range spec.Tolerations {
+			tolerated = tolerated || spec.Tolerations[j].ToleratesTaint(taint)
+		}
+		if !tolerated {
+			return fmt.Sprintf("taint %s=%s:%s not tolerated", taint.Key, taint.Value, taint.Effect)
+		}
+	}
+	if len(spec.NodeSelector) > 0 && !labels.SelectorFromSet(spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+		return "nodeSelector does not match"
+	}
+	if affinity := spec.Affinity; affinity != nil && affinity.NodeAffinity != nil && affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution != nil {
+		matches := false
+		for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+			matches = matches || nodeSelectorTermMatches(term, node)
+		}
+		if !matches {
+			return "node affinity does not match"
+		}
+	}
+	return ""
+}
+
+// nodeSelectorTermMatches evaluates the label expressions of a node
+// selector term. Field expressions other than metadata.name are ignored.
+func nodeSelectorTermMatches(term corev1.NodeSelectorTerm, node *corev1.Node) bool {
+	for _, expr := range term.MatchExpressions {
+		requirement, err := labels.NewRequirement(expr.Key, selectorOperator(expr.Operator), expr.Values)
+		if err != nil || !requirement.Matches(labels.Set(node.Labels)) {
+			return false
+		}
+	}
+	for _, expr := range term.MatchFields {
+		if expr.Key != "metadata.name" {
+			continue
+		}
+		requirement, err := labels.NewRequirement(expr.Key, selectorOperator(expr.Operator), expr.Values)
+		if err != nil || !requirement.Matches(labels.Set{"metadata.name": node.Name}) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectorOperator maps node selector operators to label selector ones
+func selectorOperator(op corev1.NodeSelectorOperator) selection.Operator {
+	switch op {
+	case corev1.NodeSelectorOpIn:
+		return selection.In
+	case corev1.NodeSelectorOpNotIn:
+		return selection.NotIn
+	case corev1.NodeSelectorOpExists:
+		return selection.Exists
+	case corev1.NodeSelectorOpDoesNotExist:
+		return selection.DoesNotExist
+	case corev1.NodeSelectorOpGt:
+		return selection.GreaterThan
+	default:
+		return selection.LessThan
+	}
+}
+
+// isPodReady reports whether a pod's Ready condition is true
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// CheckDaemonSets compares desired and ready pods of every DaemonSet per
+// node. Node agents (daemonsets.critical, defaulting to CNI plugins,
+// kube-proxy, log shippers and node exporters) must run on every Ready node:
+// nodes they skip because of taints, nodeSelector or affinity are listed
+// with the reason, and agents missing or not ready on a node are Critical.
+// Other DaemonSets with fewer ready pods than desired are a Warning.
+func (k *K8sToolkit) CheckDaemonSets(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "DaemonSets",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	daemonSets, err := k.clientset.AppsV1().DaemonSets(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list DaemonSets: %v", err)
+		result.Err = err
+		return result
+	}
+	nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list nodes: %v", err)
+		result.Err = err
+		return result
+	}
+
+	// Ready state of each DaemonSet's pod per node
+	podReady := make(map[string]map[string]bool)
+	err = k.eachPod(ctx, k.namespace, metav1.ListOptions{}, func(pod *corev1.Pod) error {
+		owner := metav1.GetControllerOf(pod)
+		if owner == nil || owner.Kind != "DaemonSet" || pod.Spec.NodeName == "" {
+			return nil
+		}
+		key := string(owner.UID)
+		if podReady[key] == nil {
+			podReady[key] = make(map[string]bool)
+		}
+		podReady[key][pod.Spec.NodeName] = podReady[key][pod.Spec.NodeName] || isPodReady(pod)
+		return nil
+	})
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list pods: %v", err)
+		result.Err = err
+		return result
+	}
+
+	var readyNodes []*corev1.Node
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue {
+				readyNodes = append(readyNodes, node)
+			}
+		}
+	}
+
+	agents := viper.GetStringSlice("daemonsets.critical")
+	if len(agents) == 0 {
+		agents = defaultNodeAgents
+	}
+
+	var degraded, gaps, excluded []string
+	agentCount, critical := 0, 0
+	for _, ds := range inScopeItems(k, daemonSets.Items) {
+		name := ds.Namespace + "/" + ds.Name
+		desired, ready := ds.Status.DesiredNumberScheduled, ds.Status.NumberReady
+		agent := isNodeAgent(ds.Name, agents)
+		if agent {
+			agentCount++
+		}
+		if ready < desired {
+			degraded = append(degraded, fmt.Sprintf("%s %d/%d ready", name, ready, desired))
+			result.Affected = append(result.Affected, objectRef{Kind: "DaemonSet", Namespace: ds.Namespace, Name: ds.Name})
+		}
+		if !agent {
+			continue
+		}
+
+		var missing, notReady, skipped []string
+		onNode := podReady[string(ds.UID)]
+		for _, node := range readyNodes {
+			podIsReady, scheduled := onNode[node.Name]
+			switch {
+			case scheduled && podIsReady:
+			case scheduled:
+				notReady = append(notReady, node.Name)
+			default:
+				if reason := uncoveredReason(&ds, node); reason != "" {
+					skipped = append(skipped, fmt.Sprintf("%s (%s)", node.Name, reason))
+				} else {
+					missing = append(missing, node.Name)
+				}
+			}
+		}
+		sort.Strings(missing)
+		sort.Strings(notReady)
+		if len(missing)+len(notReady) > 0 {
+			critical++
+			var parts []string
+			if len(missing) > 0 {
+				parts = append(parts, "no pod on "+strings.Join(missing, ", "))
+			}
+			if len(notReady) > 0 {
+				parts = append(parts, "not ready on "+strings.Join(notReady, ", "))
+			}
+			gaps = append(gaps, fmt.Sprintf("%s: %s", name, strings.Join(parts, "; ")))
+		}
+		if len(skipped) > 0 {
+			excluded = append(excluded, fmt.Sprintf("%s: %s", name, strings.Join(skipped, ", ")))
+		}
+	}
+
+	result.Details["daemonsets"] = strconv.Itoa(len(daemonSets.Items))
+	result.Details["node_agents"] = strconv.Itoa(agentCount)
+	result.Details["ready_nodes"] = strconv.Itoa(len(readyNodes))
+	if len(degraded) > 0 {
+		result.Details["degraded"] = strings.Join(degraded, ", ")
+	}
+	if len(gaps) > 0 {
+		result.Details["agent_gaps"] = strings.Join(gaps, " | ")
+	}
+	if len(excluded) > 0 {
+		result.Details["agent_excluded_nodes"] = strings.Join(excluded, " | ")
+	}
+
+	switch {
+	case critical > 0:
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("%d node agents are missing or not ready on some Ready nodes", critical)
+	case len(excluded) > 0:
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d node agents do not cover every node because of taints, nodeSelector or affinity", len(excluded))
+	case len(degraded) > 0:
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d DaemonSets have fewer ready pods than desired", len(degraded))
+	default:
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("All %d DaemonSets fully ready; node agents cover all %d Ready nodes", len(daemonSets.Items), len(readyNodes))
+	}
+	return result
+}
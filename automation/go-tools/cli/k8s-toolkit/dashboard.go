@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// dashboardPanes are the panes of the dashboard in tab order
+var dashboardPanes = []string{"Health", "Nodes", "Pods", "Events", "Failing"}
+
+// maxDashboardEvents bounds the warning events shown in the Events pane
+const maxDashboardEvents = 100
+
+// dashboardSnapshot is one refresh of everything the dashboard shows. Each
+// pane is rendered to lines up front so scrolling does not touch the cluster.
+type dashboardSnapshot struct {
+	taken time.Time
+	panes [][]string
+}
+
+type snapshotMsg dashboardSnapshot
+type tickMsg time.Time
+
+// dashboardModel is the bubbletea model of the dashboard
+type dashboardModel struct {
+	toolkit  *K8sToolkit
+	interval time.Duration
+	pane     int
+	scroll   []int
+	height   int
+	width    int
+	loading  bool
+	snapshot dashboardSnapshot
+}
+
+// dashboardSnapshot collects the data of every dashboard pane. Panes whose data
+// cannot be fetched show the error instead.
+func (k *K8sToolkit) dashboardSnapshot(ctx context.Context) dashboardSnapshot {
+	snapshot := dashboardSnapshot{taken: time.Now(), panes: make([][]string, len(dashboardPanes))}
+	snapshot.panes[0] = k.dashboardHealth(ctx)
+	snapshot.panes[1] = k.dashboardNodes(ctx)
+	snapshot.panes[2] = k.dashboardPods(ctx)
+	snapshot.panes[3] = k.dashboardEvents(ctx)
+	snapshot.panes[4] = k.dashboardFailing(ctx)
+	return snapshot
+}
+
+func (k *K8sToolkit) dashboardHealth(ctx context.Context) []string {
+	health, err := k.RunHealthCheck(ctx)
+	if err != nil {
+		return []string{"Health check failed: " + err.Error()}
+	}
+	lines := []string{fmt.Sprintf("Overall: %s %s", statusIcon(health.OverallStatus), health.OverallStatus), ""}
+	for _, check := range health.Checks {
+		lines = append(lines, fmt.Sprintf("%s %-22s %s", statusIcon(check.Status), check.Component, check.Message))
+		if check.Status == "Healthy" {
+			continue
+		}
+		keys := make([]string, 0, len(check.Details))
+		for key := range check.Details {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			lines = append(lines, fmt.Sprintf("      %s: %s", key, check.Details[key]))
+		}
+	}
+	return lines
+}
+
+func (k *K8sToolkit) dashboardNodes(ctx context.Context) []string {
+	usages, err := k.TopNodes(ctx, "")
+	if err != nil {
+		return []string{"Node metrics unavailable: " + err.Error()}
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].CPUPercent > usages[j].CPUPercent })
+	lines := []string{fmt.Sprintf("%-40s %10s %6s %10s %6s", "NODE", "CPU", "CPU%", "MEMORY", "MEM%")}
+	for _, u := range usages {
+		lines = append(lines, fmt.Sprintf("%-40s %9dm %5.0f%% %10s %5.0f%%",
+			u.Name, u.CPUUsage, u.CPUPercent, formatMemory(u.MemoryUsage), u.MemoryPercent))
+	}
+	return lines
+}
+
+func (k *K8sToolkit) dashboardPods(ctx context.Context) []string {
+	usages, err := k.TopPods(ctx)
+	if err != nil {
+		return []string{"Pod metrics unavailable: " + err.Error()}
+	}
+	sort.Slice(usages, func(i, j int) bool { return usages[i].CPUUsage > usages[j].CPUUsage })
+	lines := []string{fmt.Sprintf("%-20s %-45s %8s %10s", "NAMESPACE", "POD", "CPU", "MEMORY")}
+	for _, u := range usages {
+		lines = append(lines, fmt.Sprintf("%-20s %-45s %7dm %10s", u.Namespace, u.Name, u.CPUUsage, formatMemory(u.MemoryUsage)))
+	}
+	return lines
+}
+
+func (k *K8sToolkit) dashboardEvents(ctx context.Context) []string {
+	var events []corev1.Event
+	err := k.eachEvent(ctx, k.namespace, metav1.ListOptions{FieldSelector: "type=Warning"}, func(event *corev1.Event) error {
+		if !k.inScope(event.Namespace) {
+			return nil
+		}
+		events = append(events, *event)
+		return nil
+	})
+	if err != nil {
+		return []string{"Failed to list events: " + err.Error()}
+	}
+	sort.Slice(events, func(i, j int) bool { return eventTime(events[i]).After(eventTime(events[j])) })
+	if len(events) > maxDashboardEvents {
+		events = events[:maxDashboardEvents]
+	}
+	if len(events) == 0 {
+		return []string{"No warning events"}
+	}
+	var lines []string
+	for _, event := range events {
+		ref := objectRef{Kind: event.InvolvedObject.Kind, Namespace: event.InvolvedObject.Namespace, Name: event.InvolvedObject.Name}
+		lines = append(lines, fmt.Sprintf("%s  %s", ref, formatEvent(event)))
+	}
+	return lines
+}
+
+// dashboardFailing lists workloads with fewer ready replicas than desired
+// and the pods behind them that are failing to start
+func (k *K8sToolkit) dashboardFailing(ctx context.Context) []string {
+	var lines []string
+	deployments, err := k.clientset.AppsV1().Deployments(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		return []string{"Failed to list deployments: " + err.Error()}
+	}
+	for _, d := range inScopeItems(k, deployments.Items) {
+		desired := int32(1)
+		if d.Spec.Replicas != nil {
+			desired = *d.Spec.Replicas
+		}
+		if d.Status.ReadyReplicas < desired {
+			lines = append(lines, fmt.Sprintf("Deployment/%s/%s  %d/%d ready", d.Namespace, d.Name, d.Status.ReadyReplicas, desired))
+		}
+	}
+	statefulSets, err := k.clientset.AppsV1().StatefulSets(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		return append(lines, "Failed to list statefulsets: "+err.Error())
+	}
+	for _, s := range inScopeItems(k, statefulSets.Items) {
+		desired := int32(1)
+		if s.Spec.Replicas != nil {
+			desired = *s.Spec.Replicas
+		}
+		if s.Status.ReadyReplicas < desired {
+			lines = append(lines, fmt.Sprintf("StatefulSet/%s/%s  %d/%d ready", s.Namespace, s.Name, s.Status.ReadyReplicas, desired))
+		}
+	}
+	daemonSets, err := k.clientset.AppsV1().DaemonSets(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		return append(lines, "Failed to list daemonsets: "+err.Error())
+	}
+	for _, ds := range inScopeItems(k, daemonSets.Items) {
+		if ds.Status.NumberReady < ds.Status.DesiredNumberScheduled {
+			lines = append(lines, fmt.Sprintf("DaemonSet/%s/%s  %d/%d ready", ds.Namespace, ds.Name, ds.Status.NumberReady, ds.Status.DesiredNumberScheduled))
+		}
+	}
+	sort.Strings(lines)
+
+	issues, _, err := k.podIssues(ctx, k.namespace, &metav1.LabelSelector{})
+	if err != nil {
+		return append(lines, "Failed to list pods: "+err.Error())
+	}
+	if len(issues) > 0 {
+		lines = append(lines, "", "Failing pods:")
+		for _, issue := range issues {
+			lines = append(lines, "  "+issue)
+		}
+	}
+	if len(lines) == 0 {
+		return []string{"No failing workloads"}
+	}
+	return lines
+}
+
+func (m dashboardModel) refresh() tea.Cmd {
+	return func() tea.Msg {
+		ctx, cancel := context.WithTimeout(context.Background(), m.interval)
+		defer cancel()
+		return snapshotMsg(m.toolkit.dashboardSnapshot(ctx))
+	}
+}
+
+func (m dashboardModel) tick() tea.Cmd {
+	return tea.Tick(m.interval, func(t time.Time) tea.Msg { return tickMsg(t) })
+}
+
+func (m dashboardModel) Init() tea.Cmd {
+	return tea.Batch(m.refresh(), m.tick())
+}
+
+// bodyHeight is the number of pane lines that fit between header and footer
+func (m dashboardModel) bodyHeight() int {
+	if m.height <= 4 {
+		return 20
+	}
+	return m.height - 4
+}
+
+func (m dashboardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case snapshotMsg:
+		m.snapshot = dashboardSnapshot(msg)
+		m.loading = false
+	case tickMsg:
+		if m.loading {
+			return m, m.tick()
+		}
+		m.loading = true
+		return m, tea.Batch(m.refresh(), m.tick())
+	case tea.KeyMsg:
+		lines := 0
+		if m.snapshot.panes != nil {
+			lines = len(m.snapshot.panes[m.pane])
+		}
+		maxScroll := lines - m.bodyHeight()
+		if maxScroll < 0 {
+			maxScroll = 0
+		}
+		switch msg.String() {
+		case "q", "ctrl+c", "esc":
+			return m, tea.Quit
+		case "tab", "right", "l":
+			m.pane = (m.pane + 1) % len(dashboardPanes)
+		case "shift+tab", "left", "h":
+			m.pane = (m.pane + len(dashboardPanes) - 1) % len(dashboardPanes)
+		case "1", "2", "3", "4", "5":
+			m.pane = int(msg.String()[0] - '1')
+		case "down", "j":
+			m.scroll[m.pane] = min(m.scroll[m.pane]+1, maxScroll)
+		case "up", "k":
+			m.scroll[m.pane] = max(m.scroll[m.pane]-1, 0)
+		case "pgdown", " ":
+			m.scroll[m.pane] = min(m.scroll[m.pane]+m.bodyHeight(), maxScroll)
+		case "pgup":
+			m.scroll[m.pane] = max(m.scroll[m.pane]-m.bodyHeight(), 0)
+		case "g", "home":
+			m.scroll[m.pane] = 0
+		case "G", "end":
+			m.scroll[m.pane] = maxScroll
+		case "r":
+			if !m.loading {
+				m.loading = true
+				return m, m.refresh()
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m dashboardModel) View() string {
+	var b strings.Builder
+	for i, name := range dashboardPanes {
+		label := fmt.Sprintf(" %d %s ", i+1, name)
+		if i == m.pane {
+			label = "\033[7m" + label + "\033[0m"
+		}
+		b.WriteString(label)
+	}
+	status := "loading…"
+	if !m.snapshot.taken.IsZero() {
+		status = "updated " + m.snapshot.taken.Format("15:04:05")
+		if m.loading {
+			status += ", refreshing…"
+		}
+	}
+	b.WriteString("  " + status + "\n\n")
+
+	if m.snapshot.panes != nil {
+		lines := m.snapshot.panes[m.pane]
+		start := min(m.scroll[m.pane], len(lines))
+		end := min(start+m.bodyHeight(), len(lines))
+		for _, line := range lines[start:end] {
+			if m.width > 0 && len(line) > m.width {
+				line = line[:m.width]
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+
+	b.WriteString("\n\033[2mtab/1-5 switch pane · ↑↓/jk scroll · r refresh · q quit\033[0m")
+	return b.String()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// createDashboardCmd creates the dashboard command
+func createDashboardCmd() *cobra.Command {
+	var interval time.Duration
+
+	dashboardCmd := &cobra.Command{
+		Use:   "dashboard",
+		Short: "Interactive terminal dashboard for health triage",
+		Long: `Opens a full-screen dashboard with panes for health check results, node and pod usage,
+recent warning events and failing workloads, refreshed every --interval. Switch panes with tab
+or 1-5, scroll with the arrow keys or j/k, refresh with r and quit with q.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			if viper.GetBool("use_cache") {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				if err := toolkit.enableCache(ctx); err != nil {
+					logger.Fatalf("Failed to start cache: %v", err)
+				}
+			}
+
+			// Warnings from the refresh goroutine would corrupt the screen
+			logger.SetOutput(io.Discard)
+			defer logger.SetOutput(os.Stderr)
+
+			model := dashboardModel{
+				toolkit:  toolkit,
+				interval: interval,
+				scroll:   make([]int, len(dashboardPanes)),
+				loading:  true,
+			}
+			if _, err := tea.NewProgram(model, tea.WithAltScreen()).Run(); err != nil {
+				logger.SetOutput(os.Stderr)
+				logger.Fatalf("Dashboard failed: %v", err)
+			}
+		},
+	}
+
+	dashboardCmd.Flags().DurationVar(&interval, "interval", 15*time.Second, "Refresh interval")
+
+	return dashboardCmd
+}
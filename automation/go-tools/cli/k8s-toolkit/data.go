@@ -0,0 +1,175 @@
+package main
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed data/*.yaml
+var bundledData embed.FS
+
+// dataBundles are the data files shipped with the binary. A copy in the data
+// directory, written by data update, takes precedence over the bundled one.
+var dataBundles = []string{"deprecations.yaml", "cis.yaml"}
+
+// offline reports whether calls to external services are disabled
+func offline() bool {
+	return viper.GetBool("offline")
+}
+
+// dataDir returns the directory holding refreshed data bundles
+func dataDir() (string, error) {
+	if dir := viper.GetString("data.dir"); dir != "" {
+		return dir, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "k8s-toolkit", "data"), nil
+}
+
+// loadDataBundle returns the contents of a data bundle and where it was read from
+func loadDataBundle(name string) ([]byte, string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, "", err
+	}
+	path := filepath.Join(dir, name)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return data, path, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	data, err = bundledData.ReadFile("data/" + name)
+	if err != nil {
+		return nil, "", fmt.Errorf("no bundled data file %s: %w", name, err)
+	}
+	return data, "bundled", nil
+}
+
+// fetchDataBundle reads a bundle from a mirror, which is either an HTTP(S)
+// base URL or a local directory such as a mounted transfer volume
+func fetchDataBundle(mirror, name string) ([]byte, error) {
+	if !strings.HasPrefix(mirror, "http://") && !strings.HasPrefix(mirror, "https://") {
+		return os.ReadFile(filepath.Join(mirror, name))
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(mirror, "/") + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// UpdateDataBundles copies every bundle from mirror into the data directory.
+// Each file must parse as YAML before it replaces the current copy.
+func UpdateDataBundles(mirror string) (map[string]string, error) {
+	dir, err := dataDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	checksums := make(map[string]string)
+	for _, name := range dataBundles {
+		data, err := fetchDataBundle(mirror, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %w", name, err)
+		}
+		var parsed interface{}
+		if err := yaml.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("mirror copy of %s is invalid: %w", name, err)
+		}
+
+		tmp := filepath.Join(dir, "."+name+".tmp")
+		if err := os.WriteFile(tmp, data, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", name, err)
+		}
+		if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+			return nil, fmt.Errorf("failed to replace %s: %w", name, err)
+		}
+		checksums[name] = checksum(data)
+	}
+	return checksums, nil
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// createDataCmd creates the data command
+func createDataCmd() *cobra.Command {
+	dataCmd := &cobra.Command{
+		Use:   "data",
+		Short: "Manage the data files used for offline operation",
+		Long: `Data bundles (deprecation tables and similar reference data) ship with the binary. In an
+air-gapped environment they can be refreshed from an internal mirror with data update.`,
+	}
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show where each data bundle is read from",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Printf("%-25s %-14s %s\n", "BUNDLE", "SHA256", "SOURCE")
+			for _, name := range dataBundles {
+				data, source, err := loadDataBundle(name)
+				if err != nil {
+					logger.Fatalf("Failed to load %s: %v", name, err)
+				}
+				fmt.Printf("%-25s %-14s %s\n", name, checksum(data)[:12], source)
+			}
+		},
+	}
+
+	updateCmd := &cobra.Command{
+		Use:   "update",
+		Short: "Refresh data bundles from a mirror",
+		Long:  `Downloads every data bundle from an HTTP(S) mirror or copies it from a local directory into the data directory, where it overrides the bundled copy.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			mirror := viper.GetString("data.mirror")
+			if mirror == "" {
+				logger.Fatalf("No mirror configured: use --mirror or set data.mirror")
+			}
+
+			checksums, err := UpdateDataBundles(mirror)
+			if err != nil {
+				logger.Fatalf("Failed to update data: %v", err)
+			}
+			for _, name := range dataBundles {
+				fmt.Printf("Updated %s (sha256 %s)\n", name, checksums[name])
+			}
+		},
+	}
+	updateCmd.Flags().String("mirror", "", "HTTP(S) base URL or directory to copy data bundles from")
+	viper.BindPFlag("data.mirror", updateCmd.Flags().Lookup("mirror"))
+
+	dataCmd.AddCommand(statusCmd)
+	dataCmd.AddCommand(updateCmd)
+	return dataCmd
+}
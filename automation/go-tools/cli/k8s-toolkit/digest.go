@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// teamConfig is a team entry under teams in the config file
+type teamConfig struct {
+	Namespaces []string `mapstructure:"namespaces"`
+	Webhook    string   `mapstructure:"webhook"`
+}
+
+// TeamDigest is the weekly digest of one team
+type TeamDigest struct {
+	Team        string            `json:"team"`
+	Week        string            `json:"week"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Namespaces  []string          `json:"namespaces"`
+	Health      *ClusterHealth    `json:"health,omitempty"`
+	Sources     map[string]string `json:"sources"`
+	Counts      map[string]int    `json:"counts"`
+	Findings    []Finding         `json:"findings"`
+}
+
+// teamNamespaces maps each team to the namespaces it owns: those listed
+// under teams.<team>.namespaces in the config file, plus namespaces labeled
+// with digest.team_label
+func (k *K8sToolkit) teamNamespaces(ctx context.Context) (map[string][]string, error) {
+	owned := make(map[string]map[string]bool)
+	own := func(team, namespace string) {
+		if owned[team] == nil {
+			owned[team] = make(map[string]bool)
+		}
+		owned[team][namespace] = true
+	}
+
+	var teams map[string]teamConfig
+	if err := viper.UnmarshalKey("teams", &teams); err != nil {
+		return nil, fmt.Errorf("invalid teams config: %w", err)
+	}
+	for team, config := range teams {
+		for _, namespace := range config.Namespaces {
+			own(team, namespace)
+		}
+	}
+
+	if label := viper.GetString("digest.team_label"); label != "" {
+		namespaces, err := k.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: label})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, ns := range namespaces.Items {
+			if team := ns.Labels[label]; team != "" {
+				own(team, ns.Name)
+			}
+		}
+	}
+
+	result := make(map[string][]string)
+	for team, namespaces := range owned {
+		for namespace := range namespaces {
+			result[team] = append(result[team], namespace)
+		}
+		sort.Strings(result[team])
+	}
+	return result, nil
+}
+
+// BuildDigests runs the health checks and finding sources once and splits
+// the findings by owning team. Findings outside any team's namespaces are
+// left out; the cluster health summary is shared by every digest.
+func (k *K8sToolkit) BuildDigests(ctx context.Context, sources []string, opts FindingSourceOptions) ([]TeamDigest, error) {
+	teams, err := k.teamNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(teams) == 0 {
+		return nil, fmt.Errorf("no teams found: configure teams in the config file or label namespaces with %q", viper.GetString("digest.team_label"))
+	}
+
+	now := time.Now()
+	year, week := now.ISOWeek()
+	health, err := k.RunHealthCheck(ctx)
+	if err != nil {
+		k.log().Warnf("health check failed: %v", err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range sources {
+		wanted[name] = true
+	}
+	status := make(map[string]string)
+	var findings []Finding
+	for _, source := range k.findingSources(opts) {
+		if len(wanted) > 0 && !wanted[source.name] {
+			continue
+		}
+		sourceFindings, err := source.run(ctx)
+		if err != nil {
+			status[source.name] = fmt.Sprintf("error (%s): %v", errorCategory(err), err)
+			continue
+		}
+		status[source.name] = fmt.Sprintf("ok (%d findings)", len(sourceFindings))
+		findings = append(findings, sourceFindings...)
+	}
+	sortFindings(findings)
+
+	owner := make(map[string]string)
+	for team, namespaces := range teams {
+		for _, namespace := range namespaces {
+			owner[namespace] = team
+		}
+	}
+
+	var digests []TeamDigest
+	for team, namespaces := range teams {
+		digest := TeamDigest{
+			Team:        team,
+			Week:        fmt.Sprintf("%d-W%02d", year, week),
+			GeneratedAt: now,
+			Namespaces:  namespaces,
+			Health:      health,
+			Sources:     status,
+			Counts:      make(map[string]int),
+		}
+		for _, f := range findings {
+			if f.Namespace != "" && owner[f.Namespace] == team {
+				digest.Findings = append(digest.Findings, f)
+				digest.Counts[f.Severity]++
+			}
+		}
+		digests = append(digests, digest)
+	}
+	sort.Slice(digests, func(i, j int) bool { return digests[i].Team < digests[j].Team })
+	return digests, nil
+}
+
+// WriteDigest renders a digest as Markdown and HTML into dir and returns the
+// Markdown text
+func WriteDigest(digest *TeamDigest, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	base := filepath.Join(dir, digest.Team+"-"+digest.Week)
+
+	var markdown bytes.Buffer
+	if err := renderText(&markdown, "digest.md.tmpl", digest); err != nil {
+		return "", fmt.Errorf("failed to render digest: %w", err)
+	}
+	if err := os.WriteFile(base+".md", markdown.Bytes(), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %s.md: %w", base, err)
+	}
+
+	htmlFile, err := os.Create(base + ".html")
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s.html: %w", base, err)
+	}
+	defer htmlFile.Close()
+	if err := renderHTML(htmlFile, "digest.html.tmpl", digest); err != nil {
+		return "", fmt.Errorf("failed to render %s.html: %w", base, err)
+	}
+	return markdown.String(), nil
+}
+
+// createDigestCmd creates the digest command
+func createDigestCmd() *cobra.Command {
+	var outputDir string
+	var sources []string
+	var interval time.Duration
+	var schedule string
+	var sourceOpts FindingSourceOptions
+
+	digestCmd := &cobra.Command{
+		Use:   "digest",
+		Short: "Generate a weekly digest per team",
+		Long: `Runs the health checks and audits once, splits the findings by owning team and writes a
+Markdown and HTML digest per team. Teams own the namespaces listed under teams.<team>.namespaces
+in the config file and the namespaces labeled with --team-label. Digests are posted to
+teams.<team>.webhook when set. With --interval the digest is regenerated on that schedule.
+
+With --schedule it runs as a daemon on a cron schedule, e.g. "0 8 * * MON", and also mails the
+digests to reports.email.recipients over notifications.smtp: the HTML digest inline with the JSON
+attached. A recipient only gets the findings in the namespaces of the teams listed for them, or
+every team's when none are listed:
+
+  notifications:
+    smtp: {host: smtp.example.com, port: 587, username: reports, password: "env:SMTP_PASSWORD", from: k8s-toolkit@example.com}
+  reports:
+    email:
+      recipients:
+        - to: [payments-lead@example.com]
+          teams: [payments]
+        - to: [engineering-managers@example.com]`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if schedule != "" && interval > 0 {
+				logger.Fatalf("--schedule and --interval are mutually exclusive")
+			}
+			var cronSchedule cron.Schedule
+			var mailer *Mailer
+			if schedule != "" {
+				var err error
+				if cronSchedule, err = cron.ParseStandard(schedule); err != nil {
+					logger.Fatalf("Invalid --schedule %q: %v", schedule, err)
+				}
+				if mailer, err = NewMailer(); err != nil {
+					logger.Fatalf("Failed to configure report mails: %v", err)
+				}
+			}
+
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			// wait sleeps until the next run and reports whether to run
+			wait := func() bool {
+				delay := interval
+				if cronSchedule != nil {
+					delay = time.Until(cronSchedule.Next(time.Now()))
+				}
+				select {
+				case <-ctx.Done():
+					return false
+				case <-time.After(delay):
+					return true
+				}
+			}
+			if cronSchedule != nil && !wait() {
+				return
+			}
+
+			for {
+				digests, err := toolkit.BuildDigests(ctx, sources, sourceOpts)
+				if err != nil {
+					logger.Fatalf("Failed to build digests: %v", err)
+				}
+
+				for i := range digests {
+					markdown, err := WriteDigest(&digests[i], outputDir)
+					if err != nil {
+						logger.Fatalf("Failed to write digest: %v", err)
+					}
+					fmt.Printf("Digest for %s written to %s (%d findings)\n", digests[i].Team, outputDir, len(digests[i].Findings))
+
+					webhook := viper.GetString("teams." + digests[i].Team + ".webhook")
+					if webhook == "" || offline() {
+						continue
+					}
+					if err := postWebhook(ctx, webhook, map[string]interface{}{"text": markdown}); err != nil {
+						logger.Warnf("failed to deliver digest for %s: %v", digests[i].Team, err)
+					}
+				}
+				if mailer != nil {
+					mailer.Deliver(ctx, digests)
+				}
+
+				if (interval <= 0 && cronSchedule == nil) || !wait() {
+					return
+				}
+			}
+		},
+	}
+
+	digestCmd.Flags().StringVar(&outputDir, "output-dir", "digests", "Directory to write the digests into")
+	digestCmd.Flags().StringSliceVar(&sources, "sources", []string{"security-pods", "security-images", "security-netpol", "security-serviceaccounts", "availability"}, "Finding sources to include (empty for all)")
+	addFindingSourceFlags(digestCmd, &sourceOpts)
+	digestCmd.Flags().DurationVar(&interval, "interval", 0, "Regenerate and deliver the digests on this interval, e.g. 168h")
+	digestCmd.Flags().StringVar(&schedule, "schedule", "", "Run as a daemon on this cron schedule and mail the digests to reports.email.recipients")
+	digestCmd.Flags().String("team-label", "team", "Namespace label naming the owning team")
+	viper.BindPFlag("digest.team_label", digestCmd.Flags().Lookup("team-label"))
+
+	return digestCmd
+}
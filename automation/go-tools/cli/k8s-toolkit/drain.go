@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// mirrorPodAnnotation marks static pods managed by the kubelet, which cannot be evicted
+const mirrorPodAnnotation = "kubernetes.io/config.mirror"
+
+// DrainOptions controls how nodes are drained
+type DrainOptions struct {
+	Timeout     time.Duration
+	Force       bool
+	DryRun      bool
+	VerifyDelay time.Duration
+	SkipHealth  bool
+}
+
+// drainPod is a pod on a node being drained and whether it is evicted
+type drainPod struct {
+	pod     corev1.Pod
+	skip    string
+	blocker string
+}
+
+// planDrain sorts the pods on a node into those to evict, those skipped
+// (DaemonSet and mirror pods, finished pods) and those that block the drain
+// unless forced: pods without a controller and pods with emptyDir volumes,
+// whose data is lost on eviction
+func (k *K8sToolkit) planDrain(ctx context.Context, node string) ([]drainPod, error) {
+	pods, err := k.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "spec.nodeName=" + node})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods on %s: %w", node, err)
+	}
+
+	var plan []drainPod
+	for _, pod := range pods.Items {
+		dp := drainPod{pod: pod}
+		owner := metav1.GetControllerOf(&pod)
+		switch {
+		case pod.Annotations[mirrorPodAnnotation] != "":
+			dp.skip = "mirror pod"
+		case owner != nil && owner.Kind == "DaemonSet":
+			dp.skip = "DaemonSet pod"
+		case pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed:
+			dp.skip = "finished"
+		case owner == nil:
+			dp.blocker = "no controller, it will not be recreated"
+		}
+		if dp.skip == "" && dp.blocker == "" {
+			for _, volume := range pod.Spec.Volumes {
+				if volume.EmptyDir != nil {
+					dp.blocker = fmt.Sprintf("local storage in emptyDir volume %q will be lost", volume.Name)
+					break
+				}
+			}
+		}
+		plan = append(plan, dp)
+	}
+	return plan, nil
+}
+
+// setUnschedulable cordons or uncordons a node
+func (k *K8sToolkit) setUnschedulable(ctx context.Context, node string, unschedulable bool) error {
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable)
+	_, err := k.clientset.CoreV1().Nodes().Patch(ctx, node, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	return err
+}
+
+// evictPod evicts a pod through the eviction API, retrying while a
+// PodDisruptionBudget refuses the eviction, until ctx expires
+func (k *K8sToolkit) evictPod(ctx context.Context, pod *corev1.Pod) error {
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+	}
+	for {
+		err := k.clientset.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+		switch {
+		case err == nil, apierrors.IsNotFound(err):
+			return nil
+		case !apierrors.IsTooManyRequests(err):
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("eviction blocked by a PodDisruptionBudget: %w", err)
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// waitForPodsGone waits until the evicted pods are deleted or replaced by
+// new pods with the same name
+func (k *K8sToolkit) waitForPodsGone(ctx context.Context, pods []corev1.Pod) error {
+	for _, pod := range pods {
+		for {
+			current, err := k.clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) || (err == nil && current.UID != pod.UID) {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("pod %s/%s was not deleted in time", pod.Namespace, pod.Name)
+			case <-time.After(2 * time.Second):
+			}
+		}
+	}
+	return nil
+}
+
+// DrainNode cordons a node and evicts its pods, respecting
+// PodDisruptionBudgets. Pods that would lose data or not be recreated stop
+// the drain before anything changes unless opts.Force is set.
+func (k *K8sToolkit) DrainNode(ctx context.Context, m *mutation, node string, opts DrainOptions) error {
+	plan, err := k.planDrain(ctx, node)
+	if err != nil {
+		return err
+	}
+
+	var evict []corev1.Pod
+	var blocked []string
+	for _, dp := range plan {
+		target := objectRef{Kind: "Pod", Namespace: dp.pod.Namespace, Name: dp.pod.Name}.String()
+		switch {
+		case dp.skip != "":
+			fmt.Printf("  skip   %s (%s)\n", target, dp.skip)
+			continue
+		case dp.blocker != "" && !opts.Force:
+			blocked = append(blocked, fmt.Sprintf("%s: %s", target, dp.blocker))
+			continue
+		case dp.blocker != "":
+			fmt.Printf("  force  %s (%s)\n", target, dp.blocker)
+		default:
+			fmt.Printf("  evict  %s\n", target)
+		}
+		evict = append(evict, dp.pod)
+	}
+	if len(blocked) > 0 {
+		for _, b := range blocked {
+			fmt.Printf("  block  %s\n", b)
+		}
+		return fmt.Errorf("%d pods on %s block the drain; re-run with --force to evict them", len(blocked), node)
+	}
+	if opts.DryRun {
+		fmt.Printf("Dry run: %s would be cordoned and %d pods evicted\n", node, len(evict))
+		return nil
+	}
+
+	err = k.setUnschedulable(ctx, node, true)
+	m.record("cordon", "Node/"+node, err)
+	if err != nil {
+		return fmt.Errorf("failed to cordon %s: %w", node, err)
+	}
+	fmt.Printf("Cordoned %s\n", node)
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	for i := range evict {
+		pod := &evict[i]
+		target := objectRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}.String()
+		err := k.evictPod(ctx, pod)
+		m.record("evict", target, err)
+		if err != nil {
+			return fmt.Errorf("failed to evict %s: %w", target, err)
+		}
+		fmt.Printf("  [%d/%d] evicted %s\n", i+1, len(evict), target)
+	}
+
+	if err := k.waitForPodsGone(ctx, evict); err != nil {
+		return err
+	}
+	fmt.Printf("Drained %s\n", node)
+	return nil
+}
+
+// verifyClusterHealth runs the health checks after a node is drained and
+// fails when any check is Critical
+func (k *K8sToolkit) verifyClusterHealth(ctx context.Context) error {
+	health, err := k.RunHealthCheck(ctx)
+	if err != nil {
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	for _, check := range health.Checks {
+		if check.Status == "Critical" {
+			return fmt.Errorf("%s is Critical: %s", check.Component, check.Message)
+		}
+	}
+	fmt.Printf("Cluster health: %s\n", health.OverallStatus)
+	return nil
+}
+
+// createNodeCmd creates the node command
+func createNodeCmd() *cobra.Command {
+	nodeCmd := &cobra.Command{
+		Use:   "node",
+		Short: "Node maintenance operations",
+	}
+
+	var opts DrainOptions
+	drainCmd := &cobra.Command{
+		Use:   "drain <node> [node...]",
+		Short: "Cordon nodes and evict their pods",
+		Long: `Cordons each node and evicts its pods through the eviction API, so PodDisruptionBudgets are
+respected; evictions refused by a budget are retried until --timeout. DaemonSet and mirror pods are
+left in place. Pods without a controller or with emptyDir volumes stop the drain before the node is
+cordoned unless --force is given. Several nodes are drained one after another, waiting
+--verify-delay and requiring no Critical health check before moving on to the next node.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			var m *mutation
+			if !opts.DryRun {
+				m, err = beginMutation("node drain", fmt.Sprintf("About to cordon and drain %d nodes: %v", len(args), args))
+				if err != nil {
+					logger.Fatalf("Drain aborted: %v", err)
+				}
+			}
+
+			for i, node := range args {
+				fmt.Printf("Draining %s (%d/%d)\n", node, i+1, len(args))
+				if err := toolkit.DrainNode(ctx, m, node, opts); err != nil {
+					logger.Fatalf("Failed to drain %s: %v", node, err)
+				}
+				if opts.DryRun || opts.SkipHealth || i == len(args)-1 {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					logger.Fatalf("Drain interrupted after %s", node)
+				case <-time.After(opts.VerifyDelay):
+				}
+				if err := toolkit.verifyClusterHealth(ctx); err != nil {
+					logger.Fatalf("Stopping before the next node: %v", err)
+				}
+			}
+		},
+	}
+
+	drainCmd.Flags().DurationVar(&opts.Timeout, "timeout", 5*time.Minute, "Maximum time to evict all pods of one node")
+	drainCmd.Flags().BoolVar(&opts.Force, "force", false, "Also evict pods without a controller or with emptyDir volumes")
+	drainCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Show which pods would be evicted without changing anything")
+	drainCmd.Flags().DurationVar(&opts.VerifyDelay, "verify-delay", 30*time.Second, "Time to let workloads settle before the health check between nodes")
+	drainCmd.Flags().BoolVar(&opts.SkipHealth, "skip-health-check", false, "Do not verify cluster health between nodes")
+
+	nodeCmd.AddCommand(drainCmd)
+	return nodeCmd
+}
@@ -0,0 +1,455 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+// driftFieldManager is the field manager used for server-side dry-run applies
+const driftFieldManager = "k8s-toolkit"
+
+// FieldChange is one field that differs between the live and desired object
+type FieldChange struct {
+	Path    string      `json:"path"`
+	Live    interface{} `json:"live,omitempty"`
+	Desired interface{} `json:"desired,omitempty"`
+}
+
+// ResourceDrift is the difference for one manifest object
+type ResourceDrift struct {
+	Kind      string        `json:"kind"`
+	Namespace string        `json:"namespace,omitempty"`
+	Name      string        `json:"name"`
+	Source    string        `json:"source"`
+	Status    string        `json:"status"` // missing, changed, unchanged or error
+	Error     string        `json:"error,omitempty"`
+	Added     []FieldChange `json:"added,omitempty"`
+	Changed   []FieldChange `json:"changed,omitempty"`
+	Removed   []FieldChange `json:"removed,omitempty"`
+}
+
+// DriftReport is the result of comparing manifests with the cluster
+type DriftReport struct {
+	Resources []ResourceDrift `json:"resources"`
+	Missing   int             `json:"missing"`
+	Changed   int             `json:"changed"`
+	Unchanged int             `json:"unchanged"`
+	Errors    int             `json:"errors"`
+}
+
+// HasDrift reports whether any manifest differs from the cluster
+func (r *DriftReport) HasDrift() bool {
+	return r.Missing > 0 || r.Changed > 0
+}
+
+// manifestObject is an object decoded from a manifest file
+type manifestObject struct {
+	source string
+	obj    *unstructured.Unstructured
+}
+
+// loadManifestDir decodes every object in the YAML and JSON files under dir
+func loadManifestDir(dir string) ([]manifestObject, error) {
+	var objects []manifestObject
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !isManifestFile(path) {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		decoded, err := decodeManifests(path, data)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, decoded...)
+		return nil
+	})
+	return objects, err
+}
+
+// loadManifestRef decodes the manifests under dir as committed at ref in the
+// git repository containing dir
+func loadManifestRef(dir, ref string) ([]manifestObject, error) {
+	repo, err := git.PlainOpenWithOptions(dir, &git.PlainOpenOptions{DetectDotGit: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open git repository: %w", err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worktree: %w", err)
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix, err := filepath.Rel(worktree.Filesystem.Root(), abs)
+	if err != nil {
+		return nil, err
+	}
+	prefix = filepath.ToSlash(prefix)
+
+	hash, err := repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", ref, err)
+	}
+	commit, err := repo.CommitObject(*hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load commit %s: %w", hash, err)
+	}
+	files, err := commit.Files()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files of %s: %w", ref, err)
+	}
+
+	var objects []manifestObject
+	err = files.ForEach(func(f *object.File) error {
+		if (prefix != "." && !strings.HasPrefix(f.Name, prefix+"/")) || !isManifestFile(f.Name) {
+			return nil
+		}
+		contents, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		decoded, err := decodeManifests(ref+":"+f.Name, []byte(contents))
+		if err != nil {
+			return err
+		}
+		objects = append(objects, decoded...)
+		return nil
+	})
+	return objects, err
+}
+
+func isManifestFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// decodeManifests decodes the objects in a multi-document YAML or JSON file,
+// expanding List objects
+func decodeManifests(source string, data []byte) ([]manifestObject, error) {
+	var objects []manifestObject
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		var content map[string]interface{}
+		if err := decoder.Decode(&content); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+		}
+		if len(content) == 0 {
+			continue
+		}
+		obj := &unstructured.Unstructured{Object: content}
+		if obj.IsList() {
+			err := obj.EachListItem(func(item runtime.Object) error {
+				objects = append(objects, manifestObject{source: source, obj: item.(*unstructured.Unstructured)})
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", source, err)
+			}
+			continue
+		}
+		if obj.GetKind() == "" || obj.GetName() == "" {
+			return nil, fmt.Errorf("%s: object without kind or name", source)
+		}
+		objects = append(objects, manifestObject{source: source, obj: obj})
+	}
+}
+
+// DiffManifests server-side dry-run applies each manifest and compares the
+// result with the live object, so defaulting and admission mutations do not
+// show up as drift
+func (k *K8sToolkit) DiffManifests(ctx context.Context, objects []manifestObject) (*DriftReport, error) {
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(k.clientset.Discovery()))
+	report := &DriftReport{}
+
+	for _, m := range objects {
+		drift := ResourceDrift{Kind: m.obj.GetKind(), Name: m.obj.GetName(), Source: m.source}
+
+		gvk := m.obj.GroupVersionKind()
+		mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+		if err != nil {
+			drift.Status = "error"
+			drift.Error = fmt.Sprintf("unknown kind %s: %v", gvk, err)
+			report.add(drift)
+			continue
+		}
+
+		resource := k.dynamicClient.Resource(mapping.Resource)
+		var client dynamic.ResourceInterface = resource
+		if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			namespace := m.obj.GetNamespace()
+			if namespace == "" {
+				namespace = k.namespace
+			}
+			if namespace == "" {
+				namespace = "default"
+			}
+			m.obj.SetNamespace(namespace)
+			drift.Namespace = namespace
+			client = resource.Namespace(namespace)
+		}
+
+		live, err := client.Get(ctx, m.obj.GetName(), metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			drift.Status = "missing"
+			report.add(drift)
+			continue
+		}
+		if err != nil {
+			drift.Status = "error"
+			drift.Error = classifyError(err).Error()
+			report.add(drift)
+			continue
+		}
+
+		data, err := json.Marshal(m.obj.Object)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", m.source, err)
+		}
+		force := true
+		desired, err := client.Patch(ctx, m.obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+			DryRun:       []string{metav1.DryRunAll},
+			FieldManager: driftFieldManager,
+			Force:        &force,
+		})
+		if err != nil {
+			drift.Status = "error"
+			drift.Error = fmt.Sprintf("dry-run apply failed: %v", classifyError(err))
+			report.add(drift)
+			continue
+		}
+
+		liveFields := flattenFields(normalizeForDiff(live.Object))
+		desiredFields := flattenFields(normalizeForDiff(desired.Object))
+		for path, value := range desiredFields {
+			liveValue, ok := liveFields[path]
+			switch {
+			case !ok:
+				drift.Added = append(drift.Added, FieldChange{Path: path, Desired: value})
+			case !reflect.DeepEqual(liveValue, value):
+				drift.Changed = append(drift.Changed, FieldChange{Path: path, Live: liveValue, Desired: value})
+			}
+		}
+		for path, value := range liveFields {
+			if _, ok := desiredFields[path]; !ok {
+				drift.Removed = append(drift.Removed, FieldChange{Path: path, Live: value})
+			}
+		}
+		for _, changes := range [][]FieldChange{drift.Added, drift.Changed, drift.Removed} {
+			sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+		}
+
+		drift.Status = "unchanged"
+		if len(drift.Added)+len(drift.Changed)+len(drift.Removed) > 0 {
+			drift.Status = "changed"
+		}
+		report.add(drift)
+	}
+
+	sort.SliceStable(report.Resources, func(i, j int) bool {
+		a, b := report.Resources[i], report.Resources[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+func (r *DriftReport) add(drift ResourceDrift) {
+	switch drift.Status {
+	case "missing":
+		r.Missing++
+	case "changed":
+		r.Changed++
+	case "unchanged":
+		r.Unchanged++
+	default:
+		r.Errors++
+	}
+	r.Resources = append(r.Resources, drift)
+}
+
+// normalizeForDiff drops fields that change on every write or are owned by
+// controllers rather than manifests
+func normalizeForDiff(obj map[string]interface{}) map[string]interface{} {
+	obj = runtime.DeepCopyJSON(obj)
+	delete(obj, "status")
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		for _, field := range []string{"managedFields", "resourceVersion", "generation", "uid", "creationTimestamp", "selfLink"} {
+			delete(metadata, field)
+		}
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok {
+			delete(annotations, "kubectl.kubernetes.io/last-applied-configuration")
+			delete(annotations, "deployment.kubernetes.io/revision")
+		}
+	}
+	return obj
+}
+
+// flattenFields maps the dotted path of every leaf field to its value. List
+// items are addressed by index, or by name when every item has one.
+func flattenFields(obj map[string]interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if len(v) == 0 {
+				fields[prefix] = v
+				return
+			}
+			for key, child := range v {
+				path := key
+				if prefix != "" {
+					path = prefix + "." + key
+				}
+				walk(path, child)
+			}
+		case []interface{}:
+			if len(v) == 0 {
+				fields[prefix] = v
+				return
+			}
+			named := true
+			for _, item := range v {
+				if m, ok := item.(map[string]interface{}); !ok || m["name"] == nil {
+					named = false
+					break
+				}
+			}
+			for i, item := range v {
+				if named {
+					walk(fmt.Sprintf("%s[%v]", prefix, item.(map[string]interface{})["name"]), item)
+				} else {
+					walk(fmt.Sprintf("%s[%d]", prefix, i), item)
+				}
+			}
+		default:
+			fields[prefix] = v
+		}
+	}
+	walk("", obj)
+	return fields
+}
+
+// PrintDriftReport prints the per-resource drift summary
+func (k *K8sToolkit) PrintDriftReport(report *DriftReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Println("Manifest Drift Report")
+	fmt.Println("=====================================")
+	fmt.Printf("Missing: %d  Changed: %d  Unchanged: %d  Errors: %d\n\n", report.Missing, report.Changed, report.Unchanged, report.Errors)
+
+	for _, r := range report.Resources {
+		if r.Status == "unchanged" {
+			continue
+		}
+		fmt.Printf("%-9s %s (%s)\n", r.Status, objectRef{Kind: r.Kind, Namespace: r.Namespace, Name: r.Name}, r.Source)
+		if r.Error != "" {
+			fmt.Printf("    %s\n", r.Error)
+		}
+		for _, c := range r.Added {
+			fmt.Printf("    + %s: %v\n", c.Path, c.Desired)
+		}
+		for _, c := range r.Changed {
+			fmt.Printf("    ~ %s: %v -> %v\n", c.Path, c.Live, c.Desired)
+		}
+		for _, c := range r.Removed {
+			fmt.Printf("    - %s: %v\n", c.Path, c.Live)
+		}
+	}
+}
+
+// createDiffCmd creates the diff command
+func createDiffCmd() *cobra.Command {
+	var path string
+	var ref string
+	var failOnDrift bool
+
+	diffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare local manifests with the live cluster",
+		Long: `Loads YAML and JSON manifests from --path (or from --path as committed at --ref in its git
+repository), server-side dry-run applies each one and reports the fields that would be added,
+changed or removed per resource. Dry runs are allowed in --read-only mode.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			var objects []manifestObject
+			var err error
+			if ref != "" {
+				objects, err = loadManifestRef(path, ref)
+			} else {
+				objects, err = loadManifestDir(path)
+			}
+			if err != nil {
+				logger.Fatalf("Failed to load manifests: %v", err)
+			}
+
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			report, err := toolkit.DiffManifests(context.Background(), objects)
+			if err != nil {
+				logger.Fatalf("Failed to diff manifests: %v", err)
+			}
+
+			toolkit.PrintDriftReport(report)
+			if report.Errors > 0 || failOnDrift && report.HasDrift() {
+				os.Exit(1)
+			}
+		},
+	}
+
+	diffCmd.Flags().StringVar(&path, "path", ".", "Directory of manifests")
+	diffCmd.Flags().StringVar(&ref, "ref", "", "Git ref to read the manifests from instead of the working tree")
+	diffCmd.Flags().BoolVar(&failOnDrift, "fail-on-drift", false, "Exit non-zero when any resource is missing or changed")
+
+	return diffCmd
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// ErrorCategory is a machine-readable reason a check or source could not complete
+type ErrorCategory string
+
+const (
+	CategoryAuth        ErrorCategory = "auth"
+	CategoryNotFound    ErrorCategory = "not_found"
+	CategoryThrottled   ErrorCategory = "throttled"
+	CategoryTimeout     ErrorCategory = "timeout"
+	CategoryUnreachable ErrorCategory = "unreachable"
+	CategoryMemoryLimit ErrorCategory = "memory_limit"
+	CategoryConfig      ErrorCategory = "config"
+	CategoryInternal    ErrorCategory = "internal"
+	CategoryUnknown     ErrorCategory = "unknown"
+)
+
+// categorizedError is implemented by the typed errors below
+type categorizedError interface {
+	error
+	Category() ErrorCategory
+}
+
+// AuthError means the credentials were rejected or lack the required permissions
+type AuthError struct{ Err error }
+
+func (e *AuthError) Error() string           { return e.Err.Error() }
+func (e *AuthError) Unwrap() error           { return e.Err }
+func (e *AuthError) Category() ErrorCategory { return CategoryAuth }
+
+// NotFoundError means a requested object or API group does not exist
+type NotFoundError struct{ Err error }
+
+func (e *NotFoundError) Error() string           { return e.Err.Error() }
+func (e *NotFoundError) Unwrap() error           { return e.Err }
+func (e *NotFoundError) Category() ErrorCategory { return CategoryNotFound }
+
+// ThrottledError means the API server rejected the request with 429
+type ThrottledError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string           { return e.Err.Error() }
+func (e *ThrottledError) Unwrap() error           { return e.Err }
+func (e *ThrottledError) Category() ErrorCategory { return CategoryThrottled }
+
+// TimeoutError means a deadline passed before the request completed
+type TimeoutError struct{ Err error }
+
+func (e *TimeoutError) Error() string           { return e.Err.Error() }
+func (e *TimeoutError) Unwrap() error           { return e.Err }
+func (e *TimeoutError) Category() ErrorCategory { return CategoryTimeout }
+
+// UnreachableError means the API server could not be contacted at all
+type UnreachableError struct{ Err error }
+
+func (e *UnreachableError) Error() string           { return e.Err.Error() }
+func (e *UnreachableError) Unwrap() error           { return e.Err }
+func (e *UnreachableError) Category() ErrorCategory { return CategoryUnreachable }
+
+// classifyError wraps err in the typed error matching its cause. Errors that
+// are already typed, or whose cause is not recognized, are returned unchanged.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var typed categorizedError
+	if errors.As(err, &typed) {
+		return err
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return &TimeoutError{Err: err}
+	case apierrors.IsTooManyRequests(err):
+		throttled := &ThrottledError{Err: err}
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+			throttled.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		return throttled
+	case apierrors.IsUnauthorized(err), apierrors.IsForbidden(err):
+		return &AuthError{Err: err}
+	case apierrors.IsNotFound(err):
+		return &NotFoundError{Err: err}
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return &TimeoutError{Err: err}
+		}
+		return &UnreachableError{Err: err}
+	}
+	return err
+}
+
+// errorCategory returns the category of err, or "" when err is nil
+func errorCategory(err error) ErrorCategory {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, ErrMemoryLimit) {
+		return CategoryMemoryLimit
+	}
+	var typed categorizedError
+	if errors.As(classifyError(err), &typed) {
+		return typed.Category()
+	}
+	return CategoryUnknown
+}
+
+// CheckError records a check or source that failed to produce a result
+type CheckError struct {
+	Component string        `json:"component"`
+	Category  ErrorCategory `json:"category"`
+	Message   string        `json:"message"`
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// maxEventObjects bounds the number of affected objects events are fetched for per check
+const maxEventObjects = 5
+
+// objectRef identifies a Kubernetes object referenced by a check result
+type objectRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func (r objectRef) String() string {
+	if r.Namespace == "" {
+		return r.Kind + "/" + r.Name
+	}
+	return r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
+// RecentEvents returns the most recent events for an object, newest first
+func (k *K8sToolkit) RecentEvents(ctx context.Context, ref objectRef, limit int) ([]corev1.Event, error) {
+	selector := fields.SelectorFromSet(fields.Set{
+		"involvedObject.kind": ref.Kind,
+		"involvedObject.name": ref.Name,
+	})
+
+	var items []corev1.Event
+	newestFirst := func() {
+		sort.Slice(items, func(i, j int) bool {
+			return eventTime(items[i]).After(eventTime(items[j]))
+		})
+		if limit > 0 && len(items) > limit {
+			items = items[:limit]
+		}
+	}
+
+	// Events for cluster-scoped objects are recorded in arbitrary namespaces.
+	// Older events are discarded while paging so only about 2*limit are held.
+	err := k.eachEvent(ctx, ref.Namespace, metav1.ListOptions{FieldSelector: selector.String()}, func(event *corev1.Event) error {
+		items = append(items, *event)
+		if limit > 0 && len(items) >= 2*limit {
+			newestFirst()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	newestFirst()
+	return items, nil
+}
+
+// eventTime returns the best available timestamp for an event
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case event.Series != nil:
+		return event.Series.LastObservedTime.Time
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
+
+// formatEvent renders an event as a single line for check details
+func formatEvent(event corev1.Event) string {
+	line := fmt.Sprintf("%s: %s", event.Reason, strings.TrimSpace(event.Message))
+	if event.Count > 1 {
+		line += fmt.Sprintf(" (x%d)", event.Count)
+	}
+	return line + fmt.Sprintf(" [%s ago]", time.Since(eventTime(event)).Round(time.Second))
+}
+
+// attachEvents adds the latest events of each affected object to the result details
+func (k *K8sToolkit) attachEvents(ctx context.Context, result *HealthCheckResult, limit int) {
+	refs := result.Affected
+	if len(refs) > maxEventObjects {
+		refs = refs[:maxEventObjects]
+	}
+
+	for _, ref := range refs {
+		events, err := k.RecentEvents(ctx, ref, limit)
+		if err != nil {
+			result.Details["events "+ref.String()] = fmt.Sprintf("failed to list events: %v", err)
+			continue
+		}
+		if len(events) == 0 {
+			continue
+		}
+
+		lines := make([]string, 0, len(events))
+		for _, event := range events {
+			lines = append(lines, formatEvent(event))
+		}
+		result.Details["events "+ref.String()] = strings.Join(lines, "; ")
+	}
+}
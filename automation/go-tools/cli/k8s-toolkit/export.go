@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// defaultExportExcludes are resources recreated by controllers or that only
+// make sense in the cluster they were read from
+var defaultExportExcludes = []string{
+	"events", "events.events.k8s.io", "endpoints", "endpointslices.discovery.k8s.io",
+	"leases.coordination.k8s.io", "controllerrevisions.apps",
+}
+
+// ExportOptions selects the resources written by Export
+type ExportOptions struct {
+	OutputDir     string
+	Include       []string
+	Exclude       []string
+	LabelSelector string
+	IncludeOwned  bool
+
+	// visit receives each selected object instead of it being written to
+	// OutputDir
+	visit func(gvr schema.GroupVersionResource, namespaced bool, obj *unstructured.Unstructured) error
+}
+
+// ExportSummary counts the objects written per resource
+type ExportSummary struct {
+	Resources map[string]int
+	Total     int
+}
+
+// matchesResource reports whether a filter entry names the resource, by
+// plural name, plural.group or kind
+func matchesResource(entry string, gvr schema.GroupVersionResource, kind string) bool {
+	entry = strings.ToLower(entry)
+	if entry == gvr.Resource || entry == strings.ToLower(kind) {
+		return true
+	}
+	return gvr.Group != "" && entry == gvr.Resource+"."+gvr.Group
+}
+
+// Export writes the selected resources of the namespace as cleaned YAML,
+// one file per object under <namespace>/<resource>/<name>.yaml. Cluster-scoped
+// resources are exported under _cluster when no namespace is set.
+func (k *K8sToolkit) Export(ctx context.Context, opts ExportOptions) (*ExportSummary, error) {
+	var lists []*metav1.APIResourceList
+	var err error
+	if k.namespace != "" {
+		lists, err = k.clientset.Discovery().ServerPreferredNamespacedResources()
+	} else {
+		lists, err = k.clientset.Discovery().ServerPreferredResources()
+	}
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("failed to discover resources: %w", err)
+	}
+	if err != nil {
+		k.log().Warnf("some API groups could not be discovered: %v", err)
+	}
+
+	excludes := append(append([]string{}, defaultExportExcludes...), opts.Exclude...)
+	summary := &ExportSummary{Resources: make(map[string]int)}
+
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+	resources:
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || !hasVerb(resource.Verbs, "list") {
+				continue
+			}
+			gvr := gv.WithResource(resource.Name)
+
+			if len(opts.Include) > 0 {
+				included := false
+				for _, entry := range opts.Include {
+					included = included || matchesResource(entry, gvr, resource.Kind)
+				}
+				if !included {
+					continue
+				}
+			}
+			for _, entry := range excludes {
+				if matchesResource(entry, gvr, resource.Kind) {
+					continue resources
+				}
+			}
+
+			namespace := ""
+			if resource.Namespaced {
+				namespace = k.namespace
+			}
+			count, err := k.exportResource(ctx, gvr, resource.Kind, resource.Namespaced, namespace, opts)
+			if err != nil {
+				k.log().Warnf("failed to export %s: %v", gvr.GroupResource(), err)
+				continue
+			}
+			if count > 0 {
+				summary.Resources[gvr.GroupResource().String()] = count
+				summary.Total += count
+			}
+		}
+	}
+	return summary, nil
+}
+
+// exportResource lists one resource page by page and writes each object
+func (k *K8sToolkit) exportResource(ctx context.Context, gvr schema.GroupVersionResource, kind string, namespaced bool, namespace string, opts ExportOptions) (int, error) {
+	listOpts := metav1.ListOptions{LabelSelector: opts.LabelSelector, Limit: listPageSize}
+	count := 0
+	for {
+		page, err := k.dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, listOpts)
+		if err != nil {
+			return count, err
+		}
+		for i := range page.Items {
+			obj := &page.Items[i]
+			if !opts.IncludeOwned && len(obj.GetOwnerReferences()) > 0 {
+				continue
+			}
+			if kind == "Secret" && obj.Object["type"] == "kubernetes.io/service-account-token" {
+				continue
+			}
+
+			obj.SetAPIVersion(gvr.GroupVersion().String())
+			obj.SetKind(kind)
+			if opts.visit != nil {
+				if err := opts.visit(gvr, namespaced, obj); err != nil {
+					return count, err
+				}
+				count++
+				continue
+			}
+			dir := "_cluster"
+			if namespaced {
+				dir = obj.GetNamespace()
+			}
+			if err := writeExportedObject(filepath.Join(opts.OutputDir, dir, gvr.GroupResource().String()), obj); err != nil {
+				return count, err
+			}
+			count++
+		}
+		if page.GetContinue() == "" {
+			return count, nil
+		}
+		listOpts.Continue = page.GetContinue()
+	}
+}
+
+// writeExportedObject writes an object stripped of server-populated fields
+func writeExportedObject(dir string, obj *unstructured.Unstructured) error {
+	cleaned := cleanForExport(obj.Object)
+	data, err := yaml.Marshal(cleaned)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", obj.GetName(), err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, obj.GetName()+".yaml")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// cleanForExport removes the fields normalizeForDiff drops plus the assigned
+// cluster IPs of Services, which would conflict when the Service is restored.
+// Headless Services keep clusterIP: None.
+func cleanForExport(obj map[string]interface{}) map[string]interface{} {
+	obj = normalizeForDiff(obj)
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		if annotations, ok := metadata["annotations"].(map[string]interface{}); ok && len(annotations) == 0 {
+			delete(metadata, "annotations")
+		}
+	}
+	if clusterIP, _, _ := unstructured.NestedString(obj, "spec", "clusterIP"); obj["kind"] == "Service" && clusterIP != "None" {
+		unstructured.RemoveNestedField(obj, "spec", "clusterIP")
+		unstructured.RemoveNestedField(obj, "spec", "clusterIPs")
+	}
+	return obj
+}
+
+// hasVerb reports whether a discovered resource supports verb
+func hasVerb(verbs metav1.Verbs, verb string) bool {
+	for _, v := range verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// createExportCmd creates the export command
+func createExportCmd() *cobra.Command {
+	var opts ExportOptions
+
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Dump resources as cleaned YAML for backup",
+		Long: `Writes every listable resource in --namespace (or in the whole cluster, including
+cluster-scoped resources, when no namespace is set) as YAML stripped of status, managedFields,
+resourceVersion and other server-populated fields, organized as <namespace>/<resource>/<name>.yaml.
+Objects owned by another object (pods of a ReplicaSet, ReplicaSets of a Deployment) are skipped
+unless --include-owned is set, since their owners recreate them.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			summary, err := toolkit.Export(context.Background(), opts)
+			if err != nil {
+				logger.Fatalf("Failed to export resources: %v", err)
+			}
+
+			names := make([]string, 0, len(summary.Resources))
+			for name := range summary.Resources {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("%-50s %d\n", name, summary.Resources[name])
+			}
+			fmt.Printf("Exported %d objects to %s\n", summary.Total, opts.OutputDir)
+		},
+	}
+
+	exportCmd.Flags().StringVar(&opts.OutputDir, "output-dir", "backup", "Directory to write the exported YAML into")
+	exportCmd.Flags().StringSliceVar(&opts.Include, "include", nil, "Only export these resources (plural name, name.group or kind)")
+	exportCmd.Flags().StringSliceVar(&opts.Exclude, "exclude", nil, "Skip these resources in addition to events, endpoints, leases and controller revisions")
+	exportCmd.Flags().StringVarP(&opts.LabelSelector, "selector", "l", "", "Only export objects matching this label selector")
+	exportCmd.Flags().BoolVar(&opts.IncludeOwned, "include-owned", false, "Also export objects that have owner references")
+
+	return exportCmd
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// filtered evaluates the --filter CEL expression against the JSON form of a
+// report and reports whether it took over output. The whole report is bound
+// to `report`; when the report is an object its top-level fields are bound
+// too, so `checks.exists(c, c.status == 'Critical')` works on health reports.
+//
+// A boolean result is a gate: nothing is printed and the process exits 1 when
+// the expression is true and 0 otherwise. Any other result is printed as JSON
+// in place of the report and the process exits 0.
+func (k *K8sToolkit) filtered(report interface{}) bool {
+	if k.filter == "" {
+		return false
+	}
+
+	// Round-trip through JSON so expressions see the same field names as -o json
+	data, err := json.Marshal(report)
+	if err != nil {
+		logger.Fatalf("Failed to marshal report for filter: %v", err)
+	}
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		logger.Fatalf("Failed to decode report for filter: %v", err)
+	}
+
+	result, err := evalFilter(k.filter, doc)
+	if err != nil {
+		logger.Fatalf("Filter failed: %v", err)
+	}
+
+	if matched, ok := result.(bool); ok {
+		if matched {
+			fmt.Fprintf(os.Stderr, "filter matched: %s\n", k.filter)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	printJSON(result)
+	os.Exit(0)
+	return true
+}
+
+// evalFilter compiles and runs expression against doc and returns the result
+// as plain Go values
+func evalFilter(expression string, doc interface{}) (interface{}, error) {
+	vars := map[string]interface{}{"report": doc}
+	if fields, ok := doc.(map[string]interface{}); ok {
+		for name, value := range fields {
+			if name != "report" {
+				vars[name] = value
+			}
+		}
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	opts := []cel.EnvOption{cel.CrossTypeNumericComparisons(true)}
+	for _, name := range names {
+		opts = append(opts, cel.Variable(name, cel.DynType))
+	}
+
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("invalid expression: %w", issues.Err())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	value, _, err := program.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+
+	if value.Type() == types.BoolType {
+		return value.Value().(bool), nil
+	}
+	native, err := value.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert result: %w", err)
+	}
+	return native.(*structpb.Value).AsInterface(), nil
+}
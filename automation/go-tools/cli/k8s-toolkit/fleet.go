@@ -0,0 +1,413 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+)
+
+// fleetRunMethod is the full gRPC method name of Fleet.Run
+const fleetRunMethod = "/k8stoolkit.fleet.v1.Fleet/Run"
+
+// fleetCommands are the read-only commands a daemon runs for the coordinator
+var fleetCommands = []string{"health", "scan", "images"}
+
+// jsonCodec carries fleet messages as JSON so the service needs no generated
+// protobuf code. Clients select it with the "json" content subtype.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) Name() string                               { return "json" }
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// FleetRequest asks a daemon to run one read-only command
+type FleetRequest struct {
+	Command   string   `json:"command"`
+	Namespace string   `json:"namespace,omitempty"`
+	Sources   []string `json:"sources,omitempty"`
+}
+
+// FleetResponse is a daemon's result of a FleetRequest
+type FleetResponse struct {
+	Cluster    string          `json:"cluster"`
+	Command    string          `json:"command"`
+	Result     json.RawMessage `json:"result"`
+	Errors     []string        `json:"errors,omitempty"`
+	DurationMs int64           `json:"duration_ms"`
+}
+
+// fleetService is the server side of the Fleet gRPC service
+type fleetService interface {
+	Run(ctx context.Context, req *FleetRequest) (*FleetResponse, error)
+}
+
+// fleetServiceDesc describes the Fleet service by hand, as protoc would
+var fleetServiceDesc = grpc.ServiceDesc{
+	ServiceName: "k8stoolkit.fleet.v1.Fleet",
+	HandlerType: (*fleetService)(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "Run",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := new(FleetRequest)
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			run := func(ctx context.Context, req interface{}) (interface{}, error) {
+				return srv.(fleetService).Run(ctx, req.(*FleetRequest))
+			}
+			if interceptor == nil {
+				return run(ctx, req)
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: fleetRunMethod}, run)
+		},
+	}},
+	Metadata: "fleet.go",
+}
+
+// fleetDaemon runs fleet commands against the local cluster
+type fleetDaemon struct {
+	toolkit *K8sToolkit
+	cluster string
+}
+
+// Run executes one of fleetCommands. Only read-only commands are accepted,
+// whatever the caller's certificate allows.
+func (d *fleetDaemon) Run(ctx context.Context, req *FleetRequest) (*FleetResponse, error) {
+	start := time.Now()
+	configMu.RLock()
+	defer configMu.RUnlock()
+	k, err := d.toolkit.narrowedTo(req.Namespace)
+	if err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	var result interface{}
+	var errs []string
+	switch req.Command {
+	case "health":
+		health, err := k.RunHealthCheck(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		result = health
+	case "scan":
+		// Image scans and registry probes stay off on the daemon side
+		findings, failures := k.runFindingSources(ctx, req.Sources, FindingSourceOptions{})
+		for name, msg := range failures {
+			errs = append(errs, name+": "+msg)
+		}
+		sort.Strings(errs)
+		result = findings
+	case "images":
+		report, err := k.AuditImages(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+		result = report
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown fleet command %q (use %s)", req.Command, strings.Join(fleetCommands, ", "))
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	d.toolkit.log().Infof("Ran fleet command %s for the coordinator", req.Command)
+	return &FleetResponse{Cluster: d.cluster, Command: req.Command, Result: data, Errors: errs, DurationMs: time.Since(start).Milliseconds()}, nil
+}
+
+// fleetTLSConfig builds the mutual TLS configuration from fleet.tls.cert,
+// fleet.tls.key and fleet.tls.ca. Peers must present a certificate signed by
+// the fleet CA.
+func fleetTLSConfig(server bool) (*tls.Config, error) {
+	certFile, keyFile, caFile := viper.GetString("fleet.tls.cert"), viper.GetString("fleet.tls.key"), viper.GetString("fleet.tls.ca")
+	if certFile == "" || keyFile == "" || caFile == "" {
+		return nil, fmt.Errorf("fleet mode needs mutual TLS: set fleet.tls.cert, fleet.tls.key and fleet.tls.ca")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load fleet certificate: %w", err)
+	}
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fleet CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if server {
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	} else {
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// fleetClusterName is the name a daemon reports for its cluster
+func (k *K8sToolkit) fleetClusterName() string {
+	if name := viper.GetString("fleet.cluster"); name != "" {
+		return name
+	}
+	return k.contextName
+}
+
+// serveFleet serves the Fleet service on addr until ctx is done
+func (k *K8sToolkit) serveFleet(ctx context.Context, addr string) error {
+	tlsConfig, err := fleetTLSConfig(true)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	server.RegisterService(&fleetServiceDesc, &fleetDaemon{toolkit: k, cluster: k.fleetClusterName()})
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			k.log().Warnf("fleet server failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// fleetDaemonConfig is an entry under fleet.daemons in the config file
+type fleetDaemonConfig struct {
+	Name    string `mapstructure:"name"`
+	Address string `mapstructure:"address"`
+	// ServerName overrides the name verified in the daemon's certificate
+	ServerName string `mapstructure:"server_name"`
+}
+
+// FleetClusterResult is one daemon's answer in a fleet run
+type FleetClusterResult struct {
+	Cluster    string          `json:"cluster"`
+	Address    string          `json:"address"`
+	Status     string          `json:"status"`
+	Summary    string          `json:"summary"`
+	Error      string          `json:"error,omitempty"`
+	Errors     []string        `json:"errors,omitempty"`
+	DurationMs int64           `json:"duration_ms"`
+	Result     json.RawMessage `json:"result,omitempty"`
+}
+
+// FleetReport aggregates one command across every registered daemon
+type FleetReport struct {
+	Command     string               `json:"command"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	Clusters    []FleetClusterResult `json:"clusters"`
+	Summary     map[string]int       `json:"summary"`
+}
+
+// RunFleet sends req to every daemon concurrently and aggregates the
+// answers. Unreachable daemons are reported with status Unknown.
+func RunFleet(ctx context.Context, daemons []fleetDaemonConfig, req FleetRequest, timeout time.Duration) (*FleetReport, error) {
+	tlsConfig, err := fleetTLSConfig(false)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FleetReport{Command: req.Command, GeneratedAt: time.Now(), Clusters: make([]FleetClusterResult, len(daemons)), Summary: make(map[string]int)}
+	var wg sync.WaitGroup
+	for i, daemon := range daemons {
+		wg.Add(1)
+		go func(i int, daemon fleetDaemonConfig) {
+			defer wg.Done()
+			report.Clusters[i] = callFleetDaemon(ctx, daemon, tlsConfig, req, timeout)
+		}(i, daemon)
+	}
+	wg.Wait()
+
+	for _, cluster := range report.Clusters {
+		report.Summary[cluster.Status]++
+	}
+	return report, nil
+}
+
+// callFleetDaemon runs req on one daemon and summarizes its result
+func callFleetDaemon(ctx context.Context, daemon fleetDaemonConfig, tlsConfig *tls.Config, req FleetRequest, timeout time.Duration) FleetClusterResult {
+	result := FleetClusterResult{Cluster: daemon.Name, Address: daemon.Address, Status: "Unknown"}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	config := tlsConfig.Clone()
+	config.ServerName = daemon.ServerName
+	conn, err := grpc.DialContext(ctx, daemon.Address,
+		grpc.WithTransportCredentials(credentials.NewTLS(config)),
+		grpc.WithDefaultCallOptions(grpc.CallContentSubtype(jsonCodec{}.Name())))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer conn.Close()
+
+	var resp FleetResponse
+	if err := conn.Invoke(ctx, fleetRunMethod, &req, &resp); err != nil {
+		result.Error = status.Convert(err).Message()
+		return result
+	}
+	if result.Cluster == "" {
+		result.Cluster = resp.Cluster
+	}
+	result.Errors = resp.Errors
+	result.DurationMs = resp.DurationMs
+	result.Result = resp.Result
+	result.Status, result.Summary = summarizeFleetResult(req.Command, resp.Result)
+	return result
+}
+
+// summarizeFleetResult condenses a daemon's result to a status and one line
+func summarizeFleetResult(command string, data json.RawMessage) (string, string) {
+	switch command {
+	case "health":
+		var health ClusterHealth
+		if err := json.Unmarshal(data, &health); err != nil {
+			return "Unknown", err.Error()
+		}
+		summary := fmt.Sprintf("%d critical, %d warning, %d healthy", health.Summary["Critical"], health.Summary["Warning"], health.Summary["Healthy"])
+		if health.Partial {
+			summary += ", partial"
+		}
+		return health.OverallStatus, summary
+	case "scan", "images":
+		var findings []Finding
+		if command == "images" {
+			var report ImageReport
+			if err := json.Unmarshal(data, &report); err != nil {
+				return "Unknown", err.Error()
+			}
+			findings = report.Findings
+		} else if err := json.Unmarshal(data, &findings); err != nil {
+			return "Unknown", err.Error()
+		}
+		counts := make(map[string]int)
+		worst := 0
+		for _, f := range findings {
+			counts[f.Severity]++
+			if severityRank[f.Severity] > worst {
+				worst = severityRank[f.Severity]
+			}
+		}
+		overall := "Healthy"
+		switch {
+		case worst >= severityRank["High"]:
+			overall = "Critical"
+		case worst > 0:
+			overall = "Warning"
+		}
+		return overall, fmt.Sprintf("%d findings (%d critical, %d high, %d medium, %d low)",
+			len(findings), counts["Critical"], counts["High"], counts["Medium"], counts["Low"])
+	}
+	return "Unknown", ""
+}
+
+// PrintFleetReport prints the fleet view: one row per cluster
+func (k *K8sToolkit) PrintFleetReport(report *FleetReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Printf("Fleet %s (%d clusters)\n", report.Command, len(report.Clusters))
+	fmt.Printf("%-24s %-10s %8s  %s\n", "CLUSTER", "STATUS", "TIME", "SUMMARY")
+	for _, cluster := range report.Clusters {
+		summary := cluster.Summary
+		if cluster.Error != "" {
+			summary = "error: " + cluster.Error
+		}
+		fmt.Printf("%s %-22s %-10s %7dms  %s\n", statusIcon(cluster.Status), cluster.Cluster, cluster.Status, cluster.DurationMs, summary)
+		for _, e := range cluster.Errors {
+			fmt.Printf("    %s\n", e)
+		}
+	}
+}
+
+// createFleetCmd creates the fleet command
+func createFleetCmd() *cobra.Command {
+	fleetCmd := &cobra.Command{
+		Use:   "fleet",
+		Short: "Run read-only commands across clusters through their operator daemons",
+		Long: `Acts as the coordinator for toolkit daemons running in many clusters. Each cluster runs the operator
+with --fleet-addr; the coordinator lists them under fleet.daemons and talks to them over gRPC with
+mutual TLS (fleet.tls.cert, fleet.tls.key and fleet.tls.ca on both sides).
+
+  fleet:
+    daemons:
+      - name: prod-eu
+        address: toolkit.prod-eu.example.com:9443
+      - name: prod-us
+        address: toolkit.prod-us.example.com:9443`,
+	}
+
+	var req FleetRequest
+	var timeout time.Duration
+	runCmd := &cobra.Command{
+		Use:       "run <health|scan|images>",
+		Short:     "Run a command on every registered daemon and show the fleet view",
+		Long:      `Sends the command to every daemon under fleet.daemons concurrently and prints one row per cluster with its status and a summary. -o json includes each cluster's full result. Exits 1 when any cluster is Critical or unreachable.`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: fleetCommands,
+		Run: func(cmd *cobra.Command, args []string) {
+			var daemons []fleetDaemonConfig
+			if err := viper.UnmarshalKey("fleet.daemons", &daemons); err != nil {
+				logger.Fatalf("Invalid fleet.daemons: %v", err)
+			}
+			if len(daemons) == 0 {
+				logger.Fatalf("No daemons registered under fleet.daemons")
+			}
+			if offline() {
+				logger.Fatalf("Fleet runs contact remote daemons and are disabled in offline mode")
+			}
+
+			req.Command = args[0]
+			req.Namespace = viper.GetString("namespace")
+			report, err := RunFleet(context.Background(), daemons, req, timeout)
+			if err != nil {
+				logger.Fatalf("Fleet run failed: %v", err)
+			}
+
+			toolkit := &K8sToolkit{output: viper.GetString("output"), filter: viper.GetString("filter")}
+			toolkit.PrintFleetReport(report)
+			if report.Summary["Critical"]+report.Summary["Unknown"] > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+	runCmd.Flags().StringSliceVar(&req.Sources, "sources", nil, "Finding sources to run for scan (default: all)")
+	runCmd.Flags().DurationVar(&timeout, "timeout", 2*time.Minute, "Time to wait for each daemon")
+
+	fleetCmd.AddCommand(runCmd)
+	return fleetCmd
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/devops-excellence/automation/go-tools/pkg/freeze"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// freezeScope is the scope mutating commands are checked against: the
+// --context kubeconfig context, or the current one
+func freezeScope() string {
+	if name := viper.GetString("context"); name != "" {
+		return name
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if path := viper.GetString("kubeconfig"); path != "" {
+		rules.ExplicitPath = path
+	}
+	raw, err := rules.Load()
+	if err != nil {
+		return ""
+	}
+	return raw.CurrentContext
+}
+
+// freezeCalendar reads the calendar at freeze.calendar, a YAML file or an
+// http(s) URL authenticated with freeze.token. It returns nil when no
+// calendar is configured.
+func freezeCalendar(ctx context.Context) (*freeze.Calendar, error) {
+	location := viper.GetString("freeze.calendar")
+	if location == "" {
+		return nil, nil
+	}
+	token, err := secretSetting(ctx, "freeze.token")
+	if err != nil {
+		return nil, err
+	}
+	return freeze.NewSource(location, token).Calendar(ctx)
+}
+
+// checkFreeze enforces the freeze calendar for a mutating command. Warn
+// windows are printed; blocking windows refuse the command unless
+// --break-glass is set with a --reason. It returns the names of the blocking
+// windows that were overridden. A calendar that cannot be read blocks too, so
+// an outage of the calendar does not silently lift a freeze.
+func checkFreeze(command, reason string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	breakGlass := viper.GetBool("break_glass")
+	if breakGlass && reason == "" {
+		return nil, fmt.Errorf("--break-glass requires --reason")
+	}
+
+	calendar, err := freezeCalendar(ctx)
+	if err != nil {
+		if !breakGlass {
+			return nil, fmt.Errorf("%s refused: %w; re-run with --break-glass --reason to override", command, err)
+		}
+		return []string{"calendar unavailable"}, nil
+	}
+	if calendar == nil {
+		return nil, nil
+	}
+
+	var blocking []string
+	for _, active := range calendar.ActiveAt(time.Now(), freezeScope()) {
+		description := fmt.Sprintf("%s until %s", active.Name, active.Until.Format(time.RFC3339))
+		if active.Reason != "" {
+			description += " (" + active.Reason + ")"
+		}
+		if !active.Blocking() {
+			fmt.Fprintf(os.Stderr, "Warning: deploy freeze %s is active\n", description)
+			continue
+		}
+		if !breakGlass {
+			return nil, fmt.Errorf("%s refused: deploy freeze %s is active; re-run with --break-glass --reason to override", command, description)
+		}
+		fmt.Fprintf(os.Stderr, "Breaking deploy freeze %s\n", description)
+		blocking = append(blocking, active.Name)
+	}
+	return blocking, nil
+}
+
+// FreezeStatus is the freeze state of a scope at a point in time
+type FreezeStatus struct {
+	Scope   string          `json:"scope"`
+	At      time.Time       `json:"at"`
+	Frozen  bool            `json:"frozen"`
+	Active  []freeze.Active `json:"active"`
+	Windows []freeze.Window `json:"windows"`
+}
+
+// PrintFreezeStatus prints the active windows and the whole calendar
+func (k *K8sToolkit) PrintFreezeStatus(status *FreezeStatus) {
+	if k.filtered(status) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(status)
+		return
+	}
+
+	scope := status.Scope
+	if scope == "" {
+		scope = "all scopes"
+	}
+	if len(status.Active) == 0 {
+		fmt.Printf("No deploy freeze active for %s at %s\n", scope, status.At.Format("2006-01-02 15:04"))
+	} else {
+		fmt.Printf("Active deploy freezes for %s at %s:\n", scope, status.At.Format("2006-01-02 15:04"))
+		for _, active := range status.Active {
+			mode := "blocks changes"
+			if !active.Blocking() {
+				mode = "warns"
+			}
+			fmt.Printf("  %-30s %-15s until %s  %s\n", active.Name, mode, active.Until.Format("2006-01-02 15:04 MST"), active.Reason)
+		}
+	}
+
+	fmt.Printf("\nCalendar (%d windows):\n", len(status.Windows))
+	for _, w := range status.Windows {
+		when := w.Start.Format("2006-01-02 15:04") + " - " + w.End.Format("2006-01-02 15:04")
+		if w.Weekly != nil {
+			when = fmt.Sprintf("weekly %s %s-%s %s", strings.Join(w.Weekly.Days, ","), w.Weekly.From, w.Weekly.To, w.Weekly.Timezone)
+		}
+		scopes := "all"
+		if len(w.Scopes) > 0 {
+			scopes = strings.Join(w.Scopes, ",")
+		}
+		fmt.Printf("  %-30s %-45s scopes: %s\n", w.Name, when, scopes)
+	}
+}
+
+// createFreezeCmd creates the freeze command
+func createFreezeCmd() *cobra.Command {
+	freezeCmd := &cobra.Command{
+		Use:   "freeze",
+		Short: "Show deploy freeze windows",
+		Long: `Deploy freezes are read from freeze.calendar, a YAML file or an http(s) URL returning the
+same document as JSON (authenticated with freeze.token, which may be a secret reference).
+Every command that modifies the cluster checks the calendar for the current kubeconfig
+context: warn windows print a warning and blocking windows refuse the command unless
+--break-glass is given together with --reason. Overridden windows are recorded in the
+audit log with each action.`,
+	}
+
+	var scope, at string
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the freezes active now, or at --at, and the whole calendar",
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit := &K8sToolkit{output: viper.GetString("output"), filter: viper.GetString("filter")}
+
+			when := time.Now()
+			if at != "" {
+				parsed, err := time.Parse(time.RFC3339, at)
+				if err != nil {
+					logger.Fatalf("Invalid --at: %v", err)
+				}
+				when = parsed
+			}
+			if !cmd.Flags().Changed("scope") {
+				scope = freezeScope()
+			}
+
+			calendar, err := freezeCalendar(context.Background())
+			if err != nil {
+				logger.Fatalf("Failed to read freeze calendar: %v", err)
+			}
+			if calendar == nil {
+				logger.Fatalf("No freeze calendar configured; set freeze.calendar")
+			}
+
+			status := &FreezeStatus{Scope: scope, At: when, Active: calendar.ActiveAt(when, scope), Windows: calendar.Windows}
+			for _, active := range status.Active {
+				if active.Blocking() {
+					status.Frozen = true
+				}
+			}
+			toolkit.PrintFreezeStatus(status)
+		},
+	}
+	statusCmd.Flags().StringVar(&scope, "scope", "", "Scope to check, e.g. a cluster or environment (default the current kubeconfig context; empty matches every window)")
+	statusCmd.Flags().StringVar(&at, "at", "", "Check this RFC 3339 time instead of now")
+
+	freezeCmd.AddCommand(statusCmd)
+	return freezeCmd
+}
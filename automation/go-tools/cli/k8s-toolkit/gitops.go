@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// gitopsKind is a GitOps custom resource checked by CheckGitOps. Versions are
+// tried in order so both current and older controller releases are covered.
+type gitopsKind struct {
+	kind     string
+	group    string
+	resource string
+	versions []string
+	status   func(obj *unstructured.Unstructured) (state string, reason string)
+}
+
+var gitopsKinds = []gitopsKind{
+	{"Application", "argoproj.io", "applications", []string{"v1alpha1"}, argoAppStatus},
+	{"Kustomization", "kustomize.toolkit.fluxcd.io", "kustomizations", []string{"v1", "v1beta2"}, fluxStatus},
+	{"HelmRelease", "helm.toolkit.fluxcd.io", "helmreleases", []string{"v2", "v2beta2", "v2beta1"}, fluxStatus},
+}
+
+// GitOps states reported by CheckGitOps
+const (
+	gitopsSynced    = "Synced"
+	gitopsOutOfSync = "OutOfSync"
+	gitopsDegraded  = "Degraded"
+	gitopsSuspended = "Suspended"
+)
+
+// argoAppStatus maps an Argo CD Application to a GitOps state
+func argoAppStatus(obj *unstructured.Unstructured) (string, string) {
+	health, _, _ := unstructured.NestedString(obj.Object, "status", "health", "status")
+	sync, _, _ := unstructured.NestedString(obj.Object, "status", "sync", "status")
+	switch {
+	case health == "Degraded" || health == "Missing":
+		message, _, _ := unstructured.NestedString(obj.Object, "status", "health", "message")
+		return gitopsDegraded, strings.TrimSpace("health " + health + " " + message)
+	case health == "Suspended":
+		return gitopsSuspended, "health Suspended"
+	case sync == "OutOfSync":
+		revision, _, _ := unstructured.NestedString(obj.Object, "status", "sync", "revision")
+		return gitopsOutOfSync, strings.TrimSpace("out of sync with revision " + revision)
+	}
+	return gitopsSynced, ""
+}
+
+// fluxStatus maps a Flux Kustomization or HelmRelease to a GitOps state
+// from spec.suspend and its Ready condition
+func fluxStatus(obj *unstructured.Unstructured) (string, string) {
+	if suspended, _, _ := unstructured.NestedBool(obj.Object, "spec", "suspend"); suspended {
+		return gitopsSuspended, "reconciliation suspended"
+	}
+
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, _ := c.(map[string]interface{})
+		if condition["type"] != "Ready" {
+			continue
+		}
+		if condition["status"] == "True" {
+			return gitopsSynced, ""
+		}
+		reason, _ := condition["reason"].(string)
+		message, _ := condition["message"].(string)
+		// Progressing resources are still applying the latest revision
+		if condition["status"] == "Unknown" {
+			return gitopsOutOfSync, strings.TrimSpace(reason + " " + message)
+		}
+		return gitopsDegraded, strings.TrimSpace(reason + " " + message)
+	}
+	return gitopsOutOfSync, "not reconciled yet"
+}
+
+// listGitOpsResources lists a GitOps kind using the first served version. It
+// returns false when the CRD is not installed.
+func (k *K8sToolkit) listGitOpsResources(ctx context.Context, gk gitopsKind) ([]unstructured.Unstructured, bool, error) {
+	for _, version := range gk.versions {
+		gvr := schema.GroupVersionResource{Group: gk.group, Version: version, Resource: gk.resource}
+		list, err := k.dynamicClient.Resource(gvr).Namespace(k.namespace).List(ctx, metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to list %s: %w", gk.resource, err)
+		}
+		return inScopeItems(k, list.Items), true, nil
+	}
+	return nil, false, nil
+}
+
+// CheckGitOps reports Argo CD Applications and Flux Kustomizations and
+// HelmReleases that are out of sync, degraded or suspended. It is Healthy
+// when neither controller is installed.
+func (k *K8sToolkit) CheckGitOps(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "GitOps",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	if k.dynamicClient == nil {
+		result.Status = "Healthy"
+		result.Message = "GitOps check skipped: dynamic client unavailable"
+		return result
+	}
+
+	counts := make(map[string]int)
+	var installed []string
+	var problems []string
+	total := 0
+
+	for _, gk := range gitopsKinds {
+		items, found, err := k.listGitOpsResources(ctx, gk)
+		if err != nil {
+			result.Status = "Warning"
+			result.Message = fmt.Sprintf("Failed to list %s resources: %v", gk.kind, err)
+			result.Err = err
+			return result
+		}
+		if !found {
+			continue
+		}
+		installed = append(installed, gk.kind)
+
+		for i := range items {
+			obj := &items[i]
+			total++
+			state, reason := gk.status(obj)
+			counts[state]++
+			if state == gitopsSynced {
+				continue
+			}
+			ref := objectRef{Kind: gk.kind, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+			result.Affected = append(result.Affected, ref)
+			problem := fmt.Sprintf("%s %s", ref, state)
+			if reason != "" {
+				problem += " (" + reason + ")"
+			}
+			problems = append(problems, problem)
+		}
+	}
+
+	if len(installed) == 0 {
+		result.Status = "Healthy"
+		result.Message = "No Argo CD or Flux resources found"
+		return result
+	}
+
+	sort.Strings(problems)
+	result.Details["controllers"] = strings.Join(installed, ", ")
+	result.Details["total"] = strconv.Itoa(total)
+	for _, state := range []string{gitopsOutOfSync, gitopsDegraded, gitopsSuspended} {
+		result.Details[strings.ToLower(state)] = strconv.Itoa(counts[state])
+	}
+	if len(problems) > 0 {
+		result.Details["problems"] = strings.Join(problems, "; ")
+	}
+
+	switch {
+	case counts[gitopsDegraded] > 0:
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("%d of %d GitOps resources degraded", counts[gitopsDegraded], total)
+	case len(problems) > 0:
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d out of sync and %d suspended of %d GitOps resources", counts[gitopsOutOfSync], counts[gitopsSuspended], total)
+	default:
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("All %d GitOps resources are in sync", total)
+	}
+
+	return result
+}
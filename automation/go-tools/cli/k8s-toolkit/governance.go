@@ -0,0 +1,455 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// governedKinds are the Kubernetes objects checked against the label policy
+var governedKinds = []struct {
+	kind string
+	gvr  schema.GroupVersionResource
+}{
+	{"Namespace", schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}},
+	{"Deployment", schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}},
+	{"StatefulSet", schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "statefulsets"}},
+	{"DaemonSet", schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "daemonsets"}},
+	{"CronJob", schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "cronjobs"}},
+	{"Service", schema.GroupVersionResource{Version: "v1", Resource: "services"}},
+}
+
+// GovernancePolicy is a label and tag policy shared by Kubernetes objects
+// and cloud resources
+type GovernancePolicy struct {
+	// OwnerTag is the cloud tag naming the owning team
+	OwnerTag          string           `yaml:"owner_tag"`
+	ExcludeNamespaces []string         `yaml:"exclude_namespaces"`
+	Rules             []GovernanceRule `yaml:"rules"`
+}
+
+// GovernanceRule requires a label/tag or constrains its value
+type GovernanceRule struct {
+	Name     string   `yaml:"name"`
+	Key      string   `yaml:"key"`
+	CloudKey string   `yaml:"cloud_key"`
+	Required bool     `yaml:"required"`
+	Allowed  []string `yaml:"allowed"`
+	Pattern  string   `yaml:"pattern"`
+	// Default is applied by --fix when the key is missing
+	Default string `yaml:"default"`
+	// Kinds limits the rule to Kubernetes kinds or inventory resource types
+	Kinds []string `yaml:"kinds"`
+
+	pattern *regexp.Regexp
+}
+
+// GovernanceViolation is an object or cloud resource breaking a rule
+type GovernanceViolation struct {
+	Platform  string `json:"platform"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Rule      string `json:"rule"`
+	Key       string `json:"key"`
+	Problem   string `json:"problem"`
+	Owner     string `json:"owner,omitempty"`
+	Fix       string `json:"fix,omitempty"`
+	Fixed     bool   `json:"fixed,omitempty"`
+	FixError  string `json:"fix_error,omitempty"`
+}
+
+// GovernanceReport lists the violations across Kubernetes and the cloud inventory
+type GovernanceReport struct {
+	Checked    map[string]int        `json:"checked"`
+	Violations []GovernanceViolation `json:"violations"`
+}
+
+// loadGovernancePolicy reads and validates a policy file
+func loadGovernancePolicy(path string) (*GovernancePolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	policy := &GovernancePolicy{}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if policy.OwnerTag == "" {
+		policy.OwnerTag = "team"
+	}
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if rule.Key == "" {
+			return nil, fmt.Errorf("rule %d in %s has no key", i, path)
+		}
+		if rule.Name == "" {
+			rule.Name = rule.Key
+		}
+		if rule.CloudKey == "" {
+			rule.CloudKey = rule.Key
+		}
+		if rule.Pattern != "" {
+			if rule.pattern, err = regexp.Compile(rule.Pattern); err != nil {
+				return nil, fmt.Errorf("rule %s has an invalid pattern: %w", rule.Name, err)
+			}
+		}
+	}
+	return policy, nil
+}
+
+// appliesTo reports whether a rule covers kind
+func (r *GovernanceRule) appliesTo(kind string) bool {
+	if len(r.Kinds) == 0 {
+		return true
+	}
+	for _, k := range r.Kinds {
+		if strings.EqualFold(k, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate returns the problem with value, or "" when it satisfies the rule
+func (r *GovernanceRule) evaluate(value string, present bool) string {
+	switch {
+	case !present && r.Required:
+		return "missing"
+	case !present:
+		return ""
+	case len(r.Allowed) > 0 && !containsString(r.Allowed, value):
+		return fmt.Sprintf("value %q is not one of %s", value, strings.Join(r.Allowed, ", "))
+	case r.pattern != nil && !r.pattern.MatchString(value):
+		return fmt.Sprintf("value %q does not match %s", value, r.Pattern)
+	}
+	return ""
+}
+
+// evaluateLabels checks the labels or tags of one object against every rule
+func (p *GovernancePolicy) evaluateLabels(base GovernanceViolation, labels map[string]string, cloud bool) []GovernanceViolation {
+	var violations []GovernanceViolation
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if !rule.appliesTo(base.Kind) {
+			continue
+		}
+		key := rule.Key
+		if cloud {
+			key = rule.CloudKey
+		}
+		value, present := labels[key]
+		problem := rule.evaluate(value, present)
+		if problem == "" {
+			continue
+		}
+		violation := base
+		violation.Rule = rule.Name
+		violation.Key = key
+		violation.Problem = problem
+		if !present && rule.Default != "" {
+			violation.Fix = rule.Default
+		}
+		violations = append(violations, violation)
+	}
+	return violations
+}
+
+// kubernetesViolations checks the governed Kubernetes objects
+func (k *K8sToolkit) kubernetesViolations(ctx context.Context, policy *GovernancePolicy, report *GovernanceReport) error {
+	owner := make(map[string]string)
+	teams, err := k.teamNamespaces(ctx)
+	if err != nil {
+		k.log().Warnf("failed to resolve team ownership: %v", err)
+	}
+	for team, namespaces := range teams {
+		for _, namespace := range namespaces {
+			owner[namespace] = team
+		}
+	}
+
+	for _, governed := range governedKinds {
+		var list *unstructured.UnstructuredList
+		var err error
+		if governed.kind == "Namespace" {
+			list, err = k.dynamicClient.Resource(governed.gvr).List(ctx, metav1.ListOptions{})
+		} else {
+			list, err = k.dynamicClient.Resource(governed.gvr).Namespace(k.namespace).List(ctx, metav1.ListOptions{})
+		}
+		if err != nil {
+			return fmt.Errorf("failed to list %s: %w", governed.gvr.Resource, err)
+		}
+
+		for _, obj := range list.Items {
+			ownerNamespace := obj.GetNamespace()
+			if governed.kind == "Namespace" {
+				ownerNamespace = obj.GetName()
+			}
+			if containsString(policy.ExcludeNamespaces, ownerNamespace) || !k.inScope(ownerNamespace) {
+				continue
+			}
+
+			report.Checked["kubernetes"]++
+			base := GovernanceViolation{Platform: "kubernetes", Kind: governed.kind, Namespace: obj.GetNamespace(), Name: obj.GetName(), Owner: owner[ownerNamespace]}
+			report.Violations = append(report.Violations, policy.evaluateLabels(base, obj.GetLabels(), false)...)
+		}
+	}
+	return nil
+}
+
+// cloudViolations checks the resources in an inventory written by
+// infrastructure_manager.py aws list-resources. Resource types whose
+// entries carry no tags are skipped since their tags were not collected.
+func cloudViolations(inventory map[string]json.RawMessage, policy *GovernancePolicy, report *GovernanceReport) {
+	resourceTypes := make([]string, 0, len(inventory))
+	for resourceType := range inventory {
+		resourceTypes = append(resourceTypes, resourceType)
+	}
+	sort.Strings(resourceTypes)
+
+	for _, resourceType := range resourceTypes {
+		var resources []map[string]interface{}
+		if json.Unmarshal(inventory[resourceType], &resources) != nil {
+			continue
+		}
+		for _, resource := range resources {
+			rawTags, ok := resource["tags"].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			tags := make(map[string]string)
+			for key, value := range rawTags {
+				tags[key] = fmt.Sprint(value)
+			}
+			id := fmt.Sprint(resource["id"])
+			if resource["id"] == nil {
+				id = fmt.Sprint(resource["name"])
+			}
+
+			report.Checked["cloud"]++
+			base := GovernanceViolation{Platform: "cloud", Kind: resourceType, Name: id, Owner: tags[policy.OwnerTag]}
+			report.Violations = append(report.Violations, policy.evaluateLabels(base, tags, true)...)
+		}
+	}
+}
+
+// EvaluateGovernance checks Kubernetes objects and, when inventory is not
+// nil, cloud resources against the policy
+func (k *K8sToolkit) EvaluateGovernance(ctx context.Context, policy *GovernancePolicy, inventory map[string]json.RawMessage) (*GovernanceReport, error) {
+	report := &GovernanceReport{Checked: make(map[string]int)}
+	if err := k.kubernetesViolations(ctx, policy, report); err != nil {
+		return nil, err
+	}
+	if inventory != nil {
+		cloudViolations(inventory, policy, report)
+	}
+
+	sort.SliceStable(report.Violations, func(i, j int) bool {
+		a, b := report.Violations[i], report.Violations[j]
+		if a.Platform != b.Platform {
+			return a.Platform > b.Platform
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+// applyGovernanceFix adds a missing label or tag. Existing values are never changed.
+func (k *K8sToolkit) applyGovernanceFix(ctx context.Context, ec2Client *ec2.EC2, v GovernanceViolation) error {
+	if v.Platform == "cloud" {
+		if !strings.HasPrefix(v.Kind, "ec2_") {
+			return fmt.Errorf("tagging %s is not supported", v.Kind)
+		}
+		_, err := ec2Client.CreateTagsWithContext(ctx, &ec2.CreateTagsInput{
+			Resources: []*string{aws.String(v.Name)},
+			Tags:      []*ec2.Tag{{Key: aws.String(v.Key), Value: aws.String(v.Fix)}},
+		})
+		return err
+	}
+
+	for _, governed := range governedKinds {
+		if governed.kind != v.Kind {
+			continue
+		}
+		patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]string{v.Key: v.Fix}}})
+		if err != nil {
+			return err
+		}
+		resource := k.dynamicClient.Resource(governed.gvr)
+		if v.Namespace != "" {
+			_, err = resource.Namespace(v.Namespace).Patch(ctx, v.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		} else {
+			_, err = resource.Patch(ctx, v.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+		}
+		return err
+	}
+	return fmt.Errorf("unknown kind %s", v.Kind)
+}
+
+// PrintGovernanceReport prints the violations as a table or JSON
+func (k *K8sToolkit) PrintGovernanceReport(report *GovernanceReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Printf("Label and Tag Governance\n")
+	fmt.Printf("Checked %d Kubernetes objects and %d cloud resources\n\n", report.Checked["kubernetes"], report.Checked["cloud"])
+	if len(report.Violations) == 0 {
+		fmt.Println("✅ No violations")
+		return
+	}
+
+	fmt.Printf("%-11s %-16s %-45s %-20s %-16s %s\n", "PLATFORM", "KIND", "NAME", "RULE", "OWNER", "PROBLEM")
+	for _, v := range report.Violations {
+		name := v.Name
+		if v.Namespace != "" {
+			name = v.Namespace + "/" + v.Name
+		}
+		problem := v.Problem
+		switch {
+		case v.Fixed:
+			problem += fmt.Sprintf(" (set to %q)", v.Fix)
+		case v.FixError != "":
+			problem += " (fix failed: " + v.FixError + ")"
+		case v.Fix != "":
+			problem += fmt.Sprintf(" (fixable: %q)", v.Fix)
+		}
+		fmt.Printf("%-11s %-16s %-45s %-20s %-16s %s\n", v.Platform, v.Kind, name, v.Rule, orDash(v.Owner), problem)
+	}
+	fmt.Printf("\n%d violations\n", len(report.Violations))
+}
+
+// createGovernanceCmd creates the governance command
+func createGovernanceCmd() *cobra.Command {
+	var policyFile, inventoryFile string
+	var fix, failOnViolations bool
+
+	governanceCmd := &cobra.Command{
+		Use:   "governance",
+		Short: "Check labels and cloud tags against a shared policy",
+		Long: `Evaluates one label/tag policy against Kubernetes namespaces, workloads and Services and, with
+--inventory, against the cloud resources listed by infrastructure_manager.py aws list-resources
+(use - to read it from stdin). Violations are reported with the owning team: the team owning the
+namespace (see digest --team-label) or the owner_tag of the cloud resource. With --fix, labels
+and EC2 tags that are missing and have a default in the policy are added; existing values are
+never changed.
+
+  owner_tag: team
+  exclude_namespaces: [kube-system]
+  rules:
+    - key: team
+      required: true
+    - key: environment
+      cloud_key: Environment
+      allowed: [dev, staging, prod]
+    - key: cost-center
+      required: true
+      default: shared
+      kinds: [Namespace, ec2_instances]`,
+		Run: func(cmd *cobra.Command, args []string) {
+			policy, err := loadGovernancePolicy(policyFile)
+			if err != nil {
+				logger.Fatalf("Failed to load policy: %v", err)
+			}
+
+			var inventory map[string]json.RawMessage
+			if inventoryFile != "" {
+				var data []byte
+				if inventoryFile == "-" {
+					data, err = io.ReadAll(os.Stdin)
+				} else {
+					data, err = os.ReadFile(inventoryFile)
+				}
+				if err != nil {
+					logger.Fatalf("Failed to read inventory: %v", err)
+				}
+				if err := json.Unmarshal(data, &inventory); err != nil {
+					logger.Fatalf("Failed to parse inventory: %v", err)
+				}
+			}
+
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx := context.Background()
+			report, err := toolkit.EvaluateGovernance(ctx, policy, inventory)
+			if err != nil {
+				logger.Fatalf("Failed to evaluate policy: %v", err)
+			}
+
+			var fixable []int
+			for i, v := range report.Violations {
+				if v.Fix != "" {
+					fixable = append(fixable, i)
+				}
+			}
+			if fix && len(fixable) > 0 {
+				m, err := beginMutation("governance", fmt.Sprintf("About to add %d missing labels and tags.", len(fixable)))
+				if err != nil {
+					logger.Fatalf("Fix aborted: %v", err)
+				}
+				var ec2Client *ec2.EC2
+				if report.Checked["cloud"] > 0 {
+					sess, err := session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+					if err != nil {
+						logger.Fatalf("Failed to create AWS session: %v", err)
+					}
+					ec2Client = ec2.New(sess)
+				}
+				for _, i := range fixable {
+					v := &report.Violations[i]
+					err := toolkit.applyGovernanceFix(ctx, ec2Client, *v)
+					m.record("label", fmt.Sprintf("%s %s %s=%s", v.Kind, strings.TrimPrefix(v.Namespace+"/"+v.Name, "/"), v.Key, v.Fix), err)
+					if err != nil {
+						v.FixError = err.Error()
+						continue
+					}
+					v.Fixed = true
+				}
+			}
+
+			toolkit.PrintGovernanceReport(report)
+			if failOnViolations {
+				for _, v := range report.Violations {
+					if !v.Fixed {
+						os.Exit(1)
+					}
+				}
+			}
+		},
+	}
+
+	governanceCmd.Flags().StringVarP(&policyFile, "policy", "p", "governance.yaml", "Label and tag policy file")
+	governanceCmd.Flags().StringVar(&inventoryFile, "inventory", "", "Cloud inventory JSON from infrastructure_manager.py aws list-resources (- for stdin)")
+	governanceCmd.Flags().BoolVar(&fix, "fix", false, "Add missing labels and tags that have a default in the policy")
+	governanceCmd.Flags().BoolVar(&failOnViolations, "fail-on-violations", false, "Exit 1 when violations remain")
+
+	return governanceCmd
+}
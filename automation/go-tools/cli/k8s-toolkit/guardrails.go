@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ErrReadOnly is returned for any mutating call made in read-only mode
+var ErrReadOnly = errors.New("refusing to modify the cluster in read-only mode")
+
+// readOnly reports whether mutating commands and API calls are disabled
+func readOnly() bool {
+	return viper.GetBool("read_only")
+}
+
+// skippedForReadOnly reports whether check is left out in read-only mode.
+// checks.exec commands and check plugins run with the kubeconfig itself
+// rather than the toolkit's read-only client, so nothing would stop them
+// from writing to the cluster.
+func skippedForReadOnly(check HealthChecker) bool {
+	_, external := check.(*execCheck)
+	return external && readOnly()
+}
+
+// readOnlyTransport rejects every API request that could change cluster
+// state. Access reviews are POSTs but only evaluate permissions, port-forward
+// is a POST that only opens a tunnel, and dry-run requests are never
+// persisted, so these are let through.
+type readOnlyTransport struct {
+	next http.RoundTripper
+}
+
+func (t *readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("dryRun") == "All" {
+		return t.next.RoundTrip(req)
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return t.next.RoundTrip(req)
+	case http.MethodPost:
+		if strings.HasPrefix(req.URL.Path, "/apis/authorization.k8s.io/") || strings.HasPrefix(req.URL.Path, "/apis/authentication.k8s.io/") {
+			return t.next.RoundTrip(req)
+		}
+		if strings.HasPrefix(req.URL.Path, "/api/v1/namespaces/") && strings.HasSuffix(req.URL.Path, "/portforward") {
+			return t.next.RoundTrip(req)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrReadOnly, req.Method, req.URL.Path)
+}
+
+// AuditEntry is one line of the mutation audit log
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user"`
+	Command string    `json:"command"`
+	Action  string    `json:"action"`
+	Target  string    `json:"target"`
+	Reason  string    `json:"reason,omitempty"`
+	Result  string    `json:"result"`
+
+	// BreakGlass lists the deploy freezes overridden to take the action
+	BreakGlass []string `json:"break_glass,omitempty"`
+}
+
+// mutation authorizes a mutating command and records each action it takes
+type mutation struct {
+	command    string
+	reason     string
+	user       string
+	breakGlass []string
+}
+
+// beginMutation checks the guardrails for a mutating command before it
+// touches anything: read-only mode, a required reason, the deploy freeze
+// calendar, and an interactive confirmation of summary unless --yes is set
+func beginMutation(command, summary string) (*mutation, error) {
+	if readOnly() {
+		return nil, fmt.Errorf("%w: %s", ErrReadOnly, command)
+	}
+
+	reason := strings.TrimSpace(viper.GetString("reason"))
+	if viper.GetBool("require_reason") && reason == "" {
+		return nil, fmt.Errorf("%s modifies the cluster and requires --reason", command)
+	}
+
+	breakGlass, err := checkFreeze(command, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if !viper.GetBool("yes") {
+		if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+			return nil, fmt.Errorf("%s needs confirmation; re-run with --yes when not interactive", command)
+		}
+		fmt.Fprintf(os.Stderr, "%s\nContinue? [y/N] ", summary)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			return nil, fmt.Errorf("%s cancelled", command)
+		}
+	}
+
+	name := "unknown"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	m := &mutation{command: command, reason: reason, user: name, breakGlass: breakGlass}
+	if len(breakGlass) > 0 {
+		m.record("break-glass", "freeze "+strings.Join(breakGlass, ", "), nil)
+	}
+	return m, nil
+}
+
+// record appends an action and its outcome to the audit log. Failing to
+// write the log is reported but does not undo the action; callers that
+// depend on the log, such as remediation rate limits, check the error.
+func (m *mutation) record(action, target string, actionErr error) error {
+	result := "ok"
+	if actionErr != nil {
+		result = "error: " + actionErr.Error()
+	}
+	entry := AuditEntry{
+		Time:    time.Now().UTC(),
+		User:    m.user,
+		Command: m.command,
+		Action:  action,
+		Target:  target,
+		Reason:  m.reason,
+		Result:  result,
+
+		BreakGlass: m.breakGlass,
+	}
+
+	if err := appendAuditEntry(entry); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to write audit log: %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// auditLogPath returns the audit log location from audit.log_file, defaulting
+// to the user cache directory
+func auditLogPath() (string, error) {
+	if path := viper.GetString("audit.log_file"); path != "" {
+		return path, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "k8s-toolkit", "audit.log"), nil
+}
+
+// openAuditLog opens the audit log for appending, creating it if needed
+func openAuditLog() (*os.File, error) {
+	path, err := auditLogPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	return os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+}
+
+// checkAuditLog reports whether the audit log can be written
+func checkAuditLog() error {
+	f, err := openAuditLog()
+	if err != nil {
+		return fmt.Errorf("audit log is not writable: %w", err)
+	}
+	return f.Close()
+}
+
+func appendAuditEntry(entry AuditEntry) error {
+	f, err := openAuditLog()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	return err
+}
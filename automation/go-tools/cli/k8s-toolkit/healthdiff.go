@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// CheckChange is a check whose status differs between two health runs. An
+// empty Before or After means the check was missing from that run.
+type CheckChange struct {
+	Check     string `json:"check"`
+	Component string `json:"component"`
+	Before    string `json:"before"`
+	After     string `json:"after"`
+	Message   string `json:"message"`
+}
+
+// MetricDelta is a numeric detail of a check that changed between two runs
+type MetricDelta struct {
+	Check  string  `json:"check"`
+	Metric string  `json:"metric"`
+	Before float64 `json:"before"`
+	After  float64 `json:"after"`
+	Delta  float64 `json:"delta"`
+}
+
+// HealthDiff lists what changed between two health runs
+type HealthDiff struct {
+	Before        time.Time     `json:"before"`
+	After         time.Time     `json:"after"`
+	OverallBefore string        `json:"overall_before"`
+	OverallAfter  string        `json:"overall_after"`
+	NewIssues     []CheckChange `json:"new_issues"`
+	Resolved      []CheckChange `json:"resolved"`
+	Changed       []CheckChange `json:"changed"`
+	Metrics       []MetricDelta `json:"metrics"`
+}
+
+// checkKey identifies a check across runs; reports written before checks
+// carried their name fall back to the component
+func checkKey(result HealthCheckResult) string {
+	if result.Check != "" {
+		return result.Check
+	}
+	return result.Component
+}
+
+// DiffHealth compares two health runs. A check that is not Healthy after
+// but was Healthy or missing before is a new issue, and the reverse is
+// resolved. A check that moved between Warning and Critical is changed.
+// Numeric details that differ are reported as metric deltas.
+func DiffHealth(before, after *ClusterHealth) *HealthDiff {
+	diff := &HealthDiff{
+		Before:        before.Timestamp,
+		After:         after.Timestamp,
+		OverallBefore: before.OverallStatus,
+		OverallAfter:  after.OverallStatus,
+	}
+
+	previous := make(map[string]HealthCheckResult)
+	for _, result := range before.Checks {
+		previous[checkKey(result)] = result
+	}
+	current := make(map[string]bool)
+
+	for _, result := range after.Checks {
+		key := checkKey(result)
+		current[key] = true
+		old, existed := previous[key]
+		change := CheckChange{Check: key, Component: result.Component, Before: old.Status, After: result.Status, Message: result.Message}
+
+		switch {
+		case result.Status == old.Status:
+		case result.Status == "Healthy":
+			if existed {
+				diff.Resolved = append(diff.Resolved, change)
+			}
+		case !existed || old.Status == "Healthy":
+			diff.NewIssues = append(diff.NewIssues, change)
+		default:
+			diff.Changed = append(diff.Changed, change)
+		}
+
+		for metric, value := range result.Details {
+			newValue, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			oldValue, err := strconv.ParseFloat(old.Details[metric], 64)
+			if err != nil || oldValue == newValue {
+				continue
+			}
+			diff.Metrics = append(diff.Metrics, MetricDelta{Check: key, Metric: metric, Before: oldValue, After: newValue, Delta: newValue - oldValue})
+		}
+	}
+
+	for _, result := range before.Checks {
+		key := checkKey(result)
+		if !current[key] && result.Status != "Healthy" {
+			diff.Resolved = append(diff.Resolved, CheckChange{Check: key, Component: result.Component, Before: result.Status, Message: "check not run"})
+		}
+	}
+
+	sort.Slice(diff.Metrics, func(i, j int) bool {
+		if diff.Metrics[i].Check != diff.Metrics[j].Check {
+			return diff.Metrics[i].Check < diff.Metrics[j].Check
+		}
+		return diff.Metrics[i].Metric < diff.Metrics[j].Metric
+	})
+	return diff
+}
+
+// readHealthReport reads a health report written with -o json. Reports
+// signed with --sign-key are unwrapped without verifying the signature.
+func readHealthReport(path string) (*ClusterHealth, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var signed SignedReport
+	if json.Unmarshal(data, &signed) == nil && len(signed.Report) > 0 {
+		data = signed.Report
+	}
+	var health ClusterHealth
+	if err := json.Unmarshal(data, &health); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if health.OverallStatus == "" {
+		return nil, fmt.Errorf("%s is not a health report", path)
+	}
+	return &health, nil
+}
+
+// PrintHealthDiff prints only what changed between two health runs
+func (k *K8sToolkit) PrintHealthDiff(diff *HealthDiff) {
+	if k.filtered(diff) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(diff)
+		return
+	}
+
+	fmt.Printf("Health Diff: %s -> %s\n", diff.Before.Format("2006-01-02 15:04:05"), diff.After.Format("2006-01-02 15:04:05"))
+	if diff.OverallBefore != diff.OverallAfter {
+		fmt.Printf("Overall Status: %s %s -> %s %s\n", statusIcon(diff.OverallBefore), diff.OverallBefore, statusIcon(diff.OverallAfter), diff.OverallAfter)
+	} else {
+		fmt.Printf("Overall Status: %s %s (unchanged)\n", statusIcon(diff.OverallAfter), diff.OverallAfter)
+	}
+
+	sections := []struct {
+		title   string
+		changes []CheckChange
+	}{
+		{"New Issues", diff.NewIssues},
+		{"Resolved", diff.Resolved},
+		{"Changed", diff.Changed},
+	}
+	for _, section := range sections {
+		if len(section.changes) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s:\n", section.title)
+		for _, change := range section.changes {
+			fmt.Printf("  %s %s: %s -> %s  %s\n", statusIcon(change.After), change.Component, orDash(change.Before), orDash(change.After), change.Message)
+		}
+	}
+
+	if len(diff.Metrics) > 0 {
+		fmt.Printf("\nMetrics:\n")
+		for _, metric := range diff.Metrics {
+			fmt.Printf("  %-20s %-24s %10g -> %-10g (%+g)\n", metric.Check, metric.Metric, metric.Before, metric.After, metric.Delta)
+		}
+	}
+
+	if len(diff.NewIssues)+len(diff.Resolved)+len(diff.Changed)+len(diff.Metrics) == 0 {
+		fmt.Println("\nNo changes")
+	}
+}
+
+// createHealthDiffCmd creates the health diff command
+func createHealthDiffCmd() *cobra.Command {
+	var failOnNew bool
+
+	diffCmd := &cobra.Command{
+		Use:   "diff <old.json> <new.json>",
+		Short: "Compare two health reports",
+		Long: `Compares two reports written with health -o json and prints only what changed: checks that
+became unhealthy, checks that recovered, checks that moved between Warning and Critical, and
+numeric details such as node or pod counts that differ. Useful for before/after validation of a
+maintenance window. See also health --compare-last.`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			before, err := readHealthReport(args[0])
+			if err != nil {
+				logger.Fatalf("Failed to read report: %v", err)
+			}
+			after, err := readHealthReport(args[1])
+			if err != nil {
+				logger.Fatalf("Failed to read report: %v", err)
+			}
+
+			diff := DiffHealth(before, after)
+			toolkit := &K8sToolkit{output: viper.GetString("output"), filter: viper.GetString("filter")}
+			toolkit.PrintHealthDiff(diff)
+			if failOnNew && len(diff.NewIssues) > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+
+	diffCmd.Flags().BoolVar(&failOnNew, "fail-on-new", false, "Exit 1 when the newer report has new issues")
+
+	return diffCmd
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// helmRelease is the subset of a Helm 3 release record used by CheckHelmReleases
+type helmRelease struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	Version   int    `json:"version"`
+	Info      struct {
+		Status       string    `json:"status"`
+		Description  string    `json:"description"`
+		LastDeployed time.Time `json:"last_deployed"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"metadata"`
+	} `json:"chart"`
+}
+
+// helmStuckStatuses are release states that block the next install or upgrade
+var helmStuckStatuses = map[string]bool{
+	"pending-install":  true,
+	"pending-upgrade":  true,
+	"pending-rollback": true,
+	"failed":           true,
+}
+
+// decodeHelmRelease decodes the release stored in a Helm secret: base64
+// encoded, gzip compressed JSON
+func decodeHelmRelease(secret *corev1.Secret) (*helmRelease, error) {
+	raw, err := base64.StdEncoding.DecodeString(string(secret.Data["release"]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	// Helm compresses releases, but accepts uncompressed ones too
+	if bytes.HasPrefix(raw, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip data: %w", err)
+		}
+		defer reader.Close()
+		if raw, err = io.ReadAll(reader); err != nil {
+			return nil, fmt.Errorf("invalid gzip data: %w", err)
+		}
+	}
+
+	var release helmRelease
+	if err := json.Unmarshal(raw, &release); err != nil {
+		return nil, fmt.Errorf("invalid release JSON: %w", err)
+	}
+	return &release, nil
+}
+
+// CheckHelmReleases decodes the latest revision of every Helm release and
+// reports releases left in a pending or failed state
+func (k *K8sToolkit) CheckHelmReleases(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Helm Releases",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	// Only the latest revision of each release matters; older revisions are
+	// skipped by their version label without decoding them
+	latest := make(map[string]*helmRelease)
+	undecodable := 0
+	err := k.eachSecret(ctx, k.namespace, metav1.ListOptions{LabelSelector: "owner=helm"}, func(secret *corev1.Secret) error {
+		if secret.Type != "helm.sh/release.v1" || !k.inScope(secret.Namespace) {
+			return nil
+		}
+		key := secret.Namespace + "/" + secret.Labels["name"]
+		revision, _ := strconv.Atoi(secret.Labels["version"])
+		if current, ok := latest[key]; ok && current.Version >= revision {
+			return nil
+		}
+		release, err := decodeHelmRelease(secret)
+		if err != nil {
+			undecodable++
+			return nil
+		}
+		latest[key] = release
+		return nil
+	})
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list Helm release secrets: %v", err)
+		result.Err = err
+		return result
+	}
+
+	var stuck []string
+	for _, release := range latest {
+		if !helmStuckStatuses[release.Info.Status] {
+			continue
+		}
+		stuck = append(stuck, fmt.Sprintf("%s/%s %s (chart %s-%s, revision %d, since %s)",
+			release.Namespace, release.Name, release.Info.Status,
+			release.Chart.Metadata.Name, release.Chart.Metadata.Version, release.Version,
+			release.Info.LastDeployed.Format(time.RFC3339)))
+		result.Affected = append(result.Affected, objectRef{Kind: "Secret", Namespace: release.Namespace,
+			Name: fmt.Sprintf("sh.helm.release.v1.%s.v%d", release.Name, release.Version)})
+	}
+	sort.Strings(stuck)
+
+	result.Details["total_releases"] = strconv.Itoa(len(latest))
+	result.Details["stuck_releases"] = strconv.Itoa(len(stuck))
+	if undecodable > 0 {
+		result.Details["undecodable_secrets"] = strconv.Itoa(undecodable)
+	}
+
+	if len(stuck) > 0 {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d Helm releases pending or failed", len(stuck))
+		result.Details["stuck_release_names"] = strings.Join(stuck, "; ")
+	} else {
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("None of %d Helm releases are pending or failed", len(latest))
+	}
+
+	return result
+}
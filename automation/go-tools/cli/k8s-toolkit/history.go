@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	_ "modernc.org/sqlite"
+)
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS health_runs (
+	id             INTEGER PRIMARY KEY AUTOINCREMENT,
+	cluster        TEXT     NOT NULL,
+	timestamp      INTEGER  NOT NULL,
+	overall_status TEXT     NOT NULL,
+	partial        BOOLEAN  NOT NULL
+);
+CREATE TABLE IF NOT EXISTS health_results (
+	run_id         INTEGER NOT NULL REFERENCES health_runs(id) ON DELETE CASCADE,
+	check_name     TEXT    NOT NULL,
+	component      TEXT    NOT NULL,
+	status         TEXT    NOT NULL,
+	message        TEXT    NOT NULL,
+	duration_ms    INTEGER NOT NULL,
+	error_category TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS health_runs_timestamp ON health_runs (cluster, timestamp);
+CREATE INDEX IF NOT EXISTS health_results_run ON health_results (run_id);`
+
+// HistoryStore persists health runs in a SQLite database
+type HistoryStore struct {
+	db *sql.DB
+}
+
+// OpenHistoryStore opens the store named by a sqlite:// URL, creating the
+// database and its tables when missing
+func OpenHistoryStore(url string) (*HistoryStore, error) {
+	path := strings.TrimPrefix(url, "sqlite://")
+	if path == url || path == "" {
+		return nil, fmt.Errorf("unsupported store %q (use sqlite:///path/to/history.db)", url)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create history tables: %w", err)
+	}
+	return &HistoryStore{db: db}, nil
+}
+
+// Close closes the database
+func (s *HistoryStore) Close() error {
+	return s.db.Close()
+}
+
+// historyCluster is the name runs are recorded under
+func historyCluster() string {
+	if cluster := viper.GetString("context"); cluster != "" {
+		return cluster
+	}
+	return "default"
+}
+
+// Record stores one health run
+func (s *HistoryStore) Record(ctx context.Context, cluster string, health *ClusterHealth) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`INSERT INTO health_runs (cluster, timestamp, overall_status, partial) VALUES (?, ?, ?, ?)`,
+		cluster, health.Timestamp.Unix(), health.OverallStatus, health.Partial)
+	if err != nil {
+		return err
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	for _, check := range health.Checks {
+		name := check.Check
+		if name == "" {
+			name = check.Component
+		}
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO health_results (run_id, check_name, component, status, message, duration_ms, error_category) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			runID, name, check.Component, check.Status, check.Message, check.Duration, string(check.ErrorCategory)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// StatusSpan is a stretch of consecutive runs in which a check had the same status
+type StatusSpan struct {
+	Status  string    `json:"status"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Runs    int       `json:"runs"`
+	Message string    `json:"message"`
+}
+
+// CheckHistory is the timeline of one check over a date range
+type CheckHistory struct {
+	Check             string           `json:"check"`
+	Component         string           `json:"component"`
+	Runs              int              `json:"runs"`
+	Flaps             int              `json:"flaps"`
+	TimeIn            map[string]int64 `json:"time_in_seconds"`
+	WarningEpisodes   int              `json:"warning_episodes"`
+	MeanTimeInWarning float64          `json:"mean_time_in_warning_seconds"`
+	Timeline          []StatusSpan     `json:"timeline"`
+}
+
+// HistoryReport is the history of every check of a cluster over a date range
+type HistoryReport struct {
+	Cluster string         `json:"cluster"`
+	From    time.Time      `json:"from"`
+	To      time.Time      `json:"to"`
+	Runs    int            `json:"runs"`
+	Checks  []CheckHistory `json:"checks"`
+}
+
+// History builds status timelines from the runs recorded between from and
+// to. A span lasts until the next run with a different status, so time in a
+// status is measured between runs rather than at sampling points. Flaps
+// count status changes; a warning episode is a span in Warning.
+func (s *HistoryStore) History(ctx context.Context, cluster string, from, to time.Time, check string) (*HistoryReport, error) {
+	query := `SELECT r.timestamp, c.check_name, c.component, c.status, c.message
+		FROM health_results c JOIN health_runs r ON r.id = c.run_id
+		WHERE r.cluster = ? AND r.timestamp >= ? AND r.timestamp <= ?`
+	args := []interface{}{cluster, from.Unix(), to.Unix()}
+	if check != "" {
+		query += ` AND c.check_name = ?`
+		args = append(args, check)
+	}
+	rows, err := s.db.QueryContext(ctx, query+` ORDER BY r.timestamp, c.check_name`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	report := &HistoryReport{Cluster: cluster, From: from, To: to}
+	byCheck := make(map[string]*CheckHistory)
+	var order []string
+	runs := make(map[time.Time]bool)
+	for rows.Next() {
+		var unix int64
+		var name, component, status, message string
+		if err := rows.Scan(&unix, &name, &component, &status, &message); err != nil {
+			return nil, err
+		}
+		ts := time.Unix(unix, 0)
+		runs[ts] = true
+
+		history, ok := byCheck[name]
+		if !ok {
+			history = &CheckHistory{Check: name, Component: component, TimeIn: make(map[string]int64)}
+			byCheck[name] = history
+			order = append(order, name)
+		}
+		history.Runs++
+
+		if n := len(history.Timeline); n > 0 {
+			last := &history.Timeline[n-1]
+			last.To = ts
+			if last.Status == status {
+				last.Runs++
+				continue
+			}
+			history.Flaps++
+		}
+		history.Timeline = append(history.Timeline, StatusSpan{Status: status, From: ts, To: ts, Runs: 1, Message: message})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	report.Runs = len(runs)
+
+	sort.Strings(order)
+	for _, name := range order {
+		history := byCheck[name]
+		var warning time.Duration
+		for _, span := range history.Timeline {
+			duration := span.To.Sub(span.From)
+			history.TimeIn[span.Status] += int64(duration.Seconds())
+			if span.Status == "Warning" {
+				history.WarningEpisodes++
+				warning += duration
+			}
+		}
+		if history.WarningEpisodes > 0 {
+			history.MeanTimeInWarning = (warning / time.Duration(history.WarningEpisodes)).Seconds()
+		}
+		report.Checks = append(report.Checks, *history)
+	}
+	return report, nil
+}
+
+// LastRun returns the most recent run recorded for cluster, or nil when
+// there is none. Check details are not stored, so the run has statuses and
+// messages only.
+func (s *HistoryStore) LastRun(ctx context.Context, cluster string) (*ClusterHealth, error) {
+	var runID, unix int64
+	health := &ClusterHealth{SchemaVersion: clusterHealthSchemaVersion, Summary: make(map[string]int)}
+	err := s.db.QueryRowContext(ctx,
+		`SELECT id, timestamp, overall_status, partial FROM health_runs WHERE cluster = ? ORDER BY timestamp DESC, id DESC LIMIT 1`,
+		cluster).Scan(&runID, &unix, &health.OverallStatus, &health.Partial)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	health.Timestamp = time.Unix(unix, 0)
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT check_name, component, status, message, duration_ms, error_category FROM health_results WHERE run_id = ?`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		result := HealthCheckResult{Timestamp: health.Timestamp, Details: make(map[string]string)}
+		var category string
+		if err := rows.Scan(&result.Check, &result.Component, &result.Status, &result.Message, &result.Duration, &category); err != nil {
+			return nil, err
+		}
+		result.ErrorCategory = ErrorCategory(category)
+		health.Checks = append(health.Checks, result)
+		health.Summary[result.Status]++
+	}
+	return health, rows.Err()
+}
+
+// recordHealth stores a health run in the --store database, if configured
+func recordHealth(ctx context.Context, health *ClusterHealth) {
+	url := viper.GetString("store")
+	if url == "" {
+		return
+	}
+	store, err := OpenHistoryStore(url)
+	if err != nil {
+		logger.Warnf("failed to open history store: %v", err)
+		return
+	}
+	defer store.Close()
+	if err := store.Record(ctx, historyCluster(), health); err != nil {
+		logger.Warnf("failed to record health run: %v", err)
+	}
+}
+
+// lastRecordedHealth returns the previous run from the --store database
+func lastRecordedHealth(ctx context.Context) (*ClusterHealth, error) {
+	url := viper.GetString("store")
+	if url == "" {
+		return nil, fmt.Errorf("--compare-last needs a history store (--store)")
+	}
+	store, err := OpenHistoryStore(url)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return store.LastRun(ctx, historyCluster())
+}
+
+// PrintHistory prints the check timelines as text, CSV or JSON
+func (k *K8sToolkit) PrintHistory(report *HistoryReport) {
+	if k.filtered(report) {
+		return
+	}
+
+	switch k.output {
+	case "json":
+		printJSON(report)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"check", "component", "status", "from", "to", "runs", "message"})
+		for _, check := range report.Checks {
+			for _, span := range check.Timeline {
+				w.Write([]string{check.Check, check.Component, span.Status, span.From.Format(time.RFC3339), span.To.Format(time.RFC3339), fmt.Sprint(span.Runs), span.Message})
+			}
+		}
+		w.Flush()
+	default:
+		fmt.Printf("Health History for %s\n", report.Cluster)
+		fmt.Printf("%s to %s, %d runs\n\n", report.From.Format("2006-01-02 15:04"), report.To.Format("2006-01-02 15:04"), report.Runs)
+		if len(report.Checks) == 0 {
+			fmt.Println("No runs recorded in this range")
+			return
+		}
+
+		fmt.Printf("%-20s %6s %6s %12s %12s %12s\n", "CHECK", "RUNS", "FLAPS", "WARNING", "CRITICAL", "MTI-WARNING")
+		for _, check := range report.Checks {
+			fmt.Printf("%-20s %6d %6d %12s %12s %12s\n", check.Check, check.Runs, check.Flaps,
+				time.Duration(check.TimeIn["Warning"])*time.Second,
+				time.Duration(check.TimeIn["Critical"])*time.Second,
+				time.Duration(check.MeanTimeInWarning)*time.Second)
+		}
+
+		for _, check := range report.Checks {
+			if check.Flaps == 0 && check.Timeline[0].Status == "Healthy" {
+				continue
+			}
+			fmt.Printf("\n%s:\n", check.Component)
+			for _, span := range check.Timeline {
+				fmt.Printf("  %s %s - %s  %-8s %s\n", statusIcon(span.Status), span.From.Local().Format("01-02 15:04"), span.To.Local().Format("01-02 15:04"), span.Status, span.Message)
+			}
+		}
+	}
+}
+
+// createHistoryCmd creates the history command
+func createHistoryCmd() *cobra.Command {
+	var fromValue, toValue, check, cluster string
+
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show status timelines of health checks recorded with --store",
+		Long: `Reads the health runs recorded by health --store and shows, per check, its status timeline,
+how often it flapped between statuses, the time spent in Warning and Critical, and the mean time
+in Warning. Use -o json or -o csv to export the timelines for postmortems.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			url := viper.GetString("store")
+			if url == "" {
+				logger.Fatalf("A history store is required (--store sqlite:///path/to/history.db)")
+			}
+
+			to := time.Now()
+			if toValue != "" {
+				parsed, err := parseExpiry(toValue)
+				if err != nil {
+					logger.Fatalf("Invalid --to: %v", err)
+				}
+				to = parsed
+			}
+			from := to.Add(-7 * 24 * time.Hour)
+			if fromValue != "" {
+				parsed, err := parseExpiry(fromValue)
+				if err != nil {
+					logger.Fatalf("Invalid --from: %v", err)
+				}
+				from = parsed
+			}
+			if cluster == "" {
+				cluster = historyCluster()
+			}
+
+			store, err := OpenHistoryStore(url)
+			if err != nil {
+				logger.Fatalf("Failed to open history store: %v", err)
+			}
+			defer store.Close()
+
+			report, err := store.History(context.Background(), cluster, from, to, check)
+			if err != nil {
+				logger.Fatalf("Failed to read history: %v", err)
+			}
+			toolkit := &K8sToolkit{output: viper.GetString("output"), filter: viper.GetString("filter")}
+			toolkit.PrintHistory(report)
+		},
+	}
+
+	historyCmd.Flags().StringVar(&fromValue, "from", "", "Start of the range as a date or RFC 3339 time (default 7 days before --to)")
+	historyCmd.Flags().StringVar(&toValue, "to", "", "End of the range as a date or RFC 3339 time (default now)")
+	historyCmd.Flags().StringVar(&check, "check", "", "Only show this check")
+	historyCmd.Flags().StringVar(&cluster, "cluster", "", "Cluster the runs were recorded for (default the --context name)")
+
+	return historyCmd
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// vpaResource is the VerticalPodAutoscaler custom resource
+var vpaResource = schema.GroupVersionResource{Group: "autoscaling.k8s.io", Version: "v1", Resource: "verticalpodautoscalers"}
+
+// HPAStatus summarizes one HorizontalPodAutoscaler
+type HPAStatus struct {
+	Namespace       string   `json:"namespace"`
+	Name            string   `json:"name"`
+	Target          string   `json:"target"`
+	MinReplicas     int32    `json:"min_replicas"`
+	MaxReplicas     int32    `json:"max_replicas"`
+	CurrentReplicas int32    `json:"current_replicas"`
+	DesiredReplicas int32    `json:"desired_replicas"`
+	Metrics         []string `json:"metrics"`
+	Issues          []string `json:"issues,omitempty"`
+}
+
+// HPAReport is the result of an HPA effectiveness analysis
+type HPAReport struct {
+	HPAs     []HPAStatus `json:"hpas"`
+	Findings []Finding   `json:"findings"`
+}
+
+// hpaCondition returns the named status condition, or nil
+func hpaCondition(hpa *autoscalingv2.HorizontalPodAutoscaler, conditionType autoscalingv2.HorizontalPodAutoscalerConditionType) *autoscalingv2.HorizontalPodAutoscalerCondition {
+	for i := range hpa.Status.Conditions {
+		if hpa.Status.Conditions[i].Type == conditionType {
+			return &hpa.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// describeMetric formats a metric target, with the current value when known
+func describeMetric(spec autoscalingv2.MetricSpec, current []autoscalingv2.MetricStatus) string {
+	switch spec.Type {
+	case autoscalingv2.ResourceMetricSourceType:
+		if spec.Resource == nil {
+			break
+		}
+		target := "?"
+		if u := spec.Resource.Target.AverageUtilization; u != nil {
+			target = fmt.Sprintf("%d%%", *u)
+		} else if v := spec.Resource.Target.AverageValue; v != nil {
+			target = v.String()
+		}
+		for _, status := range current {
+			if status.Resource != nil && status.Resource.Name == spec.Resource.Name && status.Resource.Current.AverageUtilization != nil {
+				return fmt.Sprintf("%s %d%%/%s", spec.Resource.Name, *status.Resource.Current.AverageUtilization, target)
+			}
+		}
+		return fmt.Sprintf("%s <unknown>/%s", spec.Resource.Name, target)
+	case autoscalingv2.PodsMetricSourceType:
+		if spec.Pods != nil {
+			return "pods/" + spec.Pods.Metric.Name
+		}
+	case autoscalingv2.ObjectMetricSourceType:
+		if spec.Object != nil {
+			return "object/" + spec.Object.Metric.Name
+		}
+	case autoscalingv2.ExternalMetricSourceType:
+		if spec.External != nil {
+			return "external/" + spec.External.Metric.Name
+		}
+	case autoscalingv2.ContainerResourceMetricSourceType:
+		if spec.ContainerResource != nil {
+			return fmt.Sprintf("%s/%s", spec.ContainerResource.Container, spec.ContainerResource.Name)
+		}
+	}
+	return string(spec.Type)
+}
+
+// AnalyzeHPAs reports HorizontalPodAutoscalers pinned at their replica bounds
+// for longer than pinnedFor, HPAs that cannot read their metrics, resource
+// metrics on workloads without matching requests, and workloads also managed
+// by a VerticalPodAutoscaler acting on the same resources
+func (k *K8sToolkit) AnalyzeHPAs(ctx context.Context, pinnedFor time.Duration) (*HPAReport, error) {
+	hpas, err := k.clientset.AutoscalingV2().HorizontalPodAutoscalers(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list horizontal pod autoscalers: %w", err)
+	}
+
+	vpas, err := k.activeVPATargets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hpas.Items = inScopeItems(k, hpas.Items)
+	report := &HPAReport{}
+	for i := range hpas.Items {
+		hpa := &hpas.Items[i]
+		ref := hpa.Spec.ScaleTargetRef
+		status := HPAStatus{
+			Namespace:       hpa.Namespace,
+			Name:            hpa.Name,
+			Target:          ref.Kind + "/" + ref.Name,
+			MinReplicas:     1,
+			MaxReplicas:     hpa.Spec.MaxReplicas,
+			CurrentReplicas: hpa.Status.CurrentReplicas,
+			DesiredReplicas: hpa.Status.DesiredReplicas,
+		}
+		if hpa.Spec.MinReplicas != nil {
+			status.MinReplicas = *hpa.Spec.MinReplicas
+		}
+
+		resourceMetrics := make(map[corev1.ResourceName]bool)
+		for _, metric := range hpa.Spec.Metrics {
+			status.Metrics = append(status.Metrics, describeMetric(metric, hpa.Status.CurrentMetrics))
+			if metric.Type == autoscalingv2.ResourceMetricSourceType && metric.Resource != nil {
+				resourceMetrics[metric.Resource.Name] = true
+			}
+		}
+
+		add := func(rule, severity, message, remediation string) {
+			status.Issues = append(status.Issues, rule)
+			report.Findings = append(report.Findings, Finding{
+				Source:      "hpa",
+				RuleID:      "hpa/" + rule,
+				Severity:    severity,
+				Namespace:   hpa.Namespace,
+				Resource:    "hpa/" + hpa.Name,
+				Message:     message,
+				Remediation: remediation,
+			})
+		}
+
+		if active := hpaCondition(hpa, autoscalingv2.ScalingActive); active != nil && active.Status == corev1.ConditionFalse {
+			add("missing-metrics", "High",
+				fmt.Sprintf("cannot compute a replica count: %s: %s", active.Reason, active.Message),
+				"check that metrics-server or the custom metrics adapter serves the configured metrics")
+		}
+
+		if limited := hpaCondition(hpa, autoscalingv2.ScalingLimited); limited != nil && limited.Status == corev1.ConditionTrue {
+			since := time.Since(limited.LastTransitionTime.Time)
+			if since >= pinnedFor {
+				switch {
+				case status.CurrentReplicas >= status.MaxReplicas:
+					add("pinned-max", "Medium",
+						fmt.Sprintf("at max replicas (%d) for %s", status.MaxReplicas, since.Round(time.Minute)),
+						"raise maxReplicas or lower per-pod load; the workload wants more capacity than it is allowed")
+				case status.CurrentReplicas <= status.MinReplicas:
+					add("pinned-min", "Low",
+						fmt.Sprintf("at min replicas (%d) for %s", status.MinReplicas, since.Round(time.Minute)),
+						"lower minReplicas or the target utilization so the HPA can scale in")
+				}
+			}
+		}
+
+		template, err := k.workloadTemplate(ctx, hpa.Namespace, ref.Kind, ref.Name)
+		if apierrors.IsNotFound(err) {
+			add("missing-target", "High", fmt.Sprintf("scale target %s does not exist", status.Target),
+				"fix spec.scaleTargetRef or delete the HPA")
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to get %s %s/%s: %w", ref.Kind, hpa.Namespace, ref.Name, err)
+		} else if template != nil {
+			for name := range resourceMetrics {
+				if missing := containersWithoutRequest(template.Spec.Containers, name); len(missing) > 0 {
+					add("missing-requests", "High",
+						fmt.Sprintf("scales on %s but containers %s set no %s request", name, strings.Join(missing, ", "), name),
+						fmt.Sprintf("set a %s request on every container; utilization is a percentage of the request", name))
+				}
+			}
+		}
+
+		if vpaResources, ok := vpas[hpa.Namespace+"/"+ref.Kind+"/"+ref.Name]; ok {
+			for name := range resourceMetrics {
+				if vpaResources[name] {
+					add("vpa-conflict", "Medium",
+						fmt.Sprintf("a VerticalPodAutoscaler also adjusts %s for %s", name, status.Target),
+						"scale the HPA on a custom or external metric, or set the VPA to updateMode Off")
+					break
+				}
+			}
+		}
+
+		report.HPAs = append(report.HPAs, status)
+	}
+
+	sort.Slice(report.HPAs, func(i, j int) bool {
+		if report.HPAs[i].Namespace != report.HPAs[j].Namespace {
+			return report.HPAs[i].Namespace < report.HPAs[j].Namespace
+		}
+		return report.HPAs[i].Name < report.HPAs[j].Name
+	})
+	sortFindings(report.Findings)
+	return report, nil
+}
+
+// containersWithoutRequest lists containers with no request for the resource
+func containersWithoutRequest(containers []corev1.Container, name corev1.ResourceName) []string {
+	var missing []string
+	for _, container := range containers {
+		if _, ok := container.Resources.Requests[name]; !ok {
+			missing = append(missing, container.Name)
+		}
+	}
+	return missing
+}
+
+// activeVPATargets maps namespace/kind/name of workloads targeted by a VPA
+// that applies its recommendations to the resources it controls. It returns
+// an empty map when the VPA CRD is not installed.
+func (k *K8sToolkit) activeVPATargets(ctx context.Context) (map[string]map[corev1.ResourceName]bool, error) {
+	targets := make(map[string]map[corev1.ResourceName]bool)
+	if k.dynamicClient == nil {
+		return targets, nil
+	}
+
+	list, err := k.dynamicClient.Resource(vpaResource).Namespace(k.namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return targets, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list vertical pod autoscalers: %w", err)
+	}
+
+	for _, vpa := range list.Items {
+		spec, _ := vpa.Object["spec"].(map[string]interface{})
+		if policy, ok := spec["updatePolicy"].(map[string]interface{}); ok && policy["updateMode"] == "Off" {
+			continue
+		}
+		ref, _ := spec["targetRef"].(map[string]interface{})
+		kind, _ := ref["kind"].(string)
+		name, _ := ref["name"].(string)
+
+		// Without a resource policy the VPA controls both CPU and memory
+		resources := map[corev1.ResourceName]bool{corev1.ResourceCPU: true, corev1.ResourceMemory: true}
+		if policy, ok := spec["resourcePolicy"].(map[string]interface{}); ok {
+			if containers, ok := policy["containerPolicies"].([]interface{}); ok && len(containers) > 0 {
+				resources = make(map[corev1.ResourceName]bool)
+				for _, c := range containers {
+					cp, _ := c.(map[string]interface{})
+					if cp["mode"] == "Off" {
+						continue
+					}
+					controlled, ok := cp["controlledResources"].([]interface{})
+					if !ok {
+						resources[corev1.ResourceCPU] = true
+						resources[corev1.ResourceMemory] = true
+						continue
+					}
+					for _, r := range controlled {
+						if s, ok := r.(string); ok {
+							resources[corev1.ResourceName(s)] = true
+						}
+					}
+				}
+			}
+		}
+		targets[vpa.GetNamespace()+"/"+kind+"/"+name] = resources
+	}
+	return targets, nil
+}
+
+// PrintHPAReport prints each HPA with its metrics followed by the findings
+func (k *K8sToolkit) PrintHPAReport(report *HPAReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Printf("Horizontal Pod Autoscalers\n")
+	fmt.Printf("%-20s %-30s %-35s %-12s %-40s %s\n", "NAMESPACE", "NAME", "TARGET", "REPLICAS", "METRICS", "ISSUES")
+	for _, h := range report.HPAs {
+		issues := "-"
+		if len(h.Issues) > 0 {
+			issues = strings.Join(h.Issues, ",")
+		}
+		replicas := fmt.Sprintf("%d (%d-%d)", h.CurrentReplicas, h.MinReplicas, h.MaxReplicas)
+		fmt.Printf("%-20s %-30s %-35s %-12s %-40s %s\n", h.Namespace, h.Name, h.Target, replicas, strings.Join(h.Metrics, ", "), issues)
+	}
+	fmt.Println()
+
+	k.PrintFindings("HPA Effectiveness Report", report.Findings)
+}
+
+// createHPACmd creates the hpa command
+func createHPACmd() *cobra.Command {
+	var pinnedFor time.Duration
+	var failOn string
+
+	hpaCmd := &cobra.Command{
+		Use:   "hpa",
+		Short: "Analyze HorizontalPodAutoscaler effectiveness",
+		Long: `Inspects HorizontalPodAutoscalers for targets pinned at min or max replicas, missing metrics,
+scale targets without resource requests, and VerticalPodAutoscalers acting on the same
+resources, with a recommendation for each finding.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			report, err := toolkit.AnalyzeHPAs(context.Background(), pinnedFor)
+			if err != nil {
+				logger.Fatalf("Failed to analyze HPAs: %v", err)
+			}
+
+			toolkit.PrintHPAReport(report)
+			exitOnFindings(report.Findings, failOn)
+		},
+	}
+
+	hpaCmd.Flags().DurationVar(&pinnedFor, "pinned-for", 6*time.Hour, "Report HPAs held at min or max replicas for at least this long")
+	hpaCmd.Flags().StringVar(&failOn, "fail-on", "none", "Exit non-zero on findings at or above this severity (Low|Medium|High|none)")
+
+	return hpaCmd
+}
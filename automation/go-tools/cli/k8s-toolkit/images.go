@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ImageRef is a container image used by a workload
+type ImageRef struct {
+	Namespace  string   `json:"namespace"`
+	Workload   string   `json:"workload"`
+	Container  string   `json:"container"`
+	Image      string   `json:"image"`
+	Registry   string   `json:"registry"`
+	Repository string   `json:"repository"`
+	Tag        string   `json:"tag,omitempty"`
+	Digest     string   `json:"digest,omitempty"`
+	Issues     []string `json:"issues,omitempty"`
+}
+
+// ImageReport is the result of an image policy audit
+type ImageReport struct {
+	SchemaVersion string     `json:"schema_version"`
+	Images        []ImageRef `json:"images"`
+	Findings      []Finding  `json:"findings"`
+}
+
+// parseImage splits an image reference into registry, repository, tag and
+// digest, applying Docker Hub defaults the same way the container runtime does
+func parseImage(image string) (registry, repository, tag, digest string) {
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		digest = name[i+1:]
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 && !strings.Contains(name[i+1:], "/") {
+		tag = name[i+1:]
+		name = name[:i]
+	}
+
+	registry = "docker.io"
+	if i := strings.Index(name, "/"); i >= 0 {
+		first := name[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			registry = first
+			name = name[i+1:]
+		}
+	}
+	if registry == "docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	return registry, name, tag, digest
+}
+
+// registryAllowed reports whether registry/repository matches an allow-list
+// entry. Entries are registries ("ghcr.io") or repository prefixes ("ghcr.io/acme").
+func registryAllowed(registry, repository string, allowed []string) bool {
+	full := registry + "/" + repository
+	for _, entry := range allowed {
+		entry = strings.TrimSuffix(entry, "/")
+		if registry == entry || strings.HasPrefix(full, entry+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// AuditImages lists every container image per workload and flags latest or
+// missing tags, references not pinned by digest, and untrusted registries
+func (k *K8sToolkit) AuditImages(ctx context.Context) (*ImageReport, error) {
+	rsOwners, err := k.replicaSetOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := viper.GetStringSlice("security.allowed_registries")
+	report := &ImageReport{SchemaVersion: imageInventorySchemaVersion}
+	seen := make(map[string]bool)
+
+	err = k.eachPod(ctx, k.namespace, k.listOptions(metav1.ListOptions{}), func(pod *corev1.Pod) error {
+		if !k.inScope(pod.Namespace) {
+			return nil
+		}
+		workload := podWorkload(pod, rsOwners)
+
+		containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+		for _, container := range containers {
+			// Replicas of a workload share images, so report each container once
+			key := workload.namespace + "/" + workload.String() + "/" + container.Name
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			ref := ImageRef{
+				Namespace: workload.namespace,
+				Workload:  workload.String(),
+				Container: container.Name,
+				Image:     container.Image,
+			}
+			ref.Registry, ref.Repository, ref.Tag, ref.Digest = parseImage(container.Image)
+
+			add := func(rule, severity, message string) {
+				ref.Issues = append(ref.Issues, rule)
+				report.Findings = append(report.Findings, Finding{
+					Source:    "security-images",
+					RuleID:    "image/" + rule,
+					Severity:  severity,
+					Namespace: ref.Namespace,
+					Resource:  ref.Workload + "/" + ref.Container,
+					Message:   message,
+				})
+			}
+
+			if ref.Digest == "" {
+				switch ref.Tag {
+				case "":
+					add("latest-tag", "Medium", fmt.Sprintf("%s has no tag and resolves to latest", ref.Image))
+				case "latest":
+					add("latest-tag", "Medium", fmt.Sprintf("%s uses the latest tag", ref.Image))
+				}
+				add("unpinned", "Low", fmt.Sprintf("%s is not pinned by digest", ref.Image))
+			}
+			if len(allowed) > 0 && !registryAllowed(ref.Registry, ref.Repository, allowed) {
+				add("untrusted-registry", "High", fmt.Sprintf("%s is not in an allowed registry", ref.Image))
+			}
+
+			report.Images = append(report.Images, ref)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	sort.Slice(report.Images, func(i, j int) bool {
+		a, b := report.Images[i], report.Images[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Workload != b.Workload {
+			return a.Workload < b.Workload
+		}
+		return a.Container < b.Container
+	})
+	sortFindings(report.Findings)
+	return report, nil
+}
+
+// imageFindings adapts AuditImages to a compliance finding source
+func (k *K8sToolkit) imageFindings(ctx context.Context) ([]Finding, error) {
+	report, err := k.AuditImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return report.Findings, nil
+}
+
+// PrintImageReport prints the image inventory per workload and the findings per namespace
+func (k *K8sToolkit) PrintImageReport(report *ImageReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+	if k.output == "sarif" {
+		printSARIF("Image Policy Report", report.Findings)
+		return
+	}
+
+	fmt.Printf("Image Inventory\n")
+	fmt.Printf("%-20s %-40s %-20s %-60s %s\n", "NAMESPACE", "WORKLOAD", "CONTAINER", "IMAGE", "ISSUES")
+	for _, ref := range report.Images {
+		issues := "-"
+		if len(ref.Issues) > 0 {
+			issues = strings.Join(ref.Issues, ",")
+		}
+		fmt.Printf("%-20s %-40s %-20s %-60s %s\n", ref.Namespace, ref.Workload, ref.Container, ref.Image, issues)
+	}
+	fmt.Println()
+
+	k.PrintFindings("Image Policy Report", report.Findings)
+}
@@ -0,0 +1,650 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/devops-excellence/automation/go-tools/pkg/scm"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// issueLabel marks the tickets managed by the issue sync
+const issueLabel = "k8s-toolkit"
+
+// fingerprintPattern finds the marker embedded in every managed ticket
+var fingerprintPattern = regexp.MustCompile(`k8s-toolkit-fingerprint: ([0-9a-f]+) source: (\S+)`)
+
+// findingFingerprint is a short stable hash of a finding's identity
+func findingFingerprint(f Finding) string {
+	sum := sha256.Sum256([]byte(findingIdentity(f)))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// trackedIssue is an open ticket filed for a finding
+type trackedIssue struct {
+	Key         string
+	Fingerprint string
+	Source      string
+	Body        string
+}
+
+// issueSink files, updates and closes tickets in an issue tracker
+type issueSink interface {
+	OpenIssues(ctx context.Context) (map[string]trackedIssue, error)
+	Create(ctx context.Context, title, body string, labels []string) (string, error)
+	Update(ctx context.Context, issue trackedIssue, body string) error
+	Close(ctx context.Context, issue trackedIssue, comment string) error
+}
+
+// parseTrackedIssue reads the fingerprint marker from a ticket body
+func parseTrackedIssue(key, body string) (trackedIssue, bool) {
+	match := fingerprintPattern.FindStringSubmatch(body)
+	if match == nil {
+		return trackedIssue{}, false
+	}
+	return trackedIssue{Key: key, Fingerprint: match[1], Source: match[2], Body: body}, true
+}
+
+// githubSink manages GitHub issues in one repository
+type githubSink struct {
+	client *scm.Client
+	repo   string
+}
+
+func (s *githubSink) OpenIssues(ctx context.Context) (map[string]trackedIssue, error) {
+	issues := make(map[string]trackedIssue)
+	path := fmt.Sprintf("/repos/%s/issues?state=open&labels=%s&per_page=100", s.repo, url.QueryEscape(issueLabel))
+	err := s.client.GetAll(ctx, path, func(raw json.RawMessage) error {
+		var issue struct {
+			Number      int             `json:"number"`
+			Body        string          `json:"body"`
+			PullRequest json.RawMessage `json:"pull_request"`
+		}
+		if err := json.Unmarshal(raw, &issue); err != nil {
+			return err
+		}
+		if issue.PullRequest != nil {
+			return nil
+		}
+		if tracked, ok := parseTrackedIssue(strconv.Itoa(issue.Number), issue.Body); ok {
+			issues[tracked.Fingerprint] = tracked
+		}
+		return nil
+	})
+	return issues, err
+}
+
+func (s *githubSink) Create(ctx context.Context, title, body string, labels []string) (string, error) {
+	var created struct {
+		Number int `json:"number"`
+	}
+	payload := map[string]interface{}{"title": title, "body": body, "labels": labels}
+	if err := s.client.Do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues", s.repo), payload, &created); err != nil {
+		return "", err
+	}
+	return "#" + strconv.Itoa(created.Number), nil
+}
+
+func (s *githubSink) Update(ctx context.Context, issue trackedIssue, body string) error {
+	return s.client.Do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/issues/%s", s.repo, issue.Key), map[string]interface{}{"body": body}, nil)
+}
+
+func (s *githubSink) Close(ctx context.Context, issue trackedIssue, comment string) error {
+	if err := s.client.Do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%s/comments", s.repo, issue.Key), map[string]interface{}{"body": comment}, nil); err != nil {
+		return err
+	}
+	payload := map[string]interface{}{"state": "closed", "state_reason": "completed"}
+	return s.client.Do(ctx, http.MethodPatch, fmt.Sprintf("/repos/%s/issues/%s", s.repo, issue.Key), payload, nil)
+}
+
+// jiraSink manages Jira issues in one project through the REST API
+type jiraSink struct {
+	baseURL        string
+	project        string
+	issueType      string
+	user           string
+	token          string
+	doneTransition string
+	http           *http.Client
+}
+
+// do sends a JSON request to the Jira API and decodes the response into v when non-nil
+func (s *jiraSink) do(ctx context.Context, method, path string, body, v interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.baseURL+path, payload)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.user, s.token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s %s", method, path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if v == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *jiraSink) OpenIssues(ctx context.Context) (map[string]trackedIssue, error) {
+	issues := make(map[string]trackedIssue)
+	jql := fmt.Sprintf(`project = "%s" AND labels = "%s" AND statusCategory != Done`, s.project, issueLabel)
+	for startAt := 0; ; {
+		var page struct {
+			Total  int `json:"total"`
+			Issues []struct {
+				Key    string `json:"key"`
+				Fields struct {
+					Description string `json:"description"`
+				} `json:"fields"`
+			} `json:"issues"`
+		}
+		query := map[string]interface{}{"jql": jql, "startAt": startAt, "maxResults": 100, "fields": []string{"description"}}
+		if err := s.do(ctx, http.MethodPost, "/rest/api/2/search", query, &page); err != nil {
+			return nil, err
+		}
+		for _, issue := range page.Issues {
+			if tracked, ok := parseTrackedIssue(issue.Key, issue.Fields.Description); ok {
+				issues[tracked.Fingerprint] = tracked
+			}
+		}
+		startAt += len(page.Issues)
+		if len(page.Issues) == 0 || startAt >= page.Total {
+			return issues, nil
+		}
+	}
+}
+
+func (s *jiraSink) Create(ctx context.Context, title, body string, labels []string) (string, error) {
+	var created struct {
+		Key string `json:"key"`
+	}
+	payload := map[string]interface{}{"fields": map[string]interface{}{
+		"project":     map[string]string{"key": s.project},
+		"issuetype":   map[string]string{"name": s.issueType},
+		"summary":     title,
+		"description": body,
+		"labels":      labels,
+	}}
+	if err := s.do(ctx, http.MethodPost, "/rest/api/2/issue", payload, &created); err != nil {
+		return "", err
+	}
+	return created.Key, nil
+}
+
+func (s *jiraSink) Update(ctx context.Context, issue trackedIssue, body string) error {
+	payload := map[string]interface{}{"fields": map[string]interface{}{"description": body}}
+	return s.do(ctx, http.MethodPut, "/rest/api/2/issue/"+issue.Key, payload, nil)
+}
+
+func (s *jiraSink) Close(ctx context.Context, issue trackedIssue, comment string) error {
+	if err := s.do(ctx, http.MethodPost, "/rest/api/2/issue/"+issue.Key+"/comment", map[string]string{"body": comment}, nil); err != nil {
+		return err
+	}
+
+	var transitions struct {
+		Transitions []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"transitions"`
+	}
+	if err := s.do(ctx, http.MethodGet, "/rest/api/2/issue/"+issue.Key+"/transitions", nil, &transitions); err != nil {
+		return err
+	}
+	for _, transition := range transitions.Transitions {
+		if strings.EqualFold(transition.Name, s.doneTransition) {
+			payload := map[string]interface{}{"transition": map[string]string{"id": transition.ID}}
+			return s.do(ctx, http.MethodPost, "/rest/api/2/issue/"+issue.Key+"/transitions", payload, nil)
+		}
+	}
+	return fmt.Errorf("issue %s has no %q transition", issue.Key, s.doneTransition)
+}
+
+// newIssueSink creates the sink named by --sink from the issues settings
+func newIssueSink(ctx context.Context, name string) (issueSink, error) {
+	switch name {
+	case "github":
+		repo := viper.GetString("issues.github.repo")
+		if repo == "" {
+			return nil, fmt.Errorf("issues.github.repo is not set")
+		}
+		token, err := secretSetting(ctx, "issues.github.token")
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			token = os.Getenv("GITHUB_TOKEN")
+		}
+		if token == "" {
+			return nil, fmt.Errorf("no GitHub token: set issues.github.token or GITHUB_TOKEN")
+		}
+		return &githubSink{client: scm.NewClient(viper.GetString("issues.github.url"), scm.StaticToken(token)), repo: repo}, nil
+	case "jira":
+		sink := &jiraSink{
+			baseURL:        strings.TrimRight(viper.GetString("issues.jira.url"), "/"),
+			project:        viper.GetString("issues.jira.project"),
+			issueType:      viper.GetString("issues.jira.issue_type"),
+			user:           viper.GetString("issues.jira.user"),
+			doneTransition: viper.GetString("issues.jira.done_transition"),
+			http:           &http.Client{Timeout: 30 * time.Second},
+		}
+		token, err := secretSetting(ctx, "issues.jira.token")
+		if err != nil {
+			return nil, err
+		}
+		sink.token = token
+		if sink.token == "" {
+			sink.token = os.Getenv("JIRA_TOKEN")
+		}
+		if sink.baseURL == "" || sink.project == "" || sink.user == "" || sink.token == "" {
+			return nil, fmt.Errorf("issues.jira.url, issues.jira.project, issues.jira.user and a token (issues.jira.token or JIRA_TOKEN) are required")
+		}
+		return sink, nil
+	default:
+		return nil, fmt.Errorf("unknown issue sink %q (use github or jira)", name)
+	}
+}
+
+// issueState counts the consecutive syncs each finding has been seen in, so
+// only persistent findings are filed
+type issueState struct {
+	Seen map[string]seenFinding `json:"seen"`
+}
+
+type seenFinding struct {
+	Source string `json:"source"`
+	Runs   int    `json:"runs"`
+}
+
+// issueStatePath returns the state file location from issues.state_file,
+// defaulting to the user cache directory
+func issueStatePath() (string, error) {
+	if path := viper.GetString("issues.state_file"); path != "" {
+		return path, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "k8s-toolkit", "issues-state.json"), nil
+}
+
+func loadIssueState(path string) (*issueState, error) {
+	state := &issueState{Seen: make(map[string]seenFinding)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if state.Seen == nil {
+		state.Seen = make(map[string]seenFinding)
+	}
+	return state, nil
+}
+
+func saveIssueState(path string, state *issueState) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// IssueSyncOptions selects the findings that are filed as tickets
+type IssueSyncOptions struct {
+	Sources     []string
+	MinSeverity string
+	MinRuns     int
+	DryRun      bool
+
+	// Findings enables the opt-in finding sources
+	Findings FindingSourceOptions
+}
+
+// IssueSyncResult lists the tickets touched by a sync
+type IssueSyncResult struct {
+	Created []string          `json:"created,omitempty"`
+	Updated []string          `json:"updated,omitempty"`
+	Closed  []string          `json:"closed,omitempty"`
+	Pending []string          `json:"pending,omitempty"`
+	Sources map[string]string `json:"sources"`
+	Errors  []string          `json:"errors,omitempty"`
+	DryRun  bool              `json:"dry_run,omitempty"`
+}
+
+// issueLabels returns the labels of a finding's ticket
+func issueLabels(f Finding, team string) []string {
+	labels := []string{issueLabel, "severity-" + strings.ToLower(f.Severity), "source-" + f.Source}
+	if team != "" {
+		labels = append(labels, "team-"+team)
+	}
+	return labels
+}
+
+// issueBody renders a finding as a ticket description ending in the
+// fingerprint marker
+func issueBody(f Finding, fingerprint, team string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", f.Message)
+	fmt.Fprintf(&b, "- Rule: %s\n", f.RuleID)
+	fmt.Fprintf(&b, "- Severity: %s\n", f.Severity)
+	fmt.Fprintf(&b, "- Resource: %s\n", orDash(strings.TrimPrefix(f.Namespace+"/"+f.Resource, "/")))
+	fmt.Fprintf(&b, "- Source: %s\n", f.Source)
+	if team != "" {
+		fmt.Fprintf(&b, "- Team: %s\n", team)
+	}
+	if len(f.Controls) > 0 {
+		fmt.Fprintf(&b, "- Controls: %s\n", strings.Join(f.Controls, ", "))
+	}
+	if f.Remediation != "" {
+		fmt.Fprintf(&b, "\nRemediation: %s\n", f.Remediation)
+	}
+	fmt.Fprintf(&b, "\nThis ticket is managed by k8s-toolkit and closes when the finding clears.\n")
+	fmt.Fprintf(&b, "<!-- k8s-toolkit-fingerprint: %s source: %s -->", fingerprint, f.Source)
+	return b.String()
+}
+
+// SyncIssues runs the finding sources once and reconciles the tickets in the
+// sink with the findings at or above MinSeverity. A finding is filed once it
+// has been seen in MinRuns consecutive syncs; an open ticket is updated when
+// the finding's details change and closed when its source ran and no longer
+// reports the finding at any severity. Tickets and persistence counts of
+// sources that failed or were not selected this run are left alone.
+func (k *K8sToolkit) SyncIssues(ctx context.Context, sink issueSink, opts IssueSyncOptions) (*IssueSyncResult, error) {
+	result := &IssueSyncResult{Sources: make(map[string]string), DryRun: opts.DryRun}
+
+	wanted := make(map[string]bool)
+	for _, name := range opts.Sources {
+		wanted[name] = true
+	}
+	ran := make(map[string]bool)
+	reported := make(map[string]bool)
+	current := make(map[string]Finding)
+	for _, source := range k.findingSources(opts.Findings) {
+		if len(wanted) > 0 && !wanted[source.name] {
+			continue
+		}
+		findings, err := source.run(ctx)
+		if err != nil {
+			result.Sources[source.name] = fmt.Sprintf("error (%s): %v", errorCategory(err), err)
+			continue
+		}
+		ran[source.name] = true
+		result.Sources[source.name] = fmt.Sprintf("ok (%d findings)", len(findings))
+		sortFindings(findings)
+		for _, f := range findings {
+			// Pods of one workload share a fingerprint; the first one stands in
+			fingerprint := findingFingerprint(f)
+			reported[fingerprint] = true
+			if severityRank[f.Severity] < severityRank[opts.MinSeverity] {
+				continue
+			}
+			if _, ok := current[fingerprint]; !ok {
+				current[fingerprint] = f
+			}
+		}
+	}
+
+	statePath, err := issueStatePath()
+	if err != nil {
+		return nil, err
+	}
+	previous, err := loadIssueState(statePath)
+	if err != nil {
+		return nil, err
+	}
+	state := &issueState{Seen: make(map[string]seenFinding)}
+	for fingerprint, seen := range previous.Seen {
+		if !ran[seen.Source] {
+			state.Seen[fingerprint] = seen
+		}
+	}
+	for fingerprint, f := range current {
+		state.Seen[fingerprint] = seenFinding{Source: f.Source, Runs: previous.Seen[fingerprint].Runs + 1}
+	}
+
+	owner := make(map[string]string)
+	teams, err := k.teamNamespaces(ctx)
+	if err != nil {
+		k.log().Warnf("failed to resolve team ownership: %v", err)
+	}
+	for team, namespaces := range teams {
+		for _, namespace := range namespaces {
+			owner[namespace] = team
+		}
+	}
+
+	open, err := sink.OpenIssues(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list open issues: %w", err)
+	}
+
+	fingerprints := make([]string, 0, len(current))
+	for fingerprint := range current {
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	sort.Strings(fingerprints)
+
+	for _, fingerprint := range fingerprints {
+		f := current[fingerprint]
+		label := fmt.Sprintf("%s %s", f.RuleID, strings.TrimPrefix(f.Namespace+"/"+f.Resource, "/"))
+		team := owner[f.Namespace]
+		body := issueBody(f, fingerprint, team)
+
+		if issue, ok := open[fingerprint]; ok {
+			if strings.TrimSpace(strings.ReplaceAll(issue.Body, "\r\n", "\n")) == strings.TrimSpace(body) {
+				continue
+			}
+			if !opts.DryRun {
+				if err := sink.Update(ctx, issue, body); err != nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("update %s: %v", issue.Key, err))
+					continue
+				}
+			}
+			result.Updated = append(result.Updated, fmt.Sprintf("%s %s", issue.Key, label))
+			continue
+		}
+
+		if runs := state.Seen[fingerprint].Runs; runs < opts.MinRuns {
+			result.Pending = append(result.Pending, fmt.Sprintf("%s (seen %d/%d runs)", label, runs, opts.MinRuns))
+			continue
+		}
+		key := "(new)"
+		if !opts.DryRun {
+			title := fmt.Sprintf("[%s] %s", f.Severity, label)
+			if key, err = sink.Create(ctx, title, body, issueLabels(f, team)); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("create %s: %v", label, err))
+				continue
+			}
+		}
+		result.Created = append(result.Created, fmt.Sprintf("%s %s", key, label))
+	}
+
+	for fingerprint, issue := range open {
+		// Only close what this run evaluated: the ticket's source succeeded
+		// and no longer reports the finding, even below MinSeverity
+		if !ran[issue.Source] || reported[fingerprint] {
+			continue
+		}
+		if !opts.DryRun {
+			comment := fmt.Sprintf("The finding was not reported by k8s-toolkit on %s; closing.", time.Now().UTC().Format(time.RFC3339))
+			if err := sink.Close(ctx, issue, comment); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("close %s: %v", issue.Key, err))
+				continue
+			}
+		}
+		result.Closed = append(result.Closed, issue.Key)
+	}
+	sort.Strings(result.Closed)
+
+	if !opts.DryRun {
+		if err := saveIssueState(statePath, state); err != nil {
+			return result, fmt.Errorf("failed to save issue state: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// PrintIssueSyncResult prints the tickets touched by a sync
+func (k *K8sToolkit) PrintIssueSyncResult(result *IssueSyncResult) {
+	if k.output == "json" {
+		printJSON(result)
+		return
+	}
+
+	if result.DryRun {
+		fmt.Println("Dry run: no tickets were changed")
+	}
+	sections := []struct {
+		title string
+		items []string
+	}{
+		{"Created", result.Created},
+		{"Updated", result.Updated},
+		{"Closed", result.Closed},
+		{"Pending (not yet persistent)", result.Pending},
+		{"Errors", result.Errors},
+	}
+	for _, section := range sections {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Printf("%s:\n", section.title)
+		for _, item := range section.items {
+			fmt.Printf("  %s\n", item)
+		}
+	}
+	if len(result.Created)+len(result.Updated)+len(result.Closed)+len(result.Pending)+len(result.Errors) == 0 {
+		fmt.Println("Tickets are up to date")
+	}
+
+	names := make([]string, 0, len(result.Sources))
+	for name := range result.Sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  source %-26s %s\n", name, result.Sources[name])
+	}
+}
+
+// createIssuesCmd creates the issues command
+func createIssuesCmd() *cobra.Command {
+	issuesCmd := &cobra.Command{
+		Use:   "issues",
+		Short: "File tickets for persistent findings",
+	}
+
+	var sinkName string
+	var opts IssueSyncOptions
+	var interval time.Duration
+
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Open, update and close tickets to match current findings",
+		Long: `Runs the finding sources and keeps one ticket per finding fingerprint in GitHub Issues or Jira.
+Findings at or above --min-severity are filed once they have been seen in --min-runs consecutive
+syncs, labeled with their severity, source and owning team (see digest --team-label). Open tickets
+are updated when the finding changes and closed automatically once it clears. Tickets are matched
+by a fingerprint marker in their description, so they can be retitled or reassigned freely.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, ok := severityRank[opts.MinSeverity]; !ok {
+				logger.Fatalf("Invalid --min-severity %q (use Critical, High, Medium or Low)", opts.MinSeverity)
+			}
+			if offline() {
+				logger.Fatalf("Issue sync needs the issue tracker and is disabled in offline mode")
+			}
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			sink, err := newIssueSink(context.Background(), sinkName)
+			if err != nil {
+				logger.Fatalf("Failed to configure issue sink: %v", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			for {
+				result, err := toolkit.SyncIssues(ctx, sink, opts)
+				if err != nil {
+					logger.Fatalf("Failed to sync issues: %v", err)
+				}
+				toolkit.PrintIssueSyncResult(result)
+
+				if interval <= 0 {
+					if len(result.Errors) > 0 {
+						os.Exit(1)
+					}
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(interval):
+				}
+			}
+		},
+	}
+
+	syncCmd.Flags().StringVar(&sinkName, "sink", "github", "Issue tracker to sync with: github or jira")
+	syncCmd.Flags().StringSliceVar(&opts.Sources, "sources", nil, "Finding sources to include (empty for all)")
+	syncCmd.Flags().StringVar(&opts.MinSeverity, "min-severity", "Critical", "Lowest severity that is filed")
+	syncCmd.Flags().IntVar(&opts.MinRuns, "min-runs", 2, "Consecutive syncs a finding must be seen in before it is filed")
+	syncCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Show what would change without touching tickets")
+	addFindingSourceFlags(syncCmd, &opts.Findings)
+	syncCmd.Flags().BoolVar(&opts.Findings.AWS, "aws", false, "Audit IAM and S3 in the current AWS account (security-iam and security-s3 sources)")
+	syncCmd.Flags().DurationVar(&interval, "interval", 0, "Keep syncing on this interval, e.g. 1h")
+
+	issuesCmd.AddCommand(syncCmd)
+	return issuesCmd
+}
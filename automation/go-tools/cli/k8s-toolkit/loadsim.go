@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// loadsimLabel marks every object loadsim creates; the value is the run ID
+	loadsimLabel = "k8s-toolkit.devops/loadsim"
+	// loadsimExpiresAnnotation records when loadsim cleanup may delete a namespace
+	loadsimExpiresAnnotation = "k8s-toolkit.devops/loadsim-expires-at"
+)
+
+// LoadSimOptions sizes a synthetic load. Counts other than Namespaces are
+// per namespace.
+type LoadSimOptions struct {
+	Prefix        string
+	Namespaces    int
+	Deployments   int
+	Replicas      int32
+	Pods          int
+	ConfigMaps    int
+	ConfigMapSize int
+	Image         string
+	TTL           time.Duration
+	Parallel      int
+}
+
+// LoadSimReport is the outcome of a loadsim create run
+type LoadSimReport struct {
+	Run           string         `json:"run"`
+	StartedAt     time.Time      `json:"started_at"`
+	ExpiresAt     time.Time      `json:"expires_at"`
+	Namespaces    []string       `json:"namespaces"`
+	Created       map[string]int `json:"created"`
+	Failed        map[string]int `json:"failed"`
+	Errors        []string       `json:"errors,omitempty"`
+	DurationMs    int64          `json:"duration_ms"`
+	ObjectsPerSec float64        `json:"objects_per_sec"`
+}
+
+// LoadSimNamespace is a namespace created by loadsim
+type LoadSimNamespace struct {
+	Name      string    `json:"name"`
+	Run       string    `json:"run"`
+	Created   time.Time `json:"created"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Expired   bool      `json:"expired"`
+}
+
+// loadsimObject is one object to create inside a synthetic namespace
+type loadsimObject struct {
+	kind   string
+	create func(ctx context.Context) error
+}
+
+// RunLoadSim creates the synthetic namespaces and then fills them with
+// Deployments, bare pods and ConfigMaps using Parallel workers. Objects that
+// fail to create are counted and the run continues.
+func (k *K8sToolkit) RunLoadSim(ctx context.Context, opts LoadSimOptions, m *mutation) (*LoadSimReport, error) {
+	start := time.Now()
+	report := &LoadSimReport{
+		Run:       fmt.Sprintf("%s-%s", opts.Prefix, start.UTC().Format("20060102-150405")),
+		StartedAt: start,
+		ExpiresAt: start.Add(opts.TTL),
+		Created:   make(map[string]int),
+		Failed:    make(map[string]int),
+	}
+	labels := map[string]string{loadsimLabel: report.Run}
+
+	var objects []loadsimObject
+	for i := 0; i < opts.Namespaces; i++ {
+		name := fmt.Sprintf("%s-%d", report.Run, i)
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      labels,
+			Annotations: map[string]string{loadsimExpiresAnnotation: report.ExpiresAt.UTC().Format(time.RFC3339)},
+		}}
+		_, err := k.clientset.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+		m.record("create", "Namespace "+name, err)
+		if err != nil {
+			return report, fmt.Errorf("failed to create namespace %s: %w", name, err)
+		}
+		report.Namespaces = append(report.Namespaces, name)
+		report.Created["Namespace"]++
+		objects = append(objects, k.loadsimObjects(name, labels, opts)...)
+	}
+
+	var mu sync.Mutex
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				err := objects[i].create(ctx)
+				mu.Lock()
+				if err != nil {
+					report.Failed[objects[i].kind]++
+					if len(report.Errors) < 10 {
+						report.Errors = append(report.Errors, err.Error())
+					}
+				} else {
+					report.Created[objects[i].kind]++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range objects {
+		if ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	report.DurationMs = elapsed.Milliseconds()
+	total := 0
+	for _, n := range report.Created {
+		total += n
+	}
+	report.ObjectsPerSec = float64(total) / elapsed.Seconds()
+	return report, ctx.Err()
+}
+
+// loadsimObjects builds the objects of one synthetic namespace. Pods run
+// the pause image with minimal requests so the load stresses the API server
+// and controllers rather than node capacity.
+func (k *K8sToolkit) loadsimObjects(namespace string, labels map[string]string, opts LoadSimOptions) []loadsimObject {
+	var objects []loadsimObject
+	container := corev1.Container{
+		Name:  "pause",
+		Image: opts.Image,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("1m"),
+				corev1.ResourceMemory: resource.MustParse("4Mi"),
+			},
+		},
+	}
+	withLabels := func(extra map[string]string) map[string]string {
+		merged := map[string]string{}
+		for key, value := range labels {
+			merged[key] = value
+		}
+		for key, value := range extra {
+			merged[key] = value
+		}
+		return merged
+	}
+	payload := strings.Repeat("x", opts.ConfigMapSize)
+
+	for i := 0; i < opts.Deployments; i++ {
+		name := fmt.Sprintf("deploy-%d", i)
+		podLabels := withLabels(map[string]string{"app": name})
+		replicas := opts.Replicas
+		deployment := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: podLabels},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": name}},
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+					Spec:       corev1.PodSpec{Containers: []corev1.Container{container}},
+				},
+			},
+		}
+		objects = append(objects, loadsimObject{kind: "Deployment", create: func(ctx context.Context) error {
+			_, err := k.clientset.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+			return err
+		}})
+	}
+
+	for i := 0; i < opts.Pods; i++ {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("pod-%d", i), Namespace: namespace, Labels: withLabels(nil)},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{container}},
+		}
+		objects = append(objects, loadsimObject{kind: "Pod", create: func(ctx context.Context) error {
+			_, err := k.clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+			return err
+		}})
+	}
+
+	for i := 0; i < opts.ConfigMaps; i++ {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("config-%d", i), Namespace: namespace, Labels: withLabels(nil)},
+			Data:       map[string]string{"payload": payload},
+		}
+		objects = append(objects, loadsimObject{kind: "ConfigMap", create: func(ctx context.Context) error {
+			_, err := k.clientset.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{})
+			return err
+		}})
+	}
+
+	return objects
+}
+
+// ListLoadSimNamespaces returns the namespaces created by loadsim, optionally
+// of one run only
+func (k *K8sToolkit) ListLoadSimNamespaces(ctx context.Context, run string) ([]LoadSimNamespace, error) {
+	selector := loadsimLabel
+	if run != "" {
+		selector = loadsimLabel + "=" + run
+	}
+	namespaces, err := k.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list loadsim namespaces: %w", err)
+	}
+
+	now := time.Now()
+	var result []LoadSimNamespace
+	for _, ns := range namespaces.Items {
+		entry := LoadSimNamespace{Name: ns.Name, Run: ns.Labels[loadsimLabel], Created: ns.CreationTimestamp.Time}
+		if value, ok := ns.Annotations[loadsimExpiresAnnotation]; ok {
+			expires, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				k.log().Warnf("namespace %s has invalid %s annotation: %v", ns.Name, loadsimExpiresAnnotation, err)
+			} else {
+				entry.ExpiresAt = expires
+				entry.Expired = now.After(expires)
+			}
+		}
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// PrintLoadSimReport prints what a loadsim create run created
+func (k *K8sToolkit) PrintLoadSimReport(report *LoadSimReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Printf("Load Simulation %s\n", report.Run)
+	fmt.Printf("Namespaces: %d (expire %s)\n", len(report.Namespaces), report.ExpiresAt.Format("2006-01-02 15:04:05"))
+	for _, kind := range []string{"Namespace", "Deployment", "Pod", "ConfigMap"} {
+		if report.Created[kind]+report.Failed[kind] == 0 {
+			continue
+		}
+		fmt.Printf("  %-12s %6d created  %4d failed\n", kind, report.Created[kind], report.Failed[kind])
+	}
+	fmt.Printf("Duration: %s (%.1f objects/s)\n", time.Duration(report.DurationMs)*time.Millisecond, report.ObjectsPerSec)
+	if len(report.Errors) > 0 {
+		fmt.Println("\nFirst errors:")
+		for _, e := range report.Errors {
+			fmt.Printf("  %s\n", e)
+		}
+	}
+	fmt.Printf("\nRemove with: k8s-toolkit loadsim cleanup --run %s --apply\n", report.Run)
+}
+
+// PrintLoadSimNamespaces prints the loadsim namespaces selected for cleanup
+func (k *K8sToolkit) PrintLoadSimNamespaces(namespaces []LoadSimNamespace) {
+	if k.filtered(namespaces) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(namespaces)
+		return
+	}
+
+	if len(namespaces) == 0 {
+		fmt.Println("No loadsim namespaces to clean up")
+		return
+	}
+	fmt.Printf("%-40s %-30s %-20s\n", "NAMESPACE", "RUN", "EXPIRES")
+	for _, ns := range namespaces {
+		expires := "-"
+		if !ns.ExpiresAt.IsZero() {
+			expires = ns.ExpiresAt.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-40s %-30s %-20s\n", ns.Name, ns.Run, expires)
+	}
+}
+
+// createLoadSimCmd creates the loadsim command
+func createLoadSimCmd() *cobra.Command {
+	loadsimCmd := &cobra.Command{
+		Use:   "loadsim",
+		Short: "Generate synthetic Kubernetes objects for scale testing",
+		Long: `Creates large numbers of synthetic namespaces, Deployments, pods and ConfigMaps in a test cluster to
+check how the toolkit and cluster controllers behave at a multiple of production scale. Every object
+is labelled ` + loadsimLabel + `=<run>, and every namespace carries an expiry time that
+loadsim cleanup uses. Never point this at a production cluster.`,
+	}
+
+	loadsimCmd.AddCommand(createLoadSimCreateCmd())
+	loadsimCmd.AddCommand(createLoadSimCleanupCmd())
+
+	return loadsimCmd
+}
+
+// createLoadSimCreateCmd creates the loadsim create command
+func createLoadSimCreateCmd() *cobra.Command {
+	opts := LoadSimOptions{}
+
+	createCmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create a synthetic load",
+		Long: `Creates --namespaces namespaces. Each one holds --deployments Deployments of --replicas pause pods,
+--pods bare pods and --configmaps ConfigMaps of --configmap-size bytes. Pods request 1m CPU and 4Mi
+memory so the load stresses the API server, scheduler and controllers, not node capacity.
+Throughput is bounded by --parallel and the client rate limits.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			if opts.Namespaces < 1 || opts.Parallel < 1 {
+				logger.Fatalf("--namespaces and --parallel must be at least 1")
+			}
+
+			perNamespace := opts.Deployments*(1+int(opts.Replicas)) + opts.Pods + opts.ConfigMaps
+			m, err := beginMutation("loadsim", fmt.Sprintf("About to create %d namespaces with about %d objects each, expiring in %s.",
+				opts.Namespaces, perNamespace, opts.TTL))
+			if err != nil {
+				logger.Fatalf("Loadsim aborted: %v", err)
+			}
+
+			// Stop creating on interrupt; what exists is cleaned up by loadsim cleanup
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			report, err := toolkit.RunLoadSim(ctx, opts, m)
+			if report != nil {
+				toolkit.PrintLoadSimReport(report)
+			}
+			if err != nil {
+				logger.Fatalf("Load simulation stopped: %v", err)
+			}
+		},
+	}
+
+	createCmd.Flags().StringVar(&opts.Prefix, "prefix", "loadsim", "Prefix of the run ID and namespace names")
+	createCmd.Flags().IntVar(&opts.Namespaces, "namespaces", 10, "Number of namespaces to create")
+	createCmd.Flags().IntVar(&opts.Deployments, "deployments", 10, "Deployments per namespace")
+	createCmd.Flags().Int32Var(&opts.Replicas, "replicas", 2, "Replicas per Deployment")
+	createCmd.Flags().IntVar(&opts.Pods, "pods", 0, "Bare pods per namespace")
+	createCmd.Flags().IntVar(&opts.ConfigMaps, "configmaps", 20, "ConfigMaps per namespace")
+	createCmd.Flags().IntVar(&opts.ConfigMapSize, "configmap-size", 1024, "Payload size of each ConfigMap in bytes")
+	createCmd.Flags().StringVar(&opts.Image, "image", "registry.k8s.io/pause:3.9", "Image of the synthetic pods")
+	createCmd.Flags().DurationVar(&opts.TTL, "ttl", 4*time.Hour, "Time after which loadsim cleanup removes the namespaces")
+	createCmd.Flags().IntVar(&opts.Parallel, "parallel", 20, "Concurrent create requests")
+
+	return createCmd
+}
+
+// createLoadSimCleanupCmd creates the loadsim cleanup command
+func createLoadSimCleanupCmd() *cobra.Command {
+	var run string
+	var all, apply bool
+
+	cleanupCmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Delete expired synthetic namespaces",
+		Long: `Deletes loadsim namespaces whose TTL has passed, together with everything in them. --run limits
+cleanup to one run and --all ignores the TTL. Runs as a dry run unless --apply is given; run it
+from a CronJob to enforce the TTL.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx := context.Background()
+			namespaces, err := toolkit.ListLoadSimNamespaces(ctx, run)
+			if err != nil {
+				logger.Fatalf("Failed to find loadsim namespaces: %v", err)
+			}
+			var selected []LoadSimNamespace
+			for _, ns := range namespaces {
+				if all || ns.Expired {
+					selected = append(selected, ns)
+				}
+			}
+
+			toolkit.PrintLoadSimNamespaces(selected)
+
+			if !apply {
+				if len(selected) > 0 && toolkit.output != "json" {
+					fmt.Printf("Dry run: %d namespaces would be deleted. Re-run with --apply to delete them.\n", len(selected))
+				}
+				return
+			}
+
+			if len(selected) == 0 {
+				return
+			}
+			m, err := beginMutation("loadsim", fmt.Sprintf("About to delete %d loadsim namespaces and everything in them.", len(selected)))
+			if err != nil {
+				logger.Fatalf("Loadsim cleanup aborted: %v", err)
+			}
+
+			deleted := 0
+			for _, ns := range selected {
+				err := toolkit.clientset.CoreV1().Namespaces().Delete(ctx, ns.Name, metav1.DeleteOptions{})
+				m.record("delete", "Namespace "+ns.Name, err)
+				if err != nil {
+					logger.Errorf("Failed to delete namespace %s: %v", ns.Name, err)
+					continue
+				}
+				deleted++
+			}
+			fmt.Printf("Deleted %d of %d namespaces\n", deleted, len(selected))
+		},
+	}
+
+	cleanupCmd.Flags().StringVar(&run, "run", "", "Only clean up namespaces of this run")
+	cleanupCmd.Flags().BoolVar(&all, "all", false, "Also delete namespaces whose TTL has not passed")
+	cleanupCmd.Flags().BoolVar(&apply, "apply", false, "Delete the namespaces instead of only listing them")
+
+	return cleanupCmd
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// logger writes diagnostics to stderr so stdout only carries reports
+var logger = &logrus.Logger{
+	Out:       os.Stderr,
+	Formatter: &logrus.TextFormatter{DisableTimestamp: true},
+	Hooks:     make(logrus.LevelHooks),
+	Level:     logrus.InfoLevel,
+	ExitFunc:  os.Exit,
+}
+
+// initLogging applies log_level and log_format from flags or the config file
+func initLogging() {
+	level, err := logrus.ParseLevel(viper.GetString("log_level"))
+	if err != nil {
+		logger.Fatalf("Invalid --log-level: %v", err)
+	}
+	logger.SetLevel(level)
+
+	switch format := viper.GetString("log_format"); format {
+	case "json":
+		logger.SetFormatter(&logrus.JSONFormatter{})
+	case "console", "":
+		logger.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
+	default:
+		logger.Fatalf("Invalid --log-format %q (use json or console)", format)
+	}
+}
+
+// log returns the logger with the cluster and namespace the toolkit works on
+func (k *K8sToolkit) log() *logrus.Entry {
+	fields := logrus.Fields{"cluster": k.contextName}
+	if k.namespace != "" {
+		fields["namespace"] = k.namespace
+	}
+	return logger.WithFields(fields)
+}
+
+// checkLog returns the toolkit logger with the health check being run
+func (k *K8sToolkit) checkLog(check string) *logrus.Entry {
+	return k.log().WithField("check", check)
+}
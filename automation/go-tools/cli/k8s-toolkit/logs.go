@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"os/signal"
+	"regexp"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// logColors are the ANSI colors cycled through for line prefixes
+var logColors = []string{"\033[31m", "\033[32m", "\033[33m", "\033[34m", "\033[35m", "\033[36m", "\033[91m", "\033[92m", "\033[93m", "\033[94m", "\033[95m", "\033[96m"}
+
+// LogOptions selects the pods, containers and lines shown by TailLogs
+type LogOptions struct {
+	Container  *regexp.Regexp
+	Grep       *regexp.Regexp
+	Since      time.Duration
+	Tail       int64
+	Follow     bool
+	Timestamps bool
+	Color      bool
+}
+
+// logPrinter serializes prefixed lines from concurrent streams
+type logPrinter struct {
+	mu   sync.Mutex
+	out  io.Writer
+	opts LogOptions
+}
+
+func (p *logPrinter) prefix(pod, container string) string {
+	prefix := pod + "/" + container
+	if !p.opts.Color {
+		return "[" + prefix + "] "
+	}
+	h := fnv.New32a()
+	h.Write([]byte(prefix))
+	return logColors[h.Sum32()%uint32(len(logColors))] + "[" + prefix + "]\033[0m "
+}
+
+// copy prints the lines of a log stream that match --grep and returns when
+// the stream ends
+func (p *logPrinter) copy(stream io.Reader, pod, container string) error {
+	prefix := p.prefix(pod, container)
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if p.opts.Grep != nil && !p.opts.Grep.MatchString(line) {
+			continue
+		}
+		p.mu.Lock()
+		fmt.Fprintln(p.out, prefix+line)
+		p.mu.Unlock()
+	}
+	return scanner.Err()
+}
+
+// logTarget is one container of one pod instance
+type logTarget struct {
+	namespace string
+	pod       string
+	uid       string
+	container string
+}
+
+// logTargets lists the containers of the pods matching --selector
+func (k *K8sToolkit) logTargets(ctx context.Context, opts LogOptions) ([]logTarget, error) {
+	var targets []logTarget
+	err := k.eachPod(ctx, k.namespace, k.listOptions(metav1.ListOptions{}), func(pod *corev1.Pod) error {
+		if pod.Status.Phase == corev1.PodPending {
+			return nil
+		}
+		for _, container := range append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+			if opts.Container != nil && !opts.Container.MatchString(container.Name) {
+				continue
+			}
+			targets = append(targets, logTarget{
+				namespace: pod.Namespace,
+				pod:       pod.Name,
+				uid:       string(pod.UID),
+				container: container.Name,
+			})
+		}
+		return nil
+	})
+	return targets, err
+}
+
+// streamLogs streams one container's logs starting at since, or at the
+// --since/--tail position when since is zero
+func (k *K8sToolkit) streamLogs(ctx context.Context, printer *logPrinter, target logTarget, since time.Time) error {
+	logOpts := &corev1.PodLogOptions{
+		Container:  target.container,
+		Follow:     printer.opts.Follow,
+		Timestamps: printer.opts.Timestamps,
+	}
+	switch {
+	case !since.IsZero():
+		logOpts.SinceTime = &metav1.Time{Time: since}
+	case printer.opts.Since > 0:
+		seconds := int64(printer.opts.Since.Seconds())
+		logOpts.SinceSeconds = &seconds
+	}
+	if since.IsZero() && printer.opts.Tail >= 0 {
+		logOpts.TailLines = &printer.opts.Tail
+	}
+
+	stream, err := k.clientset.CoreV1().Pods(target.namespace).GetLogs(target.pod, logOpts).Stream(ctx)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	return printer.copy(stream, target.pod, target.container)
+}
+
+// TailLogs prints the logs of every container of the pods matching the
+// selector, prefixed with pod and container names. With Follow, streams run
+// concurrently, pods are re-listed every few seconds so new or replaced pods
+// are picked up, and a stream that ends while its pod still exists is
+// reconnected from where it stopped, which covers container restarts.
+func (k *K8sToolkit) TailLogs(ctx context.Context, opts LogOptions, out io.Writer) error {
+	printer := &logPrinter{out: out, opts: opts}
+
+	targets, err := k.logTargets(ctx, opts)
+	if err != nil {
+		return fmt.Errorf("failed to list pods: %w", err)
+	}
+	if !opts.Follow {
+		for _, target := range targets {
+			if err := k.streamLogs(ctx, printer, target, time.Time{}); err != nil {
+				k.log().Warnf("failed to get logs of %s/%s: %v", target.pod, target.container, err)
+			}
+		}
+		return nil
+	}
+
+	var mu sync.Mutex
+	active := make(map[logTarget]bool)
+	var wg sync.WaitGroup
+	follow := func(target logTarget) {
+		defer wg.Done()
+		defer func() {
+			mu.Lock()
+			delete(active, target)
+			mu.Unlock()
+		}()
+
+		var since time.Time
+		for {
+			err := k.streamLogs(ctx, printer, target, since)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				// Containers that have not started yet refuse log requests
+				k.log().Warnf("log stream of %s/%s ended: %v", target.pod, target.container, err)
+			}
+
+			pod, getErr := k.clientset.CoreV1().Pods(target.namespace).Get(ctx, target.pod, metav1.GetOptions{})
+			if getErr != nil || string(pod.UID) != target.uid || pod.DeletionTimestamp != nil ||
+				pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				return
+			}
+			// Resume after the lines already printed; a restarted container
+			// only has newer lines
+			since = time.Now()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(2 * time.Second):
+			}
+		}
+	}
+
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for {
+		for _, target := range targets {
+			mu.Lock()
+			if !active[target] {
+				active[target] = true
+				wg.Add(1)
+				go follow(target)
+			}
+			mu.Unlock()
+		}
+
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return nil
+		case <-ticker.C:
+		}
+
+		if targets, err = k.logTargets(ctx, opts); err != nil && ctx.Err() == nil {
+			k.log().Warnf("failed to list pods: %v", err)
+		}
+	}
+}
+
+// createLogsCmd creates the logs command
+func createLogsCmd() *cobra.Command {
+	var opts LogOptions
+	var container, grep string
+	var noColor bool
+
+	logsCmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Tail logs of all pods matching a label selector",
+		Long: `Prints the logs of every container of the pods matching --selector in --namespace, each line
+prefixed with its pod and container name. With --follow all containers are streamed concurrently,
+new and replaced pods are picked up as they appear, and streams are reconnected when a container
+restarts. --grep keeps only lines matching a regular expression.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if container != "" {
+				re, err := regexp.Compile(container)
+				if err != nil {
+					logger.Fatalf("Invalid --container: %v", err)
+				}
+				opts.Container = re
+			}
+			if grep != "" {
+				re, err := regexp.Compile(grep)
+				if err != nil {
+					logger.Fatalf("Invalid --grep: %v", err)
+				}
+				opts.Grep = re
+			}
+			if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+				opts.Color = !noColor
+			}
+
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			if toolkit.selector == "" {
+				logger.Fatalf("A label selector is required (--selector)")
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if err := toolkit.TailLogs(ctx, opts, os.Stdout); err != nil {
+				logger.Fatalf("Failed to tail logs: %v", err)
+			}
+		},
+	}
+
+	logsCmd.Flags().StringVarP(&container, "container", "c", "", "Only show containers whose name matches this regular expression")
+	logsCmd.Flags().StringVar(&grep, "grep", "", "Only show lines matching this regular expression")
+	logsCmd.Flags().DurationVar(&opts.Since, "since", 0, "Only show lines newer than this duration, e.g. 10m")
+	logsCmd.Flags().Int64Var(&opts.Tail, "tail", -1, "Number of recent lines per container to show (-1 for all)")
+	logsCmd.Flags().BoolVarP(&opts.Follow, "follow", "f", false, "Keep streaming new lines")
+	logsCmd.Flags().BoolVar(&opts.Timestamps, "timestamps", false, "Include the timestamp of every line")
+	logsCmd.Flags().BoolVar(&noColor, "no-color", false, "Disable colored prefixes")
+
+	return logsCmd
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// smtpConfig is notifications.smtp in the config file
+type smtpConfig struct {
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+	From     string `mapstructure:"from"`
+
+	// TLS connects with implicit TLS, usually on port 465. Otherwise
+	// STARTTLS is used when the server offers it.
+	TLS bool `mapstructure:"tls"`
+}
+
+// reportRecipient is an entry under reports.email.recipients. A recipient
+// gets the digest of the teams listed, merged into one mail; without teams
+// it gets every team's namespaces.
+type reportRecipient struct {
+	To    []string `mapstructure:"to"`
+	Teams []string `mapstructure:"teams"`
+}
+
+// Mailer sends digests by mail over SMTP
+type Mailer struct {
+	smtp       smtpConfig
+	recipients []reportRecipient
+}
+
+// NewMailer builds a mailer from notifications.smtp and
+// reports.email.recipients. It returns nil when no recipients are
+// configured or external calls are disabled.
+func NewMailer() (*Mailer, error) {
+	var recipients []reportRecipient
+	if err := viper.UnmarshalKey("reports.email.recipients", &recipients); err != nil {
+		return nil, fmt.Errorf("invalid reports.email.recipients: %w", err)
+	}
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+	if offline() {
+		logger.Warnf("offline mode, report mails are disabled")
+		return nil, nil
+	}
+
+	var config smtpConfig
+	if err := viper.UnmarshalKey("notifications.smtp", &config); err != nil {
+		return nil, fmt.Errorf("invalid notifications.smtp: %w", err)
+	}
+	if config.Host == "" || config.From == "" {
+		return nil, fmt.Errorf("reports.email.recipients needs notifications.smtp.host and notifications.smtp.from")
+	}
+	if config.Port == 0 {
+		config.Port = 587
+	}
+	// The password is a credential and may be a secret reference
+	var err error
+	if config.Password, err = secretResolver.Resolve(context.Background(), config.Password); err != nil {
+		return nil, fmt.Errorf("notifications.smtp.password: %w", err)
+	}
+	for i, r := range recipients {
+		if len(r.To) == 0 {
+			return nil, fmt.Errorf("reports.email.recipients[%d] has no to addresses", i)
+		}
+	}
+	return &Mailer{smtp: config, recipients: recipients}, nil
+}
+
+// recipientDigest merges the digests of teams into one, so that a recipient
+// only sees findings in namespaces their teams own. It returns false when
+// none of the teams has a digest.
+func recipientDigest(digests []TeamDigest, teams []string) (TeamDigest, bool) {
+	wanted := make(map[string]bool, len(teams))
+	for _, team := range teams {
+		wanted[team] = true
+	}
+	var merged TeamDigest
+	var names []string
+	for _, d := range digests {
+		if len(wanted) > 0 && !wanted[d.Team] {
+			continue
+		}
+		if names == nil {
+			merged = TeamDigest{Week: d.Week, GeneratedAt: d.GeneratedAt, Health: d.Health, Sources: d.Sources, Counts: make(map[string]int)}
+		}
+		names = append(names, d.Team)
+		merged.Namespaces = append(merged.Namespaces, d.Namespaces...)
+		merged.Findings = append(merged.Findings, d.Findings...)
+		for severity, n := range d.Counts {
+			merged.Counts[severity] += n
+		}
+	}
+	if names == nil {
+		return merged, false
+	}
+	merged.Team = strings.Join(names, ", ")
+	sort.Strings(merged.Namespaces)
+	sortFindings(merged.Findings)
+	return merged, true
+}
+
+// Deliver mails every recipient the digest of their teams: the HTML digest
+// inline and the JSON digest attached. Failures are logged per recipient.
+func (m *Mailer) Deliver(ctx context.Context, digests []TeamDigest) {
+	for _, r := range m.recipients {
+		digest, ok := recipientDigest(digests, r.Teams)
+		if !ok {
+			logger.Warnf("no digest for teams %s of %s", strings.Join(r.Teams, ", "), strings.Join(r.To, ", "))
+			continue
+		}
+		if err := m.send(ctx, r.To, &digest); err != nil {
+			logger.Warnf("failed to mail digest to %s: %v", strings.Join(r.To, ", "), err)
+		}
+	}
+}
+
+// send renders a digest and mails it to to
+func (m *Mailer) send(ctx context.Context, to []string, digest *TeamDigest) error {
+	var html bytes.Buffer
+	if err := renderHTML(&html, "digest.html.tmpl", digest); err != nil {
+		return fmt.Errorf("failed to render digest: %w", err)
+	}
+	attachment, err := json.MarshalIndent(digest, "", "  ")
+	if err != nil {
+		return err
+	}
+	subject := tr("Weekly Digest: %s (%s)", digest.Team, digest.Week)
+	message, err := buildMail(m.smtp.From, to, subject, html.Bytes(), "digest-"+digest.Week+".json", attachment)
+	if err != nil {
+		return err
+	}
+	return m.smtp.send(ctx, to, message)
+}
+
+// buildMail builds a multipart/mixed message with an inline HTML body and a
+// JSON attachment
+func buildMail(from string, to []string, subject string, html []byte, filename string, attachment []byte) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	htmlPart, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/html; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+		"Content-Disposition":       {"inline"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	qp := quotedprintable.NewWriter(htmlPart)
+	if _, err := qp.Write(html); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	attachmentPart, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType("application/json", map[string]string{"name": filename})},
+		"Content-Transfer-Encoding": {"base64"},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": filename})},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+	for len(encoded) > 76 {
+		fmt.Fprintf(attachmentPart, "%s\r\n", encoded[:76])
+		encoded = encoded[76:]
+	}
+	fmt.Fprintf(attachmentPart, "%s\r\n", encoded)
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: %s\r\n\r\n", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": parts.Boundary()}))
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+// send delivers a message over SMTP, upgrading to TLS with STARTTLS when the
+// server offers it and authenticating when a username is set
+func (c *smtpConfig) send(ctx context.Context, to []string, message []byte) error {
+	addr := net.JoinHostPort(c.Host, strconv.Itoa(c.Port))
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(time.Minute)
+	}
+	conn.SetDeadline(deadline)
+	if c.TLS {
+		conn = tls.Client(conn, &tls.Config{ServerName: c.Host})
+	}
+
+	client, err := smtp.NewClient(conn, c.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if !c.TLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: c.Host}); err != nil {
+				return fmt.Errorf("STARTTLS failed: %w", err)
+			}
+		}
+	}
+	if c.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.Username, c.Password, c.Host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(c.From); err != nil {
+		return err
+	}
+	for _, address := range to {
+		if err := client.Rcpt(address); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
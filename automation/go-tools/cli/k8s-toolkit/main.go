@@ -4,49 +4,110 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/devops-excellence/automation/go-tools/pkg/secrets"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiversion "k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	metricsv1beta1 "k8s.io/metrics/pkg/apis/metrics/v1beta1"
 	metrics "k8s.io/metrics/pkg/client/clientset/versioned"
 )
 
 // HealthCheckResult represents the result of a health check
 type HealthCheckResult struct {
+	Check     string            `json:"check,omitempty"`
 	Component string            `json:"component"`
 	Status    string            `json:"status"`
 	Message   string            `json:"message"`
 	Details   map[string]string `json:"details"`
 	Timestamp time.Time         `json:"timestamp"`
+	Duration  int64             `json:"duration_ms"`
+
+	// ErrorCategory is set when the check could not query the cluster, as
+	// opposed to finding something unhealthy
+	ErrorCategory ErrorCategory `json:"error_category,omitempty"`
+
+	// Err is the error behind ErrorCategory
+	Err error `json:"-"`
+
+	// Affected lists objects behind a non-healthy result, used to look up related events
+	Affected []objectRef `json:"-"`
+
+	// exitCode is the exit_code of the policy rule that matched the result
+	exitCode int
 }
 
 // ClusterHealth represents overall cluster health
 type ClusterHealth struct {
-	OverallStatus string               `json:"overall_status"`
-	Checks        []HealthCheckResult  `json:"checks"`
-	Summary       map[string]int       `json:"summary"`
-	Timestamp     time.Time            `json:"timestamp"`
+	SchemaVersion string              `json:"schema_version"`
+	OverallStatus string              `json:"overall_status"`
+	Checks        []HealthCheckResult `json:"checks"`
+	Summary       map[string]int      `json:"summary"`
+	Timestamp     time.Time           `json:"timestamp"`
+
+	// Partial is set when some checks failed to run; their results are
+	// still included and listed in Errors
+	Partial bool         `json:"partial"`
+	Errors  []CheckError `json:"errors,omitempty"`
+
+	// Cache is set when the checks read from the informer cache
+	Cache *CacheStatus `json:"cache,omitempty"`
+
+	// Skipped lists the cluster-scoped checks left out because the run was
+	// scoped to a namespace, and external command checks left out in
+	// read-only mode
+	Skipped []string `json:"skipped,omitempty"`
+
+	// Ignored lists the checks dropped by an Ignore rule under policy.rules
+	Ignored []string `json:"ignored,omitempty"`
 }
 
 // K8sToolkit represents the main application
 type K8sToolkit struct {
-	clientset        *kubernetes.Clientset
-	metricsClientset *metrics.Clientset
+	clientset        kubernetes.Interface
+	metricsClientset metrics.Interface
+	dynamicClient    dynamic.Interface
+	restConfig       *rest.Config
+	contextName      string
 	namespace        string
 	output           string
+	filter           string
+	maxMemory        int64
+
+	// selector and excludeNamespaces narrow the namespaced checks beyond
+	// namespace; see scope.go
+	selector          string
+	excludeNamespaces []string
+
+	// cache serves nodes, pods and PVs from informers when --use-cache is set
+	cache *clusterCache
 }
 
-// NewK8sToolkit creates a new instance of K8sToolkit
+// NewK8sToolkit creates a new instance of K8sToolkit for the --context
+// kubeconfig context, or the current context when it is not set
 func NewK8sToolkit() (*K8sToolkit, error) {
+	return NewK8sToolkitForContext(viper.GetString("context"))
+}
+
+// NewK8sToolkitForContext creates a K8sToolkit connected to the named
+// kubeconfig context
+func NewK8sToolkitForContext(kubeContext string) (*K8sToolkit, error) {
 	// Get kubeconfig path
 	kubeconfig := viper.GetString("kubeconfig")
 	if kubeconfig == "" {
@@ -54,10 +115,37 @@ func NewK8sToolkit() (*K8sToolkit, error) {
 	}
 
 	// Build config
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig},
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+	)
+	config, err := clientConfig.ClientConfig()
 	if err != nil {
 		return nil, fmt.Errorf("failed to build config: %w", err)
 	}
+	if kubeContext == "" {
+		if raw, err := clientConfig.RawConfig(); err == nil {
+			kubeContext = raw.CurrentContext
+		}
+	}
+
+	// Client-go's defaults of 5 QPS and burst 10 are too slow for large
+	// clusters; --qps and --burst tune it for the API server at hand
+	config.QPS = float32(viper.GetFloat64("qps"))
+	config.Burst = viper.GetInt("burst")
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return &retryTransport{next: rt, retries: viper.GetInt("api_retries"), timeout: viper.GetDuration("request_timeout")}
+	})
+
+	// Enforce read-only mode below every client so no code path can bypass it
+	if readOnly() {
+		config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+			return &readOnlyTransport{next: rt}
+		})
+	}
+	if tracerProvider != nil {
+		config.Wrap(tracingTransport)
+	}
 
 	// Create clientset
 	clientset, err := kubernetes.NewForConfig(config)
@@ -65,35 +153,81 @@ func NewK8sToolkit() (*K8sToolkit, error) {
 		return nil, fmt.Errorf("failed to create clientset: %w", err)
 	}
 
-	// Create metrics clientset
-	metricsClientset, err := metrics.NewForConfig(config)
+	// Secret references of the form k8s:namespace/name#key read from this cluster
+	if !secretResolver.Has("k8s") {
+		secretResolver.Register("k8s", &secrets.KubernetesProvider{Client: clientset})
+	}
+
+	// Create dynamic client for resources without typed clients
+	dynamicClient, err := dynamic.NewForConfig(config)
 	if err != nil {
-		log.Printf("Warning: failed to create metrics clientset: %v", err)
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
+	// Create metrics clientset, leaving the interface nil on failure so
+	// checks can tell metrics are unavailable
+	var metricsClientset metrics.Interface
+	if mc, err := metrics.NewForConfig(config); err != nil {
+		logger.Warnf("failed to create metrics clientset: %v", err)
+	} else {
+		metricsClientset = mc
+	}
+
+	maxMemory, err := parseMaxMemory()
+	if err != nil {
+		return nil, err
 	}
 
 	return &K8sToolkit{
 		clientset:        clientset,
 		metricsClientset: metricsClientset,
+		dynamicClient:    dynamicClient,
+		restConfig:       config,
+		contextName:      kubeContext,
 		namespace:        viper.GetString("namespace"),
 		output:           viper.GetString("output"),
+		filter:           viper.GetString("filter"),
+		maxMemory:        maxMemory,
+
+		selector:          viper.GetString("selector"),
+		excludeNamespaces: viper.GetStringSlice("exclude_namespaces"),
 	}, nil
 }
 
-// CheckAPIServer checks if the API server is healthy
-func (k *K8sToolkit) CheckAPIServer() HealthCheckResult {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+// serverVersion fetches the API server version honoring ctx, which the
+// discovery client's ServerVersion does not
+func (k *K8sToolkit) serverVersion(ctx context.Context) (*apiversion.Info, error) {
+	restClient := k.clientset.Discovery().RESTClient()
+	if restClient == nil {
+		// Fake discovery clients have no REST client
+		return k.clientset.Discovery().ServerVersion()
+	}
+
+	body, err := restClient.Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return nil, err
+	}
 
+	var version apiversion.Info
+	if err := json.Unmarshal(body, &version); err != nil {
+		return nil, fmt.Errorf("failed to decode version: %w", err)
+	}
+	return &version, nil
+}
+
+// CheckAPIServer checks if the API server is healthy
+func (k *K8sToolkit) CheckAPIServer(ctx context.Context) HealthCheckResult {
 	result := HealthCheckResult{
 		Component: "API Server",
 		Timestamp: time.Now(),
 		Details:   make(map[string]string),
 	}
 
-	version, err := k.clientset.Discovery().ServerVersion()
+	version, err := k.serverVersion(ctx)
 	if err != nil {
 		result.Status = "Critical"
 		result.Message = fmt.Sprintf("Failed to connect to API server: %v", err)
+		result.Err = err
 		return result
 	}
 
@@ -105,10 +239,7 @@ func (k *K8sToolkit) CheckAPIServer() HealthCheckResult {
 }
 
 // CheckNodes checks the health of all nodes
-func (k *K8sToolkit) CheckNodes() HealthCheckResult {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+func (k *K8sToolkit) CheckNodes(ctx context.Context) HealthCheckResult {
 	result := HealthCheckResult{
 		Component: "Nodes",
 		Timestamp: time.Now(),
@@ -119,6 +250,7 @@ func (k *K8sToolkit) CheckNodes() HealthCheckResult {
 	if err != nil {
 		result.Status = "Critical"
 		result.Message = fmt.Sprintf("Failed to list nodes: %v", err)
+		result.Err = err
 		return result
 	}
 
@@ -149,6 +281,7 @@ func (k *K8sToolkit) CheckNodes() HealthCheckResult {
 		if !nodeReady {
 			notReadyNodes++
 			nodeIssues = append(nodeIssues, fmt.Sprintf("%s: Ready condition not found", node.Name))
+			result.Affected = append(result.Affected, objectRef{Kind: "Node", Name: node.Name})
 		}
 	}
 
@@ -168,36 +301,38 @@ func (k *K8sToolkit) CheckNodes() HealthCheckResult {
 	return result
 }
 
-// CheckSystemPods checks critical system pods
-func (k *K8sToolkit) CheckSystemPods() HealthCheckResult {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+// systemNamespaces hold cluster components rather than workloads
+var systemNamespaces = []string{"kube-system", "kube-public", "kube-node-lease"}
 
+// CheckSystemPods checks critical system pods
+func (k *K8sToolkit) CheckSystemPods(ctx context.Context) HealthCheckResult {
 	result := HealthCheckResult{
 		Component: "System Pods",
 		Timestamp: time.Now(),
 		Details:   make(map[string]string),
 	}
 
-	systemNamespaces := []string{"kube-system", "kube-public", "kube-node-lease"}
 	var allIssues []string
 	totalPods := 0
 	runningPods := 0
 
 	for _, ns := range systemNamespaces {
-		pods, err := k.clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{})
-		if err != nil {
-			allIssues = append(allIssues, fmt.Sprintf("Failed to list pods in %s: %v", ns, err))
+		if !k.inScope(ns) {
 			continue
 		}
-
-		for _, pod := range pods.Items {
+		err := k.eachPod(ctx, ns, metav1.ListOptions{}, func(pod *corev1.Pod) error {
 			totalPods++
 			if pod.Status.Phase == "Running" {
 				runningPods++
 			} else if pod.Status.Phase != "Succeeded" {
 				allIssues = append(allIssues, fmt.Sprintf("%s/%s: %s", ns, pod.Name, pod.Status.Phase))
+				result.Affected = append(result.Affected, objectRef{Kind: "Pod", Namespace: ns, Name: pod.Name})
 			}
+			return nil
+		})
+		if err != nil {
+			allIssues = append(allIssues, fmt.Sprintf("Failed to list pods in %s: %v", ns, err))
+			result.Err = err
 		}
 	}
 
@@ -217,7 +352,7 @@ func (k *K8sToolkit) CheckSystemPods() HealthCheckResult {
 }
 
 // CheckResourceUsage checks cluster resource usage
-func (k *K8sToolkit) CheckResourceUsage() HealthCheckResult {
+func (k *K8sToolkit) CheckResourceUsage(ctx context.Context) HealthCheckResult {
 	result := HealthCheckResult{
 		Component: "Resource Usage",
 		Timestamp: time.Now(),
@@ -230,14 +365,12 @@ func (k *K8sToolkit) CheckResourceUsage() HealthCheckResult {
 		return result
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	// Get node metrics
 	nodeMetrics, err := k.metricsClientset.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		result.Status = "Warning"
 		result.Message = fmt.Sprintf("Failed to get node metrics: %v", err)
+		result.Err = err
 		return result
 	}
 
@@ -247,6 +380,8 @@ func (k *K8sToolkit) CheckResourceUsage() HealthCheckResult {
 		return result
 	}
 
+	cpuThreshold := viper.GetFloat64("thresholds.cpu_percent")
+	memoryThreshold := viper.GetFloat64("thresholds.memory_percent")
 	var highCPUNodes []string
 	var highMemoryNodes []string
 
@@ -266,10 +401,10 @@ func (k *K8sToolkit) CheckResourceUsage() HealthCheckResult {
 		cpuPercent := float64(cpuUsage.MilliValue()) / float64(cpuCapacity.MilliValue()) * 100
 		memoryPercent := float64(memoryUsage.Value()) / float64(memoryCapacity.Value()) * 100
 
-		if cpuPercent > 80 {
+		if cpuPercent > cpuThreshold {
 			highCPUNodes = append(highCPUNodes, fmt.Sprintf("%s(%.1f%%)", nodeMetric.Name, cpuPercent))
 		}
-		if memoryPercent > 80 {
+		if memoryPercent > memoryThreshold {
 			highMemoryNodes = append(highMemoryNodes, fmt.Sprintf("%s(%.1f%%)", nodeMetric.Name, memoryPercent))
 		}
 	}
@@ -295,10 +430,7 @@ func (k *K8sToolkit) CheckResourceUsage() HealthCheckResult {
 }
 
 // CheckPVs checks persistent volumes
-func (k *K8sToolkit) CheckPVs() HealthCheckResult {
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
+func (k *K8sToolkit) CheckPVs(ctx context.Context) HealthCheckResult {
 	result := HealthCheckResult{
 		Component: "Persistent Volumes",
 		Timestamp: time.Now(),
@@ -309,6 +441,7 @@ func (k *K8sToolkit) CheckPVs() HealthCheckResult {
 	if err != nil {
 		result.Status = "Warning"
 		result.Message = fmt.Sprintf("Failed to list PVs: %v", err)
+		result.Err = err
 		return result
 	}
 
@@ -327,6 +460,7 @@ func (k *K8sToolkit) CheckPVs() HealthCheckResult {
 		case "Failed":
 			failedPVs++
 			failedPVNames = append(failedPVNames, pv.Name)
+			result.Affected = append(result.Affected, objectRef{Kind: "PersistentVolume", Name: pv.Name})
 		}
 	}
 
@@ -347,22 +481,173 @@ func (k *K8sToolkit) CheckPVs() HealthCheckResult {
 	return result
 }
 
-// RunHealthCheck runs all health checks
-func (k *K8sToolkit) RunHealthCheck() (*ClusterHealth, error) {
-	checks := []HealthCheckResult{
-		k.CheckAPIServer(),
-		k.CheckNodes(),
-		k.CheckSystemPods(),
-		k.CheckResourceUsage(),
-		k.CheckPVs(),
+func init() {
+	registerCheck("api-server", "API Server", 10*time.Second, (*K8sToolkit).CheckAPIServer)
+	registerCheck("nodes", "Nodes", 30*time.Second, (*K8sToolkit).CheckNodes)
+	registerCheck("node-conditions", "Node Conditions", 30*time.Second, (*K8sToolkit).CheckNodeConditions)
+	registerCheck("system-pods", "System Pods", 30*time.Second, (*K8sToolkit).CheckSystemPods)
+	registerCheck("crashloops", "Crashlooping Pods", 30*time.Second, (*K8sToolkit).CheckCrashLoops)
+	registerCheck("resource-usage", "Resource Usage", 30*time.Second, (*K8sToolkit).CheckResourceUsage)
+	registerCheck("pvs", "Persistent Volumes", 30*time.Second, (*K8sToolkit).CheckPVs)
+	registerCheck("gitops", "GitOps", 30*time.Second, (*K8sToolkit).CheckGitOps)
+	registerCheck("progressive-delivery", "Progressive Delivery", 30*time.Second, (*K8sToolkit).CheckProgressiveDelivery)
+	registerCheck("policy-violations", "Policy Violations", 30*time.Second, (*K8sToolkit).CheckPolicyViolations)
+	registerCheck("daemonsets", "DaemonSets", 30*time.Second, (*K8sToolkit).CheckDaemonSets)
+	registerCheck("jobs", "Jobs", 30*time.Second, (*K8sToolkit).CheckJobs)
+	registerCheck("admission-webhooks", "Admission Webhooks", 30*time.Second, (*K8sToolkit).CheckAdmissionWebhooks)
+	registerCheck("helm", "Helm Releases", 30*time.Second, (*K8sToolkit).CheckHelmReleases)
+	registerCheck("credentials", "Credential Expiry", 30*time.Second, (*K8sToolkit).CheckCredentialExpiry)
+	registerCheck("slo", "Workload SLOs", 30*time.Second, (*K8sToolkit).CheckSLOs)
+}
+
+// runCheck runs a single check within its own timeout budget and records how
+// long it took. Related events are attached to failed checks when health.events
+// is set. A panicking check is reported as Critical instead of ending the run.
+func (k *K8sToolkit) runCheck(ctx context.Context, check HealthChecker) (result HealthCheckResult) {
+	ctx, span := tracer.Start(ctx, "check "+check.Name(), trace.WithAttributes(
+		attribute.String("check", check.Name()),
+		attribute.String("component", check.Component()),
+	))
+	defer func() {
+		span.SetAttributes(attribute.String("status", result.Status))
+		if result.Status == "Critical" {
+			span.SetStatus(codes.Error, result.Message)
+		}
+		span.End()
+	}()
+
+	checkCtx, cancel := context.WithTimeout(ctx, check.Timeout())
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		if r := recover(); r != nil {
+			result = HealthCheckResult{
+				Check:         check.Name(),
+				Component:     check.Component(),
+				Status:        "Critical",
+				Message:       fmt.Sprintf("Check panicked: %v", r),
+				Details:       make(map[string]string),
+				Timestamp:     start,
+				ErrorCategory: CategoryInternal,
+				Duration:      time.Since(start).Milliseconds(),
+			}
+			k.checkLog(check.Name()).Errorf("Check panicked: %v", r)
+		}
+	}()
+
+	result = check.Check(checkCtx)
+	result.Check = check.Name()
+	if result.Err != nil {
+		result.Err = classifyError(result.Err)
+		result.ErrorCategory = errorCategory(result.Err)
+	}
+	if result.Status != "Healthy" && viper.GetBool("health.events") {
+		k.attachEvents(checkCtx, &result, viper.GetInt("health.events_limit"))
+	}
+	result.Duration = time.Since(start).Milliseconds()
+	k.checkLog(check.Name()).Debugf("%s in %dms: %s", result.Status, result.Duration, result.Message)
+	return result
+}
+
+// RunHealthCheck runs all enabled health checks concurrently on a worker
+// pool bounded by health.workers. Checks still running when the
+// health.timeout deadline passes are reported as Critical.
+func (k *K8sToolkit) RunHealthCheck(ctx context.Context) (*ClusterHealth, error) {
+	ctx, span := tracer.Start(ctx, "health", trace.WithAttributes(attribute.String("cluster", k.contextName)))
+	defer span.End()
+
+	policy, err := loadSeverityPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, viper.GetDuration("health.timeout"))
+	defer cancel()
+
+	var enabled []HealthChecker
+	var skipped []string
+	for _, check := range k.healthChecks() {
+		switch {
+		case !checkEnabled(check.Name()):
+		case k.skippedForScope(check.Name()), skippedForReadOnly(check):
+			skipped = append(skipped, check.Name())
+		default:
+			enabled = append(enabled, check)
+		}
+	}
+
+	type indexedResult struct {
+		index  int
+		result HealthCheckResult
+	}
+
+	jobs := make(chan int)
+	results := make(chan indexedResult, len(enabled))
+
+	workers := viper.GetInt("health.workers")
+	if workers < 1 {
+		workers = 1
+	}
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				results <- indexedResult{index: i, result: k.runCheck(ctx, enabled[i])}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range enabled {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	checks := make([]HealthCheckResult, len(enabled))
+	received := make([]bool, len(enabled))
+collect:
+	for n := 0; n < len(enabled); n++ {
+		select {
+		case r := <-results:
+			checks[r.index] = r.result
+			received[r.index] = true
+		case <-ctx.Done():
+			break collect
+		}
+	}
+
+	for i, check := range enabled {
+		if !received[i] {
+			checks[i] = HealthCheckResult{
+				Check:     check.Name(),
+				Component: check.Component(),
+				Status:    "Critical",
+				Message:   "Check did not complete before the health check deadline",
+				Details:   make(map[string]string),
+				Timestamp: time.Now(),
+
+				ErrorCategory: CategoryTimeout,
+			}
+		}
 	}
 
+	checks, ignored := policy.apply(checks)
+
 	summary := make(map[string]int)
 	overallStatus := "Healthy"
+	var errs []CheckError
 
 	for _, check := range checks {
 		summary[check.Status]++
-		
+		if check.ErrorCategory != "" {
+			errs = append(errs, CheckError{Component: check.Component, Category: check.ErrorCategory, Message: check.Message})
+		}
+
 		// Determine overall status
 		if check.Status == "Critical" {
 			overallStatus = "Critical"
@@ -371,64 +656,97 @@ func (k *K8sToolkit) RunHealthCheck() (*ClusterHealth, error) {
 		}
 	}
 
-	return &ClusterHealth{
+	span.SetAttributes(attribute.String("status", overallStatus))
+	health := &ClusterHealth{
+		SchemaVersion: clusterHealthSchemaVersion,
 		OverallStatus: overallStatus,
 		Checks:        checks,
 		Summary:       summary,
 		Timestamp:     time.Now(),
-	}, nil
+		Partial:       len(errs) > 0,
+		Errors:        errs,
+		Skipped:       skipped,
+		Ignored:       ignored,
+	}
+	if k.cache != nil {
+		health.Cache = k.cache.Status()
+	}
+	return health, nil
+}
+
+// unavailableHealth is the report produced when no check could be attempted,
+// so callers parsing the output still get a complete document
+func unavailableHealth(err error, category ErrorCategory) *ClusterHealth {
+	message := err.Error()
+	return &ClusterHealth{
+		SchemaVersion: clusterHealthSchemaVersion,
+		OverallStatus: "Critical",
+		Checks: []HealthCheckResult{{
+			Component:     "API Server",
+			Status:        "Critical",
+			Message:       message,
+			Details:       make(map[string]string),
+			Timestamp:     time.Now(),
+			ErrorCategory: category,
+		}},
+		Summary:   map[string]int{"Critical": 1},
+		Timestamp: time.Now(),
+		Partial:   true,
+		Errors:    []CheckError{{Component: "API Server", Category: category, Message: message}},
+	}
+}
+
+// checkEnabled reports whether a check is listed in checks.enabled. All
+// checks are enabled when the list is empty.
+func checkEnabled(name string) bool {
+	enabled := viper.GetStringSlice("checks.enabled")
+	if len(enabled) == 0 {
+		return true
+	}
+	for _, e := range enabled {
+		if e == name {
+			return true
+		}
+	}
+	return false
 }
 
 // PrintHealthCheck prints the health check results
 func (k *K8sToolkit) PrintHealthCheck(health *ClusterHealth) {
-	if k.output == "json" {
-		jsonData, err := json.MarshalIndent(health, "", "  ")
-		if err != nil {
-			log.Printf("Error marshaling JSON: %v", err)
-			return
-		}
-		fmt.Println(string(jsonData))
+	if k.filtered(health) {
 		return
 	}
-
-	// Text output
-	fmt.Printf("Kubernetes Cluster Health Report\n")
-	fmt.Printf("Generated: %s\n", health.Timestamp.Format("2006-01-02 15:04:05"))
-	fmt.Printf("Overall Status: %s\n\n", health.OverallStatus)
-
-	// Summary
-	fmt.Printf("Summary:\n")
-	for status, count := range health.Summary {
-		fmt.Printf("  %s: %d\n", status, count)
+	if k.output == "json" {
+		printJSON(health)
+		return
 	}
-	fmt.Println()
 
-	// Detailed results
-	fmt.Printf("Detailed Results:\n")
 	sort.Slice(health.Checks, func(i, j int) bool {
 		// Sort by status priority: Critical > Warning > Healthy
 		statusPriority := map[string]int{"Critical": 3, "Warning": 2, "Healthy": 1}
 		return statusPriority[health.Checks[i].Status] > statusPriority[health.Checks[j].Status]
 	})
+	if err := renderText(os.Stdout, "health.txt.tmpl", health); err != nil {
+		logger.Fatalf("Failed to render health report: %v", err)
+	}
+}
 
-	for _, check := range health.Checks {
-		statusIcon := map[string]string{
-			"Healthy":  "✅",
-			"Warning":  "⚠️",
-			"Critical": "❌",
-		}[check.Status]
-
-		fmt.Printf("%s %s: %s\n", statusIcon, check.Component, check.Message)
-		
-		if len(check.Details) > 0 && (check.Status == "Warning" || check.Status == "Critical") {
-			for key, value := range check.Details {
-				if key != "issues" || check.Status != "Healthy" {
-					fmt.Printf("    %s: %s\n", key, value)
-				}
-			}
+// printJSON prints v as indented JSON, wrapped in a signed envelope when
+// --sign-key is set
+func printJSON(v interface{}) {
+	if key := viper.GetString("sign_key"); key != "" {
+		signed, err := SignReport(context.Background(), v, key)
+		if err != nil {
+			logger.Fatalf("Failed to sign report: %v", err)
 		}
-		fmt.Println()
+		v = signed
+	}
+	jsonData, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		logger.Errorf("Error marshaling JSON: %v", err)
+		return
 	}
+	fmt.Println(string(jsonData))
 }
 
 // createRootCmd creates the root command
@@ -437,65 +755,272 @@ func createRootCmd() *cobra.Command {
 		Use:   "k8s-toolkit",
 		Short: "Kubernetes toolkit for DevOps operations",
 		Long:  `A comprehensive toolkit for Kubernetes operations including health checks, resource optimization, and security scanning.`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			if schema, _ := cmd.Flags().GetBool("schema"); schema {
+				printSchema(cmd)
+			}
+		},
 	}
 
 	// Global flags
+	rootCmd.PersistentFlags().String("config", "", "Path to config file")
+	rootCmd.PersistentFlags().String("config-repo", "", "Git repository URL to load configuration from")
+	rootCmd.PersistentFlags().String("config-ref", "main", "Branch of the config repository")
+	rootCmd.PersistentFlags().String("config-path", "k8s-toolkit.yaml", "Path of the config file inside the config repository")
+	rootCmd.PersistentFlags().String("config-keyring", "", "Armored PGP keyring used to verify config repository commits (required unless --config-allow-unsigned)")
+	rootCmd.PersistentFlags().Bool("config-allow-unsigned", false, "Accept unsigned config repository commits")
 	rootCmd.PersistentFlags().String("kubeconfig", "", "Path to kubeconfig file")
-	rootCmd.PersistentFlags().StringP("namespace", "n", "", "Kubernetes namespace")
-	rootCmd.PersistentFlags().StringP("output", "o", "text", "Output format (text|json)")
+	rootCmd.PersistentFlags().String("context", "", "Kubeconfig context to use instead of the current context")
+	rootCmd.PersistentFlags().StringP("namespace", "n", "", "Kubernetes namespace; checks that need cluster-wide access are skipped when set")
+	rootCmd.PersistentFlags().String("selector", "", "Only check pods and workloads matching this label selector")
+	rootCmd.PersistentFlags().StringSlice("exclude-namespaces", nil, "Namespaces to leave out of checks and scans")
+	rootCmd.PersistentFlags().StringP("output", "o", "text", "Output format (text|json; csv and sarif where supported)")
+	rootCmd.PersistentFlags().String("filter", "", "CEL expression over the JSON report; a boolean result sets the exit code (true exits 1), any other result is printed instead of the report")
+	rootCmd.PersistentFlags().Bool("offline", false, "Disable calls to external services (registries, config repository, vulnerability database updates) and use local data only")
+	rootCmd.PersistentFlags().String("lang", "en", "Language of report text")
+	rootCmd.PersistentFlags().String("template-dir", "", "Directory with report templates (*.tmpl) and messages.<lang>.yaml overriding the bundled ones")
+	rootCmd.PersistentFlags().String("max-memory", "", "Abort list-heavy operations when heap usage exceeds this size (e.g. 512Mi)")
+	rootCmd.PersistentFlags().Bool("read-only", false, "Refuse every create, update, delete and exec call against the cluster, and skip exec checks and plugins")
+	rootCmd.PersistentFlags().Bool("require-reason", false, "Require --reason for commands that modify the cluster")
+	rootCmd.PersistentFlags().String("reason", "", "Reason recorded in the audit log for commands that modify the cluster")
+	rootCmd.PersistentFlags().BoolP("yes", "y", false, "Skip the confirmation prompt of commands that modify the cluster")
+	rootCmd.PersistentFlags().Bool("break-glass", false, "Modify the cluster during a blocking deploy freeze; requires --reason and is recorded in the audit log")
+	rootCmd.PersistentFlags().String("store", "", "Record health runs in this database (sqlite:///path/to/history.db) for the history command")
+	rootCmd.PersistentFlags().String("log-level", "info", "Minimum level of diagnostic logs written to stderr (debug|info|warn|error)")
+	rootCmd.PersistentFlags().String("log-format", "console", "Format of diagnostic logs (console|json)")
+	rootCmd.PersistentFlags().Float64("qps", 20, "Maximum sustained Kubernetes API requests per second")
+	rootCmd.PersistentFlags().Int("burst", 40, "Maximum burst of Kubernetes API requests above --qps")
+	rootCmd.PersistentFlags().Duration("request-timeout", 30*time.Second, "Timeout of each Kubernetes API request attempt, excluding watches and log streams (0 disables)")
+	rootCmd.PersistentFlags().Int("retries", 3, "Retries of read requests that failed with a connection error or a 429/5xx response")
+	rootCmd.PersistentFlags().Bool("use-cache", false, "In long-running modes (health --watch, operator, dashboard) read nodes, pods and PVs from informers instead of listing them every cycle")
+	rootCmd.PersistentFlags().String("otel-endpoint", "", "Export traces of checks and Kubernetes API calls to this OTLP/gRPC collector (host:port)")
+	rootCmd.PersistentFlags().Bool("otel-insecure", false, "Connect to the OTLP collector without TLS")
+	rootCmd.PersistentFlags().Bool("schema", false, "Print the JSON Schema of the command's -o json output instead of running it")
+	rootCmd.PersistentFlags().String("sign-key", "", "Sign JSON reports with this cosign key (file or KMS reference) or minisign secret key; check them with verify-report")
 
 	viper.BindPFlag("kubeconfig", rootCmd.PersistentFlags().Lookup("kubeconfig"))
+	viper.BindPFlag("context", rootCmd.PersistentFlags().Lookup("context"))
 	viper.BindPFlag("namespace", rootCmd.PersistentFlags().Lookup("namespace"))
+	viper.BindPFlag("selector", rootCmd.PersistentFlags().Lookup("selector"))
+	viper.BindPFlag("exclude_namespaces", rootCmd.PersistentFlags().Lookup("exclude-namespaces"))
 	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	viper.BindPFlag("filter", rootCmd.PersistentFlags().Lookup("filter"))
+	viper.BindPFlag("offline", rootCmd.PersistentFlags().Lookup("offline"))
+	viper.BindPFlag("lang", rootCmd.PersistentFlags().Lookup("lang"))
+	viper.BindPFlag("template_dir", rootCmd.PersistentFlags().Lookup("template-dir"))
+	viper.BindPFlag("max_memory", rootCmd.PersistentFlags().Lookup("max-memory"))
+	viper.BindPFlag("read_only", rootCmd.PersistentFlags().Lookup("read-only"))
+	viper.BindPFlag("require_reason", rootCmd.PersistentFlags().Lookup("require-reason"))
+	viper.BindPFlag("reason", rootCmd.PersistentFlags().Lookup("reason"))
+	viper.BindPFlag("yes", rootCmd.PersistentFlags().Lookup("yes"))
+	viper.BindPFlag("break_glass", rootCmd.PersistentFlags().Lookup("break-glass"))
+	viper.BindPFlag("store", rootCmd.PersistentFlags().Lookup("store"))
+	viper.BindPFlag("sign_key", rootCmd.PersistentFlags().Lookup("sign-key"))
+	viper.BindPFlag("log_level", rootCmd.PersistentFlags().Lookup("log-level"))
+	viper.BindPFlag("log_format", rootCmd.PersistentFlags().Lookup("log-format"))
+	viper.BindPFlag("qps", rootCmd.PersistentFlags().Lookup("qps"))
+	viper.BindPFlag("burst", rootCmd.PersistentFlags().Lookup("burst"))
+	viper.BindPFlag("request_timeout", rootCmd.PersistentFlags().Lookup("request-timeout"))
+	viper.BindPFlag("api_retries", rootCmd.PersistentFlags().Lookup("retries"))
+	viper.BindPFlag("use_cache", rootCmd.PersistentFlags().Lookup("use-cache"))
+	viper.BindPFlag("otel_endpoint", rootCmd.PersistentFlags().Lookup("otel-endpoint"))
+	viper.BindPFlag("otel_insecure", rootCmd.PersistentFlags().Lookup("otel-insecure"))
+	viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
+	viper.BindPFlag("config_repo.url", rootCmd.PersistentFlags().Lookup("config-repo"))
+	viper.BindPFlag("config_repo.ref", rootCmd.PersistentFlags().Lookup("config-ref"))
+	viper.BindPFlag("config_repo.path", rootCmd.PersistentFlags().Lookup("config-path"))
+	viper.BindPFlag("config_repo.keyring", rootCmd.PersistentFlags().Lookup("config-keyring"))
+	viper.BindPFlag("config_repo.allow_unsigned", rootCmd.PersistentFlags().Lookup("config-allow-unsigned"))
 
 	return rootCmd
 }
 
 // createHealthCmd creates the health command
 func createHealthCmd() *cobra.Command {
+	var watch time.Duration
+	var compareLast bool
+
 	var healthCmd = &cobra.Command{
 		Use:   "health",
 		Short: "Check cluster health",
-		Long:  `Performs comprehensive health checks on the Kubernetes cluster including nodes, pods, and resources.`,
+		Long: `Performs comprehensive health checks on the Kubernetes cluster including nodes, pods, and resources.
+When Argo CD or Flux is installed, out-of-sync, degraded and suspended GitOps
+resources are reported too, as are Helm releases stuck in a pending or failed
+state, credentials expiring within credentials.warn_within, and workloads whose
+ready-replica ratio is below their slo.devops/availability target.
+
+Commands listed under checks.exec in the config file run as additional checks:
+exit code 0 is Healthy, 1 Warning and anything else Critical, unless the
+command prints a JSON object with status, message and details.
+
+A check that cannot query the cluster (auth, not_found, throttled, timeout,
+unreachable) does not stop the others: the report is marked partial and lists
+each failed check with its error category.
+
+With --watch the checks are re-run on that interval, and every target under
+notifications.targets (Slack, PagerDuty or a generic webhook) is alerted when a
+routed check moves into Warning or Critical and notified again on recovery.
+With --use-cache, nodes, pods and PVs are read from informers between runs
+instead of being listed again, and the report shows how stale the cache is.
+With --store every run is recorded for the history command, and --compare-last
+prints only what changed since the previous recorded run (see also health diff).
+
+Rules under policy.rules in the config file override the status of matching
+results (by check name, status and a message pattern), drop them with severity
+Ignore, and set the exit code used when they fail the run. The command exits
+non-zero once any result reaches --fail-on (default critical).`,
 		Run: func(cmd *cobra.Command, args []string) {
 			toolkit, err := NewK8sToolkit()
 			if err != nil {
-				log.Fatalf("Failed to initialize toolkit: %v", err)
+				// Report the failure in the requested format instead of dying
+				// so automation always receives a parseable report
+				toolkit = &K8sToolkit{output: viper.GetString("output")}
+				toolkit.PrintHealthCheck(unavailableHealth(fmt.Errorf("failed to initialize toolkit: %w", err), CategoryConfig))
+				os.Exit(1)
 			}
 
-			health, err := toolkit.RunHealthCheck()
+			notifier, err := NewNotifier()
 			if err != nil {
-				log.Fatalf("Failed to run health check: %v", err)
+				logger.Fatalf("Failed to configure notifications: %v", err)
+			}
+			failOn, err := parseFailOn(viper.GetString("policy.fail_on"))
+			if err != nil {
+				logger.Fatalf("Invalid configuration: %v", err)
 			}
 
-			toolkit.PrintHealthCheck(health)
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
 
-			// Exit with non-zero status if there are critical issues
-			if health.OverallStatus == "Critical" {
-				os.Exit(1)
+			if watch > 0 && viper.GetBool("use_cache") {
+				if err := toolkit.enableCache(ctx); err != nil {
+					logger.Fatalf("Failed to start cache: %v", err)
+				}
+			}
+			if watch > 0 {
+				watchConfigRepo(ctx)
+			}
+
+			for {
+				// A config repository revision is applied between runs
+				configMu.RLock()
+				health, err := toolkit.RunHealthCheck(ctx)
+				if err != nil {
+					logger.Fatalf("Failed to run health check: %v", err)
+				}
+
+				if compareLast {
+					previous, err := lastRecordedHealth(ctx)
+					if err != nil {
+						logger.Fatalf("Failed to read the last recorded run: %v", err)
+					}
+					if previous == nil {
+						fmt.Println("No previous run recorded, showing the full report")
+						toolkit.PrintHealthCheck(health)
+					} else {
+						toolkit.PrintHealthDiff(DiffHealth(previous, health))
+					}
+				} else {
+					toolkit.PrintHealthCheck(health)
+				}
+				recordHealth(ctx, health)
+				notifier.notifyHealth(ctx, "", health)
+				configMu.RUnlock()
+
+				if watch <= 0 {
+					// Exit non-zero when a result reaches --fail-on
+					if code := healthExitCode(health, failOn); code != 0 {
+						os.Exit(code)
+					}
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(watch):
+				}
 			}
 		},
 	}
 
-	return healthCmd
+	healthCmd.Flags().BoolVar(&compareLast, "compare-last", false, "Print only the changes since the last run recorded in --store")
+	healthCmd.Flags().DurationVar(&watch, "watch", 0, "Re-run the checks on this interval and send notifications on status changes")
+	healthCmd.Flags().Duration("timeout", 60*time.Second, "Deadline for the whole health check run")
+	healthCmd.Flags().Int("workers", 4, "Number of checks to run concurrently")
+	healthCmd.Flags().Bool("events", false, "Attach recent events for objects affected by failed checks")
+	healthCmd.Flags().Int("events-limit", 3, "Number of events to attach per affected object")
+	healthCmd.Flags().String("fail-on", "critical", "Lowest status that makes the command exit non-zero (warning|critical|none)")
+	viper.BindPFlag("health.timeout", healthCmd.Flags().Lookup("timeout"))
+	viper.BindPFlag("health.workers", healthCmd.Flags().Lookup("workers"))
+	viper.BindPFlag("health.events", healthCmd.Flags().Lookup("events"))
+	viper.BindPFlag("health.events_limit", healthCmd.Flags().Lookup("events-limit"))
+	viper.BindPFlag("policy.fail_on", healthCmd.Flags().Lookup("fail-on"))
+
+	healthCmd.AddCommand(createHealthDiffCmd())
+	return withSchema(healthCmd, "cluster-health")
 }
 
 func main() {
+	cobra.OnInitialize(initConfig, initLogging, initTracing)
 	rootCmd := createRootCmd()
-	
+
 	// Add subcommands
 	rootCmd.AddCommand(createHealthCmd())
+	rootCmd.AddCommand(createRotateCmd())
+	rootCmd.AddCommand(createTopCmd())
+	rootCmd.AddCommand(createOptimizeCmd())
+	rootCmd.AddCommand(createComplianceCmd())
+	rootCmd.AddCommand(createUpgradeCheckCmd())
+	rootCmd.AddCommand(createCleanupCmd())
+	rootCmd.AddCommand(createRemediateCmd())
+	rootCmd.AddCommand(createReachabilityCmd())
+	rootCmd.AddCommand(createProbeCmd())
+	rootCmd.AddCommand(createPortForwardCmd())
+	rootCmd.AddCommand(createHPACmd())
+	rootCmd.AddCommand(createAvailabilityCmd())
+	rootCmd.AddCommand(createAddonsCmd())
+	rootCmd.AddCommand(createExpiryCmd())
+	rootCmd.AddCommand(createDiffCmd())
+	rootCmd.AddCommand(createCompareCmd())
+	rootCmd.AddCommand(createNetMeshCmd())
+	rootCmd.AddCommand(createDigestCmd())
+	rootCmd.AddCommand(createExportCmd())
+	rootCmd.AddCommand(createMigrateCmd())
+	rootCmd.AddCommand(createQuotaCmd())
+	rootCmd.AddCommand(createCapacityCmd())
+	rootCmd.AddCommand(createCostCmd())
+	rootCmd.AddCommand(createNodeCmd())
+	rootCmd.AddCommand(createChaosCmd())
+	rootCmd.AddCommand(createRolloutCmd())
+	rootCmd.AddCommand(createCanaryCmd())
+	rootCmd.AddCommand(createFreezeCmd())
+	rootCmd.AddCommand(createLogsCmd())
+	rootCmd.AddCommand(createDashboardCmd())
+	rootCmd.AddCommand(createTroubleshootCmd())
+	rootCmd.AddCommand(createOperatorCmd())
+	rootCmd.AddCommand(createWatchRulesCmd())
+	rootCmd.AddCommand(createIssuesCmd())
+	rootCmd.AddCommand(createHistoryCmd())
+	rootCmd.AddCommand(createGovernanceCmd())
+	rootCmd.AddCommand(createLoadSimCmd())
+	rootCmd.AddCommand(createFleetCmd())
+	rootCmd.AddCommand(createSecurityCmd())
+	rootCmd.AddCommand(createDataCmd())
+	rootCmd.AddCommand(createVerifyReportCmd())
+	rootCmd.AddCommand(createPluginCmd())
+	rootCmd.AddCommand(createBenchCmd())
 
 	// Add version command
 	rootCmd.AddCommand(&cobra.Command{
 		Use:   "version",
 		Short: "Print version information",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println("k8s-toolkit version 2.0.0")
+			fmt.Println("k8s-toolkit version " + version)
 		},
 	})
 
-	if err := rootCmd.Execute(); err != nil {
-		log.Fatal(err)
+	err := rootCmd.Execute()
+	shutdownTracing()
+	if err != nil {
+		logger.Fatal(err)
 	}
 }
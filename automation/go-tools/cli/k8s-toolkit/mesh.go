@@ -0,0 +1,431 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Versions tried in order for Istio networking resources
+var istioNetworkingVersions = []string{"v1", "v1beta1", "v1alpha3"}
+
+// meshSystemNamespaces never get sidecars and are left out of injection coverage
+var meshSystemNamespaces = map[string]bool{
+	"kube-system": true, "kube-public": true, "kube-node-lease": true,
+}
+
+// istioDetected reports whether the Istio networking API is served
+func (k *K8sToolkit) istioDetected() bool {
+	for _, version := range istioNetworkingVersions {
+		if _, err := k.clientset.Discovery().ServerResourcesForGroupVersion("networking.istio.io/" + version); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// meshChecks returns the mesh check group. With mesh.istio set to auto, the
+// default, the checks are only added when Istio is installed; true and
+// false force them on or off.
+func (k *K8sToolkit) meshChecks() []HealthChecker {
+	switch strings.ToLower(viper.GetString("mesh.istio")) {
+	case "false", "disabled":
+		return nil
+	case "true", "enabled":
+	default:
+		if k.clientset == nil || !k.istioDetected() {
+			return nil
+		}
+	}
+	checks := []struct {
+		name, component string
+		run             func(*K8sToolkit, context.Context) HealthCheckResult
+	}{
+		{"mesh-istiod", "Istio Control Plane", (*K8sToolkit).CheckIstiod},
+		{"mesh-injection", "Istio Sidecar Injection", (*K8sToolkit).CheckSidecarInjection},
+		{"mesh-proxies", "Istio Proxy Versions", (*K8sToolkit).CheckProxyVersions},
+		{"mesh-gateways", "Istio Gateways", (*K8sToolkit).CheckIstioGateways},
+	}
+	var checkers []HealthChecker
+	for _, c := range checks {
+		run := c.run
+		checkers = append(checkers, healthCheck{c.name, c.component, 30 * time.Second, func(ctx context.Context) HealthCheckResult { return run(k, ctx) }})
+	}
+	return checkers
+}
+
+// istiodDeployments returns the istiod Deployments of every revision
+func (k *K8sToolkit) istiodDeployments(ctx context.Context) ([]appsv1.Deployment, error) {
+	deployments, err := k.clientset.AppsV1().Deployments(viper.GetString("mesh.istio_namespace")).List(ctx, metav1.ListOptions{LabelSelector: "app=istiod"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list istiod deployments: %w", err)
+	}
+	return deployments.Items, nil
+}
+
+// istioVersion returns the version in an Istio image tag such as 1.20.3 or
+// 1.20.3-distroless, and its major and minor numbers
+func istioVersion(image string) (version string, major, minor int, ok bool) {
+	_, _, tag, _ := parseImage(image)
+	version, _, _ = strings.Cut(tag, "-")
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 {
+		return version, 0, 0, false
+	}
+	major, err1 := strconv.Atoi(parts[0])
+	minor, err2 := strconv.Atoi(parts[1])
+	return version, major, minor, err1 == nil && err2 == nil
+}
+
+// istioProxy returns the istio-proxy container of a pod, which is a native
+// sidecar init container in recent releases
+func istioProxy(pod *corev1.Pod) *corev1.Container {
+	for _, containers := range [][]corev1.Container{pod.Spec.Containers, pod.Spec.InitContainers} {
+		for i := range containers {
+			if containers[i].Name == "istio-proxy" {
+				return &containers[i]
+			}
+		}
+	}
+	return nil
+}
+
+// CheckIstiod reports istiod revisions without available replicas, which
+// stop sidecar injection and configuration pushes
+func (k *K8sToolkit) CheckIstiod(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Istio Control Plane",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	deployments, err := k.istiodDeployments(ctx)
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = err.Error()
+		result.Err = err
+		return result
+	}
+	if len(deployments) == 0 {
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("Istio CRDs are installed but no istiod deployment was found in %s", viper.GetString("mesh.istio_namespace"))
+		return result
+	}
+
+	var revisions, down, degraded []string
+	for _, d := range deployments {
+		revision := d.Labels["istio.io/rev"]
+		if revision == "" {
+			revision = "default"
+		}
+		version := "unknown"
+		for _, c := range d.Spec.Template.Spec.Containers {
+			if c.Name == "discovery" {
+				version, _, _, _ = istioVersion(c.Image)
+			}
+		}
+		desired := int32(1)
+		if d.Spec.Replicas != nil {
+			desired = *d.Spec.Replicas
+		}
+		entry := fmt.Sprintf("%s (%s, %d/%d available)", revision, version, d.Status.AvailableReplicas, desired)
+		revisions = append(revisions, entry)
+		ref := objectRef{Kind: "Deployment", Namespace: d.Namespace, Name: d.Name}
+		switch {
+		case d.Status.AvailableReplicas == 0:
+			down = append(down, entry)
+			result.Affected = append(result.Affected, ref)
+		case d.Status.AvailableReplicas < desired:
+			degraded = append(degraded, entry)
+			result.Affected = append(result.Affected, ref)
+		}
+	}
+	result.Details["revisions"] = strings.Join(revisions, ", ")
+
+	switch {
+	case len(down) > 0:
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("istiod unavailable: %s", strings.Join(down, ", "))
+	case len(degraded) > 0:
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("istiod running below desired replicas: %s", strings.Join(degraded, ", "))
+	default:
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("%d istiod revisions available", len(deployments))
+	}
+	return result
+}
+
+// CheckSidecarInjection reports per namespace how many pods run with a
+// sidecar. Pods without one in a namespace labeled for injection were
+// created before the label or bypassed the injector and need a restart.
+func (k *K8sToolkit) CheckSidecarInjection(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Istio Sidecar Injection",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	namespaces, err := k.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list namespaces: %v", err)
+		result.Err = err
+		return result
+	}
+	injected := make(map[string]bool)
+	meshNamespace := viper.GetString("mesh.istio_namespace")
+	for _, ns := range namespaces.Items {
+		if ns.Labels["istio-injection"] == "enabled" || (ns.Labels["istio.io/rev"] != "" && ns.Labels["istio-injection"] != "disabled") {
+			injected[ns.Name] = true
+		}
+	}
+
+	type coverage struct{ total, withSidecar int }
+	byNamespace := make(map[string]*coverage)
+	missing := make(map[string][]string)
+	err = k.eachPod(ctx, k.namespace, k.listOptions(metav1.ListOptions{FieldSelector: "status.phase=Running"}), func(pod *corev1.Pod) error {
+		if meshSystemNamespaces[pod.Namespace] || pod.Namespace == meshNamespace || !k.inScope(pod.Namespace) || pod.Spec.HostNetwork {
+			return nil
+		}
+		if pod.Annotations["sidecar.istio.io/inject"] == "false" || pod.Labels["sidecar.istio.io/inject"] == "false" {
+			return nil
+		}
+		c := byNamespace[pod.Namespace]
+		if c == nil {
+			c = &coverage{}
+			byNamespace[pod.Namespace] = c
+		}
+		c.total++
+		if istioProxy(pod) != nil {
+			c.withSidecar++
+		} else if injected[pod.Namespace] {
+			missing[pod.Namespace] = append(missing[pod.Namespace], pod.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list pods: %v", err)
+		result.Err = err
+		return result
+	}
+
+	names := make([]string, 0, len(byNamespace))
+	for ns := range byNamespace {
+		names = append(names, ns)
+	}
+	sort.Strings(names)
+	var perNamespace, uninjected []string
+	meshed, pods, withSidecar := 0, 0, 0
+	for _, ns := range names {
+		c := byNamespace[ns]
+		pods += c.total
+		withSidecar += c.withSidecar
+		if injected[ns] {
+			meshed++
+		}
+		perNamespace = append(perNamespace, fmt.Sprintf("%s=%d/%d", ns, c.withSidecar, c.total))
+		if len(missing[ns]) > 0 {
+			example := missing[ns]
+			if len(example) > 3 {
+				example = append(example[:3], fmt.Sprintf("%d more", len(missing[ns])-3))
+			}
+			uninjected = append(uninjected, fmt.Sprintf("%s: %s", ns, strings.Join(example, ", ")))
+		}
+	}
+
+	result.Details["injected_namespaces"] = fmt.Sprintf("%d/%d", meshed, len(names))
+	result.Details["pods_with_sidecar"] = fmt.Sprintf("%d/%d", withSidecar, pods)
+	if len(perNamespace) > 0 {
+		result.Details["coverage"] = strings.Join(perNamespace, ", ")
+	}
+	if len(uninjected) > 0 {
+		result.Details["missing_sidecar"] = strings.Join(uninjected, "; ")
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d namespaces labeled for injection have pods without a sidecar; restart them to inject", len(uninjected))
+		return result
+	}
+	result.Status = "Healthy"
+	result.Message = fmt.Sprintf("%d of %d pods have a sidecar; every pod in the %d injected namespaces is meshed", withSidecar, pods, meshed)
+	return result
+}
+
+// CheckProxyVersions compares the istio-proxy version of every pod with the
+// istiod versions. Proxies on a version no istiod runs are outdated and are
+// Critical once more than mesh.max_proxy_skew minor versions behind, the
+// skew Istio supports.
+func (k *K8sToolkit) CheckProxyVersions(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Istio Proxy Versions",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	deployments, err := k.istiodDeployments(ctx)
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = err.Error()
+		result.Err = err
+		return result
+	}
+	controlPlane := make(map[string]bool)
+	newestMinor, newestMajor := -1, -1
+	for _, d := range deployments {
+		for _, c := range d.Spec.Template.Spec.Containers {
+			if c.Name != "discovery" {
+				continue
+			}
+			if version, major, minor, ok := istioVersion(c.Image); ok {
+				controlPlane[version] = true
+				if major > newestMajor || (major == newestMajor && minor > newestMinor) {
+					newestMajor, newestMinor = major, minor
+				}
+			}
+		}
+	}
+	if len(controlPlane) == 0 {
+		result.Status = "Healthy"
+		result.Message = "Proxy version check skipped: istiod version unknown"
+		return result
+	}
+
+	maxSkew := viper.GetInt("mesh.max_proxy_skew")
+	byVersion := make(map[string]int)
+	var outdated, unsupported []string
+	err = k.eachPod(ctx, k.namespace, k.listOptions(metav1.ListOptions{FieldSelector: "status.phase=Running"}), func(pod *corev1.Pod) error {
+		proxy := istioProxy(pod)
+		if proxy == nil || !k.inScope(pod.Namespace) {
+			return nil
+		}
+		version, major, minor, ok := istioVersion(proxy.Image)
+		byVersion[version]++
+		if controlPlane[version] {
+			return nil
+		}
+		name := fmt.Sprintf("%s/%s (%s)", pod.Namespace, pod.Name, version)
+		if ok && (major < newestMajor || newestMinor-minor > maxSkew) {
+			unsupported = append(unsupported, name)
+		} else {
+			outdated = append(outdated, name)
+		}
+		return nil
+	})
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list pods: %v", err)
+		result.Err = err
+		return result
+	}
+
+	versions := make([]string, 0, len(controlPlane))
+	for version := range controlPlane {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	result.Details["control_plane"] = strings.Join(versions, ", ")
+	result.Details["proxies"] = topCounts(byVersion, 10)
+	limit := func(items []string) string {
+		if len(items) > 10 {
+			items = append(items[:10], fmt.Sprintf("%d more", len(items)-10))
+		}
+		return strings.Join(items, ", ")
+	}
+
+	switch {
+	case len(unsupported) > 0:
+		result.Details["unsupported"] = limit(unsupported)
+		if len(outdated) > 0 {
+			result.Details["outdated"] = limit(outdated)
+		}
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("%d proxies are more than %d minor versions behind istiod", len(unsupported), maxSkew)
+	case len(outdated) > 0:
+		result.Details["outdated"] = limit(outdated)
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d proxies do not match any istiod version; restart them to pick up the current proxy", len(outdated))
+	default:
+		result.Status = "Healthy"
+		result.Message = "All proxies match an istiod version"
+	}
+	return result
+}
+
+// CheckIstioGateways reports Gateways that no VirtualService binds to,
+// which accept connections but route nothing
+func (k *K8sToolkit) CheckIstioGateways(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Istio Gateways",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	if k.dynamicClient == nil {
+		result.Status = "Healthy"
+		result.Message = "Gateway check skipped: dynamic client unavailable"
+		return result
+	}
+	gateways, _, err := k.listFirstServed(ctx, "networking.istio.io", "gateways", istioNetworkingVersions, k.namespace)
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = err.Error()
+		result.Err = err
+		return result
+	}
+	// VirtualServices in any namespace may bind a gateway as namespace/name
+	virtualServices, _, err := k.listFirstServed(ctx, "networking.istio.io", "virtualservices", istioNetworkingVersions, "")
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = err.Error()
+		result.Err = err
+		return result
+	}
+
+	bound := make(map[string]bool)
+	for _, vs := range virtualServices {
+		refs, _, _ := unstructured.NestedStringSlice(vs.Object, "spec", "gateways")
+		for _, ref := range refs {
+			if ref == "mesh" {
+				continue
+			}
+			if !strings.Contains(ref, "/") {
+				ref = vs.GetNamespace() + "/" + ref
+			}
+			bound[ref] = true
+		}
+	}
+
+	var unbound []string
+	for _, gw := range gateways {
+		if !k.inScope(gw.GetNamespace()) {
+			continue
+		}
+		name := gw.GetNamespace() + "/" + gw.GetName()
+		if !bound[name] {
+			unbound = append(unbound, name)
+			result.Affected = append(result.Affected, objectRef{Kind: "Gateway", Namespace: gw.GetNamespace(), Name: gw.GetName()})
+		}
+	}
+
+	result.Details["gateways"] = strconv.Itoa(len(gateways))
+	result.Details["virtual_services"] = strconv.Itoa(len(virtualServices))
+	if len(unbound) > 0 {
+		sort.Strings(unbound)
+		result.Details["unbound"] = strings.Join(unbound, ", ")
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d Gateways have no VirtualService bound to them", len(unbound))
+		return result
+	}
+	result.Status = "Healthy"
+	result.Message = fmt.Sprintf("All %d Gateways have VirtualServices", len(gateways))
+	return result
+}
@@ -0,0 +1,551 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+// migrationFieldManager owns the fields applied by migrate namespace
+const migrationFieldManager = "k8s-toolkit-migrate"
+
+// migrationKindOrder is the order objects are applied in, so that service
+// accounts, configuration and claims exist before the workloads using them.
+// Kinds not listed, such as custom resources, go between policy objects and
+// workloads.
+var migrationKindOrder = map[string]int{
+	"ServiceAccount":          1,
+	"Secret":                  2,
+	"ConfigMap":               2,
+	"LimitRange":              3,
+	"ResourceQuota":           3,
+	"Role":                    4,
+	"RoleBinding":             5,
+	"PersistentVolumeClaim":   6,
+	"Service":                 7,
+	"NetworkPolicy":           8,
+	"PodDisruptionBudget":     8,
+	"Deployment":              20,
+	"StatefulSet":             20,
+	"DaemonSet":               20,
+	"Job":                     20,
+	"CronJob":                 20,
+	"Pod":                     20,
+	"HorizontalPodAutoscaler": 21,
+	"Ingress":                 22,
+}
+
+// migrationKindRank returns the apply order of kind
+func migrationKindRank(kind string) int {
+	if rank, ok := migrationKindOrder[kind]; ok {
+		return rank
+	}
+	return 10
+}
+
+// MigrationMappings rewrites cluster-specific references. Registries maps a
+// registry or repository prefix of the source to its replacement.
+type MigrationMappings struct {
+	StorageClasses map[string]string `yaml:"storage_classes" mapstructure:"storage_classes"`
+	IngressClasses map[string]string `yaml:"ingress_classes" mapstructure:"ingress_classes"`
+	Registries     map[string]string `yaml:"registries" mapstructure:"registries"`
+}
+
+// loadMigrationMappings reads the mapping file, or migrate.mappings from the
+// config when no file is given
+func loadMigrationMappings(path string) (*MigrationMappings, error) {
+	mappings := &MigrationMappings{}
+	if path == "" {
+		if err := viper.UnmarshalKey("migrate.mappings", mappings); err != nil {
+			return nil, fmt.Errorf("invalid migrate.mappings: %w", err)
+		}
+		return mappings, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, mappings); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return mappings, nil
+}
+
+// rewriteImage maps the registry of an image, preferring the longest
+// matching prefix, and keeps its tag and digest
+func (m *MigrationMappings) rewriteImage(image string) (string, bool) {
+	registry, repository, tag, digest := parseImage(image)
+	full := registry + "/" + repository
+
+	var match string
+	for from := range m.Registries {
+		from = strings.TrimSuffix(from, "/")
+		if (full == from || strings.HasPrefix(full, from+"/")) && len(from) > len(match) {
+			match = from
+		}
+	}
+	if match == "" {
+		return image, false
+	}
+	to := m.Registries[match]
+	if to == "" {
+		to = m.Registries[match+"/"]
+	}
+	rewritten := strings.TrimSuffix(to, "/") + full[len(match):]
+	if tag != "" {
+		rewritten += ":" + tag
+	}
+	if digest != "" {
+		rewritten += "@" + digest
+	}
+	return rewritten, rewritten != image
+}
+
+// rewriteImages rewrites every container image below value
+func (m *MigrationMappings) rewriteImages(value interface{}, rewrites *[]string) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if key != "containers" && key != "initContainers" && key != "ephemeralContainers" {
+				m.rewriteImages(child, rewrites)
+				continue
+			}
+			containers, _ := child.([]interface{})
+			for _, c := range containers {
+				container, ok := c.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				image, _ := container["image"].(string)
+				if rewritten, ok := m.rewriteImage(image); ok {
+					container["image"] = rewritten
+					*rewrites = append(*rewrites, fmt.Sprintf("image %s -> %s", image, rewritten))
+				}
+			}
+		}
+	case []interface{}:
+		for _, child := range v {
+			m.rewriteImages(child, rewrites)
+		}
+	}
+}
+
+// mapField replaces the string at fields using mapping
+func mapField(obj map[string]interface{}, mapping map[string]string, what string, rewrites *[]string, fields ...string) {
+	value, found, _ := unstructured.NestedString(obj, fields...)
+	if !found {
+		return
+	}
+	if to, ok := mapping[value]; ok && to != value {
+		unstructured.SetNestedField(obj, to, fields...)
+		*rewrites = append(*rewrites, fmt.Sprintf("%s %s -> %s", what, value, to))
+	}
+}
+
+// rewriteForTarget prepares an exported object for the target cluster: it
+// moves it to the target namespace, drops references to source cluster
+// objects such as bound volumes and token secrets, and applies the mappings.
+// It returns the rewrites made.
+func (m *MigrationMappings) rewriteForTarget(obj *unstructured.Unstructured, namespace string) []string {
+	var rewrites []string
+	obj.SetNamespace(namespace)
+	content := obj.Object
+
+	switch obj.GetKind() {
+	case "PersistentVolumeClaim":
+		unstructured.RemoveNestedField(content, "spec", "volumeName")
+		annotations := obj.GetAnnotations()
+		for _, key := range []string{
+			"pv.kubernetes.io/bind-completed", "pv.kubernetes.io/bound-by-controller",
+			"volume.beta.kubernetes.io/storage-provisioner", "volume.kubernetes.io/storage-provisioner",
+			"volume.kubernetes.io/selected-node",
+		} {
+			delete(annotations, key)
+		}
+		obj.SetAnnotations(annotations)
+		mapField(content, m.StorageClasses, "storage class", &rewrites, "spec", "storageClassName")
+	case "StatefulSet":
+		templates, _, _ := unstructured.NestedSlice(content, "spec", "volumeClaimTemplates")
+		for _, t := range templates {
+			if template, ok := t.(map[string]interface{}); ok {
+				mapField(template, m.StorageClasses, "storage class", &rewrites, "spec", "storageClassName")
+			}
+		}
+		if len(templates) > 0 {
+			unstructured.SetNestedSlice(content, templates, "spec", "volumeClaimTemplates")
+		}
+	case "Ingress":
+		mapField(content, m.IngressClasses, "ingress class", &rewrites, "spec", "ingressClassName")
+		mapField(content, m.IngressClasses, "ingress class", &rewrites, "metadata", "annotations", "kubernetes.io/ingress.class")
+	case "ServiceAccount":
+		delete(content, "secrets")
+	}
+
+	m.rewriteImages(content, &rewrites)
+	return rewrites
+}
+
+// MigrationOptions configures MigrateNamespace
+type MigrationOptions struct {
+	From            string
+	To              string
+	Namespace       string
+	TargetNamespace string
+	Include         []string
+	Exclude         []string
+	LabelSelector   string
+	Mappings        *MigrationMappings
+	DryRun          bool
+	Verify          bool
+	Timeout         time.Duration
+}
+
+// MigratedObject is one object applied to the target cluster
+type MigratedObject struct {
+	Kind     string   `json:"kind"`
+	Name     string   `json:"name"`
+	Status   string   `json:"status"`
+	Rewrites []string `json:"rewrites,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// MigrationReport is the result of migrate namespace
+type MigrationReport struct {
+	From            string           `json:"from"`
+	To              string           `json:"to"`
+	Namespace       string           `json:"namespace"`
+	TargetNamespace string           `json:"target_namespace"`
+	DryRun          bool             `json:"dry_run"`
+	Objects         []MigratedObject `json:"objects"`
+	Rollouts        []*RolloutResult `json:"rollouts,omitempty"`
+	Health          *ClusterHealth   `json:"health,omitempty"`
+	Succeeded       bool             `json:"succeeded"`
+}
+
+// collectMigrationObjects reads the namespace's objects from the source
+// cluster and sorts them into apply order
+func (k *K8sToolkit) collectMigrationObjects(ctx context.Context, opts MigrationOptions) ([]*unstructured.Unstructured, error) {
+	var objects []*unstructured.Unstructured
+	exportOpts := ExportOptions{
+		Include:       opts.Include,
+		Exclude:       opts.Exclude,
+		LabelSelector: opts.LabelSelector,
+		visit: func(gvr schema.GroupVersionResource, namespaced bool, obj *unstructured.Unstructured) error {
+			// The root CA config map is published into every namespace
+			if obj.GetKind() == "ConfigMap" && obj.GetName() == "kube-root-ca.crt" {
+				return nil
+			}
+			objects = append(objects, &unstructured.Unstructured{Object: cleanForExport(obj.Object)})
+			return nil
+		},
+	}
+	if _, err := k.Export(ctx, exportOpts); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		a, b := objects[i], objects[j]
+		if ra, rb := migrationKindRank(a.GetKind()), migrationKindRank(b.GetKind()); ra != rb {
+			return ra < rb
+		}
+		if a.GetKind() != b.GetKind() {
+			return a.GetKind() < b.GetKind()
+		}
+		return a.GetName() < b.GetName()
+	})
+	return objects, nil
+}
+
+// ensureNamespace creates the target namespace with the labels and
+// annotations of the source namespace when it does not exist yet, and
+// reports whether it had to be created
+func (k *K8sToolkit) ensureNamespace(ctx context.Context, source *corev1.Namespace, name string, dryRun bool) (bool, error) {
+	if _, err := k.clientset.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{}); err == nil || !apierrors.IsNotFound(err) {
+		return false, err
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: source.Labels, Annotations: source.Annotations}}
+	delete(ns.Labels, "kubernetes.io/metadata.name")
+	options := metav1.CreateOptions{FieldManager: migrationFieldManager}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	_, err := k.clientset.CoreV1().Namespaces().Create(ctx, ns, options)
+	return err == nil, err
+}
+
+// applyObject server-side applies an object to the target namespace. When
+// the namespace only exists as a dry-run create, namespaced objects cannot
+// be dry-run applied into it; they are only checked against the target's
+// API and applyObject reports them as skipped.
+func (k *K8sToolkit) applyObject(ctx context.Context, mapper meta.RESTMapper, obj *unstructured.Unstructured, dryRun, namespaceMissing bool) (bool, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, fmt.Errorf("kind %s is not served by the target cluster: %w", gvk, err)
+	}
+	if dryRun && namespaceMissing && mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return true, nil
+	}
+	data, err := json.Marshal(obj.Object)
+	if err != nil {
+		return false, err
+	}
+	force := true
+	options := metav1.PatchOptions{FieldManager: migrationFieldManager, Force: &force}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	_, err = k.dynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace()).
+		Patch(ctx, obj.GetName(), types.ApplyPatchType, data, options)
+	return false, err
+}
+
+// MigrateNamespace copies a namespace's objects from source to target,
+// rewriting cluster-specific fields, and verifies the workloads afterwards.
+// Objects are applied in dependency order; a failing object is recorded and
+// the migration continues. Volume contents are not copied.
+func MigrateNamespace(ctx context.Context, source, target *K8sToolkit, opts MigrationOptions, m *mutation) (*MigrationReport, error) {
+	report := &MigrationReport{
+		From:            opts.From,
+		To:              opts.To,
+		Namespace:       opts.Namespace,
+		TargetNamespace: opts.TargetNamespace,
+		DryRun:          opts.DryRun,
+	}
+
+	sourceNamespace, err := source.clientset.CoreV1().Namespaces().Get(ctx, opts.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read namespace %s in %s: %w", opts.Namespace, opts.From, err)
+	}
+	objects, err := source.collectMigrationObjects(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	namespaceMissing, err := target.ensureNamespace(ctx, sourceNamespace, opts.TargetNamespace, opts.DryRun)
+	if m != nil {
+		m.record("create-namespace", opts.To+"/"+opts.TargetNamespace, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create namespace %s in %s: %w", opts.TargetNamespace, opts.To, err)
+	}
+
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(target.clientset.Discovery()))
+	report.Succeeded = true
+	var workloads []objectRef
+	for _, obj := range objects {
+		migrated := MigratedObject{Kind: obj.GetKind(), Name: obj.GetName(), Status: "applied"}
+		migrated.Rewrites = opts.Mappings.rewriteForTarget(obj, opts.TargetNamespace)
+		if opts.DryRun {
+			migrated.Status = "dry-run"
+		}
+
+		skipped, err := target.applyObject(ctx, mapper, obj, opts.DryRun, namespaceMissing)
+		if skipped {
+			migrated.Status = "dry-run"
+			migrated.Rewrites = append(migrated.Rewrites, fmt.Sprintf("would create (namespace %s does not exist yet)", opts.TargetNamespace))
+		}
+		if m != nil {
+			m.record("apply", fmt.Sprintf("%s/%s %s/%s", opts.To, opts.TargetNamespace, strings.ToLower(obj.GetKind()), obj.GetName()), err)
+		}
+		if err != nil {
+			migrated.Status = "failed"
+			migrated.Error = classifyError(err).Error()
+			report.Succeeded = false
+		} else if ref, err := parseWorkloadRef(opts.TargetNamespace, obj.GetKind()+"/"+obj.GetName()); err == nil {
+			workloads = append(workloads, ref)
+		}
+		report.Objects = append(report.Objects, migrated)
+	}
+
+	if opts.DryRun || !opts.Verify {
+		return report, nil
+	}
+
+	// Verification: every migrated workload must roll out, then the
+	// namespace-scoped health checks run against the target
+	for _, ref := range workloads {
+		result := target.WaitForRollout(ctx, ref, opts.Timeout, true)
+		if !result.Succeeded {
+			report.Succeeded = false
+		}
+		report.Rollouts = append(report.Rollouts, result)
+	}
+	health, err := target.RunHealthCheck(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to run health checks on %s: %w", opts.To, err)
+	}
+	report.Health = health
+	if health.OverallStatus == "Critical" {
+		report.Succeeded = false
+	}
+	return report, nil
+}
+
+// PrintMigrationReport prints the applied objects and the verification
+func (k *K8sToolkit) PrintMigrationReport(report *MigrationReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	mode := ""
+	if report.DryRun {
+		mode = " (dry run)"
+	}
+	fmt.Printf("Migration of %s/%s to %s/%s%s\n\n", report.From, report.Namespace, report.To, report.TargetNamespace, mode)
+	fmt.Printf("%-25s %-40s %-8s %s\n", "KIND", "NAME", "STATUS", "DETAILS")
+	for _, o := range report.Objects {
+		details := strings.Join(o.Rewrites, "; ")
+		if o.Error != "" {
+			details = o.Error
+		}
+		fmt.Printf("%-25s %-40s %-8s %s\n", o.Kind, o.Name, o.Status, details)
+	}
+
+	if len(report.Rollouts) > 0 {
+		fmt.Println("\nRollouts:")
+		for _, r := range report.Rollouts {
+			if r.Succeeded {
+				fmt.Printf("  ✅ %s ready in %s\n", r.Workload, r.Duration)
+				continue
+			}
+			fmt.Printf("  ❌ %s: %s\n", r.Workload, r.Reason)
+			for _, issue := range r.PodIssues {
+				fmt.Printf("     - %s\n", issue)
+			}
+		}
+	}
+	if report.Health != nil {
+		fmt.Printf("\nHealth of %s/%s: %s\n", report.To, report.TargetNamespace, report.Health.OverallStatus)
+		for _, check := range report.Health.Checks {
+			if check.Status != "Healthy" {
+				fmt.Printf("  %-25s %-8s %s\n", check.Component, check.Status, check.Message)
+			}
+		}
+	}
+
+	if report.Succeeded {
+		fmt.Println("\n✅ Migration succeeded")
+	} else {
+		fmt.Println("\n❌ Migration incomplete; see the failures above")
+	}
+}
+
+// createMigrateCmd creates the migrate command
+func createMigrateCmd() *cobra.Command {
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Move resources between clusters",
+	}
+
+	var opts MigrationOptions
+	var mappingsFile string
+	var skipVerify bool
+
+	namespaceCmd := &cobra.Command{
+		Use:   "namespace <name>",
+		Short: "Copy a namespace's resources from one cluster to another",
+		Long: `Exports the resources of a namespace from the --from context like export does (skipping
+objects owned by a controller, events and service account tokens), rewrites cluster-specific
+fields and server-side applies them to the --to context in dependency order: service accounts,
+secrets and config maps, RBAC, claims and services first, then custom resources, workloads,
+autoscalers and ingresses. Claims lose their bound volume and are provisioned anew; volume
+contents are not copied.
+
+Storage classes, ingress classes and image registries are rewritten with mapping rules from
+--mappings or migrate.mappings in the config:
+
+  storage_classes: {gp3: premium-rwo}
+  ingress_classes: {alb: nginx}
+  registries: {123456789012.dkr.ecr.eu-west-1.amazonaws.com: europe-docker.pkg.dev/acme/images}
+
+Afterwards every migrated Deployment, StatefulSet and DaemonSet must finish rolling out within
+--timeout and the namespace-scoped health checks run against the target. Exits 1 when an
+object fails to apply or verification fails. --dry-run applies with server-side dry run.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.Namespace = args[0]
+			if opts.TargetNamespace == "" {
+				opts.TargetNamespace = opts.Namespace
+			}
+			if opts.From == "" || opts.To == "" {
+				logger.Fatalf("Both --from and --to are required")
+			}
+			if opts.From == opts.To && opts.Namespace == opts.TargetNamespace {
+				logger.Fatalf("Source and target are the same namespace; set --target-namespace")
+			}
+			opts.Verify = !skipVerify
+
+			mappings, err := loadMigrationMappings(mappingsFile)
+			if err != nil {
+				logger.Fatalf("Failed to load mappings: %v", err)
+			}
+			opts.Mappings = mappings
+
+			source, err := NewK8sToolkitForContext(opts.From)
+			if err != nil {
+				logger.Fatalf("Failed to connect to %s: %v", opts.From, err)
+			}
+			source.namespace = opts.Namespace
+			target, err := NewK8sToolkitForContext(opts.To)
+			if err != nil {
+				logger.Fatalf("Failed to connect to %s: %v", opts.To, err)
+			}
+			target.namespace = opts.TargetNamespace
+
+			var m *mutation
+			if !opts.DryRun {
+				m, err = beginMutation("migrate namespace", fmt.Sprintf("About to apply the resources of %s/%s to %s/%s.", opts.From, opts.Namespace, opts.To, opts.TargetNamespace))
+				if err != nil {
+					logger.Fatalf("%v", err)
+				}
+			}
+
+			report, err := MigrateNamespace(context.Background(), source, target, opts, m)
+			if err != nil && report == nil {
+				logger.Fatalf("Migration failed: %v", err)
+			}
+			target.PrintMigrationReport(report)
+			if err != nil {
+				logger.Fatalf("Verification failed: %v", err)
+			}
+			if !report.Succeeded {
+				os.Exit(1)
+			}
+		},
+	}
+	namespaceCmd.Flags().StringVar(&opts.From, "from", "", "Kubeconfig context to read the namespace from")
+	namespaceCmd.Flags().StringVar(&opts.To, "to", "", "Kubeconfig context to apply the namespace to")
+	namespaceCmd.Flags().StringVar(&opts.TargetNamespace, "target-namespace", "", "Namespace to create in the target (default the source name)")
+	namespaceCmd.Flags().StringVar(&mappingsFile, "mappings", "", "YAML file with storage_classes, ingress_classes and registries mappings (default migrate.mappings from the config)")
+	namespaceCmd.Flags().StringSliceVar(&opts.Include, "include", nil, "Only migrate these resources (plural name, name.group or kind)")
+	namespaceCmd.Flags().StringSliceVar(&opts.Exclude, "exclude", nil, "Skip these resources in addition to events, endpoints, leases and controller revisions")
+	namespaceCmd.Flags().StringVarP(&opts.LabelSelector, "selector", "l", "", "Only migrate objects matching this label selector")
+	namespaceCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "Apply with server-side dry run and skip verification")
+	namespaceCmd.Flags().BoolVar(&skipVerify, "skip-verify", false, "Do not wait for rollouts or run health checks after applying")
+	namespaceCmd.Flags().DurationVar(&opts.Timeout, "timeout", 10*time.Minute, "Time each migrated workload has to roll out")
+
+	migrateCmd.AddCommand(namespaceCmd)
+	return migrateCmd
+}
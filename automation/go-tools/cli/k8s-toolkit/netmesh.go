@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// netmeshName names the probe DaemonSet and labels its pods
+const netmeshName = "k8s-toolkit-netmesh"
+
+var (
+	pingLossPattern = regexp.MustCompile(`([\d.]+)% packet loss`)
+	pingRTTPattern  = regexp.MustCompile(`= ([\d.]+)/([\d.]+)/([\d.]+)`)
+)
+
+// NetMeshOptions configures a node-to-node latency probe
+type NetMeshOptions struct {
+	Namespace    string
+	Image        string
+	Count        int
+	ReadyTimeout time.Duration
+}
+
+// NetMeshPair is the latency measured from one node to another
+type NetMeshPair struct {
+	From        string  `json:"from"`
+	To          string  `json:"to"`
+	AvgMs       float64 `json:"avg_ms"`
+	MaxMs       float64 `json:"max_ms"`
+	LossPercent float64 `json:"loss_percent"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// NetMeshReport is the latency between every pair of probed nodes
+type NetMeshReport struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Nodes       []string      `json:"nodes"`
+	Pairs       []NetMeshPair `json:"pairs"`
+}
+
+// NetMeshRow is one row of the latency matrix, in Nodes order
+type NetMeshRow struct {
+	Node  string
+	Cells []*NetMeshPair
+}
+
+// Rows returns the latency matrix; the diagonal and unmeasured pairs are nil
+func (r *NetMeshReport) Rows() []NetMeshRow {
+	index := make(map[string]*NetMeshPair)
+	for i := range r.Pairs {
+		index[r.Pairs[i].From+"\x00"+r.Pairs[i].To] = &r.Pairs[i]
+	}
+	rows := make([]NetMeshRow, len(r.Nodes))
+	for i, from := range r.Nodes {
+		rows[i] = NetMeshRow{Node: from, Cells: make([]*NetMeshPair, len(r.Nodes))}
+		for j, to := range r.Nodes {
+			rows[i].Cells[j] = index[from+"\x00"+to]
+		}
+	}
+	return rows
+}
+
+// RunNetMesh deploys a probe DaemonSet, pings every other probe pod from each
+// one and removes the DaemonSet again, even when probing fails
+func (k *K8sToolkit) RunNetMesh(ctx context.Context, opts NetMeshOptions, m *mutation) (*NetMeshReport, error) {
+	ds := netmeshDaemonSet(opts)
+	target := opts.Namespace + "/" + netmeshName
+	_, err := k.clientset.AppsV1().DaemonSets(opts.Namespace).Create(ctx, ds, metav1.CreateOptions{})
+	m.record("create", "DaemonSet "+target, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create probe daemonset: %w", err)
+	}
+	defer func() {
+		// Tear down with a fresh context so an interrupt still cleans up
+		deleteCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		err := k.clientset.AppsV1().DaemonSets(opts.Namespace).Delete(deleteCtx, netmeshName, metav1.DeleteOptions{})
+		m.record("delete", "DaemonSet "+target, err)
+		if err != nil {
+			k.log().Warnf("failed to delete probe daemonset %s: %v", target, err)
+		}
+	}()
+
+	pods, err := k.waitForNetMeshPods(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &NetMeshReport{GeneratedAt: time.Now()}
+	for _, pod := range pods {
+		report.Nodes = append(report.Nodes, pod.Spec.NodeName)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, 10)
+	for _, source := range pods {
+		wg.Add(1)
+		go func(source corev1.Pod) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			pairs := k.probeFromPod(ctx, source, pods, opts.Count)
+			mu.Lock()
+			report.Pairs = append(report.Pairs, pairs...)
+			mu.Unlock()
+		}(source)
+	}
+	wg.Wait()
+
+	sort.Slice(report.Pairs, func(i, j int) bool {
+		if report.Pairs[i].From != report.Pairs[j].From {
+			return report.Pairs[i].From < report.Pairs[j].From
+		}
+		return report.Pairs[i].To < report.Pairs[j].To
+	})
+	return report, nil
+}
+
+// netmeshDaemonSet builds the probe DaemonSet. It tolerates every taint so
+// all nodes are covered, and its pods exit on their own after an hour in
+// case the teardown never runs.
+func netmeshDaemonSet(opts NetMeshOptions) *appsv1.DaemonSet {
+	labels := map[string]string{"app.kubernetes.io/name": netmeshName}
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Name: netmeshName, Namespace: opts.Namespace, Labels: labels},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Tolerations:                   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+					TerminationGracePeriodSeconds: new(int64),
+					Containers: []corev1.Container{{
+						Name:    "probe",
+						Image:   opts.Image,
+						Command: []string{"sleep", "3600"},
+						SecurityContext: &corev1.SecurityContext{
+							Capabilities: &corev1.Capabilities{Add: []corev1.Capability{"NET_RAW"}},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// waitForNetMeshPods waits until a probe pod runs on every scheduled node
+func (k *K8sToolkit) waitForNetMeshPods(ctx context.Context, opts NetMeshOptions) ([]corev1.Pod, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.ReadyTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		ds, err := k.clientset.AppsV1().DaemonSets(opts.Namespace).Get(ctx, netmeshName, metav1.GetOptions{})
+		if err == nil && ds.Status.DesiredNumberScheduled > 0 && ds.Status.NumberReady == ds.Status.DesiredNumberScheduled {
+			pods, err := k.clientset.CoreV1().Pods(opts.Namespace).List(ctx, metav1.ListOptions{LabelSelector: "app.kubernetes.io/name=" + netmeshName})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list probe pods: %w", err)
+			}
+			var running []corev1.Pod
+			for _, pod := range pods.Items {
+				if pod.Status.Phase == corev1.PodRunning && pod.Status.PodIP != "" && pod.DeletionTimestamp == nil {
+					running = append(running, pod)
+				}
+			}
+			sort.Slice(running, func(i, j int) bool { return running[i].Spec.NodeName < running[j].Spec.NodeName })
+			return running, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("probe pods not ready after %s", opts.ReadyTimeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeFromPod pings every other probe pod from source in a single exec
+func (k *K8sToolkit) probeFromPod(ctx context.Context, source corev1.Pod, pods []corev1.Pod, count int) []NetMeshPair {
+	var script strings.Builder
+	var targets []corev1.Pod
+	for _, pod := range pods {
+		if pod.Name == source.Name {
+			continue
+		}
+		targets = append(targets, pod)
+		fmt.Fprintf(&script, "echo '== %s'; ping -c %d -W 1 %s 2>&1; ", pod.Spec.NodeName, count, pod.Status.PodIP)
+	}
+
+	stdout, stderr, err := k.execInPod(ctx, source.Namespace, source.Name, "probe", []string{"sh", "-c", script.String()})
+	if err != nil && stdout == "" {
+		var pairs []NetMeshPair
+		for _, target := range targets {
+			pairs = append(pairs, NetMeshPair{From: source.Spec.NodeName, To: target.Spec.NodeName, LossPercent: 100,
+				Error: strings.TrimSpace(fmt.Sprintf("exec failed: %v %s", err, stderr))})
+		}
+		return pairs
+	}
+
+	var pairs []NetMeshPair
+	for _, block := range strings.Split(stdout, "== ")[1:] {
+		lines := strings.SplitN(block, "\n", 2)
+		pair := NetMeshPair{From: source.Spec.NodeName, To: strings.TrimSpace(lines[0]), LossPercent: 100}
+		output := ""
+		if len(lines) > 1 {
+			output = lines[1]
+		}
+		if match := pingLossPattern.FindStringSubmatch(output); match != nil {
+			pair.LossPercent, _ = strconv.ParseFloat(match[1], 64)
+		} else {
+			pair.Error = strings.TrimSpace(output)
+		}
+		if match := pingRTTPattern.FindStringSubmatch(output); match != nil {
+			pair.AvgMs, _ = strconv.ParseFloat(match[2], 64)
+			pair.MaxMs, _ = strconv.ParseFloat(match[3], 64)
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+// execInPod runs a command in a container and returns its output
+func (k *K8sToolkit) execInPod(ctx context.Context, namespace, pod, container string, command []string) (string, string, error) {
+	if readOnly() {
+		return "", "", fmt.Errorf("%w: exec into %s/%s", ErrReadOnly, namespace, pod)
+	}
+
+	req := k.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(pod).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(k.restConfig, "POST", req.URL())
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create executor: %w", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr})
+	return stdout.String(), stderr.String(), err
+}
+
+// PrintNetMeshReport prints the average latency matrix in milliseconds.
+// Pairs with packet loss are marked with the loss percentage.
+func (k *K8sToolkit) PrintNetMeshReport(report *NetMeshReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Println("Node Latency Matrix (avg ms, from row to column)")
+	fmt.Println("=====================================")
+	for i, node := range report.Nodes {
+		fmt.Printf("[%d] %s\n", i, node)
+	}
+	fmt.Println()
+
+	fmt.Printf("%6s", "")
+	for i := range report.Nodes {
+		fmt.Printf(" %10s", fmt.Sprintf("[%d]", i))
+	}
+	fmt.Println()
+	for i, row := range report.Rows() {
+		fmt.Printf("%6s", fmt.Sprintf("[%d]", i))
+		for _, cell := range row.Cells {
+			fmt.Printf(" %10s", netmeshCell(cell))
+		}
+		fmt.Println()
+	}
+}
+
+// netmeshCell formats one matrix cell: "-" on the diagonal, "X" when every
+// packet was lost, and the loss percentage next to the latency otherwise
+func netmeshCell(cell *NetMeshPair) string {
+	switch {
+	case cell == nil:
+		return "-"
+	case cell.LossPercent >= 100:
+		return "X"
+	case cell.LossPercent > 0:
+		return fmt.Sprintf("%.2f/%.0f%%", cell.AvgMs, cell.LossPercent)
+	}
+	return fmt.Sprintf("%.2f", cell.AvgMs)
+}
+
+// netmeshHeat returns the heatmap background color of a matrix cell, from
+// green for under a millisecond to red for loss or 10ms and more
+func netmeshHeat(cell *NetMeshPair) string {
+	switch {
+	case cell == nil:
+		return "#eeeeee"
+	case cell.LossPercent > 0 || cell.AvgMs >= 10:
+		return "#e74c3c"
+	case cell.AvgMs >= 2:
+		return "#f39c12"
+	case cell.AvgMs >= 1:
+		return "#f1c40f"
+	}
+	return "#2ecc71"
+}
+
+// createNetMeshCmd creates the netmesh command
+func createNetMeshCmd() *cobra.Command {
+	opts := NetMeshOptions{}
+	var htmlFile string
+
+	netmeshCmd := &cobra.Command{
+		Use:   "netmesh",
+		Short: "Measure pod-to-pod latency and loss between all node pairs",
+		Long: `Deploys a short-lived DaemonSet of probe pods, pings every probe pod from every other one
+and renders the average latency and packet loss as a node-by-node matrix, then removes the
+DaemonSet. A single slow row or column points at one node or its switch.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			if opts.Namespace == "" {
+				opts.Namespace = toolkit.namespace
+			}
+			if opts.Namespace == "" {
+				opts.Namespace = "default"
+			}
+
+			m, err := beginMutation("netmesh", fmt.Sprintf("About to create DaemonSet %s/%s on every node and exec into its pods.", opts.Namespace, netmeshName))
+			if err != nil {
+				logger.Fatalf("Netmesh aborted: %v", err)
+			}
+
+			// Cancel on interrupt so the probe DaemonSet is still torn down
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			report, err := toolkit.RunNetMesh(ctx, opts, m)
+			if err != nil {
+				logger.Fatalf("Failed to probe node latency: %v", err)
+			}
+
+			toolkit.PrintNetMeshReport(report)
+
+			if htmlFile != "" {
+				f, err := os.Create(htmlFile)
+				if err != nil {
+					logger.Fatalf("Failed to create %s: %v", htmlFile, err)
+				}
+				defer f.Close()
+				if err := renderHTML(f, "netmesh.html.tmpl", report); err != nil {
+					logger.Fatalf("Failed to render %s: %v", htmlFile, err)
+				}
+			}
+		},
+	}
+
+	netmeshCmd.Flags().StringVar(&opts.Namespace, "probe-namespace", "", "Namespace to deploy the probe DaemonSet into (default: --namespace or default)")
+	netmeshCmd.Flags().StringVar(&opts.Image, "image", "busybox:1.36", "Probe image; must provide sh and ping")
+	netmeshCmd.Flags().IntVar(&opts.Count, "count", 5, "Pings per node pair")
+	netmeshCmd.Flags().DurationVar(&opts.ReadyTimeout, "ready-timeout", 2*time.Minute, "Time to wait for the probe pods to start")
+	netmeshCmd.Flags().StringVar(&htmlFile, "html", "", "Also write the heatmap as HTML to this file")
+
+	return netmeshCmd
+}
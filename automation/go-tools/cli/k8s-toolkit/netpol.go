@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NamespaceCoverage describes NetworkPolicy coverage of one namespace
+type NamespaceCoverage struct {
+	Namespace          string   `json:"namespace"`
+	Policies           int      `json:"policies"`
+	Workloads          int      `json:"workloads"`
+	CoveredWorkloads   int      `json:"covered_workloads"`
+	DefaultDenyIngress bool     `json:"default_deny_ingress"`
+	DefaultDenyEgress  bool     `json:"default_deny_egress"`
+	Uncovered          []string `json:"uncovered,omitempty"`
+}
+
+// NetworkPolicyCoverage is the cluster-wide segmentation report
+type NetworkPolicyCoverage struct {
+	Namespaces       []NamespaceCoverage `json:"namespaces"`
+	Workloads        int                 `json:"workloads"`
+	CoveredWorkloads int                 `json:"covered_workloads"`
+	CoveragePercent  float64             `json:"coverage_percent"`
+	Findings         []Finding           `json:"findings"`
+}
+
+// isDefaultDeny reports whether the policy selects every pod in its namespace
+// and allows no traffic of the given type
+func isDefaultDeny(policy *networkingv1.NetworkPolicy, policyType networkingv1.PolicyType) bool {
+	if len(policy.Spec.PodSelector.MatchLabels) > 0 || len(policy.Spec.PodSelector.MatchExpressions) > 0 {
+		return false
+	}
+
+	hasType := false
+	for _, t := range policy.Spec.PolicyTypes {
+		if t == policyType {
+			hasType = true
+		}
+	}
+	// With no policyTypes set, Ingress is implied and Egress only when egress rules exist
+	if len(policy.Spec.PolicyTypes) == 0 && policyType == networkingv1.PolicyTypeIngress {
+		hasType = true
+	}
+	if !hasType {
+		return false
+	}
+
+	if policyType == networkingv1.PolicyTypeIngress {
+		return len(policy.Spec.Ingress) == 0
+	}
+	return len(policy.Spec.Egress) == 0
+}
+
+// NetworkPolicyCoverage computes which workloads no NetworkPolicy selects and
+// which namespaces lack a default-deny policy
+func (k *K8sToolkit) NetworkPolicyCoverage(ctx context.Context, exclude []string) (*NetworkPolicyCoverage, error) {
+	policies, err := k.clientset.NetworkingV1().NetworkPolicies(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network policies: %w", err)
+	}
+	rsOwners, err := k.replicaSetOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := make(map[string]bool)
+	for _, ns := range exclude {
+		excluded[ns] = true
+	}
+
+	type namespaceState struct {
+		coverage  NamespaceCoverage
+		selectors []labels.Selector
+		// covered tracks, per workload, whether every pod is selected
+		covered map[string]bool
+	}
+	namespaces := make(map[string]*namespaceState)
+	stateFor := func(ns string) *namespaceState {
+		state, ok := namespaces[ns]
+		if !ok {
+			state = &namespaceState{coverage: NamespaceCoverage{Namespace: ns}, covered: make(map[string]bool)}
+			namespaces[ns] = state
+		}
+		return state
+	}
+
+	if k.namespace != "" {
+		stateFor(k.namespace)
+	} else {
+		namespaceList, err := k.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, ns := range namespaceList.Items {
+			if !excluded[ns.Name] {
+				stateFor(ns.Name)
+			}
+		}
+	}
+
+	for i := range policies.Items {
+		policy := &policies.Items[i]
+		if excluded[policy.Namespace] {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil {
+			continue
+		}
+
+		state := stateFor(policy.Namespace)
+		state.coverage.Policies++
+		state.selectors = append(state.selectors, selector)
+		if isDefaultDeny(policy, networkingv1.PolicyTypeIngress) {
+			state.coverage.DefaultDenyIngress = true
+		}
+		if isDefaultDeny(policy, networkingv1.PolicyTypeEgress) {
+			state.coverage.DefaultDenyEgress = true
+		}
+	}
+
+	err = k.eachPod(ctx, k.namespace, metav1.ListOptions{}, func(pod *corev1.Pod) error {
+		if excluded[pod.Namespace] || pod.Spec.HostNetwork {
+			// Host-network pods are not subject to NetworkPolicy
+			return nil
+		}
+
+		state := stateFor(pod.Namespace)
+		selected := false
+		for _, selector := range state.selectors {
+			if selector.Matches(labels.Set(pod.Labels)) {
+				selected = true
+				break
+			}
+		}
+
+		workload := podWorkload(pod, rsOwners).String()
+		if previous, ok := state.covered[workload]; ok {
+			state.covered[workload] = previous && selected
+		} else {
+			state.covered[workload] = selected
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	report := &NetworkPolicyCoverage{}
+	for _, state := range namespaces {
+		coverage := state.coverage
+		for workload, covered := range state.covered {
+			coverage.Workloads++
+			if covered {
+				coverage.CoveredWorkloads++
+				continue
+			}
+			coverage.Uncovered = append(coverage.Uncovered, workload)
+			report.Findings = append(report.Findings, Finding{
+				Source:    "security-netpol",
+				RuleID:    "netpol/unselected-workload",
+				Severity:  "Low",
+				Namespace: coverage.Namespace,
+				Resource:  workload,
+				Message:   "no NetworkPolicy selects its pods",
+			})
+		}
+		sort.Strings(coverage.Uncovered)
+
+		// Empty namespaces are reported too so new workloads do not start
+		// out unsegmented
+		if !coverage.DefaultDenyIngress {
+			report.Findings = append(report.Findings, Finding{
+				Source:    "security-netpol",
+				RuleID:    "netpol/no-default-deny",
+				Severity:  "Medium",
+				Namespace: coverage.Namespace,
+				Resource:  "namespace/" + coverage.Namespace,
+				Message:   "no default-deny ingress policy",
+			})
+		}
+
+		report.Workloads += coverage.Workloads
+		report.CoveredWorkloads += coverage.CoveredWorkloads
+		report.Namespaces = append(report.Namespaces, coverage)
+	}
+
+	sort.Slice(report.Namespaces, func(i, j int) bool {
+		return report.Namespaces[i].Namespace < report.Namespaces[j].Namespace
+	})
+	if report.Workloads > 0 {
+		report.CoveragePercent = float64(report.CoveredWorkloads) / float64(report.Workloads) * 100
+	}
+	sortFindings(report.Findings)
+	return report, nil
+}
+
+// netpolFindings adapts NetworkPolicyCoverage to a compliance finding source
+func (k *K8sToolkit) netpolFindings(ctx context.Context) ([]Finding, error) {
+	report, err := k.NetworkPolicyCoverage(ctx, systemNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	return report.Findings, nil
+}
+
+// PrintNetworkPolicyCoverage prints coverage per namespace and the cluster total
+func (k *K8sToolkit) PrintNetworkPolicyCoverage(report *NetworkPolicyCoverage) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Printf("NetworkPolicy Coverage Report\n")
+	fmt.Printf("%-30s %-9s %-10s %-9s %-13s %-12s\n", "NAMESPACE", "POLICIES", "WORKLOADS", "COVERED", "DENY-INGRESS", "DENY-EGRESS")
+	for _, ns := range report.Namespaces {
+		fmt.Printf("%-30s %-9d %-10d %-9d %-13t %-12t\n", ns.Namespace, ns.Policies, ns.Workloads, ns.CoveredWorkloads, ns.DefaultDenyIngress, ns.DefaultDenyEgress)
+		if len(ns.Uncovered) > 0 {
+			fmt.Printf("  uncovered: %s\n", strings.Join(ns.Uncovered, ", "))
+		}
+	}
+	fmt.Printf("\nCovered workloads: %d/%d (%.1f%%)\n\n", report.CoveredWorkloads, report.Workloads, report.CoveragePercent)
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxKubeletSkew is the number of minor versions kubelets may lag the API server
+const maxKubeletSkew = 2
+
+// CheckNodeConditions reports node pressure conditions, cordoned nodes,
+// taints no workload tolerates, and kubelet version skew
+func (k *K8sToolkit) CheckNodeConditions(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Node Conditions",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("Failed to list nodes: %v", err)
+		result.Err = err
+		return result
+	}
+
+	// Only the distinct tolerations are needed, not the pods themselves
+	var tolerations []corev1.Toleration
+	seenTolerations := make(map[corev1.Toleration]bool)
+	err = k.eachPod(ctx, "", metav1.ListOptions{}, func(pod *corev1.Pod) error {
+		for _, toleration := range pod.Spec.Tolerations {
+			key := toleration
+			key.TolerationSeconds = nil
+			if !seenTolerations[key] {
+				seenTolerations[key] = true
+				tolerations = append(tolerations, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list pods: %v", err)
+		result.Err = err
+		return result
+	}
+
+	var pressure, cordoned, untolerated []string
+	kubeletVersions := make(map[string][]string)
+
+	for _, node := range nodes.Items {
+		affected := false
+
+		for _, condition := range node.Status.Conditions {
+			switch condition.Type {
+			case corev1.NodeMemoryPressure, corev1.NodeDiskPressure, corev1.NodePIDPressure:
+				if condition.Status == corev1.ConditionTrue {
+					pressure = append(pressure, fmt.Sprintf("%s: %s", node.Name, condition.Type))
+					affected = true
+				}
+			}
+		}
+
+		if node.Spec.Unschedulable {
+			cordoned = append(cordoned, node.Name)
+			affected = true
+		}
+
+		for i := range node.Spec.Taints {
+			taint := &node.Spec.Taints[i]
+			if taint.Effect != corev1.TaintEffectNoSchedule && taint.Effect != corev1.TaintEffectNoExecute {
+				continue
+			}
+			// The cordon taint is already reported as cordoned
+			if taint.Key == corev1.TaintNodeUnschedulable {
+				continue
+			}
+			if !anyTolerates(tolerations, taint) {
+				untolerated = append(untolerated, fmt.Sprintf("%s: %s=%s:%s", node.Name, taint.Key, taint.Value, taint.Effect))
+				affected = true
+			}
+		}
+
+		version := node.Status.NodeInfo.KubeletVersion
+		kubeletVersions[version] = append(kubeletVersions[version], node.Name)
+
+		if affected {
+			result.Affected = append(result.Affected, objectRef{Kind: "Node", Name: node.Name})
+		}
+	}
+
+	var issues []string
+	if len(pressure) > 0 {
+		result.Details["pressure"] = strings.Join(pressure, "; ")
+		issues = append(issues, fmt.Sprintf("%d node pressure conditions", len(pressure)))
+	}
+	if len(cordoned) > 0 {
+		result.Details["cordoned"] = strings.Join(cordoned, ", ")
+		issues = append(issues, fmt.Sprintf("%d nodes cordoned", len(cordoned)))
+	}
+	if len(untolerated) > 0 {
+		result.Details["untolerated_taints"] = strings.Join(untolerated, "; ")
+		issues = append(issues, fmt.Sprintf("%d taints tolerated by no workload", len(untolerated)))
+	}
+
+	versions := make([]string, 0, len(kubeletVersions))
+	for version, names := range kubeletVersions {
+		versions = append(versions, fmt.Sprintf("%s (%d nodes)", version, len(names)))
+	}
+	sort.Strings(versions)
+	result.Details["kubelet_versions"] = strings.Join(versions, ", ")
+
+	if skew := k.kubeletSkew(ctx, kubeletVersions); skew != "" {
+		result.Details["version_skew"] = skew
+		issues = append(issues, "kubelet version skew exceeds policy")
+	}
+
+	if len(issues) > 0 {
+		result.Status = "Warning"
+		result.Message = strings.Join(issues, "; ")
+	} else {
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("No pressure, cordons or untolerated taints across %d nodes", len(nodes.Items))
+	}
+
+	return result
+}
+
+// anyTolerates reports whether at least one toleration matches the taint
+func anyTolerates(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+// kubeletSkew describes kubelets that are newer than the API server or more
+// than maxKubeletSkew minor versions behind it
+func (k *K8sToolkit) kubeletSkew(ctx context.Context, kubeletVersions map[string][]string) string {
+	server, err := k.serverVersion(ctx)
+	if err != nil {
+		return ""
+	}
+	serverMinor, err := strconv.Atoi(strings.TrimSuffix(server.Minor, "+"))
+	if err != nil {
+		return ""
+	}
+
+	var skewed []string
+	for version, names := range kubeletVersions {
+		minor, err := parseMinorVersion(version)
+		if err != nil {
+			continue
+		}
+		if minor > serverMinor || serverMinor-minor > maxKubeletSkew {
+			skewed = append(skewed, fmt.Sprintf("%s on %s", version, strings.Join(names, ", ")))
+		}
+	}
+	if len(skewed) == 0 {
+		return ""
+	}
+
+	sort.Strings(skewed)
+	return fmt.Sprintf("API server 1.%d; unsupported kubelets: %s", serverMinor, strings.Join(skewed, "; "))
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Thresholds at which a node counts as saturated
+const (
+	noisyCPUPercent      = 80
+	noisyMemoryPercent   = 85
+	noisyThrottledRatio  = 0.25
+	noisyThrottledVictim = 2
+	noisyScrapeWorkers   = 8
+)
+
+// containerSample is one container's cAdvisor counters at a point in time
+type containerSample struct {
+	cpuSeconds      float64
+	periods         float64
+	throttled       float64
+	workingSetBytes float64
+	ioBytes         float64
+}
+
+// PodSignals are a pod's saturation signals over the sampling interval
+type PodSignals struct {
+	Namespace       string  `json:"namespace"`
+	Pod             string  `json:"pod"`
+	Node            string  `json:"node"`
+	CPUCores        float64 `json:"cpu_cores"`
+	CPURequest      float64 `json:"cpu_request_cores"`
+	CPULimit        float64 `json:"cpu_limit_cores,omitempty"`
+	ThrottledRatio  float64 `json:"throttled_ratio"`
+	MemoryBytes     int64   `json:"memory_working_set_bytes"`
+	MemoryRequest   int64   `json:"memory_request_bytes"`
+	IOBytesPerSec   float64 `json:"io_bytes_per_second"`
+	MemoryLimitless bool    `json:"-"`
+}
+
+// NodePressure summarizes the saturation of one node
+type NodePressure struct {
+	Node          string   `json:"node"`
+	CPUPercent    float64  `json:"cpu_percent_of_allocatable"`
+	MemoryPercent float64  `json:"memory_percent_of_allocatable"`
+	IOBytesPerSec float64  `json:"io_bytes_per_second"`
+	Conditions    []string `json:"conditions,omitempty"`
+	Throttled     []string `json:"throttled_pods,omitempty"`
+	Saturated     bool     `json:"saturated"`
+}
+
+// NoisySuspect is a pod likely degrading its neighbors
+type NoisySuspect struct {
+	PodSignals
+	Score    float64  `json:"score"`
+	Evidence []string `json:"evidence"`
+}
+
+// NoisyNeighborReport ranks likely noisy neighbors on saturated nodes
+type NoisyNeighborReport struct {
+	Interval string            `json:"interval"`
+	Nodes    []NodePressure    `json:"nodes"`
+	Suspects []NoisySuspect    `json:"suspects"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// scrapeCAdvisor reads the container counters of one node through the API
+// server's node proxy, keyed by namespace/pod
+func (k *K8sToolkit) scrapeCAdvisor(ctx context.Context, node string) (map[string]containerSample, error) {
+	data, err := k.clientset.CoreV1().RESTClient().Get().AbsPath("/api/v1/nodes", node, "proxy/metrics/cadvisor").DoRaw(ctx)
+	if err != nil {
+		return nil, classifyError(err)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse cAdvisor metrics: %w", err)
+	}
+
+	samples := make(map[string]containerSample)
+	collect := func(family string, set func(s *containerSample, value float64)) {
+		f, ok := families[family]
+		if !ok {
+			return
+		}
+		for _, m := range f.GetMetric() {
+			labels := make(map[string]string)
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			// Older kubelets label with container_name and pod_name
+			container, pod := labels["container"], labels["pod"]
+			if container == "" {
+				container, pod = labels["container_name"], labels["pod_name"]
+			}
+			if container == "" || container == "POD" || pod == "" {
+				continue
+			}
+			key := labels["namespace"] + "/" + pod
+			s := samples[key]
+			set(&s, metricValue(m))
+			samples[key] = s
+		}
+	}
+	collect("container_cpu_usage_seconds_total", func(s *containerSample, v float64) { s.cpuSeconds += v })
+	collect("container_cpu_cfs_periods_total", func(s *containerSample, v float64) { s.periods += v })
+	collect("container_cpu_cfs_throttled_periods_total", func(s *containerSample, v float64) { s.throttled += v })
+	collect("container_memory_working_set_bytes", func(s *containerSample, v float64) { s.workingSetBytes += v })
+	collect("container_fs_reads_bytes_total", func(s *containerSample, v float64) { s.ioBytes += v })
+	collect("container_fs_writes_bytes_total", func(s *containerSample, v float64) { s.ioBytes += v })
+	return samples, nil
+}
+
+func metricValue(m *dto.Metric) float64 {
+	switch {
+	case m.Counter != nil:
+		return m.GetCounter().GetValue()
+	case m.Gauge != nil:
+		return m.GetGauge().GetValue()
+	}
+	return m.GetUntyped().GetValue()
+}
+
+// sampleNodes scrapes every node twice, interval apart, and returns the
+// per-pod rates. Nodes that cannot be scraped are returned in errs.
+func (k *K8sToolkit) sampleNodes(ctx context.Context, nodes []string, interval time.Duration) (map[string]map[string]PodSignals, map[string]string) {
+	var mu sync.Mutex
+	first := make(map[string]map[string]containerSample)
+	errs := make(map[string]string)
+
+	scrapeAll := func(into map[string]map[string]containerSample) {
+		jobs := make(chan string)
+		var wg sync.WaitGroup
+		for w := 0; w < noisyScrapeWorkers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for node := range jobs {
+					samples, err := k.scrapeCAdvisor(ctx, node)
+					mu.Lock()
+					if err != nil {
+						errs[node] = err.Error()
+					} else {
+						into[node] = samples
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		for _, node := range nodes {
+			jobs <- node
+		}
+		close(jobs)
+		wg.Wait()
+	}
+
+	start := time.Now()
+	scrapeAll(first)
+	select {
+	case <-time.After(interval):
+	case <-ctx.Done():
+	}
+	second := make(map[string]map[string]containerSample)
+	scrapeAll(second)
+	elapsed := time.Since(start).Seconds()
+
+	signals := make(map[string]map[string]PodSignals)
+	for node, after := range second {
+		before, ok := first[node]
+		if !ok {
+			continue
+		}
+		signals[node] = make(map[string]PodSignals)
+		for key, a := range after {
+			b, ok := before[key]
+			if !ok {
+				continue
+			}
+			namespace, pod, _ := strings.Cut(key, "/")
+			s := PodSignals{
+				Namespace:     namespace,
+				Pod:           pod,
+				Node:          node,
+				CPUCores:      math.Max(0, a.cpuSeconds-b.cpuSeconds) / elapsed,
+				MemoryBytes:   int64(a.workingSetBytes),
+				IOBytesPerSec: math.Max(0, a.ioBytes-b.ioBytes) / elapsed,
+			}
+			if periods := a.periods - b.periods; periods > 0 {
+				s.ThrottledRatio = math.Max(0, a.throttled-b.throttled) / periods
+			}
+			signals[node][key] = s
+		}
+	}
+	return signals, errs
+}
+
+// FindNoisyNeighbors samples container CPU, throttling, memory and disk IO
+// on every node over interval. A node is saturated when its CPU or memory use
+// is high, the kubelet reports a pressure condition, or several pods on it
+// are CPU throttled. Pods on saturated nodes are ranked by how far they use
+// CPU and memory beyond their requests and by their share of the node's
+// disk IO, which is what they take from their neighbors.
+func (k *K8sToolkit) FindNoisyNeighbors(ctx context.Context, interval time.Duration) (*NoisyNeighborReport, error) {
+	nodeList, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	// Requests and limits of running pods: CPU in millicores, memory in bytes
+	resources := make(map[string][4]int64)
+	err = k.eachPod(ctx, "", metav1.ListOptions{FieldSelector: "status.phase=Running"}, func(pod *corev1.Pod) error {
+		cpuRequest, cpuLimit, memoryRequest, memoryLimit := podResources(pod)
+		resources[pod.Namespace+"/"+pod.Name] = [4]int64{cpuRequest, cpuLimit, memoryRequest, memoryLimit}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var names []string
+	for _, node := range nodeList.Items {
+		names = append(names, node.Name)
+	}
+	signals, errs := k.sampleNodes(ctx, names, interval)
+
+	report := &NoisyNeighborReport{Interval: interval.String()}
+	if len(errs) > 0 {
+		report.Errors = errs
+	}
+	for _, node := range nodeList.Items {
+		nodeSignals, ok := signals[node.Name]
+		if !ok {
+			continue
+		}
+		allocatableCPU := float64(node.Status.Allocatable.Cpu().MilliValue()) / 1000
+		allocatableMemory := node.Status.Allocatable.Memory().Value()
+
+		pressure := NodePressure{Node: node.Name}
+		var cpu, memory float64
+		for key, s := range nodeSignals {
+			if r, ok := resources[key]; ok {
+				s.CPURequest = float64(r[0]) / 1000
+				s.CPULimit = float64(r[1]) / 1000
+				s.MemoryRequest = r[2]
+				s.MemoryLimitless = r[3] == 0
+				nodeSignals[key] = s
+			}
+			cpu += s.CPUCores
+			memory += float64(s.MemoryBytes)
+			pressure.IOBytesPerSec += s.IOBytesPerSec
+			if s.ThrottledRatio >= noisyThrottledRatio {
+				pressure.Throttled = append(pressure.Throttled, key)
+			}
+		}
+		sort.Strings(pressure.Throttled)
+		if allocatableCPU > 0 {
+			pressure.CPUPercent = math.Round(cpu/allocatableCPU*1000) / 10
+		}
+		pressure.MemoryPercent = math.Round(percentOf(int64(memory), allocatableMemory)*10) / 10
+		for _, condition := range node.Status.Conditions {
+			switch condition.Type {
+			case corev1.NodeMemoryPressure, corev1.NodeDiskPressure, corev1.NodePIDPressure:
+				if condition.Status == corev1.ConditionTrue {
+					pressure.Conditions = append(pressure.Conditions, string(condition.Type))
+				}
+			}
+		}
+		pressure.Saturated = pressure.CPUPercent >= noisyCPUPercent || pressure.MemoryPercent >= noisyMemoryPercent ||
+			len(pressure.Conditions) > 0 || len(pressure.Throttled) >= noisyThrottledVictim
+		report.Nodes = append(report.Nodes, pressure)
+
+		if !pressure.Saturated {
+			continue
+		}
+		for key, s := range nodeSignals {
+			if (k.namespace != "" && s.Namespace != k.namespace) || !k.inScope(s.Namespace) {
+				continue
+			}
+			if suspect, ok := noisySuspect(s, pressure, allocatableCPU, allocatableMemory, key); ok {
+				report.Suspects = append(report.Suspects, suspect)
+			}
+		}
+	}
+
+	sort.Slice(report.Nodes, func(i, j int) bool { return report.Nodes[i].CPUPercent > report.Nodes[j].CPUPercent })
+	sort.Slice(report.Suspects, func(i, j int) bool { return report.Suspects[i].Score > report.Suspects[j].Score })
+	return report, nil
+}
+
+// noisySuspect scores a pod on a saturated node and collects the evidence.
+// Pods within their requests are not suspects.
+func noisySuspect(s PodSignals, node NodePressure, allocatableCPU float64, allocatableMemory int64, key string) (NoisySuspect, bool) {
+	suspect := NoisySuspect{PodSignals: s}
+	cpuExcess := math.Max(0, s.CPUCores-s.CPURequest)
+	memoryExcess := math.Max(0, float64(s.MemoryBytes-s.MemoryRequest))
+	ioShare := 0.0
+	if node.IOBytesPerSec > 0 {
+		ioShare = s.IOBytesPerSec / node.IOBytesPerSec
+	}
+
+	if cpuExcess > 0.05 && allocatableCPU > 0 {
+		suspect.Score += cpuExcess / allocatableCPU * 100
+		evidence := fmt.Sprintf("uses %.2f cores, %.2f above its %.2f request (%.0f%% of node)", s.CPUCores, cpuExcess, s.CPURequest, s.CPUCores/allocatableCPU*100)
+		if s.CPULimit == 0 {
+			evidence += ", no CPU limit"
+		}
+		suspect.Evidence = append(suspect.Evidence, evidence)
+	}
+	if memoryExcess > 0 && allocatableMemory > 0 && (node.MemoryPercent >= noisyMemoryPercent || len(node.Conditions) > 0) {
+		suspect.Score += memoryExcess / float64(allocatableMemory) * 100
+		evidence := fmt.Sprintf("working set %s, %s above its %s request", formatMemory(s.MemoryBytes), formatMemory(int64(memoryExcess)), formatMemory(s.MemoryRequest))
+		if s.MemoryLimitless {
+			evidence += ", no memory limit"
+		}
+		suspect.Evidence = append(suspect.Evidence, evidence)
+	}
+	if ioShare >= 0.3 && s.IOBytesPerSec >= 1<<20 {
+		suspect.Score += ioShare * 50
+		suspect.Evidence = append(suspect.Evidence, fmt.Sprintf("%s/s disk IO, %.0f%% of node", formatMemory(int64(s.IOBytesPerSec)), ioShare*100))
+	}
+	if suspect.Score == 0 {
+		return suspect, false
+	}
+
+	var victims []string
+	for _, throttled := range node.Throttled {
+		if throttled != key {
+			victims = append(victims, throttled)
+		}
+	}
+	situation := fmt.Sprintf("node %s at %.0f%% CPU and %.0f%% memory", node.Node, node.CPUPercent, node.MemoryPercent)
+	if len(node.Conditions) > 0 {
+		situation += ", " + strings.Join(node.Conditions, ", ")
+	}
+	if len(victims) > 0 {
+		suspect.Score *= 1 + float64(len(victims))/10
+		if len(victims) > 5 {
+			victims = append(victims[:5], fmt.Sprintf("%d more", len(victims)-5))
+		}
+		situation += fmt.Sprintf("; throttled neighbors: %s", strings.Join(victims, ", "))
+	}
+	suspect.Evidence = append(suspect.Evidence, situation)
+	suspect.Score = math.Round(suspect.Score*10) / 10
+	return suspect, true
+}
+
+// PrintNoisyNeighborReport prints the saturated nodes and the ranked suspects
+func (k *K8sToolkit) PrintNoisyNeighborReport(report *NoisyNeighborReport, limit int) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Printf("Node saturation over %s\n", report.Interval)
+	fmt.Printf("%-40s %6s %6s %12s %-22s %s\n", "NODE", "CPU", "MEM", "IO/s", "CONDITIONS", "THROTTLED PODS")
+	for _, n := range report.Nodes {
+		if !n.Saturated {
+			continue
+		}
+		conditions := strings.Join(n.Conditions, ",")
+		if conditions == "" {
+			conditions = "-"
+		}
+		fmt.Printf("%-40s %5.0f%% %5.0f%% %12s %-22s %d\n", n.Node, n.CPUPercent, n.MemoryPercent, formatMemory(int64(n.IOBytesPerSec)), conditions, len(n.Throttled))
+	}
+
+	if len(report.Suspects) == 0 {
+		fmt.Println("\nNo noisy neighbor suspects: no saturated node has pods beyond their requests")
+	} else {
+		fmt.Printf("\nSuspects\n")
+		for i, s := range report.Suspects {
+			if limit > 0 && i == limit {
+				fmt.Printf("  ... %d more\n", len(report.Suspects)-limit)
+				break
+			}
+			fmt.Printf("%2d. %s/%s on %s (score %.1f)\n", i+1, s.Namespace, s.Pod, s.Node, s.Score)
+			for _, evidence := range s.Evidence {
+				fmt.Printf("      - %s\n", evidence)
+			}
+		}
+	}
+
+	for node, err := range report.Errors {
+		fmt.Printf("\nWarning: could not sample node %s: %s\n", node, err)
+	}
+}
+
+// createNoisyNeighborsCmd creates the top noisy-neighbors command; --limit
+// is inherited from top
+func createNoisyNeighborsCmd() *cobra.Command {
+	var interval time.Duration
+
+	noisyCmd := &cobra.Command{
+		Use:     "noisy-neighbors",
+		Aliases: []string{"noisy"},
+		Short:   "Rank pods likely to be degrading their neighbors on saturated nodes",
+		Long: `Samples the kubelet's cAdvisor metrics of every node twice, --interval apart, through the API
+server's node proxy: container CPU usage and CFS throttling, memory working set and disk IO. Nodes
+above 80% CPU or 85% memory of allocatable, with a MemoryPressure, DiskPressure or PIDPressure
+condition, or with several throttled pods count as saturated. Pods on them are ranked by CPU and
+memory used beyond their requests and their share of node disk IO, with the evidence for each;
+throttled pods on the same node are listed as likely victims. --namespace limits the suspects,
+not the nodes. Needs get on nodes/proxy.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			report, err := toolkit.FindNoisyNeighbors(context.Background(), interval)
+			if err != nil {
+				logger.Fatalf("Noisy neighbor analysis failed: %v", err)
+			}
+			limit, _ := cmd.Flags().GetInt("limit")
+			toolkit.PrintNoisyNeighborReport(report, limit)
+		},
+	}
+	noisyCmd.Flags().DurationVar(&interval, "interval", 30*time.Second, "Time between the two metric samples")
+
+	return noisyCmd
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// pagerDutyEventsURL is the PagerDuty Events API v2 endpoint
+const pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// notificationTarget is an entry under notifications.targets in the config file
+type notificationTarget struct {
+	Name       string   `mapstructure:"name"`
+	Type       string   `mapstructure:"type"`
+	URL        string   `mapstructure:"url"`
+	RoutingKey string   `mapstructure:"routing_key"`
+	Checks     []string `mapstructure:"checks"`
+	MinStatus  string   `mapstructure:"min_status"`
+	Template   string   `mapstructure:"template"`
+
+	tmpl *template.Template
+}
+
+// alerts reports whether status is severe enough to alert the target
+func (t *notificationTarget) alerts(status string) bool {
+	return status != "Healthy" && statusValues[status] >= statusValues[t.MinStatus]
+}
+
+// routes reports whether transitions of check are sent to the target
+func (t *notificationTarget) routes(check string) bool {
+	if len(t.Checks) == 0 {
+		return true
+	}
+	for _, pattern := range t.Checks {
+		if matched, _ := path.Match(pattern, check); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Transition is a health check changing status, as passed to notification
+// templates and generic webhooks
+type Transition struct {
+	Cluster   string            `json:"cluster"`
+	Scope     string            `json:"scope,omitempty"`
+	Check     string            `json:"check"`
+	Component string            `json:"component"`
+	Previous  string            `json:"previous"`
+	Status    string            `json:"status"`
+	Resolved  bool              `json:"resolved"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	Time      time.Time         `json:"time"`
+}
+
+// Notifier sends alerts when health checks move into Warning or Critical
+// and resolve notices when they recover. Statuses are remembered per scope
+// and check, so only transitions are sent.
+type Notifier struct {
+	cluster string
+	targets []notificationTarget
+
+	mu   sync.Mutex
+	last map[string]string
+}
+
+// NewNotifier builds a notifier from notifications.targets. It returns nil
+// when no targets are configured or external calls are disabled.
+func NewNotifier() (*Notifier, error) {
+	var targets []notificationTarget
+	if err := viper.UnmarshalKey("notifications.targets", &targets); err != nil {
+		return nil, fmt.Errorf("invalid notifications.targets: %w", err)
+	}
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	if offline() {
+		logger.Warnf("offline mode, notifications are disabled")
+		return nil, nil
+	}
+
+	ctx := context.Background()
+	defaultTemplate, err := readTemplate("notification.txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	for i := range targets {
+		target := &targets[i]
+		if target.Name == "" {
+			target.Name = fmt.Sprintf("%s-%d", target.Type, i)
+		}
+		// Webhook URLs and routing keys are credentials and may be secret references
+		if target.URL, err = secretResolver.Resolve(ctx, target.URL); err != nil {
+			return nil, fmt.Errorf("notification target %s: %w", target.Name, err)
+		}
+		if target.RoutingKey, err = secretResolver.Resolve(ctx, target.RoutingKey); err != nil {
+			return nil, fmt.Errorf("notification target %s: %w", target.Name, err)
+		}
+		switch target.Type {
+		case "slack", "webhook":
+			if target.URL == "" {
+				return nil, fmt.Errorf("notification target %s has no url", target.Name)
+			}
+		case "pagerduty":
+			if target.RoutingKey == "" {
+				return nil, fmt.Errorf("notification target %s has no routing_key", target.Name)
+			}
+			if target.URL == "" {
+				target.URL = pagerDutyEventsURL
+			}
+		default:
+			return nil, fmt.Errorf("notification target %s has unknown type %q (use slack, pagerduty or webhook)", target.Name, target.Type)
+		}
+		if target.MinStatus == "" {
+			target.MinStatus = "Warning"
+		}
+		if _, ok := statusValues[target.MinStatus]; !ok {
+			return nil, fmt.Errorf("notification target %s has invalid min_status %q", target.Name, target.MinStatus)
+		}
+
+		text := target.Template
+		if text == "" {
+			text = defaultTemplate
+		}
+		if target.tmpl, err = template.New(target.Name).Funcs(templateFuncs).Parse(text); err != nil {
+			return nil, fmt.Errorf("failed to parse template of notification target %s: %w", target.Name, err)
+		}
+	}
+
+	cluster := viper.GetString("notifications.cluster")
+	if cluster == "" {
+		cluster = viper.GetString("context")
+	}
+	if cluster == "" {
+		cluster = "cluster"
+	}
+	return &Notifier{cluster: cluster, targets: targets, last: make(map[string]string)}, nil
+}
+
+// Observe records the latest result of a check and notifies the routed
+// targets when its status changed. A check seen for the first time is
+// compared against Healthy. Observe on a nil Notifier does nothing.
+func (n *Notifier) Observe(ctx context.Context, scope, check string, result HealthCheckResult) {
+	if n == nil {
+		return
+	}
+	key := scope + "/" + check
+
+	n.mu.Lock()
+	previous, seen := n.last[key]
+	n.last[key] = result.Status
+	n.mu.Unlock()
+
+	if !seen {
+		previous = "Healthy"
+	}
+	if previous == result.Status {
+		return
+	}
+	transition := Transition{
+		Cluster:   n.cluster,
+		Scope:     scope,
+		Check:     check,
+		Component: result.Component,
+		Previous:  previous,
+		Status:    result.Status,
+		Message:   result.Message,
+		Details:   result.Details,
+		Time:      time.Now(),
+	}
+
+	// A target alerts while the status is at or above its min_status and gets
+	// a resolve notice once it drops below
+	for i := range n.targets {
+		target := &n.targets[i]
+		if !target.routes(check) {
+			continue
+		}
+		wasAlerting := target.alerts(previous)
+		transition.Resolved = !target.alerts(result.Status)
+		if transition.Resolved && !wasAlerting {
+			continue
+		}
+		if err := n.send(ctx, target, transition); err != nil {
+			logger.Warnf("failed to notify %s about %s: %v", target.Name, check, err)
+		}
+	}
+}
+
+// send delivers one transition to a target
+func (n *Notifier) send(ctx context.Context, target *notificationTarget, transition Transition) error {
+	var text bytes.Buffer
+	if err := target.tmpl.Execute(&text, transition); err != nil {
+		return fmt.Errorf("failed to render message: %w", err)
+	}
+	message := strings.TrimSpace(text.String())
+
+	switch target.Type {
+	case "slack":
+		return postWebhook(ctx, target.URL, map[string]interface{}{"text": message})
+	case "pagerduty":
+		event := map[string]interface{}{
+			"routing_key":  target.RoutingKey,
+			"event_action": "trigger",
+			"dedup_key":    fmt.Sprintf("k8s-toolkit/%s/%s/%s", transition.Cluster, transition.Scope, transition.Check),
+		}
+		if transition.Resolved {
+			event["event_action"] = "resolve"
+		} else {
+			event["payload"] = map[string]interface{}{
+				"summary":        message,
+				"source":         transition.Cluster,
+				"severity":       strings.ToLower(transition.Status),
+				"component":      transition.Component,
+				"custom_details": transition.Details,
+			}
+		}
+		return postWebhook(ctx, target.URL, event)
+	default:
+		payload, err := json.Marshal(transition)
+		if err != nil {
+			return err
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(payload, &body); err != nil {
+			return err
+		}
+		body["text"] = message
+		return postWebhook(ctx, target.URL, body)
+	}
+}
+
+// notifyHealth passes every result of a health check run to the notifier
+func (n *Notifier) notifyHealth(ctx context.Context, scope string, health *ClusterHealth) {
+	for _, result := range health.Checks {
+		n.Observe(ctx, scope, result.Check, result)
+	}
+}
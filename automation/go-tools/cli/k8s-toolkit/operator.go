@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+//go:embed crds/clusterhealthcheck.yaml
+var clusterHealthCheckCRD []byte
+
+var (
+	clusterHealthCheckGVR = schema.GroupVersionResource{Group: "toolkit.devops.io", Version: "v1alpha1", Resource: "clusterhealthchecks"}
+	crdGVR                = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+)
+
+// Metrics exported in operator mode
+var (
+	checkStatusGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8s_toolkit_health_check_status",
+		Help: "Result of a health check: 0 Healthy, 1 Warning, 2 Critical.",
+	}, []string{"healthcheck", "check"})
+	checkDurationGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8s_toolkit_health_check_duration_seconds",
+		Help: "Duration of the last run of a health check.",
+	}, []string{"healthcheck", "check"})
+	checkLastRunGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8s_toolkit_health_check_last_run_timestamp_seconds",
+		Help: "Unix time of the last run of a ClusterHealthCheck.",
+	}, []string{"healthcheck"})
+	cacheStalenessGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8s_toolkit_cache_staleness_seconds",
+		Help: "Time the informer watch of a cached resource has been broken; 0 while it is healthy.",
+	}, []string{"resource"})
+)
+
+// statusValues maps health check statuses to metric values
+var statusValues = map[string]float64{"Healthy": 0, "Warning": 1, "Critical": 2}
+
+// OperatorOptions configures operator mode
+type OperatorOptions struct {
+	MetricsAddr    string
+	LeaseNamespace string
+	LeaseName      string
+	EventNamespace string
+	Resync         time.Duration
+	SkipCRDInstall bool
+
+	// APIAddr serves on-demand check runs when set
+	APIAddr    string
+	APITimeout time.Duration
+
+	// FleetAddr serves read-only commands to a fleet coordinator when set
+	FleetAddr string
+
+	// Notifier is told about every check result; nil disables notifications
+	Notifier *Notifier
+}
+
+// ensureCRD creates or updates the ClusterHealthCheck CRD
+func (k *K8sToolkit) ensureCRD(ctx context.Context) error {
+	objects, err := decodeManifests("crds/clusterhealthcheck.yaml", clusterHealthCheckCRD)
+	if err != nil || len(objects) != 1 {
+		return fmt.Errorf("invalid bundled CRD: %v", err)
+	}
+	crd := objects[0].obj
+
+	existing, err := k.dynamicClient.Resource(crdGVR).Get(ctx, crd.GetName(), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = k.dynamicClient.Resource(crdGVR).Create(ctx, crd, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	crd.SetResourceVersion(existing.GetResourceVersion())
+	_, err = k.dynamicClient.Resource(crdGVR).Update(ctx, crd, metav1.UpdateOptions{})
+	return err
+}
+
+// clusterHealthCheckSpec is the spec of a ClusterHealthCheck
+type clusterHealthCheckSpec struct {
+	interval  time.Duration
+	checks    map[string]bool
+	namespace string
+	suspend   bool
+}
+
+func parseClusterHealthCheckSpec(obj *unstructured.Unstructured) (clusterHealthCheckSpec, error) {
+	spec := clusterHealthCheckSpec{interval: 5 * time.Minute}
+	if value, found, _ := unstructured.NestedString(obj.Object, "spec", "interval"); found && value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval < 10*time.Second {
+			return spec, fmt.Errorf("invalid spec.interval %q: must be a duration of at least 10s", value)
+		}
+		spec.interval = interval
+	}
+	if checks, found, _ := unstructured.NestedStringSlice(obj.Object, "spec", "checks"); found && len(checks) > 0 {
+		spec.checks = make(map[string]bool, len(checks))
+		for _, name := range checks {
+			spec.checks[name] = true
+		}
+	}
+	spec.namespace, _, _ = unstructured.NestedString(obj.Object, "spec", "namespace")
+	spec.suspend, _, _ = unstructured.NestedBool(obj.Object, "spec", "suspend")
+	return spec, nil
+}
+
+// healthCheckDue reports whether a ClusterHealthCheck should run now: its spec changed
+// since the last run or its interval has passed
+func healthCheckDue(obj *unstructured.Unstructured, spec clusterHealthCheckSpec, now time.Time) bool {
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	if observed != obj.GetGeneration() {
+		return true
+	}
+	lastRun, _, _ := unstructured.NestedString(obj.Object, "status", "lastRunTime")
+	last, err := time.Parse(time.RFC3339, lastRun)
+	return err != nil || now.Sub(last) >= spec.interval
+}
+
+// runClusterHealthCheck runs the checks selected by a ClusterHealthCheck and
+// writes the results into its status. A status change emits an Event on the
+// resource.
+func (k *K8sToolkit) runClusterHealthCheck(ctx context.Context, obj *unstructured.Unstructured, spec clusterHealthCheckSpec, opts OperatorOptions) error {
+	scoped := *k
+	scoped.namespace = spec.namespace
+
+	overall := "Healthy"
+	var results []interface{}
+	for _, check := range scoped.healthChecks() {
+		if spec.checks != nil && !spec.checks[check.Name()] {
+			continue
+		}
+		result := scoped.runCheck(ctx, check)
+		opts.Notifier.Observe(ctx, obj.GetName(), check.Name(), result)
+		if statusValues[result.Status] > statusValues[overall] {
+			overall = result.Status
+		}
+		results = append(results, map[string]interface{}{
+			"name":       check.Name(),
+			"component":  result.Component,
+			"status":     result.Status,
+			"message":    result.Message,
+			"durationMs": result.Duration,
+		})
+		checkStatusGauge.WithLabelValues(obj.GetName(), check.Name()).Set(statusValues[result.Status])
+		checkDurationGauge.WithLabelValues(obj.GetName(), check.Name()).Set(float64(result.Duration) / 1000)
+	}
+	now := time.Now()
+	checkLastRunGauge.WithLabelValues(obj.GetName()).Set(float64(now.Unix()))
+
+	previous, _, _ := unstructured.NestedString(obj.Object, "status", "overallStatus")
+	status := map[string]interface{}{
+		"observedGeneration": obj.GetGeneration(),
+		"lastRunTime":        now.UTC().Format(time.RFC3339),
+		"overallStatus":      overall,
+		"results":            results,
+	}
+	if err := unstructured.SetNestedField(obj.Object, status, "status"); err != nil {
+		return err
+	}
+	if _, err := k.dynamicClient.Resource(clusterHealthCheckGVR).UpdateStatus(ctx, obj, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update status: %w", err)
+	}
+
+	if overall != previous {
+		eventType := corev1.EventTypeNormal
+		if overall != "Healthy" {
+			eventType = corev1.EventTypeWarning
+		}
+		message := fmt.Sprintf("Cluster health changed from %s to %s", orDash(previous), overall)
+		if err := k.emitEvent(ctx, obj, opts.EventNamespace, eventType, "HealthChanged", message); err != nil {
+			k.log().Warnf("failed to emit event for %s: %v", obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// emitEvent records an Event about a cluster-scoped object in namespace
+func (k *K8sToolkit) emitEvent(ctx context.Context, obj *unstructured.Unstructured, namespace, eventType, reason, message string) error {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: obj.GetName() + ".", Namespace: namespace},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Name:       obj.GetName(),
+			UID:        obj.GetUID(),
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        message,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Source:         corev1.EventSource{Component: "k8s-toolkit-operator"},
+	}
+	_, err := k.clientset.CoreV1().Events(namespace).Create(ctx, event, metav1.CreateOptions{})
+	return err
+}
+
+// reconcile runs every ClusterHealthCheck that is due
+func (k *K8sToolkit) reconcile(ctx context.Context, opts OperatorOptions) {
+	if k.cache != nil {
+		for _, resource := range k.cache.Status().Resources {
+			cacheStalenessGauge.WithLabelValues(resource.Resource).Set(resource.StalenessSeconds)
+		}
+	}
+
+	list, err := k.dynamicClient.Resource(clusterHealthCheckGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		k.log().Warnf("failed to list ClusterHealthChecks: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for i := range list.Items {
+		obj := &list.Items[i]
+		spec, err := parseClusterHealthCheckSpec(obj)
+		if err != nil {
+			if emitErr := k.emitEvent(ctx, obj, opts.EventNamespace, corev1.EventTypeWarning, "InvalidSpec", err.Error()); emitErr != nil {
+				k.log().Warnf("failed to emit event for %s: %v", obj.GetName(), emitErr)
+			}
+			continue
+		}
+		if spec.suspend || !healthCheckDue(obj, spec, now) {
+			continue
+		}
+		if err := k.runClusterHealthCheck(ctx, obj, spec, opts); err != nil {
+			k.log().Warnf("ClusterHealthCheck %s: %v", obj.GetName(), err)
+		}
+	}
+}
+
+// RunOperator installs the CRD, serves metrics and, while holding the
+// leader lease, runs every ClusterHealthCheck on its schedule until ctx is
+// cancelled. Standby replicas serve metrics but run no checks.
+func (k *K8sToolkit) RunOperator(ctx context.Context, opts OperatorOptions) error {
+	if readOnly() {
+		return fmt.Errorf("%w: operator mode writes status, events and leases", ErrReadOnly)
+	}
+	if !opts.SkipCRDInstall {
+		if err := k.ensureCRD(ctx); err != nil {
+			return fmt.Errorf("failed to install CRD: %w", err)
+		}
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(checkStatusGauge, checkDurationGauge, checkLastRunGauge, cacheStalenessGauge)
+	server := &http.Server{Addr: opts.MetricsAddr, Handler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{})}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			k.log().Warnf("metrics server failed: %v", err)
+		}
+	}()
+	defer server.Close()
+
+	// Every replica serves the API; on-demand runs need no lease
+	if opts.APIAddr != "" {
+		api, err := k.newAPIServer(ctx, opts.APITimeout)
+		if err != nil {
+			return err
+		}
+		apiHTTP := &http.Server{Addr: opts.APIAddr, Handler: api.handler()}
+		go func() {
+			if err := apiHTTP.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				k.log().Warnf("API server failed: %v", err)
+			}
+		}()
+		defer apiHTTP.Close()
+	}
+	if opts.FleetAddr != "" {
+		if err := k.serveFleet(ctx, opts.FleetAddr); err != nil {
+			return err
+		}
+	}
+
+	identity, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("failed to determine identity: %w", err)
+	}
+	lock, err := resourcelock.New(resourcelock.LeasesResourceLock, opts.LeaseNamespace, opts.LeaseName,
+		k.clientset.CoreV1(), k.clientset.CoordinationV1(), resourcelock.ResourceLockConfig{Identity: identity})
+	if err != nil {
+		return fmt.Errorf("failed to create lease lock: %w", err)
+	}
+
+	leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				k.log().Infof("Leading as %s, running ClusterHealthChecks", identity)
+				ticker := time.NewTicker(opts.Resync)
+				defer ticker.Stop()
+				for {
+					configMu.RLock()
+					k.reconcile(ctx, opts)
+					configMu.RUnlock()
+					select {
+					case <-ctx.Done():
+						return
+					case <-ticker.C:
+					}
+				}
+			},
+			OnStoppedLeading: func() {
+				k.log().Infof("%s lost the leader lease", identity)
+			},
+			OnNewLeader: func(leader string) {
+				if leader != identity {
+					k.log().Infof("Standing by, %s is the leader", leader)
+				}
+			},
+		},
+	})
+	return nil
+}
+
+// createOperatorCmd creates the operator command
+func createOperatorCmd() *cobra.Command {
+	var opts OperatorOptions
+
+	operatorCmd := &cobra.Command{
+		Use:   "operator",
+		Short: "Run health checks declared as ClusterHealthCheck resources",
+		Long: `Runs in the cluster as a controller. Installs the ClusterHealthCheck CRD (toolkit.devops.io/v1alpha1),
+runs the checks listed in each resource every spec.interval, writes the results into its status,
+emits an Event whenever the overall status changes and exports the results as Prometheus metrics
+on --metrics-addr. Check status changes are sent to notifications.targets like health --watch.
+Run several replicas for HA: a Lease elects the one that runs checks.
+
+With --use-cache, nodes, pods and PVs are read from informers instead of being listed on every
+run, and k8s_toolkit_cache_staleness_seconds reports broken watches.
+
+With --api-addr, every replica also serves POST /run-check/{name} and POST /run-all for on-demand
+runs, authenticated with "Authorization: Bearer <token>" (operator.api_token or
+K8S_TOOLKIT_API_TOKEN). Both accept ?namespace= and ?timeout= and return the JSON result.
+With --fleet-addr it serves health, scan and images to a fleet coordinator over mutual TLS.
+
+  apiVersion: toolkit.devops.io/v1alpha1
+  kind: ClusterHealthCheck
+  metadata:
+    name: core
+  spec:
+    interval: 5m
+    checks: [nodes, node-conditions, system-pods, pvs]`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			if opts.Notifier, err = NewNotifier(); err != nil {
+				logger.Fatalf("Failed to configure notifications: %v", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if viper.GetBool("use_cache") {
+				if err := toolkit.enableCache(ctx); err != nil {
+					logger.Fatalf("Failed to start cache: %v", err)
+				}
+			}
+
+			watchConfigRepo(ctx)
+			if err := toolkit.RunOperator(ctx, opts); err != nil {
+				logger.Fatalf("Operator failed: %v", err)
+			}
+		},
+	}
+
+	operatorCmd.Flags().StringVar(&opts.MetricsAddr, "metrics-addr", ":8080", "Address to serve Prometheus metrics on")
+	operatorCmd.Flags().StringVar(&opts.LeaseNamespace, "lease-namespace", "default", "Namespace of the leader election Lease")
+	operatorCmd.Flags().StringVar(&opts.LeaseName, "lease-name", "k8s-toolkit-operator", "Name of the leader election Lease")
+	operatorCmd.Flags().StringVar(&opts.EventNamespace, "event-namespace", "default", "Namespace Events about ClusterHealthChecks are recorded in")
+	operatorCmd.Flags().DurationVar(&opts.Resync, "resync", 15*time.Second, "How often to look for ClusterHealthChecks that are due")
+	operatorCmd.Flags().StringVar(&opts.APIAddr, "api-addr", "", "Address to serve the on-demand check API on (disabled when empty)")
+	operatorCmd.Flags().DurationVar(&opts.APITimeout, "api-timeout", 60*time.Second, "Longest an on-demand check run may take")
+	operatorCmd.Flags().StringVar(&opts.FleetAddr, "fleet-addr", "", "Address to serve fleet commands on over mutual TLS (disabled when empty)")
+	operatorCmd.Flags().BoolVar(&opts.SkipCRDInstall, "skip-crd-install", false, "Do not create or update the CRD, e.g. when it is managed by GitOps")
+
+	return operatorCmd
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const hoursPerMonth = 730
+
+// OptimizeOptions controls how recommendations are computed
+type OptimizeOptions struct {
+	Samples       int
+	Interval      time.Duration
+	Headroom      float64
+	LimitRatio    float64
+	CPUCostHour   float64
+	MemoryCostGiB float64
+}
+
+// ContainerRecommendation holds current and recommended resources for one container of a workload
+type ContainerRecommendation struct {
+	Namespace            string  `json:"namespace"`
+	Kind                 string  `json:"kind"`
+	Workload             string  `json:"workload"`
+	Container            string  `json:"container"`
+	Replicas             int     `json:"replicas"`
+	PeakCPU              int64   `json:"peak_cpu_millicores"`
+	PeakMemory           int64   `json:"peak_memory_bytes"`
+	CurrentCPURequest    int64   `json:"current_cpu_request_millicores"`
+	CurrentCPULimit      int64   `json:"current_cpu_limit_millicores"`
+	CurrentMemoryRequest int64   `json:"current_memory_request_bytes"`
+	CurrentMemoryLimit   int64   `json:"current_memory_limit_bytes"`
+	CPURequest           int64   `json:"recommended_cpu_request_millicores"`
+	CPULimit             int64   `json:"recommended_cpu_limit_millicores"`
+	MemoryRequest        int64   `json:"recommended_memory_request_bytes"`
+	MemoryLimit          int64   `json:"recommended_memory_limit_bytes"`
+	MonthlySavings       float64 `json:"estimated_monthly_savings"`
+}
+
+// Optimize samples pod metrics and recommends requests and limits per workload container
+func (k *K8sToolkit) Optimize(ctx context.Context, opts OptimizeOptions) ([]ContainerRecommendation, error) {
+	if k.metricsClientset == nil {
+		return nil, fmt.Errorf("metrics server not available")
+	}
+
+	// Peak usage per namespace/pod/container across all samples
+	peakCPU := make(map[string]int64)
+	peakMemory := make(map[string]int64)
+
+	for sample := 0; sample < opts.Samples; sample++ {
+		if sample > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(opts.Interval):
+			}
+		}
+
+		podMetrics, err := k.metricsClientset.MetricsV1beta1().PodMetricses(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pod metrics: %w", err)
+		}
+		for _, metric := range inScopeItems(k, podMetrics.Items) {
+			for _, container := range metric.Containers {
+				key := metric.Namespace + "/" + metric.Name + "/" + container.Name
+				if cpu := container.Usage.Cpu().MilliValue(); cpu > peakCPU[key] {
+					peakCPU[key] = cpu
+				}
+				if memory := container.Usage.Memory().Value(); memory > peakMemory[key] {
+					peakMemory[key] = memory
+				}
+			}
+		}
+	}
+
+	pods, err := k.clientset.CoreV1().Pods(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	rsOwners, err := k.replicaSetOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendations := make(map[string]*ContainerRecommendation)
+	for _, pod := range inScopeItems(k, pods.Items) {
+		if metav1.GetControllerOf(&pod) == nil {
+			continue
+		}
+		workload := podWorkload(&pod, rsOwners)
+
+		for _, container := range pod.Spec.Containers {
+			key := fmt.Sprintf("%s/%s/%s/%s", workload.namespace, workload.kind, workload.name, container.Name)
+			rec, ok := recommendations[key]
+			if !ok {
+				rec = &ContainerRecommendation{
+					Namespace:            workload.namespace,
+					Kind:                 workload.kind,
+					Workload:             workload.name,
+					Container:            container.Name,
+					CurrentCPURequest:    container.Resources.Requests.Cpu().MilliValue(),
+					CurrentCPULimit:      container.Resources.Limits.Cpu().MilliValue(),
+					CurrentMemoryRequest: container.Resources.Requests.Memory().Value(),
+					CurrentMemoryLimit:   container.Resources.Limits.Memory().Value(),
+				}
+				recommendations[key] = rec
+			}
+			rec.Replicas++
+
+			usageKey := pod.Namespace + "/" + pod.Name + "/" + container.Name
+			if peakCPU[usageKey] > rec.PeakCPU {
+				rec.PeakCPU = peakCPU[usageKey]
+			}
+			if peakMemory[usageKey] > rec.PeakMemory {
+				rec.PeakMemory = peakMemory[usageKey]
+			}
+		}
+	}
+
+	var results []ContainerRecommendation
+	for _, rec := range recommendations {
+		recommendResources(rec, opts)
+		results = append(results, *rec)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].MonthlySavings > results[j].MonthlySavings
+	})
+
+	return results, nil
+}
+
+// recommendResources fills in recommended values and estimated savings from peak usage
+func recommendResources(rec *ContainerRecommendation, opts OptimizeOptions) {
+	const mi = 1024 * 1024
+
+	cpu := int64(math.Ceil(float64(rec.PeakCPU)*(1+opts.Headroom)/5)) * 5
+	if cpu < 10 {
+		cpu = 10
+	}
+	memory := int64(math.Ceil(float64(rec.PeakMemory)*(1+opts.Headroom)/mi)) * mi
+	if memory < 32*mi {
+		memory = 32 * mi
+	}
+
+	rec.CPURequest = cpu
+	rec.MemoryRequest = memory
+
+	// Only recommend limits where the workload already sets them
+	if rec.CurrentCPULimit > 0 {
+		rec.CPULimit = int64(math.Ceil(float64(cpu)*opts.LimitRatio/5)) * 5
+	}
+	if rec.CurrentMemoryLimit > 0 {
+		rec.MemoryLimit = int64(math.Ceil(float64(memory)*opts.LimitRatio/mi)) * mi
+	}
+
+	cpuSaved := float64(rec.CurrentCPURequest-rec.CPURequest) / 1000
+	memorySaved := float64(rec.CurrentMemoryRequest-rec.MemoryRequest) / (1024 * mi)
+	rec.MonthlySavings = (cpuSaved*opts.CPUCostHour + memorySaved*opts.MemoryCostGiB) * hoursPerMonth * float64(rec.Replicas)
+}
+
+// PrintRecommendations prints recommendations as text, JSON or patch YAML
+func (k *K8sToolkit) PrintRecommendations(recs []ContainerRecommendation) error {
+	if k.filtered(recs) {
+		return nil
+	}
+	switch k.output {
+	case "json":
+		printJSON(recs)
+		return nil
+	case "patch":
+		return writeRecommendationPatches(recs)
+	}
+
+	fmt.Printf("%-20s %-35s %-20s %17s %17s %10s\n", "NAMESPACE", "WORKLOAD", "CONTAINER", "CPU REQ", "MEMORY REQ", "SAVINGS/MO")
+	total := 0.0
+	for _, rec := range recs {
+		fmt.Printf("%-20s %-35s %-20s %7dm -> %5dm %7s -> %6s %10.2f\n",
+			rec.Namespace, rec.Kind+"/"+rec.Workload, rec.Container,
+			rec.CurrentCPURequest, rec.CPURequest,
+			formatMemory(rec.CurrentMemoryRequest), formatMemory(rec.MemoryRequest),
+			rec.MonthlySavings)
+		total += rec.MonthlySavings
+	}
+	fmt.Printf("\nEstimated total monthly savings: %.2f\n", total)
+	return nil
+}
+
+// writeRecommendationPatches writes one strategic merge patch per workload,
+// suitable for `kubectl patch --patch-file`
+func writeRecommendationPatches(recs []ContainerRecommendation) error {
+	type containerPatch struct {
+		Name      string `yaml:"name"`
+		Resources struct {
+			Requests map[string]string `yaml:"requests"`
+			Limits   map[string]string `yaml:"limits,omitempty"`
+		} `yaml:"resources"`
+	}
+
+	byWorkload := make(map[workloadKey][]containerPatch)
+	var order []workloadKey
+	for _, rec := range recs {
+		key := workloadKey{namespace: rec.Namespace, kind: rec.Kind, name: rec.Workload}
+		if _, ok := byWorkload[key]; !ok {
+			order = append(order, key)
+		}
+
+		patch := containerPatch{Name: rec.Container}
+		patch.Resources.Requests = map[string]string{
+			"cpu":    resource.NewMilliQuantity(rec.CPURequest, resource.DecimalSI).String(),
+			"memory": resource.NewQuantity(rec.MemoryRequest, resource.BinarySI).String(),
+		}
+		if rec.CPULimit > 0 || rec.MemoryLimit > 0 {
+			patch.Resources.Limits = make(map[string]string)
+			if rec.CPULimit > 0 {
+				patch.Resources.Limits["cpu"] = resource.NewMilliQuantity(rec.CPULimit, resource.DecimalSI).String()
+			}
+			if rec.MemoryLimit > 0 {
+				patch.Resources.Limits["memory"] = resource.NewQuantity(rec.MemoryLimit, resource.BinarySI).String()
+			}
+		}
+		byWorkload[key] = append(byWorkload[key], patch)
+	}
+
+	for i, key := range order {
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Printf("# kubectl -n %s patch %s %s --patch-file <this document>\n", key.namespace, key.kind, key.name)
+
+		doc := map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": byWorkload[key],
+					},
+				},
+			},
+		}
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(doc); err != nil {
+			return fmt.Errorf("failed to encode patch for %s/%s: %w", key.kind, key.name, err)
+		}
+		encoder.Close()
+	}
+	return nil
+}
+
+// createOptimizeCmd creates the optimize command
+func createOptimizeCmd() *cobra.Command {
+	var opts OptimizeOptions
+
+	optimizeCmd := &cobra.Command{
+		Use:   "optimize",
+		Short: "Recommend right-sized resource requests and limits",
+		Long: `Compares container requests and limits against live metrics, optionally sampled over a window,
+and recommends new values per workload with estimated savings. Use -o patch for ready-to-apply patches.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if opts.Samples < 1 {
+				logger.Fatalf("--samples must be at least 1")
+			}
+
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			recs, err := toolkit.Optimize(context.Background(), opts)
+			if err != nil {
+				logger.Fatalf("Failed to compute recommendations: %v", err)
+			}
+
+			if err := toolkit.PrintRecommendations(recs); err != nil {
+				logger.Fatalf("Failed to print recommendations: %v", err)
+			}
+		},
+	}
+
+	optimizeCmd.Flags().IntVar(&opts.Samples, "samples", 1, "Number of metric samples to collect")
+	optimizeCmd.Flags().DurationVar(&opts.Interval, "interval", 30*time.Second, "Time between metric samples")
+	optimizeCmd.Flags().Float64Var(&opts.Headroom, "headroom", 0.2, "Fraction added on top of peak usage for requests")
+	optimizeCmd.Flags().Float64Var(&opts.LimitRatio, "limit-ratio", 1.5, "Recommended limit as a multiple of the recommended request")
+	optimizeCmd.Flags().Float64Var(&opts.CPUCostHour, "cpu-cost", 0.031, "Cost per CPU core hour")
+	optimizeCmd.Flags().Float64Var(&opts.MemoryCostGiB, "memory-cost", 0.004, "Cost per GiB hour of memory")
+
+	return optimizeCmd
+}
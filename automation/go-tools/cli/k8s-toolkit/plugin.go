@@ -0,0 +1,932 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// Plugin kinds
+const (
+	// pluginKindCheck is run as a health check with the argument "check"
+	pluginKindCheck = "check"
+	// pluginKindCommand is run with plugin run and receives the remaining arguments
+	pluginKindCommand = "command"
+)
+
+// pluginIndexFile is the marketplace manifest at the root of a plugin index
+const pluginIndexFile = "index.yaml"
+
+// Plugin names and versions become directory names under the plugin
+// directory, so the index may only use these forms. Versions are strict
+// semantic versions with an optional leading v.
+var (
+	pluginNamePattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	pluginVersionPattern = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+)
+
+// pluginArtifact is a plugin binary for one platform. URL is an http(s) URL,
+// an oci:// reference pulled with oras, or a path relative to the index.
+// Signature is a detached cosign or minisign signature of the artifact's
+// pluginStatement, which binds the binary's checksum to the plugin name,
+// version and platform it is published as.
+type pluginArtifact struct {
+	URL       string `yaml:"url" json:"url"`
+	SHA256    string `yaml:"sha256" json:"sha256"`
+	Signature string `yaml:"signature" json:"signature,omitempty"`
+}
+
+// pluginRelease is one version of a plugin, keyed by GOOS/GOARCH
+type pluginRelease struct {
+	Version   string                    `yaml:"version" json:"version"`
+	Timeout   time.Duration             `yaml:"timeout" json:"timeout,omitempty"`
+	Platforms map[string]pluginArtifact `yaml:"platforms" json:"platforms"`
+}
+
+// pluginManifest describes a published plugin and its releases
+type pluginManifest struct {
+	Name        string          `yaml:"name" json:"name"`
+	Description string          `yaml:"description" json:"description,omitempty"`
+	Kind        string          `yaml:"kind" json:"kind"`
+	Component   string          `yaml:"component" json:"component,omitempty"`
+	Owner       string          `yaml:"owner" json:"owner,omitempty"`
+	Versions    []pluginRelease `yaml:"versions" json:"versions"`
+}
+
+// pluginIndex is the list of plugins published to an index
+type pluginIndex struct {
+	Plugins []pluginManifest `yaml:"plugins" json:"plugins"`
+
+	// base resolves relative artifact URLs: a directory, or an http(s) URL
+	base string
+}
+
+// installedPlugin is an entry of the installed plugin state
+type installedPlugin struct {
+	Name        string        `yaml:"name" json:"name"`
+	Version     string        `yaml:"version" json:"version"`
+	Kind        string        `yaml:"kind" json:"kind"`
+	Component   string        `yaml:"component,omitempty" json:"component,omitempty"`
+	Timeout     time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	SHA256      string        `yaml:"sha256" json:"sha256"`
+	Signed      bool          `yaml:"signed" json:"signed"`
+	Index       string        `yaml:"index" json:"index"`
+	Path        string        `yaml:"path" json:"path"`
+	InstalledAt time.Time     `yaml:"installed_at" json:"installed_at"`
+}
+
+// pluginState is installed.yaml in the plugin directory
+type pluginState struct {
+	Plugins map[string]installedPlugin `yaml:"plugins"`
+}
+
+// pluginDir returns the directory plugins are installed into
+func pluginDir() (string, error) {
+	if dir := viper.GetString("plugins.dir"); dir != "" {
+		return dir, nil
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "k8s-toolkit", "plugins"), nil
+}
+
+// loadPluginState reads the installed plugins; a missing file means none
+func loadPluginState() (*pluginState, error) {
+	state := &pluginState{Plugins: make(map[string]installedPlugin)}
+	dir, err := pluginDir()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "installed.yaml"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read installed plugins: %w", err)
+	}
+	if err := yaml.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse installed plugins: %w", err)
+	}
+	if state.Plugins == nil {
+		state.Plugins = make(map[string]installedPlugin)
+	}
+	return state, nil
+}
+
+// save writes the state atomically
+func (s *pluginState) save() error {
+	dir, err := pluginDir()
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dir, ".installed.yaml.tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write installed plugins: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(dir, "installed.yaml"))
+}
+
+// sortedPlugins returns the installed plugins by name
+func (s *pluginState) sortedPlugins() []installedPlugin {
+	plugins := make([]installedPlugin, 0, len(s.Plugins))
+	for _, p := range s.Plugins {
+		plugins = append(plugins, p)
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// isHTTP reports whether location is an http(s) URL
+func isHTTP(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// isGitIndex reports whether location is a git repository: git+https://,
+// ssh://, git@host: or a URL ending in .git, optionally followed by #ref
+func isGitIndex(location string) bool {
+	location, _, _ = strings.Cut(location, "#")
+	return strings.HasPrefix(location, "git+") || strings.HasPrefix(location, "ssh://") ||
+		strings.HasPrefix(location, "git@") || strings.HasSuffix(location, ".git")
+}
+
+// httpGet returns the body of a successful GET
+func httpGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 2 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// orasPull pulls an OCI artifact into dir with the oras binary, which uses
+// the registry credentials of docker login
+func orasPull(ctx context.Context, ref, dir string) error {
+	cmd := exec.CommandContext(ctx, "oras", "pull", "--output", dir, strings.TrimPrefix(ref, "oci://"))
+	cmd.Stdout = io.Discard
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("oras pull %s failed: %w", ref, err)
+	}
+	return nil
+}
+
+// fetchPluginIndex fetches the index at location into workDir when it is a
+// git repository or OCI artifact and parses its manifest
+func fetchPluginIndex(ctx context.Context, location, workDir string) (*pluginIndex, error) {
+	if offline() && (isHTTP(location) || isGitIndex(location) || strings.HasPrefix(location, "oci://")) {
+		return nil, fmt.Errorf("plugin index %s is remote and offline mode is set", location)
+	}
+
+	var data []byte
+	var base string
+	var err error
+	switch {
+	case isGitIndex(location):
+		url, ref, _ := strings.Cut(strings.TrimPrefix(location, "git+"), "#")
+		options := &git.CloneOptions{URL: url, Depth: 1, SingleBranch: true}
+		if ref != "" {
+			options.ReferenceName = plumbing.NewBranchReferenceName(ref)
+		}
+		if err := os.RemoveAll(workDir); err != nil {
+			return nil, err
+		}
+		if _, err := git.PlainCloneContext(ctx, workDir, false, options); err != nil {
+			return nil, fmt.Errorf("failed to clone plugin index %s: %w", url, err)
+		}
+		base = workDir
+		data, err = os.ReadFile(filepath.Join(workDir, pluginIndexFile))
+	case strings.HasPrefix(location, "oci://"):
+		if err := os.RemoveAll(workDir); err != nil {
+			return nil, err
+		}
+		if err := orasPull(ctx, location, workDir); err != nil {
+			return nil, err
+		}
+		base = workDir
+		data, err = os.ReadFile(filepath.Join(workDir, pluginIndexFile))
+	case isHTTP(location):
+		if !strings.HasSuffix(location, ".yaml") {
+			location = strings.TrimSuffix(location, "/") + "/" + pluginIndexFile
+		}
+		base = location[:strings.LastIndex(location, "/")]
+		data, err = httpGet(ctx, location)
+	default:
+		if info, statErr := os.Stat(location); statErr == nil && info.IsDir() {
+			location = filepath.Join(location, pluginIndexFile)
+		}
+		base = filepath.Dir(location)
+		data, err = os.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin index: %w", err)
+	}
+
+	index := &pluginIndex{base: base}
+	if err := yaml.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to parse plugin index: %w", err)
+	}
+	for i, p := range index.Plugins {
+		if !pluginNamePattern.MatchString(p.Name) {
+			return nil, fmt.Errorf("plugin index entry %d has an invalid name %q", i, p.Name)
+		}
+		if p.Kind != pluginKindCheck && p.Kind != pluginKindCommand {
+			return nil, fmt.Errorf("plugin %s: unknown kind %q (check|command)", p.Name, p.Kind)
+		}
+		for _, release := range p.Versions {
+			if !pluginVersionPattern.MatchString(release.Version) {
+				return nil, fmt.Errorf("plugin %s: version %q is not a semantic version", p.Name, release.Version)
+			}
+		}
+	}
+	return index, nil
+}
+
+// loadPluginIndex fetches the index configured at plugins.index
+func loadPluginIndex(ctx context.Context) (*pluginIndex, error) {
+	location := viper.GetString("plugins.index")
+	if location == "" {
+		return nil, errors.New("no plugin index configured; set plugins.index")
+	}
+	dir, err := pluginDir()
+	if err != nil {
+		return nil, err
+	}
+	return fetchPluginIndex(ctx, location, filepath.Join(dir, ".index"))
+}
+
+// find returns the manifest of the named plugin
+func (idx *pluginIndex) find(name string) (*pluginManifest, error) {
+	for i := range idx.Plugins {
+		if idx.Plugins[i].Name == name {
+			return &idx.Plugins[i], nil
+		}
+	}
+	return nil, fmt.Errorf("plugin %s not found in the index", name)
+}
+
+// fetchArtifact downloads a plugin binary relative to the index
+func (idx *pluginIndex) fetchArtifact(ctx context.Context, url string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(url, "oci://"):
+		dir, err := os.MkdirTemp("", "k8s-toolkit-plugin-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		if err := orasPull(ctx, url, dir); err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) != 1 || entries[0].IsDir() {
+			return nil, fmt.Errorf("artifact %s must contain exactly one file", url)
+		}
+		return os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	case isHTTP(url):
+		return httpGet(ctx, url)
+	case isHTTP(idx.base):
+		return httpGet(ctx, idx.base+"/"+path.Clean(url))
+	case filepath.IsAbs(url):
+		return os.ReadFile(url)
+	default:
+		return os.ReadFile(filepath.Join(idx.base, url))
+	}
+}
+
+// compareVersions orders dotted versions numerically, ignoring a leading v
+// and any pre-release suffix; non-numeric parts compare as strings
+func compareVersions(a, b string) int {
+	split := func(v string) []string {
+		v = strings.TrimPrefix(v, "v")
+		v, _, _ = strings.Cut(v, "-")
+		return strings.Split(v, ".")
+	}
+	pa, pb := split(a), split(b)
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y string
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		nx, errX := strconv.Atoi(x)
+		ny, errY := strconv.Atoi(y)
+		switch {
+		case errX == nil && errY == nil && nx != ny:
+			if nx < ny {
+				return -1
+			}
+			return 1
+		case (errX != nil || errY != nil) && x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+// release returns the requested version of the plugin, or the latest
+func (m *pluginManifest) release(version string) (*pluginRelease, error) {
+	var found *pluginRelease
+	for i := range m.Versions {
+		r := &m.Versions[i]
+		if version != "" {
+			if strings.TrimPrefix(r.Version, "v") == strings.TrimPrefix(version, "v") {
+				return r, nil
+			}
+			continue
+		}
+		if found == nil || compareVersions(r.Version, found.Version) > 0 {
+			found = r
+		}
+	}
+	if found == nil {
+		if version != "" {
+			return nil, fmt.Errorf("plugin %s has no version %s", m.Name, version)
+		}
+		return nil, fmt.Errorf("plugin %s has no releases", m.Name)
+	}
+	return found, nil
+}
+
+// pluginStatement is the payload a plugin artifact's signature covers. Signing
+// it rather than the binary keeps a signed binary from being republished in
+// the index under another plugin's name or version. Publishers sign the
+// output of:
+//
+//	printf 'k8s-toolkit-plugin name=%s version=%s platform=%s sha256=%s\n' ...
+func pluginStatement(name, version, platform, sha256 string) []byte {
+	return []byte(fmt.Sprintf("k8s-toolkit-plugin name=%s version=%s platform=%s sha256=%s\n", name, version, platform, strings.ToLower(sha256)))
+}
+
+// verifyPlugin checks the checksum of a downloaded binary and the signature
+// of its pluginStatement. A signature is required unless
+// plugins.allow_unsigned is set.
+func verifyPlugin(ctx context.Context, name, version, platform string, artifact pluginArtifact, data []byte) (bool, error) {
+	if artifact.SHA256 == "" {
+		return false, fmt.Errorf("plugin %s has no sha256 in the index", name)
+	}
+	if sum := checksum(data); !strings.EqualFold(sum, artifact.SHA256) {
+		return false, fmt.Errorf("plugin %s checksum mismatch: index has %s, download is %s", name, artifact.SHA256, sum)
+	}
+
+	if artifact.Signature == "" {
+		if !viper.GetBool("plugins.allow_unsigned") {
+			return false, fmt.Errorf("plugin %s is not signed; set plugins.allow_unsigned to install it anyway", name)
+		}
+		return false, nil
+	}
+	key := viper.GetString("plugins.public_key")
+	if key == "" {
+		return false, fmt.Errorf("plugin %s is signed but no plugins.public_key is configured to verify it", name)
+	}
+	statement := pluginStatement(name, version, platform, artifact.SHA256)
+	if _, err := runSigner(ctx, signingScheme(key), key, statement, artifact.Signature, true); err != nil {
+		return false, fmt.Errorf("plugin %s signature verification failed: %w", name, err)
+	}
+	return true, nil
+}
+
+// InstallPlugin downloads, verifies and installs a plugin version for the
+// current platform, replacing any other installed version
+func InstallPlugin(ctx context.Context, index *pluginIndex, name, version string) (*installedPlugin, error) {
+	manifest, err := index.find(name)
+	if err != nil {
+		return nil, err
+	}
+	release, err := manifest.release(version)
+	if err != nil {
+		return nil, err
+	}
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	artifact, ok := release.Platforms[platform]
+	if !ok {
+		return nil, fmt.Errorf("plugin %s %s has no build for %s", name, release.Version, platform)
+	}
+
+	data, err := index.fetchArtifact(ctx, artifact.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download plugin %s: %w", name, err)
+	}
+	signed, err := verifyPlugin(ctx, name, release.Version, platform, artifact, data)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := pluginDir()
+	if err != nil {
+		return nil, err
+	}
+	versionDir := filepath.Join(dir, name, release.Version)
+	if rel, err := filepath.Rel(dir, versionDir); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("plugin %s %s would install outside %s", name, release.Version, dir)
+	}
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", versionDir, err)
+	}
+	binary := filepath.Join(versionDir, name)
+	tmp := binary + ".tmp"
+	if err := os.WriteFile(tmp, data, 0755); err != nil {
+		return nil, fmt.Errorf("failed to write plugin %s: %w", name, err)
+	}
+	if err := os.Rename(tmp, binary); err != nil {
+		return nil, fmt.Errorf("failed to install plugin %s: %w", name, err)
+	}
+
+	state, err := loadPluginState()
+	if err != nil {
+		return nil, err
+	}
+	plugin := installedPlugin{
+		Name:        name,
+		Version:     release.Version,
+		Kind:        manifest.Kind,
+		Component:   manifest.Component,
+		Timeout:     release.Timeout,
+		SHA256:      strings.ToLower(artifact.SHA256),
+		Signed:      signed,
+		Index:       viper.GetString("plugins.index"),
+		Path:        binary,
+		InstalledAt: time.Now().UTC(),
+	}
+	state.Plugins[name] = plugin
+	if err := state.save(); err != nil {
+		return nil, err
+	}
+
+	// Older versions are removed once the new one is active
+	entries, _ := os.ReadDir(filepath.Join(dir, name))
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() != release.Version {
+			os.RemoveAll(filepath.Join(dir, name, entry.Name()))
+		}
+	}
+	return &plugin, nil
+}
+
+// RemovePlugin uninstalls a plugin
+func RemovePlugin(name string) error {
+	state, err := loadPluginState()
+	if err != nil {
+		return err
+	}
+	if _, ok := state.Plugins[name]; !ok {
+		return fmt.Errorf("plugin %s is not installed", name)
+	}
+	delete(state.Plugins, name)
+	if err := state.save(); err != nil {
+		return err
+	}
+	dir, err := pluginDir()
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(dir, name))
+}
+
+// toolkitEnv is the toolkit context passed to checks.exec commands that are
+// not isolated
+func toolkitEnv(namespace string) []string {
+	env := []string{
+		"K8S_TOOLKIT_NAMESPACE=" + namespace,
+		"K8S_TOOLKIT_CONTEXT=" + viper.GetString("context"),
+		"K8S_TOOLKIT_OUTPUT=" + viper.GetString("output"),
+	}
+	if kubeconfig := viper.GetString("kubeconfig"); kubeconfig != "" {
+		env = append(env, "KUBECONFIG="+kubeconfig)
+	}
+	return env
+}
+
+// pluginContext names the only context in the kubeconfig of an isolated plugin
+const pluginContext = "k8s-toolkit-plugin"
+
+// pluginKubeconfig writes the kubeconfig of an isolated plugin to dir and
+// returns its path. It never holds the user's credentials: with
+// plugins.service_account set to namespace/name it carries a token for that
+// service account that expires after ttl (at least ten minutes, the API
+// server's minimum), otherwise it has no credentials at all.
+func (k *K8sToolkit) pluginKubeconfig(ctx context.Context, dir, namespace string, ttl time.Duration) (string, error) {
+	config := clientcmdapi.NewConfig()
+	cluster := clientcmdapi.NewCluster()
+	cluster.Server = k.restConfig.Host
+	cluster.CertificateAuthorityData = k.restConfig.CAData
+	if len(cluster.CertificateAuthorityData) == 0 && k.restConfig.CAFile != "" {
+		ca, err := os.ReadFile(k.restConfig.CAFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read cluster CA: %w", err)
+		}
+		cluster.CertificateAuthorityData = ca
+	}
+	cluster.InsecureSkipTLSVerify = k.restConfig.Insecure
+	config.Clusters[pluginContext] = cluster
+
+	user := clientcmdapi.NewAuthInfo()
+	if account := viper.GetString("plugins.service_account"); account != "" {
+		saNamespace, saName, ok := strings.Cut(account, "/")
+		if !ok {
+			return "", fmt.Errorf("plugins.service_account must be namespace/name, got %q", account)
+		}
+		if ttl < 10*time.Minute {
+			ttl = 10 * time.Minute
+		}
+		seconds := int64(ttl.Seconds())
+		token, err := k.clientset.CoreV1().ServiceAccounts(saNamespace).CreateToken(ctx, saName,
+			&authenticationv1.TokenRequest{Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &seconds}},
+			metav1.CreateOptions{})
+		if err != nil {
+			return "", fmt.Errorf("failed to request a token for %s: %w", account, err)
+		}
+		user.Token = token.Status.Token
+	}
+	config.AuthInfos[pluginContext] = user
+
+	kubeContext := clientcmdapi.NewContext()
+	kubeContext.Cluster = pluginContext
+	kubeContext.AuthInfo = pluginContext
+	kubeContext.Namespace = namespace
+	config.Contexts[pluginContext] = kubeContext
+	config.CurrentContext = pluginContext
+
+	path := filepath.Join(dir, "kubeconfig")
+	if err := clientcmd.WriteToFile(*config, path); err != nil {
+		return "", fmt.Errorf("failed to write plugin kubeconfig: %w", err)
+	}
+	return path, nil
+}
+
+// pluginEnv is the whole environment of an isolated plugin running in dir:
+// the toolkit context, the kubeconfig from pluginKubeconfig, the variables a
+// process needs to run, and those listed in plugins.pass_env. HOME is dir,
+// so credential files in the user's home such as ~/.kube/config are out of
+// reach, and credentials in the environment of the toolkit, such as tokens
+// for issue trackers or cloud providers, are not passed on.
+func (k *K8sToolkit) pluginEnv(ctx context.Context, dir, namespace string, ttl time.Duration) ([]string, error) {
+	kubeconfig, err := k.pluginKubeconfig(ctx, dir, namespace, ttl)
+	if err != nil {
+		return nil, err
+	}
+	env := []string{
+		"K8S_TOOLKIT_NAMESPACE=" + namespace,
+		"K8S_TOOLKIT_CONTEXT=" + pluginContext,
+		"K8S_TOOLKIT_OUTPUT=" + viper.GetString("output"),
+		"KUBECONFIG=" + kubeconfig,
+		"HOME=" + dir,
+	}
+	names := append([]string{"PATH", "USER", "LANG", "TZ", "TMPDIR", "SSL_CERT_FILE", "SSL_CERT_DIR"}, viper.GetStringSlice("plugins.pass_env")...)
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env, nil
+}
+
+// pluginChecks returns a check for every installed check plugin enabled in
+// plugins.checks. Plugins run isolated and report like checks.exec commands.
+func (k *K8sToolkit) pluginChecks() []HealthChecker {
+	enabled := make(map[string]bool)
+	for _, name := range viper.GetStringSlice("plugins.checks") {
+		enabled[name] = true
+	}
+	if len(enabled) == 0 {
+		return nil
+	}
+
+	state, err := loadPluginState()
+	if err != nil {
+		return []HealthChecker{&execCheck{
+			config:    execCheckConfig{Name: "plugins", Component: "Plugins"},
+			configErr: err,
+		}}
+	}
+
+	var checkers []HealthChecker
+	for _, p := range state.sortedPlugins() {
+		if p.Kind != pluginKindCheck || !enabled[p.Name] {
+			continue
+		}
+		config := execCheckConfig{
+			Name:      p.Name,
+			Component: p.Component,
+			Command:   []string{p.Path, "check"},
+			Timeout:   p.Timeout,
+			Isolated:  true,
+		}
+		if config.Component == "" {
+			config.Component = p.Name
+		}
+		if config.Timeout <= 0 {
+			config.Timeout = 30 * time.Second
+		}
+		checkers = append(checkers, &execCheck{config: config, toolkit: k})
+	}
+	return checkers
+}
+
+// RunPlugin runs an installed command plugin isolated, connected to the
+// terminal, and returns its exit code
+func (k *K8sToolkit) RunPlugin(ctx context.Context, name string, args []string) (int, error) {
+	state, err := loadPluginState()
+	if err != nil {
+		return 0, err
+	}
+	plugin, ok := state.Plugins[name]
+	if !ok {
+		return 0, fmt.Errorf("plugin %s is not installed", name)
+	}
+	if plugin.Kind != pluginKindCommand {
+		return 0, fmt.Errorf("plugin %s is a %s plugin and runs as part of health", name, plugin.Kind)
+	}
+	if readOnly() {
+		return 0, fmt.Errorf("%w: plugin %s runs with the kubeconfig outside the read-only client", ErrReadOnly, name)
+	}
+
+	dir, err := os.MkdirTemp("", "k8s-toolkit-plugin-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	cmd := exec.CommandContext(ctx, plugin.Path, args...)
+	cmd.Dir = dir
+	// Command plugins run interactively; the token lives for an hour
+	if cmd.Env, err = k.pluginEnv(ctx, dir, k.namespace, time.Hour); err != nil {
+		return 0, err
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to run plugin %s: %w", name, err)
+	}
+	return 0, nil
+}
+
+// PluginListEntry is an installed plugin with the latest version in the index
+type PluginListEntry struct {
+	installedPlugin
+	Latest string `json:"latest,omitempty"`
+}
+
+// createPluginCmd creates the plugin command
+func createPluginCmd() *cobra.Command {
+	pluginCmd := &cobra.Command{
+		Use:   "plugin",
+		Short: "Install check and command plugins from a plugin index",
+		Long: `Plugins let teams publish checks and commands without a toolkit release. They are listed in
+index.yaml of the index at plugins.index: a git repository (git+https://host/repo.git#branch,
+ssh:// or any URL ending in .git), an OCI artifact (oci://registry/repo:tag, pulled with oras),
+an http(s) URL or a local directory. Each release names a binary per GOOS/GOARCH with its
+sha256 and a detached cosign or minisign signature, verified against plugins.public_key with
+the matching binary on PATH. The signature covers the line
+"k8s-toolkit-plugin name=<name> version=<version> platform=<goos>/<goarch> sha256=<sha256>"
+followed by a newline, not the binary itself. Unsigned plugins are refused unless
+plugins.allow_unsigned is set.
+
+Check plugins listed in plugins.checks run with every health run as "<plugin> check" and
+report like checks.exec commands; installed check plugins do not run until they are listed.
+Command plugins run with plugin run <name> -- <args>. Both run in an empty working directory
+that is also their HOME, with only K8S_TOOLKIT_* context variables, basic process variables and
+those listed in plugins.pass_env in their environment. They never see the user's kubeconfig:
+KUBECONFIG points to one holding a short-lived token for the service account in
+plugins.service_account (namespace/name), or no credentials when it is not set. Plugins do not
+run in read-only mode.`,
+	}
+
+	searchCmd := &cobra.Command{
+		Use:   "search [term]",
+		Short: "List the plugins in the index",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit := &K8sToolkit{output: viper.GetString("output"), filter: viper.GetString("filter")}
+			index, err := loadPluginIndex(context.Background())
+			if err != nil {
+				logger.Fatalf("Failed to load plugin index: %v", err)
+			}
+
+			var matches []pluginManifest
+			for _, p := range index.Plugins {
+				if len(args) == 0 || strings.Contains(strings.ToLower(p.Name+" "+p.Description), strings.ToLower(args[0])) {
+					matches = append(matches, p)
+				}
+			}
+			if toolkit.filtered(matches) {
+				return
+			}
+			if toolkit.output == "json" {
+				printJSON(matches)
+				return
+			}
+			fmt.Printf("%-25s %-8s %-10s %-20s %s\n", "NAME", "KIND", "LATEST", "OWNER", "DESCRIPTION")
+			for _, p := range matches {
+				latest := ""
+				if release, err := p.release(""); err == nil {
+					latest = release.Version
+				}
+				fmt.Printf("%-25s %-8s %-10s %-20s %s\n", p.Name, p.Kind, latest, p.Owner, p.Description)
+			}
+		},
+	}
+
+	installCmd := &cobra.Command{
+		Use:   "install <name>[@version]...",
+		Short: "Install or upgrade plugins, the latest version unless one is given",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			index, err := loadPluginIndex(ctx)
+			if err != nil {
+				logger.Fatalf("Failed to load plugin index: %v", err)
+			}
+			for _, arg := range args {
+				name, version, _ := strings.Cut(arg, "@")
+				plugin, err := InstallPlugin(ctx, index, name, version)
+				if err != nil {
+					logger.Fatalf("Failed to install %s: %v", arg, err)
+				}
+				verified := "signature verified"
+				if !plugin.Signed {
+					verified = "UNSIGNED"
+				}
+				fmt.Printf("Installed %s %s (%s plugin, %s)\n", plugin.Name, plugin.Version, plugin.Kind, verified)
+				if plugin.Kind == pluginKindCheck && !containsString(viper.GetStringSlice("plugins.checks"), plugin.Name) {
+					fmt.Printf("Add %s to plugins.checks to run it with health\n", plugin.Name)
+				}
+			}
+		},
+	}
+
+	upgradeCmd := &cobra.Command{
+		Use:   "upgrade [name]...",
+		Short: "Upgrade installed plugins to the latest version in the index",
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			state, err := loadPluginState()
+			if err != nil {
+				logger.Fatalf("Failed to read installed plugins: %v", err)
+			}
+			index, err := loadPluginIndex(ctx)
+			if err != nil {
+				logger.Fatalf("Failed to load plugin index: %v", err)
+			}
+			names := args
+			if len(names) == 0 {
+				for _, p := range state.sortedPlugins() {
+					names = append(names, p.Name)
+				}
+			}
+			for _, name := range names {
+				current, ok := state.Plugins[name]
+				if !ok {
+					logger.Fatalf("Plugin %s is not installed", name)
+				}
+				manifest, err := index.find(name)
+				if err != nil {
+					logger.Fatalf("Failed to upgrade %s: %v", name, err)
+				}
+				release, err := manifest.release("")
+				if err != nil {
+					logger.Fatalf("Failed to upgrade %s: %v", name, err)
+				}
+				if compareVersions(release.Version, current.Version) <= 0 {
+					fmt.Printf("%s %s is up to date\n", name, current.Version)
+					continue
+				}
+				if _, err := InstallPlugin(ctx, index, name, release.Version); err != nil {
+					logger.Fatalf("Failed to upgrade %s: %v", name, err)
+				}
+				fmt.Printf("Upgraded %s %s -> %s\n", name, current.Version, release.Version)
+			}
+		},
+	}
+
+	var checkLatest bool
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List installed plugins",
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit := &K8sToolkit{output: viper.GetString("output"), filter: viper.GetString("filter")}
+			state, err := loadPluginState()
+			if err != nil {
+				logger.Fatalf("Failed to read installed plugins: %v", err)
+			}
+
+			var index *pluginIndex
+			if checkLatest {
+				if index, err = loadPluginIndex(context.Background()); err != nil {
+					logger.Fatalf("Failed to load plugin index: %v", err)
+				}
+			}
+			var entries []PluginListEntry
+			for _, p := range state.sortedPlugins() {
+				entry := PluginListEntry{installedPlugin: p}
+				if index != nil {
+					if manifest, err := index.find(p.Name); err == nil {
+						if release, err := manifest.release(""); err == nil {
+							entry.Latest = release.Version
+						}
+					}
+				}
+				entries = append(entries, entry)
+			}
+
+			if toolkit.filtered(entries) {
+				return
+			}
+			if toolkit.output == "json" {
+				printJSON(entries)
+				return
+			}
+			fmt.Printf("%-25s %-8s %-10s %-10s %-8s %s\n", "NAME", "KIND", "VERSION", "LATEST", "SIGNED", "INSTALLED")
+			for _, e := range entries {
+				fmt.Printf("%-25s %-8s %-10s %-10s %-8t %s\n", e.Name, e.Kind, e.Version, e.Latest, e.Signed, e.InstalledAt.Format("2006-01-02 15:04"))
+			}
+		},
+	}
+	listCmd.Flags().BoolVar(&checkLatest, "check", false, "Show the latest version in the index")
+
+	removeCmd := &cobra.Command{
+		Use:   "remove <name>...",
+		Short: "Uninstall plugins",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			for _, name := range args {
+				if err := RemovePlugin(name); err != nil {
+					logger.Fatalf("Failed to remove %s: %v", name, err)
+				}
+				fmt.Printf("Removed %s\n", name)
+			}
+		},
+	}
+
+	runCmd := &cobra.Command{
+		Use:   "run <name> [-- args...]",
+		Short: "Run a command plugin; arguments after -- are passed to it",
+		Args:  cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			code, err := toolkit.RunPlugin(context.Background(), args[0], args[1:])
+			if err != nil {
+				logger.Fatalf("%v", err)
+			}
+			os.Exit(code)
+		},
+	}
+
+	pluginCmd.AddCommand(searchCmd)
+	pluginCmd.AddCommand(installCmd)
+	pluginCmd.AddCommand(upgradeCmd)
+	pluginCmd.AddCommand(listCmd)
+	pluginCmd.AddCommand(removeCmd)
+	pluginCmd.AddCommand(runCmd)
+	return pluginCmd
+}
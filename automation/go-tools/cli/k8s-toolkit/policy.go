@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// severityIgnore drops a matching result from the report
+const severityIgnore = "Ignore"
+
+// policyRule is an entry under policy.rules in the config file. Check and
+// Status select results; Message additionally matches a regular expression
+// against the result message. A matching result takes Severity as its status,
+// or is left out of the report when Severity is Ignore. ExitCode replaces the
+// exit code of the health command when the result fails the run.
+type policyRule struct {
+	Check    string `mapstructure:"check"`
+	Status   string `mapstructure:"status"`
+	Message  string `mapstructure:"message"`
+	Severity string `mapstructure:"severity"`
+	ExitCode int    `mapstructure:"exit_code"`
+
+	message *regexp.Regexp
+}
+
+// severityPolicy maps check results to severities and exit codes. Rules are
+// applied in order and the first match wins.
+type severityPolicy struct {
+	rules []policyRule
+}
+
+// loadSeverityPolicy reads policy.rules from the config
+func loadSeverityPolicy() (*severityPolicy, error) {
+	var rules []policyRule
+	if err := viper.UnmarshalKey("policy.rules", &rules); err != nil {
+		return nil, fmt.Errorf("invalid policy.rules: %w", err)
+	}
+	for i := range rules {
+		rule := &rules[i]
+		switch rule.Severity {
+		case "", "Healthy", "Warning", "Critical", severityIgnore:
+		default:
+			return nil, fmt.Errorf("policy.rules[%d]: unknown severity %q (Healthy|Warning|Critical|Ignore)", i, rule.Severity)
+		}
+		switch rule.Status {
+		case "", "Healthy", "Warning", "Critical":
+		default:
+			return nil, fmt.Errorf("policy.rules[%d]: unknown status %q (Healthy|Warning|Critical)", i, rule.Status)
+		}
+		if rule.ExitCode < 0 || rule.ExitCode > 125 {
+			return nil, fmt.Errorf("policy.rules[%d]: exit code %d out of range 0-125", i, rule.ExitCode)
+		}
+		if rule.Message != "" {
+			re, err := regexp.Compile(rule.Message)
+			if err != nil {
+				return nil, fmt.Errorf("policy.rules[%d]: invalid message pattern: %w", i, err)
+			}
+			rule.message = re
+		}
+	}
+	return &severityPolicy{rules: rules}, nil
+}
+
+// match returns the first rule selecting result, or nil
+func (p *severityPolicy) match(result HealthCheckResult) *policyRule {
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if rule.Check != "" && rule.Check != result.Check {
+			continue
+		}
+		if rule.Status != "" && rule.Status != result.Status {
+			continue
+		}
+		if rule.message != nil && !rule.message.MatchString(result.Message) {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// apply sets the severity of each result, returning the kept results and the
+// names of the ignored checks. The original status is kept in the details.
+func (p *severityPolicy) apply(checks []HealthCheckResult) ([]HealthCheckResult, []string) {
+	kept := checks[:0]
+	var ignored []string
+	for _, check := range checks {
+		rule := p.match(check)
+		switch {
+		case rule == nil || rule.Severity == "":
+		case rule.Severity == severityIgnore:
+			ignored = append(ignored, check.Check)
+			continue
+		case rule.Severity != check.Status:
+			if check.Details == nil {
+				check.Details = make(map[string]string)
+			}
+			check.Details["policy_original_status"] = check.Status
+			check.Status = rule.Severity
+		}
+		if rule != nil {
+			check.exitCode = rule.ExitCode
+		}
+		kept = append(kept, check)
+	}
+	return kept, ignored
+}
+
+// failsOn reports whether status fails a run gated at failOn
+func failsOn(status, failOn string) bool {
+	switch failOn {
+	case "none":
+		return false
+	case "warning":
+		return status == "Warning" || status == "Critical"
+	default:
+		return status == "Critical"
+	}
+}
+
+// healthExitCode returns the exit code of a health run gated at failOn: 0
+// when no result fails it, otherwise the highest exit_code of the rules that
+// matched the failing results, or 1 when none sets one
+func healthExitCode(health *ClusterHealth, failOn string) int {
+	code := 0
+	for _, check := range health.Checks {
+		if !failsOn(check.Status, failOn) {
+			continue
+		}
+		if code == 0 {
+			code = 1
+		}
+		if check.exitCode > code {
+			code = check.exitCode
+		}
+	}
+	return code
+}
+
+// parseFailOn validates --fail-on
+func parseFailOn(value string) (string, error) {
+	value = strings.ToLower(value)
+	switch value {
+	case "warning", "critical", "none":
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid fail-on %q (warning|critical|none)", value)
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// Port-forward states
+const (
+	forwardWaiting    = "waiting"
+	forwardConnecting = "connecting"
+	forwardActive     = "forwarding"
+	forwardStopped    = "stopped"
+)
+
+// maxForwardBackoff caps the delay between reconnect attempts
+const maxForwardBackoff = 30 * time.Second
+
+// portForwardConfig is an entry under port_forwards in the config file or
+// forwards in a --forwards-file. Target is pod/<name>, svc/<name> or
+// deploy/<name>; ports are local:remote, where remote may be a port name,
+// or a single port forwarded to the same local port.
+type portForwardConfig struct {
+	Name      string   `mapstructure:"name"`
+	Namespace string   `mapstructure:"namespace"`
+	Target    string   `mapstructure:"target"`
+	Ports     []string `mapstructure:"ports"`
+	Address   string   `mapstructure:"address"`
+}
+
+// PortForwardStatus is the state of one managed port-forward
+type PortForwardStatus struct {
+	Name       string    `json:"name"`
+	Target     string    `json:"target"`
+	Pod        string    `json:"pod,omitempty"`
+	Ports      []string  `json:"ports"`
+	State      string    `json:"state"`
+	Since      time.Time `json:"since"`
+	Reconnects int       `json:"reconnects"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// loadPortForwards reads the port-forwards from file, or from port_forwards
+// in the config file when file is empty, keeping only names when given
+func loadPortForwards(file string, names []string) ([]portForwardConfig, error) {
+	source, key := viper.GetViper(), "port_forwards"
+	if file != "" {
+		source, key = viper.New(), "forwards"
+		source.SetConfigFile(file)
+		if err := source.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+	}
+	var forwards []portForwardConfig
+	if err := source.UnmarshalKey(key, &forwards); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		wanted[name] = true
+	}
+	var selected []portForwardConfig
+	for i, forward := range forwards {
+		if forward.Name == "" {
+			forward.Name = forward.Target
+		}
+		if len(wanted) > 0 && !wanted[forward.Name] {
+			continue
+		}
+		delete(wanted, forward.Name)
+		if kind, _, ok := strings.Cut(forward.Target, "/"); !ok || forwardTargetKind(kind) == "" {
+			return nil, fmt.Errorf("%s[%d]: invalid target %q (use pod/<name>, svc/<name> or deploy/<name>)", key, i, forward.Target)
+		}
+		if len(forward.Ports) == 0 {
+			return nil, fmt.Errorf("%s[%d]: no ports", key, i)
+		}
+		if forward.Namespace == "" {
+			forward.Namespace = "default"
+		}
+		if forward.Address == "" {
+			forward.Address = "localhost"
+		}
+		selected = append(selected, forward)
+	}
+	for name := range wanted {
+		return nil, fmt.Errorf("no port-forward named %q under %s", name, key)
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no port-forwards configured: add them under %s", key)
+	}
+	return selected, nil
+}
+
+// forwardTargetKind normalizes the kind of a target, or returns "" for
+// unsupported kinds
+func forwardTargetKind(kind string) string {
+	switch strings.ToLower(kind) {
+	case "pod", "pods", "po":
+		return "pod"
+	case "svc", "service", "services":
+		return "service"
+	case "deploy", "deployment", "deployments":
+		return "deployment"
+	}
+	return ""
+}
+
+// resolvePortForward picks the pod to forward to and translates the ports
+// to local:containerPort. Services and Deployments resolve to one of their
+// running and ready pods; service ports map through their targetPort.
+func (k *K8sToolkit) resolvePortForward(ctx context.Context, forward portForwardConfig) (*corev1.Pod, []string, error) {
+	kind, name, _ := strings.Cut(forward.Target, "/")
+	pods := k.clientset.CoreV1().Pods(forward.Namespace)
+
+	var pod *corev1.Pod
+	var service *corev1.Service
+	switch forwardTargetKind(kind) {
+	case "pod":
+		p, err := pods.Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		if p.Status.Phase != corev1.PodRunning || p.DeletionTimestamp != nil {
+			return nil, nil, fmt.Errorf("pod %s is %s", name, p.Status.Phase)
+		}
+		pod = p
+	default:
+		var selector labels.Selector
+		if forwardTargetKind(kind) == "service" {
+			svc, err := k.clientset.CoreV1().Services(forward.Namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(svc.Spec.Selector) == 0 {
+				return nil, nil, fmt.Errorf("service %s has no selector", name)
+			}
+			service = svc
+			selector = labels.SelectorFromSet(svc.Spec.Selector)
+		} else {
+			deployment, err := k.clientset.AppsV1().Deployments(forward.Namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return nil, nil, err
+			}
+			s, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+			if err != nil {
+				return nil, nil, err
+			}
+			selector = s
+		}
+		candidates, err := pods.List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+		if err != nil {
+			return nil, nil, err
+		}
+		for i := range candidates.Items {
+			p := &candidates.Items[i]
+			if p.Status.Phase == corev1.PodRunning && p.DeletionTimestamp == nil && isPodReady(p) {
+				pod = p
+				break
+			}
+		}
+		if pod == nil {
+			return nil, nil, fmt.Errorf("%s has no running and ready pods", forward.Target)
+		}
+	}
+
+	var ports []string
+	for _, spec := range forward.Ports {
+		local, remote, ok := strings.Cut(spec, ":")
+		if !ok {
+			remote = local
+		}
+		port, err := forwardRemotePort(remote, pod, service)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok {
+			local = strconv.Itoa(int(port))
+		}
+		ports = append(ports, fmt.Sprintf("%s:%d", local, port))
+	}
+	return pod, ports, nil
+}
+
+// forwardRemotePort resolves a remote port number or name to a container
+// port, through the matching service port when forwarding to a Service
+func forwardRemotePort(remote string, pod *corev1.Pod, service *corev1.Service) (int32, error) {
+	target := intstr.Parse(remote)
+	if service != nil {
+		found := false
+		for _, port := range service.Spec.Ports {
+			if (target.Type == intstr.Int && port.Port == target.IntVal) || (target.Type == intstr.String && port.Name == target.StrVal) {
+				target, found = port.TargetPort, true
+				if target.Type == intstr.Int && target.IntVal == 0 {
+					target = intstr.FromInt(int(port.Port))
+				}
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("service %s has no port %s", service.Name, remote)
+		}
+	}
+	if target.Type == intstr.Int {
+		return target.IntVal, nil
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == target.StrVal {
+				return port.ContainerPort, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("pod %s has no container port named %s", pod.Name, target.StrVal)
+}
+
+// portForwardManager keeps the configured port-forwards running and tracks
+// their status
+type portForwardManager struct {
+	toolkit *K8sToolkit
+
+	mu       sync.Mutex
+	statuses []PortForwardStatus
+	changed  chan struct{}
+}
+
+// update changes the status of forward i and signals the status view
+func (m *portForwardManager) update(i int, change func(*PortForwardStatus)) {
+	m.mu.Lock()
+	status := &m.statuses[i]
+	previous := status.State
+	change(status)
+	if status.State != previous {
+		status.Since = time.Now()
+	}
+	m.mu.Unlock()
+	select {
+	case m.changed <- struct{}{}:
+	default:
+	}
+}
+
+// Statuses returns a copy of the status of every port-forward
+func (m *portForwardManager) Statuses() []PortForwardStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]PortForwardStatus(nil), m.statuses...)
+}
+
+// run keeps forward i running until ctx is done, resolving the target again
+// and reconnecting with exponential backoff whenever the pod goes away or
+// the connection drops
+func (m *portForwardManager) run(ctx context.Context, i int, forward portForwardConfig) {
+	k := m.toolkit
+	backoff := time.Second
+	for ctx.Err() == nil {
+		pod, ports, err := k.resolvePortForward(ctx, forward)
+		if err != nil {
+			m.update(i, func(s *PortForwardStatus) {
+				s.State, s.Pod, s.LastError = forwardWaiting, "", err.Error()
+			})
+		} else {
+			m.update(i, func(s *PortForwardStatus) {
+				s.State, s.Pod, s.Ports = forwardConnecting, pod.Name, ports
+			})
+			started := time.Now()
+			err = k.forwardToPod(ctx, forward, pod, ports, func() {
+				m.update(i, func(s *PortForwardStatus) { s.State = forwardActive })
+			})
+			if ctx.Err() != nil {
+				break
+			}
+			m.update(i, func(s *PortForwardStatus) {
+				s.State, s.LastError = forwardWaiting, err.Error()
+				s.Reconnects++
+			})
+			// A forward that ran for a while reconnects quickly again
+			if time.Since(started) > maxForwardBackoff {
+				backoff = time.Second
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxForwardBackoff {
+			backoff = maxForwardBackoff
+		}
+	}
+	m.update(i, func(s *PortForwardStatus) { s.State = forwardStopped })
+}
+
+// forwardToPod forwards ports to a pod until ctx is done, the connection
+// drops or the pod stops running, and returns why it ended
+func (k *K8sToolkit) forwardToPod(ctx context.Context, forward portForwardConfig, pod *corev1.Pod, ports []string, ready func()) error {
+	transport, upgrader, err := spdy.RoundTripperFor(k.restConfig)
+	if err != nil {
+		return err
+	}
+	url := k.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(pod.Namespace).
+		Name(pod.Name).
+		SubResource("portforward").
+		URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stopCh, readyCh := make(chan struct{}), make(chan struct{})
+	var stopOnce sync.Once
+	var reason error
+	stop := func(err error) {
+		stopOnce.Do(func() {
+			reason = err
+			close(stopCh)
+		})
+	}
+
+	var errors strings.Builder
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{forward.Address}, ports, stopCh, readyCh, nil, &errors)
+	if err != nil {
+		return err
+	}
+
+	// Watch the pod, since a deleted pod does not always close the stream
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(3 * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				stop(ctx.Err())
+				return
+			case <-readyCh:
+				ready()
+				readyCh = nil
+			case <-ticker.C:
+				current, err := k.clientset.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+				switch {
+				case apierrors.IsNotFound(err):
+					stop(fmt.Errorf("pod %s was deleted", pod.Name))
+				case err == nil && current.UID != pod.UID:
+					stop(fmt.Errorf("pod %s was replaced", pod.Name))
+				case err == nil && (current.DeletionTimestamp != nil || current.Status.Phase != corev1.PodRunning):
+					stop(fmt.Errorf("pod %s is terminating", pod.Name))
+				}
+			}
+		}
+	}()
+
+	if err := forwarder.ForwardPorts(); err != nil {
+		return err
+	}
+	if reason != nil {
+		return reason
+	}
+	if message := strings.TrimSpace(errors.String()); message != "" {
+		return fmt.Errorf("lost connection to pod %s: %s", pod.Name, message)
+	}
+	return fmt.Errorf("lost connection to pod %s", pod.Name)
+}
+
+// PrintPortForwardStatus prints the state of every port-forward
+func (k *K8sToolkit) PrintPortForwardStatus(statuses []PortForwardStatus) {
+	if k.output == "json" {
+		printJSON(statuses)
+		return
+	}
+
+	fmt.Printf("\nPort-forwards (%s)\n", time.Now().Format("15:04:05"))
+	fmt.Printf("%-20s %-30s %-20s %-12s %-10s %-10s %s\n", "NAME", "TARGET", "PORTS", "STATE", "SINCE", "RECONNECTS", "POD / LAST ERROR")
+	for _, s := range statuses {
+		detail := s.Pod
+		if s.State != forwardActive && s.LastError != "" {
+			detail = s.LastError
+		}
+		fmt.Printf("%-20s %-30s %-20s %-12s %-10s %-10d %s\n", s.Name, s.Target, strings.Join(s.Ports, ","), s.State,
+			time.Since(s.Since).Round(time.Second), s.Reconnects, detail)
+	}
+}
+
+// RunPortForwards keeps every forward running until ctx is done and prints
+// the status table whenever a forward changes state. With statusAddr the
+// statuses are also served as JSON on GET /status.
+func (k *K8sToolkit) RunPortForwards(ctx context.Context, forwards []portForwardConfig, statusAddr string) error {
+	m := &portForwardManager{toolkit: k, changed: make(chan struct{}, 1)}
+	for _, forward := range forwards {
+		m.statuses = append(m.statuses, PortForwardStatus{
+			Name: forward.Name, Target: forward.Namespace + "/" + forward.Target, Ports: forward.Ports,
+			State: forwardWaiting, Since: time.Now(),
+		})
+	}
+
+	if statusAddr != "" {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+			statuses := m.Statuses()
+			sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(statuses)
+		})
+		server := &http.Server{Addr: statusAddr, Handler: mux}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				k.log().Errorf("status server failed: %v", err)
+			}
+		}()
+		defer server.Close()
+	}
+
+	var wg sync.WaitGroup
+	for i, forward := range forwards {
+		wg.Add(1)
+		go func(i int, forward portForwardConfig) {
+			defer wg.Done()
+			m.run(ctx, i, forward)
+		}(i, forward)
+	}
+
+	// Redraw at most once a second so a flapping forward does not flood the terminal
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			k.PrintPortForwardStatus(m.Statuses())
+			return nil
+		case <-m.changed:
+			k.PrintPortForwardStatus(m.Statuses())
+			time.Sleep(time.Second)
+		}
+	}
+}
+
+// createPortForwardCmd creates the port-forward command
+func createPortForwardCmd() *cobra.Command {
+	var forwardsFile, statusAddr string
+
+	portForwardCmd := &cobra.Command{
+		Use:   "port-forward [name...]",
+		Short: "Keep several port-forwards running and reconnect them",
+		Long: `Runs the port-forwards defined under port_forwards in the config file, or under forwards in
+--forwards-file, side by side in one process, replacing a terminal full of kubectl port-forward
+sessions. Pass names to run only some of them. Services and Deployments forward to one of their
+running and ready pods, with service ports mapped through their targetPort. When the pod is
+deleted, replaced or the connection drops, the target is resolved again and the forward
+reconnects with backoff. A status table is printed whenever a forward changes state, and with
+--status-addr the same status is served as JSON on GET /status.
+
+  port_forwards:
+    - name: grafana
+      namespace: monitoring
+      target: svc/grafana
+      ports: ["3000:80"]
+    - name: postgres
+      namespace: payments
+      target: deploy/postgres
+      ports: ["5432"]
+    - name: debug
+      namespace: payments
+      target: pod/api-7d9f8-abcde
+      ports: ["8080:http", "9090"]
+      address: 0.0.0.0`,
+		Run: func(cmd *cobra.Command, args []string) {
+			forwards, err := loadPortForwards(forwardsFile, args)
+			if err != nil {
+				logger.Fatalf("Invalid port-forwards: %v", err)
+			}
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if err := toolkit.RunPortForwards(ctx, forwards, statusAddr); err != nil {
+				logger.Fatalf("Port-forwarding failed: %v", err)
+			}
+		},
+	}
+
+	portForwardCmd.Flags().StringVar(&forwardsFile, "forwards-file", "", "YAML file with the port-forwards under forwards (default: port_forwards in the config file)")
+	portForwardCmd.Flags().StringVar(&statusAddr, "status-addr", "", "Serve the status as JSON on GET /status at this address, e.g. localhost:9999")
+
+	return portForwardCmd
+}
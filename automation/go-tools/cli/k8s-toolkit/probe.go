@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// probeName prefixes the probe pod and ephemeral container names
+const probeName = "k8s-toolkit-probe"
+
+// probeMarker starts the output of each curl run in the probe script
+const probeMarker = "@@k8s-toolkit-probe "
+
+// ServiceProbeOptions configures a Service probe
+type ServiceProbeOptions struct {
+	Image        string
+	Path         string
+	Scheme       string
+	Timeout      time.Duration
+	ReadyTimeout time.Duration
+
+	// From is a pod to probe from with an ephemeral container instead of a
+	// new probe pod, to test with its identity and network policies
+	From string
+}
+
+// ProbeTLS is the certificate an endpoint presented
+type ProbeTLS struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	DNSNames []string  `json:"dns_names,omitempty"`
+	NotAfter time.Time `json:"not_after"`
+
+	// ChainVerified is whether the chain verified against the probe image's
+	// trust store; HostnameMatch whether it covers <service>.<namespace>.svc
+	ChainVerified bool `json:"chain_verified"`
+	HostnameMatch bool `json:"hostname_match"`
+}
+
+// EndpointProbe is the result of requesting one Service port through the
+// Service address or directly on one endpoint
+type EndpointProbe struct {
+	Port           string    `json:"port"`
+	Target         string    `json:"target"`
+	URL            string    `json:"url"`
+	StatusCode     int       `json:"status_code,omitempty"`
+	HTTPVersion    string    `json:"http_version,omitempty"`
+	LatencyMs      float64   `json:"latency_ms"`
+	ConnectMs      float64   `json:"connect_ms"`
+	TLSHandshakeMs float64   `json:"tls_handshake_ms,omitempty"`
+	TLS            *ProbeTLS `json:"tls,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// ServiceProbeReport is the result of probing every port of a Service
+type ServiceProbeReport struct {
+	Namespace   string          `json:"namespace"`
+	Service     string          `json:"service"`
+	ProbedFrom  string          `json:"probed_from"`
+	GeneratedAt time.Time       `json:"generated_at"`
+	Probes      []EndpointProbe `json:"probes"`
+}
+
+// curlResult is the part of curl's --write-out %{json} the probe reads
+type curlResult struct {
+	HTTPCode        int     `json:"http_code"`
+	HTTPVersion     string  `json:"http_version"`
+	TimeTotal       float64 `json:"time_total"`
+	TimeConnect     float64 `json:"time_connect"`
+	TimeAppConnect  float64 `json:"time_appconnect"`
+	SSLVerifyResult int     `json:"ssl_verify_result"`
+	ExitCode        int     `json:"exitcode"`
+	ErrorMsg        *string `json:"errormsg"`
+}
+
+// probeScheme picks http or https for a Service port: the override unless it
+// is auto, otherwise https when the port name or appProtocol mentions https or
+// tls or the port is 443 or 8443
+func probeScheme(port corev1.ServicePort, override string) string {
+	if override != "" && override != "auto" {
+		return override
+	}
+	name := strings.ToLower(port.Name)
+	if port.AppProtocol != nil {
+		name += " " + strings.ToLower(*port.AppProtocol)
+	}
+	if strings.Contains(name, "https") || strings.Contains(name, "tls") || port.Port == 443 || port.Port == 8443 {
+		return "https"
+	}
+	return "http"
+}
+
+// serviceProbeTargets lists the URLs to request: each port through the
+// Service address, unless it is headless, and on every endpoint. Endpoints
+// that are not ready are listed with an error instead of being requested.
+func (k *K8sToolkit) serviceProbeTargets(ctx context.Context, svc *corev1.Service, opts ServiceProbeOptions) ([]EndpointProbe, error) {
+	endpoints, err := k.clientset.CoreV1().Endpoints(svc.Namespace).Get(ctx, svc.Name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoints of %s/%s: %w", svc.Namespace, svc.Name, err)
+	}
+
+	var targets []EndpointProbe
+	for _, port := range svc.Spec.Ports {
+		if port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+			continue
+		}
+		scheme := probeScheme(port, opts.Scheme)
+		portName := port.Name
+		if portName == "" {
+			portName = strconv.Itoa(int(port.Port))
+		}
+		url := func(host string, number int32) string {
+			return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(int(number))) + opts.Path
+		}
+
+		if svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != corev1.ClusterIPNone {
+			host := fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace)
+			targets = append(targets, EndpointProbe{Port: portName, Target: "service", URL: url(host, port.Port)})
+		}
+		for _, subset := range endpoints.Subsets {
+			var number int32
+			for _, p := range subset.Ports {
+				if p.Name == port.Name {
+					number = p.Port
+				}
+			}
+			if number == 0 {
+				continue
+			}
+			name := func(address corev1.EndpointAddress) string {
+				if address.TargetRef != nil {
+					return address.TargetRef.Name
+				}
+				return address.IP
+			}
+			for _, address := range subset.Addresses {
+				targets = append(targets, EndpointProbe{Port: portName, Target: name(address), URL: url(address.IP, number)})
+			}
+			for _, address := range subset.NotReadyAddresses {
+				targets = append(targets, EndpointProbe{Port: portName, Target: name(address), URL: url(address.IP, number), Error: "endpoint not ready"})
+			}
+		}
+	}
+	return targets, nil
+}
+
+// probeScript requests every target with curl, printing curl's JSON
+// write-out and the peer certificates after a marker line per target
+func probeScript(targets []EndpointProbe, timeout time.Duration) string {
+	seconds := int(timeout.Seconds())
+	if seconds < 1 {
+		seconds = 1
+	}
+	var script strings.Builder
+	for i, target := range targets {
+		if target.Error != "" {
+			continue
+		}
+		fmt.Fprintf(&script, "echo '%s%d'; curl -sk -o /dev/null --max-time %d -w '%%{json}\\n%%{certs}' '%s'; echo; ",
+			probeMarker, i, seconds, strings.ReplaceAll(target.URL, "'", `'\''`))
+	}
+	return script.String()
+}
+
+// parseProbeOutput fills in the targets from the probe script's output
+func parseProbeOutput(output string, targets []EndpointProbe, serviceHost string) {
+	for _, block := range strings.Split(output, probeMarker)[1:] {
+		lines := strings.SplitN(block, "\n", 3)
+		index, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+		if err != nil || index < 0 || index >= len(targets) {
+			continue
+		}
+		probe := &targets[index]
+		if len(lines) < 2 {
+			probe.Error = "no output from curl"
+			continue
+		}
+		var result curlResult
+		if err := json.Unmarshal([]byte(lines[1]), &result); err != nil {
+			probe.Error = strings.TrimSpace(lines[1])
+			continue
+		}
+		probe.StatusCode = result.HTTPCode
+		probe.HTTPVersion = result.HTTPVersion
+		probe.LatencyMs = result.TimeTotal * 1000
+		probe.ConnectMs = result.TimeConnect * 1000
+		probe.TLSHandshakeMs = result.TimeAppConnect * 1000
+		if result.ExitCode != 0 {
+			probe.Error = fmt.Sprintf("curl exit code %d", result.ExitCode)
+			if result.ErrorMsg != nil && *result.ErrorMsg != "" {
+				probe.Error = *result.ErrorMsg
+			}
+		}
+
+		if len(lines) < 3 {
+			continue
+		}
+		if certs := parseCertificates([]byte(lines[2])); len(certs) > 0 {
+			leaf := certs[0]
+			probe.TLS = &ProbeTLS{
+				Subject:       leaf.Subject.String(),
+				Issuer:        leaf.Issuer.String(),
+				DNSNames:      leaf.DNSNames,
+				NotAfter:      leaf.NotAfter,
+				ChainVerified: result.SSLVerifyResult == 0,
+				HostnameMatch: leaf.VerifyHostname(serviceHost) == nil,
+			}
+		}
+	}
+}
+
+// probePod builds the probe pod. It satisfies the restricted Pod Security
+// Standard and exits on its own after ten minutes in case the teardown
+// never runs.
+func probePod(namespace string, opts ServiceProbeOptions) *corev1.Pod {
+	deadline := int64(600)
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: probeName + "-",
+			Namespace:    namespace,
+			Labels:       map[string]string{"app.kubernetes.io/name": probeName},
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy:                 corev1.RestartPolicyNever,
+			ActiveDeadlineSeconds:         &deadline,
+			TerminationGracePeriodSeconds: new(int64),
+			AutomountServiceAccountToken:  new(bool),
+			SecurityContext: &corev1.PodSecurityContext{
+				SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+			},
+			Containers: []corev1.Container{{
+				Name:            "probe",
+				Image:           opts.Image,
+				Command:         []string{"sleep", "600"},
+				SecurityContext: probeSecurityContext(),
+			}},
+		},
+	}
+}
+
+// probeSecurityContext runs the curl container unprivileged as its
+// unprivileged image user
+func probeSecurityContext() *corev1.SecurityContext {
+	nonRoot, user := true, int64(100)
+	return &corev1.SecurityContext{
+		RunAsNonRoot:             &nonRoot,
+		RunAsUser:                &user,
+		AllowPrivilegeEscalation: new(bool),
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+	}
+}
+
+// startProbePod creates the probe pod, waits until it runs and returns its
+// name with a teardown that deletes it again
+func (k *K8sToolkit) startProbePod(ctx context.Context, namespace string, opts ServiceProbeOptions, m *mutation) (string, func(), error) {
+	pod, err := k.clientset.CoreV1().Pods(namespace).Create(ctx, probePod(namespace, opts), metav1.CreateOptions{})
+	if err != nil {
+		m.record("create", "Pod "+namespace+"/"+probeName, err)
+		return "", nil, fmt.Errorf("failed to create probe pod: %w", err)
+	}
+	target := namespace + "/" + pod.Name
+	m.record("create", "Pod "+target, nil)
+	teardown := func() {
+		// Tear down with a fresh context so an interrupt still cleans up
+		deleteCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		err := k.clientset.CoreV1().Pods(namespace).Delete(deleteCtx, pod.Name, metav1.DeleteOptions{})
+		m.record("delete", "Pod "+target, err)
+		if err != nil {
+			k.log().Warnf("failed to delete probe pod %s: %v", target, err)
+		}
+	}
+
+	err = k.waitForProbeContainer(ctx, namespace, pod.Name, opts.ReadyTimeout, func(pod *corev1.Pod) bool {
+		return pod.Status.Phase == corev1.PodRunning
+	})
+	if err != nil {
+		teardown()
+		return "", nil, err
+	}
+	return pod.Name, teardown, nil
+}
+
+// startEphemeralProbe adds a curl ephemeral container to an existing pod and
+// waits until it runs. Ephemeral containers cannot be removed; it exits on
+// its own after ten minutes and stays listed in the pod spec.
+func (k *K8sToolkit) startEphemeralProbe(ctx context.Context, namespace, name string, opts ServiceProbeOptions, m *mutation) (string, error) {
+	pod, err := k.clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod %s/%s: %w", namespace, name, err)
+	}
+	container := fmt.Sprintf("%s-%d", probeName, time.Now().Unix())
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:            container,
+			Image:           opts.Image,
+			Command:         []string{"sleep", "600"},
+			SecurityContext: probeSecurityContext(),
+		},
+	})
+	_, err = k.clientset.CoreV1().Pods(namespace).UpdateEphemeralContainers(ctx, name, pod, metav1.UpdateOptions{})
+	m.record("add-ephemeral-container", fmt.Sprintf("Pod %s/%s container %s", namespace, name, container), err)
+	if err != nil {
+		return "", fmt.Errorf("failed to add ephemeral container to %s/%s: %w", namespace, name, err)
+	}
+
+	err = k.waitForProbeContainer(ctx, namespace, name, opts.ReadyTimeout, func(pod *corev1.Pod) bool {
+		for _, status := range pod.Status.EphemeralContainerStatuses {
+			if status.Name == container {
+				return status.State.Running != nil
+			}
+		}
+		return false
+	})
+	return container, err
+}
+
+// waitForProbeContainer polls a pod until running reports true
+func (k *K8sToolkit) waitForProbeContainer(ctx context.Context, namespace, name string, timeout time.Duration, running func(*corev1.Pod) bool) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		pod, err := k.clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err == nil && running(pod) {
+			return nil
+		}
+		if err == nil && (pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded) {
+			return fmt.Errorf("probe pod %s/%s exited: %s", namespace, name, pod.Status.Phase)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("probe container in %s/%s not running after %s", namespace, name, timeout)
+		case <-ticker.C:
+		}
+	}
+}
+
+// ProbeService requests every port of a Service through the Service address
+// and on each endpoint from inside the cluster, with curl in a short-lived
+// probe pod in the Service's namespace or an ephemeral container in
+// opts.From, and reports status, latency and TLS details per endpoint
+func (k *K8sToolkit) ProbeService(ctx context.Context, namespace, name string, opts ServiceProbeOptions, m *mutation) (*ServiceProbeReport, error) {
+	svc, err := k.clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get service %s/%s: %w", namespace, name, err)
+	}
+	if svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return nil, fmt.Errorf("service %s/%s is an ExternalName for %s and has no endpoints to probe", namespace, name, svc.Spec.ExternalName)
+	}
+	targets, err := k.serviceProbeTargets(ctx, svc, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("service %s/%s has no TCP ports with endpoints to probe", namespace, name)
+	}
+
+	podNamespace, pod, container := namespace, "", "probe"
+	if opts.From != "" {
+		podNamespace, pod = namespace, opts.From
+		if ns, podName, ok := strings.Cut(opts.From, "/"); ok {
+			podNamespace, pod = ns, podName
+		}
+		if container, err = k.startEphemeralProbe(ctx, podNamespace, pod, opts, m); err != nil {
+			return nil, err
+		}
+	} else {
+		var teardown func()
+		if pod, teardown, err = k.startProbePod(ctx, namespace, opts, m); err != nil {
+			return nil, err
+		}
+		defer teardown()
+	}
+
+	stdout, stderr, err := k.execInPod(ctx, podNamespace, pod, container, []string{"sh", "-c", probeScript(targets, opts.Timeout)})
+	if err != nil && stdout == "" {
+		return nil, fmt.Errorf("failed to run curl in %s/%s: %v %s", podNamespace, pod, err, strings.TrimSpace(stderr))
+	}
+	parseProbeOutput(stdout, targets, fmt.Sprintf("%s.%s.svc", name, namespace))
+
+	sort.SliceStable(targets, func(i, j int) bool { return targets[i].Port < targets[j].Port })
+	return &ServiceProbeReport{
+		Namespace:   namespace,
+		Service:     name,
+		ProbedFrom:  podNamespace + "/" + pod,
+		GeneratedAt: time.Now(),
+		Probes:      targets,
+	}, nil
+}
+
+// PrintServiceProbeReport prints one line per probed URL
+func (k *K8sToolkit) PrintServiceProbeReport(report *ServiceProbeReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Printf("Service Probe: %s/%s (from %s)\n", report.Namespace, report.Service, report.ProbedFrom)
+	fmt.Println("=====================================")
+	fmt.Printf("%-10s %-30s %-45s %-7s %9s %9s  %s\n", "PORT", "TARGET", "URL", "STATUS", "LATENCY", "TLS HS", "TLS")
+	for _, probe := range report.Probes {
+		status := strconv.Itoa(probe.StatusCode)
+		if probe.Error != "" {
+			status = "error"
+		}
+		handshake := "-"
+		if probe.TLSHandshakeMs > 0 {
+			handshake = fmt.Sprintf("%.1fms", probe.TLSHandshakeMs)
+		}
+		tls := "-"
+		if probe.TLS != nil {
+			var notes []string
+			if !probe.TLS.ChainVerified {
+				notes = append(notes, "untrusted")
+			}
+			if !probe.TLS.HostnameMatch {
+				notes = append(notes, "name mismatch")
+			}
+			tls = "expires " + probe.TLS.NotAfter.Format("2006-01-02")
+			if len(notes) > 0 {
+				tls += " (" + strings.Join(notes, ", ") + ")"
+			}
+		}
+		fmt.Printf("%-10s %-30s %-45s %-7s %9s %9s  %s\n", probe.Port, probe.Target, probe.URL, status,
+			fmt.Sprintf("%.1fms", probe.LatencyMs), handshake, tls)
+		if probe.Error != "" {
+			fmt.Printf("           %s\n", probe.Error)
+		}
+	}
+}
+
+// createProbeCmd creates the probe command
+func createProbeCmd() *cobra.Command {
+	probeCmd := &cobra.Command{
+		Use:   "probe",
+		Short: "Probe endpoints from inside the cluster",
+	}
+	probeCmd.AddCommand(createProbeServiceCmd())
+	return probeCmd
+}
+
+// createProbeServiceCmd creates the probe service command
+func createProbeServiceCmd() *cobra.Command {
+	opts := ServiceProbeOptions{}
+
+	serviceCmd := &cobra.Command{
+		Use:   "service <namespace>/<service>",
+		Short: "Request every port of a Service from inside the cluster",
+		Long: `Automates "spin up a debug pod and curl": starts a short-lived curl pod in the Service's
+namespace, requests every TCP port of the Service through its DNS name and directly on each
+endpoint, and reports the HTTP status, latency and TLS handshake time and certificate per
+endpoint, then deletes the pod. Ports named or with an appProtocol mentioning https or tls, and
+ports 443 and 8443, are requested over HTTPS unless --scheme is set. Endpoints that are not ready
+are listed but not requested.
+
+With --from <pod> the requests are made from an ephemeral container added to that pod instead, so
+they carry its identity and network policies. Ephemeral containers cannot be removed; it exits
+after ten minutes but stays in the pod spec until the pod is replaced.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			namespace, name, ok := strings.Cut(args[0], "/")
+			if !ok {
+				namespace, name = toolkit.namespace, args[0]
+			}
+			if namespace == "" {
+				namespace = "default"
+			}
+			if opts.Scheme != "auto" && opts.Scheme != "http" && opts.Scheme != "https" {
+				logger.Fatalf("Invalid --scheme %q (use auto, http or https)", opts.Scheme)
+			}
+			if !strings.HasPrefix(opts.Path, "/") {
+				opts.Path = "/" + opts.Path
+			}
+
+			summary := fmt.Sprintf("About to create a probe pod in %s and exec curl in it.", namespace)
+			if opts.From != "" {
+				summary = fmt.Sprintf("About to add an ephemeral container to pod %s and exec curl in it.", opts.From)
+			}
+			m, err := beginMutation("probe", summary)
+			if err != nil {
+				logger.Fatalf("Probe aborted: %v", err)
+			}
+
+			// Cancel on interrupt so the probe pod is still deleted
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			report, err := toolkit.ProbeService(ctx, namespace, name, opts, m)
+			if err != nil {
+				logger.Fatalf("Failed to probe service: %v", err)
+			}
+			toolkit.PrintServiceProbeReport(report)
+		},
+	}
+
+	serviceCmd.Flags().StringVar(&opts.Image, "image", "curlimages/curl:8.5.0", "Probe image; must provide sh and curl 8.2 or later")
+	serviceCmd.Flags().StringVar(&opts.Path, "path", "/", "Path to request on every endpoint")
+	serviceCmd.Flags().StringVar(&opts.Scheme, "scheme", "auto", "Scheme to request with (auto|http|https)")
+	serviceCmd.Flags().DurationVar(&opts.Timeout, "timeout", 5*time.Second, "Timeout of each request")
+	serviceCmd.Flags().DurationVar(&opts.ReadyTimeout, "ready-timeout", time.Minute, "Time to wait for the probe container to start")
+	serviceCmd.Flags().StringVar(&opts.From, "from", "", "Probe from an ephemeral container in this pod ([namespace/]pod) instead of a new pod")
+
+	return serviceCmd
+}
@@ -0,0 +1,701 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var (
+	argoRolloutResource   = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+	argoAnalysisResource  = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "analysisruns"}
+	flaggerCanaryResource = schema.GroupVersionResource{Group: "flagger.app", Version: "v1beta1", Resource: "canaries"}
+)
+
+// Progressive delivery controllers
+const (
+	controllerArgoRollouts = "Argo Rollouts"
+	controllerFlagger      = "Flagger"
+)
+
+// maxMeasurementValues bounds the measurement values reported per failed metric
+const maxMeasurementValues = 3
+
+// FailedMetric is a metric check that failed an analysis, with the query
+// that was run and the values it returned
+type FailedMetric struct {
+	Analysis string   `json:"analysis,omitempty"`
+	Metric   string   `json:"metric"`
+	Phase    string   `json:"phase"`
+	Query    string   `json:"query,omitempty"`
+	Values   []string `json:"values,omitempty"`
+	Message  string   `json:"message,omitempty"`
+}
+
+// CanaryStatus is the state of an Argo Rollout or Flagger Canary
+type CanaryStatus struct {
+	Controller string         `json:"controller"`
+	Kind       string         `json:"kind"`
+	Namespace  string         `json:"namespace"`
+	Name       string         `json:"name"`
+	Phase      string         `json:"phase"`
+	Message    string         `json:"message,omitempty"`
+	Weight     int64          `json:"weight"`
+	Step       string         `json:"step,omitempty"`
+	Since      *time.Time     `json:"since,omitempty"`
+	Stuck      bool           `json:"stuck"`
+	Failed     bool           `json:"failed"`
+	Metrics    []FailedMetric `json:"failed_metrics,omitempty"`
+}
+
+func (c CanaryStatus) ref() objectRef {
+	return objectRef{Kind: c.Kind, Namespace: c.Namespace, Name: c.Name}
+}
+
+// ProgressiveReport lists the canaries of every installed controller
+type ProgressiveReport struct {
+	Controllers []string       `json:"controllers"`
+	StuckAfter  string         `json:"stuck_after"`
+	Canaries    []CanaryStatus `json:"canaries"`
+}
+
+// nestedTime reads an RFC 3339 timestamp field, returning nil when it is
+// missing or invalid
+func nestedTime(obj map[string]interface{}, fields ...string) *time.Time {
+	value, _, _ := unstructured.NestedString(obj, fields...)
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// metricQuery returns the query of an analysis metric from whichever
+// provider it uses (Prometheus, Datadog, New Relic, ...), or the URL of a web
+// metric
+func metricQuery(metric map[string]interface{}) string {
+	if query, ok := metric["query"].(string); ok {
+		return query
+	}
+	provider, _, _ := unstructured.NestedMap(metric, "provider")
+	names := make([]string, 0, len(provider))
+	for name := range provider {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		config, _ := provider[name].(map[string]interface{})
+		for _, field := range []string{"query", "url"} {
+			if value, ok := config[field].(string); ok && value != "" {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	return ""
+}
+
+// analysisFailures returns the metrics of an AnalysisRun that failed, erred
+// or were inconclusive, with the values of their unsuccessful measurements
+func analysisFailures(run *unstructured.Unstructured) []FailedMetric {
+	queries := make(map[string]string)
+	metrics, _, _ := unstructured.NestedSlice(run.Object, "spec", "metrics")
+	for _, m := range metrics {
+		metric, _ := m.(map[string]interface{})
+		name, _ := metric["name"].(string)
+		queries[name] = metricQuery(metric)
+	}
+
+	var failed []FailedMetric
+	results, _, _ := unstructured.NestedSlice(run.Object, "status", "metricResults")
+	for _, r := range results {
+		result, _ := r.(map[string]interface{})
+		phase, _ := result["phase"].(string)
+		if phase != "Failed" && phase != "Error" && phase != "Inconclusive" {
+			continue
+		}
+		name, _ := result["name"].(string)
+		message, _ := result["message"].(string)
+		fm := FailedMetric{Analysis: run.GetName(), Metric: name, Phase: phase, Query: queries[name], Message: message}
+
+		measurements, _ := result["measurements"].([]interface{})
+		for i := len(measurements) - 1; i >= 0 && len(fm.Values) < maxMeasurementValues; i-- {
+			measurement, _ := measurements[i].(map[string]interface{})
+			if measurement["phase"] == "Successful" {
+				continue
+			}
+			value, _ := measurement["value"].(string)
+			if value == "" {
+				value, _ = measurement["message"].(string)
+			}
+			if value != "" {
+				fm.Values = append(fm.Values, value)
+			}
+		}
+		failed = append(failed, fm)
+	}
+	return failed
+}
+
+// argoRolloutStatuses reads every Argo Rollout and the metric failures of
+// its latest AnalysisRun. It returns false when Argo Rollouts is not installed.
+func (k *K8sToolkit) argoRolloutStatuses(ctx context.Context, stuckAfter time.Duration) ([]CanaryStatus, bool, error) {
+	rollouts, err := k.dynamicClient.Resource(argoRolloutResource).Namespace(k.namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to list rollouts: %w", err)
+	}
+	runs, err := k.dynamicClient.Resource(argoAnalysisResource).Namespace(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, true, fmt.Errorf("failed to list analysis runs: %w", err)
+	}
+
+	// Latest AnalysisRun of each rollout, by controller owner reference
+	latestRun := make(map[string]*unstructured.Unstructured)
+	if runs != nil {
+		for i := range runs.Items {
+			run := &runs.Items[i]
+			owner := metav1.GetControllerOf(run)
+			if owner == nil || owner.Kind != "Rollout" {
+				continue
+			}
+			key := run.GetNamespace() + "/" + owner.Name
+			if current := latestRun[key]; current == nil || run.GetCreationTimestamp().After(current.GetCreationTimestamp().Time) {
+				latestRun[key] = run
+			}
+		}
+	}
+
+	var statuses []CanaryStatus
+	for _, rollout := range inScopeItems(k, rollouts.Items) {
+		obj := rollout.Object
+		status := CanaryStatus{
+			Controller: controllerArgoRollouts,
+			Kind:       "Rollout",
+			Namespace:  rollout.GetNamespace(),
+			Name:       rollout.GetName(),
+		}
+		status.Phase, _, _ = unstructured.NestedString(obj, "status", "phase")
+		status.Message, _, _ = unstructured.NestedString(obj, "status", "message")
+		status.Weight, _, _ = unstructured.NestedInt64(obj, "status", "canary", "weights", "canary", "weight")
+		steps, _, _ := unstructured.NestedSlice(obj, "spec", "strategy", "canary", "steps")
+		if index, found, _ := unstructured.NestedInt64(obj, "status", "currentStepIndex"); found && len(steps) > 0 {
+			status.Step = fmt.Sprintf("%d/%d", index, len(steps))
+		}
+		aborted, _, _ := unstructured.NestedBool(obj, "status", "abort")
+		status.Failed = aborted || status.Phase == "Degraded"
+
+		run := latestRun[status.Namespace+"/"+status.Name]
+		if run != nil {
+			status.Metrics = analysisFailures(run)
+		}
+
+		// A rollout is stuck when it has been paused, or its current analysis
+		// has been running, for longer than stuckAfter
+		if pauses, _, _ := unstructured.NestedSlice(obj, "status", "pauseConditions"); len(pauses) > 0 {
+			pause, _ := pauses[0].(map[string]interface{})
+			status.Since = nestedTime(pause, "startTime")
+			if reason, _ := pause["reason"].(string); reason != "" && status.Message == "" {
+				status.Message = "paused: " + reason
+			}
+		} else if run != nil {
+			if phase, _, _ := unstructured.NestedString(run.Object, "status", "phase"); phase == "Running" || phase == "Pending" {
+				status.Since = nestedTime(run.Object, "status", "startedAt")
+				if status.Since == nil {
+					created := run.GetCreationTimestamp().Time
+					status.Since = &created
+				}
+				status.Message = strings.TrimSpace(status.Message + " analysis " + run.GetName() + " " + strings.ToLower(phase))
+			}
+		}
+		status.Stuck = !status.Failed && status.Since != nil && time.Since(*status.Since) > stuckAfter
+		statuses = append(statuses, status)
+	}
+	return statuses, true, nil
+}
+
+// flaggerCanaryStatuses reads every Flagger Canary. Flagger does not keep
+// query results, so failed metrics carry the metric's query or template and
+// the message of the Promoted condition. It returns false when Flagger is not
+// installed.
+func (k *K8sToolkit) flaggerCanaryStatuses(ctx context.Context, stuckAfter time.Duration) ([]CanaryStatus, bool, error) {
+	canaries, err := k.dynamicClient.Resource(flaggerCanaryResource).Namespace(k.namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to list canaries: %w", err)
+	}
+
+	var statuses []CanaryStatus
+	for _, canary := range inScopeItems(k, canaries.Items) {
+		obj := canary.Object
+		status := CanaryStatus{
+			Controller: controllerFlagger,
+			Kind:       "Canary",
+			Namespace:  canary.GetNamespace(),
+			Name:       canary.GetName(),
+		}
+		status.Phase, _, _ = unstructured.NestedString(obj, "status", "phase")
+		status.Weight, _, _ = unstructured.NestedInt64(obj, "status", "canaryWeight")
+		status.Since = nestedTime(obj, "status", "lastTransitionTime")
+		status.Failed = status.Phase == "Failed"
+
+		conditions, _, _ := unstructured.NestedSlice(obj, "status", "conditions")
+		for _, c := range conditions {
+			condition, _ := c.(map[string]interface{})
+			if condition["type"] == "Promoted" {
+				status.Message, _ = condition["message"].(string)
+			}
+		}
+
+		failedChecks, _, _ := unstructured.NestedInt64(obj, "status", "failedChecks")
+		threshold, _, _ := unstructured.NestedInt64(obj, "spec", "analysis", "threshold")
+		if failedChecks > 0 {
+			status.Step = fmt.Sprintf("%d/%d failed checks", failedChecks, threshold)
+			metrics, _, _ := unstructured.NestedSlice(obj, "spec", "analysis", "metrics")
+			for _, m := range metrics {
+				metric, _ := m.(map[string]interface{})
+				name, _ := metric["name"].(string)
+				query := metricQuery(metric)
+				if query == "" {
+					if template, _, _ := unstructured.NestedString(metric, "templateRef", "name"); template != "" {
+						query = "template " + template
+					}
+				}
+				fm := FailedMetric{Metric: name, Phase: "Failed", Query: query}
+				if strings.Contains(status.Message, name) {
+					fm.Message = status.Message
+				}
+				status.Metrics = append(status.Metrics, fm)
+			}
+		}
+
+		switch status.Phase {
+		case "Progressing", "Waiting", "WaitingPromotion", "Promoting", "Finalising":
+			status.Stuck = status.Since != nil && time.Since(*status.Since) > stuckAfter
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, true, nil
+}
+
+// ProgressiveDelivery reports the Argo Rollouts and Flagger canaries in
+// --namespace. Canaries progressing or paused for longer than stuckAfter are
+// marked stuck.
+func (k *K8sToolkit) ProgressiveDelivery(ctx context.Context, stuckAfter time.Duration) (*ProgressiveReport, error) {
+	report := &ProgressiveReport{StuckAfter: stuckAfter.String()}
+	if k.dynamicClient == nil {
+		return report, nil
+	}
+
+	for _, list := range []struct {
+		controller string
+		statuses   func(context.Context, time.Duration) ([]CanaryStatus, bool, error)
+	}{
+		{controllerArgoRollouts, k.argoRolloutStatuses},
+		{controllerFlagger, k.flaggerCanaryStatuses},
+	} {
+		statuses, installed, err := list.statuses(ctx, stuckAfter)
+		if err != nil {
+			return nil, err
+		}
+		if installed {
+			report.Controllers = append(report.Controllers, list.controller)
+			report.Canaries = append(report.Canaries, statuses...)
+		}
+	}
+
+	sort.Slice(report.Canaries, func(i, j int) bool {
+		a, b := report.Canaries[i], report.Canaries[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return report, nil
+}
+
+// CheckProgressiveDelivery reports aborted or failed canaries as Critical
+// and canaries stuck in analysis or awaiting promotion as Warning. It is
+// Healthy when neither controller is installed.
+func (k *K8sToolkit) CheckProgressiveDelivery(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Progressive Delivery",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	report, err := k.ProgressiveDelivery(ctx, viper.GetDuration("progressive.stuck_after"))
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to read canaries: %v", err)
+		result.Err = err
+		return result
+	}
+	if len(report.Controllers) == 0 {
+		result.Status = "Healthy"
+		result.Message = "No Argo Rollouts or Flagger resources found"
+		return result
+	}
+
+	var failed, stuck []string
+	for _, c := range report.Canaries {
+		problem := c.ref().String() + " " + c.Phase
+		for _, m := range c.Metrics {
+			problem += fmt.Sprintf(" (%s %s", m.Metric, strings.ToLower(m.Phase))
+			if len(m.Values) > 0 {
+				problem += ": " + strings.Join(m.Values, ", ")
+			}
+			problem += ")"
+		}
+		switch {
+		case c.Failed:
+			failed = append(failed, problem)
+		case c.Stuck:
+			stuck = append(stuck, problem+" since "+c.Since.Format(time.RFC3339))
+		default:
+			continue
+		}
+		result.Affected = append(result.Affected, c.ref())
+	}
+
+	result.Details["controllers"] = strings.Join(report.Controllers, ", ")
+	result.Details["total"] = strconv.Itoa(len(report.Canaries))
+	result.Details["failed"] = strconv.Itoa(len(failed))
+	result.Details["stuck"] = strconv.Itoa(len(stuck))
+	if len(failed)+len(stuck) > 0 {
+		result.Details["problems"] = strings.Join(append(failed, stuck...), "; ")
+	}
+
+	switch {
+	case len(failed) > 0:
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("%d of %d canaries failed or were aborted", len(failed), len(report.Canaries))
+	case len(stuck) > 0:
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d of %d canaries stuck for more than %s", len(stuck), len(report.Canaries), report.StuckAfter)
+	default:
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("All %d canaries are progressing normally", len(report.Canaries))
+	}
+	return result
+}
+
+// parseCanaryRef parses rollout/name or canary/name
+func parseCanaryRef(namespace, ref string) (objectRef, error) {
+	kind, name, ok := strings.Cut(ref, "/")
+	if !ok || name == "" {
+		return objectRef{}, fmt.Errorf("invalid canary %q, expected rollout/name or canary/name", ref)
+	}
+	switch strings.ToLower(kind) {
+	case "rollout", "rollouts", "ro":
+		kind = "Rollout"
+	case "canary", "canaries":
+		kind = "Canary"
+	default:
+		return objectRef{}, fmt.Errorf("unsupported canary kind %q", kind)
+	}
+	return objectRef{Kind: kind, Namespace: namespace, Name: name}, nil
+}
+
+// canaryStatus reads the current status of one canary
+func (k *K8sToolkit) canaryStatus(ctx context.Context, ref objectRef) (*CanaryStatus, error) {
+	scoped := *k
+	scoped.namespace = ref.Namespace
+	read := scoped.argoRolloutStatuses
+	if ref.Kind == "Canary" {
+		read = scoped.flaggerCanaryStatuses
+	}
+	statuses, installed, err := read(ctx, viper.GetDuration("progressive.stuck_after"))
+	if err != nil {
+		return nil, err
+	}
+	if !installed {
+		return nil, fmt.Errorf("the %s custom resource is not installed", ref.Kind)
+	}
+	for i := range statuses {
+		if statuses[i].Name == ref.Name {
+			return &statuses[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s not found", ref)
+}
+
+// PromoteRollout resumes a paused Argo Rollout, or skips its current step
+// when it is not paused. With full, every remaining step and analysis is
+// skipped.
+func (k *K8sToolkit) PromoteRollout(ctx context.Context, ref objectRef, full bool) error {
+	client := k.dynamicClient.Resource(argoRolloutResource).Namespace(ref.Namespace)
+	if full {
+		_, err := client.Patch(ctx, ref.Name, types.MergePatchType, []byte(`{"status":{"promoteFull":true}}`), metav1.PatchOptions{}, "status")
+		return err
+	}
+
+	rollout, err := client.Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	paused, _, _ := unstructured.NestedBool(rollout.Object, "spec", "paused")
+	pauses, _, _ := unstructured.NestedSlice(rollout.Object, "status", "pauseConditions")
+	if !paused && len(pauses) == 0 {
+		index, _, _ := unstructured.NestedInt64(rollout.Object, "status", "currentStepIndex")
+		patch := fmt.Sprintf(`{"status":{"currentStepIndex":%d}}`, index+1)
+		_, err = client.Patch(ctx, ref.Name, types.MergePatchType, []byte(patch), metav1.PatchOptions{}, "status")
+		return err
+	}
+	if paused {
+		if _, err := client.Patch(ctx, ref.Name, types.MergePatchType, []byte(`{"spec":{"paused":false}}`), metav1.PatchOptions{}); err != nil {
+			return err
+		}
+	}
+	_, err = client.Patch(ctx, ref.Name, types.MergePatchType, []byte(`{"status":{"pauseConditions":null}}`), metav1.PatchOptions{}, "status")
+	return err
+}
+
+// AbortRollout aborts an Argo Rollout, scaling the canary down and sending
+// all traffic back to the stable version
+func (k *K8sToolkit) AbortRollout(ctx context.Context, ref objectRef) error {
+	_, err := k.dynamicClient.Resource(argoRolloutResource).Namespace(ref.Namespace).
+		Patch(ctx, ref.Name, types.MergePatchType, []byte(`{"status":{"abort":true}}`), metav1.PatchOptions{}, "status")
+	return err
+}
+
+// openFlaggerGate opens a Flagger load tester gate for a canary. Flagger has
+// no API to promote or roll back a canary directly; it asks the webhooks of
+// type confirm-promotion and rollback, which the load tester answers from
+// gates opened at /gate/open and /rollback/open. The load tester is reached
+// through the API server service proxy, so it need not be exposed.
+func (k *K8sToolkit) openFlaggerGate(ctx context.Context, ref objectRef, webhookType string) error {
+	canary, err := k.dynamicClient.Resource(flaggerCanaryResource).Namespace(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	var hookURL string
+	webhooks, _, _ := unstructured.NestedSlice(canary.Object, "spec", "analysis", "webhooks")
+	for _, w := range webhooks {
+		webhook, _ := w.(map[string]interface{})
+		if webhook["type"] == webhookType {
+			hookURL, _ = webhook["url"].(string)
+			break
+		}
+	}
+	if hookURL == "" {
+		return fmt.Errorf("%s has no %s webhook; add one pointing at the Flagger load tester to gate it from the CLI", ref, webhookType)
+	}
+
+	u, err := url.Parse(hookURL)
+	if err != nil {
+		return fmt.Errorf("invalid %s webhook URL %q: %w", webhookType, hookURL, err)
+	}
+	// http://<service>.<namespace>[.svc.cluster.local][:port]/gate/check
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host, port = u.Host, "80"
+	}
+	service, namespace, _ := strings.Cut(host, ".")
+	namespace, _, _ = strings.Cut(namespace, ".")
+	if namespace == "" {
+		namespace = ref.Namespace
+	}
+	path := "/gate/open"
+	if webhookType == "rollback" {
+		path = "/rollback/open"
+	}
+
+	body, _ := json.Marshal(map[string]string{"name": ref.Name, "namespace": ref.Namespace})
+	return k.clientset.CoreV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("services").
+		Name(service + ":" + port).
+		SubResource("proxy").
+		Suffix(path).
+		Body(body).
+		Do(ctx).
+		Error()
+}
+
+// PrintProgressiveReport prints the canaries and the failed metrics behind them
+func (k *K8sToolkit) PrintProgressiveReport(report *ProgressiveReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	if len(report.Controllers) == 0 {
+		fmt.Println("No Argo Rollouts or Flagger resources found")
+		return
+	}
+	fmt.Printf("Progressive Delivery (%s)\n", strings.Join(report.Controllers, ", "))
+	fmt.Printf("%-50s %-18s %6s %-20s %s\n", "CANARY", "PHASE", "WEIGHT", "STEP", "STATE")
+	for _, c := range report.Canaries {
+		state := ""
+		switch {
+		case c.Failed:
+			state = "FAILED"
+		case c.Stuck:
+			state = "STUCK since " + c.Since.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-50s %-18s %5d%% %-20s %s\n", c.ref(), c.Phase, c.Weight, c.Step, state)
+		if c.Message != "" && (c.Failed || c.Stuck) {
+			fmt.Printf("    %s\n", c.Message)
+		}
+		for _, m := range c.Metrics {
+			fmt.Printf("    metric %s %s", m.Metric, strings.ToLower(m.Phase))
+			if m.Analysis != "" {
+				fmt.Printf(" in %s", m.Analysis)
+			}
+			fmt.Println()
+			if m.Query != "" {
+				fmt.Printf("      query:  %s\n", m.Query)
+			}
+			if len(m.Values) > 0 {
+				fmt.Printf("      values: %s\n", strings.Join(m.Values, ", "))
+			}
+			if m.Message != "" && m.Message != c.Message {
+				fmt.Printf("      %s\n", m.Message)
+			}
+		}
+	}
+}
+
+// createCanaryCmd creates the canary command
+func createCanaryCmd() *cobra.Command {
+	canaryCmd := &cobra.Command{
+		Use:   "canary",
+		Short: "Inspect, promote and abort Argo Rollouts and Flagger canaries",
+	}
+
+	var stuckAfter time.Duration
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "List canaries, those stuck in analysis and the metric checks that failed",
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			if stuckAfter <= 0 {
+				stuckAfter = viper.GetDuration("progressive.stuck_after")
+			}
+			report, err := toolkit.ProgressiveDelivery(context.Background(), stuckAfter)
+			if err != nil {
+				logger.Fatalf("Failed to read canaries: %v", err)
+			}
+			toolkit.PrintProgressiveReport(report)
+		},
+	}
+	statusCmd.Flags().DurationVar(&stuckAfter, "stuck-after", 0, "Mark canaries paused or in analysis for longer than this as stuck (default progressive.stuck_after, 30m)")
+
+	resolve := func(arg string) (*K8sToolkit, objectRef) {
+		toolkit, err := NewK8sToolkit()
+		if err != nil {
+			logger.Fatalf("Failed to initialize toolkit: %v", err)
+		}
+		namespace := toolkit.namespace
+		if namespace == "" {
+			namespace = "default"
+		}
+		ref, err := parseCanaryRef(namespace, arg)
+		if err != nil {
+			logger.Fatalf("Invalid canary: %v", err)
+		}
+		return toolkit, ref
+	}
+
+	var full, force bool
+	promoteCmd := &cobra.Command{
+		Use:   "promote <rollout/name|canary/name>",
+		Short: "Promote a canary past its current pause or gate",
+		Long: `Resumes a paused Argo Rollout (or skips its current step), or opens the confirm-promotion gate
+of a Flagger Canary through its load tester. Promotion is refused while the canary has failed or its
+latest analysis has failed metrics, unless --force is given. Every promotion is written to the audit log.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, ref := resolve(args[0])
+			ctx := context.Background()
+
+			status, err := toolkit.canaryStatus(ctx, ref)
+			if err != nil {
+				logger.Fatalf("Failed to read %s: %v", ref, err)
+			}
+			if (status.Failed || len(status.Metrics) > 0) && !force {
+				toolkit.PrintProgressiveReport(&ProgressiveReport{Controllers: []string{status.Controller}, Canaries: []CanaryStatus{*status}})
+				logger.Fatalf("Refusing to promote %s: its analysis has failed; re-run with --force to override", ref)
+			}
+			if full && ref.Kind == "Canary" {
+				logger.Fatalf("--full applies to Argo Rollouts only")
+			}
+
+			m, err := beginMutation("canary promote", fmt.Sprintf("About to promote %s (phase %s, weight %d%%).", ref, status.Phase, status.Weight))
+			if err != nil {
+				logger.Fatalf("Promotion aborted: %v", err)
+			}
+			action := "promote"
+			if ref.Kind == "Canary" {
+				err = toolkit.openFlaggerGate(ctx, ref, "confirm-promotion")
+			} else {
+				if full {
+					action = "promote-full"
+				}
+				err = toolkit.PromoteRollout(ctx, ref, full)
+			}
+			m.record(action, ref.String(), err)
+			if err != nil {
+				logger.Fatalf("Failed to promote %s: %v", ref, err)
+			}
+			fmt.Printf("Promoted %s\n", ref)
+		},
+	}
+	promoteCmd.Flags().BoolVar(&full, "full", false, "Skip all remaining steps and analyses of an Argo Rollout")
+	promoteCmd.Flags().BoolVar(&force, "force", false, "Promote even though the canary's analysis has failed")
+
+	abortCmd := &cobra.Command{
+		Use:   "abort <rollout/name|canary/name>",
+		Short: "Abort a canary and return traffic to the stable version",
+		Long: `Aborts an Argo Rollout, or opens the rollback gate of a Flagger Canary through its load tester.
+Every abort is written to the audit log.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, ref := resolve(args[0])
+			ctx := context.Background()
+
+			m, err := beginMutation("canary abort", fmt.Sprintf("About to abort %s and return all traffic to the stable version.", ref))
+			if err != nil {
+				logger.Fatalf("Abort cancelled: %v", err)
+			}
+			if ref.Kind == "Canary" {
+				err = toolkit.openFlaggerGate(ctx, ref, "rollback")
+			} else {
+				err = toolkit.AbortRollout(ctx, ref)
+			}
+			m.record("abort", ref.String(), err)
+			if err != nil {
+				logger.Fatalf("Failed to abort %s: %v", ref, err)
+			}
+			fmt.Printf("Aborted %s\n", ref)
+		},
+	}
+
+	canaryCmd.AddCommand(statusCmd, promoteCmd, abortCmd)
+	return canaryCmd
+}
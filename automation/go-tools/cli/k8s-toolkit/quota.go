@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// QuotaUsage is one resource of a ResourceQuota
+type QuotaUsage struct {
+	Quota    string  `json:"quota"`
+	Resource string  `json:"resource"`
+	Hard     string  `json:"hard"`
+	Used     string  `json:"used"`
+	Percent  float64 `json:"percent"`
+}
+
+// LimitRangeDefault is the container defaults and bounds of a LimitRange
+type LimitRangeDefault struct {
+	LimitRange     string `json:"limit_range"`
+	Type           string `json:"type"`
+	Resource       string `json:"resource"`
+	Default        string `json:"default,omitempty"`
+	DefaultRequest string `json:"default_request,omitempty"`
+	Min            string `json:"min,omitempty"`
+	Max            string `json:"max,omitempty"`
+}
+
+// NamespaceQuota is the quota, request and LimitRange picture of a namespace
+type NamespaceQuota struct {
+	Namespace       string              `json:"namespace"`
+	Quotas          []QuotaUsage        `json:"quotas,omitempty"`
+	MaxPercent      float64             `json:"max_quota_percent"`
+	NearQuota       bool                `json:"near_quota"`
+	CPURequested    int64               `json:"cpu_requested_millicores"`
+	MemoryRequested int64               `json:"memory_requested_bytes"`
+	CPUShare        float64             `json:"cpu_percent_of_allocatable"`
+	MemoryShare     float64             `json:"memory_percent_of_allocatable"`
+	LimitRanges     []LimitRangeDefault `json:"limit_ranges,omitempty"`
+}
+
+// QuotaReport is the per-namespace quota report
+type QuotaReport struct {
+	CPUAllocatable    int64            `json:"cpu_allocatable_millicores"`
+	MemoryAllocatable int64            `json:"memory_allocatable_bytes"`
+	WarnPercent       float64          `json:"warn_percent"`
+	Namespaces        []NamespaceQuota `json:"namespaces"`
+}
+
+// quotaPercent returns used as a percentage of hard
+func quotaPercent(used, hard resource.Quantity) float64 {
+	if hard.IsZero() {
+		if used.IsZero() {
+			return 0
+		}
+		return 100
+	}
+	return float64(used.MilliValue()) / float64(hard.MilliValue()) * 100
+}
+
+// BuildQuotaReport compares ResourceQuota usage with its limits, sums pod requests
+// against the allocatable capacity of all nodes and lists LimitRange
+// defaults for every namespace. Namespaces with any quota resource at or
+// above warnPercent are marked near quota and sorted first.
+func (k *K8sToolkit) BuildQuotaReport(ctx context.Context, warnPercent float64) (*QuotaReport, error) {
+	report := &QuotaReport{WarnPercent: warnPercent}
+
+	nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	for _, node := range nodes.Items {
+		report.CPUAllocatable += node.Status.Allocatable.Cpu().MilliValue()
+		report.MemoryAllocatable += node.Status.Allocatable.Memory().Value()
+	}
+
+	byNamespace := make(map[string]*NamespaceQuota)
+	get := func(namespace string) *NamespaceQuota {
+		if byNamespace[namespace] == nil {
+			byNamespace[namespace] = &NamespaceQuota{Namespace: namespace}
+		}
+		return byNamespace[namespace]
+	}
+
+	if k.namespace != "" {
+		get(k.namespace)
+	} else {
+		namespaces, err := k.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		for _, ns := range namespaces.Items {
+			get(ns.Name)
+		}
+	}
+
+	quotas, err := k.clientset.CoreV1().ResourceQuotas(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource quotas: %w", err)
+	}
+	for _, quota := range quotas.Items {
+		nq := get(quota.Namespace)
+		for name, hard := range quota.Status.Hard {
+			used := quota.Status.Used[name]
+			usage := QuotaUsage{
+				Quota:    quota.Name,
+				Resource: string(name),
+				Hard:     hard.String(),
+				Used:     used.String(),
+				Percent:  quotaPercent(used, hard),
+			}
+			nq.Quotas = append(nq.Quotas, usage)
+			if usage.Percent > nq.MaxPercent {
+				nq.MaxPercent = usage.Percent
+			}
+		}
+	}
+
+	err = k.eachPod(ctx, k.namespace, metav1.ListOptions{}, func(pod *corev1.Pod) error {
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			return nil
+		}
+		cpu, _, memory, _ := podResources(pod)
+		nq := get(pod.Namespace)
+		nq.CPURequested += cpu
+		nq.MemoryRequested += memory
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	limitRanges, err := k.clientset.CoreV1().LimitRanges(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list limit ranges: %w", err)
+	}
+	for _, lr := range limitRanges.Items {
+		nq := get(lr.Namespace)
+		for _, item := range lr.Spec.Limits {
+			names := make(map[corev1.ResourceName]bool)
+			for _, list := range []corev1.ResourceList{item.Default, item.DefaultRequest, item.Min, item.Max} {
+				for name := range list {
+					names[name] = true
+				}
+			}
+			for name := range names {
+				d := LimitRangeDefault{LimitRange: lr.Name, Type: string(item.Type), Resource: string(name)}
+				if q, ok := item.Default[name]; ok {
+					d.Default = q.String()
+				}
+				if q, ok := item.DefaultRequest[name]; ok {
+					d.DefaultRequest = q.String()
+				}
+				if q, ok := item.Min[name]; ok {
+					d.Min = q.String()
+				}
+				if q, ok := item.Max[name]; ok {
+					d.Max = q.String()
+				}
+				nq.LimitRanges = append(nq.LimitRanges, d)
+			}
+		}
+	}
+
+	for _, nq := range byNamespace {
+		if !k.inScope(nq.Namespace) {
+			continue
+		}
+		nq.CPUShare = percentOf(nq.CPURequested, report.CPUAllocatable)
+		nq.MemoryShare = percentOf(nq.MemoryRequested, report.MemoryAllocatable)
+		nq.NearQuota = len(nq.Quotas) > 0 && nq.MaxPercent >= warnPercent
+		sort.Slice(nq.Quotas, func(i, j int) bool {
+			if nq.Quotas[i].Quota != nq.Quotas[j].Quota {
+				return nq.Quotas[i].Quota < nq.Quotas[j].Quota
+			}
+			return nq.Quotas[i].Resource < nq.Quotas[j].Resource
+		})
+		sort.Slice(nq.LimitRanges, func(i, j int) bool {
+			a, b := nq.LimitRanges[i], nq.LimitRanges[j]
+			if a.LimitRange != b.LimitRange {
+				return a.LimitRange < b.LimitRange
+			}
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+			return a.Resource < b.Resource
+		})
+		report.Namespaces = append(report.Namespaces, *nq)
+	}
+
+	sort.Slice(report.Namespaces, func(i, j int) bool {
+		a, b := report.Namespaces[i], report.Namespaces[j]
+		if a.MaxPercent != b.MaxPercent {
+			return a.MaxPercent > b.MaxPercent
+		}
+		return a.Namespace < b.Namespace
+	})
+	return report, nil
+}
+
+// PrintQuotaReport prints the quota report, marking namespaces near quota
+func (k *K8sToolkit) PrintQuotaReport(report *QuotaReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Println("Namespace Quota Report")
+	fmt.Println("=====================================")
+	fmt.Printf("Cluster allocatable: %dm CPU, %s memory\n", report.CPUAllocatable, formatMemory(report.MemoryAllocatable))
+
+	for _, nq := range report.Namespaces {
+		marker := ""
+		if nq.NearQuota {
+			marker = fmt.Sprintf(" ⚠️  %.0f%% of quota", nq.MaxPercent)
+		}
+		fmt.Printf("\n%s%s\n", nq.Namespace, marker)
+		fmt.Printf("  Requests: %dm CPU (%.1f%% of cluster), %s memory (%.1f%% of cluster)\n",
+			nq.CPURequested, nq.CPUShare, formatMemory(nq.MemoryRequested), nq.MemoryShare)
+
+		if len(nq.Quotas) == 0 {
+			fmt.Println("  No ResourceQuota")
+		}
+		for _, q := range nq.Quotas {
+			flag := ""
+			if q.Percent >= report.WarnPercent {
+				flag = " ⚠️"
+			}
+			fmt.Printf("  %-20s %-28s %12s / %-12s %5.0f%%%s\n", q.Quota, q.Resource, q.Used, q.Hard, q.Percent, flag)
+		}
+
+		for _, d := range nq.LimitRanges {
+			fmt.Printf("  LimitRange %s %s %s: default=%s defaultRequest=%s min=%s max=%s\n",
+				d.LimitRange, d.Type, d.Resource, orDash(d.Default), orDash(d.DefaultRequest), orDash(d.Min), orDash(d.Max))
+		}
+	}
+}
+
+// orDash returns "-" for empty values in table output
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// createQuotaCmd creates the quota command
+func createQuotaCmd() *cobra.Command {
+	var warnPercent float64
+	var failNear bool
+
+	quotaCmd := &cobra.Command{
+		Use:   "quota",
+		Short: "Show ResourceQuota usage, pod requests and LimitRange defaults per namespace",
+		Long: `For every namespace, shows ResourceQuota limits against current usage, the sum of pod
+requests as a share of the allocatable capacity of all nodes, and LimitRange defaults.
+Namespaces with any quota resource at or above --warn-percent are highlighted and listed first.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			report, err := toolkit.BuildQuotaReport(context.Background(), warnPercent)
+			if err != nil {
+				logger.Fatalf("Failed to build quota report: %v", err)
+			}
+
+			toolkit.PrintQuotaReport(report)
+
+			if failNear {
+				for _, nq := range report.Namespaces {
+					if nq.NearQuota {
+						os.Exit(1)
+					}
+				}
+			}
+		},
+	}
+
+	quotaCmd.Flags().Float64Var(&warnPercent, "warn-percent", 80, "Highlight namespaces using at least this percentage of any quota resource")
+	quotaCmd.Flags().BoolVar(&failNear, "fail-near-quota", false, "Exit non-zero when any namespace is near quota")
+
+	return quotaCmd
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// publicGroups are the groups every caller, or every anonymous caller,
+// belongs to
+var publicGroups = map[string]bool{"system:anonymous": true, "system:unauthenticated": true, "system:authenticated": true}
+
+// publicRoles are the built-in roles the API server binds to publicGroups
+// itself; they only grant discovery and self reviews
+var publicRoles = map[string]bool{"system:basic-user": true, "system:discovery": true, "system:public-info-viewer": true}
+
+// AuditRBAC reports users and groups bound to powerful ClusterRoles,
+// bindings that grant anything beyond discovery to anonymous or all
+// authenticated callers, and custom roles granting every verb on every
+// resource. Service accounts are covered by AuditServiceAccounts. Within a
+// namespace scope only the namespace's bindings and roles are reported.
+func (k *K8sToolkit) AuditRBAC(ctx context.Context) ([]Finding, error) {
+	powerful, err := k.powerfulClusterRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	add := func(rule, severity, namespace, resource, message, remediation string) {
+		findings = append(findings, Finding{
+			Source:      "security-rbac",
+			RuleID:      "rbac/" + rule,
+			Severity:    severity,
+			Namespace:   namespace,
+			Resource:    resource,
+			Message:     message,
+			Remediation: remediation,
+		})
+	}
+
+	// checkSubjects reports the subjects of one binding; namespace is "" for
+	// ClusterRoleBindings
+	checkSubjects := func(namespace, kind, name string, roleRef rbacv1.RoleRef, subjects []rbacv1.Subject) {
+		for _, subject := range subjects {
+			if subject.Kind == rbacv1.ServiceAccountKind {
+				continue
+			}
+			if subject.Kind == rbacv1.GroupKind && publicGroups[subject.Name] {
+				if !publicRoles[roleRef.Name] {
+					add("public-binding", "Critical", namespace, strings.ToLower(kind)+"/"+name,
+						fmt.Sprintf("grants %s %s to group %s", roleRef.Kind, roleRef.Name, subject.Name),
+						"bind the role to the users or groups that need it instead of every caller")
+				}
+				continue
+			}
+			// system: users and groups are the control plane's own identities
+			if strings.HasPrefix(subject.Name, "system:") {
+				continue
+			}
+			reason, ok := powerful[roleRef.Name]
+			if !ok || roleRef.Kind != "ClusterRole" {
+				continue
+			}
+			severity, scope := "High", "cluster-wide"
+			if namespace != "" {
+				severity, scope = "Medium", "in namespace "+namespace
+			}
+			add("powerful-binding", severity, namespace, strings.ToLower(subject.Kind)+"/"+subject.Name,
+				fmt.Sprintf("bound %s to ClusterRole %s (%s) via %s %s", scope, roleRef.Name, reason, kind, name),
+				"grant standing access through a narrower role and use break-glass access for admin tasks")
+		}
+	}
+
+	if k.namespace == "" {
+		clusterBindings, err := k.clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+		}
+		for _, binding := range clusterBindings.Items {
+			checkSubjects("", "ClusterRoleBinding", binding.Name, binding.RoleRef, binding.Subjects)
+		}
+
+		// Built-in roles such as cluster-admin are wildcards by design
+		clusterRoles, err := k.clientset.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list cluster roles: %w", err)
+		}
+		for _, role := range clusterRoles.Items {
+			if builtinPowerfulRoles[role.Name] || strings.HasPrefix(role.Name, "system:") {
+				continue
+			}
+			if wildcardRules(role.Rules) {
+				add("wildcard-role", "Medium", "", "clusterrole/"+role.Name, "grants all verbs on all resources",
+					"list the resources and verbs the role is meant for")
+			}
+		}
+	}
+
+	roleBindings, err := k.clientset.RbacV1().RoleBindings(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+	for _, binding := range inScopeItems(k, roleBindings.Items) {
+		checkSubjects(binding.Namespace, "RoleBinding", binding.Name, binding.RoleRef, binding.Subjects)
+	}
+
+	roles, err := k.clientset.RbacV1().Roles(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	for _, role := range inScopeItems(k, roles.Items) {
+		if wildcardRules(role.Rules) {
+			add("wildcard-role", "Low", role.Namespace, "role/"+role.Name, "grants all verbs on all resources in the namespace",
+				"list the resources and verbs the role is meant for")
+		}
+	}
+
+	sortFindings(findings)
+	return findings, nil
+}
+
+// wildcardRules reports whether any rule grants all verbs on all resources
+func wildcardRules(rules []rbacv1.PolicyRule) bool {
+	for _, rule := range rules {
+		if powerfulRule(rule) == "all verbs on all resources" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// tlsExpiryWarning is how far ahead of expiry an ingress certificate is reported
+const tlsExpiryWarning = 30 * 24 * time.Hour
+
+// HostProbe is the result of requesting an ingress host
+type HostProbe struct {
+	Namespace  string     `json:"namespace"`
+	Ingress    string     `json:"ingress"`
+	URL        string     `json:"url"`
+	StatusCode int        `json:"status_code,omitempty"`
+	TLSExpiry  *time.Time `json:"tls_expiry,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// ReachabilityReport is the result of a Service and Ingress reachability check
+type ReachabilityReport struct {
+	Probes   []HostProbe `json:"probes,omitempty"`
+	Findings []Finding   `json:"findings"`
+}
+
+// ReachabilityOptions configures CheckReachability
+type ReachabilityOptions struct {
+	Probe        bool
+	ProbeTimeout time.Duration
+}
+
+// CheckReachability reports Ingresses that reference missing Services, ports
+// or TLS Secrets, Services without ready endpoints, and LoadBalancer Services
+// without an external address. With Probe set, each ingress host is requested
+// over HTTP(S) to record its status code and certificate expiry.
+func (k *K8sToolkit) CheckReachability(ctx context.Context, opts ReachabilityOptions) (*ReachabilityReport, error) {
+	report := &ReachabilityReport{}
+	add := func(rule, severity, namespace, resource, message string) {
+		report.Findings = append(report.Findings, Finding{
+			Source:    "reachability",
+			RuleID:    "reach/" + rule,
+			Severity:  severity,
+			Namespace: namespace,
+			Resource:  resource,
+			Message:   message,
+		})
+	}
+
+	services, err := k.clientset.CoreV1().Services(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	endpoints, err := k.clientset.CoreV1().Endpoints(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list endpoints: %w", err)
+	}
+
+	serviceByKey := make(map[string]*corev1.Service, len(services.Items))
+	for i := range services.Items {
+		svc := &services.Items[i]
+		serviceByKey[svc.Namespace+"/"+svc.Name] = svc
+	}
+	readyAddresses := make(map[string]int, len(endpoints.Items))
+	for _, ep := range endpoints.Items {
+		for _, subset := range ep.Subsets {
+			readyAddresses[ep.Namespace+"/"+ep.Name] += len(subset.Addresses)
+		}
+	}
+
+	for _, svc := range inScopeItems(k, services.Items) {
+		resource := "service/" + svc.Name
+		if svc.Spec.Type == corev1.ServiceTypeExternalName {
+			continue
+		}
+		if readyAddresses[svc.Namespace+"/"+svc.Name] == 0 {
+			message := "has no ready endpoints"
+			if len(svc.Spec.Selector) > 0 {
+				message = fmt.Sprintf("has no ready endpoints; no ready pod matches selector %s", formatSelector(svc.Spec.Selector))
+			}
+			add("no-endpoints", "High", svc.Namespace, resource, message)
+		}
+		if svc.Spec.Type == corev1.ServiceTypeLoadBalancer && len(svc.Status.LoadBalancer.Ingress) == 0 {
+			add("pending-loadbalancer", "Medium", svc.Namespace, resource, "LoadBalancer has no external IP or hostname allocated")
+		}
+	}
+
+	ingresses, err := k.clientset.NetworkingV1().Ingresses(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ingresses: %w", err)
+	}
+
+	for _, ing := range inScopeItems(k, ingresses.Items) {
+		resource := "ingress/" + ing.Name
+
+		var backends []*networkingv1.IngressServiceBackend
+		if ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil {
+			backends = append(backends, ing.Spec.DefaultBackend.Service)
+		}
+		for _, rule := range ing.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			for _, path := range rule.HTTP.Paths {
+				if path.Backend.Service != nil {
+					backends = append(backends, path.Backend.Service)
+				}
+			}
+		}
+
+		checked := make(map[string]bool)
+		for _, backend := range backends {
+			key := backend.Name + ":" + backend.Port.Name + fmt.Sprint(backend.Port.Number)
+			if checked[key] {
+				continue
+			}
+			checked[key] = true
+
+			svc, ok := serviceByKey[ing.Namespace+"/"+backend.Name]
+			if !ok {
+				add("missing-service", "High", ing.Namespace, resource, fmt.Sprintf("backend service %s does not exist", backend.Name))
+				continue
+			}
+			if !servicePortExists(svc, backend.Port) {
+				add("missing-port", "High", ing.Namespace, resource, fmt.Sprintf("backend service %s has no port %s", backend.Name, formatBackendPort(backend.Port)))
+			}
+		}
+
+		for _, tls := range ing.Spec.TLS {
+			if tls.SecretName == "" {
+				continue
+			}
+			_, err := k.clientset.CoreV1().Secrets(ing.Namespace).Get(ctx, tls.SecretName, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				add("missing-tls-secret", "High", ing.Namespace, resource, fmt.Sprintf("TLS secret %s does not exist", tls.SecretName))
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to get secret %s/%s: %w", ing.Namespace, tls.SecretName, err)
+			}
+		}
+
+		if opts.Probe && !offline() {
+			for _, probe := range probeIngressHosts(ctx, &ing, opts.ProbeTimeout) {
+				report.Probes = append(report.Probes, probe)
+				switch {
+				case probe.Error != "":
+					add("probe-failed", "High", ing.Namespace, resource, fmt.Sprintf("%s: %s", probe.URL, probe.Error))
+				case probe.StatusCode >= 500:
+					add("probe-failed", "High", ing.Namespace, resource, fmt.Sprintf("%s returned %d", probe.URL, probe.StatusCode))
+				}
+				if probe.TLSExpiry != nil {
+					if remaining := time.Until(*probe.TLSExpiry); remaining <= 0 {
+						add("tls-expired", "High", ing.Namespace, resource, fmt.Sprintf("certificate for %s expired on %s", probe.URL, probe.TLSExpiry.Format("2006-01-02")))
+					} else if remaining < tlsExpiryWarning {
+						add("tls-expiring", "Medium", ing.Namespace, resource, fmt.Sprintf("certificate for %s expires on %s", probe.URL, probe.TLSExpiry.Format("2006-01-02")))
+					}
+				}
+			}
+		}
+	}
+
+	sortFindings(report.Findings)
+	return report, nil
+}
+
+// servicePortExists reports whether an ingress backend port is defined on the service
+func servicePortExists(svc *corev1.Service, port networkingv1.ServiceBackendPort) bool {
+	for _, p := range svc.Spec.Ports {
+		if (port.Name != "" && p.Name == port.Name) || (port.Name == "" && p.Port == port.Number) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatBackendPort(port networkingv1.ServiceBackendPort) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return fmt.Sprint(port.Number)
+}
+
+func formatSelector(selector map[string]string) string {
+	pairs := make([]string, 0, len(selector))
+	for key, value := range selector {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// probeIngressHosts requests the root of every non-wildcard host of an
+// ingress, over HTTPS when the host is listed under TLS. Redirects are not
+// followed so the ingress's own status code is reported.
+func probeIngressHosts(ctx context.Context, ing *networkingv1.Ingress, timeout time.Duration) []HostProbe {
+	tlsHosts := make(map[string]bool)
+	for _, tls := range ing.Spec.TLS {
+		for _, host := range tls.Hosts {
+			tlsHosts[host] = true
+		}
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var probes []HostProbe
+	seen := make(map[string]bool)
+	for _, rule := range ing.Spec.Rules {
+		if rule.Host == "" || strings.HasPrefix(rule.Host, "*") || seen[rule.Host] {
+			continue
+		}
+		seen[rule.Host] = true
+
+		scheme := "http"
+		if tlsHosts[rule.Host] {
+			scheme = "https"
+		}
+		probe := HostProbe{Namespace: ing.Namespace, Ingress: ing.Name, URL: scheme + "://" + rule.Host + "/"}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, probe.URL, nil)
+		if err != nil {
+			probe.Error = err.Error()
+			probes = append(probes, probe)
+			continue
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			probe.Error = err.Error()
+			probes = append(probes, probe)
+			continue
+		}
+		resp.Body.Close()
+
+		probe.StatusCode = resp.StatusCode
+		if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+			expiry := resp.TLS.PeerCertificates[0].NotAfter
+			probe.TLSExpiry = &expiry
+		}
+		probes = append(probes, probe)
+	}
+	return probes
+}
+
+// reachabilityFindings adapts CheckReachability to a compliance finding source
+func (k *K8sToolkit) reachabilityFindings(ctx context.Context) ([]Finding, error) {
+	report, err := k.CheckReachability(ctx, ReachabilityOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return report.Findings, nil
+}
+
+// PrintReachabilityReport prints the probe results followed by the findings
+func (k *K8sToolkit) PrintReachabilityReport(report *ReachabilityReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	if len(report.Probes) > 0 {
+		fmt.Printf("Ingress Probes\n")
+		fmt.Printf("%-20s %-30s %-50s %-8s %s\n", "NAMESPACE", "INGRESS", "URL", "STATUS", "TLS EXPIRY")
+		for _, probe := range report.Probes {
+			status := fmt.Sprint(probe.StatusCode)
+			if probe.Error != "" {
+				status = "error"
+			}
+			expiry := "-"
+			if probe.TLSExpiry != nil {
+				expiry = probe.TLSExpiry.Format("2006-01-02")
+			}
+			fmt.Printf("%-20s %-30s %-50s %-8s %s\n", probe.Namespace, probe.Ingress, probe.URL, status, expiry)
+		}
+		fmt.Println()
+	}
+
+	k.PrintFindings("Service and Ingress Reachability", report.Findings)
+}
+
+// createReachabilityCmd creates the reachability command
+func createReachabilityCmd() *cobra.Command {
+	var opts ReachabilityOptions
+	var failOn string
+
+	reachabilityCmd := &cobra.Command{
+		Use:   "reachability",
+		Short: "Check that Services and Ingresses can actually serve traffic",
+		Long: `Validates that Ingresses reference existing Services, ports and TLS Secrets, that Services
+have ready endpoints and that LoadBalancer Services have an external address. With --probe
+every ingress host is requested over HTTP(S) to report its status code and certificate expiry.
+Probing is skipped in offline mode.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			report, err := toolkit.CheckReachability(context.Background(), opts)
+			if err != nil {
+				logger.Fatalf("Failed to check reachability: %v", err)
+			}
+
+			toolkit.PrintReachabilityReport(report)
+			exitOnFindings(report.Findings, failOn)
+		},
+	}
+
+	reachabilityCmd.Flags().BoolVar(&opts.Probe, "probe", false, "Request each ingress host over HTTP(S)")
+	reachabilityCmd.Flags().DurationVar(&opts.ProbeTimeout, "probe-timeout", 5*time.Second, "Timeout for each ingress host probe")
+	reachabilityCmd.Flags().StringVar(&failOn, "fail-on", "none", "Exit non-zero on findings at or above this severity (Low|Medium|High|none)")
+
+	return reachabilityCmd
+}
@@ -0,0 +1,584 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// remediationDetectors are the findings rules can act on, with the actions
+// each allows. Only actions that remove objects the cluster no longer needs
+// are offered.
+var remediationDetectors = map[string][]string{
+	"evicted-pods":       {"delete"},
+	"completed-jobs":     {"delete"},
+	"stuck-terminating":  {"force-delete"},
+	"failed-pods":        {"delete"},
+	"orphaned-succeeded": {"delete"},
+}
+
+// Default minimum ages before a finding is acted on
+var remediationMinAge = map[string]time.Duration{
+	"evicted-pods":       time.Hour,
+	"completed-jobs":     7 * 24 * time.Hour,
+	"stuck-terminating":  30 * time.Minute,
+	"failed-pods":        24 * time.Hour,
+	"orphaned-succeeded": 24 * time.Hour,
+}
+
+// remediationRule maps a finding to a remediation action
+type remediationRule struct {
+	Name       string        `mapstructure:"name" json:"name"`
+	Finding    string        `mapstructure:"finding" json:"finding"`
+	Action     string        `mapstructure:"action" json:"action"`
+	Enabled    bool          `mapstructure:"enabled" json:"enabled"`
+	MinAge     time.Duration `mapstructure:"min_age" json:"min_age"`
+	MaxActions int           `mapstructure:"max_actions" json:"max_actions"`
+	Window     time.Duration `mapstructure:"window" json:"window"`
+	Namespaces []string      `mapstructure:"namespaces" json:"namespaces,omitempty"`
+}
+
+// loadRemediationRules reads remediation.rules and validates each rule
+// against the detectors. Rules are disabled unless enabled is set.
+func loadRemediationRules() ([]remediationRule, error) {
+	var rules []remediationRule
+	if err := viper.UnmarshalKey("remediation.rules", &rules); err != nil {
+		return nil, fmt.Errorf("invalid remediation.rules: %w", err)
+	}
+	seen := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			rule.Name = rule.Finding
+		}
+		if seen[rule.Name] {
+			return nil, fmt.Errorf("duplicate remediation rule %s", rule.Name)
+		}
+		seen[rule.Name] = true
+
+		actions, ok := remediationDetectors[rule.Finding]
+		if !ok {
+			return nil, fmt.Errorf("remediation rule %s has unknown finding %q", rule.Name, rule.Finding)
+		}
+		if rule.Action == "" {
+			rule.Action = actions[0]
+		}
+		allowed := false
+		for _, action := range actions {
+			allowed = allowed || action == rule.Action
+		}
+		if !allowed {
+			return nil, fmt.Errorf("remediation rule %s: action %q is not allowed for %s (use %s)", rule.Name, rule.Action, rule.Finding, strings.Join(actions, ", "))
+		}
+		if rule.MinAge == 0 {
+			rule.MinAge = remediationMinAge[rule.Finding]
+		}
+		if rule.MaxActions == 0 {
+			rule.MaxActions = viper.GetInt("remediation.max_actions")
+		}
+		if rule.Window == 0 {
+			rule.Window = viper.GetDuration("remediation.window")
+		}
+	}
+	return rules, nil
+}
+
+// RemediationTarget is an object a rule found
+type RemediationTarget struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason"`
+}
+
+func (t RemediationTarget) String() string {
+	return fmt.Sprintf("%s %s/%s", t.Kind, t.Namespace, t.Name)
+}
+
+// RemediationAction is the outcome of one remediation
+type RemediationAction struct {
+	Rule   string            `json:"rule"`
+	Action string            `json:"action"`
+	Target RemediationTarget `json:"target"`
+	Result string            `json:"result"`
+}
+
+// RemediationReport is the result of evaluating remediation rules
+type RemediationReport struct {
+	DryRun  bool                `json:"dry_run"`
+	Actions []RemediationAction `json:"actions"`
+	Skipped map[string]string   `json:"skipped,omitempty"`
+}
+
+// inRuleScope reports whether namespace is covered by the rule and the
+// toolkit scope
+func (k *K8sToolkit) inRuleScope(rule remediationRule, namespace string) bool {
+	if !k.inScope(namespace) {
+		return false
+	}
+	if len(rule.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range rule.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// detectRemediationTargets returns the objects a rule's finding currently
+// matches, oldest first
+func (k *K8sToolkit) detectRemediationTargets(ctx context.Context, rule remediationRule) ([]RemediationTarget, error) {
+	cutoff := time.Now().Add(-rule.MinAge)
+	var targets []RemediationTarget
+	var ages []time.Time
+
+	add := func(target RemediationTarget, since time.Time) {
+		if since.After(cutoff) || !k.inRuleScope(rule, target.Namespace) {
+			return
+		}
+		targets = append(targets, target)
+		ages = append(ages, since)
+	}
+
+	switch rule.Finding {
+	case "completed-jobs":
+		jobs, err := k.clientset.BatchV1().Jobs(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list jobs: %w", err)
+		}
+		for i := range jobs.Items {
+			job := &jobs.Items[i]
+			// CronJobs prune their own history
+			if owner := metav1.GetControllerOf(job); owner != nil && owner.Kind == "CronJob" {
+				continue
+			}
+			state, finishedAt, ok := jobFinished(job)
+			if !ok || state != "completed" {
+				continue
+			}
+			add(RemediationTarget{Kind: "Job", Namespace: job.Namespace, Name: job.Name, Reason: fmt.Sprintf("completed %s ago", time.Since(finishedAt).Round(time.Hour))}, finishedAt)
+		}
+
+	case "stuck-terminating":
+		// Force deletion is only safe when the kubelet that should finish the
+		// deletion is gone or unreachable
+		nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list nodes: %w", err)
+		}
+		ready := make(map[string]bool)
+		for _, node := range nodes.Items {
+			for _, condition := range node.Status.Conditions {
+				if condition.Type == corev1.NodeReady {
+					ready[node.Name] = condition.Status == corev1.ConditionTrue
+				}
+			}
+		}
+		err = k.eachPod(ctx, k.namespace, metav1.ListOptions{}, func(pod *corev1.Pod) error {
+			if pod.DeletionTimestamp == nil || len(pod.Finalizers) > 0 {
+				return nil
+			}
+			if pod.Spec.NodeName != "" && ready[pod.Spec.NodeName] {
+				return nil
+			}
+			since := pod.DeletionTimestamp.Time
+			if pod.DeletionGracePeriodSeconds != nil {
+				since = since.Add(time.Duration(*pod.DeletionGracePeriodSeconds) * time.Second)
+			}
+			node := pod.Spec.NodeName
+			if _, exists := ready[node]; !exists {
+				node += " (gone)"
+			} else {
+				node += " (NotReady)"
+			}
+			add(RemediationTarget{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Reason: fmt.Sprintf("terminating for %s on node %s", time.Since(since).Round(time.Minute), node)}, since)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %w", err)
+		}
+
+	default:
+		err := k.eachPod(ctx, k.namespace, metav1.ListOptions{}, func(pod *corev1.Pod) error {
+			var reason string
+			switch {
+			case rule.Finding == "evicted-pods" && pod.Status.Phase == corev1.PodFailed && pod.Status.Reason == "Evicted":
+				reason = "Evicted: " + pod.Status.Message
+			case rule.Finding == "failed-pods" && pod.Status.Phase == corev1.PodFailed && pod.Status.Reason != "Evicted" && metav1.GetControllerOf(pod) == nil:
+				reason = "Failed"
+			case rule.Finding == "orphaned-succeeded" && pod.Status.Phase == corev1.PodSucceeded && metav1.GetControllerOf(pod) == nil:
+				reason = "Succeeded without a controller"
+			default:
+				return nil
+			}
+			add(RemediationTarget{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name, Reason: reason}, podFinishedAt(pod))
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods: %w", err)
+		}
+	}
+
+	sort.SliceStable(targets, func(i, j int) bool { return ages[i].Before(ages[j]) })
+	return targets, nil
+}
+
+// podFinishedAt returns when the last container of a finished pod
+// terminated, falling back to its start or creation time
+func podFinishedAt(pod *corev1.Pod) time.Time {
+	var finished time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if t := status.State.Terminated; t != nil && t.FinishedAt.After(finished) {
+			finished = t.FinishedAt.Time
+		}
+	}
+	if !finished.IsZero() {
+		return finished
+	}
+	if pod.Status.StartTime != nil {
+		return pod.Status.StartTime.Time
+	}
+	return pod.CreationTimestamp.Time
+}
+
+// remediate takes a rule's action on one target
+func (k *K8sToolkit) remediate(ctx context.Context, action string, target RemediationTarget) error {
+	switch action {
+	case "force-delete":
+		zero := int64(0)
+		return k.clientset.CoreV1().Pods(target.Namespace).Delete(ctx, target.Name, metav1.DeleteOptions{GracePeriodSeconds: &zero})
+	case "delete":
+		return k.DeleteCleanupCandidate(ctx, CleanupCandidate{Kind: target.Kind, Namespace: target.Namespace, Name: target.Name})
+	default:
+		return fmt.Errorf("unsupported remediation action %s", action)
+	}
+}
+
+// remediationCommand is the audit log command of a rule's actions
+func remediationCommand(rule string) string {
+	return "remediate/" + rule
+}
+
+// recentRemediations counts the actions each rule took within its window
+// according to the audit log, so rate limits hold across runs and callers
+func recentRemediations(rules []remediationRule) (map[string]int, error) {
+	counts := make(map[string]int)
+	path, err := auditLogPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return counts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	defer f.Close()
+
+	windows := make(map[string]time.Time)
+	for _, rule := range rules {
+		windows[remediationCommand(rule.Name)] = time.Now().Add(-rule.Window)
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		since, ok := windows[entry.Command]
+		if !ok || entry.Time.Before(since) || entry.Action == "break-glass" {
+			continue
+		}
+		counts[strings.TrimPrefix(entry.Command, "remediate/")]++
+	}
+	return counts, scanner.Err()
+}
+
+// remediationLocks holds a mutex per rule, so concurrent runs of a rule
+// cannot all count the same actions in the audit log and each spend the
+// full budget
+var remediationLocks sync.Map
+
+// runRemediationRule takes the actions of one rule within its rate limit
+// and adds them to report. The rule stays locked from counting its recent
+// actions until its last action is recorded. An action is only taken while
+// the audit log can be written, since the rate limit counts its entries.
+func (k *K8sToolkit) runRemediationRule(ctx context.Context, rule remediationRule, dryRun bool, m *mutation, report *RemediationReport) error {
+	lock, _ := remediationLocks.LoadOrStore(rule.Name, &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	taken, err := recentRemediations([]remediationRule{rule})
+	if err != nil {
+		return err
+	}
+	targets, err := k.detectRemediationTargets(ctx, rule)
+	if err != nil {
+		return err
+	}
+	budget := rule.MaxActions - taken[rule.Name]
+	var recorder mutation
+	if m != nil {
+		recorder = *m
+		recorder.command = remediationCommand(rule.Name)
+	}
+	for i, target := range targets {
+		action := RemediationAction{Rule: rule.Name, Action: rule.Action, Target: target}
+		switch {
+		case budget <= 0:
+			report.Skipped[rule.Name] = fmt.Sprintf("rate limit of %d actions per %s reached, %d targets left", rule.MaxActions, rule.Window, len(targets)-i)
+			return nil
+		case dryRun:
+			action.Result = "would " + rule.Action
+		default:
+			if err := checkAuditLog(); err != nil {
+				report.Skipped[rule.Name] = fmt.Sprintf("%v, %d targets left", err, len(targets)-i)
+				return nil
+			}
+			err := k.remediate(ctx, rule.Action, target)
+			action.Result = "ok"
+			if err != nil {
+				action.Result = "error: " + err.Error()
+			}
+			if auditErr := recorder.record(rule.Action, target.String()+" ("+target.Reason+")", err); auditErr != nil {
+				action.Result = fmt.Sprintf("%s, not audited: %v", action.Result, auditErr)
+				report.Actions = append(report.Actions, action)
+				report.Skipped[rule.Name] = fmt.Sprintf("audit log write failed, %d targets left", len(targets)-i-1)
+				return nil
+			}
+		}
+		budget--
+		report.Actions = append(report.Actions, action)
+	}
+	return nil
+}
+
+// RunRemediation evaluates the enabled rules, or only the named ones, and
+// takes their actions within each rule's rate limit. With dryRun nothing is
+// changed and m may be nil. Every action taken is written to the audit log
+// under the command remediate/<rule>, which the rate limits count.
+func (k *K8sToolkit) RunRemediation(ctx context.Context, names []string, dryRun bool, m *mutation) (*RemediationReport, error) {
+	rules, err := loadRemediationRules()
+	if err != nil {
+		return nil, err
+	}
+	selected := make(map[string]bool)
+	for _, name := range names {
+		selected[name] = true
+	}
+
+	var active []remediationRule
+	report := &RemediationReport{DryRun: dryRun, Skipped: make(map[string]string)}
+	for _, rule := range rules {
+		if len(names) > 0 && !selected[rule.Name] {
+			continue
+		}
+		delete(selected, rule.Name)
+		if !rule.Enabled {
+			report.Skipped[rule.Name] = "disabled"
+			continue
+		}
+		active = append(active, rule)
+	}
+	for name := range selected {
+		return nil, fmt.Errorf("unknown remediation rule %s", name)
+	}
+
+	for _, rule := range active {
+		if err := k.runRemediationRule(ctx, rule, dryRun, m, report); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+	}
+	if len(report.Skipped) == 0 {
+		report.Skipped = nil
+	}
+	return report, nil
+}
+
+// remediationMutation authorizes remediation triggered through the API: it
+// honors read-only mode and deploy freezes like beginMutation but asks for
+// no confirmation, since the rules themselves were opted into
+func remediationMutation(caller string) (*mutation, error) {
+	if readOnly() {
+		return nil, fmt.Errorf("%w: remediate", ErrReadOnly)
+	}
+	breakGlass, err := checkFreeze("remediate", "")
+	if err != nil {
+		return nil, err
+	}
+	return &mutation{command: "remediate", reason: "remediation rules", user: caller, breakGlass: breakGlass}, nil
+}
+
+// PrintRemediationReport prints the actions taken or planned per rule
+func (k *K8sToolkit) PrintRemediationReport(report *RemediationReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	if len(report.Actions) == 0 {
+		fmt.Println("Nothing to remediate")
+	}
+	for _, action := range report.Actions {
+		fmt.Printf("  %-22s %-14s %-60s %s\n", action.Rule, action.Result, action.Target, action.Target.Reason)
+	}
+	rules := make([]string, 0, len(report.Skipped))
+	for rule := range report.Skipped {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		fmt.Printf("  %-22s skipped: %s\n", rule, report.Skipped[rule])
+	}
+	if report.DryRun && len(report.Actions) > 0 {
+		fmt.Println("\nDry run: nothing was changed. Re-run with --dry-run=false to remediate.")
+	}
+}
+
+// remediate serves POST /remediate and /remediate/{rule}, so alerts
+// and notification webhooks can trigger the rules. Remediation is a dry run
+// unless remediation.dry_run is false and the request does not ask for one
+// with ?dry_run=true.
+func (s *apiServer) remediate(w http.ResponseWriter, r *http.Request, k *K8sToolkit) {
+	var names []string
+	if rule := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/remediate"), "/"); rule != "" {
+		names = []string{rule}
+	}
+	dryRun := viper.GetBool("remediation.dry_run") || r.URL.Query().Get("dry_run") == "true"
+
+	ctx, cancel, err := s.requestContext(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	var m *mutation
+	if !dryRun {
+		if m, err = remediationMutation("api:" + r.RemoteAddr); err != nil {
+			writeAPIError(w, http.StatusConflict, err.Error())
+			return
+		}
+	}
+
+	k.log().Infof("Running remediation rules %v on request from %s (dry run %t)", names, r.RemoteAddr, dryRun)
+	report, err := k.RunRemediation(ctx, names, dryRun, m)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIResponse(w, report)
+}
+
+// createRemediateCmd creates the remediate command
+func createRemediateCmd() *cobra.Command {
+	var dryRun bool
+
+	remediateCmd := &cobra.Command{
+		Use:   "remediate",
+		Short: "Clean up known low-risk findings according to remediation rules",
+		Long: `Evaluates the rules in remediation.rules, each mapping a finding to a safe action:
+
+  evicted-pods        delete Evicted pods
+  completed-jobs      delete completed Jobs not owned by a CronJob
+  failed-pods         delete failed pods without a controller
+  orphaned-succeeded  delete succeeded pods without a controller
+  stuck-terminating   force-delete pods stuck Terminating on NotReady or deleted nodes
+
+A rule acts only when enabled: true and on findings older than min_age, and takes at most
+max_actions actions per window (remediation.max_actions and remediation.window by default),
+counted from the audit log across all runs. Every action is recorded in the audit log as
+remediate/<rule>. Runs are dry runs unless --dry-run=false.
+
+The operator API also serves POST /remediate and /remediate/<rule> so alerts and notification
+webhooks can trigger remediation; those calls are dry runs unless remediation.dry_run is false.`,
+	}
+
+	runCmd := &cobra.Command{
+		Use:   "run [rule...]",
+		Short: "Run the enabled rules, or only the named ones",
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx := context.Background()
+			var m *mutation
+			if !dryRun {
+				plan, err := toolkit.RunRemediation(ctx, args, true, nil)
+				if err != nil {
+					logger.Fatalf("Remediation failed: %v", err)
+				}
+				if len(plan.Actions) == 0 {
+					toolkit.PrintRemediationReport(plan)
+					return
+				}
+				if m, err = beginMutation("remediate", fmt.Sprintf("About to remediate %d resources.", len(plan.Actions))); err != nil {
+					logger.Fatalf("Remediation aborted: %v", err)
+				}
+			}
+
+			report, err := toolkit.RunRemediation(ctx, args, dryRun, m)
+			if err != nil {
+				logger.Fatalf("Remediation failed: %v", err)
+			}
+			toolkit.PrintRemediationReport(report)
+		},
+	}
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", true, "Only report what the rules would do")
+
+	rulesCmd := &cobra.Command{
+		Use:   "rules",
+		Short: "List the configured remediation rules and their recent actions",
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit := &K8sToolkit{output: viper.GetString("output"), filter: viper.GetString("filter")}
+			rules, err := loadRemediationRules()
+			if err != nil {
+				logger.Fatalf("%v", err)
+			}
+			taken, err := recentRemediations(rules)
+			if err != nil {
+				logger.Fatalf("%v", err)
+			}
+			if toolkit.filtered(rules) {
+				return
+			}
+			if toolkit.output == "json" {
+				printJSON(rules)
+				return
+			}
+			if len(rules) == 0 {
+				fmt.Println("No remediation rules configured (remediation.rules)")
+				return
+			}
+			fmt.Printf("%-22s %-20s %-13s %-8s %-10s %s\n", "RULE", "FINDING", "ACTION", "ENABLED", "MIN AGE", "RATE")
+			for _, rule := range rules {
+				rate := fmt.Sprintf("%d/%d per %s", taken[rule.Name], rule.MaxActions, rule.Window)
+				fmt.Printf("%-22s %-20s %-13s %-8t %-10s %s\n", rule.Name, rule.Finding, rule.Action, rule.Enabled, rule.MinAge, rate)
+			}
+		},
+	}
+
+	remediateCmd.AddCommand(runCmd, rulesCmd)
+	return remediateCmd
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// retryBaseDelay is the wait before the first retry; it doubles per attempt
+const retryBaseDelay = 200 * time.Millisecond
+
+// retryMaxDelay caps a single wait between retries
+const retryMaxDelay = 10 * time.Second
+
+// retryTransport retries idempotent API requests that failed transiently:
+// connection errors and 429, 500, 502, 503 and 504 responses. Waits back off
+// exponentially with jitter. 429s carrying Retry-After are left to
+// client-go, which already honors them. A request timeout bounds each
+// attempt of non-streaming requests.
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+	timeout time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return t.attempt(req)
+	}
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req)
+		if attempt >= t.retries || req.Context().Err() != nil || !transientResponse(resp, err) {
+			return resp, err
+		}
+
+		wait := retryDelay(attempt)
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			logger.Debugf("Retrying %s %s after %s in %s", req.Method, req.URL.Path, resp.Status, wait)
+		} else {
+			logger.Debugf("Retrying %s %s after %v in %s", req.Method, req.URL.Path, err, wait)
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// attempt sends req once, under the request timeout unless it streams
+func (t *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 || streamingRequest(req) {
+		return t.next.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.next.RoundTrip(req.Clone(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// The deadline must outlive the response body, which is read after we return
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// streamingRequest reports whether req is a watch, a followed log stream or
+// an upgraded exec, attach or port-forward connection
+func streamingRequest(req *http.Request) bool {
+	if req.Header.Get("Upgrade") != "" {
+		return true
+	}
+	query := req.URL.Query()
+	return query.Get("watch") == "true" || query.Get("watch") == "1" || query.Get("follow") == "true"
+}
+
+// transientResponse reports whether a request outcome is worth retrying.
+// Every transport error is, including an attempt running into the request
+// timeout; the caller's own cancellation is checked before.
+func transientResponse(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		_, parseErr := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return parseErr != nil
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay returns the wait before retry number attempt+1
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	if delay > retryMaxDelay || delay <= 0 {
+		delay = retryMaxDelay
+	}
+	// Jitter keeps many clients from retrying in lockstep
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// cancelOnClose releases a per-attempt context once the body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// failingWaitingReasons are container waiting reasons that do not resolve
+// without a change to the workload
+var failingWaitingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+	"RunContainerError":          true,
+}
+
+// rolloutProgress is the replica state of a workload rollout
+type rolloutProgress struct {
+	Desired   int32  `json:"desired"`
+	Updated   int32  `json:"updated"`
+	Ready     int32  `json:"ready"`
+	Available int32  `json:"available"`
+	Done      bool   `json:"done"`
+	Failed    string `json:"failed,omitempty"`
+
+	selector *metav1.LabelSelector
+}
+
+func (p rolloutProgress) String() string {
+	return fmt.Sprintf("%d/%d updated, %d ready, %d available", p.Updated, p.Desired, p.Ready, p.Available)
+}
+
+// RolloutResult is the outcome of waiting for a rollout
+type RolloutResult struct {
+	Workload  string          `json:"workload"`
+	Succeeded bool            `json:"succeeded"`
+	Reason    string          `json:"reason,omitempty"`
+	Duration  string          `json:"duration"`
+	Progress  rolloutProgress `json:"progress"`
+	PodIssues []string        `json:"pod_issues,omitempty"`
+	Events    []string        `json:"events,omitempty"`
+}
+
+// parseWorkloadRef parses kind/name as accepted by kubectl, e.g. deployment/foo or sts/db
+func parseWorkloadRef(namespace, ref string) (objectRef, error) {
+	kind, name, ok := strings.Cut(ref, "/")
+	if !ok || name == "" {
+		return objectRef{}, fmt.Errorf("invalid workload %q, expected kind/name", ref)
+	}
+	switch strings.ToLower(kind) {
+	case "deployment", "deployments", "deploy":
+		kind = "Deployment"
+	case "statefulset", "statefulsets", "sts":
+		kind = "StatefulSet"
+	case "daemonset", "daemonsets", "ds":
+		kind = "DaemonSet"
+	default:
+		return objectRef{}, fmt.Errorf("unsupported workload kind %q", kind)
+	}
+	return objectRef{Kind: kind, Namespace: namespace, Name: name}, nil
+}
+
+// rolloutStatus reads the current rollout progress of a workload. A rollout
+// is done once the controller has observed the latest spec and every replica
+// runs the new revision and is available.
+func (k *K8sToolkit) rolloutStatus(ctx context.Context, ref objectRef) (rolloutProgress, error) {
+	var p rolloutProgress
+	switch ref.Kind {
+	case "Deployment":
+		d, err := k.clientset.AppsV1().Deployments(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return p, err
+		}
+		p = rolloutProgress{Updated: d.Status.UpdatedReplicas, Ready: d.Status.ReadyReplicas, Available: d.Status.AvailableReplicas, selector: d.Spec.Selector}
+		p.Desired = 1
+		if d.Spec.Replicas != nil {
+			p.Desired = *d.Spec.Replicas
+		}
+		for _, c := range d.Status.Conditions {
+			if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+				p.Failed = c.Message
+			}
+		}
+		p.Done = d.Status.ObservedGeneration >= d.Generation && p.Updated == p.Desired &&
+			d.Status.Replicas == p.Updated && p.Available == p.Updated
+	case "StatefulSet":
+		s, err := k.clientset.AppsV1().StatefulSets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return p, err
+		}
+		p = rolloutProgress{Updated: s.Status.UpdatedReplicas, Ready: s.Status.ReadyReplicas, Available: s.Status.AvailableReplicas, selector: s.Spec.Selector}
+		p.Desired = 1
+		if s.Spec.Replicas != nil {
+			p.Desired = *s.Spec.Replicas
+		}
+		p.Done = s.Status.ObservedGeneration >= s.Generation && p.Ready == p.Desired &&
+			(s.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType ||
+				(p.Updated == p.Desired && s.Status.CurrentRevision == s.Status.UpdateRevision))
+	case "DaemonSet":
+		ds, err := k.clientset.AppsV1().DaemonSets(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		if err != nil {
+			return p, err
+		}
+		p = rolloutProgress{
+			Desired:   ds.Status.DesiredNumberScheduled,
+			Updated:   ds.Status.UpdatedNumberScheduled,
+			Ready:     ds.Status.NumberReady,
+			Available: ds.Status.NumberAvailable,
+			selector:  ds.Spec.Selector,
+		}
+		p.Done = ds.Status.ObservedGeneration >= ds.Generation && p.Updated == p.Desired && p.Available == p.Desired
+	}
+	return p, nil
+}
+
+// podIssues describes the pods of a workload that are failing to start:
+// containers waiting for a reason that will not clear on its own, containers
+// that terminated with an error, and pods the scheduler cannot place
+func (k *K8sToolkit) podIssues(ctx context.Context, namespace string, selector *metav1.LabelSelector) ([]string, []objectRef, error) {
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var issues []string
+	var refs []objectRef
+	err = k.eachPod(ctx, namespace, metav1.ListOptions{LabelSelector: labelSelector.String()}, func(pod *corev1.Pod) error {
+		if pod.DeletionTimestamp != nil {
+			return nil
+		}
+		var reasons []string
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+				reasons = append(reasons, "Unschedulable: "+c.Message)
+			}
+		}
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			switch {
+			case status.State.Waiting != nil && failingWaitingReasons[status.State.Waiting.Reason]:
+				reason := fmt.Sprintf("container %s %s", status.Name, status.State.Waiting.Reason)
+				if msg := strings.TrimSpace(status.State.Waiting.Message); msg != "" {
+					reason += ": " + msg
+				}
+				if term := status.LastTerminationState.Terminated; term != nil {
+					reason += fmt.Sprintf(" (last exit %d %s)", term.ExitCode, term.Reason)
+				}
+				reasons = append(reasons, reason)
+			case status.State.Terminated != nil && status.State.Terminated.ExitCode != 0:
+				reasons = append(reasons, fmt.Sprintf("container %s terminated: exit %d %s", status.Name, status.State.Terminated.ExitCode, status.State.Terminated.Reason))
+			}
+		}
+		for _, reason := range reasons {
+			issues = append(issues, fmt.Sprintf("%s: %s", pod.Name, reason))
+		}
+		if len(reasons) > 0 {
+			refs = append(refs, objectRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name})
+		}
+		return nil
+	})
+	sort.Strings(issues)
+	return issues, refs, err
+}
+
+// WaitForRollout polls a workload until its rollout completes, fails or
+// timeout passes, printing replica progress and new pod issues as they
+// appear. With failFast, a failing pod ends the wait immediately.
+func (k *K8sToolkit) WaitForRollout(ctx context.Context, ref objectRef, timeout time.Duration, failFast bool) *RolloutResult {
+	start := time.Now()
+	result := &RolloutResult{Workload: ref.String()}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stream := k.output != "json"
+	seen := make(map[string]bool)
+	var lastProgress string
+	var failingPods []objectRef
+
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		progress, err := k.rolloutStatus(ctx, ref)
+		if err != nil && ctx.Err() == nil {
+			result.Reason = fmt.Sprintf("failed to get %s: %v", ref, classifyError(err))
+			break
+		}
+		if err == nil {
+			result.Progress = progress
+			if s := progress.String(); stream && s != lastProgress {
+				fmt.Printf("[%s] %s\n", time.Since(start).Round(time.Second), s)
+				lastProgress = s
+			}
+
+			issues, refs, err := k.podIssues(ctx, ref.Namespace, progress.selector)
+			if err != nil && ctx.Err() == nil {
+				k.log().Warnf("failed to list pods of %s: %v", ref, err)
+			}
+			result.PodIssues = issues
+			failingPods = refs
+			for _, issue := range issues {
+				if stream && !seen[issue] {
+					fmt.Printf("[%s] ⚠️  %s\n", time.Since(start).Round(time.Second), issue)
+				}
+				seen[issue] = true
+			}
+
+			switch {
+			case progress.Done:
+				result.Succeeded = true
+			case progress.Failed != "":
+				result.Reason = progress.Failed
+			case failFast && len(issues) > 0:
+				result.Reason = fmt.Sprintf("%d pod issues and --fail-fast is set", len(issues))
+			}
+			if result.Succeeded || result.Reason != "" {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			result.Reason = fmt.Sprintf("rollout did not complete within %s", timeout)
+		case <-ticker.C:
+			continue
+		}
+		break
+	}
+
+	result.Duration = time.Since(start).Round(time.Second).String()
+	if !result.Succeeded {
+		// The wait context may have expired; diagnostics get a fresh deadline
+		diagCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		for _, r := range append([]objectRef{ref}, failingPods...) {
+			if len(result.Events) >= 20 {
+				break
+			}
+			events, err := k.RecentEvents(diagCtx, r, 5)
+			if err != nil {
+				continue
+			}
+			for _, event := range events {
+				if event.Type == corev1.EventTypeWarning || r == ref {
+					result.Events = append(result.Events, r.Name+": "+formatEvent(event))
+				}
+			}
+		}
+	}
+	return result
+}
+
+// PrintRolloutResult prints the outcome and, for failed rollouts, the diagnostic summary
+func (k *K8sToolkit) PrintRolloutResult(result *RolloutResult) {
+	if k.filtered(result) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(result)
+		return
+	}
+
+	if result.Succeeded {
+		fmt.Printf("✅ %s rolled out in %s\n", result.Workload, result.Duration)
+		return
+	}
+
+	fmt.Printf("\n❌ %s rollout failed after %s: %s\n", result.Workload, result.Duration, result.Reason)
+	fmt.Printf("Progress: %s\n", result.Progress)
+	if len(result.PodIssues) > 0 {
+		fmt.Println("Pod issues:")
+		for _, issue := range result.PodIssues {
+			fmt.Printf("  - %s\n", issue)
+		}
+	}
+	if len(result.Events) > 0 {
+		fmt.Println("Recent events:")
+		for _, event := range result.Events {
+			fmt.Printf("  - %s\n", event)
+		}
+	}
+}
+
+// createRolloutCmd creates the rollout command
+func createRolloutCmd() *cobra.Command {
+	rolloutCmd := &cobra.Command{
+		Use:   "rollout",
+		Short: "Follow workload rollouts",
+	}
+
+	var timeout time.Duration
+	var failFast bool
+	waitCmd := &cobra.Command{
+		Use:   "wait <kind/name>",
+		Short: "Wait for a Deployment, StatefulSet or DaemonSet rollout to complete",
+		Long: `Follows the rollout of a workload in --namespace, printing replica progress and failing pods
+(image pull errors, crash loops, unschedulable pods, failed containers) as they occur. Exits non-zero
+with the pod issues and recent warning events when the rollout does not complete within --timeout,
+the Deployment exceeds its progress deadline, or, with --fail-fast, as soon as a pod is failing.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			namespace := toolkit.namespace
+			if namespace == "" {
+				namespace = "default"
+			}
+			ref, err := parseWorkloadRef(namespace, args[0])
+			if err != nil {
+				logger.Fatalf("Invalid workload: %v", err)
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			result := toolkit.WaitForRollout(ctx, ref, timeout, failFast)
+			toolkit.PrintRolloutResult(result)
+			if !result.Succeeded {
+				os.Exit(1)
+			}
+		},
+	}
+
+	waitCmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "Maximum time to wait for the rollout")
+	waitCmd.Flags().BoolVar(&failFast, "fail-fast", false, "Fail as soon as any pod of the workload is failing")
+
+	rolloutCmd.AddCommand(waitCmd)
+	return rolloutCmd
+}
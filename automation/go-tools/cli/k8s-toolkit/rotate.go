@@ -0,0 +1,624 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	vault "github.com/hashicorp/vault/api"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const rotatedAtAnnotation = "k8s-toolkit.devops/rotated-at"
+
+// rotatedAtMetadata is the Vault KV custom metadata key holding the time of
+// the last rotation
+const rotatedAtMetadata = "rotated-at"
+
+// RotationSpec describes a credential managed by the rotate command
+type RotationSpec struct {
+	Name      string   `yaml:"name"`
+	Backend   string   `yaml:"backend"`
+	Namespace string   `yaml:"namespace"`
+	Secret    string   `yaml:"secret"`
+	Mount     string   `yaml:"mount"`
+	Path      string   `yaml:"path"`
+	Key       string   `yaml:"key"`
+	Generator string   `yaml:"generator"`
+	Length    int      `yaml:"length"`
+	Interval  string   `yaml:"interval"`
+	Restart   []string `yaml:"restart"`
+}
+
+// RotationConfig is the top-level structure of a rotations file
+type RotationConfig struct {
+	Rotations []RotationSpec `yaml:"rotations"`
+}
+
+// RotationStatus reports when a credential was last rotated and when it is
+// due. A zero RotatedAt means it was never rotated, which makes it due now.
+type RotationStatus struct {
+	Name      string    `json:"name"`
+	Backend   string    `json:"backend"`
+	RotatedAt time.Time `json:"rotated_at"`
+	DueAt     time.Time `json:"due_at"`
+	Overdue   bool      `json:"overdue"`
+	DueSoon   bool      `json:"due_soon"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// secretBackend reads and writes a single credential value. Read reports
+// whether the key is set, so a rollback can Delete a key that did not exist
+// before instead of writing it empty.
+type secretBackend interface {
+	Read(ctx context.Context) (value string, found bool, rotatedAt time.Time, err error)
+	Write(ctx context.Context, value string, rotatedAt time.Time) error
+	Delete(ctx context.Context, rotatedAt time.Time) error
+}
+
+// k8sSecretBackend stores a credential as a key of a Kubernetes Secret
+type k8sSecretBackend struct {
+	toolkit *K8sToolkit
+	spec    RotationSpec
+}
+
+func (b *k8sSecretBackend) Read(ctx context.Context) (string, bool, time.Time, error) {
+	secret, err := b.toolkit.clientset.CoreV1().Secrets(b.spec.Namespace).Get(ctx, b.spec.Secret, metav1.GetOptions{})
+	if err != nil {
+		return "", false, time.Time{}, fmt.Errorf("failed to get secret %s/%s: %w", b.spec.Namespace, b.spec.Secret, err)
+	}
+
+	rotatedAt := secret.CreationTimestamp.Time
+	if ts, ok := secret.Annotations[rotatedAtAnnotation]; ok {
+		if parsed, err := time.Parse(time.RFC3339, ts); err == nil {
+			rotatedAt = parsed
+		}
+	}
+
+	value, found := secret.Data[b.spec.Key]
+	return string(value), found, rotatedAt, nil
+}
+
+func (b *k8sSecretBackend) Write(ctx context.Context, value string, rotatedAt time.Time) error {
+	return b.update(ctx, rotatedAt, func(data map[string][]byte) {
+		data[b.spec.Key] = []byte(value)
+	})
+}
+
+func (b *k8sSecretBackend) Delete(ctx context.Context, rotatedAt time.Time) error {
+	return b.update(ctx, rotatedAt, func(data map[string][]byte) {
+		delete(data, b.spec.Key)
+	})
+}
+
+// update changes the data of the secret and records rotatedAt
+func (b *k8sSecretBackend) update(ctx context.Context, rotatedAt time.Time, change func(map[string][]byte)) error {
+	secrets := b.toolkit.clientset.CoreV1().Secrets(b.spec.Namespace)
+	secret, err := secrets.Get(ctx, b.spec.Secret, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get secret %s/%s: %w", b.spec.Namespace, b.spec.Secret, err)
+	}
+
+	if secret.Data == nil {
+		secret.Data = make(map[string][]byte)
+	}
+	if secret.Annotations == nil {
+		secret.Annotations = make(map[string]string)
+	}
+	change(secret.Data)
+	secret.Annotations[rotatedAtAnnotation] = rotatedAt.UTC().Format(time.RFC3339)
+
+	if _, err := secrets.Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update secret %s/%s: %w", b.spec.Namespace, b.spec.Secret, err)
+	}
+	return nil
+}
+
+// vaultBackend stores a credential as a key of a Vault KV v2 secret.
+// The client is configured from the standard VAULT_* environment variables.
+// The rotation time is kept in the secret's custom metadata, since a new
+// version is also written whenever another key of the secret changes.
+type vaultBackend struct {
+	client *vault.Client
+	spec   RotationSpec
+}
+
+// Read returns the credential and its rotation time. A secret that does not
+// exist yet is reported as not found and never rotated rather than as an
+// error, so that its first rotation creates it.
+func (b *vaultBackend) Read(ctx context.Context) (string, bool, time.Time, error) {
+	kv := b.client.KVv2(b.spec.Mount)
+	secret, err := kv.Get(ctx, b.spec.Path)
+	if errors.Is(err, vault.ErrSecretNotFound) {
+		return "", false, time.Time{}, nil
+	}
+	if err != nil {
+		return "", false, time.Time{}, fmt.Errorf("failed to read vault secret %s/%s: %w", b.spec.Mount, b.spec.Path, err)
+	}
+
+	metadata, err := kv.GetMetadata(ctx, b.spec.Path)
+	if err != nil {
+		return "", false, time.Time{}, fmt.Errorf("failed to read metadata of vault secret %s/%s: %w", b.spec.Mount, b.spec.Path, err)
+	}
+	// Secrets the toolkit has not rotated yet count from their creation
+	rotatedAt := metadata.CreatedTime
+	if ts, ok := metadata.CustomMetadata[rotatedAtMetadata].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339, ts); err == nil {
+			rotatedAt = parsed
+		}
+	}
+
+	raw, found := secret.Data[b.spec.Key]
+	value, _ := raw.(string)
+	return value, found, rotatedAt, nil
+}
+
+func (b *vaultBackend) Write(ctx context.Context, value string, rotatedAt time.Time) error {
+	return b.update(ctx, rotatedAt, func(data map[string]interface{}) {
+		data[b.spec.Key] = value
+	})
+}
+
+func (b *vaultBackend) Delete(ctx context.Context, rotatedAt time.Time) error {
+	return b.update(ctx, rotatedAt, func(data map[string]interface{}) {
+		delete(data, b.spec.Key)
+	})
+}
+
+// update writes a new version of the secret with change applied to the
+// current data and records rotatedAt in its custom metadata. The other keys
+// stored alongside the rotated one are kept, so any error reading them other
+// than the secret not existing yet is returned rather than writing a version
+// with only the rotated key.
+func (b *vaultBackend) update(ctx context.Context, rotatedAt time.Time, change func(map[string]interface{})) error {
+	kv := b.client.KVv2(b.spec.Mount)
+
+	data := make(map[string]interface{})
+	current, err := kv.Get(ctx, b.spec.Path)
+	switch {
+	case err == nil:
+		for k, v := range current.Data {
+			data[k] = v
+		}
+	case !errors.Is(err, vault.ErrSecretNotFound):
+		return fmt.Errorf("failed to read vault secret %s/%s: %w", b.spec.Mount, b.spec.Path, err)
+	}
+	change(data)
+
+	if _, err := kv.Put(ctx, b.spec.Path, data); err != nil {
+		return fmt.Errorf("failed to write vault secret %s/%s: %w", b.spec.Mount, b.spec.Path, err)
+	}
+
+	// A rollback to a never rotated secret clears the time again
+	custom := map[string]interface{}{rotatedAtMetadata: nil}
+	if !rotatedAt.IsZero() {
+		custom[rotatedAtMetadata] = rotatedAt.UTC().Format(time.RFC3339)
+	}
+	if err := kv.PatchMetadata(ctx, b.spec.Path, vault.KVMetadataPatchInput{CustomMetadata: custom}); err != nil {
+		return fmt.Errorf("failed to record rotation time of vault secret %s/%s: %w", b.spec.Mount, b.spec.Path, err)
+	}
+	return nil
+}
+
+// loadRotationConfig reads and validates a rotations file
+func loadRotationConfig(path string) (*RotationConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var config RotationConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for _, spec := range config.Rotations {
+		if spec.Name == "" || spec.Key == "" {
+			return nil, fmt.Errorf("rotation entries require name and key")
+		}
+		if _, err := time.ParseDuration(spec.Interval); err != nil {
+			return nil, fmt.Errorf("rotation %s: invalid interval %q: %w", spec.Name, spec.Interval, err)
+		}
+	}
+
+	return &config, nil
+}
+
+// backendFor returns the secret backend configured for a rotation
+func (k *K8sToolkit) backendFor(spec RotationSpec) (secretBackend, error) {
+	switch spec.Backend {
+	case "", "kubernetes":
+		return &k8sSecretBackend{toolkit: k, spec: spec}, nil
+	case "vault":
+		client, err := vault.NewClient(vault.DefaultConfig())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create vault client: %w", err)
+		}
+		return &vaultBackend{client: client, spec: spec}, nil
+	default:
+		return nil, fmt.Errorf("unknown backend %q", spec.Backend)
+	}
+}
+
+// generateSecretValue creates a new credential value for the given generator
+func generateSecretValue(generator string, length int) (string, error) {
+	if length <= 0 {
+		length = 32
+	}
+
+	switch generator {
+	case "", "password":
+		const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!#%+-=_"
+		value := make([]byte, length)
+		for i := range value {
+			n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+			if err != nil {
+				return "", err
+			}
+			value[i] = charset[n.Int64()]
+		}
+		return string(value), nil
+	case "api-key":
+		buf := make([]byte, length)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(buf), nil
+	default:
+		return "", fmt.Errorf("unknown generator %q", generator)
+	}
+}
+
+// RotationStatuses reports the due date of every configured rotation.
+// Rotations due within rotation.warn_within are flagged as due soon.
+func (k *K8sToolkit) RotationStatuses(ctx context.Context, config *RotationConfig) []RotationStatus {
+	var statuses []RotationStatus
+	now := time.Now()
+	warnWithin := viper.GetDuration("rotation.warn_within")
+
+	for _, spec := range config.Rotations {
+		status := RotationStatus{Name: spec.Name, Backend: spec.Backend}
+
+		backend, err := k.backendFor(spec)
+		if err != nil {
+			status.Error = err.Error()
+			statuses = append(statuses, status)
+			continue
+		}
+
+		_, _, rotatedAt, err := backend.Read(ctx)
+		if err != nil {
+			status.Error = err.Error()
+			statuses = append(statuses, status)
+			continue
+		}
+
+		interval, _ := time.ParseDuration(spec.Interval)
+		status.RotatedAt = rotatedAt
+		status.DueAt = now
+		if !rotatedAt.IsZero() {
+			status.DueAt = rotatedAt.Add(interval)
+		}
+		status.Overdue = !now.Before(status.DueAt)
+		status.DueSoon = !status.Overdue && now.Add(warnWithin).After(status.DueAt)
+		statuses = append(statuses, status)
+	}
+
+	return statuses
+}
+
+// rotationResult converts a rotation status into a health check result for
+// notifications: Critical when overdue or unreadable, Warning when due soon
+func rotationResult(status RotationStatus) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Credential rotation " + status.Name,
+		Timestamp: time.Now(),
+		Details:   map[string]string{"backend": status.Backend},
+		Status:    "Healthy",
+		Message:   fmt.Sprintf("%s is due %s", status.Name, status.DueAt.Format("2006-01-02")),
+	}
+	if !status.RotatedAt.IsZero() {
+		result.Details["rotated_at"] = status.RotatedAt.UTC().Format(time.RFC3339)
+	}
+	switch {
+	case status.Error != "":
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("Failed to read %s: %s", status.Name, status.Error)
+	case status.RotatedAt.IsZero():
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("%s was never rotated", status.Name)
+	case status.Overdue:
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("%s is overdue since %s", status.Name, status.DueAt.Format("2006-01-02"))
+	case status.DueSoon:
+		result.Status = "Warning"
+	}
+	return result
+}
+
+// notifyRotations sends overdue and soon due rotations to the configured
+// notification targets. Each rotation is a check named rotation-<name>, so
+// targets can route them with a pattern such as "rotation-*".
+func notifyRotations(ctx context.Context, statuses []RotationStatus) {
+	notifier, err := NewNotifier()
+	if err != nil {
+		logger.Fatalf("Failed to configure notifications: %v", err)
+	}
+	for _, status := range statuses {
+		notifier.Observe(ctx, "rotation", "rotation-"+status.Name, rotationResult(status))
+	}
+}
+
+// Rotate replaces a credential, restarts dependent deployments and verifies
+// they become healthy. On failure the previous value is restored, or the key
+// removed again when it did not exist before.
+func (k *K8sToolkit) Rotate(ctx context.Context, spec RotationSpec, timeout time.Duration) error {
+	backend, err := k.backendFor(spec)
+	if err != nil {
+		return err
+	}
+
+	oldValue, oldFound, oldRotatedAt, err := backend.Read(ctx)
+	if err != nil {
+		return err
+	}
+
+	newValue, err := generateSecretValue(spec.Generator, spec.Length)
+	if err != nil {
+		return fmt.Errorf("failed to generate value: %w", err)
+	}
+
+	k.log().Infof("Rotating %s", spec.Name)
+	if err := backend.Write(ctx, newValue, time.Now()); err != nil {
+		return err
+	}
+
+	verifyErr := k.restartAndVerify(ctx, spec, timeout)
+	if verifyErr == nil {
+		k.log().Infof("Rotation of %s finalized", spec.Name)
+		return nil
+	}
+
+	k.log().Warnf("Rotation of %s failed verification, rolling back: %v", spec.Name, verifyErr)
+	rollback := backend.Write
+	if !oldFound {
+		rollback = func(ctx context.Context, _ string, rotatedAt time.Time) error {
+			return backend.Delete(ctx, rotatedAt)
+		}
+	}
+	if err := rollback(ctx, oldValue, oldRotatedAt); err != nil {
+		return fmt.Errorf("rollback failed after verification error (%v): %w", verifyErr, err)
+	}
+	if err := k.restartAndVerify(ctx, spec, timeout); err != nil {
+		return fmt.Errorf("rolled back but workloads are still unhealthy (%v): %w", verifyErr, err)
+	}
+
+	return fmt.Errorf("rotation rolled back: %w", verifyErr)
+}
+
+// restartAndVerify restarts the deployments that consume a credential and
+// waits for their rollouts to complete
+func (k *K8sToolkit) restartAndVerify(ctx context.Context, spec RotationSpec, timeout time.Duration) error {
+	for _, name := range spec.Restart {
+		if err := k.restartDeployment(ctx, spec.Namespace, name); err != nil {
+			return err
+		}
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for _, name := range spec.Restart {
+		if err := k.waitForDeployment(waitCtx, spec.Namespace, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restartDeployment triggers a rolling restart the same way `kubectl rollout restart` does
+func (k *K8sToolkit) restartDeployment(ctx context.Context, namespace, name string) error {
+	patch := fmt.Sprintf(`{"spec":{"template":{"metadata":{"annotations":{"kubectl.kubernetes.io/restartedAt":%q}}}}}`,
+		time.Now().Format(time.RFC3339))
+
+	_, err := k.clientset.AppsV1().Deployments(namespace).Patch(ctx, name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to restart deployment %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// waitForDeployment polls a deployment until all replicas are updated and available
+func (k *K8sToolkit) waitForDeployment(ctx context.Context, namespace, name string) error {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		deployment, err := k.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get deployment %s/%s: %w", namespace, name, err)
+		}
+
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		if deployment.Status.ObservedGeneration >= deployment.Generation &&
+			deployment.Status.UpdatedReplicas == replicas &&
+			deployment.Status.AvailableReplicas == replicas {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("deployment %s/%s did not become available: %d/%d updated, %d/%d available",
+				namespace, name, deployment.Status.UpdatedReplicas, replicas, deployment.Status.AvailableReplicas, replicas)
+		case <-ticker.C:
+		}
+	}
+}
+
+// createRotateCmd creates the rotate command
+func createRotateCmd() *cobra.Command {
+	var file string
+
+	rotateCmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Rotate credentials defined in a rotations file",
+		Long: `Manages credential rotation for Kubernetes Secrets and Vault KV secrets, including dependent deployment restarts, health verification and rollback.
+
+Credentials that are overdue, never rotated or due within rotation.warn_within
+are sent to the notification targets as checks named rotation-<name>.`,
+	}
+	rotateCmd.PersistentFlags().StringVarP(&file, "file", "f", "rotations.yaml", "Path to the rotations file")
+
+	var name string
+	var force bool
+	var dryRun bool
+	var timeout time.Duration
+
+	runCmd := &cobra.Command{
+		Use:   "run",
+		Short: "Rotate every credential that is due",
+		Run: func(cmd *cobra.Command, args []string) {
+			config, err := loadRotationConfig(file)
+			if err != nil {
+				logger.Fatalf("Failed to load rotations: %v", err)
+			}
+
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx := context.Background()
+			statuses := toolkit.RotationStatuses(ctx, config)
+
+			failed := 0
+			var due []RotationSpec
+			for i, spec := range config.Rotations {
+				if name != "" && spec.Name != name {
+					continue
+				}
+				if statuses[i].Error != "" {
+					logger.Infof("Skipping %s: %s", spec.Name, statuses[i].Error)
+					failed++
+					continue
+				}
+				if !force && !statuses[i].Overdue {
+					continue
+				}
+				if dryRun {
+					fmt.Printf("Would rotate %s (due %s)\n", spec.Name, statuses[i].DueAt.Format("2006-01-02"))
+					continue
+				}
+				due = append(due, spec)
+			}
+
+			if len(due) > 0 {
+				names := make([]string, len(due))
+				for i, spec := range due {
+					names[i] = spec.Name
+				}
+				m, err := beginMutation("rotate run", fmt.Sprintf("About to rotate %s and restart dependent deployments.", strings.Join(names, ", ")))
+				if err != nil {
+					logger.Fatalf("Rotation aborted: %v", err)
+				}
+				for _, spec := range due {
+					err := toolkit.Rotate(ctx, spec, timeout)
+					m.record("rotate", spec.Name, err)
+					if err != nil {
+						logger.Errorf("Rotation of %s failed: %v", spec.Name, err)
+						failed++
+					}
+				}
+			}
+
+			// Alert on what is still overdue after this run, such as rolled back rotations
+			if !dryRun {
+				notifyRotations(ctx, toolkit.RotationStatuses(ctx, config))
+			}
+
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	}
+	runCmd.Flags().StringVar(&name, "name", "", "Only rotate the named credential")
+	runCmd.Flags().BoolVar(&force, "force", false, "Rotate even if the credential is not due")
+	runCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print the rotations that would run")
+	runCmd.Flags().DurationVar(&timeout, "timeout", 5*time.Minute, "Time to wait for restarted deployments to become available")
+
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show rotation due dates",
+		Run: func(cmd *cobra.Command, args []string) {
+			config, err := loadRotationConfig(file)
+			if err != nil {
+				logger.Fatalf("Failed to load rotations: %v", err)
+			}
+
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx := context.Background()
+			statuses := toolkit.RotationStatuses(ctx, config)
+			toolkit.PrintRotationStatuses(statuses)
+			notifyRotations(ctx, statuses)
+
+			// Exit with non-zero status so schedulers can alert on overdue credentials
+			for _, status := range statuses {
+				if status.Overdue || status.Error != "" {
+					os.Exit(1)
+				}
+			}
+		},
+	}
+
+	rotateCmd.AddCommand(runCmd, statusCmd)
+	return rotateCmd
+}
+
+// PrintRotationStatuses prints rotation due dates
+func (k *K8sToolkit) PrintRotationStatuses(statuses []RotationStatus) {
+	if k.filtered(statuses) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(statuses)
+		return
+	}
+
+	fmt.Printf("%-30s %-12s %-12s %-12s %s\n", "NAME", "BACKEND", "ROTATED", "DUE", "STATUS")
+	for _, status := range statuses {
+		state := "OK"
+		switch {
+		case status.Error != "":
+			state = "Error: " + status.Error
+		case status.RotatedAt.IsZero():
+			state = "Never rotated"
+		case status.Overdue:
+			state = "Overdue"
+		case status.DueSoon:
+			state = "Due soon"
+		}
+		rotated := "never"
+		if !status.RotatedAt.IsZero() {
+			rotated = status.RotatedAt.Format("2006-01-02")
+		}
+		fmt.Printf("%-30s %-12s %-12s %-12s %s\n", status.Name, status.Backend,
+			rotated, status.DueAt.Format("2006-01-02"), state)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// sarifSchema is the SARIF version findings are emitted in
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// sarifLevels maps finding severities to SARIF result levels
+var sarifLevels = map[string]string{"Critical": "error", "High": "error", "Medium": "warning", "Low": "note"}
+
+// sarifSecuritySeverity maps finding severities to the CVSS-like score GitHub
+// code scanning uses to rank security alerts
+var sarifSecuritySeverity = map[string]string{"Critical": "9.5", "High": "8.0", "Medium": "5.5", "Low": "3.0"}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifRule struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name"`
+	ShortDescription     sarifMessage           `json:"shortDescription"`
+	FullDescription      sarifMessage           `json:"fullDescription"`
+	Help                 *sarifMessage          `json:"help,omitempty"`
+	DefaultConfiguration map[string]string      `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation struct {
+		URI string `json:"uri"`
+	} `json:"artifactLocation"`
+}
+
+type sarifResult struct {
+	RuleID              string                 `json:"ruleId"`
+	RuleIndex           int                    `json:"ruleIndex"`
+	Level               string                 `json:"level"`
+	Message             sarifMessage           `json:"message"`
+	Locations           []sarifLocation        `json:"locations"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver struct {
+			Name           string      `json:"name"`
+			Version        string      `json:"version"`
+			InformationURI string      `json:"informationUri"`
+			Rules          []sarifRule `json:"rules"`
+		} `json:"driver"`
+	} `json:"tool"`
+	AutomationDetails struct {
+		ID          string       `json:"id"`
+		Description sarifMessage `json:"description"`
+	} `json:"automationDetails"`
+	Results []sarifResult `json:"results"`
+}
+
+// SARIFLog is a SARIF 2.1.0 document with a single run
+type SARIFLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+// nonSlug matches the characters replaced when deriving a SARIF category
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// findingLocator is the resource path of a finding, namespace/resource for
+// namespaced resources
+func findingLocator(f Finding) string {
+	if f.Namespace == "" {
+		return f.Resource
+	}
+	return f.Namespace + "/" + f.Resource
+}
+
+// BuildSARIF converts findings into a SARIF log. Each distinct rule ID becomes
+// a rule of the run, and resources are reported as logical locations since
+// findings come from the live cluster rather than source files. The title is
+// turned into the run category so the reports of different audits uploaded to
+// the same repository do not replace each other.
+func BuildSARIF(title string, findings []Finding) *SARIFLog {
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver.Name = "k8s-toolkit"
+	run.Tool.Driver.Version = version
+	run.Tool.Driver.InformationURI = "https://github.com/devops-excellence/automation"
+	run.Tool.Driver.Rules = []sarifRule{}
+	run.AutomationDetails.ID = "k8s-toolkit/" + strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(title), "-"), "-") + "/"
+	run.AutomationDetails.Description = sarifMessage{Text: title}
+
+	ruleIndex := make(map[string]int)
+	for _, f := range findings {
+		index, ok := ruleIndex[f.RuleID]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndex[f.RuleID] = index
+			rule := sarifRule{
+				ID:                   f.RuleID,
+				Name:                 f.RuleID,
+				ShortDescription:     sarifMessage{Text: fmt.Sprintf("%s: %s", f.Source, f.RuleID)},
+				FullDescription:      sarifMessage{Text: f.Message},
+				DefaultConfiguration: map[string]string{"level": sarifLevels[f.Severity]},
+				Properties: map[string]interface{}{
+					"security-severity": sarifSecuritySeverity[f.Severity],
+					"tags":              append([]string{"security", "kubernetes", f.Source}, f.Controls...),
+				},
+			}
+			if f.Remediation != "" {
+				rule.Help = &sarifMessage{Text: f.Remediation}
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		locator := findingLocator(f)
+		location := sarifLocation{
+			PhysicalLocation: &sarifPhysicalLocation{},
+			LogicalLocations: []sarifLogicalLocation{{Name: f.Resource, FullyQualifiedName: locator, Kind: "resource"}},
+		}
+		location.PhysicalLocation.ArtifactLocation.URI = "k8s/" + locator
+
+		level := sarifLevels[f.Severity]
+		if level == "" {
+			level = "warning"
+		}
+		result := sarifResult{
+			RuleID:              f.RuleID,
+			RuleIndex:           index,
+			Level:               level,
+			Message:             sarifMessage{Text: f.Message},
+			Locations:           []sarifLocation{location},
+			PartialFingerprints: map[string]string{"k8sToolkitFinding/v1": findingFingerprint(f)},
+			Properties:          map[string]interface{}{"severity": f.Severity},
+		}
+		if f.Namespace != "" {
+			result.Properties["namespace"] = f.Namespace
+		}
+		if f.Remediation != "" {
+			result.Properties["remediation"] = f.Remediation
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return &SARIFLog{Schema: sarifSchema, Version: "2.1.0", Runs: []sarifRun{run}}
+}
+
+// printSARIF writes findings as a SARIF log to stdout. Reports are not signed,
+// since code scanning uploads must be plain SARIF.
+func printSARIF(title string, findings []Finding) {
+	data, err := json.MarshalIndent(BuildSARIF(title, findings), "", "  ")
+	if err != nil {
+		logger.Fatalf("Failed to marshal SARIF: %v", err)
+	}
+	fmt.Fprintln(os.Stdout, string(data))
+}
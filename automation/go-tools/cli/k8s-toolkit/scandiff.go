@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// generatedPodSuffix matches the suffixes controllers append to pod names:
+// the ReplicaSet hash plus a random suffix, or only the random suffix
+var generatedPodSuffix = regexp.MustCompile(`(-[a-z0-9]{8,10})?-[a-z0-9]{5}$`)
+
+// SecurityDiff is the difference in security findings between two clusters
+type SecurityDiff struct {
+	ContextA string            `json:"context_a"`
+	ContextB string            `json:"context_b"`
+	OnlyA    []Finding         `json:"only_a"`
+	OnlyB    []Finding         `json:"only_b"`
+	Common   int               `json:"common"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// findingIdentity identifies a finding across clusters. Generated pod name
+// suffixes are dropped so pods of the same workload compare equal, and the
+// message is ignored since it may contain cluster-specific values.
+func findingIdentity(f Finding) string {
+	resource := f.Resource
+	if strings.HasPrefix(resource, "pod/") {
+		resource = generatedPodSuffix.ReplaceAllString(resource, "")
+	}
+	return strings.Join([]string{f.Source, f.RuleID, f.Namespace, resource}, "|")
+}
+
+// scanContext runs the named finding sources against one kubeconfig context
+func scanContext(ctx context.Context, kubeContext string, sources []string, opts FindingSourceOptions) ([]Finding, map[string]string, error) {
+	toolkit, err := NewK8sToolkitForContext(kubeContext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	findings, sourceFailures := toolkit.runFindingSources(ctx, sources, opts)
+	failures := make(map[string]string, len(sourceFailures))
+	for name, msg := range sourceFailures {
+		failures[kubeContext+"/"+name] = msg
+	}
+	return findings, failures, nil
+}
+
+// runFindingSources runs the named finding sources, or all of them when
+// sources is empty. Failed sources are returned by name with their error
+// category.
+func (k *K8sToolkit) runFindingSources(ctx context.Context, sources []string, opts FindingSourceOptions) ([]Finding, map[string]string) {
+	wanted := make(map[string]bool)
+	for _, name := range sources {
+		wanted[name] = true
+	}
+	failures := make(map[string]string)
+	var findings []Finding
+	for _, source := range k.findingSources(opts) {
+		if len(wanted) > 0 && !wanted[source.name] {
+			continue
+		}
+		sourceFindings, err := source.run(ctx)
+		if err != nil {
+			failures[source.name] = fmt.Sprintf("%s: %v", errorCategory(err), err)
+			continue
+		}
+		findings = append(findings, sourceFindings...)
+	}
+	return findings, failures
+}
+
+// DiffSecurityPosture scans both contexts concurrently and returns the
+// findings present in only one of them. A source that fails in either
+// cluster is left out of the comparison for both, so it does not show up as
+// a difference.
+func DiffSecurityPosture(ctx context.Context, contextA, contextB string, sources []string, opts FindingSourceOptions) (*SecurityDiff, error) {
+	var wg sync.WaitGroup
+	var findings [2][]Finding
+	var failures [2]map[string]string
+	var errs [2]error
+	for i, kubeContext := range []string{contextA, contextB} {
+		wg.Add(1)
+		go func(i int, kubeContext string) {
+			defer wg.Done()
+			findings[i], failures[i], errs[i] = scanContext(ctx, kubeContext, sources, opts)
+		}(i, kubeContext)
+	}
+	wg.Wait()
+	for i, kubeContext := range []string{contextA, contextB} {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", kubeContext, errs[i])
+		}
+	}
+
+	diff := &SecurityDiff{ContextA: contextA, ContextB: contextB, Errors: make(map[string]string)}
+	failedSource := make(map[string]bool)
+	for i, kubeContext := range []string{contextA, contextB} {
+		for key, msg := range failures[i] {
+			diff.Errors[key] = msg
+			failedSource[strings.TrimPrefix(key, kubeContext+"/")] = true
+		}
+	}
+
+	index := func(list []Finding) map[string]bool {
+		keys := make(map[string]bool, len(list))
+		for _, f := range list {
+			keys[findingIdentity(f)] = true
+		}
+		return keys
+	}
+	inA, inB := index(findings[0]), index(findings[1])
+
+	common := make(map[string]bool)
+	for _, f := range findings[0] {
+		key := findingIdentity(f)
+		switch {
+		case failedSource[f.Source]:
+		case !inB[key]:
+			diff.OnlyA = append(diff.OnlyA, f)
+		default:
+			common[key] = true
+		}
+	}
+	for _, f := range findings[1] {
+		if !failedSource[f.Source] && !inA[findingIdentity(f)] {
+			diff.OnlyB = append(diff.OnlyB, f)
+		}
+	}
+	diff.Common = len(common)
+	sortFindings(diff.OnlyA)
+	sortFindings(diff.OnlyB)
+	return diff, nil
+}
+
+// PrintSecurityDiff prints the findings unique to each cluster
+func (k *K8sToolkit) PrintSecurityDiff(diff *SecurityDiff) {
+	if k.filtered(diff) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(diff)
+		return
+	}
+
+	for _, side := range []struct {
+		context  string
+		findings []Finding
+	}{{diff.ContextA, diff.OnlyA}, {diff.ContextB, diff.OnlyB}} {
+		data := struct {
+			Title  string
+			Groups []findingGroup
+		}{Title: tr("Findings Only in %s", side.context), Groups: groupFindings(side.findings)}
+		if err := renderText(os.Stdout, "findings.txt.tmpl", data); err != nil {
+			logger.Fatalf("Failed to render findings: %v", err)
+		}
+	}
+
+	fmt.Printf("\n%d findings only in %s, %d only in %s, %d in both\n",
+		len(diff.OnlyA), diff.ContextA, len(diff.OnlyB), diff.ContextB, diff.Common)
+	for key, msg := range diff.Errors {
+		fmt.Printf("⚠️  %s not compared: %s\n", key, msg)
+	}
+}
+
+// createSecurityDiffCmd creates the security diff command
+func createSecurityDiffCmd() *cobra.Command {
+	var contextA, contextB string
+	var sources []string
+	var failOnDiff bool
+	var sourceOpts FindingSourceOptions
+
+	diffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Compare security findings between two clusters",
+		Long: `Runs the security audits against two kubeconfig contexts and reports the findings present in
+one cluster but not the other, to verify hardening changes were rolled out consistently. Findings
+are matched by source, rule, namespace and resource; generated pod name suffixes are ignored so
+pods of the same workload match across clusters.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if contextA == "" || contextB == "" {
+				logger.Fatalf("Both --context-a and --context-b are required")
+			}
+
+			toolkit, err := NewK8sToolkitForContext(contextA)
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			diff, err := DiffSecurityPosture(context.Background(), contextA, contextB, sources, sourceOpts)
+			if err != nil {
+				logger.Fatalf("Failed to compare clusters: %v", err)
+			}
+
+			toolkit.PrintSecurityDiff(diff)
+			if failOnDiff && (len(diff.OnlyA) > 0 || len(diff.OnlyB) > 0) {
+				os.Exit(1)
+			}
+		},
+	}
+
+	diffCmd.Flags().StringVar(&contextA, "context-a", "", "First kubeconfig context")
+	diffCmd.Flags().StringVar(&contextB, "context-b", "", "Second kubeconfig context")
+	diffCmd.Flags().StringSliceVar(&sources, "sources", []string{"security-pods", "security-images", "security-netpol", "security-secrets", "security-serviceaccounts"}, "Finding sources to compare")
+	diffCmd.Flags().BoolVar(&failOnDiff, "fail-on-diff", false, "Exit non-zero when the clusters differ")
+	addFindingSourceFlags(diffCmd, &sourceOpts)
+
+	return diffCmd
+}
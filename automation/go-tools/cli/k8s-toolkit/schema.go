@@ -0,0 +1,58 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// JSON outputs carry schema_version "<major>.<minor>". The minor version is
+// bumped for additive changes: new optional fields or new enum values.
+// Removing or renaming a field, changing its type or making it required
+// bumps the major version, and the previous major version stays available
+// for at least one release. The schemas are published under schemas/ and
+// printed by --schema.
+const (
+	clusterHealthSchemaVersion   = "1.0"
+	findingsSchemaVersion        = "1.0"
+	imageInventorySchemaVersion  = "1.0"
+	vulnerabilitiesSchemaVersion = "1.0"
+)
+
+//go:embed schemas/*.schema.json
+var bundledSchemas embed.FS
+
+// schemaAnnotation names the schema of a command's -o json output
+const schemaAnnotation = "k8s-toolkit/schema"
+
+// withSchema marks cmd as printing JSON of the named schema, which --schema prints
+func withSchema(cmd *cobra.Command, schema string) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[schemaAnnotation] = schema
+	return cmd
+}
+
+// printSchema prints the JSON Schema of cmd's output and exits, for --schema
+func printSchema(cmd *cobra.Command) {
+	schema, ok := cmd.Annotations[schemaAnnotation]
+	if !ok {
+		logger.Fatalf("%s has no published JSON schema", cmd.CommandPath())
+	}
+	data, err := bundledSchemas.ReadFile("schemas/" + schema + ".schema.json")
+	if err != nil {
+		logger.Fatalf("Failed to read schema %s: %v", schema, err)
+	}
+	fmt.Print(string(data))
+	os.Exit(0)
+}
+
+// FindingsReport is the JSON form of the finding-based audits
+type FindingsReport struct {
+	SchemaVersion string    `json:"schema_version"`
+	Title         string    `json:"title"`
+	Findings      []Finding `json:"findings"`
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// clusterScopedChecks read cluster-scoped objects or kube-system, which a
+// user with namespace-level access cannot list. They are skipped when
+// --namespace is set instead of failing with RBAC errors.
+var clusterScopedChecks = map[string]bool{
+	"nodes":              true,
+	"node-conditions":    true,
+	"system-pods":        true,
+	"resource-usage":     true,
+	"pvs":                true,
+	"daemonsets":         true,
+	"admission-webhooks": true,
+	"mesh-istiod":        true,
+	"mesh-injection":     true,
+	"mesh-proxies":       true,
+}
+
+// skippedForScope reports whether check cannot run within the namespace scope
+func (k *K8sToolkit) skippedForScope(check string) bool {
+	return k.namespace != "" && clusterScopedChecks[check]
+}
+
+// listOptions adds the --selector label selector to opts. It is meant for
+// pods and workloads, the objects teams label; secrets, events and policy
+// objects are listed without it.
+func (k *K8sToolkit) listOptions(opts metav1.ListOptions) metav1.ListOptions {
+	switch {
+	case k.selector == "":
+	case opts.LabelSelector == "":
+		opts.LabelSelector = k.selector
+	default:
+		opts.LabelSelector += "," + k.selector
+	}
+	return opts
+}
+
+// inScope reports whether objects in namespace belong in the report, i.e.
+// the namespace is not listed in --exclude-namespaces
+func (k *K8sToolkit) inScope(namespace string) bool {
+	for _, excluded := range k.excludeNamespaces {
+		if namespace == excluded {
+			return false
+		}
+	}
+	return true
+}
+
+// inScopeItems returns the items of a list that are in scope, reusing its
+// backing array
+func inScopeItems[T any, PT interface {
+	*T
+	metav1.Object
+}](k *K8sToolkit, items []T) []T {
+	if len(k.excludeNamespaces) == 0 {
+		return items
+	}
+	kept := items[:0]
+	for i := range items {
+		if k.inScope(PT(&items[i]).GetNamespace()) {
+			kept = append(kept, items[i])
+		}
+	}
+	return kept
+}
+
+// narrowedTo returns a copy of the toolkit scoped to a namespace a remote
+// caller asked for. The request can only narrow the configured scope: with
+// --namespace set it must name that namespace or none, and it cannot name a
+// namespace in --exclude-namespaces.
+func (k *K8sToolkit) narrowedTo(namespace string) (*K8sToolkit, error) {
+	scoped := *k
+	if namespace == "" {
+		return &scoped, nil
+	}
+	if k.namespace != "" && namespace != k.namespace {
+		return nil, fmt.Errorf("namespace %s is outside the configured namespace %s", namespace, k.namespace)
+	}
+	if !k.inScope(namespace) {
+		return nil, fmt.Errorf("namespace %s is excluded", namespace)
+	}
+	scoped.namespace = namespace
+	return &scoped, nil
+}
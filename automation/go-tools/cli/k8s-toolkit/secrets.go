@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// registryProbeTimeout bounds each registry liveness probe
+const registryProbeTimeout = 5 * time.Second
+
+// credentialPatterns recognize secret values that hold private keys or
+// long-lived cloud credentials. Values are matched but never reported.
+var credentialPatterns = []struct {
+	rule     string
+	severity string
+	what     string
+	pattern  *regexp.Regexp
+}{
+	{"cloud-credential", "High", "an AWS access key", regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"cloud-credential", "High", "a GCP service account key", regexp.MustCompile(`"type"\s*:\s*"service_account"[\s\S]*"private_key"`)},
+	{"cloud-credential", "High", "an Azure storage account key", regexp.MustCompile(`AccountKey=[A-Za-z0-9+/=]{40,}`)},
+	{"private-key", "Medium", "a private key", regexp.MustCompile(`-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`)},
+}
+
+// SecretsAuditOptions configures AuditSecrets
+type SecretsAuditOptions struct {
+	// ProbeRegistries checks docker-registry secrets against their registries.
+	// It has no effect in offline mode.
+	ProbeRegistries bool
+}
+
+// secretUsage records how pods consume a secret
+type secretUsage struct {
+	env   []string
+	files bool
+	pull  bool
+}
+
+// AuditSecrets reports secrets exposed through environment variables, secrets
+// no pod uses, values that look like private keys or cloud credentials, and
+// docker-registry secrets whose registries do not respond
+func (k *K8sToolkit) AuditSecrets(ctx context.Context, opts SecretsAuditOptions) ([]Finding, error) {
+	usage := make(map[string]*secretUsage)
+	use := func(namespace, name string) *secretUsage {
+		key := namespace + "/" + name
+		if usage[key] == nil {
+			usage[key] = &secretUsage{}
+		}
+		return usage[key]
+	}
+
+	var findings []Finding
+	add := func(rule, severity, namespace, resource, message string) {
+		findings = append(findings, Finding{
+			Source:    "security-secrets",
+			RuleID:    "secret/" + rule,
+			Severity:  severity,
+			Namespace: namespace,
+			Resource:  resource,
+			Message:   message,
+		})
+	}
+
+	err := k.eachPod(ctx, k.namespace, metav1.ListOptions{}, func(pod *corev1.Pod) error {
+		for _, secret := range pod.Spec.ImagePullSecrets {
+			use(pod.Namespace, secret.Name).pull = true
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.Secret != nil {
+				use(pod.Namespace, volume.Secret.SecretName).files = true
+			}
+			if volume.Projected != nil {
+				for _, source := range volume.Projected.Sources {
+					if source.Secret != nil {
+						use(pod.Namespace, source.Secret.Name).files = true
+					}
+				}
+			}
+		}
+
+		containers := append(append([]corev1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...)
+		for _, container := range containers {
+			consumer := "pod/" + pod.Name + "/" + container.Name
+			for _, envFrom := range container.EnvFrom {
+				if envFrom.SecretRef != nil {
+					u := use(pod.Namespace, envFrom.SecretRef.Name)
+					u.env = append(u.env, consumer)
+				}
+			}
+			for _, env := range container.Env {
+				if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+					u := use(pod.Namespace, env.ValueFrom.SecretKeyRef.Name)
+					u.env = append(u.env, consumer)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	// Registries referenced by docker-registry secrets, probed once each
+	registrySecrets := make(map[string][]string)
+
+	err = k.eachSecret(ctx, k.namespace, metav1.ListOptions{}, func(secret *corev1.Secret) error {
+		if !k.inScope(secret.Namespace) {
+			return nil
+		}
+		resource := "secret/" + secret.Name
+		u := usage[secret.Namespace+"/"+secret.Name]
+
+		switch secret.Type {
+		case corev1.SecretTypeServiceAccountToken, "helm.sh/release.v1":
+			// Consumed by the API server and Helm rather than pod specs
+		default:
+			if u == nil && secret.Type != corev1.SecretTypeTLS {
+				add("unused", "Low", secret.Namespace, resource, "not referenced by any pod")
+			}
+		}
+
+		if u != nil && len(u.env) > 0 {
+			consumers := dedupeStrings(u.env)
+			add("env-exposure", "Medium", secret.Namespace, resource,
+				fmt.Sprintf("exposed as environment variables to %s; mount as files instead", strings.Join(consumers, ", ")))
+		}
+
+		if secret.Type != corev1.SecretTypeTLS && secret.Type != corev1.SecretTypeSSHAuth {
+			for key, value := range secret.Data {
+				if rule, severity, what := matchCredential(value); rule != "" {
+					add(rule, severity, secret.Namespace, resource+"/"+key, fmt.Sprintf("key %q appears to hold %s", key, what))
+				}
+			}
+		}
+
+		if secret.Type == corev1.SecretTypeDockerConfigJson || secret.Type == corev1.SecretTypeDockercfg {
+			for _, registry := range dockerRegistries(secret) {
+				registrySecrets[registry] = append(registrySecrets[registry], secret.Namespace+"/"+secret.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	if opts.ProbeRegistries && !offline() {
+		for registry, probeErr := range probeRegistries(ctx, registrySecrets) {
+			for _, secret := range registrySecrets[registry] {
+				namespace, name, _ := strings.Cut(secret, "/")
+				add("dead-registry", "Medium", namespace, "secret/"+name,
+					fmt.Sprintf("registry %s is unreachable: %v", registry, probeErr))
+			}
+		}
+	}
+
+	sortFindings(findings)
+	return findings, nil
+}
+
+// matchCredential checks a secret value, and the value decoded once more when
+// it is itself base64, against credentialPatterns
+func matchCredential(value []byte) (rule, severity, what string) {
+	candidates := [][]byte{value}
+	if decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(value))); err == nil {
+		candidates = append(candidates, decoded)
+	}
+
+	for _, candidate := range candidates {
+		for _, p := range credentialPatterns {
+			if p.pattern.Match(candidate) {
+				return p.rule, p.severity, p.what
+			}
+		}
+	}
+	return "", "", ""
+}
+
+// dockerRegistries returns the registry hosts of a docker-registry secret
+func dockerRegistries(secret *corev1.Secret) []string {
+	var auths map[string]json.RawMessage
+	if data, ok := secret.Data[corev1.DockerConfigJsonKey]; ok {
+		var config struct {
+			Auths map[string]json.RawMessage `json:"auths"`
+		}
+		if json.Unmarshal(data, &config) == nil {
+			auths = config.Auths
+		}
+	} else if data, ok := secret.Data[corev1.DockerConfigKey]; ok {
+		json.Unmarshal(data, &auths)
+	}
+
+	var registries []string
+	for server := range auths {
+		host := strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+		host, _, _ = strings.Cut(host, "/")
+		// Docker Hub's legacy auth key
+		if host == "index.docker.io" {
+			host = "registry-1.docker.io"
+		}
+		registries = append(registries, host)
+	}
+	sort.Strings(registries)
+	return registries
+}
+
+// probeRegistries checks each registry's /v2/ endpoint concurrently and
+// returns the registries that did not answer. Any HTTP response, including
+// 401, counts as alive.
+func probeRegistries(ctx context.Context, registries map[string][]string) map[string]error {
+	client := &http.Client{Timeout: registryProbeTimeout}
+	dead := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for registry := range registries {
+		wg.Add(1)
+		go func(registry string) {
+			defer wg.Done()
+			err := probeRegistry(ctx, client, registry)
+			if err != nil {
+				mu.Lock()
+				dead[registry] = err
+				mu.Unlock()
+			}
+		}(registry)
+	}
+	wg.Wait()
+	return dead
+}
+
+func probeRegistry(ctx context.Context, client *http.Client, registry string) error {
+	host := registry
+	if h, _, err := net.SplitHostPort(registry); err == nil {
+		host = h
+	}
+	if _, err := net.DefaultResolver.LookupHost(ctx, host); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+registry+"/v2/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func dedupeStrings(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
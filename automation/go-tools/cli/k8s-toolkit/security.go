@@ -0,0 +1,451 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// severityRank orders finding severities from most to least severe
+var severityRank = map[string]int{"Critical": 4, "High": 3, "Medium": 2, "Low": 1}
+
+// baselineCapabilities may be added under the baseline Pod Security Standard
+var baselineCapabilities = map[corev1.Capability]bool{
+	"AUDIT_WRITE": true, "CHOWN": true, "DAC_OVERRIDE": true, "FOWNER": true, "FSETID": true,
+	"KILL": true, "MKNOD": true, "NET_BIND_SERVICE": true, "SETFCAP": true, "SETGID": true,
+	"SETPCAP": true, "SETUID": true, "SYS_CHROOT": true,
+}
+
+// AuditPodSecurity evaluates pod specs against the baseline or restricted Pod Security Standard
+func (k *K8sToolkit) AuditPodSecurity(ctx context.Context, level string) ([]Finding, error) {
+	var findings []Finding
+	err := k.eachPod(ctx, k.namespace, k.listOptions(metav1.ListOptions{}), func(pod *corev1.Pod) error {
+		if !k.inScope(pod.Namespace) {
+			return nil
+		}
+		findings = append(findings, podSecurityFindings(pod, level == "restricted")...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	sortFindings(findings)
+	return findings, nil
+}
+
+// podSecurityFindings returns the Pod Security Standard violations of a single pod
+func podSecurityFindings(pod *corev1.Pod, restricted bool) []Finding {
+	var findings []Finding
+	add := func(rule, severity, resource, message string) {
+		findings = append(findings, Finding{
+			Source:    "security-pods",
+			RuleID:    "pss/" + rule,
+			Severity:  severity,
+			Namespace: pod.Namespace,
+			Resource:  resource,
+			Message:   message,
+		})
+	}
+
+	podName := "pod/" + pod.Name
+	spec := &pod.Spec
+
+	if spec.HostNetwork || spec.HostPID || spec.HostIPC {
+		add("host-namespaces", "High", podName, "shares host network, PID or IPC namespace")
+	}
+	for _, volume := range spec.Volumes {
+		if volume.HostPath != nil {
+			add("host-path", "High", podName, fmt.Sprintf("mounts hostPath %s", volume.HostPath.Path))
+		}
+	}
+
+	podSC := spec.SecurityContext
+	if podSC == nil {
+		podSC = &corev1.PodSecurityContext{}
+	}
+	if podSC.SeccompProfile != nil && podSC.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+		add("seccomp", "Medium", podName, "pod seccomp profile is Unconfined")
+	}
+
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		resource := podName + "/" + container.Name
+		sc := container.SecurityContext
+		if sc == nil {
+			sc = &corev1.SecurityContext{}
+		}
+
+		if sc.Privileged != nil && *sc.Privileged {
+			add("privileged", "High", resource, "runs privileged")
+		}
+		for _, port := range container.Ports {
+			if port.HostPort != 0 {
+				add("host-ports", "Medium", resource, fmt.Sprintf("binds host port %d", port.HostPort))
+			}
+		}
+		if sc.SeccompProfile != nil && sc.SeccompProfile.Type == corev1.SeccompProfileTypeUnconfined {
+			add("seccomp", "Medium", resource, "container seccomp profile is Unconfined")
+		}
+
+		var added []corev1.Capability
+		if sc.Capabilities != nil {
+			added = sc.Capabilities.Add
+		}
+		for _, capability := range added {
+			if !baselineCapabilities[capability] || (restricted && capability != "NET_BIND_SERVICE") {
+				add("capabilities", "Medium", resource, fmt.Sprintf("adds capability %s", capability))
+			}
+		}
+
+		if !restricted {
+			continue
+		}
+
+		if sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			add("privilege-escalation", "Medium", resource, "allowPrivilegeEscalation is not false")
+		}
+
+		runAsUser := podSC.RunAsUser
+		if sc.RunAsUser != nil {
+			runAsUser = sc.RunAsUser
+		}
+		runAsNonRoot := podSC.RunAsNonRoot
+		if sc.RunAsNonRoot != nil {
+			runAsNonRoot = sc.RunAsNonRoot
+		}
+		switch {
+		case runAsUser != nil && *runAsUser == 0:
+			add("run-as-root", "High", resource, "runs as UID 0")
+		case runAsNonRoot == nil || !*runAsNonRoot:
+			add("run-as-root", "Medium", resource, "runAsNonRoot is not set to true")
+		}
+
+		if sc.SeccompProfile == nil && podSC.SeccompProfile == nil {
+			add("seccomp", "Low", resource, "no seccomp profile (RuntimeDefault or Localhost required)")
+		}
+
+		dropsAll := false
+		if sc.Capabilities != nil {
+			for _, capability := range sc.Capabilities.Drop {
+				if capability == "ALL" {
+					dropsAll = true
+				}
+			}
+		}
+		if !dropsAll {
+			add("drop-all", "Low", resource, "does not drop ALL capabilities")
+		}
+	}
+
+	return findings
+}
+
+// sortFindings orders findings by namespace, then severity, then resource
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Namespace != findings[j].Namespace {
+			return findings[i].Namespace < findings[j].Namespace
+		}
+		if severityRank[findings[i].Severity] != severityRank[findings[j].Severity] {
+			return severityRank[findings[i].Severity] > severityRank[findings[j].Severity]
+		}
+		return findings[i].Resource < findings[j].Resource
+	})
+}
+
+// PrintFindings prints findings grouped by namespace with per-namespace severity counts
+func (k *K8sToolkit) PrintFindings(title string, findings []Finding) {
+	report := FindingsReport{SchemaVersion: findingsSchemaVersion, Title: title, Findings: findings}
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+	if k.output == "sarif" {
+		printSARIF(title, findings)
+		return
+	}
+
+	data := struct {
+		Title  string
+		Groups []findingGroup
+	}{Title: tr(title), Groups: groupFindings(findings)}
+	if err := renderText(os.Stdout, "findings.txt.tmpl", data); err != nil {
+		logger.Fatalf("Failed to render findings: %v", err)
+	}
+}
+
+// findingGroup is the findings of one namespace with severity counts
+type findingGroup struct {
+	Name              string
+	High, Medium, Low int
+	Findings          []Finding
+}
+
+// groupFindings splits sorted findings into consecutive namespace groups
+func groupFindings(findings []Finding) []findingGroup {
+	var groups []findingGroup
+	for i, f := range findings {
+		if i == 0 || f.Namespace != findings[i-1].Namespace {
+			name := f.Namespace
+			if name == "" {
+				name = tr("(cluster)")
+			}
+			groups = append(groups, findingGroup{Name: name})
+		}
+		group := &groups[len(groups)-1]
+		switch f.Severity {
+		case "Critical", "High":
+			group.High++
+		case "Medium":
+			group.Medium++
+		case "Low":
+			group.Low++
+		}
+		group.Findings = append(group.Findings, f)
+	}
+	return groups
+}
+
+// exitOnFindings exits non-zero when any finding is at or above the threshold severity
+func exitOnFindings(findings []Finding, threshold string) {
+	if threshold == "" || threshold == "none" {
+		return
+	}
+	for _, f := range findings {
+		if severityRank[f.Severity] >= severityRank[threshold] {
+			os.Exit(1)
+		}
+	}
+}
+
+// createSecurityCmd creates the security command group
+func createSecurityCmd() *cobra.Command {
+	securityCmd := &cobra.Command{
+		Use:     "security",
+		Aliases: []string{"scan"},
+		Short:   "Security audits for workloads and cluster configuration",
+		Long: `Security audits for workloads and cluster configuration. The pods, images, vulns, secrets,
+serviceaccounts and cis audits accept -o sarif to emit SARIF 2.1.0 for GitHub code scanning and
+other SARIF-aware dashboards; resources are reported as logical locations.`,
+	}
+
+	var level string
+	var failOn string
+
+	podsCmd := &cobra.Command{
+		Use:   "pods",
+		Short: "Audit pods against the Pod Security Standards",
+		Long:  `Evaluates every pod spec against the baseline or restricted Pod Security Standard and reports violations per namespace with severity levels.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if level != "baseline" && level != "restricted" {
+				logger.Fatalf("Invalid --level %q: must be baseline or restricted", level)
+			}
+
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			findings, err := toolkit.AuditPodSecurity(context.Background(), level)
+			if err != nil {
+				logger.Fatalf("Failed to audit pods: %v", err)
+			}
+
+			toolkit.PrintFindings(tr("Pod Security Standards (%s) Report", level), findings)
+			exitOnFindings(findings, failOn)
+		},
+	}
+	podsCmd.Flags().StringVar(&level, "level", "restricted", "Pod Security Standard to evaluate (baseline|restricted)")
+	podsCmd.Flags().StringVar(&failOn, "fail-on", "none", "Exit non-zero on findings at or above this severity (Low|Medium|High|none)")
+
+	var imagesFailOn string
+
+	imagesCmd := &cobra.Command{
+		Use:   "images",
+		Short: "Audit container images for latest tags, missing digests and untrusted registries",
+		Long: `Lists all container images in the cluster per workload, flagging :latest or missing tags,
+images not pinned by digest, and images outside security.allowed_registries from the config file.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			report, err := toolkit.AuditImages(context.Background())
+			if err != nil {
+				logger.Fatalf("Failed to audit images: %v", err)
+			}
+
+			toolkit.PrintImageReport(report)
+			exitOnFindings(report.Findings, imagesFailOn)
+		},
+	}
+	imagesCmd.Flags().StringVar(&imagesFailOn, "fail-on", "none", "Exit non-zero on findings at or above this severity (Low|Medium|High|none)")
+
+	vulnsCmd := &cobra.Command{
+		Use:   "vulns",
+		Short: "Scan running images for vulnerabilities with Trivy",
+		Long: `Enumerates the unique images running in the cluster, scans each with Trivy and aggregates
+CVE counts by severity per workload. Exits non-zero when any workload has vulnerabilities at or
+above the --fail-on severity.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			report, err := toolkit.ScanVulnerabilities(context.Background(), vulnScanOptions())
+			if err != nil {
+				logger.Fatalf("Failed to scan images: %v", err)
+			}
+
+			toolkit.PrintVulnReport(report)
+			exitOnFindings(report.Findings, viper.GetString("security.vulns.fail_on"))
+		},
+	}
+	vulnsCmd.Flags().String("trivy", "trivy", "Path to the trivy binary")
+	vulnsCmd.Flags().Int("parallel", 2, "Number of images to scan concurrently")
+	vulnsCmd.Flags().Duration("scan-timeout", 5*time.Minute, "Timeout for scanning a single image")
+	vulnsCmd.Flags().String("fail-on", "Critical", "Exit non-zero on vulnerabilities at or above this severity (Low|Medium|High|Critical|none)")
+	viper.BindPFlag("security.vulns.trivy_path", vulnsCmd.Flags().Lookup("trivy"))
+	viper.BindPFlag("security.vulns.parallel", vulnsCmd.Flags().Lookup("parallel"))
+	viper.BindPFlag("security.vulns.timeout", vulnsCmd.Flags().Lookup("scan-timeout"))
+	viper.BindPFlag("security.vulns.fail_on", vulnsCmd.Flags().Lookup("fail-on"))
+
+	var excludeNamespaces []string
+	var minCoverage float64
+
+	netpolCmd := &cobra.Command{
+		Use:   "netpol",
+		Short: "Report NetworkPolicy coverage of namespaces and workloads",
+		Long: `Computes which workloads no NetworkPolicy selects, identifies namespaces without a
+default-deny policy, and reports the percentage of workloads covered.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			report, err := toolkit.NetworkPolicyCoverage(context.Background(), excludeNamespaces)
+			if err != nil {
+				logger.Fatalf("Failed to compute NetworkPolicy coverage: %v", err)
+			}
+
+			toolkit.PrintNetworkPolicyCoverage(report)
+			if report.CoveragePercent < minCoverage {
+				os.Exit(1)
+			}
+		},
+	}
+	netpolCmd.Flags().StringSliceVar(&excludeNamespaces, "exclude-namespaces", systemNamespaces, "Namespaces to leave out of the report")
+	netpolCmd.Flags().Float64Var(&minCoverage, "min-coverage", 0, "Exit non-zero when workload coverage is below this percentage")
+
+	var skipRegistryProbe bool
+	var secretsFailOn string
+
+	secretsCmd := &cobra.Command{
+		Use:   "secrets",
+		Short: "Audit secret hygiene",
+		Long: `Detects secrets exposed to pods as environment variables rather than files, secrets no pod
+uses, values that look like private keys or cloud credentials, and docker-registry secrets
+pointing at registries that no longer respond. Secret values are never printed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			findings, err := toolkit.AuditSecrets(context.Background(), SecretsAuditOptions{ProbeRegistries: !skipRegistryProbe})
+			if err != nil {
+				logger.Fatalf("Failed to audit secrets: %v", err)
+			}
+
+			toolkit.PrintFindings("Secret Hygiene Report", findings)
+			exitOnFindings(findings, secretsFailOn)
+		},
+	}
+	secretsCmd.Flags().BoolVar(&skipRegistryProbe, "skip-registry-probe", false, "Do not probe registries referenced by docker-registry secrets")
+	secretsCmd.Flags().StringVar(&secretsFailOn, "fail-on", "none", "Exit non-zero on findings at or above this severity (Low|Medium|High|none)")
+
+	var saFailOn string
+
+	serviceAccountsCmd := &cobra.Command{
+		Use:     "serviceaccounts",
+		Aliases: []string{"sa"},
+		Short:   "Audit service account tokens, automounting and powerful bindings",
+		Long: `Lists pods that automount tokens for service accounts with no role bindings, long-lived
+service account token Secrets, and service accounts bound to powerful ClusterRoles, with a
+remediation hint for each finding.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			findings, err := toolkit.AuditServiceAccounts(context.Background())
+			if err != nil {
+				logger.Fatalf("Failed to audit service accounts: %v", err)
+			}
+
+			toolkit.PrintFindings("ServiceAccount Audit", findings)
+			exitOnFindings(findings, saFailOn)
+		},
+	}
+	serviceAccountsCmd.Flags().StringVar(&saFailOn, "fail-on", "none", "Exit non-zero on findings at or above this severity (Low|Medium|High|none)")
+
+	var asUser string
+	var asGroups, accessNamespaces, accessVerbs, accessResources []string
+
+	accessMatrixCmd := &cobra.Command{
+		Use:   "access-matrix",
+		Short: "Build a verb by resource access matrix for a subject",
+		Long: `Uses SubjectAccessReview to check which verbs a user, group or service account may use on
+each resource in the selected namespaces, for periodic access reviews. Without --as the
+current credentials are checked with SelfSubjectAccessReview. Service accounts are given as
+system:serviceaccount:<namespace>:<name>. Use -o csv or -o json for machine-readable output.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			namespaces := accessNamespaces
+			if len(namespaces) == 0 {
+				namespaces = []string{toolkit.namespace}
+			}
+
+			matrix, err := toolkit.AccessMatrix(context.Background(), parseAccessSubject(asUser, asGroups), namespaces, accessVerbs, accessResources)
+			if err != nil {
+				logger.Fatalf("Failed to build access matrix (%s): %v", errorCategory(err), err)
+			}
+
+			toolkit.PrintAccessMatrix(matrix)
+		},
+	}
+	accessMatrixCmd.Flags().StringVar(&asUser, "as", "", "User or system:serviceaccount:<namespace>:<name> to check (default: current credentials)")
+	accessMatrixCmd.Flags().StringSliceVar(&asGroups, "as-group", nil, "Group to check, alone or together with --as")
+	accessMatrixCmd.Flags().StringSliceVar(&accessNamespaces, "namespaces", nil, "Namespaces to check (default: --namespace, or all namespaces when unset)")
+	accessMatrixCmd.Flags().StringSliceVar(&accessVerbs, "verbs", defaultAccessVerbs, "Verbs to check")
+	accessMatrixCmd.Flags().StringSliceVar(&accessResources, "resources", defaultAccessResources, "Resources to check as resource[.group][/subresource]")
+
+	securityCmd.AddCommand(withSchema(podsCmd, "findings"))
+	securityCmd.AddCommand(withSchema(imagesCmd, "image-inventory"))
+	securityCmd.AddCommand(withSchema(vulnsCmd, "vulnerabilities"))
+	securityCmd.AddCommand(netpolCmd)
+	securityCmd.AddCommand(withSchema(secretsCmd, "findings"))
+	securityCmd.AddCommand(withSchema(serviceAccountsCmd, "findings"))
+	securityCmd.AddCommand(accessMatrixCmd)
+	securityCmd.AddCommand(createCISCmd())
+	securityCmd.AddCommand(createSecurityDiffCmd())
+	return securityCmd
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// builtinPowerfulRoles are ClusterRoles that grant broad write access
+var builtinPowerfulRoles = map[string]bool{"cluster-admin": true, "admin": true, "edit": true}
+
+// powerfulRule reports why a policy rule is dangerous to hand to a workload,
+// or "" when it is not
+func powerfulRule(rule rbacv1.PolicyRule) string {
+	has := func(values []string, want ...string) bool {
+		for _, v := range values {
+			for _, w := range want {
+				if v == w {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	switch {
+	case has(rule.Verbs, "*") && has(rule.Resources, "*"):
+		return "all verbs on all resources"
+	case has(rule.Verbs, "escalate", "bind", "impersonate"):
+		return "can escalate privileges (" + strings.Join(rule.Verbs, ",") + ")"
+	case has(rule.Resources, "secrets", "*") && has(rule.Verbs, "get", "list", "watch", "*"):
+		return "can read secrets"
+	case has(rule.Resources, "pods/exec", "*") && has(rule.Verbs, "create", "*"):
+		return "can exec into pods"
+	}
+	return ""
+}
+
+// powerfulClusterRoles returns the ClusterRoles granting powerful rules,
+// with the reason each one is dangerous
+func (k *K8sToolkit) powerfulClusterRoles(ctx context.Context) (map[string]string, error) {
+	clusterRoles, err := k.clientset.RbacV1().ClusterRoles().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster roles: %w", err)
+	}
+	powerful := make(map[string]string)
+	for _, role := range clusterRoles.Items {
+		if builtinPowerfulRoles[role.Name] {
+			powerful[role.Name] = "built-in " + role.Name + " role"
+			continue
+		}
+		for _, rule := range role.Rules {
+			if reason := powerfulRule(rule); reason != "" {
+				powerful[role.Name] = reason
+				break
+			}
+		}
+	}
+	return powerful, nil
+}
+
+// AuditServiceAccounts reports pods that automount tokens for service
+// accounts without API permissions, long-lived token Secrets, and service
+// accounts bound to powerful ClusterRoles
+func (k *K8sToolkit) AuditServiceAccounts(ctx context.Context) ([]Finding, error) {
+	powerful, err := k.powerfulClusterRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	add := func(rule, severity, namespace, resource, message, remediation string) {
+		findings = append(findings, Finding{
+			Source:      "security-serviceaccounts",
+			RuleID:      "sa/" + rule,
+			Severity:    severity,
+			Namespace:   namespace,
+			Resource:    resource,
+			Message:     message,
+			Remediation: remediation,
+		})
+	}
+
+	// bound records service accounts that have any role binding at all,
+	// privileged those bound to a powerful ClusterRole
+	bound := make(map[string]bool)
+	privileged := make(map[string]bool)
+	saKey := func(namespace, name string) string { return namespace + "/" + name }
+
+	clusterBindings, err := k.clientset.RbacV1().ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster role bindings: %w", err)
+	}
+	for _, binding := range clusterBindings.Items {
+		for _, subject := range binding.Subjects {
+			if subject.Kind != rbacv1.ServiceAccountKind {
+				continue
+			}
+			bound[saKey(subject.Namespace, subject.Name)] = true
+			if k.namespace != "" && subject.Namespace != k.namespace {
+				continue
+			}
+			if reason, ok := powerful[binding.RoleRef.Name]; ok && binding.RoleRef.Kind == "ClusterRole" {
+				privileged[saKey(subject.Namespace, subject.Name)] = true
+				add("powerful-binding", "High", subject.Namespace, "serviceaccount/"+subject.Name,
+					fmt.Sprintf("bound cluster-wide to ClusterRole %s (%s) via %s", binding.RoleRef.Name, reason, binding.Name),
+					"replace the ClusterRoleBinding with namespaced RoleBindings to a role granting only the verbs the workload uses")
+			}
+		}
+	}
+
+	roleBindings, err := k.clientset.RbacV1().RoleBindings(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role bindings: %w", err)
+	}
+	for _, binding := range roleBindings.Items {
+		for _, subject := range binding.Subjects {
+			if subject.Kind != rbacv1.ServiceAccountKind {
+				continue
+			}
+			namespace := subject.Namespace
+			if namespace == "" {
+				namespace = binding.Namespace
+			}
+			bound[saKey(namespace, subject.Name)] = true
+			if reason, ok := powerful[binding.RoleRef.Name]; ok && binding.RoleRef.Kind == "ClusterRole" {
+				privileged[saKey(namespace, subject.Name)] = true
+				add("powerful-binding", "Medium", namespace, "serviceaccount/"+subject.Name,
+					fmt.Sprintf("bound in namespace %s to ClusterRole %s (%s) via %s", binding.Namespace, binding.RoleRef.Name, reason, binding.Name),
+					"bind a Role that grants only the verbs and resources the workload uses")
+			}
+		}
+	}
+
+	serviceAccounts, err := k.clientset.CoreV1().ServiceAccounts(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service accounts: %w", err)
+	}
+	saAutomount := make(map[string]*bool, len(serviceAccounts.Items))
+	for _, sa := range serviceAccounts.Items {
+		saAutomount[saKey(sa.Namespace, sa.Name)] = sa.AutomountServiceAccountToken
+	}
+
+	rsOwners, err := k.replicaSetOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reported := make(map[string]bool)
+	err = k.eachPod(ctx, k.namespace, k.listOptions(metav1.ListOptions{}), func(pod *corev1.Pod) error {
+		if !k.inScope(pod.Namespace) {
+			return nil
+		}
+		saName := pod.Spec.ServiceAccountName
+		if saName == "" {
+			saName = "default"
+		}
+		key := saKey(pod.Namespace, saName)
+
+		// The pod setting wins over the service account setting; both default to true
+		automount := true
+		if value := saAutomount[key]; value != nil {
+			automount = *value
+		}
+		if pod.Spec.AutomountServiceAccountToken != nil {
+			automount = *pod.Spec.AutomountServiceAccountToken
+		}
+		if !automount || bound[key] {
+			return nil
+		}
+
+		workload := podWorkload(pod, rsOwners).String()
+		if reported[pod.Namespace+"/"+workload] {
+			return nil
+		}
+		reported[pod.Namespace+"/"+workload] = true
+		add("unneeded-automount", "Low", pod.Namespace, workload,
+			fmt.Sprintf("mounts a token for service account %s, which has no role bindings", saName),
+			"set automountServiceAccountToken: false on the pod spec or the service account")
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	err = k.eachSecret(ctx, k.namespace, metav1.ListOptions{FieldSelector: "type=" + string(corev1.SecretTypeServiceAccountToken)}, func(secret *corev1.Secret) error {
+		if !k.inScope(secret.Namespace) {
+			return nil
+		}
+		saName := secret.Annotations[corev1.ServiceAccountNameKey]
+		severity := "Medium"
+		if privileged[saKey(secret.Namespace, saName)] {
+			severity = "High"
+		}
+		add("long-lived-token", severity, secret.Namespace, "secret/"+secret.Name,
+			fmt.Sprintf("non-expiring token for service account %s", saName),
+			"delete the secret and use projected tokens or kubectl create token, which expire")
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	sortFindings(findings)
+	return findings, nil
+}
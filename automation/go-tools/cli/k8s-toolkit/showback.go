@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Pseudo-teams of the showback report
+const (
+	teamUnallocated = "unallocated"
+	teamShared      = "shared"
+	teamIdle        = "idle"
+)
+
+// Cost distribution policies for shared namespaces and idle capacity
+const (
+	distributeProportional = "proportional"
+	distributeEven         = "even"
+	distributeNone         = "none"
+)
+
+// Metrics exported by cost exporter
+var (
+	costWorkloadGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8s_toolkit_cost_workload_hourly",
+		Help: "Hourly cost allocated to a workload, including its share of shared and idle cost.",
+	}, []string{"namespace", "workload", "team"})
+	costNamespaceGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8s_toolkit_cost_namespace_hourly",
+		Help: "Hourly cost allocated to a namespace, including its share of shared and idle cost.",
+	}, []string{"namespace"})
+	costTeamGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8s_toolkit_cost_team_hourly",
+		Help: "Hourly cost allocated to a team, including its share of shared and idle cost.",
+	}, []string{"team"})
+	costTeamCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "k8s_toolkit_cost_team_total",
+		Help: "Cost accumulated by a team since the exporter started, for increase() over billing periods.",
+	}, []string{"team"})
+	costClusterGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "k8s_toolkit_cost_cluster_hourly",
+		Help: "Hourly cost of the cluster by component: allocated, shared and idle before distribution.",
+	}, []string{"component"})
+)
+
+// ShowbackOptions configures cost allocation; it is read from cost.showback
+type ShowbackOptions struct {
+	// Allocation charges pods for their requests, their usage, or the larger
+	// of both per resource (max)
+	Allocation string `mapstructure:"allocation"`
+	// SharedNamespaces hold platform services whose cost is spread over the
+	// other namespaces
+	SharedNamespaces []string `mapstructure:"shared_namespaces"`
+	SharedPolicy     string   `mapstructure:"shared_policy"`
+	IdlePolicy       string   `mapstructure:"idle_policy"`
+	// TeamLabel is the pod label naming the owning team; namespaces owned
+	// under teams in the config are used for pods without it
+	TeamLabel string `mapstructure:"team_label"`
+}
+
+// loadShowbackOptions reads and validates cost.showback
+func loadShowbackOptions() (ShowbackOptions, error) {
+	opts := ShowbackOptions{
+		Allocation:   "max",
+		SharedPolicy: distributeProportional,
+		IdlePolicy:   distributeNone,
+		TeamLabel:    "team",
+	}
+	if err := viper.UnmarshalKey("cost.showback", &opts); err != nil {
+		return opts, fmt.Errorf("invalid cost.showback: %w", err)
+	}
+	switch opts.Allocation {
+	case "requests", "usage", "max":
+	default:
+		return opts, fmt.Errorf("unknown allocation %q, expected requests, usage or max", opts.Allocation)
+	}
+	for _, policy := range []string{opts.SharedPolicy, opts.IdlePolicy} {
+		switch policy {
+		case distributeProportional, distributeEven, distributeNone:
+		default:
+			return opts, fmt.Errorf("unknown distribution policy %q, expected proportional, even or none", policy)
+		}
+	}
+	return opts, nil
+}
+
+// ShowbackWorkload is the hourly cost allocated to one workload
+type ShowbackWorkload struct {
+	Namespace string  `json:"namespace"`
+	Workload  string  `json:"workload"`
+	Team      string  `json:"team"`
+	Pods      int     `json:"pods"`
+	CPU       int64   `json:"cpu_millicores"`
+	Memory    int64   `json:"memory_bytes"`
+	Direct    float64 `json:"direct_hourly"`
+	Shared    float64 `json:"shared_hourly"`
+	Idle      float64 `json:"idle_hourly"`
+	Hourly    float64 `json:"hourly"`
+}
+
+// ShowbackTeam is the cost allocated to one team
+type ShowbackTeam struct {
+	Team       string   `json:"team"`
+	Namespaces []string `json:"namespaces"`
+	Hourly     float64  `json:"hourly"`
+	Monthly    float64  `json:"monthly"`
+	Share      float64  `json:"percent_of_total"`
+}
+
+// ShowbackReport allocates the whole cluster cost to teams and workloads
+type ShowbackReport struct {
+	GeneratedAt   time.Time          `json:"generated_at"`
+	Allocation    string             `json:"allocation"`
+	SharedPolicy  string             `json:"shared_policy"`
+	IdlePolicy    string             `json:"idle_policy"`
+	Currency      string             `json:"currency"`
+	ClusterHourly float64            `json:"cluster_hourly"`
+	Allocated     float64            `json:"allocated_hourly"`
+	SharedHourly  float64            `json:"shared_hourly"`
+	IdleHourly    float64            `json:"idle_hourly"`
+	Teams         []ShowbackTeam     `json:"teams"`
+	Workloads     []ShowbackWorkload `json:"workloads"`
+}
+
+// distribute spreads amount over the workloads proportionally to their direct
+// cost, or evenly across their namespaces and then proportionally within each
+func distribute(workloads []*ShowbackWorkload, amount float64, policy string, add func(w *ShowbackWorkload, share float64)) {
+	if amount <= 0 || len(workloads) == 0 {
+		return
+	}
+	proportional := func(group []*ShowbackWorkload, amount float64) {
+		total := 0.0
+		for _, w := range group {
+			total += w.Direct
+		}
+		for _, w := range group {
+			if total > 0 {
+				add(w, amount*w.Direct/total)
+			} else {
+				add(w, amount/float64(len(group)))
+			}
+		}
+	}
+	if policy != distributeEven {
+		proportional(workloads, amount)
+		return
+	}
+	byNamespace := make(map[string][]*ShowbackWorkload)
+	for _, w := range workloads {
+		byNamespace[w.Namespace] = append(byNamespace[w.Namespace], w)
+	}
+	for _, group := range byNamespace {
+		proportional(group, amount/float64(len(byNamespace)))
+	}
+}
+
+// BuildShowback allocates the cost of every node to the workloads running on
+// it. Node cost not claimed by any pod is idle; the cost of shared namespaces
+// and idle capacity is spread over the other workloads by the configured
+// policies, or reported as the shared and idle teams with policy none.
+func (k *K8sToolkit) BuildShowback(ctx context.Context, pricing *PricingConfig, opts ShowbackOptions) (*ShowbackReport, error) {
+	report := &ShowbackReport{
+		GeneratedAt:  time.Now(),
+		Allocation:   opts.Allocation,
+		SharedPolicy: opts.SharedPolicy,
+		IdlePolicy:   opts.IdlePolicy,
+		Currency:     pricing.Currency,
+	}
+
+	nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	type rates struct{ cpu, gb float64 }
+	nodeRates := make(map[string]rates, len(nodes.Items))
+	nodeCost := make(map[string]float64, len(nodes.Items))
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		cpu, gb := pricing.nodeRates(node)
+		nodeRates[node.Name] = rates{cpu, gb}
+		nodeCost[node.Name] = float64(node.Status.Allocatable.Cpu().MilliValue())/1000*cpu +
+			float64(node.Status.Allocatable.Memory().Value())/(1<<30)*gb
+		report.ClusterHourly += nodeCost[node.Name]
+	}
+
+	// Allocation covers the whole cluster, or idle cost could not be known
+	cluster := *k
+	cluster.namespace = ""
+
+	allocation := opts.Allocation
+	usage := make(map[string]corev1.ResourceList)
+	if allocation != "requests" {
+		if k.metricsClientset == nil {
+			if allocation == "usage" {
+				return nil, fmt.Errorf("metrics server not available")
+			}
+			k.log().Warnf("metrics server not available, allocating by requests")
+			allocation = "requests"
+		} else {
+			metrics, err := k.metricsClientset.MetricsV1beta1().PodMetricses("").List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to get pod metrics: %w", err)
+			}
+			for _, metric := range metrics.Items {
+				total := corev1.ResourceList{}
+				for _, container := range metric.Containers {
+					for name, quantity := range container.Usage {
+						sum := total[name]
+						sum.Add(quantity)
+						total[name] = sum
+					}
+				}
+				usage[metric.Namespace+"/"+metric.Name] = total
+			}
+		}
+	}
+	report.Allocation = allocation
+
+	teams, err := k.teamNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	namespaceTeam := make(map[string]string)
+	for team, namespaces := range teams {
+		for _, namespace := range namespaces {
+			namespaceTeam[namespace] = team
+		}
+	}
+	shared := make(map[string]bool)
+	for _, namespace := range opts.SharedNamespaces {
+		shared[namespace] = true
+	}
+
+	rsOwners, err := cluster.replicaSetOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeAllocated := make(map[string]float64)
+	workloads := make(map[workloadKey]*ShowbackWorkload)
+	err = cluster.eachPod(ctx, "", metav1.ListOptions{}, func(pod *corev1.Pod) error {
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			return nil
+		}
+
+		cpu, _, memory, _ := podResources(pod)
+		if allocation != "requests" {
+			used := usage[pod.Namespace+"/"+pod.Name]
+			usedCPU, usedMemory := used.Cpu().MilliValue(), used.Memory().Value()
+			if allocation == "usage" {
+				cpu, memory = usedCPU, usedMemory
+			} else {
+				if usedCPU > cpu {
+					cpu = usedCPU
+				}
+				if usedMemory > memory {
+					memory = usedMemory
+				}
+			}
+		}
+
+		r := nodeRates[pod.Spec.NodeName]
+		cost := float64(cpu)/1000*r.cpu + float64(memory)/(1<<30)*r.gb
+		nodeAllocated[pod.Spec.NodeName] += cost
+
+		key := podWorkload(pod, rsOwners)
+		w := workloads[key]
+		if w == nil {
+			team := pod.Labels[opts.TeamLabel]
+			if team == "" {
+				team = namespaceTeam[pod.Namespace]
+			}
+			if team == "" {
+				team = teamUnallocated
+			}
+			w = &ShowbackWorkload{Namespace: pod.Namespace, Workload: key.String(), Team: team}
+			workloads[key] = w
+		}
+		w.Pods++
+		w.CPU += cpu
+		w.Memory += memory
+		w.Direct += cost
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	for name, cost := range nodeCost {
+		// Overcommitted nodes have no idle cost rather than a negative one
+		if idle := cost - nodeAllocated[name]; idle > 0 {
+			report.IdleHourly += idle
+		}
+	}
+
+	var owners []*ShowbackWorkload
+	var sharedWorkloads []*ShowbackWorkload
+	for _, w := range workloads {
+		report.Allocated += w.Direct
+		if shared[w.Namespace] {
+			report.SharedHourly += w.Direct
+			sharedWorkloads = append(sharedWorkloads, w)
+			continue
+		}
+		owners = append(owners, w)
+	}
+
+	if opts.SharedPolicy == distributeNone || len(owners) == 0 {
+		for _, w := range sharedWorkloads {
+			w.Team = teamShared
+			owners = append(owners, w)
+		}
+	} else {
+		distribute(owners, report.SharedHourly, opts.SharedPolicy, func(w *ShowbackWorkload, share float64) { w.Shared += share })
+	}
+
+	if opts.IdlePolicy == distributeNone {
+		if report.IdleHourly > 0 {
+			owners = append(owners, &ShowbackWorkload{Workload: teamIdle, Team: teamIdle, Idle: report.IdleHourly})
+		}
+	} else {
+		distribute(owners, report.IdleHourly, opts.IdlePolicy, func(w *ShowbackWorkload, share float64) { w.Idle += share })
+	}
+
+	byTeam := make(map[string]*ShowbackTeam)
+	seenNamespaces := make(map[string]map[string]bool)
+	for _, w := range owners {
+		w.Hourly = w.Direct + w.Shared + w.Idle
+		if k.namespace != "" && w.Namespace != k.namespace {
+			continue
+		}
+		report.Workloads = append(report.Workloads, *w)
+
+		t := byTeam[w.Team]
+		if t == nil {
+			t = &ShowbackTeam{Team: w.Team}
+			byTeam[w.Team] = t
+			seenNamespaces[w.Team] = make(map[string]bool)
+		}
+		t.Hourly += w.Hourly
+		if w.Namespace != "" && !seenNamespaces[w.Team][w.Namespace] {
+			seenNamespaces[w.Team][w.Namespace] = true
+			t.Namespaces = append(t.Namespaces, w.Namespace)
+		}
+	}
+	for _, t := range byTeam {
+		t.Monthly = t.Hourly * hoursPerMonth
+		if report.ClusterHourly > 0 {
+			t.Share = t.Hourly / report.ClusterHourly * 100
+		}
+		sort.Strings(t.Namespaces)
+		report.Teams = append(report.Teams, *t)
+	}
+
+	sort.Slice(report.Teams, func(i, j int) bool { return report.Teams[i].Hourly > report.Teams[j].Hourly })
+	sort.Slice(report.Workloads, func(i, j int) bool { return report.Workloads[i].Hourly > report.Workloads[j].Hourly })
+	return report, nil
+}
+
+// PrintShowbackReport prints the cost per team and, unless teamsOnly is set,
+// per workload
+func (k *K8sToolkit) PrintShowbackReport(report *ShowbackReport, teamsOnly bool) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+
+	fmt.Printf("Cost Showback (allocation by %s, shared %s, idle %s)\n", report.Allocation, report.SharedPolicy, report.IdlePolicy)
+	fmt.Println("=====================================")
+	fmt.Printf("Cluster: %.2f %s/h (%.2f %s/month), allocated %.2f, shared %.2f, idle %.2f\n\n",
+		report.ClusterHourly, report.Currency, report.ClusterHourly*hoursPerMonth, report.Currency,
+		report.Allocated, report.SharedHourly, report.IdleHourly)
+
+	fmt.Printf("%-30s %12s %12s %7s  %s\n", "TEAM", "HOURLY", "MONTHLY", "SHARE", "NAMESPACES")
+	for _, t := range report.Teams {
+		fmt.Printf("%-30s %12.4f %12.2f %6.1f%%  %v\n", t.Team, t.Hourly, t.Monthly, t.Share, t.Namespaces)
+	}
+	if teamsOnly {
+		return
+	}
+
+	fmt.Printf("\n%-25s %-40s %-20s %10s %10s %10s %10s\n", "NAMESPACE", "WORKLOAD", "TEAM", "DIRECT", "SHARED", "IDLE", "HOURLY")
+	for _, w := range report.Workloads {
+		fmt.Printf("%-25s %-40s %-20s %10.4f %10.4f %10.4f %10.4f\n", w.Namespace, w.Workload, w.Team, w.Direct, w.Shared, w.Idle, w.Hourly)
+	}
+}
+
+// exportShowback sets the cost metrics from a report. The team counter
+// accumulates the hourly cost over elapsed.
+func exportShowback(report *ShowbackReport, elapsed time.Duration) {
+	costWorkloadGauge.Reset()
+	costNamespaceGauge.Reset()
+	costTeamGauge.Reset()
+
+	namespaces := make(map[string]float64)
+	for _, w := range report.Workloads {
+		costWorkloadGauge.WithLabelValues(w.Namespace, w.Workload, w.Team).Set(w.Hourly)
+		if w.Namespace != "" {
+			namespaces[w.Namespace] += w.Hourly
+		}
+	}
+	for namespace, hourly := range namespaces {
+		costNamespaceGauge.WithLabelValues(namespace).Set(hourly)
+	}
+	for _, t := range report.Teams {
+		costTeamGauge.WithLabelValues(t.Team).Set(t.Hourly)
+		if elapsed > 0 {
+			costTeamCounter.WithLabelValues(t.Team).Add(t.Hourly * elapsed.Hours())
+		}
+	}
+	costClusterGauge.WithLabelValues("total").Set(report.ClusterHourly)
+	costClusterGauge.WithLabelValues("allocated").Set(report.Allocated)
+	costClusterGauge.WithLabelValues("shared").Set(report.SharedHourly)
+	costClusterGauge.WithLabelValues("idle").Set(report.IdleHourly)
+}
+
+// createShowbackCmds creates the cost showback and cost exporter commands
+func createShowbackCmds(pricingFile *string) []*cobra.Command {
+	setup := func() (*K8sToolkit, *PricingConfig, ShowbackOptions) {
+		pricing, err := loadPricing(*pricingFile)
+		if err != nil {
+			logger.Fatalf("Failed to load pricing: %v", err)
+		}
+		opts, err := loadShowbackOptions()
+		if err != nil {
+			logger.Fatalf("Invalid configuration: %v", err)
+		}
+		toolkit, err := NewK8sToolkit()
+		if err != nil {
+			logger.Fatalf("Failed to initialize toolkit: %v", err)
+		}
+		return toolkit, pricing, opts
+	}
+
+	var teamsOnly bool
+	showbackCmd := &cobra.Command{
+		Use:   "showback",
+		Short: "Allocate the whole cluster cost to teams, including shared and idle cost",
+		Long: `Allocates the cost of every node to the workloads running on it and rolls it up by team.
+Allocation is configured under cost.showback:
+
+  cost:
+    showback:
+      allocation: max                 # requests, usage, or the larger of both per resource
+      shared_namespaces: [kube-system, monitoring]
+      shared_policy: proportional     # proportional, even (across namespaces) or none
+      idle_policy: none               # idle capacity: proportional, even or none
+      team_label: team                # pod label; otherwise teams.<team>.namespaces
+
+With policy none, shared and idle cost are reported as the teams "shared" and "idle".`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, pricing, opts := setup()
+			report, err := toolkit.BuildShowback(context.Background(), pricing, opts)
+			if err != nil {
+				logger.Fatalf("Failed to build showback: %v", err)
+			}
+			toolkit.PrintShowbackReport(report, teamsOnly)
+		},
+	}
+	showbackCmd.Flags().BoolVar(&teamsOnly, "teams-only", false, "Only show the per-team totals")
+
+	var listen string
+	var interval time.Duration
+	exporterCmd := &cobra.Command{
+		Use:   "exporter",
+		Short: "Serve showback cost metrics for Prometheus",
+		Long: `Recomputes the showback allocation every --interval and serves it on /metrics:
+k8s_toolkit_cost_{workload,namespace,team,cluster}_hourly gauges and the
+k8s_toolkit_cost_team_total counter, whose increase() over a period is the team's cost for it.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, pricing, opts := setup()
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(costWorkloadGauge, costNamespaceGauge, costTeamGauge, costTeamCounter, costClusterGauge)
+			server := &http.Server{Addr: listen, Handler: promhttp.HandlerFor(registry, promhttp.HandlerOpts{})}
+			go func() {
+				if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					logger.Fatalf("Metrics server failed: %v", err)
+				}
+			}()
+			defer server.Close()
+			toolkit.log().Infof("Serving cost metrics on %s", listen)
+
+			var last time.Time
+			for {
+				report, err := toolkit.BuildShowback(ctx, pricing, opts)
+				if err != nil {
+					toolkit.log().Errorf("Failed to build showback: %v", err)
+				} else {
+					var elapsed time.Duration
+					if !last.IsZero() {
+						elapsed = report.GeneratedAt.Sub(last)
+					}
+					exportShowback(report, elapsed)
+					last = report.GeneratedAt
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(interval):
+				}
+			}
+		},
+	}
+	exporterCmd.Flags().StringVar(&listen, "listen", ":9105", "Address to serve /metrics on")
+	exporterCmd.Flags().DurationVar(&interval, "interval", time.Minute, "How often to recompute the allocation")
+
+	return []*cobra.Command{showbackCmd, exporterCmd}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// version is the toolkit version embedded in signed reports; release builds
+// set it with -ldflags "-X main.version=..."
+var version = "2.0.0"
+
+// SignedReport wraps a JSON report with the tool version and a detached
+// signature over everything but the signature itself
+type SignedReport struct {
+	Tool      string           `json:"tool"`
+	Version   string           `json:"version"`
+	SignedAt  time.Time        `json:"signed_at"`
+	Report    json.RawMessage  `json:"report"`
+	Signature *ReportSignature `json:"signature,omitempty"`
+}
+
+// ReportSignature is a cosign or minisign signature of a SignedReport
+type ReportSignature struct {
+	Scheme string `json:"scheme"`
+	Value  string `json:"value"`
+}
+
+// signedPayload is the byte sequence that is signed and verified. Marshaling
+// compacts the embedded report, so re-indenting the file does not break
+// verification.
+func (r SignedReport) signedPayload() ([]byte, error) {
+	r.Signature = nil
+	return json.Marshal(r)
+}
+
+// signingScheme picks minisign for minisign key files and cosign for
+// everything else, including KMS key references
+func signingScheme(key string) string {
+	if data, err := os.ReadFile(key); err == nil && bytes.HasPrefix(data, []byte("untrusted comment:")) {
+		return "minisign"
+	}
+	return "cosign"
+}
+
+// runSigner runs cosign or minisign in a scratch directory holding the
+// payload and returns the contents of the signature file it writes. verify
+// reads the signature from the same file instead.
+func runSigner(ctx context.Context, scheme, key string, payload []byte, signature string, verify bool) (string, error) {
+	dir, err := os.MkdirTemp("", "k8s-toolkit-sign-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	payloadPath := filepath.Join(dir, "report.json")
+	sigPath := filepath.Join(dir, "report.sig")
+	if err := os.WriteFile(payloadPath, payload, 0600); err != nil {
+		return "", err
+	}
+	if verify {
+		if scheme == "minisign" {
+			signature += "\n"
+		}
+		if err := os.WriteFile(sigPath, []byte(signature), 0600); err != nil {
+			return "", err
+		}
+	}
+
+	// Signatures stay out of the public transparency log since reports
+	// describe internal infrastructure
+	var args []string
+	switch {
+	case scheme == "cosign" && !verify:
+		args = []string{"sign-blob", "--yes", "--tlog-upload=false", "--key", key, "--output-signature", sigPath, payloadPath}
+	case scheme == "cosign":
+		args = []string{"verify-blob", "--insecure-ignore-tlog=true", "--key", key, "--signature", sigPath, payloadPath}
+	case scheme == "minisign" && !verify:
+		args = []string{"-S", "-s", key, "-m", payloadPath, "-x", sigPath}
+	case scheme == "minisign":
+		args = []string{"-V", "-p", key, "-m", payloadPath, "-x", sigPath}
+	default:
+		return "", fmt.Errorf("unknown signature scheme %q", scheme)
+	}
+
+	// Key passwords are prompted for on the terminal unless COSIGN_PASSWORD
+	// is set or the minisign key is unencrypted
+	cmd := exec.CommandContext(ctx, scheme, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed: %w", scheme, err)
+	}
+	if verify {
+		return "", nil
+	}
+
+	sig, err := os.ReadFile(sigPath)
+	if err != nil {
+		return "", fmt.Errorf("%s wrote no signature: %w", scheme, err)
+	}
+	return strings.TrimSpace(string(sig)), nil
+}
+
+// SignReport marshals v and signs it with key
+func SignReport(ctx context.Context, v interface{}, key string) (*SignedReport, error) {
+	report, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	signed := &SignedReport{Tool: "k8s-toolkit", Version: version, SignedAt: time.Now().UTC(), Report: report}
+	payload, err := signed.signedPayload()
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := signingScheme(key)
+	value, err := runSigner(ctx, scheme, key, payload, "", false)
+	if err != nil {
+		return nil, err
+	}
+	signed.Signature = &ReportSignature{Scheme: scheme, Value: value}
+	return signed, nil
+}
+
+// VerifyReport checks the embedded signature of a signed report against a
+// public key
+func VerifyReport(ctx context.Context, data []byte, publicKey string) (*SignedReport, error) {
+	var signed SignedReport
+	if err := json.Unmarshal(data, &signed); err != nil {
+		return nil, fmt.Errorf("not a signed report: %w", err)
+	}
+	if signed.Signature == nil || signed.Signature.Value == "" {
+		return nil, errors.New("report has no signature")
+	}
+	payload, err := signed.signedPayload()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := runSigner(ctx, signed.Signature.Scheme, publicKey, payload, signed.Signature.Value, true); err != nil {
+		return nil, err
+	}
+	return &signed, nil
+}
+
+// createVerifyReportCmd creates the verify-report command
+func createVerifyReportCmd() *cobra.Command {
+	var publicKey string
+	var extract bool
+
+	verifyCmd := &cobra.Command{
+		Use:   "verify-report <file>",
+		Short: "Verify the signature of a report written with --sign-key",
+		Long: `Checks the signature embedded in a JSON report produced with --sign-key against --key, a
+cosign public key (or KMS reference) or a minisign public key matching the scheme recorded in the
+report. The matching cosign or minisign binary must be on PATH. With --extract the verified report
+is printed without the signature envelope. Exits 1 when the report was modified or signed with a
+different key.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if publicKey == "" {
+				logger.Fatalf("A public key is required (--key)")
+			}
+			data, err := os.ReadFile(args[0])
+			if err != nil {
+				logger.Fatalf("Failed to read report: %v", err)
+			}
+
+			signed, err := VerifyReport(context.Background(), data, publicKey)
+			if err != nil {
+				logger.Fatalf("Verification failed: %v", err)
+			}
+			if extract {
+				var report interface{}
+				if err := json.Unmarshal(signed.Report, &report); err != nil {
+					logger.Fatalf("Failed to decode report: %v", err)
+				}
+				printJSON(report)
+				return
+			}
+			fmt.Printf("Verified: signed by %s %s at %s (%s)\n", signed.Tool, signed.Version, signed.SignedAt.Format(time.RFC3339), signed.Signature.Scheme)
+		},
+	}
+
+	verifyCmd.Flags().StringVar(&publicKey, "key", "", "Public key to verify the signature with")
+	verifyCmd.Flags().BoolVar(&extract, "extract", false, "Print the verified report instead of a summary")
+
+	return verifyCmd
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// sloAvailabilityAnnotation sets a workload's availability target in percent, e.g. "99.9"
+const sloAvailabilityAnnotation = "slo.devops/availability"
+
+// WorkloadSLO is the availability target of a workload and how it is doing
+type WorkloadSLO struct {
+	Kind      string  `json:"kind"`
+	Namespace string  `json:"namespace"`
+	Name      string  `json:"name"`
+	Target    float64 `json:"target_percent"`
+	Observed  float64 `json:"observed_percent"`
+	Ready     int32   `json:"ready_replicas"`
+	Desired   int32   `json:"desired_replicas"`
+	Violating bool    `json:"violating"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// newWorkloadSLO parses the availability annotation of a workload, returning
+// nil when it has none
+func newWorkloadSLO(kind string, meta metav1.ObjectMeta, replicas *int32, ready int32) *WorkloadSLO {
+	value, ok := meta.Annotations[sloAvailabilityAnnotation]
+	if !ok {
+		return nil
+	}
+	slo := &WorkloadSLO{Kind: kind, Namespace: meta.Namespace, Name: meta.Name, Ready: ready, Desired: 1}
+	if replicas != nil {
+		slo.Desired = *replicas
+	}
+
+	target, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || target <= 0 || target > 100 {
+		slo.Error = fmt.Sprintf("invalid %s annotation %q", sloAvailabilityAnnotation, value)
+		return slo
+	}
+	slo.Target = target
+
+	// Scaled to zero is intentional, not an outage
+	slo.Observed = 100
+	if slo.Desired > 0 {
+		slo.Observed = percentOf(int64(ready), int64(slo.Desired))
+	}
+	slo.Violating = slo.Observed < slo.Target
+	return slo
+}
+
+// WorkloadSLOs computes the observed availability of every Deployment and
+// StatefulSet annotated with an availability target. Observed availability
+// is the ready-replica ratio at the time of the check.
+func (k *K8sToolkit) WorkloadSLOs(ctx context.Context) ([]WorkloadSLO, error) {
+	var slos []WorkloadSLO
+
+	deployments, err := k.clientset.AppsV1().Deployments(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list deployments: %w", err)
+	}
+	for _, d := range inScopeItems(k, deployments.Items) {
+		if slo := newWorkloadSLO("Deployment", d.ObjectMeta, d.Spec.Replicas, d.Status.ReadyReplicas); slo != nil {
+			slos = append(slos, *slo)
+		}
+	}
+
+	statefulSets, err := k.clientset.AppsV1().StatefulSets(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list statefulsets: %w", err)
+	}
+	for _, s := range inScopeItems(k, statefulSets.Items) {
+		if slo := newWorkloadSLO("StatefulSet", s.ObjectMeta, s.Spec.Replicas, s.Status.ReadyReplicas); slo != nil {
+			slos = append(slos, *slo)
+		}
+	}
+
+	sort.Slice(slos, func(i, j int) bool {
+		if slos[i].Namespace != slos[j].Namespace {
+			return slos[i].Namespace < slos[j].Namespace
+		}
+		if slos[i].Kind != slos[j].Kind {
+			return slos[i].Kind < slos[j].Kind
+		}
+		return slos[i].Name < slos[j].Name
+	})
+	return slos, nil
+}
+
+// CheckSLOs reports annotated workloads whose observed availability is below
+// their target. It is Healthy when no workload has a target.
+func (k *K8sToolkit) CheckSLOs(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Workload SLOs",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	slos, err := k.WorkloadSLOs(ctx)
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to evaluate SLOs: %v", err)
+		result.Err = err
+		return result
+	}
+	if len(slos) == 0 {
+		result.Status = "Healthy"
+		result.Message = "No workloads with an availability target"
+		return result
+	}
+
+	var violations, invalid []string
+	for _, slo := range slos {
+		ref := objectRef{Kind: slo.Kind, Namespace: slo.Namespace, Name: slo.Name}
+		switch {
+		case slo.Error != "":
+			invalid = append(invalid, fmt.Sprintf("%s: %s", ref, slo.Error))
+		case slo.Violating:
+			result.Affected = append(result.Affected, ref)
+			violations = append(violations, fmt.Sprintf("%s %.2f%% < %.2f%% (%d/%d ready)", ref, slo.Observed, slo.Target, slo.Ready, slo.Desired))
+		}
+	}
+
+	result.Details["workloads"] = strconv.Itoa(len(slos))
+	if len(violations) > 0 {
+		result.Details["violations"] = strings.Join(violations, "; ")
+	}
+	if len(invalid) > 0 {
+		result.Details["invalid"] = strings.Join(invalid, "; ")
+	}
+
+	switch {
+	case len(violations) > 0:
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d of %d workloads below their availability target", len(violations), len(slos))
+	case len(invalid) > 0:
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d workloads have an invalid availability target", len(invalid))
+	default:
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("All %d workloads meet their availability target", len(slos))
+	}
+	return result
+}
+
+// sloFindings converts SLO violations into findings
+func (k *K8sToolkit) sloFindings(ctx context.Context) ([]Finding, error) {
+	slos, err := k.WorkloadSLOs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []Finding
+	for _, slo := range slos {
+		resource := strings.ToLower(slo.Kind) + "/" + slo.Name
+		switch {
+		case slo.Error != "":
+			findings = append(findings, Finding{
+				Source:      "slo",
+				RuleID:      "slo/invalid-target",
+				Severity:    "Low",
+				Namespace:   slo.Namespace,
+				Resource:    resource,
+				Message:     slo.Error,
+				Remediation: fmt.Sprintf("set %s to a percentage such as \"99.9\"", sloAvailabilityAnnotation),
+			})
+		case slo.Violating:
+			findings = append(findings, Finding{
+				Source:      "slo",
+				RuleID:      "slo/availability",
+				Severity:    "High",
+				Namespace:   slo.Namespace,
+				Resource:    resource,
+				Message:     fmt.Sprintf("availability %.2f%% is below the %.2f%% target (%d/%d replicas ready)", slo.Observed, slo.Target, slo.Ready, slo.Desired),
+				Remediation: "investigate why replicas are not ready",
+			})
+		}
+	}
+	sortFindings(findings)
+	return findings, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"runtime/metrics"
+
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// listPageSize is the number of objects requested per page from the API server
+const listPageSize = 500
+
+// heapMetric is the runtime metric compared against --max-memory
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// ErrMemoryLimit is returned when heap usage exceeds --max-memory
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// parseMaxMemory reads max_memory (a quantity such as 512Mi) from the config.
+// It also sets the runtime soft limit so the GC works harder before the hard
+// check aborts.
+func parseMaxMemory() (int64, error) {
+	value := viper.GetString("max_memory")
+	if value == "" || value == "0" {
+		return 0, nil
+	}
+
+	quantity, err := resource.ParseQuantity(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max memory %q: %w", value, err)
+	}
+	limit := quantity.Value()
+	debug.SetMemoryLimit(limit)
+	return limit, nil
+}
+
+// checkMemory returns ErrMemoryLimit when live heap objects exceed the configured limit
+func (k *K8sToolkit) checkMemory() error {
+	if k.maxMemory <= 0 {
+		return nil
+	}
+
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return nil
+	}
+	if heap := sample[0].Value.Uint64(); int64(heap) > k.maxMemory {
+		return fmt.Errorf("%w: heap %s exceeds %s", ErrMemoryLimit, formatMemory(int64(heap)), formatMemory(k.maxMemory))
+	}
+	return nil
+}
+
+// eachPod calls fn for every pod matching opts, fetching one page at a time so
+// only a single page is held in memory. fn must not retain the pod pointer.
+func (k *K8sToolkit) eachPod(ctx context.Context, namespace string, opts metav1.ListOptions, fn func(pod *corev1.Pod) error) error {
+	opts.Limit = listPageSize
+	for {
+		page, err := k.clientset.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for i := range page.Items {
+			if err := fn(&page.Items[i]); err != nil {
+				return err
+			}
+		}
+		if err := k.checkMemory(); err != nil {
+			return err
+		}
+
+		opts.Continue = page.Continue
+		if opts.Continue == "" {
+			return nil
+		}
+	}
+}
+
+// eachEvent calls fn for every event matching opts, one page at a time
+func (k *K8sToolkit) eachEvent(ctx context.Context, namespace string, opts metav1.ListOptions, fn func(event *corev1.Event) error) error {
+	opts.Limit = listPageSize
+	for {
+		page, err := k.clientset.CoreV1().Events(namespace).List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for i := range page.Items {
+			if err := fn(&page.Items[i]); err != nil {
+				return err
+			}
+		}
+		if err := k.checkMemory(); err != nil {
+			return err
+		}
+
+		opts.Continue = page.Continue
+		if opts.Continue == "" {
+			return nil
+		}
+	}
+}
+
+// eachSecret calls fn for every secret matching opts, one page at a time
+func (k *K8sToolkit) eachSecret(ctx context.Context, namespace string, opts metav1.ListOptions, fn func(secret *corev1.Secret) error) error {
+	opts.Limit = listPageSize
+	for {
+		page, err := k.clientset.CoreV1().Secrets(namespace).List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for i := range page.Items {
+			if err := fn(&page.Items[i]); err != nil {
+				return err
+			}
+		}
+		if err := k.checkMemory(); err != nil {
+			return err
+		}
+
+		opts.Continue = page.Continue
+		if opts.Continue == "" {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed templates/*.tmpl i18n/*.yaml
+var reportFiles embed.FS
+
+var (
+	catalogOnce sync.Once
+	catalog     map[string]string
+)
+
+// loadCatalog reads the message catalog for the configured language. Messages
+// are keyed by their English text; the bundled catalog is overlaid with
+// messages.<lang>.yaml from the template directory when present.
+func loadCatalog() map[string]string {
+	messages := make(map[string]string)
+	lang := viper.GetString("lang")
+	if lang == "" {
+		lang = "en"
+	}
+
+	found := lang == "en"
+	if data, err := reportFiles.ReadFile("i18n/" + lang + ".yaml"); err == nil {
+		if err := yaml.Unmarshal(data, &messages); err != nil {
+			logger.Warnf("bundled catalog %s is invalid: %v", lang, err)
+		}
+		found = true
+	}
+
+	if dir := viper.GetString("template_dir"); dir != "" {
+		path := filepath.Join(dir, "messages."+lang+".yaml")
+		data, err := os.ReadFile(path)
+		switch {
+		case err == nil:
+			overrides := make(map[string]string)
+			if err := yaml.Unmarshal(data, &overrides); err != nil {
+				logger.Warnf("ignoring %s: %v", path, err)
+				break
+			}
+			for key, value := range overrides {
+				messages[key] = value
+			}
+			found = true
+		case !errors.Is(err, fs.ErrNotExist):
+			logger.Warnf("failed to read %s: %v", path, err)
+		}
+	}
+
+	if !found {
+		logger.Warnf("no message catalog for language %q, using English", lang)
+	}
+	return messages
+}
+
+// tr translates a message and formats it with args like fmt.Sprintf.
+// Messages missing from the catalog are used untranslated.
+func tr(message string, args ...interface{}) string {
+	catalogOnce.Do(func() { catalog = loadCatalog() })
+	if translated, ok := catalog[message]; ok && translated != "" {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// statusIcon returns the icon shown next to a health check status
+func statusIcon(status string) string {
+	return map[string]string{
+		"Healthy":  "✅",
+		"Warning":  "⚠️",
+		"Critical": "❌",
+	}[status]
+}
+
+var templateFuncs = map[string]interface{}{
+	"t":       tr,
+	"icon":    statusIcon,
+	"latency": netmeshCell,
+	"heat":    netmeshHeat,
+	"join":    strings.Join,
+}
+
+// readTemplate returns a report template from the template directory, falling
+// back to the copy bundled with the binary
+func readTemplate(name string) (string, error) {
+	if dir := viper.GetString("template_dir"); dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err == nil {
+			return string(data), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	data, err := reportFiles.ReadFile("templates/" + name)
+	if err != nil {
+		return "", fmt.Errorf("no template %s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// renderText executes a text report template
+func renderText(w io.Writer, name string, data interface{}) error {
+	text, err := readTemplate(name)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return tmpl.Execute(w, data)
+}
+
+// renderHTML executes an HTML report template with contextual escaping
+func renderHTML(w io.Writer, name string, data interface{}) error {
+	text, err := readTemplate(name)
+	if err != nil {
+		return err
+	}
+	tmpl, err := htmltemplate.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	return tmpl.Execute(w, data)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PodUsage joins live pod metrics with the pod's requests and limits
+type PodUsage struct {
+	Namespace     string  `json:"namespace"`
+	Name          string  `json:"name"`
+	CPUUsage      int64   `json:"cpu_usage_millicores"`
+	CPURequest    int64   `json:"cpu_request_millicores"`
+	CPULimit      int64   `json:"cpu_limit_millicores"`
+	CPUPercent    float64 `json:"cpu_percent_of_request"`
+	MemoryUsage   int64   `json:"memory_usage_bytes"`
+	MemoryRequest int64   `json:"memory_request_bytes"`
+	MemoryLimit   int64   `json:"memory_limit_bytes"`
+	MemoryPercent float64 `json:"memory_percent_of_request"`
+}
+
+// NodeUsage joins live node metrics with allocatable capacity and the sum of pod requests
+type NodeUsage struct {
+	Name              string  `json:"name"`
+	CPUUsage          int64   `json:"cpu_usage_millicores"`
+	CPURequested      int64   `json:"cpu_requested_millicores"`
+	CPUAllocatable    int64   `json:"cpu_allocatable_millicores"`
+	CPUPercent        float64 `json:"cpu_percent_of_allocatable"`
+	MemoryUsage       int64   `json:"memory_usage_bytes"`
+	MemoryRequested   int64   `json:"memory_requested_bytes"`
+	MemoryAllocatable int64   `json:"memory_allocatable_bytes"`
+	MemoryPercent     float64 `json:"memory_percent_of_allocatable"`
+}
+
+// podResources sums the CPU (millicores) and memory (bytes) requests and limits of a pod's containers
+func podResources(pod *corev1.Pod) (cpuRequest, cpuLimit, memoryRequest, memoryLimit int64) {
+	for _, container := range pod.Spec.Containers {
+		cpuRequest += container.Resources.Requests.Cpu().MilliValue()
+		cpuLimit += container.Resources.Limits.Cpu().MilliValue()
+		memoryRequest += container.Resources.Requests.Memory().Value()
+		memoryLimit += container.Resources.Limits.Memory().Value()
+	}
+	return
+}
+
+// percentOf returns value as a percentage of total, or 0 when total is unknown
+func percentOf(value, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(value) / float64(total) * 100
+}
+
+// TopPods returns usage for pods matching the namespace and --selector
+func (k *K8sToolkit) TopPods(ctx context.Context) ([]PodUsage, error) {
+	if k.metricsClientset == nil {
+		return nil, fmt.Errorf("metrics server not available")
+	}
+
+	podMetrics, err := k.metricsClientset.MetricsV1beta1().PodMetricses(k.namespace).List(ctx, k.listOptions(metav1.ListOptions{}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod metrics: %w", err)
+	}
+
+	// Keep only the summed resources of each pod, not the pod objects
+	resourcesByKey := make(map[string][4]int64)
+	err = k.eachPod(ctx, k.namespace, k.listOptions(metav1.ListOptions{}), func(pod *corev1.Pod) error {
+		cpuReq, cpuLim, memReq, memLim := podResources(pod)
+		resourcesByKey[pod.Namespace+"/"+pod.Name] = [4]int64{cpuReq, cpuLim, memReq, memLim}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var usages []PodUsage
+	for _, metric := range podMetrics.Items {
+		if !k.inScope(metric.Namespace) {
+			continue
+		}
+		usage := PodUsage{Namespace: metric.Namespace, Name: metric.Name}
+		for _, container := range metric.Containers {
+			usage.CPUUsage += container.Usage.Cpu().MilliValue()
+			usage.MemoryUsage += container.Usage.Memory().Value()
+		}
+
+		if r, ok := resourcesByKey[metric.Namespace+"/"+metric.Name]; ok {
+			usage.CPURequest, usage.CPULimit, usage.MemoryRequest, usage.MemoryLimit = r[0], r[1], r[2], r[3]
+		}
+		usage.CPUPercent = percentOf(usage.CPUUsage, usage.CPURequest)
+		usage.MemoryPercent = percentOf(usage.MemoryUsage, usage.MemoryRequest)
+
+		usages = append(usages, usage)
+	}
+
+	return usages, nil
+}
+
+// TopNodes returns usage for nodes matching the label selector
+func (k *K8sToolkit) TopNodes(ctx context.Context, selector string) ([]NodeUsage, error) {
+	if k.metricsClientset == nil {
+		return nil, fmt.Errorf("metrics server not available")
+	}
+
+	nodeMetrics, err := k.metricsClientset.MetricsV1beta1().NodeMetricses().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node metrics: %w", err)
+	}
+
+	nodes, err := k.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	cpuRequested := make(map[string]int64)
+	memoryRequested := make(map[string]int64)
+	err = k.eachPod(ctx, "", metav1.ListOptions{}, func(pod *corev1.Pod) error {
+		if pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			return nil
+		}
+		cpu, _, memory, _ := podResources(pod)
+		cpuRequested[pod.Spec.NodeName] += cpu
+		memoryRequested[pod.Spec.NodeName] += memory
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	allocatable := make(map[string]corev1.ResourceList, len(nodes.Items))
+	for _, node := range nodes.Items {
+		allocatable[node.Name] = node.Status.Allocatable
+	}
+
+	var usages []NodeUsage
+	for _, metric := range nodeMetrics.Items {
+		resources, ok := allocatable[metric.Name]
+		if !ok {
+			continue
+		}
+
+		usage := NodeUsage{
+			Name:              metric.Name,
+			CPUUsage:          metric.Usage.Cpu().MilliValue(),
+			CPURequested:      cpuRequested[metric.Name],
+			CPUAllocatable:    resources.Cpu().MilliValue(),
+			MemoryUsage:       metric.Usage.Memory().Value(),
+			MemoryRequested:   memoryRequested[metric.Name],
+			MemoryAllocatable: resources.Memory().Value(),
+		}
+		usage.CPUPercent = percentOf(usage.CPUUsage, usage.CPUAllocatable)
+		usage.MemoryPercent = percentOf(usage.MemoryUsage, usage.MemoryAllocatable)
+
+		usages = append(usages, usage)
+	}
+
+	return usages, nil
+}
+
+// formatMemory renders bytes in Mi for table output
+func formatMemory(bytes int64) string {
+	return fmt.Sprintf("%dMi", bytes/(1024*1024))
+}
+
+// PrintTopPods prints pod usage
+func (k *K8sToolkit) PrintTopPods(usages []PodUsage) {
+	if k.filtered(usages) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(usages)
+		return
+	}
+
+	fmt.Printf("%-20s %-45s %8s %8s %8s %6s %9s %9s %9s %6s\n",
+		"NAMESPACE", "NAME", "CPU", "CPU-REQ", "CPU-LIM", "%REQ", "MEMORY", "MEM-REQ", "MEM-LIM", "%REQ")
+	for _, u := range usages {
+		fmt.Printf("%-20s %-45s %7dm %7dm %7dm %5.0f%% %9s %9s %9s %5.0f%%\n",
+			u.Namespace, u.Name,
+			u.CPUUsage, u.CPURequest, u.CPULimit, u.CPUPercent,
+			formatMemory(u.MemoryUsage), formatMemory(u.MemoryRequest), formatMemory(u.MemoryLimit), u.MemoryPercent)
+	}
+}
+
+// PrintTopNodes prints node usage
+func (k *K8sToolkit) PrintTopNodes(usages []NodeUsage) {
+	if k.filtered(usages) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(usages)
+		return
+	}
+
+	fmt.Printf("%-40s %8s %8s %8s %6s %9s %9s %9s %6s\n",
+		"NAME", "CPU", "CPU-REQ", "CPU-ALLOC", "%", "MEMORY", "MEM-REQ", "MEM-ALLOC", "%")
+	for _, u := range usages {
+		fmt.Printf("%-40s %7dm %7dm %8dm %5.0f%% %9s %9s %9s %5.0f%%\n",
+			u.Name,
+			u.CPUUsage, u.CPURequested, u.CPUAllocatable, u.CPUPercent,
+			formatMemory(u.MemoryUsage), formatMemory(u.MemoryRequested), formatMemory(u.MemoryAllocatable), u.MemoryPercent)
+	}
+}
+
+// createTopCmd creates the top command
+func createTopCmd() *cobra.Command {
+	var sortBy string
+	var limit int
+	var nodeSelector string
+
+	topCmd := &cobra.Command{
+		Use:   "top",
+		Short: "Show resource usage joined with requests and limits",
+		Long:  `Displays live CPU and memory usage for pods or nodes from the metrics server alongside requested and allocatable resources.`,
+	}
+	topCmd.PersistentFlags().StringVar(&sortBy, "sort-by", "cpu", "Sort by cpu or memory")
+	topCmd.PersistentFlags().IntVar(&limit, "limit", 0, "Maximum number of rows to show (0 for all)")
+
+	validateSort := func() {
+		if sortBy != "cpu" && sortBy != "memory" {
+			logger.Fatalf("Invalid --sort-by %q: must be cpu or memory", sortBy)
+		}
+	}
+
+	podsCmd := &cobra.Command{
+		Use:   "pods",
+		Short: "Show pod resource usage",
+		Run: func(cmd *cobra.Command, args []string) {
+			validateSort()
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			usages, err := toolkit.TopPods(context.Background())
+			if err != nil {
+				logger.Fatalf("Failed to get pod usage: %v", err)
+			}
+
+			sort.Slice(usages, func(i, j int) bool {
+				if sortBy == "memory" {
+					return usages[i].MemoryUsage > usages[j].MemoryUsage
+				}
+				return usages[i].CPUUsage > usages[j].CPUUsage
+			})
+			if limit > 0 && len(usages) > limit {
+				usages = usages[:limit]
+			}
+
+			toolkit.PrintTopPods(usages)
+		},
+	}
+
+	nodesCmd := &cobra.Command{
+		Use:   "nodes",
+		Short: "Show node resource usage",
+		Run: func(cmd *cobra.Command, args []string) {
+			validateSort()
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			usages, err := toolkit.TopNodes(context.Background(), nodeSelector)
+			if err != nil {
+				logger.Fatalf("Failed to get node usage: %v", err)
+			}
+
+			sort.Slice(usages, func(i, j int) bool {
+				if sortBy == "memory" {
+					return usages[i].MemoryUsage > usages[j].MemoryUsage
+				}
+				return usages[i].CPUUsage > usages[j].CPUUsage
+			})
+			if limit > 0 && len(usages) > limit {
+				usages = usages[:limit]
+			}
+
+			toolkit.PrintTopNodes(usages)
+		},
+	}
+
+	nodesCmd.Flags().StringVar(&nodeSelector, "node-selector", "", "Only show nodes matching this label selector")
+
+	topCmd.AddCommand(podsCmd, nodesCmd, createNoisyNeighborsCmd())
+	return topCmd
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+// tracer creates the toolkit's spans. It is a no-op until initTracing
+// installs an exporting provider.
+var tracer = otel.Tracer("github.com/devops-excellence/automation/go-tools/cli/k8s-toolkit")
+
+// tracerProvider is set while spans are exported, so they can be flushed on exit
+var tracerProvider *sdktrace.TracerProvider
+
+// initTracing exports spans over OTLP/gRPC when otel_endpoint is set
+func initTracing() {
+	endpoint := viper.GetString("otel_endpoint")
+	if endpoint == "" {
+		return
+	}
+	if offline() {
+		logger.Warnf("offline mode, tracing is disabled")
+		return
+	}
+
+	options := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+	if viper.GetBool("otel_insecure") {
+		options = append(options, otlptracegrpc.WithInsecure())
+	}
+	// The exporter connects lazily, so an unreachable collector only loses spans
+	exporter, err := otlptracegrpc.New(context.Background(), options...)
+	if err != nil {
+		logger.Fatalf("Failed to create OTLP exporter: %v", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("k8s-toolkit"),
+		semconv.ServiceVersion(version),
+	))
+	if err != nil {
+		logger.Fatalf("Failed to describe trace resource: %v", err)
+	}
+
+	tracerProvider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tracerProvider)
+
+	// Fatal errors exit through the logger; flush what was recorded first
+	logger.ExitFunc = func(code int) {
+		shutdownTracing()
+		os.Exit(code)
+	}
+}
+
+// shutdownTracing flushes and stops the span exporter, if any
+func shutdownTracing() {
+	if tracerProvider == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracerProvider.Shutdown(ctx); err != nil {
+		logger.Warnf("failed to flush traces: %v", err)
+	}
+	tracerProvider = nil
+}
+
+// tracingTransport wraps a Kubernetes client transport in a span per API call
+func tracingTransport(rt http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(rt, otelhttp.WithSpanNameFormatter(func(_ string, req *http.Request) string {
+		return req.Method + " " + req.URL.Path
+	}))
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// LikelyCause is one possible reason a workload is failing, with the
+// evidence found for it and commands to investigate further
+type LikelyCause struct {
+	Score     int      `json:"score"`
+	Title     string   `json:"title"`
+	Evidence  []string `json:"evidence"`
+	NextSteps []string `json:"next_steps"`
+}
+
+// Diagnosis is the result of troubleshooting a workload
+type Diagnosis struct {
+	Workload string        `json:"workload"`
+	Progress string        `json:"progress"`
+	Pods     int           `json:"pods"`
+	Causes   []LikelyCause `json:"causes"`
+}
+
+// diagnosis collects causes, merging evidence for the same cause
+type diagnosis struct {
+	causes map[string]*LikelyCause
+}
+
+func (d *diagnosis) add(score int, title, evidence string, nextSteps ...string) {
+	cause := d.causes[title]
+	if cause == nil {
+		cause = &LikelyCause{Score: score, Title: title, NextSteps: nextSteps}
+		d.causes[title] = cause
+	}
+	if score > cause.Score {
+		cause.Score = score
+	}
+	for _, e := range cause.Evidence {
+		if e == evidence {
+			return
+		}
+	}
+	cause.Evidence = append(cause.Evidence, evidence)
+}
+
+// failingWorkloads lists Deployments, StatefulSets and DaemonSets in the
+// namespace with fewer ready replicas than desired
+func (k *K8sToolkit) failingWorkloads(ctx context.Context, namespace string) ([]objectRef, error) {
+	var refs []objectRef
+	desired := func(replicas *int32) int32 {
+		if replicas == nil {
+			return 1
+		}
+		return *replicas
+	}
+
+	deployments, err := k.clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments.Items {
+		if d.Status.ReadyReplicas < desired(d.Spec.Replicas) {
+			refs = append(refs, objectRef{Kind: "Deployment", Namespace: namespace, Name: d.Name})
+		}
+	}
+
+	statefulSets, err := k.clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statefulSets.Items {
+		if s.Status.ReadyReplicas < desired(s.Spec.Replicas) {
+			refs = append(refs, objectRef{Kind: "StatefulSet", Namespace: namespace, Name: s.Name})
+		}
+	}
+
+	daemonSets, err := k.clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, ds := range daemonSets.Items {
+		if ds.Status.NumberReady < ds.Status.DesiredNumberScheduled {
+			refs = append(refs, objectRef{Kind: "DaemonSet", Namespace: namespace, Name: ds.Name})
+		}
+	}
+	return refs, nil
+}
+
+// pickWorkload asks the operator to choose one of the failing workloads
+func (k *K8sToolkit) pickWorkload(ctx context.Context, namespace string) (objectRef, error) {
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return objectRef{}, fmt.Errorf("no workload given and stdin is not interactive")
+	}
+
+	refs, err := k.failingWorkloads(ctx, namespace)
+	if err != nil {
+		return objectRef{}, fmt.Errorf("failed to list workloads: %w", err)
+	}
+	if len(refs) == 0 {
+		return objectRef{}, fmt.Errorf("no workload in %s has unready replicas; pass one as kind/name", namespace)
+	}
+
+	fmt.Printf("Workloads with unready replicas in %s:\n", namespace)
+	for i, ref := range refs {
+		fmt.Printf("  %d) %s/%s\n", i+1, ref.Kind, ref.Name)
+	}
+	fmt.Print("Which one should be diagnosed? ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || choice < 1 || choice > len(refs) {
+		return objectRef{}, fmt.Errorf("invalid choice %q", strings.TrimSpace(answer))
+	}
+	return refs[choice-1], nil
+}
+
+// Troubleshoot runs the checks relevant to a failing workload and ranks the
+// likely causes: container and scheduling failures of its pods, probe
+// failures, pods rejected by a ResourceQuota, Services without ready
+// endpoints, and NetworkPolicies that would drop its traffic.
+func (k *K8sToolkit) Troubleshoot(ctx context.Context, ref objectRef) (*Diagnosis, error) {
+	progress, err := k.rolloutStatus(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", ref, err)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(progress.selector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector of %s: %w", ref, err)
+	}
+
+	result := &Diagnosis{Workload: ref.String(), Progress: progress.String()}
+	d := &diagnosis{causes: make(map[string]*LikelyCause)}
+	podLogs := fmt.Sprintf("k8s-toolkit logs -n %s -l %s --tail 50", ref.Namespace, selector)
+
+	var pods []corev1.Pod
+	err = k.eachPod(ctx, ref.Namespace, metav1.ListOptions{LabelSelector: selector.String()}, func(pod *corev1.Pod) error {
+		pods = append(pods, *pod)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	result.Pods = len(pods)
+
+	for i := range pods {
+		pod := &pods[i]
+		describe := fmt.Sprintf("kubectl describe pod -n %s %s", pod.Namespace, pod.Name)
+		diagnoseContainers(d, pod, ref, describe, podLogs)
+
+		events, err := k.RecentEvents(ctx, objectRef{Kind: "Pod", Namespace: pod.Namespace, Name: pod.Name}, 10)
+		if err != nil {
+			continue
+		}
+		for _, event := range events {
+			if event.Reason == "Unhealthy" {
+				d.add(70, "Liveness or readiness probe failing", pod.Name+": "+strings.TrimSpace(event.Message),
+					"verify the probe path, port and initialDelaySeconds match the application",
+					describe)
+			}
+		}
+	}
+
+	// Pods rejected at creation never exist, so their errors are only
+	// visible as events on the owning controller
+	err = k.eachEvent(ctx, ref.Namespace, metav1.ListOptions{FieldSelector: "reason=FailedCreate"}, func(event *corev1.Event) error {
+		if !strings.HasPrefix(event.InvolvedObject.Name, ref.Name) {
+			return nil
+		}
+		if strings.Contains(event.Message, "exceeded quota") {
+			d.add(95, "ResourceQuota prevents new pods", strings.TrimSpace(event.Message),
+				fmt.Sprintf("k8s-toolkit quota -n %s", ref.Namespace),
+				"raise the quota or lower the workload's requests")
+		} else {
+			d.add(80, "Controller cannot create pods", strings.TrimSpace(event.Message),
+				fmt.Sprintf("kubectl describe %s -n %s %s", strings.ToLower(ref.Kind), ref.Namespace, ref.Name))
+		}
+		return nil
+	})
+	if err != nil {
+		k.log().Warnf("failed to list events: %v", err)
+	}
+
+	if len(pods) > 0 {
+		if err := k.diagnoseNetwork(ctx, d, ref, labels.Set(pods[0].Labels)); err != nil {
+			k.log().Warnf("network checks failed: %v", err)
+		}
+	}
+
+	for _, cause := range d.causes {
+		result.Causes = append(result.Causes, *cause)
+	}
+	sort.Slice(result.Causes, func(i, j int) bool {
+		if result.Causes[i].Score != result.Causes[j].Score {
+			return result.Causes[i].Score > result.Causes[j].Score
+		}
+		return result.Causes[i].Title < result.Causes[j].Title
+	})
+	return result, nil
+}
+
+// diagnoseContainers maps container and scheduling states of a pod to causes
+func diagnoseContainers(d *diagnosis, pod *corev1.Pod, ref objectRef, describe, podLogs string) {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+			d.add(85, "Pods cannot be scheduled", pod.Name+": "+c.Message,
+				fmt.Sprintf("k8s-toolkit capacity --workload %s/%s/%s", strings.ToLower(ref.Kind), ref.Namespace, ref.Name),
+				"check nodeSelector, affinity and tolerations against the available nodes")
+		}
+	}
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		evidence := func(detail string) string {
+			return fmt.Sprintf("%s/%s: %s", pod.Name, status.Name, strings.TrimSpace(detail))
+		}
+		if term := status.LastTerminationState.Terminated; term != nil && term.Reason == "OOMKilled" {
+			d.add(88, "Container runs out of memory", evidence(fmt.Sprintf("OOMKilled, %d restarts", status.RestartCount)),
+				"raise the memory limit or fix the leak",
+				fmt.Sprintf("k8s-toolkit optimize -n %s", pod.Namespace))
+		}
+		if status.State.Waiting == nil {
+			continue
+		}
+		waiting := status.State.Waiting
+		switch waiting.Reason {
+		case "ImagePullBackOff", "ErrImagePull", "InvalidImageName":
+			d.add(90, "Image cannot be pulled", evidence(waiting.Reason+" "+waiting.Message),
+				"check the image name and tag exist and imagePullSecrets grant access",
+				describe)
+		case "CreateContainerConfigError":
+			d.add(90, "Referenced ConfigMap or Secret is missing", evidence(waiting.Message),
+				fmt.Sprintf("kubectl get configmaps,secrets -n %s", pod.Namespace))
+		case "CrashLoopBackOff":
+			detail := fmt.Sprintf("CrashLoopBackOff, %d restarts", status.RestartCount)
+			if term := status.LastTerminationState.Terminated; term != nil {
+				detail += fmt.Sprintf(", last exit %d %s", term.ExitCode, term.Reason)
+			}
+			d.add(85, "Container crashes after starting", evidence(detail),
+				podLogs,
+				fmt.Sprintf("kubectl logs -n %s %s -c %s --previous", pod.Namespace, pod.Name, status.Name))
+		case "CreateContainerError", "RunContainerError":
+			d.add(80, "Container runtime cannot start the container", evidence(waiting.Message), describe)
+		}
+	}
+}
+
+// diagnoseNetwork simulates the NetworkPolicies of the namespace against the
+// workload's pod labels. Services with no ready endpoints are reported too,
+// since callers of the workload see the same symptoms either way.
+func (k *K8sToolkit) diagnoseNetwork(ctx context.Context, d *diagnosis, ref objectRef, podLabels labels.Set) error {
+	services, err := k.clientset.CoreV1().Services(ref.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var serving []string
+	for _, svc := range services.Items {
+		if len(svc.Spec.Selector) == 0 || !labels.SelectorFromSet(svc.Spec.Selector).Matches(podLabels) {
+			continue
+		}
+		serving = append(serving, svc.Name)
+		endpoints, err := k.clientset.CoreV1().Endpoints(ref.Namespace).Get(ctx, svc.Name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		ready := 0
+		for _, subset := range endpoints.Subsets {
+			ready += len(subset.Addresses)
+		}
+		if ready == 0 {
+			d.add(60, "Service has no ready endpoints", fmt.Sprintf("Service %s selects the workload but no pod is ready", svc.Name),
+				fmt.Sprintf("kubectl get endpoints -n %s %s", ref.Namespace, svc.Name))
+		}
+	}
+
+	policies, err := k.clientset.NetworkingV1().NetworkPolicies(ref.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	var ingressPolicies, egressPolicies []networkingv1.NetworkPolicy
+	for _, policy := range policies.Items {
+		selector, err := metav1.LabelSelectorAsSelector(&policy.Spec.PodSelector)
+		if err != nil || !selector.Matches(podLabels) {
+			continue
+		}
+		for _, t := range policyTypes(&policy) {
+			if t == networkingv1.PolicyTypeIngress {
+				ingressPolicies = append(ingressPolicies, policy)
+			} else {
+				egressPolicies = append(egressPolicies, policy)
+			}
+		}
+	}
+
+	netpols := fmt.Sprintf("kubectl get networkpolicies -n %s", ref.Namespace)
+	if len(serving) > 0 && len(ingressPolicies) > 0 {
+		allowsAny := false
+		for _, policy := range ingressPolicies {
+			allowsAny = allowsAny || len(policy.Spec.Ingress) > 0
+		}
+		if !allowsAny {
+			d.add(50, "NetworkPolicy blocks all ingress to the workload",
+				fmt.Sprintf("policies %s select the pods and allow no ingress, but Services %s route to them",
+					policyNames(ingressPolicies), strings.Join(serving, ", ")),
+				netpols, "add an ingress rule for the clients of the Service")
+		}
+	}
+	if len(egressPolicies) > 0 && !allowsDNS(egressPolicies) {
+		d.add(55, "NetworkPolicy blocks DNS lookups",
+			fmt.Sprintf("egress policies %s select the pods and none allows port 53", policyNames(egressPolicies)),
+			netpols, "add an egress rule allowing UDP and TCP port 53 to kube-dns")
+	}
+	return nil
+}
+
+// policyTypes returns the traffic directions a policy applies to, applying
+// the defaults for policies without policyTypes
+func policyTypes(policy *networkingv1.NetworkPolicy) []networkingv1.PolicyType {
+	if len(policy.Spec.PolicyTypes) > 0 {
+		return policy.Spec.PolicyTypes
+	}
+	types := []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	if len(policy.Spec.Egress) > 0 {
+		types = append(types, networkingv1.PolicyTypeEgress)
+	}
+	return types
+}
+
+// allowsDNS reports whether any egress rule allows traffic on port 53. A rule
+// without ports allows every port.
+func allowsDNS(policies []networkingv1.NetworkPolicy) bool {
+	dns := intstr.FromInt(53)
+	for _, policy := range policies {
+		for _, rule := range policy.Spec.Egress {
+			if len(rule.Ports) == 0 {
+				return true
+			}
+			for _, port := range rule.Ports {
+				if port.Port == nil || *port.Port == dns || port.Port.String() == "dns" || port.Port.String() == "dns-tcp" {
+					return true
+				}
+				if port.EndPort != nil && port.Port.Type == intstr.Int && port.Port.IntVal <= 53 && *port.EndPort >= 53 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func policyNames(policies []networkingv1.NetworkPolicy) string {
+	names := make([]string, 0, len(policies))
+	for _, policy := range policies {
+		names = append(names, policy.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// PrintDiagnosis prints the likely causes, most likely first
+func (k *K8sToolkit) PrintDiagnosis(diag *Diagnosis) {
+	if k.filtered(diag) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(diag)
+		return
+	}
+
+	fmt.Printf("\nDiagnosis of %s\n", diag.Workload)
+	fmt.Println("=====================================")
+	fmt.Printf("Rollout: %s, %d pods\n\n", diag.Progress, diag.Pods)
+	if len(diag.Causes) == 0 {
+		fmt.Println("No likely cause found. Check the application logs and recent changes to the workload.")
+		return
+	}
+	for i, cause := range diag.Causes {
+		fmt.Printf("%d. %s (confidence %d%%)\n", i+1, cause.Title, cause.Score)
+		for _, e := range cause.Evidence {
+			fmt.Printf("     - %s\n", e)
+		}
+		for _, step := range cause.NextSteps {
+			fmt.Printf("     → %s\n", step)
+		}
+		fmt.Println()
+	}
+}
+
+// createTroubleshootCmd creates the troubleshoot command
+func createTroubleshootCmd() *cobra.Command {
+	troubleshootCmd := &cobra.Command{
+		Use:   "troubleshoot [kind/name]",
+		Short: "Diagnose a failing workload",
+		Long: `Walks through diagnosing a failing Deployment, StatefulSet or DaemonSet in --namespace. Without
+an argument, lists the workloads with unready replicas and asks which one to diagnose. Checks pod
+scheduling, image pulls, crash loops, OOM kills, probe failures, pods rejected by a ResourceQuota,
+Services without endpoints and NetworkPolicies that would drop the workload's traffic, then prints
+the likely causes ranked by confidence with the next commands to run.`,
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+
+			ctx := context.Background()
+			namespace := toolkit.namespace
+			if namespace == "" {
+				namespace = "default"
+			}
+
+			var ref objectRef
+			if len(args) == 1 {
+				ref, err = parseWorkloadRef(namespace, args[0])
+			} else {
+				ref, err = toolkit.pickWorkload(ctx, namespace)
+			}
+			if err != nil {
+				logger.Fatalf("No workload to diagnose: %v", err)
+			}
+
+			diag, err := toolkit.Troubleshoot(ctx, ref)
+			if err != nil {
+				logger.Fatalf("Failed to diagnose %s: %v", ref, err)
+			}
+			toolkit.PrintDiagnosis(diag)
+		},
+	}
+
+	return troubleshootCmd
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// apiDeprecation describes an API version that is deprecated or removed in a
+// Kubernetes minor release. Resource is the plural name served under Replacement.
+type apiDeprecation struct {
+	APIVersion   string `yaml:"api_version"`
+	Kind         string `yaml:"kind"`
+	DeprecatedIn int    `yaml:"deprecated_in"`
+	RemovedIn    int    `yaml:"removed_in"`
+	Replacement  string `yaml:"replacement"`
+	Resource     string `yaml:"resource"`
+}
+
+// apiDeprecations lists deprecated API versions by 1.x minor release. It is
+// loaded from the deprecations data bundle by loadAPIDeprecations.
+var apiDeprecations []apiDeprecation
+
+// loadAPIDeprecations reads the deprecation table from the data directory,
+// falling back to the copy bundled with the binary
+func loadAPIDeprecations() error {
+	data, _, err := loadDataBundle("deprecations.yaml")
+	if err != nil {
+		return err
+	}
+	var table struct {
+		Deprecations []apiDeprecation `yaml:"deprecations"`
+	}
+	if err := yaml.Unmarshal(data, &table); err != nil {
+		return fmt.Errorf("failed to parse deprecations.yaml: %w", err)
+	}
+	apiDeprecations = table.Deprecations
+	return nil
+}
+
+// DeprecatedAPIUsage is a resource that uses an API version deprecated or removed in the target release
+type DeprecatedAPIUsage struct {
+	Source      string `json:"source"`
+	APIVersion  string `json:"api_version"`
+	Kind        string `json:"kind"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name"`
+	Removed     bool   `json:"removed"`
+	RemovedIn   string `json:"removed_in"`
+	Replacement string `json:"replacement"`
+}
+
+// parseMinorVersion parses "1.30" or "v1.30.2" into its minor version
+func parseMinorVersion(version string) (int, error) {
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) < 2 || parts[0] != "1" {
+		return 0, fmt.Errorf("invalid Kubernetes version %q", version)
+	}
+	return strconv.Atoi(parts[1])
+}
+
+// deprecationFor returns the deprecation entry affecting apiVersion/kind in the target release
+func deprecationFor(apiVersion, kind string, target int) (apiDeprecation, bool) {
+	for _, d := range apiDeprecations {
+		if d.APIVersion == apiVersion && d.Kind == kind && target >= d.DeprecatedIn {
+			return d, true
+		}
+	}
+	return apiDeprecation{}, false
+}
+
+// newDeprecatedAPIUsage builds a usage record for a matching deprecation
+func newDeprecatedAPIUsage(source string, d apiDeprecation, target int, namespace, name string) DeprecatedAPIUsage {
+	replacement := d.Replacement
+	if replacement == "" {
+		replacement = "none (API removed without replacement)"
+	}
+	return DeprecatedAPIUsage{
+		Source:      source,
+		APIVersion:  d.APIVersion,
+		Kind:        d.Kind,
+		Namespace:   namespace,
+		Name:        name,
+		Removed:     target >= d.RemovedIn,
+		RemovedIn:   fmt.Sprintf("1.%d", d.RemovedIn),
+		Replacement: replacement,
+	}
+}
+
+// ScanLiveDeprecatedAPIs finds live objects last applied or managed through a
+// deprecated API version. The server converts objects to every served version,
+// so the version used by clients is taken from the last-applied annotation and managedFields.
+func (k *K8sToolkit) ScanLiveDeprecatedAPIs(ctx context.Context, target int) ([]DeprecatedAPIUsage, error) {
+	var usages []DeprecatedAPIUsage
+	scanned := make(map[string]bool)
+
+	for _, d := range apiDeprecations {
+		if target < d.DeprecatedIn || d.Replacement == "" {
+			continue
+		}
+
+		gv, err := schema.ParseGroupVersion(d.Replacement)
+		if err != nil {
+			return nil, err
+		}
+		gvr := gv.WithResource(d.Resource)
+		if scanned[gvr.String()] {
+			continue
+		}
+		scanned[gvr.String()] = true
+
+		list, err := k.dynamicClient.Resource(gvr).Namespace(k.namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			k.log().Warnf("skipping %s: %v", gvr.String(), err)
+			continue
+		}
+
+		for _, item := range inScopeItems(k, list.Items) {
+			versions := make(map[string]bool)
+			if lastApplied, ok := item.GetAnnotations()["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+				var applied struct {
+					APIVersion string `json:"apiVersion"`
+				}
+				if json.Unmarshal([]byte(lastApplied), &applied) == nil && applied.APIVersion != "" {
+					versions[applied.APIVersion] = true
+				}
+			}
+			for _, entry := range item.GetManagedFields() {
+				versions[entry.APIVersion] = true
+			}
+
+			for version := range versions {
+				if match, ok := deprecationFor(version, item.GetKind(), target); ok {
+					usages = append(usages, newDeprecatedAPIUsage("cluster", match, target, item.GetNamespace(), item.GetName()))
+				}
+			}
+		}
+	}
+
+	return usages, nil
+}
+
+// ScanManifestDeprecatedAPIs finds manifests under path that use deprecated API versions
+func ScanManifestDeprecatedAPIs(path string, target int) ([]DeprecatedAPIUsage, error) {
+	var usages []DeprecatedAPIUsage
+
+	err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || (filepath.Ext(file) != ".yaml" && filepath.Ext(file) != ".yml") {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		decoder := yaml.NewDecoder(f)
+		for {
+			var doc struct {
+				APIVersion string `yaml:"apiVersion"`
+				Kind       string `yaml:"kind"`
+				Metadata   struct {
+					Name      string `yaml:"name"`
+					Namespace string `yaml:"namespace"`
+				} `yaml:"metadata"`
+			}
+			if err := decoder.Decode(&doc); err != nil {
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				return fmt.Errorf("failed to parse %s: %w", file, err)
+			}
+
+			if match, ok := deprecationFor(doc.APIVersion, doc.Kind, target); ok {
+				usages = append(usages, newDeprecatedAPIUsage(file, match, target, doc.Metadata.Namespace, doc.Metadata.Name))
+			}
+		}
+		return nil
+	})
+
+	return usages, err
+}
+
+// PrintDeprecatedAPIUsages prints the upgrade readiness report
+func (k *K8sToolkit) PrintDeprecatedAPIUsages(usages []DeprecatedAPIUsage, target string) {
+	if k.filtered(usages) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(usages)
+		return
+	}
+
+	if len(usages) == 0 {
+		fmt.Printf("No deprecated API usage found for Kubernetes %s\n", target)
+		return
+	}
+
+	fmt.Printf("Deprecated API usage for Kubernetes %s:\n\n", target)
+	for _, u := range usages {
+		state := "deprecated"
+		if u.Removed {
+			state = "REMOVED"
+		}
+		name := u.Name
+		if u.Namespace != "" {
+			name = u.Namespace + "/" + u.Name
+		}
+		fmt.Printf("[%s in %s] %s %s %s (%s) -> %s\n", state, u.RemovedIn, u.Kind, name, u.APIVersion, u.Source, u.Replacement)
+	}
+}
+
+// createUpgradeCheckCmd creates the upgrade-check command
+func createUpgradeCheckCmd() *cobra.Command {
+	var targetVersion string
+	var manifestPath string
+	var skipCluster bool
+
+	upgradeCmd := &cobra.Command{
+		Use:   "upgrade-check",
+		Short: "Find API versions deprecated or removed in a target Kubernetes version",
+		Long:  `Scans live objects and optionally manifest files for API versions deprecated or removed in the target Kubernetes version, listing each resource with its replacement apiVersion.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			target, err := parseMinorVersion(targetVersion)
+			if err != nil {
+				logger.Fatalf("Invalid --target-version: %v", err)
+			}
+			if err := loadAPIDeprecations(); err != nil {
+				logger.Fatalf("Failed to load deprecation data: %v", err)
+			}
+
+			toolkit := &K8sToolkit{output: viper.GetString("output")}
+			var usages []DeprecatedAPIUsage
+
+			if !skipCluster {
+				toolkit, err = NewK8sToolkit()
+				if err != nil {
+					logger.Fatalf("Failed to initialize toolkit: %v", err)
+				}
+				live, err := toolkit.ScanLiveDeprecatedAPIs(context.Background(), target)
+				if err != nil {
+					logger.Fatalf("Failed to scan cluster: %v", err)
+				}
+				usages = append(usages, live...)
+			}
+
+			if manifestPath != "" {
+				manifests, err := ScanManifestDeprecatedAPIs(manifestPath, target)
+				if err != nil {
+					logger.Fatalf("Failed to scan manifests: %v", err)
+				}
+				usages = append(usages, manifests...)
+			}
+
+			toolkit.PrintDeprecatedAPIUsages(usages, targetVersion)
+
+			for _, u := range usages {
+				if u.Removed {
+					os.Exit(1)
+				}
+			}
+		},
+	}
+
+	upgradeCmd.Flags().StringVar(&targetVersion, "target-version", "", "Kubernetes version to upgrade to (e.g. 1.30)")
+	upgradeCmd.Flags().StringVar(&manifestPath, "path", "", "Directory of manifest files to scan")
+	upgradeCmd.Flags().BoolVar(&skipCluster, "skip-cluster", false, "Only scan manifest files")
+	upgradeCmd.MarkFlagRequired("target-version")
+
+	return upgradeCmd
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Versions tried in order for the policy engine resources
+var (
+	constraintTemplateVersions = []string{"v1", "v1beta1"}
+	policyReportVersions       = []string{"v1alpha2", "v1beta1"}
+)
+
+// policyViolation is one current violation of an admission policy
+type policyViolation struct {
+	policy    string
+	namespace string
+	resource  string
+	message   string
+	enforced  bool
+}
+
+// listFirstServed lists resource in namespace using the first served
+// version. It returns false when the CRD is not installed.
+func (k *K8sToolkit) listFirstServed(ctx context.Context, group, resource string, versions []string, namespace string) ([]unstructured.Unstructured, bool, error) {
+	for _, version := range versions {
+		gvr := schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+		list, err := k.dynamicClient.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to list %s.%s: %w", resource, group, err)
+		}
+		return list.Items, true, nil
+	}
+	return nil, false, nil
+}
+
+// gatekeeperViolations reads the audit results recorded in the status of
+// every Gatekeeper constraint. Gatekeeper stores a limited number of
+// violations per constraint, so totals come from status.totalViolations
+// when the whole cluster is in scope.
+func (k *K8sToolkit) gatekeeperViolations(ctx context.Context) ([]policyViolation, map[string]int, bool, error) {
+	templates, found, err := k.listFirstServed(ctx, "templates.gatekeeper.sh", "constrainttemplates", constraintTemplateVersions, "")
+	if err != nil || !found {
+		return nil, nil, found, err
+	}
+
+	var violations []policyViolation
+	totals := make(map[string]int)
+	for _, template := range templates {
+		kind, _, _ := unstructured.NestedString(template.Object, "spec", "crd", "spec", "names", "kind")
+		if kind == "" {
+			continue
+		}
+		gvr := schema.GroupVersionResource{Group: "constraints.gatekeeper.sh", Version: "v1beta1", Resource: strings.ToLower(kind)}
+		constraints, err := k.dynamicClient.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, true, fmt.Errorf("failed to list %s constraints: %w", kind, err)
+		}
+
+		for _, constraint := range constraints.Items {
+			policy := kind + "/" + constraint.GetName()
+			action, _, _ := unstructured.NestedString(constraint.Object, "spec", "enforcementAction")
+			total, _, _ := unstructured.NestedInt64(constraint.Object, "status", "totalViolations")
+			totals[policy] = int(total)
+
+			items, _, _ := unstructured.NestedSlice(constraint.Object, "status", "violations")
+			for _, item := range items {
+				v, _ := item.(map[string]interface{})
+				namespace, _ := v["namespace"].(string)
+				if !k.inViolationScope(namespace) {
+					continue
+				}
+				resourceKind, _ := v["kind"].(string)
+				name, _ := v["name"].(string)
+				message, _ := v["message"].(string)
+				itemAction, _ := v["enforcementAction"].(string)
+				if itemAction == "" {
+					itemAction = action
+				}
+				violations = append(violations, policyViolation{
+					policy:    policy,
+					namespace: namespace,
+					resource:  resourceKind + "/" + name,
+					message:   message,
+					enforced:  itemAction == "" || itemAction == "deny",
+				})
+			}
+		}
+	}
+	return violations, totals, true, nil
+}
+
+// kyvernoViolations reads the failed results of Kyverno's PolicyReports and,
+// when the whole cluster is in scope, ClusterPolicyReports
+func (k *K8sToolkit) kyvernoViolations(ctx context.Context) ([]policyViolation, bool, error) {
+	reports, found, err := k.listFirstServed(ctx, "wgpolicyk8s.io", "policyreports", policyReportVersions, k.namespace)
+	if err != nil || !found {
+		return nil, found, err
+	}
+	if k.namespace == "" {
+		clusterReports, _, err := k.listFirstServed(ctx, "wgpolicyk8s.io", "clusterpolicyreports", policyReportVersions, "")
+		if err != nil {
+			return nil, true, err
+		}
+		reports = append(reports, clusterReports...)
+	}
+
+	var violations []policyViolation
+	for _, report := range reports {
+		if !k.inViolationScope(report.GetNamespace()) {
+			continue
+		}
+		results, _, _ := unstructured.NestedSlice(report.Object, "results")
+		for _, item := range results {
+			r, _ := item.(map[string]interface{})
+			outcome, _ := r["result"].(string)
+			if outcome != "fail" && outcome != "warn" {
+				continue
+			}
+			policy, _ := r["policy"].(string)
+			if rule, _ := r["rule"].(string); rule != "" {
+				policy += "/" + rule
+			}
+			message, _ := r["message"].(string)
+			severity, _ := r["severity"].(string)
+
+			resource := ""
+			if resources, _ := r["resources"].([]interface{}); len(resources) > 0 {
+				ref, _ := resources[0].(map[string]interface{})
+				kind, _ := ref["kind"].(string)
+				name, _ := ref["name"].(string)
+				resource = kind + "/" + name
+			}
+			violations = append(violations, policyViolation{
+				policy:    policy,
+				namespace: report.GetNamespace(),
+				resource:  resource,
+				message:   message,
+				enforced:  outcome == "fail" && (severity == "critical" || severity == "high"),
+			})
+		}
+	}
+	return violations, true, nil
+}
+
+// inViolationScope reports whether a violation in namespace is covered by
+// --namespace and --exclude-namespaces; cluster-scoped ones only count when
+// no namespace is selected
+func (k *K8sToolkit) inViolationScope(namespace string) bool {
+	if k.namespace != "" && namespace != k.namespace {
+		return false
+	}
+	return k.inScope(namespace)
+}
+
+// topCounts renders the largest counts as "key=n, ..." limited to limit entries
+func topCounts(counts map[string]int, limit int) string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	var parts []string
+	for i, key := range keys {
+		if i == limit {
+			parts = append(parts, fmt.Sprintf("%d more", len(keys)-limit))
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s=%d", key, counts[key]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CheckPolicyViolations aggregates the current violations reported by OPA
+// Gatekeeper audits and Kyverno PolicyReports by policy and namespace. It is
+// Healthy when neither engine is installed. Violations of enforced policies
+// (Gatekeeper deny, Kyverno failures of high or critical severity) are
+// Critical, since the resources predate the policy or bypassed it.
+func (k *K8sToolkit) CheckPolicyViolations(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Policy Violations",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	if k.dynamicClient == nil {
+		result.Status = "Healthy"
+		result.Message = "Policy violation check skipped: dynamic client unavailable"
+		return result
+	}
+
+	var engines []string
+	gatekeeper, totals, found, err := k.gatekeeperViolations(ctx)
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to read Gatekeeper constraints: %v", err)
+		result.Err = err
+		return result
+	}
+	if found {
+		engines = append(engines, "Gatekeeper")
+	}
+	kyverno, found, err := k.kyvernoViolations(ctx)
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to read Kyverno policy reports: %v", err)
+		result.Err = err
+		return result
+	}
+	if found {
+		engines = append(engines, "Kyverno")
+	}
+
+	if len(engines) == 0 {
+		result.Status = "Healthy"
+		result.Message = "No Gatekeeper or Kyverno installation found"
+		return result
+	}
+	result.Details["engines"] = strings.Join(engines, ", ")
+
+	byPolicy := make(map[string]int)
+	byNamespace := make(map[string]int)
+	enforced := 0
+	violations := append(gatekeeper, kyverno...)
+	for _, v := range violations {
+		byPolicy[v.policy]++
+		namespace := v.namespace
+		if namespace == "" {
+			namespace = "(cluster)"
+		}
+		byNamespace[namespace]++
+		if v.enforced {
+			enforced++
+		}
+	}
+
+	// Gatekeeper keeps only a sample of violations per constraint; its
+	// totals are exact when nothing is filtered out
+	total := len(violations)
+	if k.namespace == "" && len(k.excludeNamespaces) == 0 {
+		for policy, n := range totals {
+			if n > byPolicy[policy] {
+				total += n - byPolicy[policy]
+				byPolicy[policy] = n
+			}
+		}
+	}
+
+	result.Details["violations"] = strconv.Itoa(total)
+	result.Details["enforced_violations"] = strconv.Itoa(enforced)
+	if total == 0 {
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("No current violations reported by %s", strings.Join(engines, " or "))
+		return result
+	}
+	result.Details["by_policy"] = topCounts(byPolicy, 10)
+	result.Details["by_namespace"] = topCounts(byNamespace, 10)
+
+	sort.SliceStable(violations, func(i, j int) bool { return violations[i].enforced && !violations[j].enforced })
+	var examples []string
+	for i, v := range violations {
+		if i == 5 {
+			break
+		}
+		example := fmt.Sprintf("%s %s", v.policy, v.resource)
+		if v.namespace != "" {
+			example = fmt.Sprintf("%s %s/%s", v.policy, v.namespace, v.resource)
+		}
+		if v.message != "" {
+			example += ": " + v.message
+		}
+		examples = append(examples, example)
+	}
+	result.Details["examples"] = strings.Join(examples, "; ")
+
+	if enforced > 0 {
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("%d policy violations across %d policies in %d namespaces, %d of enforced policies", total, len(byPolicy), len(byNamespace), enforced)
+	} else {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("%d policy violations across %d policies in %d namespaces", total, len(byPolicy), len(byNamespace))
+	}
+	return result
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// vulnSeverities maps Trivy severities to finding severities, in report order
+var vulnSeverities = []struct{ trivy, finding string }{
+	{"CRITICAL", "Critical"},
+	{"HIGH", "High"},
+	{"MEDIUM", "Medium"},
+	{"LOW", "Low"},
+}
+
+// ImageScan is the Trivy result for one image
+type ImageScan struct {
+	Image  string         `json:"image"`
+	Counts map[string]int `json:"counts"`
+	CVEs   []string       `json:"cves,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// WorkloadVulns aggregates CVE counts across the images of one workload
+type WorkloadVulns struct {
+	Namespace string         `json:"namespace"`
+	Workload  string         `json:"workload"`
+	Images    []string       `json:"images"`
+	Counts    map[string]int `json:"counts"`
+}
+
+// VulnReport is the result of a cluster image vulnerability scan
+type VulnReport struct {
+	SchemaVersion string          `json:"schema_version"`
+	Workloads     []WorkloadVulns `json:"workloads"`
+	Scans         []ImageScan     `json:"scans"`
+	Findings      []Finding       `json:"findings"`
+}
+
+// VulnScanOptions configures ScanVulnerabilities
+type VulnScanOptions struct {
+	TrivyPath string
+	Parallel  int
+	Timeout   time.Duration
+
+	// Offline uses the local vulnerability database without updating it
+	Offline bool
+}
+
+// trivyOutput is the subset of `trivy image --format json` used here
+type trivyOutput struct {
+	Results []struct {
+		Target          string `json:"Target"`
+		Vulnerabilities []struct {
+			VulnerabilityID string `json:"VulnerabilityID"`
+			Severity        string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// scanImage runs Trivy against a single image
+func scanImage(ctx context.Context, opts VulnScanOptions, image string) ImageScan {
+	scan := ImageScan{Image: image, Counts: make(map[string]int)}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	args := []string{"image", "--quiet", "--format", "json"}
+	if opts.Offline {
+		args = append(args, "--skip-db-update", "--skip-java-db-update", "--offline-scan")
+	}
+	cmd := exec.CommandContext(ctx, opts.TrivyPath, append(args, image)...)
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("%v: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		scan.Error = err.Error()
+		return scan
+	}
+
+	var result trivyOutput
+	if err := json.Unmarshal(out, &result); err != nil {
+		scan.Error = fmt.Sprintf("failed to parse trivy output: %v", err)
+		return scan
+	}
+
+	// The same CVE can be reported by several targets (OS packages, language
+	// packages) within one image; count it once
+	seen := make(map[string]bool)
+	for _, target := range result.Results {
+		for _, vuln := range target.Vulnerabilities {
+			if seen[vuln.VulnerabilityID] {
+				continue
+			}
+			seen[vuln.VulnerabilityID] = true
+			scan.Counts[vuln.Severity]++
+			if vuln.Severity == "CRITICAL" {
+				scan.CVEs = append(scan.CVEs, vuln.VulnerabilityID)
+			}
+		}
+	}
+	sort.Strings(scan.CVEs)
+	return scan
+}
+
+// ScanVulnerabilities scans every unique running image with Trivy and
+// aggregates CVE counts by severity per workload
+func (k *K8sToolkit) ScanVulnerabilities(ctx context.Context, opts VulnScanOptions) (*VulnReport, error) {
+	if _, err := exec.LookPath(opts.TrivyPath); err != nil {
+		return nil, fmt.Errorf("trivy not found: %w", err)
+	}
+
+	inventory, err := k.AuditImages(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var images []string
+	seen := make(map[string]bool)
+	for _, ref := range inventory.Images {
+		if !seen[ref.Image] {
+			seen[ref.Image] = true
+			images = append(images, ref.Image)
+		}
+	}
+	sort.Strings(images)
+
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	scans := make([]ImageScan, len(images))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				scans[i] = scanImage(ctx, opts, images[i])
+			}
+		}()
+	}
+	for i := range images {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	byImage := make(map[string]ImageScan, len(scans))
+	for _, scan := range scans {
+		byImage[scan.Image] = scan
+	}
+
+	report := &VulnReport{SchemaVersion: vulnerabilitiesSchemaVersion, Scans: scans}
+	byWorkload := make(map[string]*WorkloadVulns)
+	var order []string
+	for _, ref := range inventory.Images {
+		key := ref.Namespace + "/" + ref.Workload
+		wv, ok := byWorkload[key]
+		if !ok {
+			wv = &WorkloadVulns{Namespace: ref.Namespace, Workload: ref.Workload, Counts: make(map[string]int)}
+			byWorkload[key] = wv
+			order = append(order, key)
+		}
+		if containsString(wv.Images, ref.Image) {
+			continue
+		}
+		wv.Images = append(wv.Images, ref.Image)
+		for severity, count := range byImage[ref.Image].Counts {
+			wv.Counts[severity] += count
+		}
+	}
+
+	for _, key := range order {
+		wv := byWorkload[key]
+		report.Workloads = append(report.Workloads, *wv)
+		for _, severity := range vulnSeverities {
+			count := wv.Counts[severity.trivy]
+			if count == 0 {
+				continue
+			}
+			report.Findings = append(report.Findings, Finding{
+				Source:    "security-vulns",
+				RuleID:    "vuln/" + strings.ToLower(severity.trivy),
+				Severity:  severity.finding,
+				Namespace: wv.Namespace,
+				Resource:  wv.Workload,
+				Message:   fmt.Sprintf("%d %s vulnerabilities in %s", count, strings.ToLower(severity.trivy), strings.Join(wv.Images, ", ")),
+			})
+		}
+	}
+	sortFindings(report.Findings)
+
+	return report, nil
+}
+
+// vulnFindings adapts ScanVulnerabilities to a compliance finding source
+func (k *K8sToolkit) vulnFindings(ctx context.Context) ([]Finding, error) {
+	report, err := k.ScanVulnerabilities(ctx, vulnScanOptions())
+	if err != nil {
+		return nil, err
+	}
+	return report.Findings, nil
+}
+
+// vulnScanOptions reads scanner settings from the config
+func vulnScanOptions() VulnScanOptions {
+	return VulnScanOptions{
+		TrivyPath: viper.GetString("security.vulns.trivy_path"),
+		Parallel:  viper.GetInt("security.vulns.parallel"),
+		Timeout:   viper.GetDuration("security.vulns.timeout"),
+		Offline:   offline(),
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintVulnReport prints CVE counts per workload and any images that failed to scan
+func (k *K8sToolkit) PrintVulnReport(report *VulnReport) {
+	if k.filtered(report) {
+		return
+	}
+	if k.output == "json" {
+		printJSON(report)
+		return
+	}
+	if k.output == "sarif" {
+		printSARIF("Image Vulnerability Report", report.Findings)
+		return
+	}
+
+	fmt.Printf("Image Vulnerability Report\n")
+	fmt.Printf("%-20s %-40s %8s %8s %8s %8s\n", "NAMESPACE", "WORKLOAD", "CRITICAL", "HIGH", "MEDIUM", "LOW")
+	for _, wv := range report.Workloads {
+		fmt.Printf("%-20s %-40s %8d %8d %8d %8d\n", wv.Namespace, wv.Workload,
+			wv.Counts["CRITICAL"], wv.Counts["HIGH"], wv.Counts["MEDIUM"], wv.Counts["LOW"])
+	}
+
+	var failed []string
+	for _, scan := range report.Scans {
+		if scan.Error != "" {
+			failed = append(failed, fmt.Sprintf("  %s: %s", scan.Image, scan.Error))
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Printf("\nImages that could not be scanned:\n%s\n", strings.Join(failed, "\n"))
+	}
+	fmt.Println()
+}
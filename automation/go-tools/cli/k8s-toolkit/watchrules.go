@@ -0,0 +1,345 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// watchRule is an entry under watch_rules in the config file or rules in a
+// --rules-file. Condition is a CEL expression over `object`, the resource as
+// in its YAML form, and `now`; it must hold for For before the rule fires.
+type watchRule struct {
+	Name      string        `mapstructure:"name"`
+	Resource  string        `mapstructure:"resource"`
+	Namespace string        `mapstructure:"namespace"`
+	Selector  string        `mapstructure:"selector"`
+	Condition string        `mapstructure:"condition"`
+	For       time.Duration `mapstructure:"for"`
+	Severity  string        `mapstructure:"severity"`
+	Message   string        `mapstructure:"message"`
+
+	gvr     schema.GroupVersionResource
+	program cel.Program
+	message *template.Template
+}
+
+// watchRuleMessage is passed to the message template of a rule
+type watchRuleMessage struct {
+	Rule      string
+	Kind      string
+	Namespace string
+	Name      string
+	Since     time.Time
+	Object    map[string]interface{}
+}
+
+// parseResource reads a resource as group/version/resource, or
+// version/resource for the core group, e.g. apps/v1/deployments or v1/secrets
+func parseResource(resource string) (schema.GroupVersionResource, error) {
+	parts := strings.Split(resource, "/")
+	switch len(parts) {
+	case 2:
+		return schema.GroupVersionResource{Version: parts[0], Resource: parts[1]}, nil
+	case 3:
+		return schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]}, nil
+	}
+	return schema.GroupVersionResource{}, fmt.Errorf("invalid resource %q: use group/version/resource or v1/resource", resource)
+}
+
+// loadWatchRules reads and compiles the rules from file, or from watch_rules
+// in the config file when file is empty
+func loadWatchRules(file string) ([]watchRule, error) {
+	source, key := viper.GetViper(), "watch_rules"
+	if file != "" {
+		source, key = viper.New(), "rules"
+		source.SetConfigFile(file)
+		if err := source.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+	}
+	var rules []watchRule
+	if err := source.UnmarshalKey(key, &rules); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no watch rules configured: add them under %s", key)
+	}
+
+	env, err := cel.NewEnv(
+		cel.CrossTypeNumericComparisons(true),
+		cel.Variable("object", cel.DynType),
+		cel.Variable("now", cel.TimestampType),
+	)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" || names[rule.Name] {
+			return nil, fmt.Errorf("%s[%d]: every rule needs a unique name", key, i)
+		}
+		names[rule.Name] = true
+		if rule.gvr, err = parseResource(rule.Resource); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		switch rule.Severity {
+		case "":
+			rule.Severity = "Warning"
+		case "Warning", "Critical":
+		default:
+			return nil, fmt.Errorf("rule %s: unknown severity %q (Warning|Critical)", rule.Name, rule.Severity)
+		}
+
+		ast, issues := env.Compile(rule.Condition)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("rule %s: invalid condition: %w", rule.Name, issues.Err())
+		}
+		if output := ast.OutputType().String(); output != cel.BoolType.String() && output != cel.DynType.String() {
+			return nil, fmt.Errorf("rule %s: condition must be a boolean, not %s", rule.Name, ast.OutputType())
+		}
+		if rule.program, err = env.Program(ast); err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+
+		text := rule.Message
+		if text == "" {
+			text = "{{.Kind}} {{.Namespace}}/{{.Name}} matched {{.Rule}} since {{.Since.Format \"2006-01-02 15:04:05\"}}"
+		}
+		if rule.message, err = template.New(rule.Name).Funcs(templateFuncs).Parse(text); err != nil {
+			return nil, fmt.Errorf("rule %s: invalid message template: %w", rule.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+// matches evaluates the condition of a rule against an object. Conditions
+// that fail to evaluate, e.g. on a field the object does not have, do not
+// match; guard optional fields with has().
+func (r *watchRule) matches(obj *unstructured.Unstructured, now time.Time) (bool, error) {
+	value, _, err := r.program.Eval(map[string]interface{}{"object": obj.Object, "now": now})
+	if err != nil {
+		return false, err
+	}
+	matched, ok := value.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("condition returned %s, not a boolean", value.Type())
+	}
+	return matched, nil
+}
+
+// ruleWatcher evaluates one rule against its informer's cache. Since holds
+// when the condition started holding per object and status the last status
+// reported for objects that are not Healthy.
+type ruleWatcher struct {
+	rule     *watchRule
+	informer cache.SharedIndexInformer
+	since    map[string]time.Time
+	status   map[string]string
+}
+
+// evaluateRule checks every cached object and reports status changes. Objects
+// that disappeared while firing are reported Healthy so their alert resolves.
+func (k *K8sToolkit) evaluateRule(ctx context.Context, w *ruleWatcher, notifier *Notifier, now time.Time) {
+	seen := make(map[string]bool)
+	for _, item := range w.informer.GetStore().List() {
+		obj, ok := item.(*unstructured.Unstructured)
+		if !ok || !k.inScope(obj.GetNamespace()) {
+			continue
+		}
+		key := obj.GetKind() + " " + obj.GetNamespace() + "/" + obj.GetName()
+		if obj.GetNamespace() == "" {
+			key = obj.GetKind() + " " + obj.GetName()
+		}
+		seen[key] = true
+
+		matched, err := w.rule.matches(obj, now)
+		if err != nil {
+			k.log().Debugf("rule %s on %s: %v", w.rule.Name, key, err)
+		}
+		status := "Healthy"
+		if matched {
+			if _, ok := w.since[key]; !ok {
+				w.since[key] = now
+			}
+			if now.Sub(w.since[key]) >= w.rule.For {
+				status = w.rule.Severity
+			}
+		} else {
+			delete(w.since, key)
+		}
+		k.reportRule(ctx, w, notifier, key, obj, status)
+	}
+
+	for key := range w.status {
+		if !seen[key] {
+			k.reportRule(ctx, w, notifier, key, nil, "Healthy")
+			delete(w.since, key)
+		}
+	}
+}
+
+// reportRule logs a status change of a rule on one object and passes it to
+// the notifier, which routes it by rule name like a check
+func (k *K8sToolkit) reportRule(ctx context.Context, w *ruleWatcher, notifier *Notifier, key string, obj *unstructured.Unstructured, status string) {
+	previous, ok := w.status[key]
+	if !ok {
+		previous = "Healthy"
+	}
+	if status == previous {
+		return
+	}
+
+	result := HealthCheckResult{
+		Check:     w.rule.Name,
+		Component: w.rule.Name,
+		Status:    status,
+		Timestamp: time.Now(),
+		Details:   map[string]string{"object": key, "condition": w.rule.Condition},
+	}
+	switch {
+	case status == "Healthy" && obj == nil:
+		result.Message = fmt.Sprintf("%s was deleted", key)
+	case status == "Healthy":
+		result.Message = fmt.Sprintf("%s no longer matches %s", key, w.rule.Name)
+	default:
+		var text bytes.Buffer
+		data := watchRuleMessage{Rule: w.rule.Name, Kind: obj.GetKind(), Namespace: obj.GetNamespace(), Name: obj.GetName(), Since: w.since[key], Object: obj.Object}
+		if err := w.rule.message.Execute(&text, data); err != nil {
+			result.Message = fmt.Sprintf("%s matched %s (message template failed: %v)", key, w.rule.Name, err)
+		} else {
+			result.Message = strings.TrimSpace(text.String())
+		}
+	}
+
+	if status == "Healthy" {
+		delete(w.status, key)
+		k.log().Infof("[%s] resolved: %s", w.rule.Name, result.Message)
+	} else {
+		w.status[key] = status
+		k.log().Warnf("[%s] %s: %s", w.rule.Name, status, result.Message)
+	}
+	notifier.Observe(ctx, key, w.rule.Name, result)
+}
+
+// RunWatchRules starts an informer per rule and evaluates the rules against
+// the cached objects every interval until ctx is done. Time-based conditions
+// such as an object's age change without watch events, so rules are
+// evaluated on a schedule rather than per event.
+func (k *K8sToolkit) RunWatchRules(ctx context.Context, rules []watchRule, interval, syncTimeout time.Duration, notifier *Notifier) error {
+	var watchers []*ruleWatcher
+	for i := range rules {
+		rule := &rules[i]
+		selector := rule.Selector
+		factory := dynamicinformer.NewFilteredDynamicSharedInformerFactory(k.dynamicClient, 0, rule.Namespace, func(opts *metav1.ListOptions) {
+			opts.LabelSelector = selector
+		})
+		informer := factory.ForResource(rule.gvr).Informer()
+		factory.Start(ctx.Done())
+		watchers = append(watchers, &ruleWatcher{rule: rule, informer: informer, since: make(map[string]time.Time), status: make(map[string]string)})
+	}
+
+	syncCtx, cancel := context.WithTimeout(ctx, syncTimeout)
+	defer cancel()
+	for _, w := range watchers {
+		if !cache.WaitForCacheSync(syncCtx.Done(), w.informer.HasSynced) {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("rule %s: %s did not sync within %s; check the resource name and RBAC", w.rule.Name, w.rule.Resource, syncTimeout)
+		}
+	}
+	k.log().Infof("Watching %d rules", len(watchers))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		for _, w := range watchers {
+			k.evaluateRule(ctx, w, notifier, now)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// createWatchRulesCmd creates the watch-rules command
+func createWatchRulesCmd() *cobra.Command {
+	var rulesFile string
+	var interval, syncTimeout time.Duration
+
+	watchRulesCmd := &cobra.Command{
+		Use:   "watch-rules",
+		Short: "Alert on CEL rules over arbitrary Kubernetes resources",
+		Long: `Runs as a daemon that watches the resources named by each rule with an informer and evaluates
+the rule's CEL condition against every object every --interval. The object is bound to
+"object" in its YAML form and the current time to "now". A rule fires once its condition has
+held for "for" and resolves when it stops holding or the object is deleted. Changes are logged
+and sent to notifications.targets, which route them by rule name like check names. Conditions
+that fail to evaluate, e.g. on a missing field, do not match; guard optional fields with has().
+
+Rules are read from watch_rules in the config file, or from rules in --rules-file:
+
+  rules:
+    - name: deployment-unavailable
+      resource: apps/v1/deployments
+      condition: "(has(object.status.availableReplicas) ? object.status.availableReplicas : 0) < object.spec.replicas"
+      for: 10m
+      severity: Critical
+      message: "{{.Namespace}}/{{.Name}} has been below its desired replicas since {{.Since.Format \"15:04\"}}"
+    - name: old-secret
+      resource: v1/secrets
+      namespace: payments
+      selector: rotation=manual
+      condition: "now - timestamp(object.metadata.creationTimestamp) > duration('2160h')"`,
+		Run: func(cmd *cobra.Command, args []string) {
+			rules, err := loadWatchRules(rulesFile)
+			if err != nil {
+				logger.Fatalf("Invalid watch rules: %v", err)
+			}
+			toolkit, err := NewK8sToolkit()
+			if err != nil {
+				logger.Fatalf("Failed to initialize toolkit: %v", err)
+			}
+			notifier, err := NewNotifier()
+			if err != nil {
+				logger.Fatalf("Failed to configure notifications: %v", err)
+			}
+			if notifier == nil {
+				logger.Warnf("no notifications.targets configured, rule changes are only logged")
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if err := toolkit.RunWatchRules(ctx, rules, interval, syncTimeout, notifier); err != nil {
+				logger.Fatalf("Watch rules failed: %v", err)
+			}
+		},
+	}
+
+	watchRulesCmd.Flags().StringVar(&rulesFile, "rules-file", "", "YAML file with the rules under rules (default: watch_rules in the config file)")
+	watchRulesCmd.Flags().DurationVar(&interval, "interval", 30*time.Second, "How often to evaluate the rules")
+	watchRulesCmd.Flags().DurationVar(&syncTimeout, "sync-timeout", 2*time.Minute, "Time to wait for the informers to load the watched resources")
+
+	return watchRulesCmd
+}
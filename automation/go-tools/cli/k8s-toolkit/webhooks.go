@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// maxWebhookTimeout is the longest admission webhook timeout considered
+// safe; the API server's own request budget leaves little room above it
+const maxWebhookTimeout = 10
+
+// admissionWebhook is the part of a validating or mutating webhook the check
+// inspects
+type admissionWebhook struct {
+	kind              string
+	config            string
+	name              string
+	clientConfig      admissionregistrationv1.WebhookClientConfig
+	failClosed        bool
+	timeoutSeconds    int32
+	namespaceSelector *metav1.LabelSelector
+	objectSelector    *metav1.LabelSelector
+	rules             []admissionregistrationv1.RuleWithOperations
+}
+
+func (w admissionWebhook) String() string {
+	return w.config + "/" + w.name
+}
+
+// listAdmissionWebhooks returns every validating and mutating webhook with
+// the v1 defaults applied: failurePolicy Fail and a 10s timeout
+func (k *K8sToolkit) listAdmissionWebhooks(ctx context.Context) ([]admissionWebhook, error) {
+	var webhooks []admissionWebhook
+	add := func(kind, config, name string, clientConfig admissionregistrationv1.WebhookClientConfig, policy *admissionregistrationv1.FailurePolicyType, timeout *int32, namespaceSelector, objectSelector *metav1.LabelSelector, rules []admissionregistrationv1.RuleWithOperations) {
+		w := admissionWebhook{
+			kind:              kind,
+			config:            config,
+			name:              name,
+			clientConfig:      clientConfig,
+			failClosed:        policy == nil || *policy == admissionregistrationv1.Fail,
+			timeoutSeconds:    maxWebhookTimeout,
+			namespaceSelector: namespaceSelector,
+			objectSelector:    objectSelector,
+			rules:             rules,
+		}
+		if timeout != nil {
+			w.timeoutSeconds = *timeout
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	validating, err := k.clientset.AdmissionregistrationV1().ValidatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list validating webhook configurations: %w", err)
+	}
+	for _, config := range validating.Items {
+		for _, w := range config.Webhooks {
+			add("ValidatingWebhookConfiguration", config.Name, w.Name, w.ClientConfig, w.FailurePolicy, w.TimeoutSeconds, w.NamespaceSelector, w.ObjectSelector, w.Rules)
+		}
+	}
+
+	mutating, err := k.clientset.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mutating webhook configurations: %w", err)
+	}
+	for _, config := range mutating.Items {
+		for _, w := range config.Webhooks {
+			add("MutatingWebhookConfiguration", config.Name, w.Name, w.ClientConfig, w.FailurePolicy, w.TimeoutSeconds, w.NamespaceSelector, w.ObjectSelector, w.Rules)
+		}
+	}
+	return webhooks, nil
+}
+
+// webhookBackendProblem checks that the Service behind a webhook exists,
+// exposes the port and has ready endpoints. URL webhooks are not probed.
+func (k *K8sToolkit) webhookBackendProblem(ctx context.Context, w admissionWebhook) (string, error) {
+	ref := w.clientConfig.Service
+	if ref == nil {
+		return "", nil
+	}
+	port := int32(443)
+	if ref.Port != nil {
+		port = *ref.Port
+	}
+
+	service, err := k.clientset.CoreV1().Services(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Sprintf("service %s/%s not found", ref.Namespace, ref.Name), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	exposed := false
+	for _, p := range service.Spec.Ports {
+		exposed = exposed || p.Port == port
+	}
+	if !exposed && len(service.Spec.Ports) > 0 {
+		return fmt.Sprintf("service %s/%s has no port %d", ref.Namespace, ref.Name, port), nil
+	}
+	if service.Spec.Type == corev1.ServiceTypeExternalName {
+		return "", nil
+	}
+
+	endpoints, err := k.clientset.CoreV1().Endpoints(ref.Namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return fmt.Sprintf("service %s/%s has no endpoints", ref.Namespace, ref.Name), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Addresses) > 0 {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("service %s/%s has no ready endpoints", ref.Namespace, ref.Name), nil
+}
+
+// webhookCAProblem checks the caBundle the API server verifies the webhook
+// with. An empty bundle falls back to the API server's system roots, which
+// in-cluster webhooks are rarely signed by.
+func webhookCAProblem(w admissionWebhook, now time.Time, warnWithin time.Duration) (problem string, broken bool) {
+	if len(w.clientConfig.CABundle) == 0 {
+		if w.clientConfig.Service != nil {
+			return "no caBundle", false
+		}
+		return "", false
+	}
+	certs := parseCertificates(w.clientConfig.CABundle)
+	if len(certs) == 0 {
+		return "caBundle contains no valid certificate", true
+	}
+	valid := 0
+	var earliest time.Time
+	for _, cert := range certs {
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			continue
+		}
+		valid++
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	switch {
+	case valid == 0:
+		return fmt.Sprintf("caBundle expired %s", certs[0].NotAfter.Format("2006-01-02")), true
+	case earliest.Before(now.Add(warnWithin)):
+		return fmt.Sprintf("caBundle expires %s", earliest.Format("2006-01-02")), false
+	}
+	return "", false
+}
+
+// interceptsKubeSystem reports whether a webhook receives requests for pods
+// in kube-system, so that it failing closed stops system components from
+// being rescheduled
+func interceptsKubeSystem(w admissionWebhook, kubeSystem *corev1.Namespace) bool {
+	if w.namespaceSelector != nil && kubeSystem != nil {
+		selector, err := metav1.LabelSelectorAsSelector(w.namespaceSelector)
+		if err != nil || !selector.Matches(labels.Set(kubeSystem.Labels)) {
+			return false
+		}
+	}
+	if w.objectSelector != nil && (len(w.objectSelector.MatchLabels) > 0 || len(w.objectSelector.MatchExpressions) > 0) {
+		return false
+	}
+	for _, rule := range w.rules {
+		for _, resource := range rule.Resources {
+			resource, _, _ = strings.Cut(resource, "/")
+			if resource == "*" || resource == "pods" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CheckAdmissionWebhooks looks for the admission webhook misconfigurations
+// behind "pods cannot be created" outages: a missing or unready backend
+// Service, an invalid or expired caBundle, fail-closed webhooks covering pods
+// in kube-system, and timeouts above 10s. A broken fail-closed webhook is
+// Critical, since every request it matches is rejected.
+func (k *K8sToolkit) CheckAdmissionWebhooks(ctx context.Context) HealthCheckResult {
+	result := HealthCheckResult{
+		Component: "Admission Webhooks",
+		Timestamp: time.Now(),
+		Details:   make(map[string]string),
+	}
+
+	webhooks, err := k.listAdmissionWebhooks(ctx)
+	if err != nil {
+		result.Status = "Warning"
+		result.Message = fmt.Sprintf("Failed to list admission webhooks: %v", err)
+		result.Err = err
+		return result
+	}
+	result.Details["webhooks"] = strconv.Itoa(len(webhooks))
+	if len(webhooks) == 0 {
+		result.Status = "Healthy"
+		result.Message = "No admission webhooks configured"
+		return result
+	}
+
+	kubeSystem, err := k.clientset.CoreV1().Namespaces().Get(ctx, "kube-system", metav1.GetOptions{})
+	if err != nil {
+		kubeSystem = nil
+	}
+
+	warnWithin := viper.GetDuration("credentials.warn_within")
+	var broken, degraded, kubeSystemRisk, slow []string
+	for _, w := range webhooks {
+		var problems []string
+		failing := false
+
+		problem, err := k.webhookBackendProblem(ctx, w)
+		if err != nil {
+			result.Status = "Warning"
+			result.Message = fmt.Sprintf("Failed to check the backend of webhook %s: %v", w, err)
+			result.Err = err
+			return result
+		}
+		if problem != "" {
+			problems = append(problems, problem)
+			failing = true
+		}
+		if problem, invalid := webhookCAProblem(w, result.Timestamp, warnWithin); problem != "" {
+			problems = append(problems, problem)
+			failing = failing || invalid
+		}
+
+		if len(problems) > 0 {
+			entry := fmt.Sprintf("%s (%s)", w, strings.Join(problems, ", "))
+			if failing && w.failClosed {
+				broken = append(broken, entry)
+				result.Affected = append(result.Affected, objectRef{Kind: w.kind, Name: w.config})
+			} else {
+				degraded = append(degraded, entry)
+			}
+		}
+		if w.failClosed && interceptsKubeSystem(w, kubeSystem) {
+			kubeSystemRisk = append(kubeSystemRisk, w.String())
+		}
+		if w.timeoutSeconds > maxWebhookTimeout {
+			slow = append(slow, fmt.Sprintf("%s (%ds)", w, w.timeoutSeconds))
+		}
+	}
+
+	if len(broken) > 0 {
+		result.Details["broken"] = strings.Join(broken, "; ")
+	}
+	if len(degraded) > 0 {
+		result.Details["degraded"] = strings.Join(degraded, "; ")
+	}
+	if len(kubeSystemRisk) > 0 {
+		result.Details["fail_closed_kube_system"] = strings.Join(kubeSystemRisk, ", ")
+	}
+	if len(slow) > 0 {
+		result.Details["slow_timeouts"] = strings.Join(slow, ", ")
+	}
+
+	switch {
+	case len(broken) > 0:
+		result.Status = "Critical"
+		result.Message = fmt.Sprintf("%d fail-closed webhooks are unreachable or untrusted and reject every matching request", len(broken))
+	case len(degraded)+len(kubeSystemRisk)+len(slow) > 0:
+		var parts []string
+		if len(degraded) > 0 {
+			parts = append(parts, fmt.Sprintf("%d with backend or certificate problems", len(degraded)))
+		}
+		if len(kubeSystemRisk) > 0 {
+			parts = append(parts, fmt.Sprintf("%d fail closed for kube-system pods", len(kubeSystemRisk)))
+		}
+		if len(slow) > 0 {
+			parts = append(parts, fmt.Sprintf("%d with timeouts over %ds", len(slow), maxWebhookTimeout))
+		}
+		result.Status = "Warning"
+		result.Message = "Admission webhooks at risk: " + strings.Join(parts, ", ")
+	default:
+		result.Status = "Healthy"
+		result.Message = fmt.Sprintf("All %d admission webhooks are reachable with valid certificates", len(webhooks))
+	}
+	return result
+}
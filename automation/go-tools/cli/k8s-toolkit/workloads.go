@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// workloadKey identifies a controller that owns pods
+type workloadKey struct {
+	namespace string
+	kind      string
+	name      string
+}
+
+func (w workloadKey) String() string {
+	return w.kind + "/" + w.name
+}
+
+// replicaSetOwners maps namespace/name of each ReplicaSet to its controller,
+// so pods can be attributed to their Deployment
+func (k *K8sToolkit) replicaSetOwners(ctx context.Context) (map[string]metav1.OwnerReference, error) {
+	replicaSets, err := k.clientset.AppsV1().ReplicaSets(k.namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+
+	owners := make(map[string]metav1.OwnerReference)
+	for _, rs := range replicaSets.Items {
+		if owner := metav1.GetControllerOf(&rs); owner != nil {
+			owners[rs.Namespace+"/"+rs.Name] = *owner
+		}
+	}
+	return owners, nil
+}
+
+// podWorkload returns the top-level controller of a pod, or the pod itself
+// when it has none
+func podWorkload(pod *corev1.Pod, rsOwners map[string]metav1.OwnerReference) workloadKey {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return workloadKey{namespace: pod.Namespace, kind: "Pod", name: pod.Name}
+	}
+
+	workload := workloadKey{namespace: pod.Namespace, kind: owner.Kind, name: owner.Name}
+	if owner.Kind == "ReplicaSet" {
+		if rsOwner, ok := rsOwners[pod.Namespace+"/"+owner.Name]; ok {
+			workload.kind = rsOwner.Kind
+			workload.name = rsOwner.Name
+		}
+	}
+	return workload
+}
+
+// workloadTemplate returns the pod template of a Deployment, StatefulSet or
+// ReplicaSet, or nil for kinds without one the toolkit knows how to read
+func (k *K8sToolkit) workloadTemplate(ctx context.Context, namespace, kind, name string) (*corev1.PodTemplateSpec, error) {
+	switch kind {
+	case "Deployment":
+		obj, err := k.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &obj.Spec.Template, nil
+	case "StatefulSet":
+		obj, err := k.clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &obj.Spec.Template, nil
+	case "ReplicaSet":
+		obj, err := k.clientset.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return &obj.Spec.Template, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// LoginOptions configures the browser login against the identity provider
+type LoginOptions struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	ListenAddr   string
+	Timeout      time.Duration
+	NoBrowser    bool
+}
+
+// randomURLToken returns a URL-safe random string of n bytes of entropy
+func randomURLToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// openBrowser opens url in the user's browser, best effort
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	default:
+		return exec.Command("xdg-open", url).Start()
+	}
+}
+
+// loginWithBrowser runs the OIDC authorization code flow with PKCE against a
+// loopback redirect (RFC 8252) and returns the verified raw ID token. The
+// identity provider must allow http://127.0.0.1/callback on any port, or the
+// port in ListenAddr, as a redirect URI for the client.
+func loginWithBrowser(ctx context.Context, opts LoginOptions) (string, error) {
+	provider, err := oidc.NewProvider(ctx, opts.Issuer)
+	if err != nil {
+		return "", fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", opts.ListenAddr)
+	if err != nil {
+		return "", fmt.Errorf("failed to listen for the login callback: %w", err)
+	}
+	defer listener.Close()
+
+	config := oauth2.Config{
+		ClientID:     opts.ClientID,
+		ClientSecret: opts.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  fmt.Sprintf("http://%s/callback", listener.Addr()),
+		Scopes:       []string{oidc.ScopeOpenID, "email", "groups"},
+	}
+
+	state, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomURLToken(32)
+	if err != nil {
+		return "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL := config.AuthCodeURL(state,
+		oidc.Nonce(nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	)
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		query := r.URL.Query()
+		var res result
+		switch {
+		case query.Get("state") != state:
+			res.err = errors.New("login callback has an unexpected state")
+		case query.Get("error") != "":
+			res.err = fmt.Errorf("identity provider refused the login: %s %s", query.Get("error"), query.Get("error_description"))
+		case query.Get("code") == "":
+			res.err = errors.New("login callback has no authorization code")
+		default:
+			res.code = query.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, html.EscapeString(res.err.Error()), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Login complete, you can close this window.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	fmt.Printf("Opening the browser to log in. If it does not open, visit:\n\n  %s\n\n", authURL)
+	if !opts.NoBrowser {
+		openBrowser(authURL)
+	}
+
+	var res result
+	select {
+	case res = <-results:
+	case <-time.After(opts.Timeout):
+		return "", fmt.Errorf("login not completed within %s", opts.Timeout)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if res.err != nil {
+		return "", res.err
+	}
+
+	token, err := config.Exchange(ctx, res.code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		return "", fmt.Errorf("failed to exchange the authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return "", errors.New("identity provider returned no ID token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: opts.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return "", fmt.Errorf("invalid ID token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return "", errors.New("ID token has an unexpected nonce")
+	}
+	return rawIDToken, nil
+}
@@ -0,0 +1,629 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/devops-excellence/automation/go-tools/pkg/secrets"
+	"github.com/gorilla/mux"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+)
+
+// PrincipalMapping maps IdP groups to the SSH principals their members may log in as
+type PrincipalMapping struct {
+	Groups map[string][]string `yaml:"groups"`
+}
+
+// HostTokens lists the provisioning tokens and the hostnames each may
+// request a host certificate for
+type HostTokens struct {
+	Tokens []HostToken `yaml:"tokens"`
+}
+
+// HostToken is one provisioning token. Token may be a secret reference such
+// as vault:secret/data/ssh-ca#web_token. Hostnames are patterns in which *
+// matches exactly one DNS label, so *.web.example.com does not match
+// a.b.web.example.com.
+type HostToken struct {
+	Name      string   `yaml:"name"`
+	Token     string   `yaml:"token"`
+	Hostnames []string `yaml:"hostnames"`
+}
+
+// CertRequest is the body of a certificate request
+type CertRequest struct {
+	PublicKey string   `json:"public_key"`
+	Hostnames []string `json:"hostnames,omitempty"`
+}
+
+// CertResponse carries a signed certificate in authorized_keys format
+type CertResponse struct {
+	Certificate string    `json:"certificate"`
+	ValidBefore time.Time `json:"valid_before"`
+}
+
+// AuditEntry records a single certificate issuance
+type AuditEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Type        string    `json:"type"`
+	KeyID       string    `json:"key_id"`
+	Principals  []string  `json:"principals"`
+	Serial      uint64    `json:"serial"`
+	Fingerprint string    `json:"fingerprint"`
+	ValidBefore time.Time `json:"valid_before"`
+	RemoteAddr  string    `json:"remote_addr"`
+	HostToken   string    `json:"host_token,omitempty"`
+}
+
+// CAServer issues short-lived SSH user and host certificates
+type CAServer struct {
+	signer     ssh.Signer
+	verifier   *oidc.IDTokenVerifier
+	mapping    PrincipalMapping
+	hostTokens []HostToken
+	userTTL    time.Duration
+	hostTTL    time.Duration
+
+	auditMu  sync.Mutex
+	auditLog *os.File
+}
+
+// ServerOptions configures a CAServer
+type ServerOptions struct {
+	CAKeyFile    string
+	Issuer       string
+	ClientID     string
+	MappingFile  string
+	HostTokens   string
+	AuditLogFile string
+	UserTTL      time.Duration
+	HostTTL      time.Duration
+}
+
+// NewCAServer loads the CA key, principal mapping and OIDC provider
+func NewCAServer(ctx context.Context, opts ServerOptions) (*CAServer, error) {
+	keyData, err := os.ReadFile(opts.CAKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+
+	mappingData, err := os.ReadFile(opts.MappingFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read principal mapping: %w", err)
+	}
+	var mapping PrincipalMapping
+	if err := yaml.Unmarshal(mappingData, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse principal mapping: %w", err)
+	}
+
+	provider, err := oidc.NewProvider(ctx, opts.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+
+	hostTokens, err := loadHostTokens(ctx, opts.HostTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLog, err := os.OpenFile(opts.AuditLogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	return &CAServer{
+		signer:     signer,
+		verifier:   provider.Verifier(&oidc.Config{ClientID: opts.ClientID}),
+		mapping:    mapping,
+		hostTokens: hostTokens,
+		userTTL:    opts.UserTTL,
+		hostTTL:    opts.HostTTL,
+		auditLog:   auditLog,
+	}, nil
+}
+
+// loadHostTokens reads the provisioning tokens and resolves their secret
+// references. Without a file no host certificates are issued.
+func loadHostTokens(ctx context.Context, file string) ([]HostToken, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read host tokens: %w", err)
+	}
+	var config HostTokens
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse host tokens: %w", err)
+	}
+
+	resolver := secrets.NewDefaultResolver()
+	for i := range config.Tokens {
+		t := &config.Tokens[i]
+		if t.Name == "" {
+			t.Name = fmt.Sprintf("tokens[%d]", i)
+		}
+		if len(t.Hostnames) == 0 {
+			return nil, fmt.Errorf("host token %s allows no hostnames", t.Name)
+		}
+		if t.Token, err = resolver.Resolve(ctx, t.Token); err != nil {
+			return nil, fmt.Errorf("failed to resolve host token %s: %w", t.Name, err)
+		}
+		if len(t.Token) < 16 {
+			return nil, fmt.Errorf("host token %s is shorter than 16 characters", t.Name)
+		}
+	}
+	return config.Tokens, nil
+}
+
+// matchHostname reports whether hostname matches pattern label by label
+func matchHostname(pattern, hostname string) bool {
+	patternLabels := strings.Split(strings.ToLower(pattern), ".")
+	hostLabels := strings.Split(strings.ToLower(hostname), ".")
+	if len(patternLabels) != len(hostLabels) {
+		return false
+	}
+	for i, label := range patternLabels {
+		if ok, err := path.Match(label, hostLabels[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// allows reports whether the token may request a certificate for hostname
+func (t HostToken) allows(hostname string) bool {
+	for _, pattern := range t.Hostnames {
+		if matchHostname(pattern, hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostTokenFor returns the provisioning token matching a bearer token,
+// comparing against every configured token in constant time
+func (s *CAServer) hostTokenFor(token string) (HostToken, bool) {
+	var match HostToken
+	found := false
+	for _, t := range s.hostTokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t.Token)) == 1 {
+			match, found = t, true
+		}
+	}
+	return match, found
+}
+
+// resolveHostToken reads the provisioning token from SSH_CA_HOST_TOKEN,
+// which may hold a secret reference such as vault:secret/data/ssh-ca#host_token
+func resolveHostToken(ctx context.Context) (string, error) {
+	token, err := secrets.NewDefaultResolver().Resolve(ctx, os.Getenv("SSH_CA_HOST_TOKEN"))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve SSH_CA_HOST_TOKEN: %w", err)
+	}
+	return token, nil
+}
+
+// Router returns the HTTP routes served by the CA
+func (s *CAServer) Router() http.Handler {
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/ca.pub", s.handleCAPublicKey).Methods(http.MethodGet)
+	r.HandleFunc("/v1/user-cert", s.handleUserCert).Methods(http.MethodPost)
+	r.HandleFunc("/v1/host-cert", s.handleHostCert).Methods(http.MethodPost)
+	return r
+}
+
+func (s *CAServer) handleCAPublicKey(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write(ssh.MarshalAuthorizedKey(s.signer.PublicKey()))
+}
+
+func (s *CAServer) handleUserCert(w http.ResponseWriter, r *http.Request) {
+	rawToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	idToken, err := s.verifier.Verify(r.Context(), rawToken)
+	if err != nil {
+		http.Error(w, "invalid identity token", http.StatusUnauthorized)
+		return
+	}
+
+	var claims struct {
+		Email  string   `json:"email"`
+		Groups []string `json:"groups"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		http.Error(w, "invalid identity token claims", http.StatusUnauthorized)
+		return
+	}
+
+	principals := s.principalsFor(claims.Groups)
+	if len(principals) == 0 {
+		http.Error(w, "no principals mapped for your groups", http.StatusForbidden)
+		return
+	}
+
+	_, pubKey, ok := decodeCertRequest(w, r)
+	if !ok {
+		return
+	}
+
+	keyID := claims.Email
+	if keyID == "" {
+		keyID = idToken.Subject
+	}
+
+	cert, err := s.sign(pubKey, ssh.UserCert, keyID, principals, s.userTTL, r.RemoteAddr, "")
+	if err != nil {
+		log.Printf("Failed to sign user certificate for %s: %v", keyID, err)
+		http.Error(w, "failed to sign certificate", http.StatusInternalServerError)
+		return
+	}
+	writeCert(w, cert)
+}
+
+func (s *CAServer) handleHostCert(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	hostToken, ok := s.hostTokenFor(token)
+	if token == "" || !ok {
+		http.Error(w, "invalid provisioning token", http.StatusUnauthorized)
+		return
+	}
+
+	req, pubKey, ok := decodeCertRequest(w, r)
+	if !ok {
+		return
+	}
+	if len(req.Hostnames) == 0 {
+		http.Error(w, "hostnames are required", http.StatusBadRequest)
+		return
+	}
+	for _, hostname := range req.Hostnames {
+		if !hostToken.allows(hostname) {
+			log.Printf("Host token %s refused for hostname %s from %s", hostToken.Name, hostname, r.RemoteAddr)
+			http.Error(w, fmt.Sprintf("provisioning token is not allowed to request %s", hostname), http.StatusForbidden)
+			return
+		}
+	}
+
+	cert, err := s.sign(pubKey, ssh.HostCert, req.Hostnames[0], req.Hostnames, s.hostTTL, r.RemoteAddr, hostToken.Name)
+	if err != nil {
+		log.Printf("Failed to sign host certificate for %s: %v", req.Hostnames[0], err)
+		http.Error(w, "failed to sign certificate", http.StatusInternalServerError)
+		return
+	}
+	writeCert(w, cert)
+}
+
+// principalsFor returns the de-duplicated principals granted to a set of groups
+func (s *CAServer) principalsFor(groups []string) []string {
+	seen := make(map[string]bool)
+	var principals []string
+	for _, group := range groups {
+		for _, principal := range s.mapping.Groups[group] {
+			if !seen[principal] {
+				seen[principal] = true
+				principals = append(principals, principal)
+			}
+		}
+	}
+	return principals
+}
+
+// sign issues a certificate and records it in the audit log together with
+// the provisioning token used for host certificates
+func (s *CAServer) sign(pubKey ssh.PublicKey, certType uint32, keyID string, principals []string, ttl time.Duration, remoteAddr, hostToken string) (*ssh.Certificate, error) {
+	serialBytes := make([]byte, 8)
+	if _, err := rand.Read(serialBytes); err != nil {
+		return nil, err
+	}
+	var serial uint64
+	for _, b := range serialBytes {
+		serial = serial<<8 | uint64(b)
+	}
+
+	now := time.Now()
+	cert := &ssh.Certificate{
+		Key:             pubKey,
+		Serial:          serial,
+		CertType:        certType,
+		KeyId:           keyID,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(now.Add(-5 * time.Minute).Unix()),
+		ValidBefore:     uint64(now.Add(ttl).Unix()),
+	}
+	if certType == ssh.UserCert {
+		cert.Permissions.Extensions = map[string]string{
+			"permit-pty":              "",
+			"permit-port-forwarding":  "",
+			"permit-agent-forwarding": "",
+		}
+	}
+
+	if err := cert.SignCert(rand.Reader, s.signer); err != nil {
+		return nil, err
+	}
+
+	certTypeName := "user"
+	if certType == ssh.HostCert {
+		certTypeName = "host"
+	}
+	s.audit(AuditEntry{
+		Timestamp:   now,
+		Type:        certTypeName,
+		KeyID:       keyID,
+		Principals:  principals,
+		Serial:      serial,
+		Fingerprint: ssh.FingerprintSHA256(pubKey),
+		ValidBefore: now.Add(ttl),
+		RemoteAddr:  remoteAddr,
+		HostToken:   hostToken,
+	})
+
+	return cert, nil
+}
+
+// audit appends an issuance record to the audit log as a JSON line
+func (s *CAServer) audit(entry AuditEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("Error marshaling audit entry: %v", err)
+		return
+	}
+
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	if _, err := s.auditLog.Write(append(data, '\n')); err != nil {
+		log.Printf("Error writing audit entry: %v", err)
+	}
+}
+
+// decodeCertRequest parses the request body and the public key it carries
+func decodeCertRequest(w http.ResponseWriter, r *http.Request) (*CertRequest, ssh.PublicKey, bool) {
+	var req CertRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, 64*1024)).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	pubKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(req.PublicKey))
+	if err != nil {
+		http.Error(w, "invalid public key", http.StatusBadRequest)
+		return nil, nil, false
+	}
+
+	return &req, pubKey, true
+}
+
+func writeCert(w http.ResponseWriter, cert *ssh.Certificate) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CertResponse{
+		Certificate: string(ssh.MarshalAuthorizedKey(cert)),
+		ValidBefore: time.Unix(int64(cert.ValidBefore), 0),
+	})
+}
+
+// requestCert posts a public key to the CA and returns the signed certificate
+func requestCert(server, path, token string, req CertRequest) (*CertResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, strings.TrimRight(server, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+token)
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to contact CA: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("CA returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var certResp CertResponse
+	if err := json.NewDecoder(resp.Body).Decode(&certResp); err != nil {
+		return nil, fmt.Errorf("failed to decode CA response: %w", err)
+	}
+	return &certResp, nil
+}
+
+// certPathFor returns the OpenSSH certificate path for a public key path
+func certPathFor(pubKeyPath string) string {
+	return strings.TrimSuffix(pubKeyPath, ".pub") + "-cert.pub"
+}
+
+// createServeCmd creates the serve command
+func createServeCmd() *cobra.Command {
+	var opts ServerOptions
+	var listen, tlsCert, tlsKey string
+
+	serveCmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Run the SSH certificate authority",
+		Long: `Issues short-lived SSH user certificates to OIDC-authenticated users and host certificates to hosts
+presenting a provisioning token from --host-tokens. Each token may only request the hostnames it
+lists, so an enrolled host cannot obtain a certificate for any other host:
+
+  tokens:
+    - name: web
+      token: vault:secret/data/ssh-ca#web_token
+      hostnames: ["*.web.example.com"]
+
+Without --host-tokens no host certificates are issued.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			server, err := NewCAServer(context.Background(), opts)
+			if err != nil {
+				log.Fatalf("Failed to initialize CA: %v", err)
+			}
+
+			log.Printf("SSH CA listening on %s", listen)
+			if err := http.ListenAndServeTLS(listen, tlsCert, tlsKey, server.Router()); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+
+	serveCmd.Flags().StringVar(&listen, "listen", ":8443", "Address to listen on")
+	serveCmd.Flags().StringVar(&tlsCert, "tls-cert", "tls.crt", "TLS certificate file")
+	serveCmd.Flags().StringVar(&tlsKey, "tls-key", "tls.key", "TLS private key file")
+	serveCmd.Flags().StringVar(&opts.CAKeyFile, "ca-key", "ca_key", "SSH CA private key file")
+	serveCmd.Flags().StringVar(&opts.Issuer, "issuer", "", "OIDC issuer URL")
+	serveCmd.Flags().StringVar(&opts.ClientID, "client-id", "ssh-ca", "OIDC client ID expected in identity tokens")
+	serveCmd.Flags().StringVar(&opts.MappingFile, "principals", "principals.yaml", "Group to principal mapping file")
+	serveCmd.Flags().StringVar(&opts.HostTokens, "host-tokens", "", "Provisioning tokens and the hostnames each may request")
+	serveCmd.Flags().StringVar(&opts.AuditLogFile, "audit-log", "ssh-ca-audit.log", "Issuance audit log file")
+	serveCmd.Flags().DurationVar(&opts.UserTTL, "user-ttl", 8*time.Hour, "Validity of user certificates")
+	serveCmd.Flags().DurationVar(&opts.HostTTL, "host-ttl", 30*24*time.Hour, "Validity of host certificates")
+	serveCmd.MarkFlagRequired("issuer")
+
+	return serveCmd
+}
+
+// createLoginCmd creates the login command that fetches and installs a user certificate
+func createLoginCmd() *cobra.Command {
+	var server, idToken, pubKeyPath string
+	opts := LoginOptions{}
+
+	loginCmd := &cobra.Command{
+		Use:   "login",
+		Short: "Fetch a user certificate for your SSH key",
+		Long: `Logs in to the identity provider in the browser with the authorization code flow and PKCE,
+exchanges the resulting identity token for a short-lived certificate and installs it next to your
+public key where ssh picks it up automatically. The client must allow the loopback redirect
+http://127.0.0.1:<port>/callback. Automation that already holds an identity token can pass it with
+--id-token or SSH_CA_ID_TOKEN instead.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if idToken == "" {
+				idToken = os.Getenv("SSH_CA_ID_TOKEN")
+			}
+			if idToken == "" {
+				if opts.Issuer == "" {
+					log.Fatalf("--issuer is required to log in")
+				}
+				token, err := loginWithBrowser(context.Background(), opts)
+				if err != nil {
+					log.Fatalf("Login failed: %v", err)
+				}
+				idToken = token
+			}
+
+			pubKey, err := os.ReadFile(pubKeyPath)
+			if err != nil {
+				log.Fatalf("Failed to read public key: %v", err)
+			}
+
+			resp, err := requestCert(server, "/v1/user-cert", idToken, CertRequest{PublicKey: string(pubKey)})
+			if err != nil {
+				log.Fatalf("Failed to obtain certificate: %v", err)
+			}
+
+			certPath := certPathFor(pubKeyPath)
+			if err := os.WriteFile(certPath, []byte(resp.Certificate), 0644); err != nil {
+				log.Fatalf("Failed to write certificate: %v", err)
+			}
+			fmt.Printf("Certificate written to %s (valid until %s)\n", certPath, resp.ValidBefore.Format(time.RFC3339))
+		},
+	}
+
+	home, _ := os.UserHomeDir()
+	loginCmd.Flags().StringVar(&server, "server", "", "SSH CA URL")
+	loginCmd.Flags().StringVar(&idToken, "id-token", "", "OIDC identity token to use instead of logging in")
+	loginCmd.Flags().StringVar(&opts.Issuer, "issuer", os.Getenv("SSH_CA_ISSUER"), "OIDC issuer URL (default SSH_CA_ISSUER)")
+	loginCmd.Flags().StringVar(&opts.ClientID, "client-id", "ssh-ca", "OIDC client ID, the same as the server's")
+	loginCmd.Flags().StringVar(&opts.ClientSecret, "client-secret", "", "OIDC client secret, for providers that do not support public clients")
+	loginCmd.Flags().StringVar(&opts.ListenAddr, "listen", "127.0.0.1:0", "Loopback address for the login callback; set a port if the provider needs a fixed redirect URI")
+	loginCmd.Flags().DurationVar(&opts.Timeout, "timeout", 5*time.Minute, "Time to complete the login in the browser")
+	loginCmd.Flags().BoolVar(&opts.NoBrowser, "no-browser", false, "Only print the login URL instead of opening the browser")
+	loginCmd.Flags().StringVar(&pubKeyPath, "key", filepath.Join(home, ".ssh", "id_ed25519.pub"), "Public key to certify")
+	loginCmd.MarkFlagRequired("server")
+
+	return loginCmd
+}
+
+// createHostCmd creates the host command used at provisioning time
+func createHostCmd() *cobra.Command {
+	var server, pubKeyPath string
+	var hostnames []string
+
+	hostCmd := &cobra.Command{
+		Use:   "host",
+		Short: "Fetch a host certificate during provisioning",
+		Long:  `Requests a host certificate for the given hostnames using the provisioning token in SSH_CA_HOST_TOKEN and installs it next to the host key.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			pubKey, err := os.ReadFile(pubKeyPath)
+			if err != nil {
+				log.Fatalf("Failed to read host key: %v", err)
+			}
+
+			token, err := resolveHostToken(context.Background())
+			if err != nil {
+				log.Fatalf("Failed to read provisioning token: %v", err)
+			}
+
+			resp, err := requestCert(server, "/v1/host-cert", token, CertRequest{
+				PublicKey: string(pubKey),
+				Hostnames: hostnames,
+			})
+			if err != nil {
+				log.Fatalf("Failed to obtain certificate: %v", err)
+			}
+
+			certPath := certPathFor(pubKeyPath)
+			if err := os.WriteFile(certPath, []byte(resp.Certificate), 0644); err != nil {
+				log.Fatalf("Failed to write certificate: %v", err)
+			}
+			fmt.Printf("Host certificate written to %s; add `HostCertificate %s` to sshd_config\n", certPath, certPath)
+		},
+	}
+
+	hostCmd.Flags().StringVar(&server, "server", "", "SSH CA URL")
+	hostCmd.Flags().StringVar(&pubKeyPath, "key", "/etc/ssh/ssh_host_ed25519_key.pub", "Host public key to certify")
+	hostCmd.Flags().StringSliceVar(&hostnames, "hostname", nil, "Hostnames to include as principals")
+	hostCmd.MarkFlagRequired("server")
+	hostCmd.MarkFlagRequired("hostname")
+
+	return hostCmd
+}
+
+func main() {
+	rootCmd := &cobra.Command{
+		Use:   "ssh-ca",
+		Short: "Short-lived SSH certificate authority",
+		Long:  `Issues short-lived SSH user and host certificates so long-lived SSH keys are no longer distributed across the fleet.`,
+	}
+
+	rootCmd.AddCommand(createServeCmd())
+	rootCmd.AddCommand(createLoginCmd())
+	rootCmd.AddCommand(createHostCmd())
+
+	if err := rootCmd.Execute(); err != nil {
+		log.Fatal(err)
+	}
+}
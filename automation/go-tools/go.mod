@@ -9,8 +9,17 @@ require (
 	k8s.io/apimachinery v0.27.4
 	k8s.io/client-go v0.27.4
 	k8s.io/kubectl v0.27.4
+	k8s.io/metrics v0.27.4
 	github.com/prometheus/client_golang v1.16.0
+	github.com/prometheus/client_model v0.4.0
+	github.com/prometheus/common v0.44.0
 	github.com/gorilla/mux v1.8.0
+	github.com/robfig/cron/v3 v3.0.1
+	github.com/google/cel-go v0.16.0
+	github.com/coreos/go-oidc/v3 v3.6.0
+	golang.org/x/oauth2 v0.10.0
+	github.com/lib/pq v1.10.9
+	golang.org/x/crypto v0.11.0
 	github.com/sirupsen/logrus v1.9.3
 	github.com/stretchr/testify v1.8.4
 	gopkg.in/yaml.v3 v3.0.1
@@ -24,6 +33,14 @@ require (
 	github.com/containerd/containerd v1.7.3
 	github.com/operator-framework/operator-sdk v1.31.0
 	sigs.k8s.io/controller-runtime v0.15.0
+	github.com/charmbracelet/bubbletea v0.24.2
+	modernc.org/sqlite v1.23.1
+	go.opentelemetry.io/otel v1.16.0
+	go.opentelemetry.io/otel/trace v1.16.0
+	go.opentelemetry.io/otel/sdk v1.16.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc v1.16.0
+	go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp v0.42.0
+	google.golang.org/grpc v1.56.2
 )
 
 require (
@@ -39,8 +56,6 @@ require (
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/pelletier/go-toml/v2 v2.0.8 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	github.com/prometheus/client_model v0.4.0 // indirect
-	github.com/prometheus/common v0.44.0 // indirect
 	github.com/prometheus/procfs v0.11.1 // indirect
 	github.com/spf13/afero v1.9.5 // indirect
 	github.com/spf13/cast v1.5.1 // indirect
@@ -48,7 +63,6 @@ require (
 	github.com/spf13/pflag v1.0.5 // indirect
 	github.com/subosito/gotenv v1.4.2 // indirect
 	golang.org/x/net v0.12.0 // indirect
-	golang.org/x/oauth2 v0.10.0 // indirect
 	golang.org/x/sys v0.10.0 // indirect
 	golang.org/x/text v0.11.0 // indirect
 	golang.org/x/time v0.3.0 // indirect
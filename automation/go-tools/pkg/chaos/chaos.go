@@ -0,0 +1,109 @@
+// Package chaos injects artificial failures (latency, crashes, dropped
+// deliveries) for resilience testing in staging. Injection is compiled in only
+// with the "chaos" build tag; in normal builds New returns nil and every
+// method on a nil Injector is a no-op.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fault names understood by the packages that consult an Injector
+const (
+	FaultStoreLatency  = "store_latency"
+	FaultExecutorCrash = "executor_crash"
+	FaultWebhookDrop   = "webhook_drop"
+)
+
+// ConfigEnv names the environment variable holding the chaos config path
+const ConfigEnv = "CHAOS_CONFIG"
+
+// Fault configures one kind of injected failure
+type Fault struct {
+	// Probability is the chance in [0, 1] that the fault fires on each call
+	Probability float64 `yaml:"probability"`
+	// Latency is the delay added when a latency fault fires
+	Latency time.Duration `yaml:"latency"`
+}
+
+// Config maps fault names to their settings
+type Config struct {
+	Seed   int64            `yaml:"seed"`
+	Faults map[string]Fault `yaml:"faults"`
+}
+
+// Injector decides when faults fire
+type Injector struct {
+	faults map[string]Fault
+	mu     sync.Mutex
+	rand   *rand.Rand
+}
+
+// New returns an injector for cfg, or nil when the binary was built without
+// the chaos tag
+func New(cfg Config) *Injector {
+	if !Enabled {
+		return nil
+	}
+
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{faults: cfg.Faults, rand: rand.New(rand.NewSource(seed))}
+}
+
+// FromEnv loads the config named by CHAOS_CONFIG. It returns nil when the
+// variable is unset or the binary was built without the chaos tag.
+func FromEnv() (*Injector, error) {
+	path := os.Getenv(ConfigEnv)
+	if !Enabled || path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chaos config: %w", err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse chaos config: %w", err)
+	}
+	return New(cfg), nil
+}
+
+// Fire reports whether the named fault fires on this call
+func (i *Injector) Fire(name string) bool {
+	if i == nil {
+		return false
+	}
+	fault, ok := i.faults[name]
+	if !ok || fault.Probability <= 0 {
+		return false
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rand.Float64() < fault.Probability
+}
+
+// Delay sleeps for the fault's latency when it fires, returning early if ctx ends
+func (i *Injector) Delay(ctx context.Context, name string) error {
+	if !i.Fire(name) {
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(i.faults[name].Latency):
+		return nil
+	}
+}
@@ -0,0 +1,263 @@
+// Package freeze reads deploy freeze calendars so every tool that changes
+// production agrees on when changes are frozen. A calendar is a YAML file or
+// an HTTP endpoint returning the same document as JSON. Windows are either
+// one-off (start and end) or weekly (days and a time of day range in a time
+// zone), and may be limited to scopes such as clusters or environments.
+package freeze
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Window modes
+const (
+	// ModeBlock refuses changes during the window unless overridden
+	ModeBlock = "block"
+	// ModeWarn only warns about changes during the window
+	ModeWarn = "warn"
+)
+
+// Weekly is a window recurring on the given days. A To earlier than From
+// spans midnight, e.g. Friday 18:00 to 06:00 ends on Saturday morning.
+type Weekly struct {
+	Days     []string `yaml:"days" json:"days"`
+	From     string   `yaml:"from" json:"from"`
+	To       string   `yaml:"to" json:"to"`
+	Timezone string   `yaml:"timezone" json:"timezone,omitempty"`
+}
+
+// Window is one freeze period
+type Window struct {
+	Name   string    `yaml:"name" json:"name"`
+	Reason string    `yaml:"reason" json:"reason,omitempty"`
+	Mode   string    `yaml:"mode" json:"mode,omitempty"`
+	Scopes []string  `yaml:"scopes" json:"scopes,omitempty"`
+	Start  time.Time `yaml:"start" json:"start,omitempty"`
+	End    time.Time `yaml:"end" json:"end,omitempty"`
+	Weekly *Weekly   `yaml:"weekly" json:"weekly,omitempty"`
+}
+
+// Calendar is a set of freeze windows
+type Calendar struct {
+	Windows []Window `yaml:"windows" json:"windows"`
+}
+
+// Active is a window in effect at a given time and when it ends
+type Active struct {
+	Window
+	Until time.Time `json:"until"`
+}
+
+// Blocking reports whether the window refuses changes
+func (w Window) Blocking() bool {
+	return w.Mode == "" || w.Mode == ModeBlock
+}
+
+// appliesTo reports whether the window covers scope; a window without scopes
+// covers everything, and an empty scope matches every window
+func (w Window) appliesTo(scope string) bool {
+	if len(w.Scopes) == 0 || scope == "" {
+		return true
+	}
+	for _, s := range w.Scopes {
+		if s == scope || s == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// parseClock parses HH:MM into minutes after midnight
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// parseDay parses a weekday name or its three-letter abbreviation
+func parseDay(value string) (time.Weekday, error) {
+	value = strings.ToLower(value)
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if value == name || value == name[:3] {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", value)
+}
+
+// Validate checks that every window is either one-off or weekly and well formed
+func (c *Calendar) Validate() error {
+	for i, w := range c.Windows {
+		if w.Mode != "" && w.Mode != ModeBlock && w.Mode != ModeWarn {
+			return fmt.Errorf("window %d (%s): unknown mode %q", i, w.Name, w.Mode)
+		}
+		if w.Weekly == nil {
+			if w.Start.IsZero() || !w.End.After(w.Start) {
+				return fmt.Errorf("window %d (%s): needs a start before its end, or weekly", i, w.Name)
+			}
+			continue
+		}
+		if _, err := time.LoadLocation(w.Weekly.Timezone); err != nil {
+			return fmt.Errorf("window %d (%s): %w", i, w.Name, err)
+		}
+		if len(w.Weekly.Days) == 0 {
+			return fmt.Errorf("window %d (%s): weekly window without days", i, w.Name)
+		}
+		for _, day := range w.Weekly.Days {
+			if _, err := parseDay(day); err != nil {
+				return fmt.Errorf("window %d (%s): %w", i, w.Name, err)
+			}
+		}
+		for _, clock := range []string{w.Weekly.From, w.Weekly.To} {
+			if _, err := parseClock(clock); err != nil {
+				return fmt.Errorf("window %d (%s): %w", i, w.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// weeklyUntil returns the end of the weekly occurrence covering now, or false
+// when now is outside every occurrence. The calendar must be valid.
+func weeklyUntil(weekly *Weekly, now time.Time) (time.Time, bool) {
+	location, _ := time.LoadLocation(weekly.Timezone)
+	local := now.In(location)
+	from, _ := parseClock(weekly.From)
+	to, _ := parseClock(weekly.To)
+	minute := local.Hour()*60 + local.Minute()
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+
+	on := func(day time.Weekday) bool {
+		for _, d := range weekly.Days {
+			if parsed, _ := parseDay(d); parsed == day {
+				return true
+			}
+		}
+		return false
+	}
+	yesterday := (local.Weekday() + 6) % 7
+
+	switch {
+	case from < to:
+		if on(local.Weekday()) && minute >= from && minute < to {
+			return midnight.Add(time.Duration(to) * time.Minute), true
+		}
+	case on(local.Weekday()) && minute >= from:
+		return midnight.AddDate(0, 0, 1).Add(time.Duration(to) * time.Minute), true
+	case on(yesterday) && minute < to:
+		return midnight.Add(time.Duration(to) * time.Minute), true
+	}
+	return time.Time{}, false
+}
+
+// ActiveAt returns the windows covering scope at now, blocking windows first
+func (c *Calendar) ActiveAt(now time.Time, scope string) []Active {
+	var active []Active
+	for _, w := range c.Windows {
+		if !w.appliesTo(scope) {
+			continue
+		}
+		if w.Weekly != nil {
+			if until, ok := weeklyUntil(w.Weekly, now); ok {
+				active = append(active, Active{Window: w, Until: until})
+			}
+			continue
+		}
+		if !now.Before(w.Start) && now.Before(w.End) {
+			active = append(active, Active{Window: w, Until: w.End})
+		}
+	}
+	sort.SliceStable(active, func(i, j int) bool {
+		return active[i].Blocking() && !active[j].Blocking()
+	})
+	return active
+}
+
+// Source provides a calendar
+type Source interface {
+	Calendar(ctx context.Context) (*Calendar, error)
+}
+
+// FileSource reads a YAML calendar from a file
+type FileSource struct {
+	Path string
+}
+
+// Calendar reads and validates the file
+func (s FileSource) Calendar(ctx context.Context) (*Calendar, error) {
+	data, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read freeze calendar: %w", err)
+	}
+	var calendar Calendar
+	if err := yaml.Unmarshal(data, &calendar); err != nil {
+		return nil, fmt.Errorf("failed to parse freeze calendar %s: %w", s.Path, err)
+	}
+	if err := calendar.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid freeze calendar %s: %w", s.Path, err)
+	}
+	return &calendar, nil
+}
+
+// HTTPSource fetches a JSON calendar from a URL, sending Token as a bearer
+// token when set
+type HTTPSource struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// Calendar fetches and validates the calendar
+func (s HTTPSource) Calendar(ctx context.Context) (*Calendar, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch freeze calendar: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("freeze calendar %s returned %s: %s", s.URL, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var calendar Calendar
+	if err := json.NewDecoder(resp.Body).Decode(&calendar); err != nil {
+		return nil, fmt.Errorf("failed to decode freeze calendar: %w", err)
+	}
+	if err := calendar.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid freeze calendar %s: %w", s.URL, err)
+	}
+	return &calendar, nil
+}
+
+// NewSource returns an HTTPSource for http(s) URLs and a FileSource otherwise
+func NewSource(location, token string) Source {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return HTTPSource{URL: location, Token: token}
+	}
+	return FileSource{Path: location}
+}
@@ -0,0 +1,86 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/devops-excellence/automation/go-tools/pkg/chaos"
+)
+
+// WithChaos wraps store so every call may be delayed by the store_latency
+// fault. It returns store unchanged when injector is nil.
+func WithChaos(store Store, injector *chaos.Injector) Store {
+	if injector == nil {
+		return store
+	}
+	return &chaosStore{store: store, injector: injector}
+}
+
+// ChaosHandler wraps handler so it may panic before running, simulating an
+// executor crash mid-job. It returns handler unchanged when injector is nil.
+func ChaosHandler(handler Handler, injector *chaos.Injector) Handler {
+	if injector == nil {
+		return handler
+	}
+	return func(ctx context.Context, job *Job) error {
+		if injector.Fire(chaos.FaultExecutorCrash) {
+			panic(fmt.Sprintf("chaos: injected executor crash for job %d", job.ID))
+		}
+		return handler(ctx, job)
+	}
+}
+
+type chaosStore struct {
+	store    Store
+	injector *chaos.Injector
+}
+
+func (s *chaosStore) Enqueue(ctx context.Context, job *Job) error {
+	if err := s.injector.Delay(ctx, chaos.FaultStoreLatency); err != nil {
+		return err
+	}
+	return s.store.Enqueue(ctx, job)
+}
+
+func (s *chaosStore) Dequeue(ctx context.Context, types []string) (*Job, error) {
+	if err := s.injector.Delay(ctx, chaos.FaultStoreLatency); err != nil {
+		return nil, err
+	}
+	return s.store.Dequeue(ctx, types)
+}
+
+func (s *chaosStore) Heartbeat(ctx context.Context, job *Job) error {
+	if err := s.injector.Delay(ctx, chaos.FaultStoreLatency); err != nil {
+		return err
+	}
+	return s.store.Heartbeat(ctx, job)
+}
+
+func (s *chaosStore) Complete(ctx context.Context, job *Job) error {
+	if err := s.injector.Delay(ctx, chaos.FaultStoreLatency); err != nil {
+		return err
+	}
+	return s.store.Complete(ctx, job)
+}
+
+func (s *chaosStore) Fail(ctx context.Context, job *Job, errMsg string, retryAt time.Time) error {
+	if err := s.injector.Delay(ctx, chaos.FaultStoreLatency); err != nil {
+		return err
+	}
+	return s.store.Fail(ctx, job, errMsg, retryAt)
+}
+
+func (s *chaosStore) DeadLetter(ctx context.Context, job *Job, errMsg string) error {
+	if err := s.injector.Delay(ctx, chaos.FaultStoreLatency); err != nil {
+		return err
+	}
+	return s.store.DeadLetter(ctx, job, errMsg)
+}
+
+func (s *chaosStore) ClaimSchedule(ctx context.Context, name string, interval time.Duration) (bool, error) {
+	if err := s.injector.Delay(ctx, chaos.FaultStoreLatency); err != nil {
+		return false, err
+	}
+	return s.store.ClaimSchedule(ctx, name, interval)
+}
@@ -0,0 +1,336 @@
+// Package jobs provides durable background jobs with retries, dead-lettering,
+// recurring schedules and worker registration, so background work survives
+// process restarts.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// State is the lifecycle state of a job
+type State string
+
+const (
+	StatePending    State = "pending"
+	StateRunning    State = "running"
+	StateSucceeded  State = "succeeded"
+	StateDeadLetter State = "dead_letter"
+)
+
+// Job is a unit of background work
+type Job struct {
+	ID          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	State       State           `json:"state"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// Schedule is a recurring job definition
+type Schedule struct {
+	Name     string          `json:"name"`
+	Type     string          `json:"type"`
+	Payload  json.RawMessage `json:"payload"`
+	Interval time.Duration   `json:"interval"`
+}
+
+// Store persists jobs. Implementations must hand each due job to exactly one
+// caller of Dequeue, even across processes. A claim is identified by the job
+// and its attempt: Heartbeat, Complete, Fail and DeadLetter return
+// ErrLeaseLost once the job was claimed again, so a worker that overran its
+// lease cannot overwrite the new owner's state.
+type Store interface {
+	Enqueue(ctx context.Context, job *Job) error
+	Dequeue(ctx context.Context, types []string) (*Job, error)
+	// Heartbeat extends the lease of a running job
+	Heartbeat(ctx context.Context, job *Job) error
+	Complete(ctx context.Context, job *Job) error
+	Fail(ctx context.Context, job *Job, errMsg string, retryAt time.Time) error
+	DeadLetter(ctx context.Context, job *Job, errMsg string) error
+	// ClaimSchedule records a schedule run and reports whether the caller won the
+	// right to enqueue it; used so recurring jobs fire once across replicas.
+	ClaimSchedule(ctx context.Context, name string, interval time.Duration) (bool, error)
+}
+
+// ErrNoJob is returned by Store.Dequeue when no job is due
+var ErrNoJob = errors.New("no job available")
+
+// ErrLeaseLost is returned for a job that was claimed again by another
+// worker after its lease expired
+var ErrLeaseLost = errors.New("job lease lost")
+
+// Handler processes one job. Returning an error schedules a retry.
+type Handler func(ctx context.Context, job *Job) error
+
+// Options configures a Worker. HeartbeatInterval must be well below the
+// store's lease so running jobs are not claimed again.
+type Options struct {
+	Concurrency       int
+	PollInterval      time.Duration
+	HeartbeatInterval time.Duration
+	MaxAttempts       int
+	BaseBackoff       time.Duration
+	MaxBackoff        time.Duration
+}
+
+// DefaultOptions returns sensible worker defaults
+func DefaultOptions() Options {
+	return Options{
+		Concurrency:       4,
+		PollInterval:      time.Second,
+		HeartbeatInterval: 15 * time.Second,
+		MaxAttempts:       5,
+		BaseBackoff:       10 * time.Second,
+		MaxBackoff:        30 * time.Minute,
+	}
+}
+
+// Worker dispatches stored jobs to registered handlers
+type Worker struct {
+	store     Store
+	opts      Options
+	handlers  map[string]Handler
+	schedules []Schedule
+	mu        sync.RWMutex
+}
+
+// NewWorker creates a worker backed by store
+func NewWorker(store Store, opts Options) *Worker {
+	return &Worker{
+		store:    store,
+		opts:     opts,
+		handlers: make(map[string]Handler),
+	}
+}
+
+// Register associates a handler with a job type
+func (w *Worker) Register(jobType string, handler Handler) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[jobType] = handler
+}
+
+// Every registers a recurring job enqueued once per interval across all workers
+func (w *Worker) Every(name, jobType string, interval time.Duration, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload for schedule %s: %w", name, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.schedules = append(w.schedules, Schedule{Name: name, Type: jobType, Payload: data, Interval: interval})
+	return nil
+}
+
+// Enqueue stores a new job to run at runAt (immediately when zero)
+func Enqueue(ctx context.Context, store Store, jobType string, payload interface{}, runAt time.Time) (*Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+
+	job := &Job{Type: jobType, Payload: data, State: StatePending, RunAt: runAt}
+	if err := store.Enqueue(ctx, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Run processes jobs until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	concurrency := w.opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency + 1)
+	go func() {
+		defer wg.Done()
+		w.runSchedules(ctx)
+	}()
+	for i := 0; i < concurrency; i++ {
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+// loop repeatedly dequeues and runs jobs, sleeping when the queue is empty
+func (w *Worker) loop(ctx context.Context) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := w.store.Dequeue(ctx, w.types())
+		if err != nil {
+			if !errors.Is(err, ErrNoJob) && ctx.Err() == nil {
+				log.Printf("Warning: failed to dequeue job: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(w.opts.PollInterval):
+			}
+			continue
+		}
+
+		w.process(ctx, job)
+	}
+}
+
+// heartbeat extends the job's lease until stop is closed. When the lease is
+// lost the handler's context is cancelled, since another worker owns the job.
+func (w *Worker) heartbeat(ctx context.Context, job *Job, cancel context.CancelFunc, stop <-chan struct{}) {
+	interval := w.opts.HeartbeatInterval
+	if interval <= 0 {
+		interval = DefaultOptions().HeartbeatInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := w.store.Heartbeat(ctx, job)
+		switch {
+		case errors.Is(err, ErrLeaseLost):
+			log.Printf("Warning: lost the lease of job %d, cancelling it", job.ID)
+			cancel()
+			return
+		case err != nil && ctx.Err() == nil:
+			log.Printf("Warning: failed to extend the lease of job %d: %v", job.ID, err)
+		}
+	}
+}
+
+// process runs a single job and records its outcome
+func (w *Worker) process(ctx context.Context, job *Job) {
+	w.mu.RLock()
+	handler := w.handlers[job.Type]
+	w.mu.RUnlock()
+
+	jobCtx, cancel := context.WithCancel(ctx)
+	stop := make(chan struct{})
+	go w.heartbeat(ctx, job, cancel, stop)
+	err := runHandler(jobCtx, handler, job)
+	close(stop)
+	cancel()
+
+	if err == nil {
+		if err := w.store.Complete(ctx, job); err != nil {
+			log.Printf("Warning: failed to complete job %d: %v", job.ID, err)
+		}
+		return
+	}
+
+	maxAttempts := job.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = w.opts.MaxAttempts
+	}
+	if job.Attempts >= maxAttempts {
+		log.Printf("Job %d (%s) moved to dead letter after %d attempts: %v", job.ID, job.Type, job.Attempts, err)
+		if err := w.store.DeadLetter(ctx, job, err.Error()); err != nil {
+			log.Printf("Warning: failed to dead-letter job %d: %v", job.ID, err)
+		}
+		return
+	}
+
+	retryAt := time.Now().Add(w.backoff(job.Attempts))
+	if err := w.store.Fail(ctx, job, err.Error(), retryAt); err != nil {
+		log.Printf("Warning: failed to reschedule job %d: %v", job.ID, err)
+	}
+}
+
+// runHandler calls handler, converting panics into errors
+func runHandler(ctx context.Context, handler Handler, job *Job) (err error) {
+	if handler == nil {
+		return fmt.Errorf("no handler registered for job type %q", job.Type)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// backoff returns the exponential retry delay after the given number of attempts
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.opts.BaseBackoff
+	for i := 1; i < attempts && delay < w.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > w.opts.MaxBackoff {
+		delay = w.opts.MaxBackoff
+	}
+	return delay
+}
+
+// runSchedules enqueues recurring jobs whenever their interval has elapsed
+func (w *Worker) runSchedules(ctx context.Context) {
+	ticker := time.NewTicker(w.opts.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.mu.RLock()
+		schedules := append([]Schedule(nil), w.schedules...)
+		w.mu.RUnlock()
+
+		for _, schedule := range schedules {
+			claimed, err := w.store.ClaimSchedule(ctx, schedule.Name, schedule.Interval)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Warning: failed to claim schedule %s: %v", schedule.Name, err)
+				}
+				continue
+			}
+			if !claimed {
+				continue
+			}
+			job := &Job{Type: schedule.Type, Payload: schedule.Payload, State: StatePending, RunAt: time.Now()}
+			if err := w.store.Enqueue(ctx, job); err != nil {
+				log.Printf("Warning: failed to enqueue scheduled job %s: %v", schedule.Name, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) types() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	types := make([]string, 0, len(w.handlers))
+	for jobType := range w.handlers {
+		types = append(types, jobType)
+	}
+	return types
+}
@@ -0,0 +1,223 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schema creates the tables used by PostgresStore
+const Schema = `
+CREATE TABLE IF NOT EXISTS jobs (
+	id           BIGSERIAL PRIMARY KEY,
+	type         TEXT        NOT NULL,
+	payload      JSONB       NOT NULL DEFAULT '{}',
+	state        TEXT        NOT NULL DEFAULT 'pending',
+	attempts     INT         NOT NULL DEFAULT 0,
+	max_attempts INT         NOT NULL DEFAULT 0,
+	run_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_error   TEXT        NOT NULL DEFAULT '',
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS jobs_due_idx ON jobs (state, run_at);
+
+CREATE TABLE IF NOT EXISTS job_schedules (
+	name     TEXT PRIMARY KEY,
+	last_run TIMESTAMPTZ NOT NULL
+);
+`
+
+// PostgresStore is a Store backed by PostgreSQL. Jobs are claimed with
+// SELECT ... FOR UPDATE SKIP LOCKED so any number of workers can share a table.
+// Running jobs whose worker died are picked up again after the lease expires;
+// workers renew the lease with Heartbeat while a handler runs. Lease expiry
+// is judged by the database clock so worker clock skew does not matter.
+type PostgresStore struct {
+	db    *sql.DB
+	lease time.Duration
+}
+
+// NewPostgresStore creates a store using an open database handle. The caller
+// registers the driver (e.g. github.com/lib/pq or pgx stdlib).
+func NewPostgresStore(db *sql.DB, lease time.Duration) *PostgresStore {
+	return &PostgresStore{db: db, lease: lease}
+}
+
+// Migrate creates the job tables if they do not exist
+func (s *PostgresStore) Migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, Schema)
+	return err
+}
+
+// Enqueue inserts a job and sets its ID and creation time
+func (s *PostgresStore) Enqueue(ctx context.Context, job *Job) error {
+	payload := job.Payload
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO jobs (type, payload, state, max_attempts, run_at) VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		job.Type, string(payload), StatePending, job.MaxAttempts, job.RunAt,
+	).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue %s job: %w", job.Type, err)
+	}
+	return nil
+}
+
+// Dequeue claims the oldest due job of one of the given types
+func (s *PostgresStore) Dequeue(ctx context.Context, types []string) (*Job, error) {
+	if len(types) == 0 {
+		return nil, ErrNoJob
+	}
+
+	placeholders := make([]string, len(types))
+	args := []interface{}{s.lease.Seconds()}
+	for i, jobType := range types {
+		placeholders[i] = fmt.Sprintf("$%d", i+2)
+		args = append(args, jobType)
+	}
+
+	query := fmt.Sprintf(`
+UPDATE jobs SET state = 'running', attempts = attempts + 1, updated_at = now()
+WHERE id = (
+	SELECT id FROM jobs
+	WHERE ((state = 'pending' AND run_at <= now()) OR (state = 'running' AND updated_at < now() - make_interval(secs => $1)))
+	  AND type IN (%s)
+	ORDER BY run_at
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, type, payload, state, attempts, max_attempts, run_at, last_error, created_at`,
+		strings.Join(placeholders, ", "))
+
+	var job Job
+	var payload string
+	err := s.db.QueryRowContext(ctx, query, args...).Scan(
+		&job.ID, &job.Type, &payload, &job.State, &job.Attempts, &job.MaxAttempts, &job.RunAt, &job.LastError, &job.CreatedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoJob
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dequeue job: %w", err)
+	}
+	job.Payload = []byte(payload)
+	return &job, nil
+}
+
+// Heartbeat extends the lease of a running job
+func (s *PostgresStore) Heartbeat(ctx context.Context, job *Job) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE jobs SET updated_at = now() WHERE id = $1 AND state = 'running' AND attempts = $2`,
+		job.ID, job.Attempts)
+	return s.checkClaim(job, result, err)
+}
+
+// Complete marks a job as succeeded
+func (s *PostgresStore) Complete(ctx context.Context, job *Job) error {
+	return s.setState(ctx, job, StateSucceeded, "", time.Time{})
+}
+
+// Fail records an error and schedules the job to run again at retryAt
+func (s *PostgresStore) Fail(ctx context.Context, job *Job, errMsg string, retryAt time.Time) error {
+	return s.setState(ctx, job, StatePending, errMsg, retryAt)
+}
+
+// DeadLetter parks a job that exhausted its retries for manual inspection
+func (s *PostgresStore) DeadLetter(ctx context.Context, job *Job, errMsg string) error {
+	return s.setState(ctx, job, StateDeadLetter, errMsg, time.Time{})
+}
+
+// ClaimSchedule atomically advances a schedule when its interval has elapsed
+func (s *PostgresStore) ClaimSchedule(ctx context.Context, name string, interval time.Duration) (bool, error) {
+	result, err := s.db.ExecContext(ctx, `
+INSERT INTO job_schedules (name, last_run) VALUES ($1, now())
+ON CONFLICT (name) DO UPDATE SET last_run = now()
+WHERE job_schedules.last_run <= now() - make_interval(secs => $2)`,
+		name, interval.Seconds())
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+// Requeue moves a dead-lettered job back to pending with a fresh attempt budget
+func (s *PostgresStore) Requeue(ctx context.Context, id int64) error {
+	result, err := s.db.ExecContext(ctx,
+		`UPDATE jobs SET state = 'pending', attempts = 0, run_at = now(), updated_at = now() WHERE id = $1 AND state = 'dead_letter'`, id)
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return fmt.Errorf("job %d is not dead-lettered", id)
+	}
+	return nil
+}
+
+// DeadLetters lists jobs that exhausted their retries
+func (s *PostgresStore) DeadLetters(ctx context.Context, limit int) ([]Job, error) {
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, type, payload, state, attempts, max_attempts, run_at, last_error, created_at
+FROM jobs WHERE state = 'dead_letter' ORDER BY updated_at DESC LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		var job Job
+		var payload string
+		if err := rows.Scan(&job.ID, &job.Type, &payload, &job.State, &job.Attempts, &job.MaxAttempts, &job.RunAt, &job.LastError, &job.CreatedAt); err != nil {
+			return nil, err
+		}
+		job.Payload = []byte(payload)
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// setState records the outcome of a claim. The update only applies while
+// the job is still running under the same attempt, so a worker whose lease
+// expired cannot overwrite the state of the worker that claimed it since.
+func (s *PostgresStore) setState(ctx context.Context, job *Job, state State, errMsg string, runAt time.Time) error {
+	var result sql.Result
+	var err error
+	if runAt.IsZero() {
+		result, err = s.db.ExecContext(ctx,
+			`UPDATE jobs SET state = $1, last_error = $2, updated_at = now() WHERE id = $3 AND state = 'running' AND attempts = $4`,
+			state, errMsg, job.ID, job.Attempts)
+	} else {
+		result, err = s.db.ExecContext(ctx,
+			`UPDATE jobs SET state = $1, last_error = $2, run_at = $3, updated_at = now() WHERE id = $4 AND state = 'running' AND attempts = $5`,
+			state, errMsg, runAt, job.ID, job.Attempts)
+	}
+	return s.checkClaim(job, result, err)
+}
+
+// checkClaim turns an update of a claimed job that matched no row into
+// ErrLeaseLost
+func (s *PostgresStore) checkClaim(job *Job, result sql.Result, err error) error {
+	if err != nil {
+		return fmt.Errorf("failed to update job %d: %w", job.ID, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to update job %d: %w", job.ID, err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("job %d attempt %d: %w", job.ID, job.Attempts, ErrLeaseLost)
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+// Package scm provides a GitHub/GitLab REST client that handles pagination,
+// conditional requests, rate limits and token refresh for tools that talk to
+// source control at organization scale.
+package scm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxRetries      = 5
+	maxBackoff      = 10 * time.Second
+	maxRateLimitNap = 15 * time.Minute
+)
+
+// TokenSource supplies the bearer token for each request
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// StaticToken is a TokenSource for personal access tokens and CI tokens
+type StaticToken string
+
+// Token returns the static token
+func (t StaticToken) Token(ctx context.Context) (string, error) {
+	return string(t), nil
+}
+
+// APIError is returned for non-successful responses
+type APIError struct {
+	StatusCode int
+	Method     string
+	URL        string
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+// cachedResponse is a response body stored for conditional requests
+type cachedResponse struct {
+	etag string
+	body []byte
+	next string
+}
+
+// Client is a rate-limit-aware REST client for GitHub and GitLab
+type Client struct {
+	BaseURL string
+	HTTP    *http.Client
+	Tokens  TokenSource
+
+	mu          sync.Mutex
+	cache       map[string]cachedResponse
+	interval    time.Duration
+	lastRequest time.Time
+}
+
+// NewClient creates a client for the API at baseURL (e.g. https://api.github.com)
+func NewClient(baseURL string, tokens TokenSource) *Client {
+	return &Client{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+		Tokens:  tokens,
+		cache:   make(map[string]cachedResponse),
+	}
+}
+
+// Get fetches path and decodes the JSON response into v. Responses are cached
+// by ETag so repeated polling does not count against the rate limit.
+func (c *Client) Get(ctx context.Context, path string, v interface{}) error {
+	body, _, err := c.get(ctx, c.url(path))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// GetAll follows Link rel="next" pagination for a list endpoint, calling each
+// for every element of every page
+func (c *Client) GetAll(ctx context.Context, path string, each func(json.RawMessage) error) error {
+	next := c.url(path)
+	for next != "" {
+		body, nextURL, err := c.get(ctx, next)
+		if err != nil {
+			return err
+		}
+
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			return fmt.Errorf("failed to decode page %s: %w", next, err)
+		}
+		for _, item := range items {
+			if err := each(item); err != nil {
+				return err
+			}
+		}
+		next = nextURL
+	}
+	return nil
+}
+
+// Do sends a request with an optional JSON body and decodes the JSON response into v when non-nil
+func (c *Client) Do(ctx context.Context, method, path string, body, v interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	resp, respBody, err := c.send(ctx, method, c.url(path), payload, "")
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return &APIError{StatusCode: resp.StatusCode, Method: method, URL: path, Body: string(respBody)}
+	}
+	if v != nil && len(respBody) > 0 {
+		return json.Unmarshal(respBody, v)
+	}
+	return nil
+}
+
+// get performs a conditional GET and returns the body and the next page URL
+func (c *Client) get(ctx context.Context, url string) ([]byte, string, error) {
+	c.mu.Lock()
+	cached, hasCached := c.cache[url]
+	c.mu.Unlock()
+
+	resp, body, err := c.send(ctx, http.MethodGet, url, nil, cached.etag)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && hasCached {
+		return cached.body, cached.next, nil
+	}
+	if resp.StatusCode >= 300 {
+		return nil, "", &APIError{StatusCode: resp.StatusCode, Method: http.MethodGet, URL: url, Body: string(body)}
+	}
+
+	next := nextLink(resp.Header.Get("Link"))
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		c.mu.Lock()
+		c.cache[url] = cachedResponse{etag: etag, body: body, next: next}
+		c.mu.Unlock()
+	}
+	return body, next, nil
+}
+
+// send performs a request, retrying on rate limits and server errors
+func (c *Client) send(ctx context.Context, method, url string, payload []byte, etag string) (*http.Response, []byte, error) {
+	for attempt := 0; ; attempt++ {
+		if err := c.throttle(ctx); err != nil {
+			return nil, nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, nil, err
+		}
+		req.Header.Set("Accept", "application/vnd.github+json")
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if c.Tokens != nil {
+			token, err := c.Tokens.Token(ctx)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to obtain token: %w", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := c.HTTP.Do(req)
+		if err != nil {
+			return nil, nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		c.adapt(resp.Header)
+
+		wait, retry := retryDelay(resp, attempt)
+		if !retry || attempt >= maxRetries {
+			return resp, body, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// throttle spaces requests by the current adaptive interval
+func (c *Client) throttle(ctx context.Context) error {
+	c.mu.Lock()
+	wait := time.Until(c.lastRequest.Add(c.interval))
+	c.lastRequest = time.Now()
+	if wait > 0 {
+		c.lastRequest = c.lastRequest.Add(wait)
+	}
+	c.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// adapt slows the client down as the remaining primary rate limit shrinks and
+// relaxes again once budget is available
+func (c *Client) adapt(header http.Header) {
+	remaining, errRemaining := strconv.Atoi(firstHeader(header, "X-RateLimit-Remaining", "RateLimit-Remaining"))
+	limit, errLimit := strconv.Atoi(firstHeader(header, "X-RateLimit-Limit", "RateLimit-Limit"))
+	if errRemaining != nil || errLimit != nil || limit == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case remaining < limit/10:
+		c.interval = c.interval*2 + 100*time.Millisecond
+		if c.interval > maxBackoff {
+			c.interval = maxBackoff
+		}
+	case remaining > limit/2:
+		c.interval /= 2
+	}
+}
+
+// retryDelay decides whether a response should be retried and after how long
+func retryDelay(resp *http.Response, attempt int) (time.Duration, bool) {
+	backoff := time.Duration(1<<attempt) * time.Second
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusForbidden:
+		// Secondary rate limits send Retry-After; primary limits send a reset time
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if firstHeader(resp.Header, "X-RateLimit-Remaining", "RateLimit-Remaining") == "0" {
+			reset, err := strconv.ParseInt(firstHeader(resp.Header, "X-RateLimit-Reset", "RateLimit-Reset"), 10, 64)
+			if err != nil {
+				return backoff, true
+			}
+			wait := time.Until(time.Unix(reset, 0))
+			if wait > maxRateLimitNap {
+				return 0, false
+			}
+			return wait, true
+		}
+		return 0, resp.StatusCode == http.StatusTooManyRequests
+	case resp.StatusCode >= 500:
+		return backoff, true
+	}
+	return 0, false
+}
+
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextLink extracts the rel="next" URL from a Link header
+func nextLink(header string) string {
+	if match := linkNextPattern.FindStringSubmatch(header); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+func firstHeader(header http.Header, names ...string) string {
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func (c *Client) url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return c.BaseURL + "/" + strings.TrimLeft(path, "/")
+}
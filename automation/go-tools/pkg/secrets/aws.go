@@ -0,0 +1,64 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// AWSSecretsManagerProvider reads AWS Secrets Manager secrets:
+// "aws-sm:prod/db" returns the secret string and "aws-sm:prod/db#password"
+// one key of a JSON secret.
+type AWSSecretsManagerProvider struct {
+	// Client is created from the default credential chain on first use when nil
+	Client secretsmanageriface.SecretsManagerAPI
+
+	once    sync.Once
+	initErr error
+}
+
+// Get reads the secret and optional JSON key named by key
+func (p *AWSSecretsManagerProvider) Get(ctx context.Context, key string) (string, error) {
+	p.once.Do(func() {
+		if p.Client == nil {
+			var sess *session.Session
+			sess, p.initErr = session.NewSessionWithOptions(session.Options{SharedConfigState: session.SharedConfigEnable})
+			if p.initErr == nil {
+				p.Client = secretsmanager.New(sess)
+			}
+		}
+	})
+	if p.initErr != nil {
+		return "", fmt.Errorf("failed to create AWS session: %w", p.initErr)
+	}
+
+	id, field := splitField(key)
+	out, err := p.Client.GetSecretValueWithContext(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	value := aws.StringValue(out.SecretString)
+	if field == "" {
+		return value, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", id, err)
+	}
+	v, ok := fields[field]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return fmt.Sprint(v), nil
+}
@@ -0,0 +1,39 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// KubernetesProvider reads keys of Kubernetes Secrets:
+// "k8s:namespace/name#key"
+type KubernetesProvider struct {
+	Client kubernetes.Interface
+}
+
+// Get reads the Secret and key named by key
+func (p *KubernetesProvider) Get(ctx context.Context, key string) (string, error) {
+	ref, field := splitField(key)
+	namespace, name, found := strings.Cut(ref, "/")
+	if !found || field == "" {
+		return "", fmt.Errorf("invalid reference %q: use namespace/name#key", key)
+	}
+
+	secret, err := p.Client.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[field]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return string(value), nil
+}
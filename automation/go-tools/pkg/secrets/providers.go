@@ -0,0 +1,78 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// EnvProvider reads secrets from environment variables: "env:NAME"
+type EnvProvider struct{}
+
+// Get returns the variable named key
+func (EnvProvider) Get(_ context.Context, key string) (string, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// FileProvider reads secrets from files, e.g. mounted Kubernetes or Docker
+// secrets: "file:/run/secrets/token". A trailing newline is removed.
+type FileProvider struct{}
+
+// Get returns the content of the file at key
+func (FileProvider) Get(_ context.Context, key string) (string, error) {
+	data, err := os.ReadFile(key)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// SOPSProvider decrypts values from SOPS-encrypted files with the sops
+// binary: "sops:secrets.enc.yaml#jira.token" returns one dotted path, and
+// a reference without '#' returns the whole decrypted file.
+type SOPSProvider struct {
+	// Path of the sops binary, "sops" on PATH when empty
+	Path string
+}
+
+// Get decrypts the file and path named by key
+func (p *SOPSProvider) Get(ctx context.Context, key string) (string, error) {
+	file, field := splitField(key)
+	binary := p.Path
+	if binary == "" {
+		binary = "sops"
+	}
+
+	args := []string{"--decrypt"}
+	if field != "" {
+		var extract strings.Builder
+		for _, part := range strings.Split(field, ".") {
+			fmt.Fprintf(&extract, "[%q]", part)
+		}
+		args = append(args, "--extract", extract.String())
+	}
+	args = append(args, file)
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("sops failed: %s", message)
+		}
+		return "", fmt.Errorf("sops failed: %w", err)
+	}
+	return strings.TrimRight(stdout.String(), "\n"), nil
+}
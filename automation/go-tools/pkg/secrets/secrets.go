@@ -0,0 +1,210 @@
+// Package secrets resolves credentials from pluggable providers so tools stop
+// reimplementing credential handling. A reference names its provider with a
+// scheme prefix, e.g. "env:GITHUB_TOKEN", "file:/run/secrets/token",
+// "k8s:ops/slack#webhook", "vault:secret/data/ci#token",
+// "aws-sm:prod/db#password" or "sops:secrets.enc.yaml#jira.token". Values
+// without a registered scheme are returned unchanged, so plain settings keep
+// working.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when a provider has no value for a key
+var ErrNotFound = errors.New("secret not found")
+
+// Provider looks up a secret by its provider-specific key, the part of the
+// reference after the scheme
+type Provider interface {
+	Get(ctx context.Context, key string) (string, error)
+}
+
+// ProviderFunc adapts a function to the Provider interface
+type ProviderFunc func(ctx context.Context, key string) (string, error)
+
+// Get calls f
+func (f ProviderFunc) Get(ctx context.Context, key string) (string, error) {
+	return f(ctx, key)
+}
+
+// RotateHook is called by Refresh when the value behind a reference changes
+type RotateHook func(ref, value string)
+
+// cachedValue is a resolved reference and when it was fetched
+type cachedValue struct {
+	value     string
+	fetchedAt time.Time
+}
+
+// Resolver resolves references through registered providers and caches the
+// values for TTL. A zero TTL disables caching.
+type Resolver struct {
+	TTL time.Duration
+
+	mu        sync.Mutex
+	providers map[string]Provider
+	cache     map[string]cachedValue
+	hooks     map[string][]RotateHook
+}
+
+// NewResolver creates a resolver without providers
+func NewResolver(ttl time.Duration) *Resolver {
+	return &Resolver{
+		TTL:       ttl,
+		providers: make(map[string]Provider),
+		cache:     make(map[string]cachedValue),
+		hooks:     make(map[string][]RotateHook),
+	}
+}
+
+// NewDefaultResolver creates a resolver with the env, file, sops, vault and
+// aws-sm providers. Vault and AWS are configured from their usual
+// environment variables and connect only when a reference uses them. The
+// k8s provider needs a client and is registered by the caller.
+func NewDefaultResolver() *Resolver {
+	r := NewResolver(5 * time.Minute)
+	r.Register("env", EnvProvider{})
+	r.Register("file", FileProvider{})
+	r.Register("sops", &SOPSProvider{})
+	r.Register("vault", &VaultProvider{})
+	r.Register("aws-sm", &AWSSecretsManagerProvider{})
+	return r
+}
+
+// Register makes a provider available under scheme, replacing any previous one
+func (r *Resolver) Register(scheme string, provider Provider) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.providers[scheme] = provider
+}
+
+// Has reports whether a provider is registered for scheme
+func (r *Resolver) Has(scheme string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.providers[scheme]
+	return ok
+}
+
+// split returns the provider and key of ref, or a nil provider when ref is a
+// plain value
+func (r *Resolver) split(ref string) (Provider, string, string) {
+	scheme, key, found := strings.Cut(ref, ":")
+	if !found {
+		return nil, "", ref
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	provider := r.providers[scheme]
+	return provider, scheme, key
+}
+
+// IsReference reports whether ref names a registered provider
+func (r *Resolver) IsReference(ref string) bool {
+	provider, _, _ := r.split(ref)
+	return provider != nil
+}
+
+// Resolve returns the value behind ref, from the cache while it is fresh.
+// An empty ref resolves to an empty value.
+func (r *Resolver) Resolve(ctx context.Context, ref string) (string, error) {
+	provider, scheme, key := r.split(ref)
+	if provider == nil {
+		return ref, nil
+	}
+
+	r.mu.Lock()
+	cached, ok := r.cache[ref]
+	r.mu.Unlock()
+	if ok && r.TTL > 0 && time.Since(cached.fetchedAt) < r.TTL {
+		return cached.value, nil
+	}
+
+	value, err := provider.Get(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("%s secret %s: %w", scheme, key, err)
+	}
+	if r.TTL > 0 {
+		r.mu.Lock()
+		r.cache[ref] = cachedValue{value: value, fetchedAt: time.Now()}
+		r.mu.Unlock()
+	}
+	return value, nil
+}
+
+// OnRotate registers a hook called by Refresh when the value of ref changes,
+// e.g. to reconnect a client with a rotated password
+func (r *Resolver) OnRotate(ref string, hook RotateHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks[ref] = append(r.hooks[ref], hook)
+}
+
+// Refresh fetches every cached reference again and calls the rotation hooks
+// of those whose value changed. Failed lookups keep the cached value and are
+// returned together.
+func (r *Resolver) Refresh(ctx context.Context) error {
+	r.mu.Lock()
+	refs := make([]string, 0, len(r.cache))
+	for ref := range r.cache {
+		refs = append(refs, ref)
+	}
+	r.mu.Unlock()
+
+	var failed []string
+	for _, ref := range refs {
+		provider, scheme, key := r.split(ref)
+		if provider == nil {
+			continue
+		}
+		value, err := provider.Get(ctx, key)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s secret %s: %v", scheme, key, err))
+			continue
+		}
+
+		r.mu.Lock()
+		previous := r.cache[ref]
+		r.cache[ref] = cachedValue{value: value, fetchedAt: time.Now()}
+		hooks := append([]RotateHook(nil), r.hooks[ref]...)
+		r.mu.Unlock()
+
+		if previous.value != value {
+			for _, hook := range hooks {
+				hook(ref, value)
+			}
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to refresh %d secrets: %s", len(failed), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Watch calls Refresh every interval until ctx is done, passing errors to onError
+func (r *Resolver) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// splitField splits "name#field" into its parts; field is empty without '#'
+func splitField(key string) (string, string) {
+	name, field, _ := strings.Cut(key, "#")
+	return name, field
+}
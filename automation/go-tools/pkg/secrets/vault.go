@@ -0,0 +1,56 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	vault "github.com/hashicorp/vault/api"
+)
+
+// VaultProvider reads fields from Vault: "vault:secret/data/ci#token". KV
+// version 2 responses are unwrapped, so the path is the full API path
+// including data/. The field defaults to "value".
+type VaultProvider struct {
+	// Client is created from VAULT_ADDR and VAULT_TOKEN on first use when nil
+	Client *vault.Client
+
+	once    sync.Once
+	initErr error
+}
+
+// Get reads the path and field named by key
+func (p *VaultProvider) Get(ctx context.Context, key string) (string, error) {
+	p.once.Do(func() {
+		if p.Client == nil {
+			p.Client, p.initErr = vault.NewClient(vault.DefaultConfig())
+		}
+	})
+	if p.initErr != nil {
+		return "", fmt.Errorf("failed to create vault client: %w", p.initErr)
+	}
+
+	path, field := splitField(key)
+	if field == "" {
+		field = "value"
+	}
+	secret, err := p.Client.Logical().ReadWithContext(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if secret == nil || secret.Data == nil {
+		return "", ErrNotFound
+	}
+
+	data := secret.Data
+	if inner, ok := data["data"].(map[string]interface{}); ok {
+		if _, versioned := data["metadata"]; versioned {
+			data = inner
+		}
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return fmt.Sprint(value), nil
+}
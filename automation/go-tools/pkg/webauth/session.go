@@ -0,0 +1,148 @@
+package webauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// maxCookieSize is the largest cookie browsers reliably store
+const maxCookieSize = 4096
+
+// errInvalidCookie is returned for cookies that fail to decrypt or decode
+var errInvalidCookie = errors.New("invalid cookie")
+
+// session is the content of the session cookie
+type session struct {
+	Identity Identity `json:"identity"`
+	CSRF     string   `json:"csrf"`
+}
+
+// loginState is the content of the short-lived cookie that carries the
+// OAuth2 state and OIDC nonce from /login to /callback
+type loginState struct {
+	State   string    `json:"state"`
+	Nonce   string    `json:"nonce"`
+	Next    string    `json:"next"`
+	Expires time.Time `json:"expires"`
+}
+
+// cookieCodec seals cookie values with AES-GCM, so sessions are stateless
+// and shared by every replica with the same key. The cookie name is
+// authenticated as additional data so one cookie cannot stand in for another.
+type cookieCodec struct {
+	aead cipher.AEAD
+}
+
+func newCookieCodec(key []byte) (*cookieCodec, error) {
+	if len(key) < 32 {
+		return nil, fmt.Errorf("session key must be at least 32 bytes, got %d", len(key))
+	}
+	derived := sha256.Sum256(key)
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cookieCodec{aead: aead}, nil
+}
+
+func (c *cookieCodec) encode(name string, value interface{}) (string, error) {
+	plaintext, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, []byte(name))
+	encoded := base64.RawURLEncoding.EncodeToString(sealed)
+	if len(name)+len(encoded) > maxCookieSize {
+		return "", fmt.Errorf("cookie %s is %d bytes, more than browsers store; reduce the groups in the ID token", name, len(encoded))
+	}
+	return encoded, nil
+}
+
+func (c *cookieCodec) decode(name, encoded string, value interface{}) error {
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return errInvalidCookie
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(name))
+	if err != nil {
+		return errInvalidCookie
+	}
+	if err := json.Unmarshal(plaintext, value); err != nil {
+		return errInvalidCookie
+	}
+	return nil
+}
+
+// setCookie writes a sealed cookie valid until expires
+func (a *Auth) setCookie(w http.ResponseWriter, name string, value interface{}, expires time.Time) error {
+	encoded, err := a.codec.encode(name, value)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    encoded,
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   int(time.Until(expires).Seconds()),
+		Secure:   !a.config.InsecureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// clearCookie removes a cookie
+func (a *Auth) clearCookie(w http.ResponseWriter, name string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   !a.config.InsecureCookies,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// readSession returns the unexpired session of a request
+func (a *Auth) readSession(r *http.Request) (*session, bool) {
+	cookie, err := r.Cookie(a.config.CookieName)
+	if err != nil {
+		return nil, false
+	}
+	var s session
+	if err := a.codec.decode(a.config.CookieName, cookie.Value, &s); err != nil {
+		return nil, false
+	}
+	if time.Now().After(s.Identity.Expiry) {
+		return nil, false
+	}
+	return &s, true
+}
+
+// randomToken returns a URL-safe random token of 32 bytes
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
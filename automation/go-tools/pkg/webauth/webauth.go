@@ -0,0 +1,352 @@
+// Package webauth puts OIDC login in front of the web UIs the tools embed,
+// so that none of them ships its own authentication. It provides the login,
+// callback and logout handlers, stateless encrypted session cookies, CSRF
+// protection for state-changing requests and group-based authorization from
+// the ID token's groups claim.
+//
+//	auth, err := webauth.New(ctx, webauth.Config{IssuerURL: ..., ClientID: ..., RedirectURL: "https://reports.example.com/auth/callback", SessionKey: key})
+//	mux.Handle("/auth/", auth.Handler())
+//	mux.Handle("/", auth.Require("platform-team")(ui))
+//
+// Pages read the signed-in user with IdentityFrom and put CSRFToken into
+// forms as csrf_token, or send it in the X-CSRF-Token header.
+package webauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Default settings
+const (
+	DefaultCookieName  = "webauth_session"
+	DefaultGroupsClaim = "groups"
+	DefaultSessionTTL  = 8 * time.Hour
+
+	// loginCookieName carries the state and nonce of a login in progress
+	loginCookieName = "webauth_login"
+	// loginTTL bounds how long a login may take at the identity provider
+	loginTTL = 10 * time.Minute
+
+	// CSRFHeader and CSRFField carry the CSRF token of unsafe requests
+	CSRFHeader = "X-CSRF-Token"
+	CSRFField  = "csrf_token"
+)
+
+// Config configures OIDC login and sessions
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURL is the absolute URL of the callback handler, e.g.
+	// https://reports.example.com/auth/callback. Its origin is also the only
+	// Origin accepted on unsafe requests.
+	RedirectURL string
+
+	// Scopes requested besides openid; defaults to profile, email and groups
+	Scopes []string
+
+	// GroupsClaim names the ID token claim listing the user's groups
+	GroupsClaim string
+
+	// SessionKey encrypts the session cookie; at least 32 bytes, shared by
+	// every replica
+	SessionKey []byte
+	SessionTTL time.Duration
+	CookieName string
+
+	// InsecureCookies drops the Secure attribute for local development over
+	// plain HTTP
+	InsecureCookies bool
+}
+
+// Identity is the signed-in user
+type Identity struct {
+	Subject string    `json:"sub"`
+	Email   string    `json:"email,omitempty"`
+	Name    string    `json:"name,omitempty"`
+	Groups  []string  `json:"groups,omitempty"`
+	Expiry  time.Time `json:"exp"`
+}
+
+// InGroup reports whether the user is in any of groups
+func (id *Identity) InGroup(groups ...string) bool {
+	for _, want := range groups {
+		for _, have := range id.Groups {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Auth authenticates users with an OIDC provider and authorizes them by group
+type Auth struct {
+	config   Config
+	oauth    oauth2.Config
+	verifier *oidc.IDTokenVerifier
+	codec    *cookieCodec
+	origin   string
+	prefix   string
+}
+
+// New discovers the OIDC provider and validates the configuration
+func New(ctx context.Context, config Config) (*Auth, error) {
+	if config.IssuerURL == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, fmt.Errorf("webauth needs an issuer URL, client ID and redirect URL")
+	}
+	redirect, err := url.Parse(config.RedirectURL)
+	if err != nil || !redirect.IsAbs() || redirect.Path == "" || strings.HasSuffix(redirect.Path, "/") {
+		return nil, fmt.Errorf("redirect URL %q must be an absolute URL of the callback, e.g. https://host/auth/callback", config.RedirectURL)
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = DefaultGroupsClaim
+	}
+	if config.SessionTTL <= 0 {
+		config.SessionTTL = DefaultSessionTTL
+	}
+	if config.CookieName == "" {
+		config.CookieName = DefaultCookieName
+	}
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"profile", "email", "groups"}
+	}
+	codec, err := newCookieCodec(config.SessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := oidc.NewProvider(ctx, config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+
+	// The handlers live next to the callback, e.g. /auth/login for /auth/callback
+	prefix := redirect.Path[:strings.LastIndex(redirect.Path, "/")+1]
+	return &Auth{
+		config: config,
+		oauth: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       append([]string{oidc.ScopeOpenID}, config.Scopes...),
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+		codec:    codec,
+		origin:   redirect.Scheme + "://" + redirect.Host,
+		prefix:   prefix,
+	}, nil
+}
+
+// Handler serves login, callback and logout under the directory of the
+// redirect URL, e.g. /auth/login, /auth/callback and /auth/logout
+func (a *Auth) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(a.prefix+"login", a.login)
+	mux.HandleFunc(a.prefix+"callback", a.callback)
+	mux.Handle(a.prefix+"logout", a.Require()(http.HandlerFunc(a.logout)))
+	return mux
+}
+
+// LoginURL is where unauthenticated browsers are sent, returning to next
+func (a *Auth) LoginURL(next string) string {
+	return a.prefix + "login?next=" + url.QueryEscape(next)
+}
+
+// login starts the authorization code flow
+func (a *Auth) login(w http.ResponseWriter, r *http.Request) {
+	state, err := randomToken()
+	if err != nil {
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+	pending := loginState{State: state, Nonce: nonce, Next: safeNext(r.URL.Query().Get("next")), Expires: time.Now().Add(loginTTL)}
+	if err := a.setCookie(w, loginCookieName, pending, pending.Expires); err != nil {
+		http.Error(w, "failed to start login", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, a.oauth.AuthCodeURL(state, oidc.Nonce(nonce)), http.StatusFound)
+}
+
+// callback finishes the flow: it checks the state, exchanges the code,
+// verifies the ID token and its nonce and starts a session
+func (a *Auth) callback(w http.ResponseWriter, r *http.Request) {
+	cookie, err := r.Cookie(loginCookieName)
+	var pending loginState
+	if err != nil || a.codec.decode(loginCookieName, cookie.Value, &pending) != nil || time.Now().After(pending.Expires) {
+		http.Error(w, "login expired, start again", http.StatusBadRequest)
+		return
+	}
+	a.clearCookie(w, loginCookieName)
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("state")), []byte(pending.State)) != 1 {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	if message := r.URL.Query().Get("error"); message != "" {
+		http.Error(w, "login failed: "+message+" "+r.URL.Query().Get("error_description"), http.StatusForbidden)
+		return
+	}
+
+	token, err := a.oauth.Exchange(r.Context(), r.URL.Query().Get("code"))
+	if err != nil {
+		http.Error(w, "failed to exchange the authorization code", http.StatusBadGateway)
+		return
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "the identity provider returned no ID token", http.StatusBadGateway)
+		return
+	}
+	idToken, err := a.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		http.Error(w, "invalid ID token", http.StatusForbidden)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(idToken.Nonce), []byte(pending.Nonce)) != 1 {
+		http.Error(w, "invalid ID token nonce", http.StatusForbidden)
+		return
+	}
+
+	identity, err := a.identity(idToken)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	csrf, err := randomToken()
+	if err != nil {
+		http.Error(w, "failed to start session", http.StatusInternalServerError)
+		return
+	}
+	if err := a.setCookie(w, a.config.CookieName, session{Identity: identity, CSRF: csrf}, identity.Expiry); err != nil {
+		http.Error(w, "failed to start session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, pending.Next, http.StatusFound)
+}
+
+// identity reads the user from the ID token claims. The session ends after
+// SessionTTL, not with the usually much shorter ID token.
+func (a *Auth) identity(idToken *oidc.IDToken) (Identity, error) {
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, fmt.Errorf("invalid ID token claims")
+	}
+	identity := Identity{Subject: idToken.Subject, Expiry: time.Now().Add(a.config.SessionTTL)}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+	switch groups := claims[a.config.GroupsClaim].(type) {
+	case string:
+		identity.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				identity.Groups = append(identity.Groups, name)
+			}
+		}
+	}
+	return identity, nil
+}
+
+// logout ends the session; it is a POST with the CSRF token so other sites
+// cannot sign users out
+func (a *Auth) logout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	a.clearCookie(w, a.config.CookieName)
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Require authenticates requests and lets through users in any of groups,
+// or every signed-in user when no groups are given. Browsers without a
+// session are redirected to the login; other clients get 401. Unsafe
+// methods also need the session's CSRF token and, when sent, an Origin
+// matching the redirect URL.
+func (a *Auth) Require(groups ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			s, ok := a.readSession(r)
+			if !ok {
+				if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+					http.Redirect(w, r, a.LoginURL(r.URL.RequestURI()), http.StatusFound)
+					return
+				}
+				http.Error(w, "authentication required", http.StatusUnauthorized)
+				return
+			}
+			if len(groups) > 0 && !s.Identity.InGroup(groups...) {
+				http.Error(w, "not a member of an authorized group", http.StatusForbidden)
+				return
+			}
+			if !safeMethod(r.Method) {
+				if origin := r.Header.Get("Origin"); origin != "" && origin != a.origin {
+					http.Error(w, "cross-origin request refused", http.StatusForbidden)
+					return
+				}
+				token := r.Header.Get(CSRFHeader)
+				if token == "" {
+					token = r.PostFormValue(CSRFField)
+				}
+				if subtle.ConstantTimeCompare([]byte(token), []byte(s.CSRF)) != 1 {
+					http.Error(w, "invalid or missing CSRF token", http.StatusForbidden)
+					return
+				}
+			}
+			ctx := context.WithValue(r.Context(), sessionKey{}, s)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// sessionKey is the context key of the session of an authenticated request
+type sessionKey struct{}
+
+// IdentityFrom returns the user of a request that passed Require
+func IdentityFrom(ctx context.Context) (*Identity, bool) {
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	if !ok {
+		return nil, false
+	}
+	return &s.Identity, true
+}
+
+// CSRFToken returns the CSRF token to embed in forms of a request that
+// passed Require
+func CSRFToken(ctx context.Context) string {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		return s.CSRF
+	}
+	return ""
+}
+
+// safeMethod reports whether a method does not change state
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// safeNext only allows local paths as the post-login redirect, so the login
+// cannot be used as an open redirect
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
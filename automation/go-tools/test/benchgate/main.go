@@ -0,0 +1,149 @@
+// Command benchgate compares `go test -bench -benchmem` output with a
+// recorded baseline in the same format and exits 1 when a benchmark got
+// slower or allocates more than allowed. Without a baseline it only warns,
+// so the gate starts enforcing once one is recorded with make
+// bench-baseline. Both files can also be compared in detail with benchstat.
+//
+//	go test -run '^$' -bench . -benchmem -count 5 ./cli/k8s-toolkit > bench.txt
+//	go run ./test/benchgate --baseline cli/k8s-toolkit/testdata/bench-baseline.txt bench.txt
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// gomaxprocsSuffix is the -N suffix go test appends to benchmark names
+var gomaxprocsSuffix = regexp.MustCompile(`-\d+$`)
+
+// result is the median of the runs of one benchmark
+type result struct {
+	nsPerOp     float64
+	allocsPerOp float64
+}
+
+// parseBenchmarks reads benchmark lines and returns the median time and
+// allocations per benchmark across repeated runs (-count)
+func parseBenchmarks(path string) (map[string]result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ns := make(map[string][]float64)
+	allocs := make(map[string][]float64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		name := gomaxprocsSuffix.ReplaceAllString(fields[0], "")
+		// Fields after the iteration count are value/unit pairs
+		for i := 2; i+1 < len(fields); i += 2 {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				continue
+			}
+			switch fields[i+1] {
+			case "ns/op":
+				ns[name] = append(ns[name], value)
+			case "allocs/op":
+				allocs[name] = append(allocs[name], value)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	results := make(map[string]result, len(ns))
+	for name, values := range ns {
+		results[name] = result{nsPerOp: median(values), allocsPerOp: median(allocs[name])}
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%s contains no benchmark results", path)
+	}
+	return results, nil
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	return sorted[len(sorted)/2]
+}
+
+func percentChange(before, after float64) float64 {
+	return (after - before) / before * 100
+}
+
+func main() {
+	baselineFile := flag.String("baseline", "cli/k8s-toolkit/testdata/bench-baseline.txt", "Recorded go test -bench output to compare with")
+	maxTime := flag.Float64("max-time-regression", 25, "Allowed increase in time/op, in percent")
+	maxAllocs := flag.Float64("max-alloc-regression", 10, "Allowed increase in allocs/op, in percent")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		log.Fatalf("usage: benchgate [--baseline file] <go test -bench output>")
+	}
+
+	baseline, err := parseBenchmarks(*baselineFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		fmt.Printf("WARNING: no benchmark baseline at %s, skipping the regression check; record one with make bench-baseline on the CI runner class\n", *baselineFile)
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to read baseline: %v", err)
+	}
+	current, err := parseBenchmarks(flag.Arg(0))
+	if err != nil {
+		log.Fatalf("Failed to read results: %v", err)
+	}
+
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var regressions, missing []string
+	for _, name := range names {
+		cur := current[name]
+		base, ok := baseline[name]
+		if !ok {
+			missing = append(missing, name)
+			continue
+		}
+		if base.nsPerOp > 0 {
+			if change := percentChange(base.nsPerOp, cur.nsPerOp); change > *maxTime {
+				regressions = append(regressions, fmt.Sprintf("%s: time/op %+.1f%% (%.0f -> %.0f ns)", name, change, base.nsPerOp, cur.nsPerOp))
+			}
+		}
+		if base.allocsPerOp > 0 {
+			if change := percentChange(base.allocsPerOp, cur.allocsPerOp); change > *maxAllocs {
+				regressions = append(regressions, fmt.Sprintf("%s: allocs/op %+.1f%% (%.0f -> %.0f)", name, change, base.allocsPerOp, cur.allocsPerOp))
+			}
+		}
+	}
+
+	for _, name := range missing {
+		fmt.Printf("%s is not in the baseline; re-record it with make bench-baseline\n", name)
+	}
+	if len(regressions) > 0 {
+		fmt.Printf("Regressions against %s:\n  %s\n", *baselineFile, strings.Join(regressions, "\n  "))
+		os.Exit(1)
+	}
+	fmt.Printf("No regressions in %d benchmarks against %s\n", len(names)-len(missing), *baselineFile)
+}
@@ -0,0 +1,276 @@
+//go:build e2e
+
+// Package e2e runs the k8s-toolkit against a real API server seeded with
+// synthetic unhealthy resources and asserts on the reports it produces.
+//
+// By default it creates a throwaway kind cluster. Pass -kubeconfig to run
+// against an existing disposable cluster or an envtest API server instead;
+// scenarios that need a kubelet are skipped when the cluster has no nodes.
+//
+//	go test -tags e2e ./test/e2e
+//	go test -tags e2e ./test/e2e -kubeconfig /tmp/envtest.kubeconfig
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// fixtureNamespace holds the seeded workloads so they can be removed in one delete
+const fixtureNamespace = "k8s-toolkit-e2e"
+
+// scenario seeds a broken resource and asserts that a toolkit command reports it
+type scenario struct {
+	name string
+
+	// needsNodes marks scenarios that rely on pods actually being scheduled
+	needsNodes bool
+
+	seed func(ctx context.Context, cs kubernetes.Interface) error
+
+	// ready reports when the seeded resource has reached the broken state
+	ready func(ctx context.Context, cs kubernetes.Interface) (bool, error)
+
+	// args are passed to the toolkit; --kubeconfig and -o json are added
+	args []string
+
+	// check asserts on the report; scenarios without one only seed the
+	// fixture until a check covering it exists
+	check func(output []byte) error
+}
+
+type options struct {
+	kubeconfig  string
+	kindCluster string
+	toolkit     string
+	keep        bool
+	readyWait   time.Duration
+}
+
+var opts options
+
+func init() {
+	flag.StringVar(&opts.kubeconfig, "kubeconfig", "", "Use an existing disposable cluster instead of creating a kind cluster")
+	flag.StringVar(&opts.kindCluster, "kind-cluster", "k8s-toolkit-e2e", "Name of the kind cluster to create")
+	flag.StringVar(&opts.toolkit, "toolkit", "", "Prebuilt k8s-toolkit binary (built from ./cli/k8s-toolkit when empty)")
+	flag.BoolVar(&opts.keep, "keep", false, "Keep the cluster and fixtures after the run")
+	flag.DurationVar(&opts.readyWait, "ready-timeout", 3*time.Minute, "How long to wait for seeded resources to break")
+}
+
+// Shared by the scenarios once TestMain has set up the cluster
+var (
+	clientset kubernetes.Interface
+	hasNodes  bool
+)
+
+// TestMain creates the cluster, builds the toolkit and seeds the fixture
+// namespace, runs the scenarios and removes everything again
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(setupAndRun(m))
+}
+
+func setupAndRun(m *testing.M) int {
+	ctx := context.Background()
+
+	workDir, err := os.MkdirTemp("", "k8s-toolkit-e2e")
+	if err != nil {
+		log.Printf("Failed to create work directory: %v", err)
+		return 1
+	}
+	defer os.RemoveAll(workDir)
+
+	if opts.kubeconfig == "" {
+		opts.kubeconfig = filepath.Join(workDir, "kubeconfig")
+		if err := createKindCluster(opts.kindCluster, opts.kubeconfig); err != nil {
+			log.Printf("Failed to create kind cluster: %v", err)
+			return 1
+		}
+		if !opts.keep {
+			defer command("kind", "delete", "cluster", "--name", opts.kindCluster)
+		}
+	}
+
+	if opts.toolkit == "" {
+		// go test runs in the package directory
+		opts.toolkit = filepath.Join(workDir, "k8s-toolkit")
+		if err := command("go", "build", "-o", opts.toolkit, "../../cli/k8s-toolkit"); err != nil {
+			log.Printf("Failed to build k8s-toolkit: %v", err)
+			return 1
+		}
+	}
+
+	config, err := clientcmd.BuildConfigFromFlags("", opts.kubeconfig)
+	if err != nil {
+		log.Printf("Failed to load kubeconfig: %v", err)
+		return 1
+	}
+	cs, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		log.Printf("Failed to create clientset: %v", err)
+		return 1
+	}
+	clientset = cs
+
+	nodes, err := cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Printf("Failed to list nodes: %v", err)
+		return 1
+	}
+	hasNodes = len(nodes.Items) > 0
+
+	if err := ensureNamespace(ctx, cs); err != nil {
+		log.Printf("Failed to create fixture namespace: %v", err)
+		return 1
+	}
+	if !opts.keep {
+		defer cleanupFixtures(context.Background(), cs)
+	}
+
+	return m.Run()
+}
+
+// TestScenarios runs every scenario as a subtest
+func TestScenarios(t *testing.T) {
+	ctx := context.Background()
+	for _, s := range scenarios() {
+		s := s
+		t.Run(s.name, func(t *testing.T) {
+			if s.needsNodes && !hasNodes {
+				t.Skip("cluster has no nodes")
+			}
+			if s.check == nil {
+				if err := s.seed(ctx, clientset); err != nil {
+					t.Fatalf("seed: %v", err)
+				}
+				t.Skip("seeded, no check covers it yet")
+			}
+			if err := runScenario(ctx, clientset, opts, s); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// runScenario seeds the fixture, waits for it to break, runs the toolkit and checks its report
+func runScenario(ctx context.Context, cs kubernetes.Interface, opts options, s scenario) error {
+	if err := s.seed(ctx, cs); err != nil {
+		return fmt.Errorf("seed: %w", err)
+	}
+
+	if s.ready != nil {
+		deadline := time.Now().Add(opts.readyWait)
+		for {
+			ok, err := s.ready(ctx, cs)
+			if err != nil {
+				return fmt.Errorf("ready: %w", err)
+			}
+			if ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("fixture did not reach the expected state within %s", opts.readyWait)
+			}
+			time.Sleep(2 * time.Second)
+		}
+	}
+
+	args := append([]string{"--kubeconfig", opts.kubeconfig, "-o", "json"}, s.args...)
+	cmd := exec.CommandContext(ctx, opts.toolkit, args...)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	// Non-zero exits are expected when the toolkit finds problems; only a
+	// failure to start the binary is fatal here
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return fmt.Errorf("run toolkit: %w", err)
+	}
+
+	return s.check(output)
+}
+
+// createKindCluster creates the cluster and writes its kubeconfig to path
+func createKindCluster(name, kubeconfig string) error {
+	return command("kind", "create", "cluster", "--name", name, "--kubeconfig", kubeconfig, "--wait", "2m")
+}
+
+// command runs a command with output attached to the terminal
+func command(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// healthCheck returns the named check from a `health -o json` report
+func healthCheck(output []byte, component string) (map[string]interface{}, error) {
+	var report struct {
+		Checks []map[string]interface{} `json:"checks"`
+	}
+	if err := json.Unmarshal(output, &report); err != nil {
+		return nil, fmt.Errorf("invalid health report: %w", err)
+	}
+	for _, check := range report.Checks {
+		if check["component"] == component {
+			return check, nil
+		}
+	}
+	return nil, fmt.Errorf("health report has no %q check", component)
+}
+
+// expectUnhealthy asserts the component is not Healthy and its details mention want
+func expectUnhealthy(component, want string) func([]byte) error {
+	return func(output []byte) error {
+		check, err := healthCheck(output, component)
+		if err != nil {
+			return err
+		}
+		if check["status"] == "Healthy" {
+			return fmt.Errorf("%s reported Healthy: %v", component, check["message"])
+		}
+		details, _ := json.Marshal(check["details"])
+		if !strings.Contains(string(details), want) {
+			return fmt.Errorf("%s details do not mention %q: %s", component, want, details)
+		}
+		return nil
+	}
+}
+
+// expectFinding asserts a findings report, either a bare list or a report
+// object with a findings field, contains the rule for the resource
+func expectFinding(ruleID, resource string) func([]byte) error {
+	return func(output []byte) error {
+		type finding struct {
+			RuleID   string `json:"rule_id"`
+			Resource string `json:"resource"`
+		}
+		var findings []finding
+		if err := json.Unmarshal(output, &findings); err != nil {
+			var report struct {
+				Findings []finding `json:"findings"`
+			}
+			if err := json.Unmarshal(output, &report); err != nil {
+				return fmt.Errorf("invalid findings report: %w", err)
+			}
+			findings = report.Findings
+		}
+		for _, f := range findings {
+			if f.RuleID == ruleID && strings.HasPrefix(f.Resource, resource) {
+				return nil
+			}
+		}
+		return fmt.Errorf("no %s finding for %s among %d findings", ruleID, resource, len(findings))
+	}
+}
@@ -0,0 +1,174 @@
+//go:build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// fixtureLabel marks every seeded object so cleanup can find objects that
+// live outside the fixture namespace
+const fixtureLabel = "k8s-toolkit.devops/e2e-fixture"
+
+// scenarios returns the broken resources the harness seeds and the reports expected for them
+func scenarios() []scenario {
+	return []scenario{
+		{
+			name:  "failed persistent volume",
+			seed:  seedFailedPV,
+			args:  []string{"health"},
+			check: expectUnhealthy("Persistent Volumes", "e2e-failed-pv"),
+		},
+		{
+			name:       "crashlooping pod",
+			needsNodes: true,
+			seed:       seedPod(fixtureNamespace, "e2e-crashloop", crashLoopSpec()),
+			ready:      podCrashLooping(fixtureNamespace, "e2e-crashloop"),
+			args:       []string{"health", "-n", fixtureNamespace},
+			check:      expectUnhealthy("Crashlooping Pods", "e2e-crashloop"),
+		},
+		{
+			name:  "privileged pod",
+			seed:  seedPod(fixtureNamespace, "e2e-privileged", privilegedSpec()),
+			args:  []string{"security", "pods", "--level", "baseline", "-n", fixtureNamespace},
+			check: expectFinding("pss/privileged", "pod/e2e-privileged"),
+		},
+		{
+			name:  "service without endpoints",
+			seed:  seedOrphanService,
+			args:  []string{"reachability", "-n", fixtureNamespace},
+			check: expectFinding("reach/no-endpoints", "service/e2e-no-endpoints"),
+		},
+	}
+}
+
+func fixtureMeta(namespace, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		Labels:    map[string]string{fixtureLabel: "true"},
+	}
+}
+
+// ensureNamespace creates the fixture namespace if it does not exist
+func ensureNamespace(ctx context.Context, cs kubernetes.Interface) error {
+	ns := &corev1.Namespace{ObjectMeta: fixtureMeta("", fixtureNamespace)}
+	_, err := cs.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	return err
+}
+
+// cleanupFixtures deletes the fixture namespace and labelled objects elsewhere
+func cleanupFixtures(ctx context.Context, cs kubernetes.Interface) {
+	selector := metav1.ListOptions{LabelSelector: fixtureLabel}
+
+	cs.CoreV1().Pods("kube-system").DeleteCollection(ctx, metav1.DeleteOptions{}, selector)
+	cs.CoreV1().PersistentVolumes().DeleteCollection(ctx, metav1.DeleteOptions{}, selector)
+	cs.CoreV1().Namespaces().Delete(ctx, fixtureNamespace, metav1.DeleteOptions{})
+}
+
+// seedFailedPV creates a retained volume bound to a claim that does not
+// exist, then marks it Failed. The PV controller leaves Failed volumes with a
+// missing claim alone, so the phase sticks.
+func seedFailedPV(ctx context.Context, cs kubernetes.Interface) error {
+	pv := &corev1.PersistentVolume{
+		ObjectMeta: fixtureMeta("", "e2e-failed-pv"),
+		Spec: corev1.PersistentVolumeSpec{
+			Capacity:                      corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+			AccessModes:                   []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			PersistentVolumeReclaimPolicy: corev1.PersistentVolumeReclaimRetain,
+			PersistentVolumeSource: corev1.PersistentVolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{Path: "/tmp/k8s-toolkit-e2e"},
+			},
+			ClaimRef: &corev1.ObjectReference{
+				Kind:      "PersistentVolumeClaim",
+				Namespace: fixtureNamespace,
+				Name:      "e2e-missing-claim",
+				UID:       types.UID("00000000-0000-0000-0000-000000000000"),
+			},
+		},
+	}
+
+	created, err := cs.CoreV1().PersistentVolumes().Create(ctx, pv, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	created.Status.Phase = corev1.VolumeFailed
+	created.Status.Message = "seeded by k8s-toolkit e2e"
+	_, err = cs.CoreV1().PersistentVolumes().UpdateStatus(ctx, created, metav1.UpdateOptions{})
+	return err
+}
+
+// seedPod returns a seed function creating a pod with the given spec
+func seedPod(namespace, name string, spec corev1.PodSpec) func(context.Context, kubernetes.Interface) error {
+	return func(ctx context.Context, cs kubernetes.Interface) error {
+		pod := &corev1.Pod{ObjectMeta: fixtureMeta(namespace, name), Spec: spec}
+		_, err := cs.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{})
+		return err
+	}
+}
+
+func crashLoopSpec() corev1.PodSpec {
+	return corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name:    "crash",
+			Image:   "busybox:1.36",
+			Command: []string{"sh", "-c", "exit 1"},
+		}},
+	}
+}
+
+func privilegedSpec() corev1.PodSpec {
+	privileged := true
+	return corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name:            "privileged",
+			Image:           "busybox:1.36",
+			Command:         []string{"sleep", "3600"},
+			SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+		}},
+	}
+}
+
+// podCrashLooping reports when any container of the pod is in CrashLoopBackOff
+func podCrashLooping(namespace, name string) func(context.Context, kubernetes.Interface) (bool, error) {
+	return func(ctx context.Context, cs kubernetes.Interface) (bool, error) {
+		pod, err := cs.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff" {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// seedOrphanService creates a service whose selector matches no pods, so it
+// never gets endpoints
+func seedOrphanService(ctx context.Context, cs kubernetes.Interface) error {
+	svc := &corev1.Service{
+		ObjectMeta: fixtureMeta(fixtureNamespace, "e2e-no-endpoints"),
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": "does-not-exist"},
+			Ports:    []corev1.ServicePort{{Name: "http", Port: 80}},
+		},
+	}
+	_, err := cs.CoreV1().Services(fixtureNamespace).Create(ctx, svc, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("create service: %w", err)
+	}
+	return nil
+}